@@ -4,21 +4,38 @@
 package commands
 
 import (
+	"context"
+	"fmt"
+	"io"
 	"os"
+	"strings"
 
+	"github.com/GoogleContainerTools/kpt/internal/gitutil"
+	"github.com/GoogleContainerTools/kpt/internal/util/conditions"
+	"github.com/GoogleContainerTools/kpt/internal/util/render"
 	"github.com/GoogleContainerTools/kpt/internal/util/setters"
+	"github.com/GoogleContainerTools/kpt/internal/util/update"
 	"github.com/GoogleContainerTools/kpt/pkg/live"
 	"github.com/GoogleContainerTools/kpt/pkg/live/preprocess"
 	"github.com/spf13/cobra"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
 	"k8s.io/klog"
+	"k8s.io/kubectl/pkg/cmd/util"
 	"sigs.k8s.io/cli-utils/cmd/apply"
 	"sigs.k8s.io/cli-utils/cmd/flagutils"
+	"sigs.k8s.io/cli-utils/cmd/printers"
+	applier "sigs.k8s.io/cli-utils/pkg/apply"
+	"sigs.k8s.io/cli-utils/pkg/apply/event"
 	"sigs.k8s.io/cli-utils/pkg/common"
 	"sigs.k8s.io/cli-utils/pkg/inventory"
 	"sigs.k8s.io/cli-utils/pkg/manifestreader"
+	"sigs.k8s.io/cli-utils/pkg/object"
 	"sigs.k8s.io/cli-utils/pkg/provider"
+	"sigs.k8s.io/kustomize/kyaml/kio"
 )
 
 // Get ApplyRunner returns a wrapper around the cli-utils apply command ApplyRunner. Sets
@@ -28,10 +45,24 @@ func GetApplyRunner(provider provider.Provider, loader manifestreader.ManifestLo
 	w := &ApplyRunnerWrapper{
 		applyRunner: applyRunner,
 		provider:    provider,
+		loader:      loader,
+		ioStreams:   ioStreams,
 	}
 	// Set the wrapper run to be the RunE function for the wrapped command.
 	applyRunner.Command.RunE = w.RunE
 	applyRunner.Command.PreRunE = w.PreRunE
+	applyRunner.Command.Flags().BoolVar(&w.continueOnError, "continue-on-error", false,
+		"If true, continue applying the resources which don't depend on a resource which failed.")
+	applyRunner.Command.Flags().BoolVar(&w.auditEvents, "audit-events", false,
+		"If true, record the apply run as an Event on the inventory object.")
+	applyRunner.Command.Flags().BoolVar(&w.resume, "resume", false,
+		"If true, resume an interrupted apply, skipping the resources it already applied.")
+	applyRunner.Command.Flags().BoolVar(&w.verifyRendered, "verify-rendered", false,
+		"If true, refuse to apply the package unless its committed files match a fresh render of it.")
+	applyRunner.Command.Flags().StringArrayVar(&w.clusterVars, "cluster-var", nil,
+		"Cluster variable to inject as NAME=VALUE, overriding the value resolved from the cluster.")
+	applyRunner.Command.Flags().StringArrayVar(&w.setValues, "set", nil,
+		"Setter value to inject as NAME=VALUE, without changing the package.")
 	return w
 }
 
@@ -40,6 +71,16 @@ func GetApplyRunner(provider provider.Provider, loader manifestreader.ManifestLo
 type ApplyRunnerWrapper struct {
 	applyRunner *apply.ApplyRunner
 	provider    provider.Provider
+	loader      manifestreader.ManifestLoader
+	ioStreams   genericclioptions.IOStreams
+
+	continueOnError bool
+	auditEvents     bool
+	resume          bool
+	verifyRendered  bool
+	clusterVars     []string
+	setValues       []string
+	values          map[string]string
 }
 
 // Command returns the wrapped ApplyRunner cobraCommand structure.
@@ -48,8 +89,13 @@ func (w *ApplyRunnerWrapper) Command() *cobra.Command {
 }
 
 func (w *ApplyRunnerWrapper) PreRunE(_ *cobra.Command, args []string) error {
+	values, err := setters.ParseSetValues(w.setValues)
+	if err != nil {
+		return err
+	}
+	w.values = values
 	if len(args) > 0 {
-		if err := setters.CheckForRequiredSetters(args[0]); err != nil {
+		if err := setters.CheckForRequiredSetters(args[0], w.values); err != nil {
 			return err
 		}
 	}
@@ -74,5 +120,328 @@ func (w *ApplyRunnerWrapper) RunE(cmd *cobra.Command, args []string) error {
 			return preprocess.PreProcess(w.provider, inv, strategy)
 		}
 	}
-	return w.applyRunner.RunE(cmd, args)
+	return w.run(cmd, args)
+}
+
+// run mirrors the wrapped ApplyRunner RunE, which doesn't expose its flags
+// or events.  The progress of the apply is persisted until it completes, so
+// an interrupted apply can be resumed with --resume.  With
+// --continue-on-error the resources are applied in dependency order,
+// continuing past resources which fail to apply, and a summary of the
+// resources which failed or were skipped is printed.  With --audit-events
+// the run is recorded as an Event on the inventory object.
+func (w *ApplyRunnerWrapper) run(cmd *cobra.Command, args []string) error {
+	flags := cmd.Flags()
+	var options applier.Options
+	var err error
+	if options.ServerSideOptions.ServerSideApply, err = flags.GetBool("server-side"); err != nil {
+		return err
+	}
+	if options.ServerSideOptions.ForceConflicts, err = flags.GetBool("force-conflicts"); err != nil {
+		return err
+	}
+	if options.ServerSideOptions.FieldManager, err = flags.GetString("field-manager"); err != nil {
+		return err
+	}
+	if options.PollInterval, err = flags.GetDuration("poll-period"); err != nil {
+		return err
+	}
+	if options.ReconcileTimeout, err = flags.GetDuration("reconcile-timeout"); err != nil {
+		return err
+	}
+	if options.NoPrune, err = flags.GetBool("no-prune"); err != nil {
+		return err
+	}
+	if options.PruneTimeout, err = flags.GetDuration("prune-timeout"); err != nil {
+		return err
+	}
+	output, err := flags.GetString("output")
+	if err != nil {
+		return err
+	}
+	policy, err := flags.GetString("prune-propagation-policy")
+	if err != nil {
+		return err
+	}
+	switch metav1.DeletionPropagation(policy) {
+	case metav1.DeletePropagationForeground, metav1.DeletePropagationBackground, metav1.DeletePropagationOrphan:
+		options.PrunePropagationPolicy = metav1.DeletionPropagation(policy)
+	default:
+		return fmt.Errorf("prune propagation policy must be one of Background, Foreground, Orphan")
+	}
+	if options.InventoryPolicy, err = flagutils.ConvertInventoryPolicy(
+		w.Command().Flag(flagutils.InventoryPolicyFlag).Value.String()); err != nil {
+		return err
+	}
+	// only emit status events if we are waiting for status
+	options.EmitStatusEvents = options.ReconcileTimeout != 0 || options.PruneTimeout != 0
+	options.DryRunStrategy = common.DryRunNone
+
+	if _, err := common.DemandOneDirectory(args); err != nil {
+		return err
+	}
+	if w.verifyRendered {
+		if err := verifyRendered(flagutils.PathFromArgs(args), w.ioStreams.ErrOut); err != nil {
+			return err
+		}
+	}
+	reader, err := w.loader.ManifestReader(cmd.InOrStdin(), flagutils.PathFromArgs(args))
+	if err != nil {
+		return err
+	}
+	objs, err := reader.Read()
+	if err != nil {
+		return err
+	}
+	inv, objs, err := w.loader.InventoryInfo(objs)
+	if err != nil {
+		return err
+	}
+	if objs, err = excludeConditions(flagutils.PathFromArgs(args), objs); err != nil {
+		return err
+	}
+	if err := injectClusterVars(cmd, w.provider.Factory(), w.clusterVars, objs); err != nil {
+		return err
+	}
+	if err := injectSetters(flagutils.PathFromArgs(args), w.values, objs); err != nil {
+		return err
+	}
+	if w.applyRunner.PreProcess != nil {
+		if options.InventoryPolicy, err = w.applyRunner.PreProcess(inv, common.DryRunNone); err != nil {
+			return err
+		}
+	}
+
+	if err := w.applyRunner.Applier.Initialize(); err != nil {
+		return err
+	}
+	progress, err := w.loadProgress(inv)
+	if err != nil {
+		return err
+	}
+	prunePending := false
+	if w.resume {
+		remaining, skipped, err := progress.Remaining(objs, options.ReconcileTimeout != 0)
+		if err != nil {
+			return err
+		}
+		if skipped > 0 {
+			fmt.Fprintf(w.ioStreams.ErrOut, "resuming apply: skipping %d resource(s) already applied\n", skipped)
+			objs = remaining
+			// pruning needs every resource of the package
+			prunePending = !options.NoPrune
+			options.NoPrune = true
+		}
+	} else if progress != nil {
+		progress.Resources = map[string]live.ResourceProgress{}
+	}
+
+	var record *live.AuditRecord
+	if w.auditEvents {
+		record = live.NewAuditRecord(w.provider.Factory())
+		record.Commit = packageCommit(flagutils.PathFromArgs(args))
+	}
+	err = w.apply(inv, objs, options, output, record, progress)
+	if progress != nil {
+		saveErr := progress.Save()
+		if err == nil {
+			saveErr = progress.Remove()
+		}
+		if saveErr != nil {
+			fmt.Fprintf(w.ioStreams.ErrOut, "failed to save the apply progress: %v\n", saveErr)
+		}
+	}
+	if err == nil && prunePending {
+		fmt.Fprintln(w.ioStreams.ErrOut, "prune skipped: resumed applies don't prune, run kpt live apply to prune")
+	}
+	if record != nil {
+		record.Finish(err)
+		if err := live.RecordAuditEvent(w.provider.Factory(), inv, record); err != nil {
+			fmt.Fprintf(w.ioStreams.ErrOut, "failed to record the audit event: %v\n", err)
+		}
+	}
+	return err
+}
+
+// verifyRendered returns an error if the package at dir differs from a fresh
+// render of it, printing the differences to out.
+func verifyRendered(dir string, out io.Writer) error {
+	if dir == "" || dir == "-" {
+		return fmt.Errorf("--verify-rendered requires a package directory")
+	}
+	changes, err := render.Verify(dir)
+	if err != nil {
+		return err
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+	if err := update.PrintChanges(out, update.DryRunDiff, changes); err != nil {
+		return err
+	}
+	return fmt.Errorf("package %q differs from its render in %d file(s) "+
+		"-- render and commit the package before applying it", dir, len(changes))
+}
+
+// injectClusterVars sets the fields of objs designated by their
+// live.ClusterVarsAnnotation to the variables of the cluster of f, or the
+// NAME=VALUE pairs.
+func injectClusterVars(cmd *cobra.Command, f util.Factory, pairs []string,
+	objs []*unstructured.Unstructured) error {
+	values, err := live.ParseClusterVars(pairs)
+	if err != nil {
+		return err
+	}
+	var kubeContext string
+	if flag := cmd.Flag("context"); flag != nil {
+		kubeContext = flag.Value.String()
+	}
+	vars, err := live.NewClusterVars(f, kubeContext, values)
+	if err != nil {
+		return err
+	}
+	return vars.Inject(objs)
+}
+
+// injectSetters sets the fields of objs which reference the secret setters
+// of the package at dir, or the setters of values, to the secret and
+// injected values.  The resources are read again from the package, as objs
+// have lost the comments referencing the setters.
+func injectSetters(dir string, values map[string]string, objs []*unstructured.Unstructured) error {
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		// resources may be read from stdin rather than a package
+		if len(values) > 0 {
+			return fmt.Errorf("--set requires a package directory")
+		}
+		return nil
+	}
+	nodes, err := (&kio.LocalPackageReader{PackagePath: dir}).Read()
+	if err != nil {
+		return err
+	}
+	fields, err := setters.Inject(dir, nodes, values)
+	if err != nil {
+		return err
+	}
+	var fieldValues []live.FieldValue
+	for _, f := range fields {
+		gk := schema.FromAPIVersionAndKind(f.Resource.APIVersion, f.Resource.Kind).GroupKind()
+		fieldValues = append(fieldValues, live.FieldValue{
+			Object: object.ObjMetadata{GroupKind: gk, Namespace: f.Resource.Namespace, Name: f.Resource.Name},
+			Path:   f.Path,
+			Value:  f.Value,
+		})
+	}
+	return live.SetFields(objs, fieldValues)
+}
+
+// excludeConditions returns objs without the resources of the package at
+// dir which are excluded by their conditions.
+func excludeConditions(dir string, objs []*unstructured.Unstructured) ([]*unstructured.Unstructured, error) {
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		// resources read from stdin have no conditions
+		return objs, nil
+	}
+	conds, err := conditions.Read(dir)
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := (&kio.LocalPackageReader{PackagePath: dir, IncludeSubpackages: true}).Read()
+	if err != nil {
+		return nil, err
+	}
+	_, excluded, err := conds.Filter(nodes)
+	if err != nil {
+		return nil, err
+	}
+	var ids []object.ObjMetadata
+	for _, n := range excluded {
+		meta, err := n.GetMeta()
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, object.ObjMetadata{
+			GroupKind: schema.FromAPIVersionAndKind(meta.APIVersion, meta.Kind).GroupKind(),
+			Namespace: meta.Namespace,
+			Name:      meta.Name,
+		})
+	}
+	return live.ExcludeObjects(objs, ids), nil
+}
+
+// apply applies objs and prints the events, recording them in record and
+// progress if they are non-nil.
+func (w *ApplyRunnerWrapper) apply(inv inventory.InventoryInfo, objs []*unstructured.Unstructured,
+	options applier.Options, output string, record *live.AuditRecord, progress *live.ApplyProgress) error {
+	printer := printers.GetPrinter(output, w.ioStreams)
+	tap := func(ch <-chan event.Event) <-chan event.Event {
+		if record != nil {
+			ch = record.Tap(ch)
+		}
+		if progress != nil {
+			ch = progress.Tap(objs, ch)
+		}
+		return ch
+	}
+	methods, err := live.NewMethodApplier(w.provider.Factory(), w.applyRunner.Applier.Run, w.ioStreams.ErrOut)
+	if err != nil {
+		return err
+	}
+	if !w.continueOnError {
+		ch := methods.Run(context.Background(), inv, objs, options)
+		return printer.Print(tap(ch), common.DryRunNone)
+	}
+
+	ch, result, err := live.IsolatingApplier{Apply: methods.Run}.Run(
+		context.Background(), inv, objs, options)
+	if err != nil {
+		return err
+	}
+	// the printer error counts the failed resources, which the summary
+	// replaces
+	err = printer.Print(tap(ch), common.DryRunNone)
+	result.WriteSummary(w.ioStreams.Out)
+	if result.Aborted != nil {
+		return result.Aborted
+	}
+	if err := result.Err(); err != nil {
+		return err
+	}
+	return err
+}
+
+// loadProgress loads the progress of the apply of the package with the
+// inventory inv.  Returns nil if the progress can't be persisted, unless
+// resuming.
+func (w *ApplyRunnerWrapper) loadProgress(inv inventory.InventoryInfo) (*live.ApplyProgress, error) {
+	dir, err := live.ProgressDir()
+	if err == nil {
+		var p *live.ApplyProgress
+		if p, err = live.LoadApplyProgress(dir, inv); err == nil {
+			return p, nil
+		}
+	}
+	if w.resume {
+		return nil, err
+	}
+	fmt.Fprintf(w.ioStreams.ErrOut, "the apply progress won't be saved: %v\n", err)
+	return nil, nil
+}
+
+// packageCommit returns the git commit of the package at dir, suffixed with
+// -dirty if the package has uncommitted changes, or "" if dir isn't in git.
+func packageCommit(dir string) string {
+	if dir == "" || dir == "-" {
+		return ""
+	}
+	g := gitutil.NewLocalGitRunner(dir)
+	if err := g.Run("rev-parse", "HEAD"); err != nil {
+		return ""
+	}
+	commit := strings.TrimSpace(g.Stdout.String())
+	g = gitutil.NewLocalGitRunner(dir)
+	if err := g.Run("status", "--porcelain", "."); err == nil && strings.TrimSpace(g.Stdout.String()) != "" {
+		commit += "-dirty"
+	}
+	return commit
 }
@@ -15,10 +15,12 @@
 package commands
 
 import (
+	"os"
 	"strings"
 
 	"github.com/GoogleContainerTools/kpt/internal/cmddesc"
 	"github.com/GoogleContainerTools/kpt/internal/cmddiff"
+	"github.com/GoogleContainerTools/kpt/internal/cmddoctor"
 	"github.com/GoogleContainerTools/kpt/internal/cmdfix"
 	"github.com/GoogleContainerTools/kpt/internal/cmdget"
 	"github.com/GoogleContainerTools/kpt/internal/cmdinit"
@@ -54,12 +56,15 @@ func GetKptCommands(name string, f util.Factory) []*cobra.Command {
 	var c []*cobra.Command
 	cfgCmd := GetConfigCommand(name)
 	fnCmd := GetFnCommand(name)
-	pkgCmd := GetPkgCommand(name)
+	pkgCmd := GetPkgCommand(name, f)
 	ttlCmd := GetTTLCommand(name)
 	liveCmd := GetLiveCommand(name, f)
 	guideCmd := GetGuideCommand(name)
+	cacheCmd := GetCacheCommand(name)
+	doctor := cmddoctor.NewRunner(name, f)
+	_, doctor.Doctor.ResourceGroup = os.LookupEnv(resourceGroupEnv)
 
-	c = append(c, cfgCmd, pkgCmd, fnCmd, ttlCmd, liveCmd, guideCmd)
+	c = append(c, cfgCmd, pkgCmd, fnCmd, ttlCmd, liveCmd, guideCmd, cacheCmd, doctor.Command)
 
 	// apply cross-cutting issues to commands
 	NormalizeCommand(c...)
@@ -15,15 +15,43 @@
 package commands
 
 import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strings"
 
+	"github.com/GoogleContainerTools/kpt/internal/cmdannotate"
+	"github.com/GoogleContainerTools/kpt/internal/cmdcascade"
+	"github.com/GoogleContainerTools/kpt/internal/cmdcfgdiff"
+	"github.com/GoogleContainerTools/kpt/internal/cmddiffprofiles"
+	"github.com/GoogleContainerTools/kpt/internal/cmdlabel"
+	"github.com/GoogleContainerTools/kpt/internal/cmdredact"
+	"github.com/GoogleContainerTools/kpt/internal/cmdrenamesetter"
 	"github.com/GoogleContainerTools/kpt/internal/cmdsearch"
+	"github.com/GoogleContainerTools/kpt/internal/cmdsetcondition"
+	"github.com/GoogleContainerTools/kpt/internal/cmdsethistory"
 	"github.com/GoogleContainerTools/kpt/internal/docs/generated/cfgdocs"
+	cfgcat "github.com/GoogleContainerTools/kpt/internal/util/cat"
+	"github.com/GoogleContainerTools/kpt/internal/util/cfgtree"
+	cfgcount "github.com/GoogleContainerTools/kpt/internal/util/count"
+	"github.com/GoogleContainerTools/kpt/internal/util/fieldpath"
+	"github.com/GoogleContainerTools/kpt/internal/util/format"
 	"github.com/GoogleContainerTools/kpt/internal/util/functions"
+	"github.com/GoogleContainerTools/kpt/internal/util/grep"
+	kptopenapi "github.com/GoogleContainerTools/kpt/internal/util/openapi"
+	"github.com/GoogleContainerTools/kpt/internal/util/pipe"
 	"github.com/GoogleContainerTools/kpt/internal/util/setters"
+	"github.com/GoogleContainerTools/kpt/pkg/kptfile"
 	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/labels"
 	"sigs.k8s.io/kustomize/cmd/config/configcobra"
 	"sigs.k8s.io/kustomize/kyaml/fieldmeta"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/kio/filters"
+	"sigs.k8s.io/kustomize/kyaml/pathutil"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
 )
 
 const ShortHandRef = "$kpt-set"
@@ -47,17 +75,20 @@ func GetConfigCommand(name string) *cobra.Command {
 		},
 	}
 
-	an := configcobra.Annotate(name)
-	an.Short = cfgdocs.AnnotateShort
-	an.Long = cfgdocs.AnnotateShort + "\n" + cfgdocs.AnnotateLong
-	an.Example = cfgdocs.AnnotateExamples
+	an := cmdannotate.NewCommand(name)
 
-	cat := configcobra.Cat(name)
+	cascade := cmdcascade.NewCommand(name)
+
+	diff := cmdcfgdiff.NewCommand(name)
+
+	diffProfiles := cmddiffprofiles.NewCommand(name)
+
+	cat := CatCommand(name)
 	cat.Short = cfgdocs.CatShort
 	cat.Long = cfgdocs.CatShort + "\n" + cfgdocs.CatLong
 	cat.Example = cfgdocs.CatExamples
 
-	count := configcobra.Count(name)
+	count := CountCommand(name)
 	count.Short = cfgdocs.CountShort
 	count.Long = cfgdocs.CountShort + "\n" + cfgdocs.CountLong
 	count.Example = cfgdocs.CountExamples
@@ -82,32 +113,58 @@ func GetConfigCommand(name string) *cobra.Command {
 	createSubstitution.Long = cfgdocs.CreateSubstShort + "\n" + cfgdocs.CreateSubstLong
 	createSubstitution.Example = cfgdocs.CreateSubstExamples
 
-	fmt := configcobra.Fmt(name)
+	fmt := FmtCommand(name)
 	fmt.Short = cfgdocs.FmtShort
 	fmt.Long = cfgdocs.FmtShort + "\n" + cfgdocs.FmtLong
 	fmt.Example = cfgdocs.FmtExamples
 
-	grep := configcobra.Grep(name)
+	grep := GrepCommand(name)
 	grep.Short = cfgdocs.GrepShort
 	grep.Long = cfgdocs.GrepShort + "\n" + cfgdocs.GrepLong
 	grep.Example = cfgdocs.GrepExamples
 
-	listSetters := configcobra.ListSetters(name)
+	label := cmdlabel.NewCommand(name)
+
+	listSetters := ListSettersCommand(name)
 	listSetters.Short = cfgdocs.ListSettersShort
 	listSetters.Long = cfgdocs.ListSettersShort + "\n" + cfgdocs.ListSettersLong
 	listSetters.Example = cfgdocs.ListSettersExamples
 
+	redact := cmdredact.NewCommand(name)
+
+	renameSetter := cmdrenamesetter.NewCommand(name)
+
 	set := SetCommand(name)
 
+	setCondition := cmdsetcondition.NewCommand(name)
+
+	setHistory := cmdsethistory.NewCommand(name)
+
 	search := cmdsearch.SearchCommand(name)
 
-	tree := configcobra.Tree(name)
+	tree := TreeCommand(name)
 	tree.Short = cfgdocs.TreeShort
 	tree.Long = cfgdocs.TreeShort + "\n" + cfgdocs.TreeLong
 	tree.Example = cfgdocs.TreeExamples
 
-	cfgCmd.AddCommand(an, cat, count, createSetter, deleteSetter, deleteSubstitution, createSubstitution, fmt,
-		grep, listSetters, set, tree)
+	// read resources from stdin when the directory is "-"
+	for _, c := range []struct {
+		cmd  *cobra.Command
+		pipe pipe.Command
+	}{
+		{an, pipe.Command{Omitted: true, TextFlag: "dry-run"}},
+		{cat, pipe.Command{Omitted: true, TextFlag: "output"}},
+		{count, pipe.Command{Omitted: true, Text: true}},
+		{fmt, pipe.Command{Omitted: true}},
+		{label, pipe.Command{Omitted: true, TextFlag: "dry-run"}},
+		{grep, pipe.Command{Arg: 1, Omitted: true, TextFlag: "output"}},
+		{tree, pipe.Command{Text: true, KeepArg: true}},
+	} {
+		c.pipe.Wrap(c.cmd)
+	}
+
+	cfgCmd.AddCommand(an, cascade, cat, count, createSetter, deleteSetter, deleteSubstitution, createSubstitution,
+		diff, diffProfiles, fmt, grep, label, listSetters, redact, renameSetter, set, setCondition, setHistory, tree)
 
 	if enableSearchCmd := os.Getenv("KPT_ENABLE_SEARCH_CMD"); enableSearchCmd != "" {
 		cfgCmd.AddCommand(search)
@@ -115,28 +172,662 @@ func GetConfigCommand(name string) *cobra.Command {
 	return cfgCmd
 }
 
+// CreateSetterCommand wraps the kustomize create-setter command in order to
+// constrain the setter values with flags, which are added to the schema of
+// the setter, and to create secret setters.
 func CreateSetterCommand(parent string) *cobra.Command {
 	fieldmeta.SetShortHandRef(ShortHandRef)
-	return configcobra.CreateSetter(parent)
+	kustomizeCmd := configcobra.CreateSetter(parent)
+	var minimum, maximum float64
+	var constraints setters.Constraints
+	kustomizeCmd.Flags().Float64Var(&minimum, "minimum", 0,
+		`Minimum value of an integer or number setter`)
+	kustomizeCmd.Flags().Float64Var(&maximum, "maximum", 0,
+		`Maximum value of an integer or number setter`)
+	kustomizeCmd.Flags().StringSliceVar(&constraints.Enum, "enum", nil,
+		`Allowed values of the setter`)
+	kustomizeCmd.Flags().StringVar(&constraints.Pattern, "pattern", "",
+		`Regular expression values of the setter must match`)
+	kustomizeCmd.Flags().StringVar(&constraints.Format, "format", "",
+		`Format of the values of the setter -- one of dns-label, dns-subdomain, ip`)
+	var secretFrom string
+	kustomizeCmd.Flags().StringVar(&secretFrom, "secret-from", "",
+		`Source of the value of a secret setter -- env:NAME, file:PATH or gcp-secret-manager:SECRET`)
+	var compute string
+	kustomizeCmd.Flags().StringVar(&compute, "compute", "",
+		`Expression the value of a computed setter is computed from, referencing other setters, e.g. {name}.{zone}`)
+	var pathRefs fieldPathRefs
+	preRunE := kustomizeCmd.PreRunE
+	kustomizeCmd.PreRunE = func(c *cobra.Command, args []string) error {
+		if compute != "" {
+			if len(args) != 2 || c.Flag("value").Changed {
+				return fmt.Errorf("the value of computed setters is computed, it can't be provided")
+			}
+			// the fields with the computed value reference the setter
+			value, err := setters.ComputeValue(args[0], args[1], compute)
+			if err != nil {
+				return err
+			}
+			if err := c.Flags().Set("value", value); err != nil {
+				return err
+			}
+		}
+		if c.Flag("minimum").Changed {
+			constraints.Minimum = &minimum
+		}
+		if c.Flag("maximum").Changed {
+			constraints.Maximum = &maximum
+		}
+		typ, _ := c.Flags().GetString("type")
+		if typ == setters.SecretType && compute != "" {
+			return fmt.Errorf("secret setters can't be computed")
+		}
+		if typ == setters.SecretType {
+			// secret setters are string setters recording their source
+			typ = "string"
+			if err := c.Flags().Set("type", typ); err != nil {
+				return err
+			}
+			if secretFrom == "" && len(args) > 1 {
+				secretFrom = setters.DefaultSecretSource(args[1])
+			}
+			if err := setters.CheckSecretSource(secretFrom); err != nil {
+				return err
+			}
+		} else if secretFrom != "" {
+			return fmt.Errorf("--secret-from requires --type %s", setters.SecretType)
+		}
+		if err := pathRefs.init(c, args); err != nil {
+			return err
+		}
+		if constraints.IsEmpty() {
+			return preRunE(c, args)
+		}
+
+		// the kustomize command reads the schema from the schema file, so
+		// the constraints are added to a copy of it
+		schemaPath, _ := c.Flags().GetString("schema-path")
+		value, _ := c.Flags().GetString("value")
+		if len(args) > 2 {
+			value = args[2]
+		}
+		schema, err := constraints.Schema(schemaPath, typ, value)
+		if err != nil {
+			return err
+		}
+		f, err := ioutil.TempFile("", "kpt-setter-schema")
+		if err != nil {
+			return err
+		}
+		defer os.Remove(f.Name())
+		_, err = f.Write(schema)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return err
+		}
+		if err := c.Flags().Set("schema-path", f.Name()); err != nil {
+			return err
+		}
+		return preRunE(c, args)
+	}
+	runE := kustomizeCmd.RunE
+	kustomizeCmd.RunE = func(c *cobra.Command, args []string) error {
+		if err := runE(c, args); err != nil {
+			return err
+		}
+		if err := pathRefs.limit(); err != nil {
+			return err
+		}
+		if secretFrom == "" && compute == "" {
+			return nil
+		}
+		// the kustomize command drops the extensions of the schema, so the
+		// source and expression are added to the definitions it created
+		paths := []string{args[0]}
+		if recurse, _ := c.Flags().GetBool("recurse-subpackages"); recurse {
+			var err error
+			if paths, err = pathutil.DirsWithFile(args[0], kptfile.KptFileName, true); err != nil {
+				return err
+			}
+		}
+		for _, p := range paths {
+			if !setters.DefExists(p, args[1]) {
+				continue
+			}
+			if secretFrom != "" {
+				if err := setters.MarkSecret(p, args[1], secretFrom); err != nil {
+					return err
+				}
+			}
+			if compute != "" {
+				if err := setters.MarkComputed(p, args[1], compute); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	return kustomizeCmd
+}
+
+// fieldPathRefs references a setter being created from the fields selected
+// by a --field path with list selectors, such as containers[*].image.
+type fieldPathRefs struct {
+	ref setters.FieldPathRef
+
+	// paths are the packages the setter is created in
+	paths []string
+}
+
+// init checks the fields selected by the --field path, if it has list
+// selectors, in the packages the setter will be created in, and replaces
+// it with the path without its selectors for the kustomize command to add
+// references to the fields matching it.
+func (r *fieldPathRefs) init(c *cobra.Command, args []string) error {
+	field, _ := c.Flags().GetString("field")
+	if !strings.Contains(field, "[") {
+		return nil
+	}
+	p, err := fieldpath.Parse(field)
+	if err != nil {
+		return err
+	}
+	if typ, _ := c.Flags().GetString("type"); typ == "array" {
+		return fmt.Errorf("--field can't select list elements for array setters")
+	}
+	r.ref = setters.FieldPathRef{Name: args[1], Path: field}
+	r.ref.Value, _ = c.Flags().GetString("value")
+	if len(args) > 2 {
+		r.ref.Value = args[2]
+	}
+	paths := []string{args[0]}
+	if recurse, _ := c.Flags().GetBool("recurse-subpackages"); recurse {
+		if paths, err = pathutil.DirsWithFile(args[0], kptfile.KptFileName, true); err != nil {
+			return err
+		}
+	}
+	for _, path := range paths {
+		// the setter isn't created in packages already defining it
+		if setters.DefExists(path, args[1]) {
+			continue
+		}
+		if err := r.ref.Check(path); err != nil {
+			return err
+		}
+		r.paths = append(r.paths, path)
+	}
+	return c.Flags().Set("field", p.Fields())
 }
 
+// limit limits the references added by the kustomize command to the
+// fields selected by the --field path.
+func (r *fieldPathRefs) limit() error {
+	for _, path := range r.paths {
+		if _, err := r.ref.Limit(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListSettersCommand wraps the kustomize list-setters command in order to
+// list the setters as json or yaml, including the fields they set, for
+// tools to consume, and to redact the values of secret setters.
+func ListSettersCommand(parent string) *cobra.Command {
+	fieldmeta.SetShortHandRef(ShortHandRef)
+	kustomizeCmd := configcobra.ListSetters(parent)
+	var output string
+	kustomizeCmd.Flags().StringVarP(&output, "output", "o", "",
+		`Output format -- json or yaml.  Defaults to a table`)
+	kustomizeCmd.RunE = func(c *cobra.Command, args []string) error {
+		var name string
+		if len(args) > 1 {
+			name = args[1]
+		}
+		recurse, _ := c.Flags().GetBool("recurse-subpackages")
+		switch output {
+		case "":
+			markdown, _ := c.Flags().GetBool("markdown")
+			includeSubst, _ := c.Flags().GetBool("include-subst")
+			return setters.WriteTable(c.OutOrStdout(), args[0], name, recurse, markdown, includeSubst)
+		case setters.JSONOutput, setters.YAMLOutput:
+		default:
+			return fmt.Errorf("unsupported output format %q, must be %s or %s",
+				output, setters.JSONOutput, setters.YAMLOutput)
+		}
+		infos, err := setters.ListSetters(args[0], name, recurse)
+		if err != nil {
+			return err
+		}
+		if name != "" && len(infos) == 0 {
+			return fmt.Errorf("setter %q is not defined", name)
+		}
+		return setters.WriteSetters(c.OutOrStdout(), output, infos)
+	}
+	return kustomizeCmd
+}
+
+// CatCommand wraps the kustomize cat command in order to select the
+// resources printed by kind, name, namespace and labels, and print them as
+// json or a single yaml document.
+func CatCommand(parent string) *cobra.Command {
+	kustomizeCmd := configcobra.Cat(parent)
+	var selector cfgcat.Selector
+	var labelSelector, output string
+	kustomizeCmd.Flags().StringVar(&selector.Kind, "kind", "",
+		"only print resources of this kind.")
+	kustomizeCmd.Flags().StringVar(&selector.Name, "name", "",
+		"only print resources with this name.")
+	kustomizeCmd.Flags().StringVar(&selector.Namespace, "namespace", "",
+		"only print resources in this namespace.")
+	kustomizeCmd.Flags().StringVar(&labelSelector, "label", "",
+		"label selector of the resources to print, e.g. app=web,tier!=db")
+	kustomizeCmd.Flags().StringVarP(&output, "output", "o", cfgcat.YAMLStreamOutput,
+		"output format -- yaml-stream, json or single-doc.")
+	runE := kustomizeCmd.RunE
+	kustomizeCmd.RunE = func(c *cobra.Command, args []string) error {
+		selector.Labels = nil
+		if labelSelector != "" {
+			var err error
+			if selector.Labels, err = labels.Parse(labelSelector); err != nil {
+				return err
+			}
+		}
+		if err := cfgcat.ValidateOutput(output); err != nil {
+			return err
+		}
+		if selector.Empty() && output == cfgcat.YAMLStreamOutput && !c.Flag("output").Changed {
+			return runE(c, args)
+		}
+
+		// capture the resources printed by kustomize, and write them to
+		// dest ourselves once selected
+		dest, _ := c.Flags().GetString("dest")
+		if err := c.Flags().Set("dest", ""); err != nil {
+			return err
+		}
+		out, buf := c.OutOrStdout(), &bytes.Buffer{}
+		c.SetOut(buf)
+		err := runE(c, args)
+		c.SetOut(out)
+		if err != nil {
+			return err
+		}
+
+		r := &kio.ByteReader{Reader: buf, OmitReaderAnnotations: true}
+		nodes, err := r.Read()
+		if err != nil {
+			return err
+		}
+		if nodes, err = selector.Filter(nodes); err != nil {
+			return err
+		}
+		// remove the annotations recorded reading stdin, as the output
+		// isn't post-processed when its format is set
+		if err := pipe.Clean(nodes); err != nil {
+			return err
+		}
+		bw := kio.ByteWriter{
+			WrappingKind:       r.WrappingKind,
+			WrappingAPIVersion: r.WrappingAPIVersion,
+			FunctionConfig:     r.FunctionConfig,
+		}
+		if dest == "" {
+			return cfgcat.Write(out, output, nodes, bw)
+		}
+		f, err := os.Create(dest)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		return cfgcat.Write(f, output, nodes, bw)
+	}
+	return kustomizeCmd
+}
+
+// CountCommand wraps the kustomize count command in order to group the
+// counts by other dimensions than kind, and print them as a table, json or
+// csv.
+func CountCommand(parent string) *cobra.Command {
+	kustomizeCmd := configcobra.Count(parent)
+	var groupBy []string
+	var output string
+	kustomizeCmd.Flags().StringSliceVar(&groupBy, "group-by", nil,
+		"count resources grouped by kind, apiVersion, namespace, file or label:KEY.")
+	kustomizeCmd.Flags().StringVarP(&output, "output", "o", "",
+		`Output format -- table, json or csv.  Defaults to kind totals`)
+	runE := kustomizeCmd.RunE
+	kustomizeCmd.RunE = func(c *cobra.Command, args []string) error {
+		if !c.Flag("group-by").Changed && output == "" {
+			return runE(c, args)
+		}
+		if !c.Flag("group-by").Changed {
+			if kind, _ := c.Flags().GetBool("kind"); kind {
+				groupBy = []string{cfgcount.Kind}
+			}
+		}
+		if err := cfgcount.ValidateGroupBy(groupBy); err != nil {
+			return err
+		}
+		recurse, _ := c.Flags().GetBool("recurse-subpackages")
+		var input kio.Reader = &kio.ByteReader{Reader: c.InOrStdin()}
+		if len(args) > 0 {
+			input = kio.LocalPackageReader{
+				PackagePath:        args[0],
+				PackageFileName:    kptfile.KptFileName,
+				IncludeSubpackages: recurse,
+			}
+		}
+		var report cfgcount.Report
+		err := kio.Pipeline{
+			Inputs: []kio.Reader{input},
+			Outputs: []kio.Writer{kio.WriterFunc(func(nodes []*yaml.RNode) error {
+				var err error
+				report, err = cfgcount.Count(nodes, groupBy)
+				return err
+			})},
+		}.Execute()
+		if err != nil {
+			return err
+		}
+		return cfgcount.Write(c.OutOrStdout(), output, report)
+	}
+	return kustomizeCmd
+}
+
+// FmtCommand wraps the kustomize fmt command in order to format packages
+// with the formatting policy declared by their Kptfile, and to check that
+// packages are formatted.
+func FmtCommand(parent string) *cobra.Command {
+	kustomizeCmd := configcobra.Fmt(parent)
+	var check bool
+	kustomizeCmd.Flags().BoolVar(&check, "check", false,
+		"if true, don't format the packages but fail listing the files which aren't formatted.")
+	runE := kustomizeCmd.RunE
+	kustomizeCmd.RunE = func(c *cobra.Command, args []string) error {
+		if len(args) == 0 {
+			if check {
+				return fmt.Errorf("--check requires a package directory")
+			}
+			return runE(c, args)
+		}
+		recurse, _ := c.Flags().GetBool("recurse-subpackages")
+		useSchema, _ := c.Flags().GetBool("use-schema")
+		setFilenames, _ := c.Flags().GetBool("set-filenames")
+
+		var paths []string
+		policies := map[string]*kptfile.Formatting{}
+		var found bool
+		for _, root := range args {
+			root = filepath.Clean(root)
+			pkgs, err := pathutil.DirsWithFile(root, kptfile.KptFileName, recurse)
+			if err != nil {
+				return err
+			}
+			if len(pkgs) == 0 || pkgs[0] != root {
+				pkgs = append([]string{root}, pkgs...)
+			}
+			for _, p := range pkgs {
+				dir := p
+				if info, err := os.Stat(p); err == nil && !info.IsDir() {
+					// files are formatted with the policy of their package
+					dir = filepath.Dir(p)
+				}
+				policy, err := format.Policy(dir)
+				if err != nil {
+					return err
+				}
+				found = found || policy != nil
+				paths = append(paths, p)
+				policies[p] = policy
+			}
+		}
+		if !found && !check {
+			return runE(c, args)
+		}
+		if setFilenames && found {
+			return fmt.Errorf("--set-filenames isn't supported for packages with a formatting policy")
+		}
+
+		var unformatted int
+		for _, p := range paths {
+			var policy kptfile.Formatting
+			if policies[p] != nil {
+				policy = *policies[p]
+			}
+			files, err := format.Package(p, policy, useSchema, !check)
+			if err != nil {
+				return err
+			}
+			if !check {
+				fmt.Fprintf(c.OutOrStdout(), "formatted resource files in %q\n", p)
+				continue
+			}
+			for _, f := range files {
+				fmt.Fprintln(c.OutOrStdout(), f)
+			}
+			unformatted += len(files)
+		}
+		if unformatted > 0 {
+			return fmt.Errorf("%d resource file(s) aren't formatted, run kpt cfg fmt to format them", unformatted)
+		}
+		return nil
+	}
+	return kustomizeCmd
+}
+
+// GrepCommand wraps the kustomize grep command in order to select resources
+// with JSONPath queries over their fields.
+func GrepCommand(parent string) *cobra.Command {
+	kustomizeCmd := configcobra.Grep(parent)
+	var expr bool
+	var output string
+	kustomizeCmd.Flags().BoolVar(&expr, "expr", false,
+		"interpret QUERY as a JSONPath query such as '[?(@.spec.replicas > 3)]'.")
+	kustomizeCmd.Flags().StringVarP(&output, "output", "o", "",
+		`Output format -- json, listing the matching resources and selected values.  Requires --expr.  Defaults to the resources`)
+	preRunE, runE := kustomizeCmd.PreRunE, kustomizeCmd.RunE
+	kustomizeCmd.PreRunE = func(c *cobra.Command, args []string) error {
+		if expr {
+			return nil
+		}
+		if output != "" {
+			return fmt.Errorf("--output requires --expr")
+		}
+		return preRunE(c, args)
+	}
+	kustomizeCmd.RunE = func(c *cobra.Command, args []string) error {
+		if !expr {
+			return runE(c, args)
+		}
+		if output != "" && output != setters.JSONOutput {
+			return fmt.Errorf("unsupported output format %q, must be %s",
+				output, setters.JSONOutput)
+		}
+		e, err := grep.Parse(args[0])
+		if err != nil {
+			return err
+		}
+		invert, _ := c.Flags().GetBool("invert-match")
+		annotate, _ := c.Flags().GetBool("annotate")
+		recurse, _ := c.Flags().GetBool("recurse-subpackages")
+		filter := &grep.Filter{Expression: e, InvertMatch: invert}
+
+		var input kio.Reader = &kio.ByteReader{Reader: c.InOrStdin()}
+		if len(args) > 1 {
+			input = kio.LocalPackageReader{
+				PackagePath:        args[1],
+				PackageFileName:    kptfile.KptFileName,
+				IncludeSubpackages: recurse,
+			}
+		}
+		var outputs []kio.Writer
+		if output == "" {
+			outputs = append(outputs, kio.ByteWriter{
+				Writer:                c.OutOrStdout(),
+				KeepReaderAnnotations: annotate,
+			})
+		}
+		err = kio.Pipeline{
+			Inputs:  []kio.Reader{input},
+			Filters: []kio.Filter{filter},
+			Outputs: outputs,
+		}.Execute()
+		if err != nil || output == "" {
+			return err
+		}
+		return grep.WriteResults(c.OutOrStdout(), filter.Results)
+	}
+	return kustomizeCmd
+}
+
+// treeFields are the fields printed by the cfg tree field flags.
+var treeFields = []struct {
+	flag   string
+	fields []cfgtree.Field
+}{
+	{"name", containerFields("name")},
+	{"image", containerFields("image")},
+	{"command", containerFields("command")},
+	{"args", containerFields("args")},
+	{"env", containerFields("env")},
+	{"replicas", []cfgtree.Field{cfgtree.NewField("spec", "replicas")}},
+	{"resources", containerFields("resources")},
+	{"ports", append(containerFields("ports"), cfgtree.NewField("spec", "ports"))},
+}
+
+// containerFields returns the field of the containers of pods and pod
+// templates.
+func containerFields(field string) []cfgtree.Field {
+	return []cfgtree.Field{
+		cfgtree.NewField("spec", "containers", "[name=.*]", field),
+		cfgtree.NewField("spec", "template", "spec", "containers", "[name=.*]", field),
+	}
+}
+
+// TreeCommand wraps the kustomize tree command in order to print the tree
+// as json.
+func TreeCommand(parent string) *cobra.Command {
+	kustomizeCmd := configcobra.Tree(parent)
+	var output string
+	kustomizeCmd.Flags().StringVarP(&output, "output", "o", "",
+		`Output format -- json.  Defaults to a tree`)
+	runE := kustomizeCmd.RunE
+	kustomizeCmd.RunE = func(c *cobra.Command, args []string) error {
+		switch output {
+		case "":
+			return runE(c, args)
+		case setters.JSONOutput:
+		default:
+			return fmt.Errorf("unsupported output format %q, must be %s",
+				output, setters.JSONOutput)
+		}
+
+		paths, _ := c.Flags().GetStringSlice("field")
+		fields, err := cfgtree.ParseFields(paths)
+		if err != nil {
+			return err
+		}
+		all, _ := c.Flags().GetBool("all")
+		for _, f := range treeFields {
+			if set, _ := c.Flags().GetBool(f.flag); set || (all && !c.Flag(f.flag).Changed) {
+				fields = append(fields, f.fields...)
+			}
+		}
+
+		var input kio.Reader
+		root := "."
+		if len(args) > 0 {
+			root = args[0]
+		}
+		if root == pipe.Stdin {
+			root = ""
+			input = &kio.ByteReader{Reader: c.InOrStdin()}
+		} else {
+			input = kio.LocalPackageReader{PackagePath: root}
+		}
+		includeLocal, _ := c.Flags().GetBool("include-local")
+		excludeNonLocal, _ := c.Flags().GetBool("exclude-non-local")
+		return kio.Pipeline{
+			Inputs: []kio.Reader{input},
+			Filters: []kio.Filter{&filters.IsLocalConfig{
+				IncludeLocalConfig:    includeLocal,
+				ExcludeNonLocalConfig: excludeNonLocal,
+			}},
+			Outputs: []kio.Writer{cfgtree.JSONWriter{
+				Root:   root,
+				Writer: c.OutOrStdout(),
+				Fields: fields,
+			}},
+		}.Execute()
+	}
+	return kustomizeCmd
+}
+
+// DeleteSetterCommand wraps the kustomize delete-setter command in order to
+// refuse deleting setters used by capture substitutions.
 func DeleteSetterCommand(parent string) *cobra.Command {
 	fieldmeta.SetShortHandRef(ShortHandRef)
-	return configcobra.DeleteSetter(parent)
+	kustomizeCmd := configcobra.DeleteSetter(parent)
+	runE := kustomizeCmd.RunE
+	kustomizeCmd.RunE = func(c *cobra.Command, args []string) error {
+		recurse, _ := c.Flags().GetBool("recurse-subpackages")
+		if err := setters.CheckDeleteSetter(args[0], args[1], recurse); err != nil {
+			return err
+		}
+		return runE(c, args)
+	}
+	return kustomizeCmd
 }
 
+// DeleteSubstitutionCommand wraps the kustomize delete-subst command in
+// order to delete capture substitutions, which kustomize doesn't know of.
 func DeleteSubstitutionCommand(parent string) *cobra.Command {
 	fieldmeta.SetShortHandRef(ShortHandRef)
-	return configcobra.DeleteSubstitution(parent)
+	kustomizeCmd := configcobra.DeleteSubstitution(parent)
+	runE := kustomizeCmd.RunE
+	kustomizeCmd.RunE = func(c *cobra.Command, args []string) error {
+		recurse, _ := c.Flags().GetBool("recurse-subpackages")
+		deleted, err := setters.DeleteCapture(args[0], args[1], recurse)
+		if err != nil {
+			return err
+		}
+		if len(deleted) == 0 {
+			return runE(c, args)
+		}
+		for _, p := range deleted {
+			fmt.Fprintf(c.OutOrStdout(), "deleted capture substitution %q in package %q\n", args[1], p)
+		}
+		return nil
+	}
+	return kustomizeCmd
 }
 
+// CreateSubstCommand wraps the kustomize create-subst command in order to
+// check the values of the existing setters the substitution references
+// match the field value before anything is changed.
 func CreateSubstCommand(parent string) *cobra.Command {
 	fieldmeta.SetShortHandRef(ShortHandRef)
-	return configcobra.CreateSubstitution(parent)
+	kustomizeCmd := configcobra.CreateSubstitution(parent)
+	runE := kustomizeCmd.RunE
+	kustomizeCmd.RunE = func(c *cobra.Command, args []string) error {
+		fieldValue, _ := c.Flags().GetString("field-value")
+		pattern, _ := c.Flags().GetString("pattern")
+		recurse, _ := c.Flags().GetBool("recurse-subpackages")
+		if err := setters.CheckSubstitution(args[0], fieldValue, pattern, recurse); err != nil {
+			return err
+		}
+		return runE(c, args)
+	}
+	return kustomizeCmd
 }
 
-// SetCommand wraps the kustomize set command in order to automatically update
-// a project number if a project id is set.
+// SetCommand wraps the kustomize set command in order to validate the value
+// against the setter definitions, to automatically update a project number
+// if a project id is set, to cascade the values to subpackages, and to
+// record the changes in the history of the packages if asked to.
 func SetCommand(parent string) *cobra.Command {
 	fieldmeta.SetShortHandRef(ShortHandRef)
 	kustomizeCmd := configcobra.Set(parent)
@@ -149,10 +840,140 @@ func SetCommand(parent string) *cobra.Command {
 	var autoRun bool
 	setCmd.Flags().BoolVar(&autoRun, "auto-run", true,
 		`Automatically run functions after setting (if enabled for the package)`)
+	var cascade bool
+	setCmd.Flags().BoolVar(&cascade, "cascade", true,
+		`Cascade the values to subpackages which don't set them locally`)
+	var valuesFile, envFile string
+	setCmd.Flags().StringVar(&valuesFile, "values-file", "",
+		`Set the setters to the values in a yaml file mapping setter names to values`)
+	setCmd.Flags().StringVar(&envFile, "from-env-file", "",
+		`Set the setters to the values in a file of NAME=VALUE lines`)
+	var profile string
+	setCmd.Flags().StringVar(&profile, "profile", "",
+		`Set the setters to the values of a profile defined in the Kptfile`)
+	var fieldPath, kind string
+	setCmd.Flags().StringVar(&fieldPath, "field-path", "",
+		`Set the fields at the path, e.g. spec.template.spec.containers[*].image, `+
+			`creating the setter and adding references to it if needed`)
+	setCmd.Flags().StringVar(&kind, "kind", "",
+		`Only set the fields at --field-path of resources of the kind`)
+	var scope setters.ScopedOverride
+	setCmd.Flags().StringVar(&scope.File, "file", "",
+		`Only set the fields of the resources in the file, recording the value as an override`)
+	setCmd.Flags().StringVar(&scope.Resource, "resource", "",
+		`Only set the fields of the resource KIND/NAME, recording the value as an override`)
+	var appendElements, removeElements bool
+	setCmd.Flags().BoolVar(&appendElements, "append", false,
+		`Append the values to the elements of an array setter`)
+	setCmd.Flags().BoolVar(&removeElements, "remove", false,
+		`Remove the values from the elements of an array setter`)
+	var recordHistory bool
+	setCmd.Flags().BoolVar(&recordHistory, "record-history", false,
+		`Record the changes of the setter values in the history of the packages`)
+	// the values may be read from files rather than args, and the
+	// kustomize command validates its own args
+	setCmd.Use = "set DIR [NAME VALUE]"
+	setCmd.Args = cobra.MinimumNArgs(1)
+	setCmd.PreRunE = nil
 	setCmd.RunE = func(c *cobra.Command, args []string) error {
-		kustomizeCmd.SetArgs(args)
-		if err := kustomizeCmd.Execute(); err != nil {
+		if appendElements || removeElements {
+			var err error
+			if args, err = setElements(c, args, appendElements, removeElements); err != nil {
+				return err
+			}
+		}
+		if valuesFile != "" || envFile != "" || profile != "" {
+			if len(args) != 1 {
+				return fmt.Errorf("setter names and values can't be args when they are read from a file or profile")
+			}
+			if err := setBatch(c, args[0], valuesFile, envFile, profile, cascade); err != nil {
+				return err
+			}
+			if autoRun {
+				return functions.ReconcileFunctions(args[0])
+			}
+			return nil
+		}
+
+		if scope.File != "" || scope.Resource != "" {
+			if fieldPath != "" {
+				return fmt.Errorf("--file and --resource can't be used with --field-path")
+			}
+			if err := setScoped(c, args, scope); err != nil {
+				return err
+			}
+			if autoRun {
+				return functions.ReconcileFunctions(args[0])
+			}
+			return nil
+		}
+
+		if fieldPath != "" {
+			if err := setPath(c, args, fieldPath, kind); err != nil {
+				return err
+			}
+			if cascade {
+				if _, err := setters.Cascade(args[0], true); err != nil {
+					return err
+				}
+			}
+			computed, err := setters.Recompute(args[0])
+			if err != nil {
+				return err
+			}
+			setters.WriteComputed(c.OutOrStdout(), computed)
+			if _, err := setters.ApplyOverrides(args[0]); err != nil {
+				return err
+			}
+			if autoRun {
+				return functions.ReconcileFunctions(args[0])
+			}
+			return nil
+		}
+		if kind != "" {
+			return fmt.Errorf("--kind can only be used with --field-path")
+		}
+
+		// reject values which don't match the setter definitions before
+		// any package is changed
+		if values, err := c.Flags().GetStringArray("values"); err == nil && len(args) > 1 {
+			if !c.Flag("values").Changed {
+				values = args[2:]
+			}
+			recurse, _ := c.Flags().GetBool("recurse-subpackages")
+			if len(values) > 0 {
+				if err := setters.ValidateSet(args[0], args[1], values[0], values[1:], recurse || cascade); err != nil {
+					return err
+				}
+			}
+		}
+
+		if err := set(c, kustomizeCmd, args); err != nil {
+			return err
+		}
+
+		if cascade {
+			if _, err := setters.Cascade(args[0], true); err != nil {
+				return err
+			}
+		}
+
+		computed, err := setters.Recompute(args[0])
+		if err != nil {
+			return err
+		}
+		setters.WriteComputed(c.OutOrStdout(), computed)
+
+		if n, err := setters.ApplyOverrides(args[0]); err != nil {
 			return err
+		} else if n > 0 {
+			fmt.Fprintf(c.OutOrStdout(), "kept %d field(s) of scoped overrides\n", n)
+		}
+
+		if n, err := setters.SubstituteCaptures(args[0]); err != nil {
+			return err
+		} else if n > 0 {
+			fmt.Fprintf(c.OutOrStdout(), "substituted %d field(s) of capture and starlark substitutions\n", n)
 		}
 
 		if autoRun {
@@ -175,5 +996,167 @@ func SetCommand(parent string) *cobra.Command {
 		}
 		return nil
 	}
+	// record the changes of the setter values in the history of the
+	// packages, including those made before an error.  The schemas of the
+	// packages are added first so the values are typed by the schemas of
+	// their custom resources.
+	runE := setCmd.RunE
+	setCmd.RunE = func(c *cobra.Command, args []string) error {
+		if err := kptopenapi.AddPackageTreeSchemas(args[0]); err != nil {
+			return err
+		}
+		if !recordHistory {
+			return runE(c, args)
+		}
+		before, err := setters.TakeSnapshot(args[0])
+		if err != nil {
+			return err
+		}
+		err = runE(c, args)
+		if _, herr := setters.RecordHistory(args[0], before); herr != nil && err == nil {
+			return herr
+		}
+		return err
+	}
 	return &setCmd
 }
+
+// setElements returns the args to set an array setter to its elements with
+// the values of args appended or removed.
+func setElements(c *cobra.Command, args []string, appendElements, removeElements bool) ([]string, error) {
+	switch {
+	case appendElements && removeElements:
+		return nil, fmt.Errorf("--append and --remove can't be used together")
+	case c.Flag("values-file").Changed || c.Flag("from-env-file").Changed || c.Flag("profile").Changed ||
+		c.Flag("field-path").Changed || c.Flag("file").Changed || c.Flag("resource").Changed:
+		return nil, fmt.Errorf("--append and --remove can only be used to set a setter by name")
+	case c.Flag("values").Changed || len(args) < 3:
+		return nil, fmt.Errorf("--append and --remove require the setter name and values as args")
+	}
+	var elements []string
+	var err error
+	if appendElements {
+		elements, err = setters.AppendElements(args[0], args[1], args[2:])
+	} else {
+		elements, err = setters.RemoveElements(args[0], args[1], args[2:])
+	}
+	if err != nil {
+		return nil, err
+	}
+	return append([]string{args[0], args[1]}, elements...), nil
+}
+
+// set sets the setter.  Setting recursively is done by kpt rather than
+// kustomize, so that packages which don't define the setter are skipped
+// and the results are summarized.
+func set(c, kustomizeCmd *cobra.Command, args []string) error {
+	recurse, _ := c.Flags().GetBool("recurse-subpackages")
+	if !recurse {
+		kustomizeCmd.SetArgs(args)
+		if len(args) < 2 {
+			return kustomizeCmd.Execute()
+		}
+		return setters.PreserveElements(args[0], args[1], kustomizeCmd.Execute)
+	}
+	values, _ := c.Flags().GetStringArray("values")
+	switch {
+	case c.Flag("values").Changed && len(args) > 2:
+		return fmt.Errorf("value should set either from flag or arg")
+	case !c.Flag("values").Changed && len(args) < 3:
+		return fmt.Errorf("value must be provided either from flag or arg")
+	case !c.Flag("values").Changed:
+		values = args[2:]
+	}
+	s := setters.RecursiveSet{Name: args[1], Value: values[0], ListValues: values[1:]}
+	s.SetBy, _ = c.Flags().GetString("set-by")
+	s.Description, _ = c.Flags().GetString("description")
+	results, err := s.Set(args[0])
+	if werr := setters.WriteSetResults(c.OutOrStdout(), s, results); werr != nil {
+		return werr
+	}
+	return err
+}
+
+// setPath sets the fields at the field path to the value of the setter,
+// creating the setter if the package doesn't define it.
+func setPath(c *cobra.Command, args []string, fieldPath, kind string) error {
+	if len(args) != 3 {
+		return fmt.Errorf("a setter name and value must be provided to set the fields at --field-path")
+	}
+	if recurse, _ := c.Flags().GetBool("recurse-subpackages"); recurse {
+		return fmt.Errorf("--field-path can't be used with --recurse-subpackages")
+	}
+	s := setters.PathSet{Name: args[1], Value: args[2], Path: fieldPath, Kind: kind}
+	s.SetBy, _ = c.Flags().GetString("set-by")
+	s.Description, _ = c.Flags().GetString("description")
+	count, err := s.Set(args[0])
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(c.OutOrStdout(), "set %d field(s) of setter %q to value %q\n", count, s.Name, s.Value)
+	return nil
+}
+
+// setScoped sets the setter only in the file or resource of scope,
+// recording the value as an override.
+func setScoped(c *cobra.Command, args []string, scope setters.ScopedOverride) error {
+	if len(args) != 3 {
+		return fmt.Errorf("a setter name and value must be provided to set the fields of --file or --resource")
+	}
+	if recurse, _ := c.Flags().GetBool("recurse-subpackages"); recurse {
+		return fmt.Errorf("--file and --resource can't be used with --recurse-subpackages")
+	}
+	s := setters.ScopedSet{Name: args[1], Value: args[2], Scope: scope}
+	count, err := s.Set(args[0])
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(c.OutOrStdout(), "set %d field(s) of setter %q to value %q in %s\n",
+		count, s.Name, s.Value, scope.Scope())
+	return nil
+}
+
+// setBatch sets the setters to the values read from the values and env
+// files, and the profile, all or none of them.
+func setBatch(c *cobra.Command, dir, valuesFile, envFile, profile string, cascade bool) error {
+	var read []map[string][]string
+	if valuesFile != "" {
+		values, err := setters.ReadValuesFile(valuesFile)
+		if err != nil {
+			return err
+		}
+		read = append(read, values)
+	}
+	if envFile != "" {
+		values, err := setters.ReadEnvFile(envFile)
+		if err != nil {
+			return err
+		}
+		read = append(read, values)
+	}
+	values, err := setters.MergeValues(read...)
+	if err != nil {
+		return err
+	}
+	if profile != "" {
+		// the values read from files override the profile's
+		p, err := setters.ReadProfile(dir, profile)
+		if err != nil {
+			return err
+		}
+		for name, value := range p {
+			if _, found := values[name]; !found {
+				values[name] = value
+			}
+		}
+	}
+	b := setters.Batch{Values: values, Cascade: cascade}
+	b.SetBy, _ = c.Flags().GetString("set-by")
+	b.Description, _ = c.Flags().GetString("description")
+	counts, err := b.Set(dir)
+	if err != nil {
+		return err
+	}
+	setters.WriteBatchResults(c.OutOrStdout(), b, counts)
+	return nil
+}
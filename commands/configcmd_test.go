@@ -0,0 +1,73 @@
+// Copyright 2020 Google LLC.
+// SPDX-License-Identifier: Apache-2.0
+
+package commands
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt/pkg/kptfile"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/kustomize/cmd/config/ext"
+)
+
+func TestCreateSetterCommand_secret(t *testing.T) {
+	defer func(f func() string) { ext.KRMFileName = f }(ext.KRMFileName)
+	ext.KRMFileName = func() string {
+		return kptfile.KptFileName
+	}
+	dir, err := ioutil.TempDir("", "")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir)
+	for name, data := range map[string]string{
+		"Kptfile": `apiVersion: kpt.dev/v1alpha1
+kind: Kptfile
+metadata:
+  name: app
+`,
+		"secret.yaml": `apiVersion: v1
+kind: Secret
+metadata:
+  name: db
+stringData:
+  password: s3cr3t-value
+`,
+	} {
+		if !assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(data), 0600)) {
+			t.FailNow()
+		}
+	}
+
+	create := CreateSetterCommand("kpt cfg")
+	create.SetArgs([]string{dir, "db-password", "s3cr3t-value",
+		"--type", "secret", "--secret-from", "env:DB_PASSWORD"})
+	create.SetOut(ioutil.Discard)
+	if !assert.NoError(t, create.Execute()) {
+		t.FailNow()
+	}
+
+	list := ListSettersCommand("kpt cfg")
+	out := &bytes.Buffer{}
+	list.SetArgs([]string{dir})
+	list.SetOut(out)
+	if !assert.NoError(t, list.Execute()) {
+		t.FailNow()
+	}
+	assert.Contains(t, out.String(), "<redacted>")
+	assert.NotContains(t, out.String(), "s3cr3t-value")
+
+	for _, name := range []string{"Kptfile", "secret.yaml"} {
+		b, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		assert.Contains(t, string(b), "<redacted>", name)
+		assert.NotContains(t, string(b), "s3cr3t-value", name)
+	}
+}
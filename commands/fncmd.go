@@ -15,11 +15,27 @@
 package commands
 
 import (
+	"bytes"
+	"io/ioutil"
+	"os"
+
 	"github.com/spf13/cobra"
 	"sigs.k8s.io/kustomize/cmd/config/configcobra"
+	"sigs.k8s.io/kustomize/kyaml/copyutil"
+	"sigs.k8s.io/kustomize/kyaml/fn/runtime/runtimeutil"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
 
+	"github.com/GoogleContainerTools/kpt/internal/cmdcacheserver"
 	"github.com/GoogleContainerTools/kpt/internal/cmdexport"
+	"github.com/GoogleContainerTools/kpt/internal/cmdrender"
+	"github.com/GoogleContainerTools/kpt/internal/cmdrunjob"
 	"github.com/GoogleContainerTools/kpt/internal/docs/generated/fndocs"
+	"github.com/GoogleContainerTools/kpt/internal/util/cmdutil"
+	"github.com/GoogleContainerTools/kpt/internal/util/functions"
+	"github.com/GoogleContainerTools/kpt/internal/util/pipe"
+	"github.com/GoogleContainerTools/kpt/internal/util/tmputil"
+	"github.com/GoogleContainerTools/kpt/pkg/fnresult"
 )
 
 func GetFnCommand(name string) *cobra.Command {
@@ -41,10 +57,7 @@ func GetFnCommand(name string) *cobra.Command {
 		},
 	}
 
-	run := configcobra.RunFn(name)
-	run.Short = fndocs.RunShort
-	run.Long = fndocs.RunShort + "\n" + fndocs.RunLong
-	run.Example = fndocs.RunExamples
+	run := RunFnCommand(name)
 
 	source := configcobra.Source(name)
 	source.Short = fndocs.SourceShort
@@ -56,6 +69,318 @@ func GetFnCommand(name string) *cobra.Command {
 	sink.Long = fndocs.SinkShort + "\n" + fndocs.SinkLong
 	sink.Example = fndocs.SinkExamples
 
-	functions.AddCommand(run, source, sink, cmdexport.ExportCommand())
+	// read resources from stdin when the directory is "-", and write the
+	// resources sunk to "-" to stdout
+	pipe.Command{Omitted: true}.Wrap(run)
+	pipe.Command{Sink: true}.Wrap(sink)
+
+	functions.AddCommand(run, source, sink, cmdexport.ExportCommand(),
+		cmdcacheserver.NewCommand(name), cmdrender.NewCommand(name), cmdrunjob.NewCommand())
 	return functions
 }
+
+// RunFnCommand wraps the kustomize run command, whose flags it keeps, in
+// order to run the function containers with the runtime chosen by
+// --container-runtime, to print the functions as pipeline stages for
+// external orchestrators instead of running them, to restore the yaml
+// aliases the functions expand, and to run the functions compiled to
+// WebAssembly.
+func RunFnCommand(name string) *cobra.Command {
+	run := configcobra.RunFn(name)
+	run.Short = fndocs.RunShort
+	run.Long = fndocs.RunShort + "\n" + fndocs.RunLong
+	run.Example = fndocs.RunExamples
+
+	var asStage bool
+	run.Flags().BoolVar(&asStage, "as-pipeline-stage", false,
+		"print the container invocation of each function as a pipeline plan, instead of running them.")
+	var resultsFile string
+	run.Flags().StringVar(&resultsFile, "results-file", "",
+		"write the results of every function, with the file and line of the fields they reference, to this file.")
+	// the functions compiled to WebAssembly or served by endpoints are run
+	// after the others, for dry runs against a copy of the package which is
+	// then printed
+	var dryRunDir string
+	// the results are read from the results dir, a temporary one unless
+	// --results-dir is set
+	var resultsDir, tmpResultsDir string
+	cleanup := func(c *cobra.Command) {
+		if dryRunDir != "" {
+			os.RemoveAll(dryRunDir)
+		}
+		if tmpResultsDir != "" {
+			os.RemoveAll(tmpResultsDir)
+			_ = c.Flags().Set("results-dir", "")
+		}
+	}
+	preRunE := run.PreRunE
+	run.PreRunE = func(c *cobra.Command, args []string) error {
+		dryRunDir, tmpResultsDir = "", ""
+		resultsDir, _ = c.Flags().GetString("results-dir")
+		if !asStage && resultsDir == "" {
+			tmp, err := ioutil.TempDir("", "kpt-fn-results")
+			if err != nil {
+				return err
+			}
+			resultsDir, tmpResultsDir = tmp, tmp
+			if err := c.Flags().Set("results-dir", tmp); err != nil {
+				cleanup(c)
+				return err
+			}
+		}
+		dir := args
+		if c.ArgsLenAtDash() >= 0 {
+			dir = args[:c.ArgsLenAtDash()]
+		}
+		dryRun, _ := c.Flags().GetBool("dry-run")
+		if !asStage && dryRun && len(dir) == 1 {
+			fns, err := functions.WasmFunctions(dir[0])
+			if err != nil {
+				return err
+			}
+			endpoints, err := functions.EndpointFunctions(dir[0])
+			if err != nil {
+				return err
+			}
+			if len(fns)+len(endpoints) > 0 {
+				tmp, err := tmputil.WorkspaceDir("kpt-fn-run-")
+				if err != nil {
+					cleanup(c)
+					return err
+				}
+				dryRunDir = tmp
+				if err := copyutil.CopyDir(dir[0], tmp); err != nil {
+					cleanup(c)
+					return err
+				}
+				if err := c.Flags().Set("dry-run", "false"); err != nil {
+					cleanup(c)
+					return err
+				}
+				args[0] = tmp
+			}
+		}
+		err := preRunE(c, args)
+		if err != nil {
+			cleanup(c)
+		}
+		return err
+	}
+	run.RunE = func(c *cobra.Command, args []string) error {
+		if !asStage {
+			global, err := c.Flags().GetBool("global-scope")
+			if err != nil {
+				return err
+			}
+			defer cleanup(c)
+			dir := args
+			if c.ArgsLenAtDash() >= 0 {
+				dir = args[:c.ArgsLenAtDash()]
+			}
+			// the fields the results reference are found in the package
+			// once the functions have run
+			pkg := dryRunDir
+			if pkg == "" && len(dir) == 1 {
+				pkg = dir[0]
+			}
+			err = func() error {
+				if dryRunDir != "" {
+					if err := runStages(c, args); err != nil {
+						return err
+					}
+					if err := runPackageFunctions(dryRunDir, global, resultsDir); err != nil {
+						return err
+					}
+					nodes, err := (&kio.LocalPackageReader{PackagePath: dryRunDir}).Read()
+					if err != nil {
+						return err
+					}
+					return kio.ByteWriter{Writer: c.OutOrStdout()}.Write(nodes)
+				}
+				if dryRun, _ := c.Flags().GetBool("dry-run"); len(dir) != 1 || dryRun {
+					return runStages(c, args)
+				}
+				// the functions write the package, so the aliases they
+				// expand are restored in it
+				return functions.PreserveAnchors(dir[0], func() error {
+					if err := runStages(c, args); err != nil {
+						return err
+					}
+					return runPackageFunctions(dir[0], global, resultsDir)
+				})
+			}()
+			// the results explain why functions failed, so they are
+			// reported either way
+			if rerr := reportResults(c, resultsDir, resultsFile, pkg); err == nil {
+				err = rerr
+			}
+			return err
+		}
+		p, err := planStages(c, args)
+		if err != nil {
+			return err
+		}
+		return p.Run()
+	}
+	return run
+}
+
+// planStages returns the functions kpt fn run runs, from the flags and
+// args of c.
+func planStages(c *cobra.Command, args []string) (functions.PlanStages, error) {
+	var fnArgs []string
+	if c.ArgsLenAtDash() >= 0 {
+		fnArgs = args[c.ArgsLenAtDash():]
+		args = args[:c.ArgsLenAtDash()]
+	}
+	p := functions.PlanStages{Output: c.OutOrStdout()}
+	if len(args) == 1 {
+		p.Path = args[0]
+	}
+	var err error
+	flags := c.Flags()
+	if p.GlobalScope, err = flags.GetBool("global-scope"); err != nil {
+		return p, err
+	}
+	if p.Network, err = flags.GetBool("network"); err != nil {
+		return p, err
+	}
+	if p.AsCurrentUser, err = flags.GetBool("as-current-user"); err != nil {
+		return p, err
+	}
+	if p.Runtime, err = functions.GetContainerRuntime(cmdutil.ContainerRuntime); err != nil {
+		return p, err
+	}
+	if p.FunctionPaths, err = flags.GetStringSlice("fn-path"); err != nil {
+		return p, err
+	}
+	if p.Env, err = flags.GetStringArray("env"); err != nil {
+		return p, err
+	}
+	mounts, err := flags.GetStringArray("mount")
+	if err != nil {
+		return p, err
+	}
+	for _, m := range mounts {
+		p.StorageMounts = append(p.StorageMounts, runtimeutil.StringToStorageMount(m))
+	}
+
+	image, err := flags.GetString("image")
+	if err != nil {
+		return p, err
+	}
+	enableStar, err := flags.GetBool("enable-star")
+	if err != nil {
+		return p, err
+	}
+	starPath, err := flags.GetString("star-path")
+	if err != nil {
+		return p, err
+	}
+	starURL, err := flags.GetString("star-url")
+	if err != nil {
+		return p, err
+	}
+	starName, err := flags.GetString("star-name")
+	if err != nil {
+		return p, err
+	}
+	enableExec, err := flags.GetBool("enable-exec")
+	if err != nil {
+		return p, err
+	}
+	execPath, err := flags.GetString("exec-path")
+	if err != nil {
+		return p, err
+	}
+	var fn *yaml.RNode
+	switch {
+	case image != "":
+		fn, err = functions.ImageFunction(image, p.Network, fnArgs)
+	case enableStar && (starPath != "" || starURL != ""):
+		fn, err = functions.StarlarkFunction(starName, starPath, starURL, fnArgs)
+	case enableExec && execPath != "":
+		fn, err = functions.ExecFunction(execPath, fnArgs)
+	default:
+		return p, nil
+	}
+	if err != nil {
+		return p, err
+	}
+	p.Functions = []*yaml.RNode{fn}
+	return p, nil
+}
+
+// runStages runs the functions kpt fn run runs, from the flags and args of
+// c, with the container runtime chosen by --container-runtime.
+func runStages(c *cobra.Command, args []string) error {
+	p, err := planStages(c, args)
+	if err != nil {
+		return err
+	}
+	r := functions.RunStages{PlanStages: p, Input: c.InOrStdin(), LogWriter: c.ErrOrStderr()}
+	flags := c.Flags()
+	if r.DryRun, err = flags.GetBool("dry-run"); err != nil {
+		return err
+	}
+	if r.ResultsDir, err = flags.GetString("results-dir"); err != nil {
+		return err
+	}
+	if r.EnableStarlark, err = flags.GetBool("enable-star"); err != nil {
+		return err
+	}
+	if r.EnableExec, err = flags.GetBool("enable-exec"); err != nil {
+		return err
+	}
+	if r.LogSteps, err = flags.GetBool("log-steps"); err != nil {
+		return err
+	}
+	return r.Run()
+}
+
+// runPackageFunctions runs the functions of the package at dir which
+// kustomize doesn't run: those compiled to WebAssembly, then those served
+// by endpoints.
+func runPackageFunctions(dir string, global bool, resultsDir string) error {
+	if err := functions.RunWasmFunctions(dir, global, resultsDir); err != nil {
+		return err
+	}
+	return functions.RunEndpointFunctions(dir, global, resultsDir)
+}
+
+// reportResults prints the results the functions wrote to resultsDir, with
+// the files and lines in the package at pkg of the fields they reference,
+// and writes them to resultsFile if it is set.
+func reportResults(c *cobra.Command, resultsDir, resultsFile, pkg string) error {
+	results, err := functions.ReadResults(resultsDir)
+	if err != nil {
+		return err
+	}
+	if fi, err := os.Stat(pkg); err == nil && fi.IsDir() {
+		if err := functions.ResolveResults(pkg, results); err != nil {
+			return err
+		}
+	}
+	items := 0
+	for _, r := range results {
+		items += len(r.Items)
+	}
+	if items > 0 {
+		if err := fnresult.Write(c.ErrOrStderr(), results); err != nil {
+			return err
+		}
+	}
+	if resultsFile == "" {
+		return nil
+	}
+	if results == nil {
+		results = []fnresult.Result{}
+	}
+	b := &bytes.Buffer{}
+	e := yaml.NewEncoder(b)
+	e.SetIndent(2)
+	if err := e.Encode(results); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(resultsFile, b.Bytes(), 0600)
+}
@@ -15,10 +15,15 @@
 package commands
 
 import (
+	"fmt"
 	"os"
+	"strings"
 
 	"github.com/GoogleContainerTools/kpt/internal/cmdfetchk8sschema"
+	"github.com/GoogleContainerTools/kpt/internal/cmdgenrbac"
 	"github.com/GoogleContainerTools/kpt/internal/docs/generated/livedocs"
+	"github.com/GoogleContainerTools/kpt/internal/util/redact"
+	"github.com/GoogleContainerTools/kpt/internal/util/setters"
 	"github.com/GoogleContainerTools/kpt/pkg/live"
 	"github.com/spf13/cobra"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
@@ -58,6 +63,17 @@ func GetLiveCommand(name string, f util.Factory) *cobra.Command {
 		ErrOut: os.Stderr,
 	}
 
+	// Commands which print resources or errors about them redact Secret data
+	// from their output.
+	redactor := &redact.Redactor{}
+	redactedOut, redactedErrOut := redactor.Writer(os.Stdout), redactor.Writer(os.Stderr)
+	redactedStreams := genericclioptions.IOStreams{
+		In:     os.Stdin,
+		Out:    redactedOut,
+		ErrOut: redactedErrOut,
+	}
+	redacted := []*redact.Writer{redactedOut, redactedErrOut}
+
 	// The default provider is for ConfigMap inventory, but if the magic env
 	// var exists, then the provider which handles both ConfigMap and ResourceGroup
 	// inventory objects is used. If a package has both inventory objects, then
@@ -82,23 +98,23 @@ func GetLiveCommand(name string, f util.Factory) *cobra.Command {
 	initCmd.Long = livedocs.InitShort + "\n" + livedocs.InitLong
 	initCmd.Example = livedocs.InitExamples
 
-	applyCmd := GetApplyRunner(p, l, ioStreams).Command()
+	applyCmd := GetApplyRunner(p, l, redactedStreams).Command()
 	_ = applyCmd.Flags().MarkHidden("no-prune")
 	applyCmd.Short = livedocs.ApplyShort
 	applyCmd.Long = livedocs.ApplyShort + "\n" + livedocs.ApplyLong
 	applyCmd.Example = livedocs.ApplyExamples
 
-	previewCmd := GetPreviewRunner(p, l, ioStreams).Command()
+	previewCmd := GetPreviewRunner(p, l, redactedStreams).Command()
 	previewCmd.Short = livedocs.PreviewShort
 	previewCmd.Long = livedocs.PreviewShort + "\n" + livedocs.PreviewLong
 	previewCmd.Example = livedocs.PreviewExamples
 
-	diffCmd := diff.NewCmdDiff(f, ioStreams)
+	diffCmd := diff.NewCmdDiff(f, redactedStreams)
 	diffCmd.Short = livedocs.DiffShort
 	diffCmd.Long = livedocs.DiffShort + "\n" + livedocs.DiffLong
 	diffCmd.Example = livedocs.DiffExamples
 
-	destroyCmd := GetDestroyRunner(p, l, ioStreams).Command()
+	destroyCmd := GetDestroyRunner(p, l, redactedStreams).Command()
 	destroyCmd.Short = livedocs.DestroyShort
 	destroyCmd.Long = livedocs.DestroyShort + "\n" + livedocs.DestroyLong
 	destroyCmd.Example = livedocs.DestroyExamples
@@ -110,8 +126,14 @@ func GetLiveCommand(name string, f util.Factory) *cobra.Command {
 
 	fetchOpenAPICmd := cmdfetchk8sschema.NewCommand(name, f, ioStreams)
 
+	genRBACCmd := cmdgenrbac.NewCommand(name, f, ioStreams)
+
+	for _, c := range []*cobra.Command{applyCmd, previewCmd, diffCmd, destroyCmd} {
+		redactSecrets(redactor, redacted, c)
+	}
+
 	liveCmd.AddCommand(initCmd, applyCmd, previewCmd, diffCmd, destroyCmd,
-		fetchOpenAPICmd, statusCmd)
+		fetchOpenAPICmd, genRBACCmd, statusCmd)
 
 	// If the magic env var exists, then add the migrate to change
 	// from ConfigMap to ResourceGroup inventory object. Also add
@@ -131,3 +153,58 @@ func GetLiveCommand(name string, f util.Factory) *cobra.Command {
 
 	return liveCmd
 }
+
+// redactSecrets redacts the Secret data, sensitive fields and secret setter
+// values of the package from the output and errors of c, unless
+// --show-secrets is set.
+func redactSecrets(r *redact.Redactor, streams []*redact.Writer, c *cobra.Command) {
+	var showSecrets bool
+	c.Flags().BoolVar(&showSecrets, "show-secrets", false,
+		"Don't redact Secret data and sensitive fields from the output.")
+
+	start := func(args []string) error {
+		r.Disabled = showSecrets
+		if showSecrets || len(args) == 0 || args[0] == "-" {
+			return nil
+		}
+		// the values of secret setters are redacted too, as they may be
+		// printed from the resources they are injected into, or from the
+		// cluster
+		r.Add(setters.SecretValues(args[0])...)
+		return r.AddPackage(args[0])
+	}
+	flush := func() {
+		for _, s := range streams {
+			_ = s.Flush()
+		}
+	}
+
+	if runE := c.RunE; runE != nil {
+		c.RunE = func(cmd *cobra.Command, args []string) error {
+			if err := start(args); err != nil {
+				return err
+			}
+			err := runE(cmd, args)
+			flush()
+			return r.Error(err)
+		}
+	}
+	if run := c.Run; run != nil {
+		// kubectl commands exit on errors rather than returning them
+		c.Run = func(cmd *cobra.Command, args []string) {
+			util.CheckErr(start(args))
+			util.BehaviorOnFatal(func(msg string, code int) {
+				flush()
+				msg = r.String(msg)
+				if len(msg) > 0 && !strings.HasSuffix(msg, "\n") {
+					msg += "\n"
+				}
+				fmt.Fprint(os.Stderr, msg)
+				os.Exit(code)
+			})
+			defer util.DefaultBehaviorOnFatal()
+			run(cmd, args)
+			flush()
+		}
+	}
+}
@@ -15,18 +15,30 @@
 package commands
 
 import (
+	"github.com/GoogleContainerTools/kpt/internal/cmdadd"
+	"github.com/GoogleContainerTools/kpt/internal/cmdconverthelm"
+	"github.com/GoogleContainerTools/kpt/internal/cmdconvertkustomize"
 	"github.com/GoogleContainerTools/kpt/internal/cmddesc"
 	"github.com/GoogleContainerTools/kpt/internal/cmddiff"
 	"github.com/GoogleContainerTools/kpt/internal/cmdfix"
 	"github.com/GoogleContainerTools/kpt/internal/cmdget"
 	"github.com/GoogleContainerTools/kpt/internal/cmdinit"
+	"github.com/GoogleContainerTools/kpt/internal/cmdoutdated"
+	"github.com/GoogleContainerTools/kpt/internal/cmdpkgtree"
+	"github.com/GoogleContainerTools/kpt/internal/cmdpublish"
+	"github.com/GoogleContainerTools/kpt/internal/cmdresources"
+	"github.com/GoogleContainerTools/kpt/internal/cmdrevert"
 	"github.com/GoogleContainerTools/kpt/internal/cmdsync"
 	"github.com/GoogleContainerTools/kpt/internal/cmdupdate"
+	"github.com/GoogleContainerTools/kpt/internal/cmdvalidate"
+	"github.com/GoogleContainerTools/kpt/internal/cmdvendor"
+	"github.com/GoogleContainerTools/kpt/internal/cmdverify"
 	"github.com/GoogleContainerTools/kpt/internal/docs/generated/pkgdocs"
 	"github.com/spf13/cobra"
+	"k8s.io/kubectl/pkg/cmd/util"
 )
 
-func GetPkgCommand(name string) *cobra.Command {
+func GetPkgCommand(name string, f util.Factory) *cobra.Command {
 	pkg := &cobra.Command{
 		Use:     "pkg",
 		Short:   pkgdocs.PkgShort,
@@ -44,9 +56,15 @@ func GetPkgCommand(name string) *cobra.Command {
 			return cmd.Usage()
 		},
 	}
+	initRunner := cmdinit.NewRunner(name)
+	initRunner.Factory = f
 	pkg.AddCommand(
-		cmddesc.NewCommand(name), cmdget.NewCommand(name), cmdinit.NewCommand(name),
+		cmddesc.NewCommand(name), cmdget.NewCommand(name), initRunner.Command,
 		cmdfix.NewCommand(name), cmdsync.NewCommand(name), cmdupdate.NewCommand(name), cmddiff.NewCommand(name),
+		cmdconverthelm.NewCommand(name), cmdconvertkustomize.NewCommand(name),
+		cmdoutdated.NewCommand(name), cmdvendor.NewCommand(name), cmdresources.NewCommand(name),
+		cmdrevert.NewCommand(name), cmdadd.NewCommand(name), cmdpkgtree.NewCommand(name),
+		cmdpublish.NewCommand(name), cmdvalidate.NewCommand(name), cmdverify.NewCommand(name),
 	)
 	return pkg
 }
@@ -15,12 +15,17 @@
 package commands
 
 import (
+	"context"
+
 	"github.com/GoogleContainerTools/kpt/internal/util/setters"
+	"github.com/GoogleContainerTools/kpt/pkg/live"
 	"github.com/GoogleContainerTools/kpt/pkg/live/preprocess"
 	"github.com/spf13/cobra"
 	"k8s.io/cli-runtime/pkg/genericclioptions"
 	"sigs.k8s.io/cli-utils/cmd/flagutils"
 	"sigs.k8s.io/cli-utils/cmd/preview"
+	"sigs.k8s.io/cli-utils/cmd/printers"
+	applier "sigs.k8s.io/cli-utils/pkg/apply"
 	"sigs.k8s.io/cli-utils/pkg/common"
 	"sigs.k8s.io/cli-utils/pkg/inventory"
 	"sigs.k8s.io/cli-utils/pkg/manifestreader"
@@ -34,10 +39,16 @@ func GetPreviewRunner(provider provider.Provider, loader manifestreader.Manifest
 	w := &PreviewRunnerWrapper{
 		previewRunner: previewRunner,
 		provider:      provider,
+		loader:        loader,
+		ioStreams:     ioStreams,
 	}
 	// Set the wrapper run to be the RunE function for the wrapped command.
 	previewRunner.Command.RunE = w.RunE
 	previewRunner.Command.PreRunE = w.PreRunE
+	previewRunner.Command.Flags().StringArrayVar(&w.clusterVars, "cluster-var", nil,
+		"Cluster variable to inject as NAME=VALUE, overriding the value resolved from the cluster.")
+	previewRunner.Command.Flags().StringArrayVar(&w.setValues, "set", nil,
+		"Setter value to inject as NAME=VALUE, without changing the package.")
 	return w
 }
 
@@ -46,6 +57,11 @@ func GetPreviewRunner(provider provider.Provider, loader manifestreader.Manifest
 type PreviewRunnerWrapper struct {
 	previewRunner *preview.PreviewRunner
 	provider      provider.Provider
+	loader        manifestreader.ManifestLoader
+	ioStreams     genericclioptions.IOStreams
+	clusterVars   []string
+	setValues     []string
+	values        map[string]string
 }
 
 // Command returns the wrapped PreviewRunner cobraCommand structure.
@@ -54,8 +70,13 @@ func (w *PreviewRunnerWrapper) Command() *cobra.Command {
 }
 
 func (w *PreviewRunnerWrapper) PreRunE(_ *cobra.Command, args []string) error {
+	values, err := setters.ParseSetValues(w.setValues)
+	if err != nil {
+		return err
+	}
+	w.values = values
 	if len(args) > 0 {
-		if err := setters.CheckForRequiredSetters(args[0]); err != nil {
+		if err := setters.CheckForRequiredSetters(args[0], w.values); err != nil {
 			return err
 		}
 	}
@@ -71,5 +92,85 @@ func (w *PreviewRunnerWrapper) RunE(cmd *cobra.Command, args []string) error {
 			return preprocess.PreProcess(w.provider, inv, strategy)
 		}
 	}
-	return w.previewRunner.RunE(cmd, args)
+	destroy, err := cmd.Flags().GetBool("destroy")
+	if err != nil {
+		return err
+	}
+	if destroy {
+		return w.previewRunner.RunE(cmd, args)
+	}
+	return w.run(cmd, args)
+}
+
+// run mirrors the wrapped PreviewRunner RunE for previews of applies, which
+// doesn't expose its applier, so the resources with an apply method are
+// previewed with their method.
+func (w *PreviewRunnerWrapper) run(cmd *cobra.Command, args []string) error {
+	flags := cmd.Flags()
+	options := applier.Options{DryRunStrategy: common.DryRunClient}
+	var err error
+	if options.ServerSideOptions.ServerSideApply, err = flags.GetBool("server-side"); err != nil {
+		return err
+	}
+	if options.ServerSideOptions.ForceConflicts, err = flags.GetBool("force-conflicts"); err != nil {
+		return err
+	}
+	if options.ServerSideOptions.FieldManager, err = flags.GetString("field-manager"); err != nil {
+		return err
+	}
+	if options.NoPrune, err = flags.GetBool("no-prune"); err != nil {
+		return err
+	}
+	output, err := flags.GetString("output")
+	if err != nil {
+		return err
+	}
+	if options.ServerSideOptions.ServerSideApply {
+		options.DryRunStrategy = common.DryRunServer
+	}
+	if options.InventoryPolicy, err = flagutils.ConvertInventoryPolicy(
+		w.Command().Flag(flagutils.InventoryPolicyFlag).Value.String()); err != nil {
+		return err
+	}
+
+	reader, err := w.loader.ManifestReader(cmd.InOrStdin(), flagutils.PathFromArgs(args))
+	if err != nil {
+		return err
+	}
+	objs, err := reader.Read()
+	if err != nil {
+		return err
+	}
+	inv, objs, err := w.loader.InventoryInfo(objs)
+	if err != nil {
+		return err
+	}
+	if objs, err = excludeConditions(flagutils.PathFromArgs(args), objs); err != nil {
+		return err
+	}
+	if err := injectClusterVars(cmd, w.provider.Factory(), w.clusterVars, objs); err != nil {
+		return err
+	}
+	if err := injectSetters(flagutils.PathFromArgs(args), w.values, objs); err != nil {
+		return err
+	}
+	if w.previewRunner.PreProcess != nil {
+		if options.InventoryPolicy, err = w.previewRunner.PreProcess(inv, options.DryRunStrategy); err != nil {
+			return err
+		}
+	}
+	if err := w.previewRunner.Applier.Initialize(); err != nil {
+		return err
+	}
+	if _, err := common.DemandOneDirectory(args); err != nil {
+		return err
+	}
+
+	methods, err := live.NewMethodApplier(w.provider.Factory(), w.previewRunner.Applier.Run, w.ioStreams.ErrOut)
+	if err != nil {
+		return err
+	}
+	ch := methods.Run(context.Background(), inv, objs, options)
+	printer := printers.GetPrinter(output, w.ioStreams)
+	return printer.Print(ch, options.DryRunStrategy)
 }
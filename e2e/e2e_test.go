@@ -500,7 +500,7 @@ openAPI:
           required: true
           isSet: false
  `,
-			errMsg: `setter replicas is required but not set, please set it to new value and try again`,
+			errMsg: `setter replicas is required but not set`,
 		},
 		{
 			name:    "test apply command setters pre-check",
@@ -520,7 +520,7 @@ openAPI:
           required: true
           isSet: false
  `,
-			errMsg: `setter replicas is required but not set, please set it to new value and try again`,
+			errMsg: `setter replicas is required but not set`,
 		},
 		{
 			name:    "preview command setters pre-check pass",
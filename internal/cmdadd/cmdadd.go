@@ -0,0 +1,96 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cmdadd contains the add command
+package cmdadd
+
+import (
+	docs "github.com/GoogleContainerTools/kpt/internal/docs/generated/pkgdocs"
+	"github.com/GoogleContainerTools/kpt/internal/gitutil"
+	"github.com/GoogleContainerTools/kpt/internal/util/cmdutil"
+	"github.com/GoogleContainerTools/kpt/internal/util/parse"
+	"github.com/GoogleContainerTools/kpt/internal/util/sync"
+	"github.com/GoogleContainerTools/kpt/pkg/kptfile"
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/kustomize/kyaml/errors"
+)
+
+// NewRunner returns a command runner.
+func NewRunner(parent string) *Runner {
+	r := &Runner{}
+	c := &cobra.Command{
+		Use:     "add REPO_URI[.git]/PKG_PATH[@VERSION] [LOCAL_DEST_DIRECTORY]",
+		Short:   docs.AddShort,
+		Long:    docs.AddLong,
+		Example: docs.AddExamples,
+		RunE:    r.runE,
+		Args:    cobra.RangeArgs(1, 2),
+		PreRunE: r.preRunE,
+	}
+
+	c.Flags().StringVar(&r.Dependency.Strategy, "strategy", "",
+		"update strategy to use when the dependency is synced.")
+	c.Flags().BoolVar(&r.Dependency.AutoSet, "auto-set", false,
+		"perform setters based off the environment when the dependency is synced.")
+	c.Flags().BoolVar(&r.Sync.RequirePinned, "require-pinned-upstreams",
+		gitutil.RequirePinnedUpstreamsDefault(),
+		"reject dependencies which are not pinned to tags or commits.")
+	c.Flags().BoolVar(&r.Sync.Recursive, "recursive", true,
+		"also fetch the dependencies declared by the dependency.")
+	c.Flags().StringArrayVar(&r.gitConfig, "git-config", nil,
+		"git config key=value to pass to each git command, e.g. http.sslCAInfo=ca.pem.  may be repeated.")
+	cmdutil.FixDocs("kpt", parent, c)
+	r.Command = c
+	return r
+}
+
+func NewCommand(parent string) *cobra.Command {
+	return NewRunner(parent).Command
+}
+
+// Runner contains the run function
+type Runner struct {
+	gitConfig  []string
+	Dependency kptfile.Dependency
+	Sync       sync.Command
+	Command    *cobra.Command
+}
+
+func (r *Runner) preRunE(c *cobra.Command, args []string) error {
+	if args[0] == "-" {
+		return errors.Errorf("dependencies must be fetched from a git repository")
+	}
+	if len(args) == 1 {
+		// default the destination to the name of the package
+		args = append(args, ".")
+	}
+	var err error
+	if r.Sync.GitConfig, err = gitutil.ParseConfig(r.gitConfig); err != nil {
+		return err
+	}
+	t, err := parse.GitParseArgsWithConfig(args, r.Sync.GitConfig)
+	if err != nil {
+		return err
+	}
+	r.Dependency.Git = t.Git
+	r.Dependency.Name = t.Destination
+	r.Sync.Dir = "."
+	r.Sync.StdOut = c.OutOrStdout()
+	r.Sync.StdErr = c.ErrOrStderr()
+	return nil
+}
+
+func (r *Runner) runE(_ *cobra.Command, _ []string) error {
+	return r.Sync.Add(r.Dependency)
+}
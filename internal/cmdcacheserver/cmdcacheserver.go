@@ -0,0 +1,77 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cmdcacheserver contains the cache-server command
+package cmdcacheserver
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+
+	docs "github.com/GoogleContainerTools/kpt/internal/docs/generated/fndocs"
+	"github.com/GoogleContainerTools/kpt/internal/util/cmdutil"
+	"github.com/GoogleContainerTools/kpt/internal/util/functions"
+	"github.com/GoogleContainerTools/kpt/pkg/fncache"
+	"github.com/spf13/cobra"
+)
+
+// NewRunner returns a command runner.
+func NewRunner(parent string) *Runner {
+	r := &Runner{}
+	c := &cobra.Command{
+		Use:     "cache-server DIR",
+		Short:   docs.CacheServerShort,
+		Long:    docs.CacheServerShort + "\n" + docs.CacheServerLong,
+		Example: docs.CacheServerExamples,
+		RunE:    r.runE,
+		Args:    cobra.ExactArgs(1),
+	}
+
+	c.Flags().StringVar(&r.Address, "address", ":8080",
+		"address to serve the cache on.")
+	c.Flags().Int64Var(&r.Dir.MaxBytes, "max-bytes", 0,
+		"size the cache is pruned to, least recently used entries first.  0 for no limit.")
+	c.Flags().Int64Var(&r.Server.MaxEntryBytes, "max-entry-bytes", fncache.DefaultMaxEntryBytes,
+		"size limit of each cached function result.")
+	c.Flags().BoolVar(&r.Server.ReadOnly, "read-only", false,
+		"serve cached results, but don't accept new ones.")
+	cmdutil.FixDocs("kpt", parent, c)
+	r.Command = c
+	return r
+}
+
+func NewCommand(parent string) *cobra.Command {
+	return NewRunner(parent).Command
+}
+
+// Runner contains the run function
+type Runner struct {
+	Address string
+	Dir     fncache.Dir
+	Server  fncache.Server
+	Command *cobra.Command
+}
+
+func (r *Runner) runE(c *cobra.Command, args []string) error {
+	r.Dir.Path = args[0]
+	if err := os.MkdirAll(r.Dir.Path, 0700); err != nil {
+		return err
+	}
+	r.Server.Cache = &r.Dir
+	r.Server.Token = os.Getenv(functions.CacheTokenEnv)
+	fmt.Fprintf(c.OutOrStdout(), "serving function results cached in %q on %s\n",
+		r.Dir.Path, r.Address)
+	return http.ListenAndServe(r.Address, &r.Server)
+}
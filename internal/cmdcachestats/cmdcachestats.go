@@ -0,0 +1,106 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cmdcachestats contains the cache stats command
+package cmdcachestats
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/GoogleContainerTools/kpt/internal/docs/generated/cachedocs"
+	"github.com/GoogleContainerTools/kpt/internal/gitutil"
+	"github.com/GoogleContainerTools/kpt/internal/util/cmdutil"
+	"github.com/GoogleContainerTools/kpt/internal/util/tmputil"
+	"github.com/spf13/cobra"
+)
+
+// NewRunner returns a command runner.
+func NewRunner(parent string) *Runner {
+	r := &Runner{}
+	c := &cobra.Command{
+		Use:     "stats",
+		Short:   cachedocs.StatsShort,
+		Long:    cachedocs.StatsShort + "\n" + cachedocs.StatsLong,
+		Example: cachedocs.StatsExamples,
+		RunE:    r.runE,
+		Args:    cobra.NoArgs,
+	}
+	cmdutil.FixDocs("kpt", parent, c)
+	r.Command = c
+	return r
+}
+
+func NewCommand(parent string) *cobra.Command {
+	return NewRunner(parent).Command
+}
+
+// Runner contains the run function
+type Runner struct {
+	Command *cobra.Command
+}
+
+func (r *Runner) runE(c *cobra.Command, _ []string) error {
+	stats, err := gitutil.GetRepoCacheStats()
+	if err != nil {
+		return err
+	}
+	return printStats(c.OutOrStdout(), stats)
+}
+
+// printStats prints the stats of the repo cache and the temp clones.
+func printStats(out io.Writer, stats gitutil.RepoCacheStats) error {
+	limit := "no limit"
+	if stats.MaxSize > 0 {
+		limit = tmputil.FormatBytes(stats.MaxSize)
+	}
+	var hits, misses int
+	for _, e := range stats.Entries {
+		hits += e.Hits
+		misses += e.Misses
+	}
+	fmt.Fprintf(out, "repo cache: %s\n", stats.Dir)
+	fmt.Fprintf(out, "size: %s of %s\n", tmputil.FormatBytes(stats.Size()), limit)
+	fmt.Fprintf(out, "hit rate: %.0f%% (%d hits, %d misses)\n",
+		100*stats.HitRate(), hits, misses)
+
+	if len(stats.Entries) > 0 {
+		fmt.Fprintln(out)
+		w := tabwriter.NewWriter(out, 0, 0, 3, ' ', 0)
+		fmt.Fprintln(w, "REPO\tSIZE\tLAST USED\tHITS\tMISSES")
+		for _, e := range stats.Entries {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d\n", e.URI, tmputil.FormatBytes(e.Size),
+				e.LastUsed.Local().Format("2006-01-02 15:04"), e.Hits, e.Misses)
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprintln(out)
+	fmt.Fprintf(out, "github api responses: %s\n", tmputil.FormatBytes(stats.GitHubAPISize))
+	n, size := tmputil.Usage()
+	max, err := tmputil.MaxSize()
+	if err != nil {
+		return err
+	}
+	limit = "no limit"
+	if max > 0 {
+		limit = "limit " + tmputil.FormatBytes(max)
+	}
+	fmt.Fprintf(out, "temp clones: %d in %s, %s (%s)\n",
+		n, tmputil.Dir(), tmputil.FormatBytes(size), limit)
+	return nil
+}
@@ -0,0 +1,65 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cmdcascade contains the cascade command
+package cmdcascade
+
+import (
+	"github.com/GoogleContainerTools/kpt/internal/docs/generated/cfgdocs"
+	"github.com/GoogleContainerTools/kpt/internal/util/cmdutil"
+	"github.com/GoogleContainerTools/kpt/internal/util/setters"
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/kustomize/kyaml/fieldmeta"
+)
+
+// NewRunner returns a command runner.
+func NewRunner(parent string) *Runner {
+	r := &Runner{}
+	c := &cobra.Command{
+		Use:     "cascade [DIR]",
+		Args:    cobra.MaximumNArgs(1),
+		Short:   cfgdocs.CascadeShort,
+		Long:    cfgdocs.CascadeShort + "\n" + cfgdocs.CascadeLong,
+		Example: cfgdocs.CascadeExamples,
+		RunE:    r.runE,
+	}
+	c.Flags().BoolVar(&r.DryRun, "dry-run", false,
+		"print the effective setter values without setting them.")
+	r.Command = c
+	cmdutil.FixDocs("kpt", parent, c)
+	return r
+}
+
+func NewCommand(parent string) *cobra.Command {
+	return NewRunner(parent).Command
+}
+
+// Runner contains the run function
+type Runner struct {
+	DryRun  bool
+	Command *cobra.Command
+}
+
+func (r *Runner) runE(c *cobra.Command, args []string) error {
+	dir := "."
+	if len(args) > 0 {
+		dir = args[0]
+	}
+	fieldmeta.SetShortHandRef("$kpt-set")
+	effective, err := setters.Cascade(dir, !r.DryRun)
+	if err != nil {
+		return err
+	}
+	return setters.WriteEffectiveSetters(c.OutOrStdout(), effective)
+}
@@ -0,0 +1,91 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmdcascade_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt/internal/cmdcascade"
+	"github.com/stretchr/testify/assert"
+)
+
+const parentKptfile = `apiVersion: kpt.dev/v1alpha1
+kind: Kptfile
+metadata:
+  name: parent
+openAPI:
+  definitions:
+    io.k8s.cli.setters.replicas:
+      x-k8s-cli:
+        setter:
+          name: replicas
+          value: "4"
+          isSet: true
+`
+
+const childKptfile = `apiVersion: kpt.dev/v1alpha1
+kind: Kptfile
+metadata:
+  name: child
+openAPI:
+  definitions:
+    io.k8s.cli.setters.replicas:
+      x-k8s-cli:
+        setter:
+          name: replicas
+          value: "1"
+`
+
+func TestCmd_dryRun(t *testing.T) {
+	d, err := ioutil.TempDir("", "kptcascade")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer os.RemoveAll(d)
+	if !assert.NoError(t, os.MkdirAll(filepath.Join(d, "child"), 0700)) {
+		t.FailNow()
+	}
+	err = ioutil.WriteFile(filepath.Join(d, "Kptfile"), []byte(parentKptfile), 0600)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	err = ioutil.WriteFile(filepath.Join(d, "child", "Kptfile"), []byte(childKptfile), 0600)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	b := &bytes.Buffer{}
+	r := cmdcascade.NewRunner("kpt")
+	r.Command.SetArgs([]string{d, "--dry-run"})
+	r.Command.SetOut(b)
+	if !assert.NoError(t, r.Command.Execute()) {
+		t.FailNow()
+	}
+	assert.Equal(t, `PACKAGE  SETTER    VALUE  SOURCE  SET
+.        replicas  4      .       true
+child    replicas  4      .       true
+`, b.String())
+
+	// the child package is not modified
+	actual, err := ioutil.ReadFile(filepath.Join(d, "child", "Kptfile"))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, childKptfile, string(actual))
+}
@@ -0,0 +1,72 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cmdcfgdiff contains the cfg diff command
+package cmdcfgdiff
+
+import (
+	"github.com/GoogleContainerTools/kpt/internal/docs/generated/cfgdocs"
+	"github.com/GoogleContainerTools/kpt/internal/util/cmdutil"
+	"github.com/GoogleContainerTools/kpt/internal/util/fielddiff"
+	"github.com/spf13/cobra"
+)
+
+// NewRunner returns a command runner.
+func NewRunner(parent string) *Runner {
+	r := &Runner{}
+	c := &cobra.Command{
+		Use:     "diff DIR1 DIR2",
+		Args:    cobra.ExactArgs(2),
+		Short:   cfgdocs.DiffShort,
+		Long:    cfgdocs.DiffShort + "\n" + cfgdocs.DiffLong,
+		Example: cfgdocs.DiffExamples,
+		PreRunE: r.preRunE,
+		RunE:    r.runE,
+	}
+	c.Flags().StringVarP(&r.Output, "output", "o", fielddiff.TableOutput,
+		"output format -- table or json.")
+	r.Command = c
+	cmdutil.FixDocs("kpt", parent, c)
+	return r
+}
+
+func NewCommand(parent string) *cobra.Command {
+	return NewRunner(parent).Command
+}
+
+// Runner contains the run function
+type Runner struct {
+	Command *cobra.Command
+	Output  string
+}
+
+func (r *Runner) preRunE(c *cobra.Command, args []string) error {
+	return fielddiff.ValidateOutput(r.Output)
+}
+
+func (r *Runner) runE(c *cobra.Command, args []string) error {
+	from, err := fielddiff.Read(args[0])
+	if err != nil {
+		return err
+	}
+	to, err := fielddiff.Read(args[1])
+	if err != nil {
+		return err
+	}
+	diffs, err := fielddiff.Diff(from, to)
+	if err != nil {
+		return err
+	}
+	return fielddiff.Write(c.OutOrStdout(), r.Output, diffs)
+}
@@ -0,0 +1,134 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmdcfgdiff_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt/internal/cmdcfgdiff"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCmd(t *testing.T) {
+	d, err := ioutil.TempDir("", "kptcfgdiff")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer os.RemoveAll(d)
+	for path, data := range map[string]string{
+		"staging/deployment.yaml": `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  replicas: 2
+`,
+		"staging/service.yaml": `apiVersion: v1
+kind: Service
+metadata:
+  name: web
+`,
+		// the same resources in a single file
+		"prod/web.yaml": `apiVersion: v1
+kind: Service
+metadata:
+  name: web
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  replicas: 5
+`,
+	} {
+		path = filepath.Join(d, path)
+		if !assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0700)) {
+			t.FailNow()
+		}
+		if !assert.NoError(t, ioutil.WriteFile(path, []byte(data), 0600)) {
+			t.FailNow()
+		}
+	}
+
+	var tests = []struct {
+		name     string
+		args     []string
+		expected string
+		err      string
+	}{
+		{
+			name: "table",
+			args: []string{"staging", "prod"},
+			expected: `TYPE     RESOURCE                FIELD          FROM  TO
+changed  apps/v1/Deployment web  spec.replicas  2     5
+`,
+		},
+		{
+			name: "json",
+			args: []string{"prod", "staging", "-o", "json"},
+			expected: `[
+  {
+    "apiVersion": "apps/v1",
+    "kind": "Deployment",
+    "name": "web",
+    "type": "changed",
+    "field": "spec.replicas",
+    "from": 5,
+    "to": 2
+  }
+]
+`,
+		},
+		{
+			name:     "same",
+			args:     []string{"prod", "prod", "-o", "json"},
+			expected: "[]\n",
+		},
+		{
+			name: "unsupported",
+			args: []string{"staging", "prod", "-o", "csv"},
+			err:  `unsupported output "csv"`,
+		},
+	}
+	for i := range tests {
+		test := tests[i]
+		t.Run(test.name, func(t *testing.T) {
+			b := &bytes.Buffer{}
+			r := cmdcfgdiff.NewRunner("kpt")
+			args := append([]string{}, test.args...)
+			args[0], args[1] = filepath.Join(d, args[0]), filepath.Join(d, args[1])
+			r.Command.SetArgs(args)
+			r.Command.SetOut(b)
+			r.Command.SilenceUsage = true
+			r.Command.SilenceErrors = true
+			err := r.Command.Execute()
+			if test.err != "" {
+				if assert.Error(t, err) {
+					assert.Contains(t, err.Error(), test.err)
+				}
+				return
+			}
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			assert.Equal(t, test.expected, b.String())
+		})
+	}
+}
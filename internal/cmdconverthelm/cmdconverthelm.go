@@ -0,0 +1,153 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cmdconverthelm contains the convert-helm command
+package cmdconverthelm
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	docs "github.com/GoogleContainerTools/kpt/internal/docs/generated/pkgdocs"
+	"github.com/GoogleContainerTools/kpt/internal/util/clusterpkg"
+	"github.com/GoogleContainerTools/kpt/internal/util/cmdutil"
+	"github.com/GoogleContainerTools/kpt/internal/util/helm"
+	"github.com/GoogleContainerTools/kpt/pkg/kptfile"
+	"github.com/GoogleContainerTools/kpt/pkg/kptfile/kptfileutil"
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/kustomize/kyaml/errors"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/kio/filters"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// NewRunner returns a command runner.
+func NewRunner(parent string) *Runner {
+	r := &Runner{}
+	c := &cobra.Command{
+		Use:          "convert-helm CHART",
+		Args:         cobra.ExactArgs(1),
+		Short:        docs.ConvertHelmShort,
+		Long:         docs.ConvertHelmShort + "\n" + docs.ConvertHelmLong,
+		Example:      docs.ConvertHelmExamples,
+		PreRunE:      r.preRunE,
+		RunE:         r.runE,
+		SilenceUsage: true,
+	}
+	helmBinary := "helm"
+	if b := os.Getenv("KPT_HELM_BINARY"); b != "" {
+		helmBinary = b
+	}
+	c.Flags().StringVarP(&r.Out, "out", "o", "",
+		"directory to write the package to.  must not exist.")
+	c.Flags().StringSliceVarP(&r.Chart.ValuesFiles, "values", "f", nil,
+		"values files to render the chart with.")
+	c.Flags().StringVar(&r.Chart.Repo, "repo", "",
+		"chart repository url to fetch the chart from.")
+	c.Flags().StringVar(&r.Chart.Version, "version", "",
+		"version of the chart to fetch.  defaults to the latest version.")
+	c.Flags().StringVar(&r.Chart.ReleaseName, "release-name", "",
+		"release name to render the chart with.  defaults to the package name.")
+	c.Flags().StringVarP(&r.Chart.Namespace, "namespace", "n", "",
+		"namespace to render the chart with.")
+	c.Flags().StringVar(&r.Chart.Binary, "helm-binary", helmBinary,
+		"helm binary used to render the chart.")
+	_ = c.MarkFlagRequired("out")
+	cmdutil.FixDocs("kpt", parent, c)
+	r.Command = c
+	return r
+}
+
+// NewCommand returns a convert-helm command instance.
+func NewCommand(parent string) *cobra.Command {
+	return NewRunner(parent).Command
+}
+
+// Runner contains the run function
+type Runner struct {
+	Command *cobra.Command
+	Chart   helm.Chart
+	Out     string
+}
+
+func (r *Runner) preRunE(_ *cobra.Command, args []string) error {
+	r.Chart.Chart = args[0]
+	if r.Chart.ReleaseName == "" {
+		r.Chart.ReleaseName = filepath.Base(filepath.Clean(r.Out))
+	}
+	if _, err := os.Stat(r.Out); err == nil {
+		return errors.Errorf("%q already exists", r.Out)
+	}
+	return nil
+}
+
+func (r *Runner) runE(c *cobra.Command, _ []string) error {
+	nodes, err := r.Chart.Render()
+	if err != nil {
+		return err
+	}
+	values, err := r.Chart.Values()
+	if err != nil {
+		return err
+	}
+	version := r.Chart.Version
+	if version == "" {
+		if version, err = r.Chart.ChartVersion(); err != nil {
+			return err
+		}
+	}
+
+	if err := os.MkdirAll(r.Out, 0700); err != nil {
+		return errors.Wrap(err)
+	}
+	var setters []clusterpkg.Setter
+	err = kio.Pipeline{
+		Inputs: []kio.Reader{&kio.PackageBuffer{Nodes: nodes}},
+		Filters: []kio.Filter{
+			helm.Layout{},
+			filters.FormatFilter{},
+			kio.FilterFunc(func(nodes []*yaml.RNode) ([]*yaml.RNode, error) {
+				if len(nodes) == 0 {
+					return nil, errors.Errorf("chart %q rendered no resources", r.Chart.Chart)
+				}
+				setters, err = helm.SuggestSetters(values, nodes)
+				return nodes, err
+			}),
+		},
+		Outputs: []kio.Writer{&kio.LocalPackageWriter{PackagePath: r.Out}},
+	}.Execute()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(c.OutOrStdout(), "wrote chart %q to %q\n", r.Chart.Chart, r.Out)
+
+	kf := kptfile.KptFile{ResourceMeta: kptfile.TypeMeta}
+	kf.Name = filepath.Base(filepath.Clean(r.Out))
+	kf.Upstream = kptfile.Upstream{
+		Type: kptfile.HelmOrigin,
+		Helm: kptfile.Helm{
+			Chart:       r.Chart.Chart,
+			Repo:        r.Chart.Repo,
+			Version:     version,
+			ReleaseName: r.Chart.ReleaseName,
+			Namespace:   r.Chart.Namespace,
+			ValuesFiles: r.Chart.ValuesFiles,
+		},
+	}
+	if err := kptfileutil.WriteFile(r.Out, kf); err != nil {
+		return err
+	}
+	return clusterpkg.CreateSetters(c.OutOrStdout(), r.Out, setters)
+}
@@ -0,0 +1,154 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmdconverthelm_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt/internal/cmdconverthelm"
+	"github.com/GoogleContainerTools/kpt/internal/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+const rendered = `---
+# Source: web/templates/deployment.yaml
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  labels:
+    app.kubernetes.io/name: web
+    helm.sh/chart: web-0.1.0
+    app.kubernetes.io/managed-by: Helm
+spec:
+  replicas: 3
+  template:
+    spec:
+      containers:
+      - name: web
+        image: "nginx:1.19"
+---
+# Source: web/templates/tests/test-connection.yaml
+apiVersion: v1
+kind: Pod
+metadata:
+  name: web-test-connection
+  annotations:
+    "helm.sh/hook": test
+`
+
+// TestCmd verifies the chart is converted to a package
+func TestCmd(t *testing.T) {
+	h := testutil.NewFakeHelm(t, rendered, "replicaCount: 1\n", "name: web\nversion: 0.1.0\n")
+	defer h.Clean()
+	d, err := ioutil.TempDir("", "kpt")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer os.RemoveAll(d)
+	values := filepath.Join(d, "values.yaml")
+	if !assert.NoError(t, ioutil.WriteFile(values, []byte("replicaCount: 3\n"), 0600)) {
+		t.FailNow()
+	}
+
+	r := cmdconverthelm.NewRunner("kpt")
+	out := &bytes.Buffer{}
+	r.Command.SetOut(out)
+	r.Command.SetArgs([]string{"./web", "--out", filepath.Join(d, "web-pkg"),
+		"--values", values, "--namespace", "app", "--helm-binary", h.Binary})
+	if !assert.NoError(t, r.Command.Execute()) {
+		t.FailNow()
+	}
+	assert.Equal(t, []string{
+		"template web-pkg ./web --include-crds --namespace app --values " + values,
+		"show values ./web",
+		"show chart ./web",
+	}, h.Args(t))
+
+	b, err := ioutil.ReadFile(filepath.Join(d, "web-pkg", "deployment.yaml"))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  labels:
+    app.kubernetes.io/name: web
+spec:
+  replicas: 3 # {"$kpt-set":"replicaCount"}
+  template:
+    spec:
+      containers:
+      - name: web
+        image: "nginx:1.19"
+`, string(b))
+
+	b, err = ioutil.ReadFile(filepath.Join(d, "web-pkg", "Kptfile"))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, `apiVersion: kpt.dev/v1alpha1
+kind: Kptfile
+metadata:
+  name: web-pkg
+upstream:
+  type: helm
+  helm:
+    chart: ./web
+    version: 0.1.0
+    releaseName: web-pkg
+    namespace: app
+    valuesFiles:
+    - `+values+`
+openAPI:
+  definitions:
+    io.k8s.cli.setters.replicaCount:
+      x-k8s-cli:
+        setter:
+          name: replicaCount
+          value: "3"
+`, string(b))
+
+	// chart tests aren't converted
+	_, err = os.Stat(filepath.Join(d, "web-pkg", "tests"))
+	assert.True(t, os.IsNotExist(err))
+
+	assert.Equal(t, `wrote chart "./web" to "`+filepath.Join(d, "web-pkg")+`"
+created setter "replicaCount" for "3"
+`, out.String())
+}
+
+// TestCmd_exists verifies the package directory must not exist
+func TestCmd_exists(t *testing.T) {
+	d, err := ioutil.TempDir("", "kpt")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer os.RemoveAll(d)
+
+	r := cmdconverthelm.NewRunner("kpt")
+	r.Command.SetOut(ioutil.Discard)
+	r.Command.SetErr(ioutil.Discard)
+	r.Command.SetArgs([]string{"./web", "--out", d, "--helm-binary", "false"})
+	err = r.Command.Execute()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "already exists")
+	}
+}
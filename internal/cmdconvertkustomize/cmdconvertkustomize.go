@@ -0,0 +1,86 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cmdconvertkustomize contains the convert-kustomize command
+package cmdconvertkustomize
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	docs "github.com/GoogleContainerTools/kpt/internal/docs/generated/pkgdocs"
+	"github.com/GoogleContainerTools/kpt/internal/util/cmdutil"
+	"github.com/GoogleContainerTools/kpt/internal/util/kustomizepkg"
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/kustomize/kyaml/errors"
+)
+
+// NewRunner returns a command runner.
+func NewRunner(parent string) *Runner {
+	r := &Runner{}
+	c := &cobra.Command{
+		Use:          "convert-kustomize DIR",
+		Args:         cobra.ExactArgs(1),
+		Short:        docs.ConvertKustomizeShort,
+		Long:         docs.ConvertKustomizeShort + "\n" + docs.ConvertKustomizeLong,
+		Example:      docs.ConvertKustomizeExamples,
+		PreRunE:      r.preRunE,
+		RunE:         r.runE,
+		SilenceUsage: true,
+	}
+	c.Flags().StringVarP(&r.Out, "out", "o", "",
+		"directory to write the packages to.  must not exist.")
+	_ = c.MarkFlagRequired("out")
+	cmdutil.FixDocs("kpt", parent, c)
+	r.Command = c
+	return r
+}
+
+// NewCommand returns a convert-kustomize command instance.
+func NewCommand(parent string) *cobra.Command {
+	return NewRunner(parent).Command
+}
+
+// Runner contains the run function
+type Runner struct {
+	Command *cobra.Command
+	Out     string
+}
+
+func (r *Runner) preRunE(_ *cobra.Command, _ []string) error {
+	if _, err := os.Stat(r.Out); err == nil {
+		return errors.Errorf("%q already exists", r.Out)
+	}
+	return nil
+}
+
+func (r *Runner) runE(c *cobra.Command, args []string) error {
+	converter := &kustomizepkg.Converter{SourcePath: args[0], DestPath: r.Out}
+	findings, err := converter.Convert()
+	if err != nil {
+		return err
+	}
+	for _, p := range converter.Packages {
+		fmt.Fprintf(c.OutOrStdout(), "wrote package %q\n", filepath.Join(r.Out, p))
+	}
+	if len(findings) == 0 {
+		return nil
+	}
+	fmt.Fprintf(c.OutOrStdout(), "\nthe following need manual attention:\n")
+	for _, f := range findings {
+		fmt.Fprintf(c.OutOrStdout(), "  %s\n", f)
+	}
+	return nil
+}
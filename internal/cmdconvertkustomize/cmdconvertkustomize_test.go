@@ -0,0 +1,100 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmdconvertkustomize_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt/internal/cmdconvertkustomize"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestCmd verifies the kustomizations are converted to packages
+func TestCmd(t *testing.T) {
+	d, err := ioutil.TempDir("", "kpt")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer os.RemoveAll(d)
+	src := filepath.Join(d, "config")
+	for path, content := range map[string]string{
+		"base/kustomization.yaml": "resources:\n- deployment.yaml\n",
+		"base/deployment.yaml": `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  replicas: 1
+`,
+		"prod/kustomization.yaml": "resources:\n- ../base\nvars: []\n" +
+			"replicas:\n- name: web\n  count: 3\n",
+	} {
+		if !assert.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(src, path)), 0700)) {
+			t.FailNow()
+		}
+		if !assert.NoError(t, ioutil.WriteFile(filepath.Join(src, path), []byte(content), 0600)) {
+			t.FailNow()
+		}
+	}
+
+	out := filepath.Join(d, "packages")
+	r := cmdconvertkustomize.NewRunner("kpt")
+	b := &bytes.Buffer{}
+	r.Command.SetOut(b)
+	r.Command.SetArgs([]string{src, "--out", out})
+	if !assert.NoError(t, r.Command.Execute()) {
+		t.FailNow()
+	}
+	assert.Equal(t, `wrote package "`+filepath.Join(out, "base")+`"
+wrote package "`+filepath.Join(out, "prod")+`"
+
+the following need manual attention:
+  prod/kustomization.yaml: vars: vars is not converted
+`, b.String())
+
+	c, err := ioutil.ReadFile(filepath.Join(out, "prod", "base", "deployment.yaml"))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  replicas: 3 # {"$kpt-set":"web-replicas"}
+`, string(c))
+}
+
+// TestCmd_exists verifies the out directory must not exist
+func TestCmd_exists(t *testing.T) {
+	d, err := ioutil.TempDir("", "kpt")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer os.RemoveAll(d)
+
+	r := cmdconvertkustomize.NewRunner("kpt")
+	r.Command.SetOut(ioutil.Discard)
+	r.Command.SetErr(ioutil.Discard)
+	r.Command.SetArgs([]string{d, "--out", d})
+	err = r.Command.Execute()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "already exists")
+	}
+}
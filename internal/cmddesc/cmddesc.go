@@ -35,6 +35,8 @@ func NewRunner(parent string) *Runner {
 		PreRunE: r.preRunE,
 		RunE:    r.runE,
 	}
+	c.Flags().StringVarP(&r.Description.Output, "output", "o", desc.TableOutput,
+		"output format -- table, json or markdown.")
 	r.Command = c
 	cmdutil.FixDocs("kpt", parent, c)
 	return r
@@ -81,3 +81,92 @@ func TestCmd_defaultPkg(t *testing.T) {
 `
 	assert.Equal(t, exp, b.String())
 }
+
+// TestDesc_Output tests describing the package metadata as json and
+// markdown.
+func TestDesc_Output(t *testing.T) {
+	d, err := ioutil.TempDir("", "kptdesc")
+	testutil.AssertNoError(t, err)
+
+	defer func() {
+		_ = os.RemoveAll(d)
+	}()
+
+	err = ioutil.WriteFile(filepath.Join(d, kptfile.KptFileName), []byte(`
+apiVersion: kpt.dev/v1alpha1
+kind: Kptfile
+metadata:
+  name: web
+packageMetadata:
+  shortDescription: A web server
+  license: Apache-2.0
+  version: v1.2.0
+  maintainers:
+  - name: Jo Doe
+    email: jo@example.com
+  keywords: [web, nginx]
+  changelogURL: https://example.com/web/CHANGELOG.md
+  minKptVersion: v0.37.0
+  minKubernetesVersion: v1.18
+`), 0600)
+	testutil.AssertNoError(t, err)
+
+	for _, test := range []struct {
+		output   string
+		expected string
+	}{
+		{
+			output: "json",
+			expected: fmt.Sprintf(`[
+  {
+    "name": "web",
+    "dir": "%s",
+    "shortDescription": "A web server",
+    "version": "v1.2.0",
+    "license": "Apache-2.0",
+    "maintainers": [
+      {
+        "name": "Jo Doe",
+        "email": "jo@example.com"
+      }
+    ],
+    "keywords": [
+      "web",
+      "nginx"
+    ],
+    "changelogURL": "https://example.com/web/CHANGELOG.md",
+    "minKptVersion": "v0.37.0",
+    "minKubernetesVersion": "v1.18"
+  }
+]
+`, filepath.Base(d)),
+		},
+		{
+			output: "markdown",
+			expected: fmt.Sprintf(`## web
+
+A web server
+
+| Field | Value |
+| --- | --- |
+| Dir | %s |
+| Version | v1.2.0 |
+| License | Apache-2.0 |
+| Maintainers | Jo Doe <jo@example.com> |
+| Keywords | web, nginx |
+| Min kpt version | v0.37.0 |
+| Min Kubernetes version | v1.18 |
+| Changelog | [https://example.com/web/CHANGELOG.md](https://example.com/web/CHANGELOG.md) |
+`, filepath.Base(d)),
+		},
+	} {
+		b := &bytes.Buffer{}
+		cmd := cmddesc.NewRunner("kpt")
+		cmd.Description.PrintBasePath = true
+		cmd.Command.SetArgs([]string{d, "--output", test.output})
+		cmd.Command.SetOut(b)
+		err = cmd.Command.Execute()
+		testutil.AssertNoError(t, err)
+		assert.Equal(t, test.expected, b.String())
+	}
+}
@@ -31,7 +31,7 @@ func TestCmdInvalidDiffType(t *testing.T) {
 	err := runner.C.Execute()
 	assert.EqualError(t,
 		err,
-		"invalid diff-type 'invalid'. Supported diff-types are: local, remote, combined, 3way")
+		"invalid diff-type 'invalid'. Supported diff-types are: local, remote, combined, 3way, preview")
 }
 
 func TestCmdInvalidDiffTool(t *testing.T) {
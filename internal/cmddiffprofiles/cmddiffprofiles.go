@@ -0,0 +1,76 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cmddiffprofiles contains the diff-profiles command
+package cmddiffprofiles
+
+import (
+	"github.com/GoogleContainerTools/kpt/internal/docs/generated/cfgdocs"
+	"github.com/GoogleContainerTools/kpt/internal/util/cmdutil"
+	"github.com/GoogleContainerTools/kpt/internal/util/setters"
+	"github.com/spf13/cobra"
+)
+
+// NewRunner returns a command runner.
+func NewRunner(parent string) *Runner {
+	r := &Runner{}
+	c := &cobra.Command{
+		Use:     "diff-profiles DIR FROM [TO]",
+		Args:    cobra.RangeArgs(2, 3),
+		Short:   cfgdocs.DiffProfilesShort,
+		Long:    cfgdocs.DiffProfilesShort + "\n" + cfgdocs.DiffProfilesLong,
+		Example: cfgdocs.DiffProfilesExamples,
+		RunE:    r.runE,
+	}
+	r.Command = c
+	cmdutil.FixDocs("kpt", parent, c)
+	return r
+}
+
+func NewCommand(parent string) *cobra.Command {
+	return NewRunner(parent).Command
+}
+
+// Runner contains the run function
+type Runner struct {
+	Command *cobra.Command
+}
+
+func (r *Runner) runE(c *cobra.Command, args []string) error {
+	dir, from, to := args[0], setters.CurrentProfile, args[1]
+	fromValues, err := setters.CurrentValues(dir)
+	if err != nil {
+		return err
+	}
+	if len(args) == 3 {
+		from, to = args[1], args[2]
+		if fromValues, err = setters.ReadProfile(dir, from); err != nil {
+			return err
+		}
+	}
+	toValues, err := setters.ReadProfile(dir, to)
+	if err != nil {
+		return err
+	}
+	if len(args) == 2 {
+		// setting the profile only changes the setters it sets
+		for name := range fromValues {
+			if _, found := toValues[name]; !found {
+				delete(fromValues, name)
+			}
+		}
+	}
+	diffs := setters.DiffValues(fromValues, toValues)
+	return setters.WriteValuesDiff(c.OutOrStdout(), from, to, diffs)
+}
@@ -0,0 +1,123 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmddiffprofiles_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt/internal/cmddiffprofiles"
+	"github.com/stretchr/testify/assert"
+)
+
+const kptfile = `apiVersion: kpt.dev/v1alpha1
+kind: Kptfile
+metadata:
+  name: app
+openAPI:
+  definitions:
+    io.k8s.cli.setters.replicas:
+      x-k8s-cli:
+        setter:
+          name: replicas
+          value: "3"
+    io.k8s.cli.setters.env:
+      x-k8s-cli:
+        setter:
+          name: env
+          value: staging
+    io.k8s.cli.setters.debug:
+      x-k8s-cli:
+        setter:
+          name: debug
+          value: "true"
+profiles:
+  staging:
+    replicas: 2
+    env: staging
+  prod:
+    replicas: 5
+    env: prod
+`
+
+func TestCmd(t *testing.T) {
+	d, err := ioutil.TempDir("", "kptdiffprofiles")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer os.RemoveAll(d)
+	err = ioutil.WriteFile(filepath.Join(d, "Kptfile"), []byte(kptfile), 0600)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	var tests = []struct {
+		name     string
+		args     []string
+		expected string
+		err      string
+	}{
+		{
+			name: "profiles",
+			args: []string{"staging", "prod"},
+			expected: `SETTER    STAGING  PROD
+env       staging  prod
+replicas  2        5
+`,
+		},
+		{
+			name: "current",
+			args: []string{"staging"},
+			expected: `SETTER    CURRENT  STAGING
+replicas  3        2
+`,
+		},
+		{
+			name:     "same",
+			args:     []string{"prod", "prod"},
+			expected: "prod and prod set the same values\n",
+		},
+		{
+			name: "unknown",
+			args: []string{"staging", "dev"},
+			err:  `has no profile "dev", its profiles are: prod, staging`,
+		},
+	}
+	for i := range tests {
+		test := tests[i]
+		t.Run(test.name, func(t *testing.T) {
+			b := &bytes.Buffer{}
+			r := cmddiffprofiles.NewRunner("kpt")
+			r.Command.SetArgs(append([]string{d}, test.args...))
+			r.Command.SetOut(b)
+			r.Command.SilenceUsage = true
+			r.Command.SilenceErrors = true
+			err := r.Command.Execute()
+			if test.err != "" {
+				if assert.Error(t, err) {
+					assert.Contains(t, err.Error(), test.err)
+				}
+				return
+			}
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			assert.Equal(t, test.expected, b.String())
+		})
+	}
+}
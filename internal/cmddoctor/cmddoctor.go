@@ -0,0 +1,119 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cmddoctor contains the doctor command
+package cmddoctor
+
+import (
+	"os"
+
+	"github.com/GoogleContainerTools/kpt/internal/util/cmdutil"
+	"github.com/GoogleContainerTools/kpt/internal/util/doctor"
+	"github.com/GoogleContainerTools/kpt/internal/util/functions"
+	"github.com/spf13/cobra"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/kubectl/pkg/cmd/util"
+)
+
+// NewRunner returns a command runner.
+func NewRunner(parent string, f util.Factory) *Runner {
+	r := &Runner{}
+	c := &cobra.Command{
+		Use:   "doctor [DIR]",
+		Short: "Diagnose problems with the environment and packages",
+		Long: `Diagnose problems with the environment and packages.
+
+doctor checks the environment kpt runs in, and the packages under DIR
+(defaults to the current directory), and reports each problem it finds with
+a suggested fix.  It exits non-zero if any check fails.
+
+The environment checks are:
+
+  git                    git is installed
+  container-runtime      the container runtime, e.g. docker, is installed and running
+  git-connectivity       a git repository can be reached, see --git-url
+  registry-connectivity  the function image registry can be reached, see --registry
+  git-credentials        a git credential helper or ssh agent is configured
+  registry-credentials   the docker config has credentials for the registry
+
+The package checks are:
+
+  kptfile    the Kptfiles are valid, see kpt pkg validate
+  upstream   the upstream ref of each package still exists
+  inventory  the cluster and namespace of each inventory are reachable
+
+Checks which need the network or a cluster are skipped with --offline.
+`,
+		Example: `  # diagnose the environment and the packages in the current directory
+  kpt doctor
+
+  # diagnose without the network, and print the report as json
+  kpt doctor my-package-dir/ --offline --output json
+`,
+		RunE:    r.runE,
+		Args:    cobra.MaximumNArgs(1),
+		PreRunE: r.preRunE,
+	}
+	c.Flags().BoolVar(&r.Doctor.Offline, "offline", false,
+		"skip the checks which need the network or a cluster.")
+	c.Flags().StringVar(&r.Doctor.Registry, "registry", doctor.DefaultRegistry,
+		"registry function images are pulled from.")
+	c.Flags().StringVar(&r.Doctor.GitURL, "git-url", doctor.DefaultGitURL,
+		"git repository to check connectivity against.")
+	c.Flags().DurationVar(&r.Doctor.Timeout, "timeout", doctor.DefaultTimeout,
+		"maximum time each network check may take.")
+	c.Flags().StringVar(&r.Doctor.Output, "output", doctor.TableOutput,
+		"output format -- must be one of: "+doctor.TableOutput+","+doctor.JSONOutput)
+	cmdutil.FixDocs("kpt", parent, c)
+	r.Command = c
+	r.factory = f
+	return r
+}
+
+func NewCommand(parent string, f util.Factory) *cobra.Command {
+	return NewRunner(parent, f).Command
+}
+
+// Runner contains the run function
+type Runner struct {
+	Doctor  doctor.Command
+	Command *cobra.Command
+	factory util.Factory
+}
+
+func (r *Runner) preRunE(c *cobra.Command, args []string) error {
+	r.Doctor.Dir = "."
+	if len(args) > 0 {
+		r.Doctor.Dir = args[0]
+	}
+	if r.factory != nil {
+		r.Doctor.KubeClient = func() (kubernetes.Interface, error) {
+			return r.factory.KubernetesClientSet()
+		}
+	}
+	rt, err := functions.GetContainerRuntime(cmdutil.ContainerRuntime)
+	if err != nil {
+		return err
+	}
+	// only a runtime which was chosen must be installed
+	if cmdutil.ContainerRuntime != "" || os.Getenv(functions.ContainerRuntimeEnv) != "" {
+		r.Doctor.ContainerRuntime = string(rt)
+	}
+	r.Doctor.StdOut = c.OutOrStdout()
+	return nil
+}
+
+func (r *Runner) runE(c *cobra.Command, args []string) error {
+	return r.Doctor.Run()
+}
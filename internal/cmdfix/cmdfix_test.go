@@ -126,6 +126,56 @@ created setter with name "project" (dry-run)
 created 3 setters in total (dry-run)
 created substitution with name "project-cluster-54235872" (dry-run)
 created 1 substitution in total (dry-run)
+--- a/Kptfile
++++ b/Kptfile
+@@ -1,2 +1,33 @@
+ apiVersion: kustomization.dev/v1alpha1
+ kind: Kustomization
++openAPI:
++  definitions:
++    io.k8s.cli.setters.cluster:
++      type: string
++      x-k8s-cli:
++        setter:
++          name: cluster
++          value: someclus
++    io.k8s.cli.setters.profile:
++      type: string
++      x-k8s-cli:
++        setter:
++          name: profile
++          value: asm
++    io.k8s.cli.setters.project:
++      type: string
++      x-k8s-cli:
++        setter:
++          name: project
++          value: someproj
++    io.k8s.cli.substitutions.project-cluster-54235872:
++      x-k8s-cli:
++        substitution:
++          name: project-cluster-54235872
++          pattern: ${project}/${cluster}
++          values:
++          - marker: ${project}
++            ref: '#/definitions/io.k8s.cli.setters.project'
++          - marker: ${cluster}
++            ref: '#/definitions/io.k8s.cli.setters.cluster'
++
+--- a/deploy.yaml
++++ b/deploy.yaml
+@@ -1,8 +1,8 @@
+ apiVersion: install.istio.io/v1alpha2
+ kind: IstioControlPlane
+ metadata:
+-  cluster: "someproj/someclus" # {"type":"string","x-kustomize":{"partialSetters":[{"name":"project","value":"someproj"},{"name":"cluster","value":"someclus"}]}}
++  cluster: "someproj/someclus" # {"$kpt-set":"project-cluster-54235872"}
+ spec:
+-  profile: asm # {"type":"string","x-kustomize":{"setter":{"name":"profile","value":"asm"}}}
+-  cluster: "someproj/someclus" # {"type":"string","x-kustomize":{"partialSetters":[{"name":"project","value":"someproj"},{"name":"cluster","value":"someclus"}]}}
++  profile: asm # {"$kpt-set":"profile"}
++  cluster: "someproj/someclus" # {"$kpt-set":"project-cluster-54235872"}
+ 
 `,
 			expectedOutput: `apiVersion: install.istio.io/v1alpha2
 kind: IstioControlPlane
@@ -237,3 +287,157 @@ spec:
 		})
 	}
 }
+
+func TestFixKptfileVersion(t *testing.T) {
+	var tests = []struct {
+		name            string
+		kptfile         string
+		args            []string
+		err             string
+		expectedOut     string
+		expectedKptfile string
+	}{
+		{
+			name: "unversioned",
+			kptfile: `metadata:
+  name: app
+openAPI:
+  definitions:
+    io.k8s.cli.setters.replicas:
+      x-k8s-cli:
+        setter:
+          name: replicas
+          value: "3"
+`,
+			expectedOut: `processing resource configs to identify possible fixes... 
+set kind Kptfile
+migrated Kptfile from apiVersion "" to "kpt.dev/v1alpha1"
+package is using latest version of setters, no fix needed
+`,
+			expectedKptfile: `apiVersion: kpt.dev/v1alpha1
+kind: Kptfile
+metadata:
+  name: app
+openAPI:
+  definitions:
+    io.k8s.cli.setters.replicas:
+      x-k8s-cli:
+        setter:
+          name: replicas
+          value: "3"
+`,
+		},
+		{
+			name: "unversioned-dryRun",
+			kptfile: `metadata:
+  name: app
+`,
+			args: []string{"--dry-run"},
+			expectedOut: `processing resource configs to identify possible fixes...  (dry-run)
+set kind Kptfile (dry-run)
+migrated Kptfile from apiVersion "" to "kpt.dev/v1alpha1" (dry-run)
+package is using latest version of setters, no fix needed (dry-run)
+--- a/Kptfile
++++ b/Kptfile
+@@ -1,3 +1,5 @@
++apiVersion: kpt.dev/v1alpha1
++kind: Kptfile
+ metadata:
+   name: app
+ 
+`,
+			expectedKptfile: `metadata:
+  name: app
+`,
+		},
+		{
+			name: "legacy",
+			kptfile: `apiVersion: krm.dev/v1alpha1
+kind: Kptfile
+metadata:
+  name: app
+`,
+			expectedOut: `processing resource configs to identify possible fixes... 
+migrated Kptfile from apiVersion "krm.dev/v1alpha1" to "kpt.dev/v1alpha1"
+package is using latest version of setters, no fix needed
+`,
+			expectedKptfile: `apiVersion: kpt.dev/v1alpha1
+kind: Kptfile
+metadata:
+  name: app
+`,
+		},
+		{
+			name: "latest",
+			kptfile: `apiVersion: kpt.dev/v1alpha1
+kind: Kptfile
+metadata:
+  name: app
+`,
+			expectedOut: `processing resource configs to identify possible fixes... 
+Kptfile has the latest apiVersion kpt.dev/v1alpha1, no migration needed
+package is using latest version of setters, no fix needed
+`,
+			expectedKptfile: `apiVersion: kpt.dev/v1alpha1
+kind: Kptfile
+metadata:
+  name: app
+`,
+		},
+		{
+			name: "unknown",
+			kptfile: `apiVersion: kpt.dev/v9
+kind: Kptfile
+metadata:
+  name: app
+`,
+			err: `has unknown apiVersion "kpt.dev/v9"`,
+		},
+	}
+	for i := range tests {
+		test := tests[i]
+		t.Run(test.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "")
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			defer os.RemoveAll(dir)
+			err = ioutil.WriteFile(filepath.Join(dir, "Kptfile"), []byte(test.kptfile), 0600)
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			gitRunner := gitutil.NewLocalGitRunner(dir)
+			if !assert.NoError(t, gitRunner.Run("init", ".")) {
+				t.FailNow()
+			}
+			if !assert.NoError(t, gitRunner.Run("add", ".")) {
+				t.FailNow()
+			}
+			if !assert.NoError(t, gitRunner.Run("commit", "-m", "commit local package")) {
+				t.FailNow()
+			}
+
+			out := &bytes.Buffer{}
+			r := cmdfix.NewRunner("kpt")
+			r.Command.SetArgs(append([]string{dir}, test.args...))
+			r.Command.SetOut(out)
+			r.Command.SetErr(&bytes.Buffer{})
+			err = r.Command.Execute()
+			if test.err != "" {
+				if assert.Error(t, err) {
+					assert.Contains(t, err.Error(), test.err)
+				}
+				return
+			}
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			assert.Equal(t, test.expectedOut, out.String())
+			b, err := ioutil.ReadFile(filepath.Join(dir, "Kptfile"))
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			assert.Equal(t, test.expectedKptfile, string(b))
+		})
+	}
+}
@@ -0,0 +1,107 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cmdgenrbac contains the gen-rbac command
+package cmdgenrbac
+
+import (
+	"github.com/GoogleContainerTools/kpt/internal/docs/generated/livedocs"
+	"github.com/GoogleContainerTools/kpt/internal/util/cmdutil"
+	"github.com/GoogleContainerTools/kpt/internal/util/rbac"
+	"github.com/GoogleContainerTools/kpt/pkg/kptfile"
+	"github.com/GoogleContainerTools/kpt/pkg/kptfile/kptfileutil"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/cli-runtime/pkg/genericclioptions"
+	"k8s.io/kubectl/pkg/cmd/util"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/kio/filters"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+func NewRunner(parent string, f util.Factory,
+	ioStreams genericclioptions.IOStreams) *Runner {
+	r := &Runner{
+		IOStreams: ioStreams,
+		Factory:   f,
+	}
+	c := &cobra.Command{
+		Use:     "gen-rbac DIR",
+		Short:   livedocs.GenRbacShort,
+		Long:    livedocs.GenRbacShort + "\n" + livedocs.GenRbacLong,
+		Example: livedocs.GenRbacExamples,
+		Args:    cobra.ExactArgs(1),
+		RunE:    r.runE,
+	}
+	cmdutil.FixDocs("kpt", parent, c)
+	r.Command = c
+
+	c.Flags().StringVar(&r.Generator.Name, "name", "kpt-deployer",
+		`Name of the generated roles and bindings`)
+	c.Flags().StringVar(&r.Generator.ServiceAccount, "service-account", "kpt-deployer",
+		`Name of the service account which applies the package`)
+	c.Flags().StringVar(&r.Generator.ServiceAccountNamespace, "service-account-namespace", "default",
+		`Namespace of the service account which applies the package`)
+	c.Flags().StringVar(&r.Generator.DefaultNamespace, "namespace", "default",
+		`Namespace of namespaced resources which don't specify one`)
+	c.Flags().BoolVar(&r.Offline, "offline", false,
+		`Don't query the cluster for resource names and scopes`)
+	return r
+}
+
+func NewCommand(parent string, f util.Factory,
+	ioStreams genericclioptions.IOStreams) *cobra.Command {
+	return NewRunner(parent, f, ioStreams).Command
+}
+
+// Runner contains the run function
+type Runner struct {
+	Command   *cobra.Command
+	IOStreams genericclioptions.IOStreams
+	Factory   util.Factory
+	Generator rbac.Generator
+	Offline   bool
+}
+
+func (r *Runner) runE(c *cobra.Command, args []string) error {
+	if !r.Offline && r.Factory != nil {
+		if mapper, err := r.Factory.ToRESTMapper(); err == nil {
+			r.Generator.Mapper = func(gvk schema.GroupVersionKind) (string, bool, error) {
+				m, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+				if err != nil {
+					return "", false, err
+				}
+				return m.Resource.Resource, m.Scope.Name() == meta.RESTScopeNameNamespace, nil
+			}
+		}
+	}
+
+	// the inventory object is declared in the Kptfile, if it has one
+	var kf *kptfile.KptFile
+	if k, err := kptfileutil.ReadFile(args[0]); err == nil {
+		kf = &k
+	}
+
+	return kio.Pipeline{
+		Inputs: []kio.Reader{&kio.LocalPackageReader{PackagePath: args[0]}},
+		Filters: []kio.Filter{
+			&filters.IsLocalConfig{},
+			kio.FilterFunc(func(nodes []*yaml.RNode) ([]*yaml.RNode, error) {
+				return r.Generator.Generate(nodes, kf)
+			}),
+		},
+		Outputs: []kio.Writer{kio.ByteWriter{Writer: c.OutOrStdout()}},
+	}.Execute()
+}
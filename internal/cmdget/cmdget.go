@@ -16,15 +16,26 @@
 package cmdget
 
 import (
+	"context"
 	"fmt"
+	"strings"
+	"time"
 
 	docs "github.com/GoogleContainerTools/kpt/internal/docs/generated/pkgdocs"
+	"github.com/GoogleContainerTools/kpt/internal/gitutil"
 	"github.com/GoogleContainerTools/kpt/internal/util/cmdutil"
+	"github.com/GoogleContainerTools/kpt/internal/util/concurrency"
+	"github.com/GoogleContainerTools/kpt/internal/util/functions"
 	"github.com/GoogleContainerTools/kpt/internal/util/get"
 	"github.com/GoogleContainerTools/kpt/internal/util/get/getioreader"
+	"github.com/GoogleContainerTools/kpt/internal/util/license"
 	"github.com/GoogleContainerTools/kpt/internal/util/parse"
+	"github.com/GoogleContainerTools/kpt/internal/util/progress"
 	"github.com/GoogleContainerTools/kpt/internal/util/setters"
+	"github.com/GoogleContainerTools/kpt/internal/util/sync"
+	"github.com/GoogleContainerTools/kpt/internal/util/update"
 	"github.com/spf13/cobra"
+	"sigs.k8s.io/kustomize/kyaml/errors"
 	"sigs.k8s.io/kustomize/kyaml/kio/filters"
 )
 
@@ -33,7 +44,7 @@ func NewRunner(parent string) *Runner {
 	r := &Runner{}
 	c := &cobra.Command{
 		Use:        "get REPO_URI[.git]/PKG_PATH[@VERSION] LOCAL_DEST_DIRECTORY",
-		Args:       cobra.ExactArgs(2),
+		Args:       r.args,
 		Short:      docs.GetShort,
 		Long:       docs.GetShort + "\n" + docs.GetLong,
 		Example:    docs.GetExamples,
@@ -50,6 +61,29 @@ May contain the following formatting verbs
 `)
 	c.Flags().BoolVar(&r.AutoSet, "auto-set", true,
 		`Automatically perform setters based off the environment`)
+	c.Flags().StringVarP(&r.Batch.ManifestPath, "filename", "f", "",
+		`Path to a manifest declaring multiple packages to fetch`)
+	c.Flags().StringVar(&r.ProgressFormat, "progress", progress.FormatAuto,
+		`Format of the progress written to stderr while fetching -- must be one of: `+
+			strings.Join(progress.Formats, ","))
+	c.Flags().BoolVar(&r.RequirePinned, "require-pinned-upstreams",
+		gitutil.RequirePinnedUpstreamsDefault(),
+		`Reject refs which are not tags or commits, e.g. branches`)
+	c.Flags().StringArrayVar(&r.GitConfig, "git-config", nil,
+		`Git config key=value to pass to each git command, e.g. http.sslCAInfo=ca.pem.  May be repeated`)
+	c.Flags().StringVar(&r.Get.TagTemplate, "tag-template", "",
+		`Template naming the tags which version the package, e.g. v{{.Version}}-{{.PkgName}}`)
+	c.Flags().DurationVar(&r.Timeout, "timeout", 0,
+		`Maximum time to spend fetching before giving up, e.g. 5m.  0 for no limit`)
+	c.Flags().BoolVar(&r.Dependencies, "dependencies", true,
+		`Fetch the dependencies declared in the fetched Kptfiles, recursively`)
+	c.Flags().IntVar(&r.FetchConcurrency, "fetch-concurrency", 0,
+		`Number of packages to fetch at once with --filename.  Defaults to the --concurrency limit`)
+	c.Flags().StringSliceVar(&r.AllowedLicenses, "allowed-licenses", nil,
+		`SPDX ids of the licenses packages may be fetched under, e.g. Apache-2.0,MIT.  Defaults to any license`)
+	c.Flags().StringVar(&r.LicensePolicy, "license-policy", license.FailMode,
+		`What to do with packages under licenses not in --allowed-licenses -- must be one of: `+
+			license.FailMode+","+license.WarnMode)
 	return r
 }
 
@@ -60,13 +94,73 @@ func NewCommand(parent string) *cobra.Command {
 // Runner contains the run function
 type Runner struct {
 	Get             get.Command
+	Batch           get.BatchCommand
 	Command         *cobra.Command
 	FilenamePattern string
 	AutoSet         bool
+	ProgressFormat  string
+	RequirePinned   bool
+	Timeout         time.Duration
+	GitConfig       []string
+
+	// FetchConcurrency overrides the number of packages fetched at once
+	FetchConcurrency int
+
+	// Dependencies if set fetches the dependencies of the fetched packages
+	Dependencies bool
+
+	// AllowedLicenses and LicensePolicy restrict the licenses of the
+	// fetched packages
+	AllowedLicenses []string
+	LicensePolicy   string
+}
+
+func (r *Runner) args(c *cobra.Command, args []string) error {
+	if r.Batch.ManifestPath != "" {
+		return cobra.NoArgs(c, args)
+	}
+	return cobra.ExactArgs(2)(c, args)
 }
 
 func (r *Runner) preRunE(c *cobra.Command, args []string) error {
-	t, err := parse.GitParseArgs(args)
+	reporter, err := progress.NewReporter(r.ProgressFormat, c.ErrOrStderr())
+	if err != nil {
+		return err
+	}
+	r.Get.Progress = reporter
+	r.Batch.Progress = reporter
+	r.Get.RequirePinned = r.RequirePinned
+	r.Batch.RequirePinned = r.RequirePinned
+	gitConfig, err := gitutil.ParseConfig(r.GitConfig)
+	if err != nil {
+		return err
+	}
+	r.Get.GitConfig = gitConfig
+	r.Batch.GitConfig = gitConfig
+	licenses, err := license.NewPolicy(r.AllowedLicenses, r.LicensePolicy, c.ErrOrStderr())
+	if err != nil {
+		return err
+	}
+	r.Get.Licenses = licenses
+	r.Batch.Licenses = licenses
+
+	if r.Batch.ManifestPath != "" {
+		r.Batch.StdOut = c.OutOrStdout()
+		limits, err := concurrency.Load(cmdutil.Concurrency)
+		if err != nil {
+			return err
+		}
+		r.Batch.Concurrency = limits.GitFetch
+		if r.FetchConcurrency < 0 {
+			return errors.Errorf("invalid --fetch-concurrency %d: must be a positive integer",
+				r.FetchConcurrency)
+		}
+		if r.FetchConcurrency > 0 {
+			r.Batch.Concurrency = r.FetchConcurrency
+		}
+		return nil
+	}
+	t, err := parse.GitParseArgsWithConfig(args, gitConfig)
 	if err != nil {
 		return err
 	}
@@ -76,25 +170,98 @@ func (r *Runner) preRunE(c *cobra.Command, args []string) error {
 }
 
 func (r *Runner) runE(c *cobra.Command, args []string) error {
+	// stop fetching and clean up if interrupted or the timeout is exceeded
+	ctx := c.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	ctx, cancel := cmdutil.SignalContext(ctx)
+	defer cancel()
+	if r.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, r.Timeout)
+		defer cancel()
+	}
+
+	if r.Batch.ManifestPath != "" {
+		fmt.Fprintf(c.OutOrStdout(), "fetching packages from %q\n", r.Batch.ManifestPath)
+		if err := r.Batch.RunContext(ctx); err != nil {
+			return err
+		}
+		if err := r.autoSet(c, r.Batch.Destinations...); err != nil {
+			return err
+		}
+		return r.syncDependencies(c, r.Batch.Destinations...)
+	}
+
 	if args[0] == "-" {
 		return getioreader.Get(args[1], r.FilenamePattern, c.InOrStdin())
 	}
 
 	fmt.Fprintf(c.OutOrStdout(), "fetching package %q from %q to %q\n",
 		r.Get.Directory, r.Get.Repo, r.Get.Destination)
-	if err := r.Get.Run(); err != nil {
+	paths, err := r.Get.FetchContext(ctx)
+	if err != nil {
+		return err
+	}
+	if get.IsDirectoryGlob(r.Get.Directory) {
+		for _, p := range paths {
+			fmt.Fprintf(c.OutOrStdout(), "fetched package %q\n", p)
+		}
+	}
+	if err := r.autoSet(c, paths...); err != nil {
+		return err
+	}
+	return r.syncDependencies(c, paths...)
+}
+
+// syncDependencies fetches the dependencies declared in the Kptfiles of the
+// fetched packages, recursively
+func (r *Runner) syncDependencies(c *cobra.Command, paths ...string) error {
+	if !r.Dependencies {
+		return nil
+	}
+	limits, err := concurrency.Load(cmdutil.Concurrency)
+	if err != nil {
 		return err
 	}
+	for _, p := range paths {
+		err := sync.Command{
+			Dir:                 p,
+			Recursive:           true,
+			StdOut:              c.OutOrStdout(),
+			StdErr:              c.ErrOrStderr(),
+			RequirePinned:       r.RequirePinned,
+			Licenses:            r.Get.Licenses,
+			GitConfig:           r.Get.GitConfig,
+			FetchConcurrency:    limits.GitFetch,
+			FunctionConcurrency: limits.Functions,
+		}.Run()
+		if err != nil {
+			return errors.WrapPrefixf(err, "failed to fetch the dependencies of %q", p)
+		}
+	}
+	return nil
+}
 
-	if r.AutoSet {
-		a := setters.AutoSet{
-			Writer:      c.OutOrStdout(),
-			PackagePath: r.Get.Destination,
+// autoSet performs setters based off the environment, applies the common
+// metadata and records the digest of the contents, for the fetched packages
+func (r *Runner) autoSet(c *cobra.Command, paths ...string) error {
+	for _, p := range paths {
+		if r.AutoSet {
+			a := setters.AutoSet{
+				Writer:      c.OutOrStdout(),
+				PackagePath: p,
+			}
+			if err := a.PerformAutoSetters(); err != nil {
+				return err
+			}
 		}
-		if err := a.PerformAutoSetters(); err != nil {
+		if err := functions.ApplyCommonMetadata(p); err != nil {
+			return err
+		}
+		if err := update.RecordDigests(p); err != nil {
 			return err
 		}
 	}
-
 	return nil
 }
@@ -16,6 +16,7 @@ package cmdget_test
 
 import (
 	"bytes"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -69,6 +70,28 @@ func TestCmd_execute(t *testing.T) {
 	})
 }
 
+// TestCmd_directoryGlob tests that get fetches each directory matching a glob.
+func TestCmd_directoryGlob(t *testing.T) {
+	g, w, clean := testutil.SetupDefaultRepoAndWorkspace(t, testutil.Dataset1)
+	defer clean()
+	dest := filepath.Join(w.WorkspaceDirectory, "catalog")
+
+	r := cmdget.NewRunner("kpt")
+	r.Command.SetArgs([]string{"file://" + g.RepoDirectory + ".git/*@master", dest})
+	b := &bytes.Buffer{}
+	r.Command.SetOut(b)
+	err := r.Command.Execute()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	for _, name := range []string{"java", "mysql", "wordpress"} {
+		g.AssertEqual(t, filepath.Join(g.DatasetDirectory, testutil.Dataset1, name),
+			filepath.Join(dest, name))
+		assert.Contains(t, b.String(), fmt.Sprintf("fetched package %q", filepath.Join(dest, name)))
+	}
+}
+
 // TestCmdMainBranch_execute tests that get is correctly invoked if default branch
 // is main and master branch doesn't exist
 func TestCmdMainBranch_execute(t *testing.T) {
@@ -119,6 +142,38 @@ func TestCmdMainBranch_execute(t *testing.T) {
 
 }
 
+// TestCmd_manifest tests that get fetches the packages declared in a manifest.
+func TestCmd_manifest(t *testing.T) {
+	g, w, clean := testutil.SetupDefaultRepoAndWorkspace(t, testutil.Dataset1)
+	defer clean()
+
+	err := ioutil.WriteFile(filepath.Join(w.WorkspaceDirectory, "packages.yaml"), []byte(`
+packages:
+- git:
+    repo: file://`+g.RepoDirectory+`
+    directory: java
+    ref: master
+  destination: java
+`), 0600)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	r := cmdget.NewRunner("kpt")
+	r.Command.SetArgs([]string{"-f", "packages.yaml"})
+	err = r.Command.Execute()
+	assert.NoError(t, err)
+	g.AssertEqual(t, filepath.Join(g.DatasetDirectory, testutil.Dataset1, "java"),
+		filepath.Join(w.WorkspaceDirectory, "java"))
+
+	r = cmdget.NewRunner("kpt")
+	r.Command.SilenceErrors = true
+	r.Command.SilenceUsage = true
+	r.Command.SetArgs([]string{"-f", "packages.yaml", "file://" + g.RepoDirectory + ".git/", "./"})
+	err = r.Command.Execute()
+	assert.EqualError(t, err, `unknown command "file://`+g.RepoDirectory+`.git/" for "get"`)
+}
+
 func TestCmd_stdin(t *testing.T) {
 	d, err := ioutil.TempDir("", "kpt")
 	if !assert.NoError(t, err) {
@@ -324,3 +379,50 @@ func TestCmd_Execute_flagAndArgParsing(t *testing.T) {
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "specify '.git'")
 }
+
+// TestCmd_Execute_webURLs verifies GitHub and GitLab web UI URLs are parsed
+// into the repo, directory and ref
+func TestCmd_Execute_webURLs(t *testing.T) {
+	defer func(f func(string, map[string]string) ([]string, error)) { gitutil.RemoteRefs = f }(gitutil.RemoteRefs)
+	gitutil.RemoteRefs = func(repo string, _ map[string]string) ([]string, error) {
+		return []string{"main", "release/v1", "v1.0"}, nil
+	}
+
+	d, err := ioutil.TempDir("", "kpt")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer os.RemoveAll(d)
+
+	tests := []struct {
+		url       string
+		repo      string
+		directory string
+		ref       string
+	}{
+		{url: "https://github.com/foo/bar/tree/main/pkgs/app",
+			repo: "https://github.com/foo/bar", directory: "/pkgs/app", ref: "main"},
+		{url: "https://github.com/foo/bar/tree/release/v1/pkgs/app",
+			repo: "https://github.com/foo/bar", directory: "/pkgs/app", ref: "release/v1"},
+		{url: "https://github.com/foo/bar/blob/v1.0/pkgs/app/Kptfile",
+			repo: "https://github.com/foo/bar", directory: "/pkgs/app", ref: "v1.0"},
+		{url: "https://github.com/foo/bar/tree/8b8ecd5",
+			repo: "https://github.com/foo/bar", directory: "/", ref: "8b8ecd5"},
+		{url: "https://gitlab.com/group/sub/bar/-/tree/release/v1/pkgs/app",
+			repo: "https://gitlab.com/group/sub/bar", directory: "/pkgs/app", ref: "release/v1"},
+		{url: "https://gitlab.example.com/group/bar/-/blob/main/Kptfile",
+			repo: "https://gitlab.example.com/group/bar", directory: "/", ref: "main"},
+	}
+	for _, test := range tests {
+		r := cmdget.NewRunner("kpt")
+		r.Command.RunE = NoOpRunE
+		r.Command.SetArgs([]string{test.url, filepath.Join(d, "my-app")})
+		if !assert.NoError(t, r.Command.Execute(), test.url) {
+			continue
+		}
+		assert.Equal(t, test.repo, r.Get.Repo, test.url)
+		assert.Equal(t, test.directory, r.Get.Directory, test.url)
+		assert.Equal(t, test.ref, r.Get.Ref, test.url)
+		assert.Equal(t, filepath.Join(d, "my-app"), r.Get.Destination, test.url)
+	}
+}
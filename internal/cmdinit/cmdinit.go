@@ -17,19 +17,28 @@ package cmdinit
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"text/template"
 
 	docs "github.com/GoogleContainerTools/kpt/internal/docs/generated/pkgdocs"
+	"github.com/GoogleContainerTools/kpt/internal/util/clusterpkg"
 	"github.com/GoogleContainerTools/kpt/internal/util/cmdutil"
 	"github.com/GoogleContainerTools/kpt/internal/util/man"
+	"github.com/GoogleContainerTools/kpt/internal/util/render"
+	"github.com/GoogleContainerTools/kpt/internal/util/scaffold"
 	"github.com/GoogleContainerTools/kpt/pkg/kptfile"
 	"github.com/spf13/cobra"
+	"k8s.io/kubectl/pkg/cmd/util"
 	"sigs.k8s.io/kustomize/kyaml/errors"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/kio/filters"
+	"sigs.k8s.io/kustomize/kyaml/kio/kioutil"
 	"sigs.k8s.io/kustomize/kyaml/yaml"
 )
 
@@ -50,6 +59,15 @@ func NewRunner(parent string) *Runner {
 	c.Flags().StringVar(&r.Name, "name", "", "package name.  defaults to the directory base name.")
 	c.Flags().StringSliceVar(&r.Tags, "tag", []string{}, "list of tags for the package.")
 	c.Flags().StringVar(&r.URL, "url", "", "link to page with information about the package.")
+	c.Flags().BoolVar(&r.FromLiveCluster, "from-live-cluster", false,
+		"populate the package with the resources in the cluster.")
+	c.Flags().StringSliceVar(&r.Namespaces, "namespaces", nil,
+		"namespaces to read resources from with --from-live-cluster.  defaults to the current namespace.")
+	c.Flags().StringVarP(&r.Selector, "selector", "l", "",
+		"label selector resources must match with --from-live-cluster.")
+	c.Flags().StringVar(&r.Template, "template", "",
+		fmt.Sprintf("template to generate the package from: one of %s, or a git repository.",
+			strings.Join(scaffold.Builtin(), ", ")))
 	cmdutil.FixDocs("kpt", parent, c)
 	r.Command = c
 	return r
@@ -66,6 +84,16 @@ type Runner struct {
 	Name        string
 	Description string
 	URL         string
+
+	// Factory connects to the cluster for --from-live-cluster
+	Factory         util.Factory
+	FromLiveCluster bool
+	Namespaces      []string
+	Selector        string
+
+	// Template is the built-in template, or git repository of a template
+	// package, to generate the package from
+	Template string
 }
 
 func (r *Runner) preRunE(_ *cobra.Command, args []string) error {
@@ -89,6 +117,32 @@ func (r *Runner) runE(c *cobra.Command, args []string) error {
 		return errors.Errorf("%q does not exist", err)
 	}
 
+	var setters []clusterpkg.Setter
+	var t scaffold.Template
+	if r.Template != "" {
+		if r.FromLiveCluster {
+			return errors.Errorf("--template may not be used with --from-live-cluster")
+		}
+		if _, err = os.Stat(filepath.Join(args[0], "Kptfile")); err == nil {
+			return errors.Errorf("--template requires a new package, %q already exists",
+				filepath.Join(args[0], "Kptfile"))
+		}
+		if t, err = scaffold.Load(r.Template, r.Name); err != nil {
+			return err
+		}
+		if err = t.Write(args[0]); err != nil {
+			return err
+		}
+		fmt.Fprintf(c.OutOrStdout(), "wrote %d files from template %q to %q\n",
+			len(t.Files), r.Template, args[0])
+		setters = t.Setters
+	}
+	if r.FromLiveCluster {
+		if setters, err = r.readLiveCluster(c, args[0]); err != nil {
+			return err
+		}
+	}
+
 	if _, err = os.Stat(filepath.Join(args[0], "Kptfile")); os.IsNotExist(err) {
 		fmt.Fprintf(c.OutOrStdout(), "writing %q\n", filepath.Join(args[0], "Kptfile"))
 		k := kptfile.KptFile{
@@ -106,6 +160,8 @@ func (r *Runner) runE(c *cobra.Command, args []string) error {
 			},
 		}
 
+		t.Apply(&k)
+
 		// serialize the gvk when writing the Kptfile
 		k.Kind = kptfile.TypeMeta.Kind
 		k.APIVersion = kptfile.TypeMeta.APIVersion
@@ -148,9 +204,93 @@ func (r *Runner) runE(c *cobra.Command, args []string) error {
 		}
 	}
 
+	if err := clusterpkg.CreateSetters(c.OutOrStdout(), args[0], setters); err != nil {
+		return err
+	}
+	if r.Template != "" {
+		// run the function pipeline of the template
+		return render.Render(args[0])
+	}
 	return nil
 }
 
+// readLiveCluster writes the resources in the cluster to dir, and returns the
+// setters suggested for them.
+func (r *Runner) readLiveCluster(c *cobra.Command, dir string) ([]clusterpkg.Setter, error) {
+	if r.Factory == nil {
+		return nil, errors.Errorf("--from-live-cluster is not supported by this command")
+	}
+	if len(r.Namespaces) == 0 {
+		ns, _, err := r.Factory.ToRawKubeConfigLoader().Namespace()
+		if err != nil {
+			return nil, err
+		}
+		r.Namespaces = []string{ns}
+	}
+	client, err := r.Factory.DynamicClient()
+	if err != nil {
+		return nil, err
+	}
+	dc, err := r.Factory.ToDiscoveryClient()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := c.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	nodes, err := clusterpkg.Reader{
+		Client:     client,
+		Discovery:  dc,
+		Namespaces: r.Namespaces,
+		Selector:   r.Selector,
+	}.Read(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(nodes) == 0 {
+		return nil, errors.Errorf("no resources found in namespaces %s",
+			strings.Join(r.Namespaces, ","))
+	}
+
+	var setters []clusterpkg.Setter
+	err = kio.Pipeline{
+		Inputs: []kio.Reader{&kio.PackageBuffer{Nodes: nodes}},
+		Filters: []kio.Filter{
+			clusterpkg.Scrub{},
+			clusterpkg.Layout{},
+			filters.FormatFilter{},
+			kio.FilterFunc(func(nodes []*yaml.RNode) ([]*yaml.RNode, error) {
+				// don't overwrite files which already exist
+				var existing []string
+				for i := range nodes {
+					p, _, err := kioutil.GetFileAnnotations(nodes[i])
+					if err != nil {
+						return nil, err
+					}
+					if _, err := os.Stat(filepath.Join(dir, p)); err == nil {
+						existing = append(existing, filepath.Join(dir, p))
+					}
+				}
+				if len(existing) > 0 {
+					// the cluster returns resources in no particular order
+					sort.Strings(existing)
+					return nil, errors.Errorf("%q already exists", existing[0])
+				}
+				setters, err = clusterpkg.SuggestSetters(nodes)
+				return nodes, err
+			}),
+		},
+		Outputs: []kio.Writer{&kio.LocalPackageWriter{PackagePath: dir}},
+	}.Execute()
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(c.OutOrStdout(), "wrote %d resources from the cluster to %q\n", len(nodes), dir)
+	return setters, nil
+}
+
 // manTemplate is the content for the automatically generated README.md file.
 // It uses ' instead of ` since golang doesn't allow using ` in a raw string
 // literal. We do a replace on the content before printing.
@@ -15,6 +15,8 @@
 package cmdinit_test
 
 import (
+	"bytes"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -25,7 +27,10 @@ import (
 	"github.com/GoogleContainerTools/kpt/internal/gitutil"
 	"github.com/GoogleContainerTools/kpt/internal/testutil"
 	"github.com/GoogleContainerTools/kpt/internal/util/man"
+	"github.com/GoogleContainerTools/kpt/internal/util/render"
 	"github.com/stretchr/testify/assert"
+	"k8s.io/client-go/discovery"
+	cmdtesting "k8s.io/kubectl/pkg/cmd/testing"
 )
 
 // TestCmd verifies the directory is initialized
@@ -194,3 +199,244 @@ func TestGitUtil_DefaultRef(t *testing.T) {
 		t.FailNow()
 	}
 }
+
+// clusterFactory is a test factory serving the resources of a fake cluster
+type clusterFactory struct {
+	*cmdtesting.TestFactory
+	discovery discovery.CachedDiscoveryInterface
+}
+
+func (f clusterFactory) ToDiscoveryClient() (discovery.CachedDiscoveryInterface, error) {
+	return f.discovery, nil
+}
+
+// TestCmd_fromLiveCluster verifies the package is populated from the cluster
+func TestCmd_fromLiveCluster(t *testing.T) {
+	d, err := ioutil.TempDir("", "kpt")
+	assert.NoError(t, err)
+	defer os.RemoveAll(d)
+	assert.NoError(t, os.Mkdir(filepath.Join(d, "my-pkg"), 0700))
+
+	tf := cmdtesting.NewTestFactory().WithNamespace("app")
+	defer tf.Cleanup()
+	client, dc := testutil.NewFakeCluster(t, `
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: app
+`, `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: app
+  uid: 1234
+  resourceVersion: "42"
+spec:
+  template:
+    spec:
+      containers:
+      - name: web
+        image: nginx:1.19
+status:
+  replicas: 1
+`)
+	tf.FakeDynamicClient = client
+
+	r := cmdinit.NewRunner("kpt")
+	r.Factory = clusterFactory{TestFactory: tf, discovery: dc}
+	r.Command.SetArgs([]string{filepath.Join(d, "my-pkg"), "--from-live-cluster"})
+	b := &bytes.Buffer{}
+	r.Command.SetOut(b)
+	if !assert.NoError(t, r.Command.Execute()) {
+		t.FailNow()
+	}
+	assert.Contains(t, b.String(), `created setter "nginx-image" for image "nginx:1.19"`)
+
+	content, err := ioutil.ReadFile(filepath.Join(d, "my-pkg", "app", "web_deployment.yaml"))
+	assert.NoError(t, err)
+	assert.Equal(t, `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: app # {"$kpt-set":"namespace"}
+spec:
+  template:
+    spec:
+      containers:
+      - name: web
+        image: nginx:1.19 # {"$kpt-set":"nginx-image"}
+`, string(content))
+	assert.FileExists(t, filepath.Join(d, "my-pkg", "app_namespace.yaml"))
+
+	content, err = ioutil.ReadFile(filepath.Join(d, "my-pkg", "Kptfile"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(content), `io.k8s.cli.setters.nginx-image:
+      x-k8s-cli:
+        setter:
+          name: nginx-image
+          value: nginx:1.19`)
+
+	// files aren't overwritten
+	assert.NoError(t, os.RemoveAll(filepath.Join(d, "my-pkg", "Kptfile")))
+	r = cmdinit.NewRunner("kpt")
+	r.Factory = clusterFactory{TestFactory: tf, discovery: dc}
+	r.Command.SetArgs([]string{filepath.Join(d, "my-pkg"), "--from-live-cluster"})
+	r.Command.SetOut(b)
+	r.Command.SilenceUsage = true
+	assert.EqualError(t, r.Command.Execute(), fmt.Sprintf("%q already exists",
+		filepath.Join(d, "my-pkg", "app", "web_deployment.yaml")))
+}
+
+// TestCmd_template verifies the package is generated from the built-in
+// templates, rendered and with setters.
+func TestCmd_template(t *testing.T) {
+	tests := []struct {
+		template string
+		files    []string
+		setters  []string
+		contains map[string]string
+	}{
+		{
+			template: "app",
+			files:    []string{"deployment.yaml", "service.yaml", "fns/default-requests.star"},
+			setters:  []string{"image", "replicas"},
+			contains: map[string]string{
+				"deployment.yaml": `        image: nginx:1.19 # {"$kpt-set":"image"}`,
+				"service.yaml":    `    app.kubernetes.io/part-of: my-pkg`,
+			},
+		},
+		{
+			template: "namespace",
+			files:    []string{"namespace.yaml", "quota.yaml", "limits.yaml", "fns/set-namespace.star"},
+			setters:  []string{"namespace", "max-pods"},
+			contains: map[string]string{
+				"quota.yaml": `  namespace: my-pkg # {"$kpt-set":"namespace"}`,
+			},
+		},
+		{
+			template: "crd-operator",
+			files:    []string{"crd.yaml", "rbac.yaml", "manager.yaml", "fns/grant-crds.star"},
+			setters:  []string{"namespace", "image"},
+			contains: map[string]string{
+				"crd.yaml": `    kind: MyPkg`,
+				// added by the function pipeline
+				"rbac.yaml": `  - mypkgs/status`,
+			},
+		},
+	}
+	for i := range tests {
+		test := tests[i]
+		t.Run(test.template, func(t *testing.T) {
+			d, err := ioutil.TempDir("", "kpt")
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			defer os.RemoveAll(d)
+			dir := filepath.Join(d, "my-pkg")
+			if !assert.NoError(t, os.Mkdir(dir, 0700)) {
+				t.FailNow()
+			}
+
+			r := cmdinit.NewRunner("kpt")
+			r.Command.SetArgs([]string{dir, "--template", test.template})
+			b := &bytes.Buffer{}
+			r.Command.SetOut(b)
+			if !assert.NoError(t, r.Command.Execute()) {
+				t.FailNow()
+			}
+
+			for _, f := range test.files {
+				assert.FileExists(t, filepath.Join(dir, f))
+			}
+			for f, s := range test.contains {
+				content, err := ioutil.ReadFile(filepath.Join(dir, f))
+				if assert.NoError(t, err) {
+					assert.Contains(t, string(content), s)
+				}
+			}
+			content, err := ioutil.ReadFile(filepath.Join(dir, "Kptfile"))
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			assert.Contains(t, string(content), "  starlarkFunctions:\n")
+			for _, s := range test.setters {
+				assert.Contains(t, string(content), "io.k8s.cli.setters."+s+":")
+			}
+
+			// the package is rendered
+			changes, err := render.Verify(dir)
+			if assert.NoError(t, err) {
+				assert.Empty(t, changes)
+			}
+		})
+	}
+}
+
+// TestCmd_templateGit verifies the package is generated from a template
+// package in a git repo.
+func TestCmd_templateGit(t *testing.T) {
+	g, _, clean := testutil.SetupDefaultRepoAndWorkspace(t, testutil.Dataset1)
+	defer clean()
+	if !assert.NoError(t, ioutil.WriteFile(filepath.Join(g.RepoDirectory, "mysql", "Kptfile"),
+		[]byte(`apiVersion: kpt.dev/v1alpha1
+kind: Kptfile
+metadata:
+  name: mysql
+commonLabels:
+  tier: db
+`), 0600)) {
+		t.FailNow()
+	}
+	if !assert.NoError(t, gitutil.NewLocalGitRunner(g.RepoDirectory).Run("add", ".")) {
+		t.FailNow()
+	}
+	testutil.Commit(t, g, "add template")
+
+	d, err := ioutil.TempDir("", "kpt")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer os.RemoveAll(d)
+	dir := filepath.Join(d, "my-pkg")
+	if !assert.NoError(t, os.Mkdir(dir, 0700)) {
+		t.FailNow()
+	}
+
+	r := cmdinit.NewRunner("kpt")
+	r.Command.SetArgs([]string{dir, "--template", "file://" + g.RepoDirectory + ".git/mysql"})
+	r.Command.SetOut(&bytes.Buffer{})
+	if !assert.NoError(t, r.Command.Execute()) {
+		t.FailNow()
+	}
+	assert.FileExists(t, filepath.Join(dir, "mysql-statefulset.resource.yaml"))
+	content, err := ioutil.ReadFile(filepath.Join(dir, "Kptfile"))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, `apiVersion: kpt.dev/v1alpha1
+kind: Kptfile
+metadata:
+  name: my-pkg
+packageMetadata:
+  shortDescription: sample description
+commonLabels:
+  tier: db
+`, string(content))
+}
+
+// TestCmd_templateUnknown verifies unknown templates are rejected.
+func TestCmd_templateUnknown(t *testing.T) {
+	d, err := ioutil.TempDir("", "kpt")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer os.RemoveAll(d)
+
+	r := cmdinit.NewRunner("kpt")
+	r.Command.SetArgs([]string{d, "--template", "web"})
+	r.Command.SilenceUsage = true
+	r.Command.SetOut(&bytes.Buffer{})
+	assert.EqualError(t, r.Command.Execute(), `unknown template "web" -- must be one of `+
+		`app, crd-operator, namespace, or a git repository`)
+}
@@ -0,0 +1,79 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cmdlabel contains the label command
+package cmdlabel
+
+import (
+	"github.com/GoogleContainerTools/kpt/internal/docs/generated/cfgdocs"
+	"github.com/GoogleContainerTools/kpt/internal/util/cmdutil"
+	"github.com/GoogleContainerTools/kpt/internal/util/metadata"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// NewRunner returns a command runner.
+func NewRunner(parent string) *Runner {
+	r := &Runner{Runner: metadata.Runner{Setter: &metadata.Setter{Field: metadata.Labels}}}
+	c := &cobra.Command{
+		Use:     "label [DIR] [KEY=VALUE...]",
+		Short:   cfgdocs.LabelShort,
+		Long:    cfgdocs.LabelShort + "\n" + cfgdocs.LabelLong,
+		Example: cfgdocs.LabelExamples,
+		RunE:    r.runE,
+	}
+	c.Flags().StringVar(&r.Setter.Kind, "kind", "", "Resource kind to label")
+	c.Flags().StringVar(&r.Setter.APIVersion, "apiVersion", "", "Resource apiVersion to label")
+	c.Flags().StringVar(&r.Setter.Name, "name", "", "Resource name to label")
+	c.Flags().StringVar(&r.Setter.Namespace, "namespace", "", "Resource namespace to label")
+	c.Flags().StringVarP(&r.Selector, "selector", "l", "",
+		"label selector of the resources to label, e.g. app=web,tier!=db")
+	c.Flags().StringSliceVar(&r.Values, "kv", []string{}, "label as KEY=VALUE")
+	c.Flags().BoolVar(&r.DryRun, "dry-run", false,
+		"print the labels which would change rather than changing them.")
+	c.Flags().BoolVarP(&r.RecurseSubPackages, "recurse-subpackages", "R", false,
+		"add labels recursively in all the nested subpackages")
+	r.Command = c
+	cmdutil.FixDocs("kpt", parent, c)
+	return r
+}
+
+func NewCommand(parent string) *cobra.Command {
+	return NewRunner(parent).Command
+}
+
+// Runner contains the run function
+type Runner struct {
+	Command  *cobra.Command
+	Values   []string
+	Selector string
+	metadata.Runner
+}
+
+func (r *Runner) runE(c *cobra.Command, args []string) error {
+	dir, values := metadata.SplitArgs(args)
+	var err error
+	if r.Setter.Values, err = metadata.ParseValues(r.Setter.Field, append(r.Values, values...)); err != nil {
+		return err
+	}
+	if r.Selector != "" {
+		if r.Setter.Selector, err = labels.Parse(r.Selector); err != nil {
+			return err
+		}
+	}
+	if dir == "" {
+		return r.RunStream(c.InOrStdin(), c.OutOrStdout())
+	}
+	return r.Run(c.OutOrStdout(), dir)
+}
@@ -0,0 +1,93 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmdlabel_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt/internal/cmdlabel"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCmd_dir(t *testing.T) {
+	d, err := ioutil.TempDir("", "kptlabel")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer os.RemoveAll(d)
+	err = ioutil.WriteFile(filepath.Join(d, "Kptfile"), []byte(`apiVersion: kpt.dev/v1alpha1
+kind: Kptfile
+metadata:
+  name: app
+`), 0600)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	err = ioutil.WriteFile(filepath.Join(d, "deploy.yaml"), []byte(`apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  labels:
+    tier: frontend
+`), 0600)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	b := &bytes.Buffer{}
+	r := cmdlabel.NewRunner("kpt")
+	r.Command.SetArgs([]string{d, "app=web", "tier-", "--dry-run"})
+	r.Command.SetOut(b)
+	if !assert.NoError(t, r.Command.Execute()) {
+		t.FailNow()
+	}
+	assert.Contains(t, b.String(), `would set label "app" to "web" on Deployment/web in deploy.yaml
+would remove label "tier" from Deployment/web in deploy.yaml
+`)
+
+	b.Reset()
+	r = cmdlabel.NewRunner("kpt")
+	r.Command.SetArgs([]string{d, "app=web", "tier-"})
+	r.Command.SetOut(b)
+	if !assert.NoError(t, r.Command.Execute()) {
+		t.FailNow()
+	}
+	actual, err := ioutil.ReadFile(filepath.Join(d, "deploy.yaml"))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  labels:
+    app: 'web'
+`, string(actual))
+}
+
+func TestCmd_invalid(t *testing.T) {
+	r := cmdlabel.NewRunner("kpt")
+	r.Command.SetArgs([]string{"app=not valid"})
+	r.Command.SetOut(&bytes.Buffer{})
+	r.Command.SetErr(&bytes.Buffer{})
+	err := r.Command.Execute()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), `invalid value "not valid" of label "app"`)
+	}
+}
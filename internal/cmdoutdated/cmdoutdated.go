@@ -0,0 +1,74 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cmdoutdated contains the outdated command
+package cmdoutdated
+
+import (
+	docs "github.com/GoogleContainerTools/kpt/internal/docs/generated/pkgdocs"
+	"github.com/GoogleContainerTools/kpt/internal/util/cmdutil"
+	"github.com/GoogleContainerTools/kpt/internal/util/concurrency"
+	"github.com/GoogleContainerTools/kpt/internal/util/outdated"
+	"github.com/spf13/cobra"
+)
+
+// NewRunner returns a command runner.
+func NewRunner(parent string) *Runner {
+	r := &Runner{}
+	c := &cobra.Command{
+		Use:     "outdated [DIR]",
+		Short:   docs.OutdatedShort,
+		Long:    docs.OutdatedShort + "\n" + docs.OutdatedLong,
+		Example: docs.OutdatedExamples,
+		RunE:    r.runE,
+		Args:    cobra.MaximumNArgs(1),
+		PreRunE: r.preRunE,
+	}
+
+	c.Flags().BoolVar(&r.Outdated.All, "all", false,
+		"also report packages which are up to date.")
+	c.Flags().StringVar(&r.Outdated.Output, "output", outdated.TableOutput,
+		"output format -- must be one of: "+outdated.TableOutput+","+outdated.JSONOutput)
+	cmdutil.FixDocs("kpt", parent, c)
+	r.Command = c
+	return r
+}
+
+func NewCommand(parent string) *cobra.Command {
+	return NewRunner(parent).Command
+}
+
+// Runner contains the run function
+type Runner struct {
+	Outdated outdated.Command
+	Command  *cobra.Command
+}
+
+func (r *Runner) preRunE(c *cobra.Command, args []string) error {
+	r.Outdated.Dir = "."
+	if len(args) > 0 {
+		r.Outdated.Dir = args[0]
+	}
+	r.Outdated.StdOut = c.OutOrStdout()
+	limits, err := concurrency.Load(cmdutil.Concurrency)
+	if err != nil {
+		return err
+	}
+	r.Outdated.Concurrency = limits.GitFetch
+	return nil
+}
+
+func (r *Runner) runE(c *cobra.Command, args []string) error {
+	return r.Outdated.Run()
+}
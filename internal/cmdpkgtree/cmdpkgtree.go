@@ -0,0 +1,75 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cmdpkgtree contains the pkg tree command
+package cmdpkgtree
+
+import (
+	docs "github.com/GoogleContainerTools/kpt/internal/docs/generated/pkgdocs"
+	"github.com/GoogleContainerTools/kpt/internal/util/cmdutil"
+	"github.com/GoogleContainerTools/kpt/internal/util/concurrency"
+	"github.com/GoogleContainerTools/kpt/internal/util/pkgtree"
+	"github.com/spf13/cobra"
+)
+
+// NewRunner returns a command runner.
+func NewRunner(parent string) *Runner {
+	r := &Runner{}
+	c := &cobra.Command{
+		Use:     "tree [DIR]",
+		Short:   docs.TreeShort,
+		Long:    docs.TreeShort + "\n" + docs.TreeLong,
+		Example: docs.TreeExamples,
+		RunE:    r.runE,
+		Args:    cobra.MaximumNArgs(1),
+		PreRunE: r.preRunE,
+	}
+
+	c.Flags().StringVar(&r.Tree.Output, "output", pkgtree.TreeOutput,
+		"output format -- must be one of: "+pkgtree.TreeOutput+","+
+			pkgtree.TableOutput+","+pkgtree.JSONOutput)
+	c.Flags().BoolVar(&r.Tree.Drift, "drift", true,
+		"compare each package to the upstream commit it was fetched at.")
+	cmdutil.FixDocs("kpt", parent, c)
+	r.Command = c
+	return r
+}
+
+func NewCommand(parent string) *cobra.Command {
+	return NewRunner(parent).Command
+}
+
+// Runner contains the run function
+type Runner struct {
+	Tree    pkgtree.Command
+	Command *cobra.Command
+}
+
+func (r *Runner) preRunE(c *cobra.Command, args []string) error {
+	r.Tree.Dir = "."
+	if len(args) > 0 {
+		r.Tree.Dir = args[0]
+	}
+	r.Tree.StdOut = c.OutOrStdout()
+	limits, err := concurrency.Load(cmdutil.Concurrency)
+	if err != nil {
+		return err
+	}
+	r.Tree.Concurrency = limits.GitFetch
+	return nil
+}
+
+func (r *Runner) runE(c *cobra.Command, args []string) error {
+	return r.Tree.Run()
+}
@@ -0,0 +1,67 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cmdpublish contains the publish command
+package cmdpublish
+
+import (
+	"github.com/GoogleContainerTools/kpt/internal/docs/generated/pkgdocs"
+	"github.com/GoogleContainerTools/kpt/internal/util/cmdutil"
+	"github.com/GoogleContainerTools/kpt/internal/util/publish"
+	"github.com/spf13/cobra"
+)
+
+// NewRunner returns a command runner.
+func NewRunner(parent string) *Runner {
+	r := &Runner{}
+	c := &cobra.Command{
+		Use:     "publish LOCAL_PKG_DIR VERSION",
+		Short:   pkgdocs.PublishShort,
+		Long:    pkgdocs.PublishShort + "\n" + pkgdocs.PublishLong,
+		Example: pkgdocs.PublishExamples,
+		RunE:    r.runE,
+		Args:    cobra.ExactArgs(2),
+	}
+	c.Flags().StringVar(&r.Publish.Message, "message", "",
+		"message to describe the version with in the tag.")
+	c.Flags().BoolVar(&r.Publish.NoPush, "no-push", false,
+		"create the tag without pushing it.")
+	c.Flags().StringVar(&r.Publish.OCI, "oci", "",
+		"OCI repository to also push the package to.")
+	c.Flags().StringVar(&r.Publish.Remote, "remote", "origin",
+		"git remote to push the tag to.")
+	c.Flags().StringVar(&r.Publish.TagTemplate, "tag-template", "",
+		"template naming the tag of the version, e.g. v{{.Version}}-{{.PkgName}}.")
+	cmdutil.FixDocs("kpt", parent, c)
+	r.Command = c
+	return r
+}
+
+func NewCommand(parent string) *cobra.Command {
+	return NewRunner(parent).Command
+}
+
+// Runner contains the run function
+type Runner struct {
+	Command *cobra.Command
+	Publish publish.Command
+}
+
+func (r *Runner) runE(c *cobra.Command, args []string) error {
+	r.Publish.Path = args[0]
+	r.Publish.Version = args[1]
+	r.Publish.Output = c.OutOrStdout()
+	_, err := r.Publish.Run()
+	return err
+}
@@ -0,0 +1,59 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cmdredact contains the redact command
+package cmdredact
+
+import (
+	"github.com/GoogleContainerTools/kpt/internal/docs/generated/cfgdocs"
+	"github.com/GoogleContainerTools/kpt/internal/util/cmdutil"
+	"github.com/GoogleContainerTools/kpt/internal/util/redact"
+	"github.com/spf13/cobra"
+)
+
+// NewRunner returns a command runner.
+func NewRunner(parent string) *Runner {
+	r := &Runner{}
+	c := &cobra.Command{
+		Use:     "redact DIR DEST",
+		Args:    cobra.ExactArgs(2),
+		Short:   cfgdocs.RedactShort,
+		Long:    cfgdocs.RedactShort + "\n" + cfgdocs.RedactLong,
+		Example: cfgdocs.RedactExamples,
+		RunE:    r.runE,
+	}
+	c.Flags().StringSliceVar(&r.Sanitizer.Annotations, "annotation", nil,
+		"pattern matching the keys of annotations to remove, e.g. internal.example.com/*.")
+	c.Flags().StringSliceVar(&r.Sanitizer.Hosts, "host", nil,
+		"pattern matching the hostnames to mask, e.g. *.corp.example.com.")
+	c.Flags().BoolVar(&r.Sanitizer.PrivateIPs, "private-ips", true,
+		"mask private, loopback and link-local IPv4 addresses.")
+	r.Command = c
+	cmdutil.FixDocs("kpt", parent, c)
+	return r
+}
+
+func NewCommand(parent string) *cobra.Command {
+	return NewRunner(parent).Command
+}
+
+// Runner contains the run function
+type Runner struct {
+	Sanitizer redact.Sanitizer
+	Command   *cobra.Command
+}
+
+func (r *Runner) runE(c *cobra.Command, args []string) error {
+	return r.Sanitizer.Copy(args[0], args[1])
+}
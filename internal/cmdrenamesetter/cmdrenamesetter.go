@@ -0,0 +1,62 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cmdrenamesetter contains the rename-setter command
+package cmdrenamesetter
+
+import (
+	"fmt"
+
+	"github.com/GoogleContainerTools/kpt/internal/docs/generated/cfgdocs"
+	"github.com/GoogleContainerTools/kpt/internal/util/cmdutil"
+	"github.com/GoogleContainerTools/kpt/internal/util/setters"
+	"github.com/spf13/cobra"
+)
+
+// NewRunner returns a command runner.
+func NewRunner(parent string) *Runner {
+	r := &Runner{}
+	c := &cobra.Command{
+		Use:     "rename-setter DIR NAME NEW_NAME",
+		Args:    cobra.ExactArgs(3),
+		Short:   cfgdocs.RenameSetterShort,
+		Long:    cfgdocs.RenameSetterShort + "\n" + cfgdocs.RenameSetterLong,
+		Example: cfgdocs.RenameSetterExamples,
+		RunE:    r.runE,
+	}
+	c.Flags().BoolVarP(&r.RecurseSubPackages, "recurse-subpackages", "R", false,
+		"rename the setter in all the nested subpackages which define it.")
+	r.Command = c
+	cmdutil.FixDocs("kpt", parent, c)
+	return r
+}
+
+func NewCommand(parent string) *cobra.Command {
+	return NewRunner(parent).Command
+}
+
+// Runner contains the run function
+type Runner struct {
+	RecurseSubPackages bool
+	Command            *cobra.Command
+}
+
+func (r *Runner) runE(c *cobra.Command, args []string) error {
+	results, err := setters.RenameSetter(args[0], args[1], args[2], r.RecurseSubPackages)
+	for _, result := range results {
+		fmt.Fprintf(c.OutOrStdout(), "renamed setter %q to %q in package %q, updated %d field reference(s)\n",
+			args[1], args[2], result.Package, result.Fields)
+	}
+	return err
+}
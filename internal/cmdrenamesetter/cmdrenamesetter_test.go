@@ -0,0 +1,86 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmdrenamesetter_test
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt/internal/cmdrenamesetter"
+	"github.com/stretchr/testify/assert"
+)
+
+const kptfile = `apiVersion: kpt.dev/v1alpha1
+kind: Kptfile
+metadata:
+  name: %s
+openAPI:
+  definitions:
+    io.k8s.cli.setters.replicas:
+      x-k8s-cli:
+        setter:
+          name: replicas
+          value: "3"
+`
+
+func TestCmd_recurse(t *testing.T) {
+	d, err := ioutil.TempDir("", "kptrenamesetter")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer os.RemoveAll(d)
+	if !assert.NoError(t, os.MkdirAll(filepath.Join(d, "child"), 0700)) {
+		t.FailNow()
+	}
+	err = ioutil.WriteFile(filepath.Join(d, "Kptfile"), []byte(fmt.Sprintf(kptfile, "parent")), 0600)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	err = ioutil.WriteFile(filepath.Join(d, "child", "Kptfile"), []byte(fmt.Sprintf(kptfile, "child")), 0600)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	err = ioutil.WriteFile(filepath.Join(d, "child", "deploy.yaml"), []byte(`apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  replicas: 3 # {"$kpt-set":"replicas"}
+`), 0600)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	b := &bytes.Buffer{}
+	r := cmdrenamesetter.NewRunner("kpt")
+	r.Command.SetArgs([]string{d, "replicas", "web-replicas", "-R"})
+	r.Command.SetOut(b)
+	if !assert.NoError(t, r.Command.Execute()) {
+		t.FailNow()
+	}
+	assert.Equal(t, fmt.Sprintf(`renamed setter "replicas" to "web-replicas" in package %q, updated 0 field reference(s)
+renamed setter "replicas" to "web-replicas" in package %q, updated 1 field reference(s)
+`, d, filepath.Join(d, "child")), b.String())
+
+	actual, err := ioutil.ReadFile(filepath.Join(d, "child", "deploy.yaml"))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Contains(t, string(actual), `replicas: 3 # {"$kpt-set":"web-replicas"}`)
+}
@@ -0,0 +1,126 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cmdrender contains the render command
+package cmdrender
+
+import (
+	"encoding/json"
+	"io/ioutil"
+
+	docs "github.com/GoogleContainerTools/kpt/internal/docs/generated/fndocs"
+	"github.com/GoogleContainerTools/kpt/internal/gitutil"
+	"github.com/GoogleContainerTools/kpt/internal/util/cmdutil"
+	"github.com/GoogleContainerTools/kpt/internal/util/functions"
+	"github.com/GoogleContainerTools/kpt/internal/util/render"
+	"github.com/GoogleContainerTools/kpt/internal/util/setters"
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/kustomize/kyaml/errors"
+)
+
+// NewRunner returns a command runner.
+func NewRunner(parent string) *Runner {
+	r := &Runner{}
+	c := &cobra.Command{
+		Use:     "render [DIR]",
+		Short:   docs.RenderShort,
+		Long:    docs.RenderShort + "\n" + docs.RenderLong,
+		Example: docs.RenderExamples,
+		RunE:    r.runE,
+		Args:    cobra.MaximumNArgs(1),
+		PreRunE: r.preRunE,
+	}
+
+	c.Flags().BoolVar(&r.Render.DryRun, "dry-run", false,
+		"write the rendered resources to stdout rather than to the package.")
+	c.Flags().StringArrayVar(&r.setValues, "set", nil,
+		"setter value to inject into the rendered resources as NAME=VALUE.  Requires --dry-run.")
+	c.Flags().BoolVar(&r.stats, "stats", false,
+		"print the resources added, removed and modified by each mutator.")
+	c.Flags().StringVar(&r.statsOutput, "stats-output", "",
+		"write the mutator stats to this file as json.")
+	c.Flags().BoolVar(&r.Render.RequirePinned, "require-pinned-upstreams",
+		gitutil.RequirePinnedUpstreamsDefault(),
+		"reject packages whose upstream or dependencies are not pinned to tags or commits.")
+	cmdutil.FixDocs("kpt", parent, c)
+	r.Command = c
+	return r
+}
+
+func NewCommand(parent string) *cobra.Command {
+	return NewRunner(parent).Command
+}
+
+// Runner contains the run function
+type Runner struct {
+	Render      render.Command
+	Command     *cobra.Command
+	setValues   []string
+	stats       bool
+	statsOutput string
+}
+
+func (r *Runner) preRunE(c *cobra.Command, args []string) error {
+	r.Render.Path = "."
+	if len(args) > 0 {
+		r.Render.Path = args[0]
+	}
+	r.Render.Output = c.OutOrStdout()
+	r.Render.Log = c.ErrOrStderr()
+	values, err := setters.ParseSetValues(r.setValues)
+	if err != nil {
+		return err
+	}
+	if len(values) > 0 && !r.Render.DryRun {
+		return errors.Errorf("--set requires --dry-run, set the setters in the package with kpt cfg set")
+	}
+	r.Render.Values = values
+	if r.stats || r.statsOutput != "" {
+		r.Render.Stats = &functions.Stats{}
+	}
+	return nil
+}
+
+func (r *Runner) runE(c *cobra.Command, args []string) error {
+	// the required setters must be set before the package is rendered
+	// in place, but its render may be inspected before they are
+	if !r.Render.DryRun {
+		if err := setters.CheckForRequiredSetters(r.Render.Path, nil); err != nil {
+			return err
+		}
+	}
+	if err := r.Render.Run(); err != nil {
+		return err
+	}
+	if r.stats {
+		// the rendered resources are written to stdout by a dry run
+		w := c.OutOrStdout()
+		if r.Render.DryRun {
+			w = c.ErrOrStderr()
+		}
+		if err := r.Render.Stats.Write(w); err != nil {
+			return err
+		}
+	}
+	if r.statsOutput != "" {
+		b, err := json.MarshalIndent(r.Render.Stats, "", "  ")
+		if err != nil {
+			return errors.Wrap(err)
+		}
+		if err := ioutil.WriteFile(r.statsOutput, b, 0600); err != nil {
+			return errors.WrapPrefixf(err, "failed to write mutator stats")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cmdresources contains the resources command
+package cmdresources
+
+import (
+	docs "github.com/GoogleContainerTools/kpt/internal/docs/generated/pkgdocs"
+	"github.com/GoogleContainerTools/kpt/internal/util/cmdutil"
+	"github.com/GoogleContainerTools/kpt/internal/util/resources"
+	"github.com/spf13/cobra"
+)
+
+// NewRunner returns a command runner.
+func NewRunner(parent string) *Runner {
+	r := &Runner{}
+	c := &cobra.Command{
+		Use:     "resources DIR",
+		Short:   docs.ResourcesShort,
+		Long:    docs.ResourcesShort + "\n" + docs.ResourcesLong,
+		Example: docs.ResourcesExamples,
+		RunE:    r.runE,
+		Args:    cobra.ExactArgs(1),
+		PreRunE: r.preRunE,
+	}
+
+	c.Flags().StringVar(&r.Resources.Output, "output", resources.TableOutput,
+		"output format -- must be one of: "+resources.TableOutput+","+resources.JSONOutput)
+	cmdutil.FixDocs("kpt", parent, c)
+	r.Command = c
+	return r
+}
+
+func NewCommand(parent string) *cobra.Command {
+	return NewRunner(parent).Command
+}
+
+// Runner contains the run function
+type Runner struct {
+	Resources resources.Command
+	Command   *cobra.Command
+}
+
+func (r *Runner) preRunE(c *cobra.Command, args []string) error {
+	r.Resources.Dir = args[0]
+	r.Resources.StdOut = c.OutOrStdout()
+	return nil
+}
+
+func (r *Runner) runE(c *cobra.Command, args []string) error {
+	return r.Resources.Run()
+}
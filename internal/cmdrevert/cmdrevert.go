@@ -0,0 +1,62 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cmdrevert contains the revert command
+package cmdrevert
+
+import (
+	docs "github.com/GoogleContainerTools/kpt/internal/docs/generated/pkgdocs"
+	"github.com/GoogleContainerTools/kpt/internal/util/cmdutil"
+	"github.com/GoogleContainerTools/kpt/internal/util/revert"
+	"github.com/spf13/cobra"
+)
+
+// NewRunner returns a command runner.
+func NewRunner(parent string) *Runner {
+	r := &Runner{}
+	c := &cobra.Command{
+		Use:     "revert LOCAL_PKG_DIR",
+		Short:   docs.RevertShort,
+		Long:    docs.RevertShort + "\n" + docs.RevertLong,
+		Example: docs.RevertExamples,
+		RunE:    r.runE,
+		Args:    cobra.ExactArgs(1),
+		PreRunE: r.preRunE,
+	}
+	cmdutil.FixDocs("kpt", parent, c)
+	c.Flags().BoolVar(&r.Revert.Force, "force", false,
+		"revert the package even if it was changed after the update, discarding the changes.")
+	r.Command = c
+	return r
+}
+
+func NewCommand(parent string) *cobra.Command {
+	return NewRunner(parent).Command
+}
+
+// Runner contains the run function
+type Runner struct {
+	Revert  revert.Command
+	Command *cobra.Command
+}
+
+func (r *Runner) preRunE(c *cobra.Command, args []string) error {
+	r.Revert.Path = args[0]
+	r.Revert.StdOut = c.OutOrStdout()
+	return nil
+}
+
+func (r *Runner) runE(c *cobra.Command, args []string) error {
+	return r.Revert.Run()
+}
@@ -0,0 +1,84 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cmdrunjob contains the run-job command
+package cmdrunjob
+
+import (
+	"github.com/GoogleContainerTools/kpt/internal/util/functions"
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/kustomize/kyaml/fn/runtime/runtimeutil"
+)
+
+// NewRunner returns a command runner.
+func NewRunner() *Runner {
+	r := &Runner{}
+	c := &cobra.Command{
+		Use:   "run-job IMAGE",
+		Short: "Run a function container as a Job in the cluster",
+		Long: `Run a function container as a Job in the cluster.
+
+run-job accepts the flags of docker run, and is run in place of docker to run
+the functions of kpt fn run with the kubernetes container runtime.  It reads
+the ResourceList on stdin and writes the result to stdout.
+`,
+		RunE:   r.runE,
+		Args:   cobra.ExactArgs(1),
+		Hidden: true,
+	}
+	c.Flags().StringVar(&r.Network, "network", "none",
+		"network of the container, the pod network unless none.")
+	c.Flags().StringVar(&r.User, "user", "",
+		"numeric user the container is run as.")
+	c.Flags().StringArrayVar(&r.Mounts, "mount", nil,
+		"mounts, which aren't supported by Jobs.")
+	c.Flags().StringArrayVarP(&r.Env, "env", "e", nil,
+		"environment variables of the container.")
+	// the flags of docker run which don't apply to Jobs
+	c.Flags().Bool("rm", false, "ignored, Jobs are always deleted.")
+	c.Flags().BoolP("interactive", "i", false, "ignored, stdin is always attached.")
+	c.Flags().StringArrayP("attach", "a", nil, "ignored, every stream is attached.")
+	c.Flags().StringArray("security-opt", nil, "ignored, privilege escalation is never allowed.")
+	r.Command = c
+	return r
+}
+
+func NewCommand() *cobra.Command {
+	return NewRunner().Command
+}
+
+// Runner contains the run function
+type Runner struct {
+	Network string
+	User    string
+	Mounts  []string
+	Env     []string
+	Command *cobra.Command
+}
+
+func (r *Runner) runE(c *cobra.Command, args []string) error {
+	spec := runtimeutil.ContainerSpec{
+		Image:   args[0],
+		Network: r.Network != "none",
+		Env:     r.Env,
+	}
+	for _, m := range r.Mounts {
+		spec.StorageMounts = append(spec.StorageMounts, runtimeutil.StringToStorageMount(m))
+	}
+	job, err := functions.GetJob()
+	if err != nil {
+		return err
+	}
+	return job.Run(spec, r.User, c.InOrStdin(), c.OutOrStdout(), c.ErrOrStderr())
+}
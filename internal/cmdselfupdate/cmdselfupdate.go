@@ -0,0 +1,70 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cmdselfupdate contains the self-update command
+package cmdselfupdate
+
+import (
+	"github.com/GoogleContainerTools/kpt/internal/util/cmdutil"
+	"github.com/GoogleContainerTools/kpt/internal/util/selfupdate"
+	"github.com/spf13/cobra"
+)
+
+// NewRunner returns a command runner.
+func NewRunner(parent, version string) *Runner {
+	r := &Runner{}
+	c := &cobra.Command{
+		Use:   "self-update",
+		Short: "Update kpt to the latest release",
+		Long: `Update kpt to the latest release.
+
+self-update downloads the kpt release binary for the current platform from
+GitHub, verifies it against the signed checksums of the release, and
+atomically replaces the running kpt binary with it.
+
+The GitHub API is used to find the latest release, see kpt pkg update for
+the environment variables configuring it.
+`,
+		Example: `  # update kpt to the latest stable release
+  kpt self-update
+
+  # update kpt to the latest release, including pre-releases
+  kpt self-update --channel latest
+`,
+		RunE: r.runE,
+		Args: cobra.NoArgs,
+	}
+	c.Flags().StringVar(&r.SelfUpdate.Channel, "channel", selfupdate.StableChannel,
+		"release channel to update from -- must be one of: "+
+			selfupdate.StableChannel+","+selfupdate.LatestChannel)
+	cmdutil.FixDocs("kpt", parent, c)
+	r.SelfUpdate.Version = version
+	r.Command = c
+	return r
+}
+
+func NewCommand(parent, version string) *cobra.Command {
+	return NewRunner(parent, version).Command
+}
+
+// Runner contains the run function
+type Runner struct {
+	SelfUpdate selfupdate.Command
+	Command    *cobra.Command
+}
+
+func (r *Runner) runE(c *cobra.Command, _ []string) error {
+	r.SelfUpdate.Out = c.OutOrStdout()
+	return r.SelfUpdate.Run()
+}
@@ -0,0 +1,66 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cmdsetcondition contains the set-condition command
+package cmdsetcondition
+
+import (
+	"fmt"
+
+	"github.com/GoogleContainerTools/kpt/internal/docs/generated/cfgdocs"
+	"github.com/GoogleContainerTools/kpt/internal/util/cmdutil"
+	"github.com/GoogleContainerTools/kpt/internal/util/conditions"
+	"github.com/spf13/cobra"
+)
+
+// NewRunner returns a command runner.
+func NewRunner(parent string) *Runner {
+	r := &Runner{}
+	c := &cobra.Command{
+		Use:     "set-condition DIR NAME true|false",
+		Args:    cobra.ExactArgs(3),
+		Short:   cfgdocs.SetConditionShort,
+		Long:    cfgdocs.SetConditionShort + "\n" + cfgdocs.SetConditionLong,
+		Example: cfgdocs.SetConditionExamples,
+		RunE:    r.runE,
+	}
+	r.Command = c
+	cmdutil.FixDocs("kpt", parent, c)
+	return r
+}
+
+func NewCommand(parent string) *cobra.Command {
+	return NewRunner(parent).Command
+}
+
+// Runner contains the run function
+type Runner struct {
+	Command *cobra.Command
+}
+
+func (r *Runner) runE(c *cobra.Command, args []string) error {
+	var value bool
+	switch args[2] {
+	case "true":
+		value = true
+	case "false":
+	default:
+		return fmt.Errorf("condition value must be true or false, got %q", args[2])
+	}
+	if err := conditions.Set(args[0], args[1], value); err != nil {
+		return err
+	}
+	fmt.Fprintf(c.OutOrStdout(), "set condition %q to %s\n", args[1], args[2])
+	return nil
+}
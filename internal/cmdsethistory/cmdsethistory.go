@@ -0,0 +1,63 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cmdsethistory contains the set-history command
+package cmdsethistory
+
+import (
+	"github.com/GoogleContainerTools/kpt/internal/docs/generated/cfgdocs"
+	"github.com/GoogleContainerTools/kpt/internal/util/cmdutil"
+	"github.com/GoogleContainerTools/kpt/internal/util/setters"
+	"github.com/spf13/cobra"
+)
+
+// NewRunner returns a command runner.
+func NewRunner(parent string) *Runner {
+	r := &Runner{}
+	c := &cobra.Command{
+		Use:     "set-history DIR [NAME]",
+		Args:    cobra.RangeArgs(1, 2),
+		Short:   cfgdocs.SetHistoryShort,
+		Long:    cfgdocs.SetHistoryShort + "\n" + cfgdocs.SetHistoryLong,
+		Example: cfgdocs.SetHistoryExamples,
+		RunE:    r.runE,
+	}
+	c.Flags().StringVarP(&r.Output, "output", "o", "",
+		"output format -- json or yaml.  Defaults to a table.")
+	r.Command = c
+	cmdutil.FixDocs("kpt", parent, c)
+	return r
+}
+
+func NewCommand(parent string) *cobra.Command {
+	return NewRunner(parent).Command
+}
+
+// Runner contains the run function
+type Runner struct {
+	Command *cobra.Command
+	Output  string
+}
+
+func (r *Runner) runE(c *cobra.Command, args []string) error {
+	h, err := setters.ReadHistory(args[0])
+	if err != nil {
+		return err
+	}
+	var name string
+	if len(args) > 1 {
+		name = args[1]
+	}
+	return setters.WriteHistory(c.OutOrStdout(), r.Output, setters.FilterHistory(h, name))
+}
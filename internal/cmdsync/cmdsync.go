@@ -17,9 +17,13 @@ package cmdsync
 
 import (
 	docs "github.com/GoogleContainerTools/kpt/internal/docs/generated/pkgdocs"
+	"github.com/GoogleContainerTools/kpt/internal/gitutil"
 	"github.com/GoogleContainerTools/kpt/internal/util/cmdutil"
+	"github.com/GoogleContainerTools/kpt/internal/util/concurrency"
+	"github.com/GoogleContainerTools/kpt/internal/util/license"
 	"github.com/GoogleContainerTools/kpt/internal/util/sync"
 	"github.com/spf13/cobra"
+	"sigs.k8s.io/kustomize/kyaml/errors"
 )
 
 // NewRunner returns a command runner.
@@ -39,6 +43,22 @@ func NewRunner(parent string) *Runner {
 		"print verbose logging information.")
 	c.Flags().BoolVar(&r.Sync.DryRun, "dry-run", false,
 		"print sync actions without performing them.")
+	c.Flags().BoolVar(&r.Sync.RequirePinned, "require-pinned-upstreams",
+		gitutil.RequirePinnedUpstreamsDefault(),
+		"reject dependencies which are not pinned to tags or commits.")
+	c.Flags().StringArrayVar(&r.gitConfig, "git-config", nil,
+		"git config key=value to pass to each git command, e.g. http.sslCAInfo=ca.pem.  may be repeated.")
+	c.Flags().BoolVar(&r.Sync.Recursive, "recursive", true,
+		"also sync the dependencies declared by each dependency.")
+	c.Flags().IntVar(&r.fetchConcurrency, "fetch-concurrency", 0,
+		"number of dependencies to fetch or update at once.  defaults to the --concurrency limit.")
+	c.Flags().IntVar(&r.fnConcurrency, "fn-concurrency", 0,
+		"number of dependencies to run functions for at once.  defaults to the --concurrency limit.")
+	c.Flags().StringSliceVar(&r.allowedLicenses, "allowed-licenses", nil,
+		"SPDX ids of the licenses dependencies may be fetched under.  defaults to any license.")
+	c.Flags().StringVar(&r.licensePolicy, "license-policy", license.FailMode,
+		"what to do with dependencies under licenses not in --allowed-licenses -- must be one of: "+
+			license.FailMode+","+license.WarnMode)
 	cmdutil.FixDocs("kpt", parent, c)
 	r.Command = c
 
@@ -51,14 +71,43 @@ func NewCommand(parent string) *cobra.Command {
 }
 
 type Runner struct {
-	Sync    sync.Command
-	Command *cobra.Command
+	fetchConcurrency int
+	fnConcurrency    int
+	allowedLicenses  []string
+	licensePolicy    string
+	gitConfig        []string
+	Sync             sync.Command
+	Command          *cobra.Command
 }
 
 func (r *Runner) preRunE(c *cobra.Command, args []string) error {
 	r.Sync.Dir = args[0]
 	r.Sync.StdOut = c.OutOrStdout()
 	r.Sync.StdErr = c.ErrOrStderr()
+	licenses, err := license.NewPolicy(r.allowedLicenses, r.licensePolicy, c.ErrOrStderr())
+	if err != nil {
+		return err
+	}
+	r.Sync.Licenses = licenses
+	if r.Sync.GitConfig, err = gitutil.ParseConfig(r.gitConfig); err != nil {
+		return err
+	}
+
+	limits, err := concurrency.Load(cmdutil.Concurrency)
+	if err != nil {
+		return err
+	}
+	r.Sync.FetchConcurrency = limits.GitFetch
+	r.Sync.FunctionConcurrency = limits.Functions
+	if r.fetchConcurrency < 0 || r.fnConcurrency < 0 {
+		return errors.Errorf("--fetch-concurrency and --fn-concurrency must be positive integers")
+	}
+	if r.fetchConcurrency > 0 {
+		r.Sync.FetchConcurrency = r.fetchConcurrency
+	}
+	if r.fnConcurrency > 0 {
+		r.Sync.FunctionConcurrency = r.fnConcurrency
+	}
 	return nil
 }
 
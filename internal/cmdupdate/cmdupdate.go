@@ -22,8 +22,12 @@ import (
 	"strings"
 
 	docs "github.com/GoogleContainerTools/kpt/internal/docs/generated/pkgdocs"
+	"github.com/GoogleContainerTools/kpt/internal/gitutil"
 	"github.com/GoogleContainerTools/kpt/internal/util/cmdutil"
+	"github.com/GoogleContainerTools/kpt/internal/util/concurrency"
+	"github.com/GoogleContainerTools/kpt/internal/util/sync"
 	"github.com/GoogleContainerTools/kpt/internal/util/update"
+	"github.com/GoogleContainerTools/kpt/pkg/kptfile"
 	"github.com/spf13/cobra"
 	"sigs.k8s.io/kustomize/kyaml/errors"
 )
@@ -44,15 +48,38 @@ func NewRunner(parent string) *Runner {
 
 	c.Flags().StringVarP(&r.Update.Repo, "repo", "r", "",
 		"git repo url for updating contents.  defaults to the repo the package was fetched from.")
+	c.Flags().BoolVar(&r.Update.ToLatest, "to-latest", false,
+		"update to the newest version the package is tagged with.  tags prefixed by the package directory are preferred.")
+	c.Flags().StringVar(&r.Update.Constraint, "constraint", "",
+		"only update to versions satisfying this constraint with --to-latest, e.g. '^1.2' or '>=1.2, <2'.")
 	c.Flags().StringVar(&r.strategy, "strategy", string(update.FastForward),
 		"update strategy for preserving changes to the local package -- must be one of: "+
 			strings.Join(update.Strategies, ","))
 	c.Flags().BoolVar(&r.Update.DryRun, "dry-run", false,
-		"print the git patch rather than merging it.")
+		"print the changes the update would make rather than making them.")
+	c.Flags().StringVar(&r.output, "output", string(update.DryRunDiff),
+		"format to print the changes in for --dry-run -- must be one of: "+
+			strings.Join(update.DryRunFormats, ","))
+	c.Flags().StringVar(&r.onConflict, "on-conflict", string(update.ConflictUpstream),
+		"how to resolve changes made both upstream and locally with the resource merge "+
+			"strategies -- must be one of: "+strings.Join(update.ConflictPolicies, ","))
+	c.Flags().StringSliceVar(&r.preferLocal, "prefer-local", nil,
+		"fields to keep the local value of when they conflict, e.g. spec.replicas.")
+	c.Flags().StringSliceVar(&r.preferUpstream, "prefer-upstream", nil,
+		"fields to take the upstream value of when they conflict, e.g. metadata.labels.")
+	c.Flags().BoolVar(&r.Dependencies, "dependencies", true,
+		"fetch and update the dependencies declared in the updated Kptfile, recursively.")
 	c.Flags().BoolVar(&r.AutoSet, "auto-set", true,
 		"automatically perform setters based off the environment")
+	c.Flags().BoolVar(&r.Update.SkipHooks, "skip-hooks", false,
+		"don't run the post-update hooks declared in the updated Kptfile.")
 	c.Flags().BoolVar(&r.Update.Verbose, "verbose", false,
 		"print verbose logging information.")
+	c.Flags().BoolVar(&r.Update.RequirePinned, "require-pinned-upstreams",
+		gitutil.RequirePinnedUpstreamsDefault(),
+		"reject updating to refs which are not tags or commits.")
+	c.Flags().StringArrayVar(&r.gitConfig, "git-config", nil,
+		"git config key=value to pass to each git command, e.g. http.sslCAInfo=ca.pem.  may be repeated.")
 	cmdutil.FixDocs("kpt", parent, c)
 	r.Command = c
 	return r
@@ -65,20 +92,47 @@ func NewCommand(parent string) *cobra.Command {
 // Runner contains the run function.
 // TODO, support listing versions
 type Runner struct {
-	strategy string
-	AutoSet  bool
-	Update   update.Command
-	Command  *cobra.Command
+	strategy       string
+	output         string
+	onConflict     string
+	preferLocal    []string
+	preferUpstream []string
+	gitConfig      []string
+	AutoSet        bool
+	Dependencies   bool
+	Update         update.Command
+	Command        *cobra.Command
 }
 
 func (r *Runner) preRunE(c *cobra.Command, args []string) error {
 	r.Update.Strategy = update.StrategyType(r.strategy)
+	r.Update.DryRunFormat = update.DryRunFormat(r.output)
+	if !contains(update.DryRunFormats, r.output) {
+		return errors.Errorf("unrecognized --output %q -- must be one of: %s",
+			r.output, strings.Join(update.DryRunFormats, ","))
+	}
+	r.Update.OnConflict = update.ConflictPolicy(r.onConflict)
+	if !contains(update.ConflictPolicies, r.onConflict) {
+		return errors.Errorf("unrecognized --on-conflict %q -- must be one of: %s",
+			r.onConflict, strings.Join(update.ConflictPolicies, ","))
+	}
+	if len(r.preferLocal) > 0 {
+		r.Update.ConflictRules = append(r.Update.ConflictRules, kptfile.ConflictRule{
+			Fields: r.preferLocal, Prefer: kptfile.PreferLocal})
+	}
+	if len(r.preferUpstream) > 0 {
+		r.Update.ConflictRules = append(r.Update.ConflictRules, kptfile.ConflictRule{
+			Fields: r.preferUpstream, Prefer: kptfile.PreferUpstream})
+	}
 	parts := strings.Split(args[0], "@")
 	if len(parts) > 2 {
 		return errors.Errorf("at most 1 version permitted")
 	}
 
 	var err error
+	if r.Update.GitConfig, err = gitutil.ParseConfig(r.gitConfig); err != nil {
+		return err
+	}
 	r.Update.Path, r.Update.FullPackagePath, err = resolveAbsAndRelPaths(parts[0])
 	if err != nil {
 		return err
@@ -87,6 +141,12 @@ func (r *Runner) preRunE(c *cobra.Command, args []string) error {
 	if len(parts) > 1 {
 		r.Update.Ref = parts[1]
 	}
+	if r.Update.ToLatest && r.Update.Ref != "" {
+		return errors.Errorf("--to-latest may not be used with a version")
+	}
+	if r.Update.Constraint != "" && !r.Update.ToLatest {
+		return errors.Errorf("--constraint requires --to-latest")
+	}
 	r.Update.AutoSet = r.AutoSet
 
 	return nil
@@ -96,15 +156,42 @@ func (r *Runner) runE(c *cobra.Command, args []string) error {
 	if len(r.Update.Ref) > 0 {
 		fmt.Fprintf(c.ErrOrStderr(), "updating package %q to %s\n",
 			r.Update.Path, r.Update.Ref)
+	} else if r.Update.ToLatest {
+		fmt.Fprintf(c.ErrOrStderr(), "updating package %q to the latest version\n",
+			r.Update.Path)
 	} else {
 		fmt.Fprintf(c.ErrOrStderr(), "updating package %q\n",
 			r.Update.Path)
 	}
+	if r.Update.Output == nil {
+		r.Update.Output = c.OutOrStdout()
+	}
+	if r.Update.Input == nil {
+		r.Update.Input = c.InOrStdin()
+	}
 	if err := r.Update.Run(); err != nil {
 		return err
 	}
+	if !r.Dependencies || r.Update.DryRun {
+		return nil
+	}
 
-	return nil
+	// sync the dependencies declared by the updated package
+	limits, err := concurrency.Load(cmdutil.Concurrency)
+	if err != nil {
+		return err
+	}
+	err = sync.Command{
+		Dir:                 r.Update.FullPackagePath,
+		Recursive:           true,
+		StdOut:              c.OutOrStdout(),
+		StdErr:              c.ErrOrStderr(),
+		RequirePinned:       r.Update.RequirePinned,
+		GitConfig:           r.Update.GitConfig,
+		FetchConcurrency:    limits.GitFetch,
+		FunctionConcurrency: limits.Functions,
+	}.Run()
+	return errors.WrapPrefixf(err, "failed to sync the dependencies of %q", r.Update.Path)
 }
 
 func resolveAbsAndRelPaths(path string) (string, string, error) {
@@ -135,3 +222,12 @@ func resolveAbsAndRelPaths(path string) (string, string, error) {
 	}
 	return relPath, absPath, nil
 }
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
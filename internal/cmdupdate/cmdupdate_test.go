@@ -214,6 +214,32 @@ func TestCmd_Execute_flagAndArgParsing(t *testing.T) {
 	assert.Equal(t, "foo", r.Update.Path)
 	assert.Equal(t, update.KResourceMerge, r.Update.Strategy)
 	assert.Equal(t, "", r.Update.Ref)
+
+	// verify the dry-run output format is set
+	r = cmdupdate.NewRunner("kpt")
+	r.Command.RunE = NoOpRunE
+	r.Command.SetArgs([]string{"foo", "--dry-run", "--output", "summary"})
+	err = r.Command.Execute()
+	assert.NoError(t, err)
+	assert.True(t, r.Update.DryRun)
+	assert.Equal(t, update.DryRunSummary, r.Update.DryRunFormat)
+
+	// verify the conflict policy is set
+	r = cmdupdate.NewRunner("kpt")
+	r.Command.RunE = NoOpRunE
+	r.Command.SetArgs([]string{"foo", "--on-conflict", "prompt"})
+	err = r.Command.Execute()
+	assert.NoError(t, err)
+	assert.Equal(t, update.ConflictPrompt, r.Update.OnConflict)
+
+	// verify an error is thrown for an unknown output format
+	r = cmdupdate.NewRunner("kpt")
+	r.Command.SilenceErrors = true
+	r.Command.SilenceUsage = true
+	r.Command.RunE = failRun
+	r.Command.SetArgs([]string{"foo", "--dry-run", "--output", "yaml"})
+	err = r.Command.Execute()
+	assert.EqualError(t, err, `unrecognized --output "yaml" -- must be one of: diff,summary,json`)
 }
 
 // TestCmd_fail verifies that that command returns an error when it fails rather than exiting the process
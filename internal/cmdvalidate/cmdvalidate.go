@@ -0,0 +1,66 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cmdvalidate contains the validate command
+package cmdvalidate
+
+import (
+	docs "github.com/GoogleContainerTools/kpt/internal/docs/generated/pkgdocs"
+	"github.com/GoogleContainerTools/kpt/internal/util/cmdutil"
+	"github.com/GoogleContainerTools/kpt/internal/util/validate"
+	"github.com/spf13/cobra"
+)
+
+// NewRunner returns a command runner.
+func NewRunner(parent string) *Runner {
+	r := &Runner{}
+	c := &cobra.Command{
+		Use:     "validate [DIR]",
+		Short:   docs.ValidateShort,
+		Long:    docs.ValidateShort + "\n" + docs.ValidateLong,
+		Example: docs.ValidateExamples,
+		RunE:    r.runE,
+		Args:    cobra.MaximumNArgs(1),
+		PreRunE: r.preRunE,
+	}
+
+	c.Flags().StringVar(&r.Validate.Output, "output", validate.TableOutput,
+		"output format -- must be one of: "+validate.TableOutput+","+validate.JSONOutput)
+	cmdutil.FixDocs("kpt", parent, c)
+	r.Command = c
+	return r
+}
+
+func NewCommand(parent string) *cobra.Command {
+	return NewRunner(parent).Command
+}
+
+// Runner contains the run function
+type Runner struct {
+	Validate validate.Command
+	Command  *cobra.Command
+}
+
+func (r *Runner) preRunE(c *cobra.Command, args []string) error {
+	r.Validate.Dir = "."
+	if len(args) > 0 {
+		r.Validate.Dir = args[0]
+	}
+	r.Validate.StdOut = c.OutOrStdout()
+	return nil
+}
+
+func (r *Runner) runE(c *cobra.Command, args []string) error {
+	return r.Validate.Run()
+}
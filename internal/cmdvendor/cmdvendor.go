@@ -0,0 +1,76 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cmdvendor contains the vendor command
+package cmdvendor
+
+import (
+	docs "github.com/GoogleContainerTools/kpt/internal/docs/generated/pkgdocs"
+	"github.com/GoogleContainerTools/kpt/internal/gitutil"
+	"github.com/GoogleContainerTools/kpt/internal/util/cmdutil"
+	"github.com/GoogleContainerTools/kpt/internal/util/get"
+	"github.com/GoogleContainerTools/kpt/internal/util/git"
+	"github.com/GoogleContainerTools/kpt/internal/util/vendored"
+	"github.com/spf13/cobra"
+)
+
+// NewRunner returns a command runner.
+func NewRunner(parent string) *Runner {
+	r := &Runner{}
+	c := &cobra.Command{
+		Use:     "vendor [DIR]",
+		Short:   docs.VendorShort,
+		Long:    docs.VendorShort + "\n" + docs.VendorLong,
+		Example: docs.VendorExamples,
+		RunE:    r.runE,
+		Args:    cobra.MaximumNArgs(1),
+		PreRunE: r.preRunE,
+	}
+	cmdutil.FixDocs("kpt", parent, c)
+	r.Command = c
+	return r
+}
+
+func NewCommand(parent string) *cobra.Command {
+	return NewRunner(parent).Command
+}
+
+// Runner contains the run function
+type Runner struct {
+	Vendor  vendored.Command
+	Command *cobra.Command
+}
+
+func (r *Runner) preRunE(c *cobra.Command, args []string) error {
+	r.Vendor.Path = "."
+	if len(args) > 0 {
+		r.Vendor.Path = args[0]
+	}
+	r.Vendor.StdOut = c.OutOrStdout()
+	r.Vendor.Cloner = clone
+	return nil
+}
+
+func (r *Runner) runE(c *cobra.Command, args []string) error {
+	return r.Vendor.Run()
+}
+
+// clone clones repoSpec from git, never from the vendor tree being replaced.
+func clone(repoSpec *git.RepoSpec) error {
+	defaultRef, err := gitutil.DefaultRef(repoSpec.OrgRepo)
+	if err != nil {
+		return err
+	}
+	return get.ClonerUsingGitExec(repoSpec, defaultRef)
+}
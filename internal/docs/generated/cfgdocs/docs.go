@@ -53,12 +53,19 @@ var CfgExamples = `
 
 var AnnotateShort = `Set an annotation on one or more resources`
 var AnnotateLong = `
-  kpt cfg annotate DIR --kv KEY=VALUE...
+  kpt cfg annotate [DIR] [KEY=VALUE...] [KEY-...] [flags]
 
 Args:
 
   DIR:
-    Path to a package directory
+    Path to a package directory.  '-' or omitting it annotates the resources
+    read from stdin, writing them to stdout.
+  
+  KEY=VALUE:
+    An annotation to set.
+  
+  KEY-:
+    An annotation to remove.
 `
 var AnnotateExamples = `
   # set an annotation on all Resources: 'key: value'
@@ -72,6 +79,44 @@ var AnnotateExamples = `
 
   # set multiple annotations
   kpt cfg annotate DIR --kv key1=value1 --kv key2=value2
+
+  # set annotations on the Deployments in namespace foo
+  kpt cfg annotate DIR key1=value1 key2=value2 --kind Deployment --namespace foo
+
+  # print the annotations which would be set on Resources labeled app=web
+  kpt cfg annotate DIR owner=team-web -l app=web --dry-run
+
+  # remove an annotation from all Resources
+  kpt cfg annotate DIR key-
+`
+
+var CascadeShort = `Cascade setter values from parent to child packages`
+var CascadeLong = `
+  kpt cfg cascade [DIR]
+
+Args:
+
+  DIR
+    Path to the parent package directory.  Defaults to the current
+    working directory.
+
+Flags:
+
+  --dry-run
+    Print the effective setter values without setting them.
+`
+var CascadeExamples = `
+  # cascade the setters of the parent package to its subpackages
+  $ kpt cfg cascade parent/
+  PACKAGE    SETTER     VALUE  SOURCE  SET
+  .          namespace  prod   .       true
+  .          replicas   1      .       false
+  app        namespace  prod   .       true
+  app        replicas   3      app     true
+  app/redis  replicas   3      app     true
+
+  # print the effective values without setting them
+  $ kpt cfg cascade parent/ --dry-run
 `
 
 var CatShort = `Print the resources in a package`
@@ -79,11 +124,21 @@ var CatLong = `
   kpt cfg cat DIR
   
   DIR:
-    Path to a package directory
+    Path to a package directory.  '-' or omitting it reads resources from
+    stdin, e.g. the output of 'helm template' or 'kustomize build'.
 `
 var CatExamples = `
   # print Resource config from a directory
   kpt cfg cat my-dir/
+
+  # print the Deployments labeled app=web
+  kpt cfg cat my-dir/ --kind Deployment --label app=web
+
+  # print the resources in the prod namespace as json, for jq
+  kpt cfg cat my-dir/ --namespace prod -o json | jq '.items[].metadata.name'
+
+  # print the resources as a single List document, for kubectl
+  kpt cfg cat my-dir/ -o single-doc | kubectl apply --dry-run=client -f -
 `
 
 var CountShort = `Print resource counts for a package`
@@ -91,7 +146,7 @@ var CountLong = `
   kpt cfg count [DIR]
   
   DIR:
-    Path to a package directory.  Defaults to stdin if unspecified.
+    Path to a package directory.  Defaults to stdin if unspecified or '-'.
 `
 var CountExamples = `
   # print Resource counts from a directory
@@ -99,6 +154,12 @@ var CountExamples = `
 
   # print Resource counts from a cluster
   kubectl get all -o yaml | kpt cfg count
+
+  # print Resource counts by namespace and app label as csv
+  kpt cfg count my-dir/ --group-by namespace,label:app --output csv
+
+  # print Resource counts by apiVersion as json
+  kpt cfg count my-dir/ --group-by apiVersion --output json
 `
 
 var CreateSetterShort = `Create a setter for one or more field`
@@ -115,7 +176,7 @@ var CreateSetterLong = `
   
   VALUE
     The value of the filed for which setter reference must be added.
-    e.g. 3
+    e.g. 3.  Omitted for computed setters.
 `
 var CreateSetterExamples = `
   # create a setter called replicas for fields matching value "3"
@@ -127,10 +188,35 @@ var CreateSetterExamples = `
   # scope creating setter references to a specified field path
   kpt cfg create-setter DIR/ replicas 3 --field "spec.replicas"
 
+  # create a setter for the images of every container
+  kpt cfg create-setter DIR/ image nginx:1.19 --field "containers[*].image"
+
+  # create a setter for the host of the first rule of the ingresses
+  kpt cfg create-setter DIR/ host example.com --field "rules[0].host"
+
   # create a setter called replicas with a description and set-by
   kpt cfg create-setter DIR/ replicas 3 --set-by "package-default" \
       --description "good starter value"
 
+  # create a setter which only accepts integers from 1 to 10
+  echo '{"type": "integer", "minimum": 1, "maximum": 10}' > replicas.json
+  kpt cfg create-setter DIR/ replicas 3 --schema-path replicas.json
+
+  # create a setter which only accepts the listed regions
+  kpt cfg create-setter DIR/ region us-east1 --enum us-east1,europe-west1
+
+  # create a setter which only accepts DNS labels, e.g. for resource names
+  kpt cfg create-setter DIR/ name-prefix web --format dns-label
+
+  # create a secret setter for fields matching "changeme", replacing it with
+  # <redacted>, whose value is read from the DB_PASSWORD environment variable
+  kpt cfg create-setter DIR/ db-password changeme --type secret \
+      --secret-from env:DB_PASSWORD
+
+  # create a setter computed from the name and zone setters, for the fields
+  # matching their computed value, e.g. "web.example.com"
+  kpt cfg create-setter DIR/ dns-name --compute "{name}.{zone}"
+
   # scope create a setter with a type.  the setter will make sure the set fields
   # always parse as strings with a yaml 1.1 parser (e.g. values such as 1,on,true
   # will be quoted so they are parsed as strings)
@@ -229,12 +315,72 @@ var DeleteSubstExamples = `
   kpt cfg delete-subst DIR/ image-tag
 `
 
+var DiffShort = `Compare the fields of the resources of two packages`
+var DiffLong = `
+  kpt cfg diff DIR1 DIR2 [flags]
+
+Args:
+
+  DIR1
+    Path to the package directory to compare from.
+  
+  DIR2
+    Path to the package directory to compare to.
+
+Flags:
+
+  --output, -o
+    Output format -- table or json.  Defaults to table.  The json output is a
+    list of objects with the apiVersion, kind, namespace and name of the
+    resource, the type of the difference, and the field, from and to values
+    of field differences.
+`
+var DiffExamples = `
+  # compare the staging and prod variants of a package
+  $ kpt cfg diff staging/ prod/
+  TYPE     RESOURCE                     FIELD                                          FROM         TO
+  changed  apps/v1/Deployment prod/web  spec.replicas                                  2            5
+  changed  apps/v1/Deployment prod/web  spec.template.spec.containers[name=web].image  nginx:1.7.9  nginx:1.8.0
+  added    v1/Service prod/web
+
+  # print the differences as json
+  $ kpt cfg diff staging/ prod/ -o json
+`
+
+var DiffProfilesShort = `Compare the setter values of profiles`
+var DiffProfilesLong = `
+  kpt cfg diff-profiles DIR FROM [TO]
+
+Args:
+
+  DIR
+    Path to a package directory.
+  
+  FROM
+    The name of the profile to compare from.  The current values are compared
+    with it if TO isn't provided.
+  
+  TO
+    The name of the profile to compare to.
+`
+var DiffProfilesExamples = `
+  # compare the staging and prod profiles
+  $ kpt cfg diff-profiles hello-world/ staging prod
+  SETTER    STAGING  PROD
+  env       staging  prod
+  replicas  2        5
+
+  # compare the current values with the prod profile
+  $ kpt cfg diff-profiles hello-world/ prod
+`
+
 var FmtShort = `Format configuration files`
 var FmtLong = `
   kpt cfg fmt [DIR]
   
   DIR:
-    Path to a package directory.  Reads from STDIN if not provided.
+    Path to a package directory.  Reads from STDIN if not provided or '-',
+    and writes the formatted resources to STDOUT in the order they were read.
 `
 var FmtExamples = `
   # format file1.yaml and file2.yml
@@ -243,6 +389,9 @@ var FmtExamples = `
   # format all *.yaml and *.yml recursively traversing directories
   kpt cfg fmt my-dir/
 
+  # check the packages under my-dir/ are formatted, e.g. in CI
+  kpt cfg fmt my-dir/ -R --check
+
   # format kubectl output
   kubectl get -o yaml deployments | kpt cfg fmt
 
@@ -262,9 +411,11 @@ Args:
         List elements are matched as '[list-elem-field=field-value]'
         The value to match is expressed as '=value'
         '.' as part of a key or value can be escaped as '\.'
+        With --expr, a JSONPath query such as '[?(@.spec.replicas > 3)]'.
   
       DIR:
-        Path to a package directory
+        Path to a package directory.  Defaults to stdin if unspecified or
+        '-'.
 `
 var GrepExamples = `
   # find Deployment Resources
@@ -279,6 +430,53 @@ var GrepExamples = `
   # look for Resources matching a specific container image
   kpt cfg grep "spec.template.spec.containers[name=nginx].image=nginx:1\.7\.9" \
       my-dir/ | kpt cfg tree
+
+  # find Resources with more than 3 replicas
+  kpt cfg grep --expr '[?(@.spec.replicas > 3)]' my-dir/
+
+  # list the images of the resources with an nginx container as json
+  kpt cfg grep --expr \
+      '.spec.template.spec.containers[?(@.name == "nginx")].image' \
+      my-dir/ --output json
+`
+
+var LabelShort = `Set a label on one or more resources`
+var LabelLong = `
+  kpt cfg label [DIR] [KEY=VALUE...] [KEY-...] [flags]
+
+Args:
+
+  DIR:
+    Path to a package directory.  '-' or omitting it labels the resources
+    read from stdin, writing them to stdout.
+  
+  KEY=VALUE:
+    A label to set.
+  
+  KEY-:
+    A label to remove.
+`
+var LabelExamples = `
+  # set a label on all Resources: 'key: value'
+  kpt cfg label DIR --kv key=value
+
+  # set a label on all Service Resources
+  kpt cfg label DIR --kv key=value --kind Service
+
+  # set a label on the foo Service Resource only
+  kpt cfg label DIR --kv key=value --kind Service --name foo
+
+  # set multiple labels
+  kpt cfg label DIR --kv key1=value1 --kv key2=value2
+
+  # set labels on the Deployments in namespace foo
+  kpt cfg label DIR key1=value1 key2=value2 --kind Deployment --namespace foo
+
+  # print the labels which would be set on Resources labeled app=web
+  kpt cfg label DIR tier=frontend -l app=web --dry-run
+
+  # remove a label from all Resources
+  kpt cfg label DIR key-
 `
 
 var ListSettersShort = `List setters for a package`
@@ -297,11 +495,73 @@ var ListSettersExamples = `
   
     NAME     VALUE    SET BY    DESCRIPTION   COUNT  
   replicas   4       isabella   good value    1
+
+  # list the setters of the hello-world package, and its subpackages, as json
+  kpt cfg list-setters hello-world/ --output json -R
+`
+
+var RedactShort = `Copy a package with its secret and internal values removed`
+var RedactLong = `
+  kpt cfg redact DIR DEST [flags]
+
+Args:
+
+  DIR
+    Path to the package directory.
+  
+  DEST
+    Path to write the redacted copy to.  Must not exist.
+
+Flags:
+
+  --annotation:
+    Pattern matching the keys of annotations to remove, e.g.
+    'internal.example.com/*'.  May be repeated.
+  
+  --host:
+    Pattern matching the hostnames to mask, e.g. '*.corp.example.com'.
+    '*' matches any characters, including dots.  May be repeated.
+  
+  --private-ips:
+    Mask private, loopback and link-local IPv4 addresses.  Defaults to true.
+`
+var RedactExamples = `
+  # copy my-package with its secrets removed
+  $ kpt cfg redact my-package/ my-package-redacted/
+
+  # also remove internal annotations and mask internal hostnames
+  $ kpt cfg redact my-package/ my-package-redacted/ \
+      --annotation 'internal.example.com/*' --host '*.corp.example.com'
+`
+
+var RenameSetterShort = `Rename a setter`
+var RenameSetterLong = `
+  kpt cfg rename-setter DIR NAME NEW_NAME
+  
+  DIR:
+    Path to a package directory
+  
+  NAME:
+    The name of the setter to rename. e.g. replicas
+  
+  NEW_NAME:
+    The new name of the setter. e.g. frontend-replicas
+`
+var RenameSetterExamples = `
+  # rename the replicas setter to frontend-replicas
+  kpt cfg rename-setter DIR/ replicas frontend-replicas
+
+  # rename the replicas setter in the package and every subpackage defining it
+  kpt cfg rename-setter DIR/ replicas frontend-replicas -R
 `
 
 var SetShort = `Set one or more field values`
 var SetLong = `
   kpt cfg set DIR NAME VALUE
+  kpt cfg set DIR NAME VALUE... [--append|--remove]
+  kpt cfg set DIR --values-file FILE
+  kpt cfg set DIR --from-env-file FILE
+  kpt cfg set DIR --profile NAME
 
 Args:
 
@@ -313,12 +573,60 @@ Args:
   
   VALUE
     The new value to set on fields. e.g. 3
+    Array setters are set to every VALUE given.
 
 Flags:
 
+  --append
+    Append the values to the elements of an array setter, skipping those
+    which are already elements.
+  
+  --auto-run
+    Automatically run functions after setting (if enabled for the package).
+    Defaults to true.
+  
+  --cascade
+    Cascade the values to the subpackages of DIR which don't set them
+    locally.  Defaults to true.  See [cascade].
+  
   --description
     Optional description about the value.
   
+  --field-path
+    Set the fields at the path, creating the setter NAME and adding references
+    to it if needed.  e.g. spec.template.spec.containers[*].image
+  
+  --file
+    Only set the fields of the resources in the file, relative to DIR, and
+    record the value as an override of the setter.
+  
+  --from-env-file
+    Set the setters to the values in a file of NAME=VALUE lines.  May be
+    combined with --values-file, but a setter may only be in one of them.
+  
+  --kind
+    Only set the fields at --field-path of resources of the kind.
+  
+  --profile
+    Set the setters to the values of the profile NAME defined in the
+    Kptfile.  Values in --values-file or --from-env-file override them.
+  
+  --record-history
+    Record the changes of the setter values in the history of the packages.
+    See [set-history].
+  
+  --recurse-subpackages, -R
+    Set the value in every nested package which defines the setter, even
+    those which set it locally, and print the result for each package.
+  
+  --remove
+    Remove the values from the elements of an array setter.  At least one
+    element must remain.
+  
+  --resource
+    Only set the fields of the resource KIND/NAME, and record the value as
+    an override of the setter.  e.g. Deployment/api
+  
   --set-by
     Optional record of who set the value.  Clears the last set-by
     value if unset.
@@ -326,6 +634,10 @@ Flags:
   --values
     Optional flag, the values of the setter to be set to
     e.g. used to specify values that start with '-'
+  
+  --values-file
+    Set the setters to the values in a yaml file mapping setter names to
+    values.  Nothing is set if any setter is undefined or any value invalid.
 `
 var SetExamples = `
   # set replicas to 3 using the 'replicas' setter
@@ -337,11 +649,91 @@ var SetExamples = `
   # set the replicas to 5 and record who set this value
   kpt cfg set hello-world/ replicas 5 --set-by "mia"
 
+  # set the setters to the values in prod.yaml, all or nothing
+  kpt cfg set hello-world/ --values-file prod.yaml
+
+  # set the setters to the values in prod.env, all or nothing
+  kpt cfg set hello-world/ --from-env-file prod.env
+
+  # set the setters to the values of the prod profile in the Kptfile
+  kpt cfg set hello-world/ --profile prod
+
+  # set replicas to 5 in the package and every subpackage defining the setter
+  kpt cfg set hello-world/ replicas 5 --recurse-subpackages
+
+  # set replicas to 1 only in the resources of canary.yaml, and keep it when
+  # replicas is set again
+  kpt cfg set hello-world/ replicas 1 --file canary.yaml
+
+  # set replicas to 5 only in the api Deployment
+  kpt cfg set hello-world/ replicas 5 --resource Deployment/api
+
+  # set the cpu limits of every container of the deployments to 500m,
+  # creating the cpu-limit setter to set them again later
+  kpt cfg set hello-world/ cpu-limit 500m --kind Deployment \
+      --field-path 'spec.template.spec.containers[*].resources.limits.cpu'
+
+  # set the elements of the hosts array setter
+  kpt cfg set hello-world/ hosts a.example.com b.example.com
+
+  # append an element to the hosts array setter, and remove another
+  kpt cfg set hello-world/ hosts --append c.example.com
+  kpt cfg set hello-world/ hosts --remove a.example.com
+
   # set the tag portion of the image field to '1.8.1' using the 'tag' setter
   # the tag setter is referenced as a value by a substitution in the Kptfile
   kpt cfg set hello-world/ tag 1.8.1
 `
 
+var SetConditionShort = `Toggle a condition which includes or excludes resources of a package`
+var SetConditionLong = `
+  kpt cfg set-condition DIR NAME true|false
+
+Args:
+
+  DIR
+    Path to a package directory.
+  
+  NAME
+    The name of a condition declared in the Kptfile of the package.
+  
+  true|false
+    The value to set the condition to.
+`
+var SetConditionExamples = `
+  # enable the monitoring resources of the package
+  $ kpt cfg set-condition hello-world/ enable-monitoring true
+  set condition "enable-monitoring" to true
+`
+
+var SetHistoryShort = `Print the history of the setter values of a package`
+var SetHistoryLong = `
+  kpt cfg set-history DIR [NAME] [flags]
+
+Args:
+
+  DIR
+    Path to a package directory.
+  
+  NAME
+    Optional.  The name of a setter to print the history of.
+
+Flags:
+
+  --output, -o
+    Output format -- json or yaml.  Defaults to a table.
+`
+var SetHistoryExamples = `
+  # print the history of the setters
+  $ kpt cfg set hello-world/ replicas 5 --set-by alice --record-history
+  $ kpt cfg set-history hello-world/
+  TIMESTAMP             SETTER    SCOPE  OLD VALUE  NEW VALUE  SET BY
+  2020-06-01T10:00:00Z  replicas         3          5          alice
+
+  # print the history of the replicas setter as json
+  $ kpt cfg set-history hello-world/ replicas -o json
+`
+
 var TreeShort = `Render resources using a tree structure`
 var TreeLong = `
   kpt cfg tree [DIR] [flags]
@@ -349,7 +741,10 @@ var TreeLong = `
 Args:
 
   DIR:
-    Path to a package directory.  Defaults to STDIN if not specified.
+    Path to a package directory, or '-' to read resources from STDIN.
+    Defaults to the current directory.
+    Resources read from STDIN are shown under the helm template they were
+    rendered from, per their '# Source:' comment, if any.
 
 Flags:
 
@@ -371,6 +766,9 @@ Flags:
   --name:
     if true, print the container name fields
   
+  --output, -o:
+    output format -- json.  Defaults to a tree.
+  
   --ports:
     if true, print the container port fields
   
@@ -393,6 +791,10 @@ var TreeExamples = `
   # print the "foo"" annotation
   kpt cfg tree my-dir/ --field "metadata.annotations.foo"
 
+  # print the fields of a custom resource as json
+  kpt cfg tree my-dir/ --field spec.replicas --field metadata.labels.app \
+    --output json
+
   # print the status of resources with status.condition type of "Completed"
   kubectl get all -o yaml | kpt cfg tree \
     --field="status.conditions[type=Completed].status"
@@ -40,6 +40,51 @@ var FnExamples = `
   kpt fn run DIR/
 `
 
+var CacheServerShort = `Serve cached function results to other machines`
+var CacheServerLong = `
+  kpt fn cache-server DIR [flags]
+
+Args:
+
+  DIR:
+    The directory the results are cached in.  Created if it doesn't exist.
+
+Flags:
+
+  --address:
+    The address to serve the cache on.  Defaults to :8080.
+  
+  --max-bytes:
+    The size the cache is pruned to once it exceeds it, least recently used
+    results first.  Defaults to no limit.
+  
+  --max-entry-bytes:
+    The size limit of each cached result.  Defaults to 32MiB.
+  
+  --read-only:
+    Serve cached results, but don't accept new ones, e.g. for untrusted
+    workers.
+
+Environment Variables:
+
+  KPT_FN_CACHE:
+    The directory or cache server url to cache function results with.
+    Results aren't cached if unset.  Results of container functions are
+    keyed by the image digest, and aren't cached until the image is pulled.
+  
+  KPT_FN_CACHE_TOKEN:
+    The bearer token clients authenticate with.  If set for the server,
+    requests without it are rejected.
+`
+var CacheServerExamples = `
+  # serve the results cached under /var/cache/kpt, pruning them to 10GiB
+  kpt fn cache-server /var/cache/kpt --max-bytes 10737418240
+
+  # on each CI worker, cache function results with the server
+  export KPT_FN_CACHE=http://kpt-cache.example.com:8080
+  kpt pkg sync .
+`
+
 var ExportShort = `Auto-generating function pipelines for different workflow orchestrators`
 var ExportLong = `
   kpt fn export DIR/ [--fn-path FUNCTIONS_DIR/] --workflow ORCHESTRATOR [--output OUTPUT_FILENAME]
@@ -70,6 +115,64 @@ var ExportExamples = `
   kpt fn export DIR/ --fn-path FUNCTIONS_DIR/ --workflow cloud-build
 `
 
+var RenderShort = `Run the function pipeline declared by the package Kptfile`
+var RenderLong = `
+  kpt fn render [DIR] [flags]
+
+Args:
+
+  DIR:
+    Directory of the package to render.  Defaults to the current directory.
+
+Flags:
+
+  --dry-run:
+    Write the rendered resources to stdout rather than to the package.  The
+    functions run are written to stderr.  The values of secret setters are
+    injected into the rendered resources.
+  
+  --require-pinned-upstreams:
+    Fail before rendering if the upstream or a dependency ref of any package
+    in the rendered tree is not a tag or commit, e.g. a branch or 'latest'.
+    Defaults to the value of KPT_REQUIRE_PINNED_UPSTREAMS.
+  
+  --set:
+    Setter value to inject into the rendered resources, as NAME=VALUE.
+    Requires --dry-run.  May be repeated.
+  
+  --stats:
+    Print the number of resources each mutator added, removed and modified,
+    and the total churn.  Written to stderr with --dry-run.
+  
+  --stats-output:
+    Write the mutator stats to this file as json.
+
+Env Vars:
+
+  KPT_REQUIRE_PINNED_UPSTREAMS:
+    If true, defaults --require-pinned-upstreams to true.
+`
+var RenderExamples = `
+  # render the package in the current directory
+  kpt fn render
+
+  # render the subpackage my-package-dir/db/, including the settings it
+  # inherits from my-package-dir/
+  kpt fn render my-package-dir/db/
+
+  # print the rendered resources of my-package-dir/ without changing it
+  kpt fn render my-package-dir/ --dry-run
+
+  # print the rendered resources with setter values injected
+  kpt fn render my-package-dir/ --dry-run --set replicas=5
+
+  # print the changes each mutator made, and save them as json
+  kpt fn render my-package-dir/ --stats --stats-output stats.json
+
+  # render only if every upstream and dependency is pinned to a tag or commit
+  kpt fn render my-package-dir/ --require-pinned-upstreams
+`
+
 var RunShort = `Locally execute one or more functions in containers`
 var RunLong = `
   kpt fn run [DIR] [flags]
@@ -78,7 +181,9 @@ If the container exits with non-zero status code, run will fail and print the
 container ` + "`" + `STDERR` + "`" + `.
 
   DIR:
-    Path to a package directory.  Defaults to stdin if unspecified.
+    Path to a package directory.  Defaults to stdin if unspecified or '-',
+    writing the resources to stdout in the order they were read, followed
+    by any resources the functions generate.
 `
 var RunExamples = `
   # read the Resources from DIR, provide them to a container my-fun as input,
@@ -98,6 +203,10 @@ var RunExamples = `
   # pipe a resource from stdin and execute a function against it.
   # print the results to stdout
   kpt fn source . | kpt fn run --image gcr.io/example.com/my-fn
+
+  # print the container invocation of each function discovered in DIR, for
+  # an external orchestrator to run, instead of running them
+  kpt fn run DIR/ --as-pipeline-stage
 `
 
 var SinkShort = `Specify a directory as an output sink package`
@@ -105,7 +214,10 @@ var SinkLong = `
   kpt fn sink [DIR]
   
   DIR:
-    Path to a package directory.  Defaults to stdout if unspecified.
+    Path to a package directory.  Defaults to stdout if unspecified or '-'.
+    Resources without a path annotation are written to the file of the helm
+    template they were rendered from, per their '# Source:' comment, or else
+    to a file named for their kind and name, e.g. deployment_app.yaml.
 `
 var SinkExamples = `
   # run a function using explicit sources and sinks
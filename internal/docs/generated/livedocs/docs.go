@@ -57,10 +57,45 @@ Flags:
     The propagation policy kpt live apply should use when pruning resources. The
     default value here is Background. The other options are Foreground and Orphan.
   
+  --continue-on-error:
+    Continue applying the resources which don't depend on a resource which
+    failed to apply, and print a summary of the failures at the end. Resources
+    are applied in dependency order, and pruning is skipped if any resource
+    failed. Defaults to false.
+  
+  --resume:
+    Resume an interrupted apply, skipping the resources which were already
+    applied, or reconciled if --reconcile-timeout is set, and haven't changed
+    since. Resumed applies don't prune. Defaults to false.
+  
+  --audit-events:
+    Record the apply run as an Event on the inventory object, with who applied
+    the package, the commit and a summary of the result. Defaults to false.
+  
+  --verify-rendered:
+    Refuse to apply the package unless its files match a fresh render of it,
+    and it has no uncommitted changes if it is in a git repo. Defaults to
+    false.
+  
+  --cluster-var:
+    Cluster variable to inject into the fields designated by the
+    config.kpt.dev/cluster-vars annotation, as NAME=VALUE. Overrides the
+    value resolved from the cluster. May be repeated.
+  
+  --set:
+    Setter value to inject into the resources applied, as NAME=VALUE, without
+    changing the package. May be repeated.
+  
   --output:
     This determines the output format of the command. The default value is
     events, which will print the events as they happen. The other option is
     table, which will show the output in a table format.
+  
+  --show-secrets:
+    Don't redact Secret data, fields listed by the
+    config.kpt.dev/sensitive-fields annotation, and the values of secret
+    setters from the output and errors.
+    By default these values are replaced with <redacted>.
 `
 var ApplyExamples = `
   # apply resources and prune
@@ -69,6 +104,25 @@ var ApplyExamples = `
   # apply resources and wait for all the resources to be reconciled before pruning
   kpt live apply --reconcile-timeout=15m my-dir/
 
+  # apply resources, continuing past resources which fail to apply
+  kpt live apply --continue-on-error my-dir/
+
+  # resume an interrupted apply, skipping the resources it already applied
+  kpt live apply --resume my-dir/
+
+  # apply resources and record the run as an Event on the inventory object
+  kpt live apply --audit-events my-dir/
+
+  # apply resources only if the committed package matches its render
+  kpt live apply --verify-rendered my-dir/
+
+  # apply resources, filling in the cluster variables, with the environment
+  # variable set explicitly
+  kpt live apply --cluster-var env=prod my-dir/
+
+  # apply resources with setter values injected, without changing the package
+  kpt live apply --set replicas=5 --set image=nginx:1.19 my-dir/
+
   # apply resources and specify how often to poll the cluster for resource status
   kpt live apply --reconcile-timeout=15m --poll-period=5s my-dir/
 `
@@ -82,6 +136,14 @@ Args:
   DIR:
     Path to a package directory.  The directory must contain exactly
     one ConfigMap with the grouping object annotation.
+
+Flags:
+
+  --show-secrets:
+    Don't redact Secret data, fields listed by the
+    config.kpt.dev/sensitive-fields annotation, and the values of secret
+    setters from the output and errors.
+    By default these values are replaced with <redacted>.
 `
 var DestroyExamples = `
   # remove all resources in a package from the cluster
@@ -109,6 +171,14 @@ Exit Status:
   0 No differences were found. 1 Differences were found. >1 kpt live or diff failed with an error.
   
   Note: KUBECTL_EXTERNAL_DIFF, if used, is expected to follow that convention.
+
+Flags:
+
+  --show-secrets:
+    Don't redact Secret data, fields listed by the
+    config.kpt.dev/sensitive-fields annotation, and the values of secret
+    setters from the output and errors.
+    By default these values are replaced with <redacted>.
 `
 var DiffExamples = `
   # diff the config in "my-dir" against the live cluster resources
@@ -135,6 +205,43 @@ var FetchK8sSchemaExamples = `
   kpt live fetch-k8s-schema --context=myContext --pretty-print
 `
 
+var GenRbacShort = `Generate the RBAC needed to apply a package`
+var GenRbacLong = `
+  kpt live gen-rbac DIR [flags]
+
+Args:
+
+  DIR:
+    Path to a package directory.
+
+Flags:
+
+  --name:
+    Name of the generated roles and bindings.  Defaults to kpt-deployer.
+  
+  --namespace:
+    Namespace of namespaced resources which don't specify one.
+    Defaults to default.
+  
+  --offline:
+    Don't query the cluster for resource names and scopes.
+  
+  --service-account:
+    Name of the service account which applies the package.
+    Defaults to kpt-deployer.
+  
+  --service-account-namespace:
+    Namespace of the service account.  Defaults to default.
+`
+var GenRbacExamples = `
+  # print the RBAC needed to apply my-dir/
+  kpt live gen-rbac my-dir/
+  
+  # generate RBAC for the ci/deployer service account without a cluster
+  kpt live gen-rbac my-dir/ --service-account=deployer \
+    --service-account-namespace=ci --offline > rbac.yaml
+`
+
 var InitShort = `Initialize a package with a object to track previously applied resources`
 var InitLong = `
 The init command initializes a package with a template resource which will
@@ -164,8 +271,23 @@ Args:
 
 Flags:
 
+  --cluster-var:
+    Cluster variable to inject into the fields designated by the
+    config.kpt.dev/cluster-vars annotation, as NAME=VALUE. Overrides the
+    value resolved from the cluster. May be repeated.
+  
+  --set:
+    Setter value to inject into the resources previewed, as NAME=VALUE,
+    without changing the package. May be repeated.
+  
   --destroy:
     If true, dry-run deletion of all resources.
+  
+  --show-secrets:
+    Don't redact Secret data, fields listed by the
+    config.kpt.dev/sensitive-fields annotation, and the values of secret
+    setters from the output and errors.
+    By default these values are replaced with <redacted>.
 `
 var PreviewExamples = `
   # preview apply for a package
@@ -45,16 +45,149 @@ var PkgExamples = `
   $ kpt pkg update helloworld@v0.5.0 --strategy=resource-merge
 `
 
+var AddShort = `Declare a dependency in the Kptfile and fetch it`
+var AddLong = `
+  kpt pkg add REPO_URI[.git]/PKG_PATH[@VERSION] [LOCAL_DEST_DIRECTORY] [flags]
+
+Args:
+
+  REPO_URI:
+    URI of a git repository containing 1 or more packages as subdirectories.
+    In most cases the .git suffix should be specified to delimit the REPO_URI
+    from the PKG_PATH, but this is not required for widely recognized repo
+    prefixes.
+    e.g. https://github.com/kubernetes/examples.git
+  
+  PKG_PATH:
+    Path to remote subdirectory containing Kubernetes Resource configuration
+    files or directories.  Defaults to the root directory.
+    Uses '/' as the path separator (regardless of OS).
+    e.g. staging/cockroachdb
+  
+  VERSION:
+    A git tag, branch, ref or commit for the remote version of the package to
+    fetch.  Defaults to the repository master branch.
+    e.g. @v1.0.0
+  
+  LOCAL_DEST_DIRECTORY:
+    The directory to fetch the dependency to, relative to the package.  It is
+    recorded as the name of the dependency.  Defaults to a new directory named
+    after the Base of REPO/PKG_PATH.
+
+Flags:
+
+  --auto-set:
+    Perform setters based off the environment when the dependency is fetched
+    or updated.
+  
+  --git-config:
+    Git config key=value to set for each git command run while fetching the
+    dependency.  May be repeated.  See 'kpt pkg get'.
+  
+  --recursive:
+    Also fetch the dependencies declared by the dependency.  Defaults to true.
+  
+  --require-pinned-upstreams:
+    Reject dependencies which are not pinned to tags or commits.
+  
+  --strategy:
+    Controls how changes to the local package are handled when the dependency
+    is updated.  Defaults to fast-forward.  See ` + "`" + `kpt pkg update` + "`" + ` for the
+    strategies.
+`
+var AddExamples = `
+  # add the helloworld package as a dependency under helloworld-set/
+  kpt pkg add https://github.com/GoogleContainerTools/kpt.git/package-examples/helloworld-set@v0.5.0
+
+  # add a dependency under a different directory, merging upstream changes when
+  # it is updated
+  kpt pkg add https://github.com/GoogleContainerTools/kpt.git/package-examples/helloworld-set@v0.5.0 \
+      hello-world --strategy resource-merge
+`
+
+var ConvertHelmShort = `Convert a Helm chart to a package`
+var ConvertHelmLong = `
+  kpt pkg convert-helm CHART --out DIR [flags]
+
+Args:
+
+  CHART:
+    The chart to convert -- a chart reference, path to a chart directory or
+    packaged chart, or url.
+
+Flags:
+
+  --helm-binary
+    helm binary used to render the chart.  Defaults to the value of
+    KPT_HELM_BINARY, or helm.
+  
+  --namespace, -n
+    namespace to render the chart with.
+  
+  --out, -o
+    directory to write the package to.  Must not exist.
+  
+  --release-name
+    release name to render the chart with.  Defaults to the package name.
+  
+  --repo
+    chart repository url to fetch the chart from.
+  
+  --values, -f
+    values files to render the chart with.  May be repeated.
+  
+  --version
+    version of the chart to fetch.  Defaults to the latest version.
+`
+var ConvertHelmExamples = `
+  # convert a local chart
+  kpt pkg convert-helm ./charts/wordpress --values values.yaml --out wordpress/
+
+  # convert a chart from a chart repository
+  kpt pkg convert-helm wordpress --repo https://charts.bitnami.com/bitnami \
+      --version 10.0.0 --namespace wordpress --out wordpress/
+`
+
+var ConvertKustomizeShort = `Convert kustomizations to packages`
+var ConvertKustomizeLong = `
+  kpt pkg convert-kustomize DIR --out OUT_DIR
+
+Args:
+
+  DIR:
+    Directory containing the kustomizations to convert.
+
+Flags:
+
+  --out, -o
+    directory to write the packages to.  Must not exist.
+`
+var ConvertKustomizeExamples = `
+  # convert the kustomizations under config/
+  kpt pkg convert-kustomize config/ --out packages/
+`
+
 var DescShort = `Display upstream package metadata`
 var DescLong = `
   kpt pkg desc DIR
   
   DIR:
     Path to a package directory
+
+Flags:
+
+  --output, -o
+    Output format -- table, json or markdown.  Defaults to table.
 `
 var DescExamples = `
   # display description for the local hello-world package
   kpt pkg desc hello-world/
+
+  # describe every package under packages/ as json, e.g. to build a catalog
+  kpt pkg desc packages/ -o json
+
+  # describe the package as markdown, e.g. for its README
+  kpt pkg desc hello-world/ -o markdown
 `
 
 var DiffShort = `Diff a local package against upstream`
@@ -85,6 +218,11 @@ Flags:
               package at target version
     3way: shows changes in local package and source package at target version
           relative to original version side by side
+    preview: shows the local changes, the upstream changes between original
+             and target version, and the changes updating the local package
+             to the target version would make, one after the other.  The
+             update is previewed with the resource-merge strategy on a copy
+             of the local package.
   
   --diff-tool:
     Commandline tool (diff by default) for showing the changes.
@@ -130,6 +268,11 @@ var DiffExamples = `
   # Show 3way changes between the local package, upstream package at original
   # version and upstream package at target version using meld
   kpt pkg diff @v4.0.0 --diff-type 3way --diff-tool meld --diff-tool-opts "-a"
+
+  # Preview updating to the target version: show the local changes, the
+  # upstream changes and the result of merging them, without changing the
+  # local package
+  kpt pkg diff @v4.0.0 --diff-type preview
 `
 
 var FixShort = `Fix a local package which is using deprecated features.`
@@ -144,12 +287,13 @@ var FixLong = `
   Flags:
     --dry-run
       if set, the fix command shall only print the fixes which will be made to the
-      package without actually fixing/modifying the resources.
+      package, followed by a unified diff of the files they change, without
+      actually fixing/modifying the resources.
   
 `
 var FixExamples = `
-  # print the fixes which will be made to the package without actually modifying
-  # resources
+  # print the fixes which will be made to the package, and their diff, without
+  # actually modifying resources
   kpt pkg fix . --dry-run
 
   # fix the package if it is using deprecated features
@@ -169,18 +313,37 @@ var GetLong = `
     the argument.
     e.g. https://github.com/kubernetes/examples.git
     Specify - to read Resources from stdin and write to a LOCAL_DEST_DIRECTORY
+    Specify a path starting with ./ or ../ to fetch a package from the git
+    repository enclosing the working directory, e.g. ./../../base-pkg.  The
+    path is recorded in the Kptfile relative to the fetched package.
+    The URL of a directory or file in the GitHub or GitLab web UI may also
+    be given, e.g. https://github.com/kubernetes/examples/tree/master/staging,
+    and is parsed into the repo, PKG_PATH and VERSION.  The package of a file
+    is its directory.  Branches and tags containing slashes are matched
+    against the refs of the repo.
   
   PKG_PATH:
     Path to remote subdirectory containing Kubernetes resource configuration
     files or directories. Defaults to the root directory.
     Uses '/' as the path separator (regardless of OS).
     e.g. staging/cockroachdb
+    May be a glob to fetch every matching directory as a separate package,
+    e.g. 'packages/*'.  Each package is written to a directory of the same
+    name under LOCAL_DEST_DIRECTORY, and get fails without writing any
+    packages if one of those directories already exists.
   
   VERSION:
     A git tag, branch, ref or commit for the remote version of the package
     to fetch.  Defaults to the repository master branch.
     e.g. @master
   
+    A tag prefixed by PKG_PATH, e.g. cockroachdb/v1 for @v1, is preferred
+    to the tags and branches of the repository.  Repos with a different tag
+    convention set --tag-template.  If VERSION names both a
+    tag and a branch, the tag is fetched unless KPT_REF_PRECEDENCE is
+    branch, and the ref which was chosen is printed with its commit.  Use
+    a full ref name, e.g. @refs/heads/v1, to avoid the ambiguity.
+  
   LOCAL_DEST_DIRECTORY:
     The local directory to write the package to.
     e.g. ./my-cockroachdb-copy
@@ -191,6 +354,114 @@ var GetLong = `
         specified one, defaulting the name to the Base of REPO/PKG_PATH
       * If the directory DOES exist and already contains a directory with
         the same name of the one that would be created: fail
+  
+    The directory may be a template referencing the package being fetched:
+    {{.Org}}, {{.Repo}}, {{.Ref}} and {{.Directory}}.
+    e.g. 'vendor/{{.Org}}/{{.Repo}}/{{.Ref}}'. The expanded directory must
+    not contain '..'.
+
+Flags:
+
+  --allowed-licenses:
+    Comma separated SPDX ids of the licenses packages may be fetched under,
+    e.g. Apache-2.0,MIT.  The license declared by the package Kptfile
+    (packageMetadata.license) is used, otherwise it is detected from a
+    LICENSE file in the package or its parent directories in the repo.
+    Packages without a recognized license are not allowed.  The license is
+    recorded in the Kptfile as upstream.license.  Defaults to any license.
+  
+  -f, --filename:
+    Path to a manifest declaring multiple packages to fetch.  REPO_URI and
+    LOCAL_DEST_DIRECTORY must not be specified.  Packages are only written
+    to their destinations if all of them are fetched successfully.
+  
+    packages:
+    - git:
+        repo: https://github.com/kubernetes/examples
+        directory: staging/cockroachdb
+        ref: master
+      # relative to the manifest directory, may be a template
+      destination: vendor/{{.Repo}}/cockroachdb
+      # optional -- replace the destination if it already exists
+      strategy: force-delete-replace
+  
+  --dependencies:
+    Fetch the dependencies declared in the Kptfile of each fetched package,
+    and their dependencies, as 'kpt pkg sync' would.  Fails if the
+    dependencies have a cycle, or require the same package at different
+    refs.  Defaults to true.
+  
+  --fetch-concurrency:
+    Number of packages to fetch at once with --filename.  Defaults to the
+    --concurrency limit.  Results are printed in manifest order.
+  
+  --git-config:
+    Git config key=value to set for each git command run while fetching,
+    without changing the user's git config.  May be repeated, e.g. to use
+    a CA bundle and an auth header for a private git server:
+  
+      --git-config http.sslCAInfo=/etc/ssl/corp-ca.pem
+      --git-config "http.extraHeader=Authorization: Bearer $TOKEN"
+  
+  --license-policy:
+    What to do with packages under licenses not in --allowed-licenses.  One
+    of:
+  
+      * fail: fail before writing any package.  The default.
+      * warn: write a warning to stderr and fetch the package.
+  
+  --progress:
+    Format of the progress written to stderr while fetching.  One of:
+  
+      * auto: tty if stderr is a terminal, otherwise none.  The default.
+      * none: don't write progress.
+      * plain: write a line as each phase starts.
+      * tty: continuously rewrite a single status line.
+      * json: write each update as a line of json.
+  
+  --require-pinned-upstreams:
+    Reject fetching refs which are not tags or commits, e.g. branches or
+    'latest'.  Defaults to the value of KPT_REQUIRE_PINNED_UPSTREAMS.
+  
+  --tag-template:
+    Template naming the tags which version the package, for repos which
+    version their packages independently.  Recorded in the Kptfile as
+    upstream.git.tagTemplate and used by update, outdated and sync.
+    Defaults to the value of KPT_TAG_TEMPLATE, otherwise PKG_PATH joined
+    with VERSION.  See Tag templates.
+  
+  --timeout:
+    Maximum time to spend fetching, e.g. 5m.  If exceeded, or if get is
+    interrupted, git is stopped, temporary clones are removed and no
+    packages are written.  Defaults to 0, no limit.
+
+Env Vars:
+
+  KPT_TMPDIR:
+    Controls where temporary clones of remote repositories are created.
+    Defaults to the os temp directory, e.g. /tmp.
+  
+  KPT_TMPDIR_MIN_FREE_MB:
+    Minimum free space, in MiB, required in KPT_TMPDIR before fetching.
+    Defaults to 100.  Set to 0 to disable the check.
+  
+  KPT_TMPDIR_MAX_SIZE:
+    Size kpt temp directories are pruned to, least recently modified first,
+    e.g. 2GiB.  Directories in use by running kpt commands are kept.
+    Defaults to no limit.  See kpt cache stats.
+  
+  KPT_CONCURRENCY:
+    Sets every concurrency limit.  Overrides the kpt config file.
+  
+  KPT_REQUIRE_PINNED_UPSTREAMS:
+    If true, defaults --require-pinned-upstreams to true.
+  
+  KPT_REF_PRECEDENCE:
+    Chooses between a tag and a branch with the same name, either tag or
+    branch.  Defaults to tag.
+  
+  KPT_TAG_TEMPLATE:
+    Tag template for packages which don't set one in their Kptfile.
 `
 var GetExamples = `
   # fetch package cockroachdb from github.com/kubernetes/examples/staging/cockroachdb
@@ -205,6 +476,29 @@ var GetExamples = `
   # fetch package examples from github.com/kubernetes/examples
   # creates directory ./examples fetched from the provided commit
   kpt pkg get https://github.com/kubernetes/examples.git/@[COMMIT_HASH] ./
+
+  # fetch package cockroachdb to a directory derived from the upstream
+  # creates directory ./vendor/kubernetes/examples/master/
+  kpt pkg get https://github.com/kubernetes/examples.git/staging/cockroachdb@master \
+    'vendor/{{.Org}}/{{.Repo}}/{{.Ref}}'
+
+  # fetch every package under packages/ from a catalog repo
+  # creates directories ./catalog/<package>/ each with its own Kptfile
+  kpt pkg get https://github.com/example/catalog.git/packages/*@v1 ./catalog
+
+  # fetch package cockroachdb from the URL of its directory on github.com
+  kpt pkg get https://github.com/kubernetes/examples/tree/master/staging/cockroachdb ./
+
+  # fetch the package ../../base-pkg from the enclosing git repository
+  # the Kptfile records the upstream relative to ./my-pkg
+  kpt pkg get ./../../base-pkg@master ./my-pkg
+
+  # fetch v1.2.0 of a package from a monorepo tagged e.g. v1.2.0-mysql
+  kpt pkg get https://github.com/example/monorepo.git/pkgs/mysql@v1.2.0 \
+    --tag-template 'v{{.Version}}-{{.PkgName}}'
+
+  # fetch all of the packages declared in packages.yaml
+  kpt pkg get -f packages.yaml
 `
 
 var InitShort = `Initialize an empty package`
@@ -221,12 +515,28 @@ Flags:
   --description
     short description of the package. (default "sample description")
   
+  --from-live-cluster
+    populate the package with the resources in the cluster.
+  
+  --namespaces
+    namespaces to read resources from with --from-live-cluster.  defaults to
+    the current namespace.
+  
   --name
     package name.  defaults to the directory base name.
   
+  --selector, -l
+    label selector resources must match with --from-live-cluster.  If set,
+    matching cluster-scoped resources are also read.
+  
   --tag
     list of tags for the package.
   
+  --template
+    template to generate the package from: one of app, crd-operator or
+    namespace, or a git repository containing a template package, as for
+    kpt pkg get.
+  
   --url
     link to page with information about the package.
 `
@@ -235,6 +545,166 @@ var InitExamples = `
   mkdir my-pkg
   kpt pkg init my-pkg --tag kpt.dev/app=cockroachdb \
       --description "my cockroachdb implementation"
+
+  # create a package from the resources in the wordpress namespace
+  mkdir wordpress
+  kpt pkg init wordpress --from-live-cluster --namespaces wordpress
+
+  # create a package from the resources labelled app=wordpress, including
+  # cluster-scoped resources
+  mkdir wordpress
+  kpt pkg init wordpress --from-live-cluster -l app=wordpress
+
+  # create a package for an app from the built-in app template
+  mkdir my-app
+  kpt pkg init my-app --template app
+
+  # create a package from a template package in a git repository
+  mkdir my-db
+  kpt pkg init my-db --template https://github.com/example/templates/mysql@v1
+`
+
+var OutdatedShort = `Report packages which are behind their upstream`
+var OutdatedLong = `
+  kpt pkg outdated [DIR] [flags]
+
+Args:
+
+  DIR:
+    Directory to scan for packages.  Defaults to the current directory.
+
+Flags:
+
+  --all:
+    Also report packages which are up to date.  Packages whose upstream could
+    not be queried are always reported.
+  
+  --output:
+    Format of the report.  One of:
+  
+      * table: a table of the packages.  The default.
+      * json: a json list of the packages, including every newer version.
+
+Env Vars:
+
+  GITHUB_TOKEN:
+    Token authenticating the GitHub API requests listing the versions of
+    packages hosted on GitHub, which raises the API rate limit.
+  
+  KPT_GITHUB_API:
+    The GitHub API used to list the versions of the packages hosted on its
+    host.  Defaults to https://api.github.com, which lists the packages
+    hosted on github.com.  Set to a GitHub Enterprise API, e.g.
+    https://ghe.example.com/api/v3, to list the packages hosted on
+    ghe.example.com instead.  Set to off to list them with git.  Responses
+    are cached in KPT_CACHE_DIR, and kpt waits up to a minute for the rate
+    limit to reset before using git.  Packages hosted elsewhere, e.g. on
+    GitLab, are always listed with git.
+`
+var OutdatedExamples = `
+  # report the packages under the current directory which are behind
+  kpt pkg outdated
+
+  # report every package under my-workspace as json, e.g. for automation
+  kpt pkg outdated my-workspace/ --all --output json
+`
+
+var PublishShort = `Publish a version of a package to git or an OCI registry`
+var PublishLong = `
+  kpt pkg publish LOCAL_PKG_DIR VERSION [flags]
+
+Args:
+
+  LOCAL_PKG_DIR:
+    Local package to publish.  Must be in a git repository.
+  
+  VERSION:
+    Semantic version to publish, e.g. 1.2.0 or v1.2.0-rc.1.
+
+Flags:
+
+  --message:
+    Message to describe the version with in the tag.  Defaults to the version.
+  
+  --no-push:
+    Create the tag without pushing it to the remote.
+  
+  --oci:
+    OCI repository to also push the package to, e.g. gcr.io/project/pkg.
+    The version is used as the tag unless the reference has one.
+  
+  --remote:
+    Git remote to push the tag to.  Defaults to origin.
+  
+  --tag-template:
+    Template naming the tag of the version, e.g. v{{.Version}}-{{.PkgName}}.
+    Defaults to the value of KPT_TAG_TEMPLATE, otherwise the directory of
+    the package joined with VERSION.  See kpt pkg get.
+`
+var PublishExamples = `
+  # tag and push version 1.2.0 of the package in the cockroachdb directory
+  kpt pkg publish cockroachdb/ 1.2.0
+
+  # tag the version without pushing the tag
+  kpt pkg publish cockroachdb/ 1.2.0 --no-push
+
+  # also push the package to gcr.io/my-project/cockroachdb:1.2.0
+  kpt pkg publish cockroachdb/ 1.2.0 --oci gcr.io/my-project/cockroachdb
+`
+
+var ResourcesShort = `Report the cluster resources requested by a package`
+var ResourcesLong = `
+  kpt pkg resources DIR [flags]
+
+Args:
+
+  DIR:
+    Path to a package directory.
+
+Flags:
+
+  --output:
+    Format of the report.  One of:
+  
+      * table: a table of the namespaces and their total.  The default.
+      * json: a json object with the namespaces and their total.
+`
+var ResourcesExamples = `
+  # report the resources requested by the package in my-package-dir/
+  kpt pkg resources my-package-dir/
+
+  # report the resources requested by the package as json, e.g. to gate a rollout
+  kpt pkg resources my-package-dir/ --output json
+`
+
+var RevertShort = `Undo the most recent update of a package`
+var RevertLong = `
+  kpt pkg revert LOCAL_PKG_DIR [flags]
+
+Args:
+
+  LOCAL_PKG_DIR:
+    Local package to revert the most recent update of.
+
+Flags:
+
+  --force:
+    Revert the package even if it was changed after the update, discarding
+    the changes.
+
+Env Vars:
+
+  KPT_REVERT_DIR:
+    Controls where the snapshots of packages taken before they are updated
+    are stored.  Defaults to ~/.kpt/revert/
+`
+var RevertExamples = `
+  # undo the update of my-package-dir/
+  kpt pkg update my-package-dir/@v1.3 --strategy resource-merge
+  kpt pkg revert my-package-dir/
+
+  # undo the update, discarding the changes made since
+  kpt pkg revert my-package-dir/ --force
 `
 
 var SyncShort = `Fetch and update packages declaratively`
@@ -245,11 +715,59 @@ var SyncLong = `
     Local package with dependencies to sync.  Directory must exist and
     contain a Kptfile.
 
+Flags:
+
+  --allowed-licenses:
+    Comma separated SPDX ids of the licenses dependencies may be fetched
+    under.  See 'kpt pkg get'.  Defaults to any license.
+  
+  --dry-run:
+    Print sync actions without performing them.
+  
+  --fetch-concurrency:
+    Number of dependencies to fetch or update at once.  Defaults to the
+    --concurrency limit.  The output of each dependency is printed in order.
+  
+  --fn-concurrency:
+    Number of dependencies to run setters and functions for at once.
+    Defaults to the --concurrency limit.
+  
+  --git-config:
+    Git config key=value to set for each git command run while fetching or
+    updating the dependencies.  May be repeated.  See 'kpt pkg get'.
+  
+  --license-policy:
+    What to do with dependencies under licenses not in --allowed-licenses.
+    One of fail or warn.  Defaults to fail.
+  
+  --recursive:
+    Also sync the dependencies declared in the Kptfile of each dependency.
+    Fails if the dependencies have a cycle, or require the same package at
+    different refs.  Defaults to true.
+  
+  --require-pinned-upstreams:
+    Fail before syncing if any dependency ref is not a tag or commit,
+    e.g. a branch or 'latest'.  Defaults to the value of
+    KPT_REQUIRE_PINNED_UPSTREAMS.
+  
+  --verbose:
+    Print verbose logging information.
+
 Env Vars:
 
   KPT_CACHE_DIR:
     Controls where to cache remote packages during updates.
     Defaults to ~/.kpt/repos/
+  
+  KPT_CACHE_MAX_SIZE:
+    Size the cache is pruned to, least recently used repos first, e.g. 10GiB.
+    Defaults to 5GiB.  Set to 0 for no limit.  See kpt cache stats.
+  
+  KPT_CONCURRENCY:
+    Sets every concurrency limit.  Overrides the kpt config file.
+  
+  KPT_REQUIRE_PINNED_UPSTREAMS:
+    If true, defaults --require-pinned-upstreams to true.
 `
 var SyncExamples = `
   # print the dependencies that would be modified
@@ -333,6 +851,40 @@ Update an existing package dependency:
       hello-world --strategy=resource-merge
 `
 
+var TreeShort = `Display the package hierarchy with its upstream lineage and drift`
+var TreeLong = `
+  kpt pkg tree [DIR] [flags]
+
+Args:
+
+  DIR:
+    Directory to scan for packages.  Defaults to the current directory.
+
+Flags:
+
+  --drift:
+    Compare each package to the upstream commit it was fetched at, which
+    fetches the commit.  Defaults to true.
+  
+  --output:
+    Format of the report.  One of:
+  
+      * tree: the package hierarchy.  The default.
+      * table: a table of the packages, with a row for each package.
+      * json: the package hierarchy as json, including the modified files and
+        every newer version.
+`
+var TreeExamples = `
+  # display the packages under the current directory
+  kpt pkg tree
+
+  # display the packages without comparing them to their upstream commits
+  kpt pkg tree my-workspace/ --drift=false
+
+  # write the tree as json, e.g. for a dashboard
+  kpt pkg tree my-workspace/ --output json
+`
+
 var UpdateShort = `Apply upstream package updates`
 var UpdateLong = `
   kpt pkg update LOCAL_PKG_DIR[@VERSION] [flags]
@@ -342,6 +894,13 @@ Args:
   LOCAL_PKG_DIR:
     Local package to update.  Directory must exist and contain a Kptfile
     to be updated.
+    If the Kptfile upstream repo is a relative path, e.g. ./../../base-pkg,
+    the package is updated from the git repository enclosing the package.
+  
+    Subpackages whose Kptfile has its own upstream are updated against
+    that upstream, to the version their Kptfile records, rather than
+    merged as part of the package -- except by alpha-git-patch, which
+    patches the package as a whole.
   
   VERSION:
     A git tag, branch, ref or commit.  Specified after the local_package
@@ -360,6 +919,17 @@ Flags:
   
       * resource-merge: perform a structural comparison of the original /
         updated Resources, and merge the changes into the local package.
+        Files renamed upstream are detected by their resources, or for other
+        files their lines, and local changes are merged into the renamed file.
+      * resource-merge-3: perform the same merge as resource-merge, but
+        apply the changes to the local Resources in place -- preserving their
+        comments, field order and formatting, and leaving files without
+        upstream changes untouched.  List elements are matched by the merge
+        keys in the Kubernetes OpenAPI schema, or the schemas of the CRDs in
+        the package -- e.g. containers by name and ports by containerPort.
+        With --k8s-schema-source cluster the schemas of the CRDs installed
+        in the cluster are used too, as are the schemas bundled in the
+        schemas/ directories of the local and updated packages.
       * fast-forward: fail without updating if the local package was modified
         since it was fetched.
       * alpha-git-patch: use 'git format-patch' and 'git am' to apply a
@@ -369,12 +939,139 @@ Flags:
         DELETE the local package at local_pkg_dir/ and replace it
         with the remote version.
   
+  --on-conflict:
+    Controls how the resource-merge and resource-merge-3 strategies resolve
+    resources and fields which were changed both upstream and locally.
+    Defaults to upstream.
+  
+      * upstream: take the upstream changes.
+      * local: keep the local changes, and merge the other upstream changes.
+      * skip: don't update the files with conflicts.
+      * abort: fail without updating the package, listing the conflicts.
+      * prompt: list the conflicts in each file and prompt to keep the local
+        changes, accept the upstream changes, edit the merged file or skip the
+        file.  Files are edited with KPT_EDITOR or EDITOR, defaulting to vi.
+      * markers: merge the other upstream changes and write the local and
+        upstream values of the conflicts between git-style markers --
+        '<<<<<<< local', '=======' and '>>>>>>> upstream' -- then fail,
+        listing the files to resolve.  Setters and the package metadata
+        aren't applied to a package with conflict markers.
+  
+    Fields marked with a '# kpt-merge: keep-local' comment, and resources
+    annotated with 'config.kpt.dev/merge: keep-local', always keep their
+    local values and aren't reported as conflicts.  The protected fields
+    changed upstream are printed after each update.
+  
   -r, --repo:
     Git repo url for updating contents.  Defaults to the repo the package
-    was fetched from.
+    was fetched from.  A different repo rebases the package onto the new
+    location of a relocated upstream: it must contain the commit the package
+    was fetched at, which is the base of the merge.  The new repo is recorded
+    in the Kptfile, and the former one in its upstreamAliases.
+  
+  --dependencies:
+    Fetch, update and delete the dependencies declared in the updated Kptfile,
+    and their dependencies, as 'kpt pkg sync' would.  Fails if the
+    dependencies have a cycle, or require the same package at different
+    refs.  Defaults to true.
   
   --dry-run
-    Print the 'alpha-git-patch' strategy patch rather than merging it.
+    Print the changes the update would make -- added, removed and modified
+    files -- without changing the local package.  The 'alpha-git-patch'
+    strategy prints its patch rather than applying it.
+  
+  --git-config:
+    Git config key=value to set for each git command run while fetching the
+    upstream package, and its dependencies.  May be repeated.  See
+    'kpt pkg get'.
+  
+  --output:
+    Format to print the --dry-run changes in.  Defaults to diff.
+  
+      * diff: a unified diff of each changed file.
+      * summary: the path of each added, removed and modified file.
+      * json: a list of the changed files with their change type and diff.
+  
+  --prefer-local:
+    Fields to keep the local value of when they conflict, ahead of
+    --on-conflict.  See Conflict Rules.
+  
+  --prefer-upstream:
+    Fields to take the upstream value of when they conflict, ahead of
+    --on-conflict.  See Conflict Rules.
+  
+  --require-pinned-upstreams:
+    Reject updating to refs which are not tags or commits, e.g. branches or
+    'latest'.  Defaults to the value of KPT_REQUIRE_PINNED_UPSTREAMS.
+  
+  --skip-hooks:
+    Don't run the post-update hooks declared in the updated Kptfile.
+  
+  --to-latest:
+    Update to the newest semantic version the upstream is tagged with, e.g.
+    v1.3.0, and record the tag in the Kptfile.  Packages in subdirectories
+    are versioned by the tags prefixed with the directory, e.g.
+    my-package/v1.3.0, or named by the upstream.git.tagTemplate of the
+    Kptfile, if there are any.  Pre-releases are skipped unless
+    the constraint includes one.  May not be used with VERSION.
+  
+  --constraint:
+    Only update to versions satisfying this constraint with --to-latest.
+    Comparators separated by commas or spaces must all match, and
+    alternatives are separated by ||.
+  
+      * >=1.2, <2: any version from 1.2.0 up to, but excluding, 2.0.0.
+      * ~1.2.3: 1.2 releases from 1.2.3.  1.2.x and 1.2 match any 1.2 release.
+      * ^1.2.3: 1.x releases from 1.2.3.  1.x and 1 match any 1.x release.
+
+Conflict Rules:
+
+The local Kptfile may declare rules which resolve the conflicts of the
+resource-merge strategies by field, so that predictable customizations don't
+need resolving on every update.  Each conflicting field is resolved by the
+first rule matching it, and the remaining conflicts by --on-conflict:
+
+  conflictRules:
+  - prefer: local
+    fields:
+    - spec.replicas
+    - spec.template.spec.containers[*].image
+  - prefer: upstream
+    kinds: [Deployment, StatefulSet]
+    fields:
+    - spec.template.metadata.labels
+
+A rule matches the fields it lists and the fields under them.  Fields are
+written as in the conflicts update reports: * matches any field or list
+element, or part of one, e.g. [name=*], and ** any number of them.  Rules may
+be restricted to resources of some kinds.  The --prefer-local and
+--prefer-upstream flags are applied before the rules in the Kptfile, and the
+fields resolved by a rule are printed after each update.
+
+Hooks:
+
+The Kptfile may declare hooks which are run against the package, in order,
+after it is successfully updated -- once the setters are performed and the
+common metadata applied -- so that consumers don't forget to re-hydrate it.
+Each hook runs either a container image or a starlark program, relative to
+the package, with an optional functionConfig:
+
+  hooks:
+    postUpdate:
+    - name: regenerate-configmaps
+      starlark: functions/configmaps.star
+    - image: gcr.io/kpt-functions/kubeval
+      config:
+        apiVersion: v1
+        kind: ConfigMap
+        data:
+          strict: "true"
+
+The hooks are read from the updated Kptfile, so hooks added upstream run as
+part of the update that adds them.  If a hook fails the update is left
+applied, and may be undone with 'kpt pkg revert'.  --dry-run includes the
+changes made by the hooks.  Hooks aren't run when the update leaves
+conflict markers.
 
 Env Vars:
 
@@ -382,6 +1079,44 @@ Env Vars:
     Controls where to cache remote packages when fetching them to update
     local packages.
     Defaults to ~/.kpt/repos/
+  
+  KPT_CACHE_MAX_SIZE:
+    Size the cache is pruned to, least recently used repos first, e.g. 10GiB.
+    Defaults to 5GiB.  Set to 0 for no limit.  See kpt cache stats.
+  
+  KPT_TMPDIR:
+    Controls where temporary clones of remote repositories are created.
+    Defaults to the os temp directory, e.g. /tmp.
+  
+  KPT_TMPDIR_MIN_FREE_MB:
+    Minimum free space, in MiB, required in KPT_TMPDIR before fetching.
+    Defaults to 100.  Set to 0 to disable the check.
+  
+  KPT_TMPDIR_MAX_SIZE:
+    Size kpt temp directories are pruned to, least recently modified first,
+    e.g. 2GiB.  Directories in use by running kpt commands are kept.
+    Defaults to no limit.  See kpt cache stats.
+  
+  KPT_REQUIRE_PINNED_UPSTREAMS:
+    If true, defaults --require-pinned-upstreams to true.
+  
+  KPT_REF_PRECEDENCE:
+    Chooses between a tag and a branch with the same name, either tag or
+    branch.  Defaults to tag.
+  
+  GITHUB_TOKEN:
+    Token authenticating the GitHub API requests listing the versions of
+    packages hosted on GitHub, which raises the API rate limit.
+  
+  KPT_GITHUB_API:
+    The GitHub API used to list the versions of the packages hosted on its
+    host.  Defaults to https://api.github.com, which lists the packages
+    hosted on github.com.  Set to a GitHub Enterprise API, e.g.
+    https://ghe.example.com/api/v3, to list the packages hosted on
+    ghe.example.com instead.  Set to off to list them with git.  Responses
+    are cached in KPT_CACHE_DIR, and kpt waits up to a minute for the rate
+    limit to reset before using git.  Packages hosted elsewhere, e.g. on
+    GitLab, are always listed with git.
 `
 var UpdateExamples = `
   # update my-package-dir/
@@ -392,7 +1127,131 @@ var UpdateExamples = `
   git add . && git commit -m 'some message'
   kpt pkg update my-package-dir/@v1.3
 
+  # update my-package-dir/ to the newest 1.x release it is tagged with
+  git add . && git commit -m 'some message'
+  kpt pkg update my-package-dir/ --to-latest --constraint '^1'
+
   # update applying a git patch
   git add . && git commit -m "package updates"
   kpt pkg  update my-package-dir/@master --strategy alpha-git-patch
+
+  # update keeping the comments and field order of the local resources
+  git add . && git commit -m "package updates"
+  kpt pkg update my-package-dir/@v1.3 --strategy resource-merge-3
+
+  # update resolving conflicting local and upstream changes interactively
+  git add . && git commit -m "package updates"
+  kpt pkg update my-package-dir/@v1.3 --strategy resource-merge --on-conflict prompt
+
+  # update writing conflict markers into the files with conflicts, to
+  # resolve them in an editor
+  git add . && git commit -m "package updates"
+  kpt pkg update my-package-dir/@v1.3 --strategy resource-merge --on-conflict markers
+
+  # update keeping the locally tuned replicas, marked in the Deployment as
+  #   replicas: 5 # kpt-merge: keep-local
+  kpt pkg update my-package-dir/@v1.3 --strategy resource-merge
+
+  # update keeping the local replicas and images, and taking the upstream
+  # pod labels, wherever they conflict
+  kpt pkg update my-package-dir/@v1.3 --strategy resource-merge \
+    --prefer-local spec.replicas,**.containers[*].image \
+    --prefer-upstream spec.template.metadata.labels
+
+  # update from the new location of an upstream repo which moved
+  kpt pkg update my-package-dir/@v1.3 --repo https://github.com/new-org/catalog
+
+  # update without running the post-update hooks declared by the Kptfile
+  kpt pkg update my-package-dir/ --skip-hooks
+
+  # preview the files an update would change, without changing them
+  kpt pkg update my-package-dir/@v1.3 --strategy resource-merge --dry-run --output summary
+`
+
+var ValidateShort = `Check packages for structural and schema errors`
+var ValidateLong = `
+  kpt pkg validate [DIR] [flags]
+
+Args:
+
+  DIR:
+    Directory to validate the packages under.  Defaults to the current
+    directory.
+
+Flags:
+
+  --output:
+    Format of the findings.  One of:
+  
+      * table: a table of the findings.  The default.
+      * json: a json list of the findings, each with the file, line, severity,
+        check and message.
+`
+var ValidateExamples = `
+  # validate the packages under the current directory
+  kpt pkg validate
+
+  # validate the packages, checking custom resources against the schemas of
+  # the CRDs installed in the cluster
+  kpt pkg validate --k8s-schema-source cluster
+
+  # validate a package checking its custom resources against the schemas
+  # bundled in it
+  $ ls my-package-dir/schemas/
+  database.json
+  $ kpt pkg validate my-package-dir/
+
+  # validate my-package-dir/ and print the findings as json, e.g. in CI
+  kpt pkg validate my-package-dir/ --output json
+`
+
+var VendorShort = `Copy the upstreams of packages into a vendor tree`
+var VendorLong = `
+  kpt pkg vendor [DIR]
+
+Args:
+
+  DIR:
+    Directory containing the packages to vendor.  The vendor tree is written
+    to DIR/vendor.  Defaults to the current directory.
+`
+var VendorExamples = `
+  # vendor the upstreams of the packages under the current directory
+  kpt pkg vendor
+
+  # vendor the upstreams of the packages under my-workspace, then fetch a
+  # vendored package without network access
+  kpt pkg vendor my-workspace/
+  cd my-workspace/ && kpt pkg get https://github.com/example/catalog/app@v1.2.0 app
+`
+
+var VerifyShort = `Report local changes to a package since it was fetched or updated`
+var VerifyLong = `
+  kpt pkg verify [DIR] [flags]
+
+Args:
+
+  DIR:
+    Directory of the package to verify.  Defaults to the current directory.
+
+Flags:
+
+  --offline:
+    Only compare the package against its recorded digest, without fetching
+    the upstream commit.
+  
+  --output:
+    Format of the result.  One of:
+  
+      * table: the digest check followed by a table of the files which
+        differ from upstream.  The default.
+      * json: an object with the digest, the recorded digest, the upstream
+        commit and the files which differ, each with a path and status.
+`
+var VerifyExamples = `
+  # verify the package in the current directory
+  kpt pkg verify
+
+  # check my-package-dir/ against its recorded digest without fetching
+  kpt pkg verify my-package-dir/ --offline
 `
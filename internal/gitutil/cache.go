@@ -0,0 +1,279 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gitutil
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/GoogleContainerTools/kpt/internal/util/tmputil"
+	"sigs.k8s.io/kustomize/kyaml/errors"
+)
+
+// RepoCacheMaxSizeEnv is the name of the environment variable that controls
+// the size the repo cache is pruned to, least recently used repos first,
+// e.g. 10GiB.  Defaults to DefaultRepoCacheMaxSize.  Set to 0 for no limit.
+const RepoCacheMaxSizeEnv = "KPT_CACHE_MAX_SIZE"
+
+// DefaultRepoCacheMaxSize is the default size the repo cache is pruned to.
+const DefaultRepoCacheMaxSize = 5 << 30
+
+// RepoCacheMinEvictAge is the age under which cached repos are never pruned,
+// as other kpt processes may still be using them.
+var RepoCacheMinEvictAge = time.Hour
+
+const (
+	// statsFile records the use of each cached repo.
+	statsFile = "stats.json"
+
+	// gitHubCacheDir contains the cached GitHub API responses.
+	gitHubCacheDir = "github-api"
+)
+
+// RepoCacheEntry is a repo in the repo cache.
+type RepoCacheEntry struct {
+	// URI is the uri of the repo.
+	URI string `json:"uri"`
+
+	// Dir is the directory the repo is cached in.
+	Dir string `json:"-"`
+
+	// Size is the size of the cached repo in bytes.
+	Size int64 `json:"-"`
+
+	// LastUsed is when the cached repo was last fetched.
+	LastUsed time.Time `json:"lastUsed"`
+
+	// Hits is the number of fetches which reused the cached repo.
+	Hits int `json:"hits"`
+
+	// Misses is the number of fetches which had to clone the repo.
+	Misses int `json:"misses"`
+}
+
+// RepoCacheStats describes the repo cache.
+type RepoCacheStats struct {
+	// Dir is the cache directory.
+	Dir string
+
+	// MaxSize is the size the cache is pruned to, or 0 for no limit.
+	MaxSize int64
+
+	// Entries are the cached repos, most recently used first.
+	Entries []RepoCacheEntry
+
+	// GitHubAPISize is the size of the cached GitHub API responses.
+	GitHubAPISize int64
+}
+
+// Size returns the total size of the cached repos.
+func (s RepoCacheStats) Size() int64 {
+	var size int64
+	for _, e := range s.Entries {
+		size += e.Size
+	}
+	return size
+}
+
+// HitRate returns the fraction of fetches which reused a cached repo.
+func (s RepoCacheStats) HitRate() float64 {
+	var hits, total int
+	for _, e := range s.Entries {
+		hits += e.Hits
+		total += e.Hits + e.Misses
+	}
+	if total == 0 {
+		return 0
+	}
+	return float64(hits) / float64(total)
+}
+
+// GetRepoCacheStats returns the cached repos, and how often they were reused.
+func GetRepoCacheStats() (RepoCacheStats, error) {
+	g := &GitRunner{}
+	dir, err := g.getRepoCacheDir()
+	if err != nil {
+		return RepoCacheStats{}, err
+	}
+	max, err := repoCacheMaxSize()
+	if err != nil {
+		return RepoCacheStats{}, err
+	}
+	entries, err := repoCacheEntries(dir)
+	if err != nil {
+		return RepoCacheStats{}, err
+	}
+	for i := range entries {
+		if entries[i].URI == "" {
+			// cached before uses were recorded -- read the uri from the repo
+			r := &GitRunner{Dir: entries[i].Dir}
+			if err := r.Run("config", "--get", "remote.origin.url"); err == nil {
+				entries[i].URI = strings.TrimSpace(r.Stdout.String())
+			}
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].LastUsed.After(entries[j].LastUsed)
+	})
+	return RepoCacheStats{
+		Dir:           dir,
+		MaxSize:       max,
+		Entries:       entries,
+		GitHubAPISize: tmputil.DirSize(filepath.Join(dir, gitHubCacheDir)),
+	}, nil
+}
+
+// repoCacheMaxSize returns the size the repo cache is pruned to.
+func repoCacheMaxSize() (int64, error) {
+	v := os.Getenv(RepoCacheMaxSizeEnv)
+	if v == "" {
+		return DefaultRepoCacheMaxSize, nil
+	}
+	max, err := tmputil.ParseSize(v)
+	if err != nil {
+		return 0, errors.Errorf("invalid %s value: %v", RepoCacheMaxSizeEnv, err)
+	}
+	return max, nil
+}
+
+// repoCacheEntries returns the repos cached under dir.
+func repoCacheEntries(dir string) ([]RepoCacheEntry, error) {
+	infos, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	uses := readRepoUses(dir)
+	var entries []RepoCacheEntry
+	for _, info := range infos {
+		path := filepath.Join(dir, info.Name())
+		if !info.IsDir() {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(path, ".git")); err != nil {
+			continue
+		}
+		e, found := uses[info.Name()]
+		if !found {
+			e.LastUsed = info.ModTime()
+		}
+		e.Dir = path
+		e.Size = tmputil.DirSize(path)
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// statsLock serializes updating the stats file within the process.
+var statsLock sync.Mutex
+
+// readRepoUses reads the recorded use of each cached repo, keyed by the
+// name of its directory.
+func readRepoUses(dir string) map[string]RepoCacheEntry {
+	uses := map[string]RepoCacheEntry{}
+	b, err := ioutil.ReadFile(filepath.Join(dir, statsFile))
+	if err != nil {
+		return uses
+	}
+	_ = json.Unmarshal(b, &uses)
+	return uses
+}
+
+// recordRepoUse records fetching the repo at uri, cached under dir in
+// repoDir.  Recording is best effort, concurrent kpt processes may lose
+// each other's counts.
+func recordRepoUse(dir, repoDir, uri string, hit bool) {
+	statsLock.Lock()
+	defer statsLock.Unlock()
+	uses := readRepoUses(dir)
+	e := uses[repoDir]
+	e.URI, e.LastUsed = uri, time.Now()
+	if hit {
+		e.Hits++
+	} else {
+		e.Misses++
+	}
+	uses[repoDir] = e
+	// drop the uses of repos which were removed
+	for name := range uses {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			delete(uses, name)
+		}
+	}
+
+	b, err := json.MarshalIndent(uses, "", "  ")
+	if err != nil {
+		return
+	}
+	// write the file atomically, so concurrent readers never see part of it
+	f, err := ioutil.TempFile(dir, ".stats-")
+	if err != nil {
+		return
+	}
+	_, err = f.Write(b)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), filepath.Join(dir, statsFile))
+	}
+	if err != nil {
+		_ = os.Remove(f.Name())
+	}
+}
+
+// pruneRepoCache removes the least recently used repos cached under dir
+// until they fit the size configured by RepoCacheMaxSizeEnv.  keep, repos
+// used within RepoCacheMinEvictAge and repos locked by this process aren't
+// removed.
+func pruneRepoCache(dir, keep string) error {
+	max, err := repoCacheMaxSize()
+	if err != nil || max <= 0 {
+		return err
+	}
+	entries, err := repoCacheEntries(dir)
+	if err != nil {
+		return err
+	}
+	var size int64
+	for _, e := range entries {
+		size += e.Size
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].LastUsed.Before(entries[j].LastUsed)
+	})
+	for _, e := range entries {
+		if size <= max {
+			break
+		}
+		name := filepath.Base(e.Dir)
+		if name == keep || time.Since(e.LastUsed) < RepoCacheMinEvictAge || repoCacheLocked(name) {
+			continue
+		}
+		if err := os.RemoveAll(e.Dir); err != nil {
+			return errors.Wrap(err)
+		}
+		size -= e.Size
+	}
+	return nil
+}
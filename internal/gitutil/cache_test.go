@@ -0,0 +1,113 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gitutil_test
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/GoogleContainerTools/kpt/internal/gitutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestRepoCache_prune verifies that fetches are counted as hits or misses,
+// and that the least recently used repos are pruned once the cache exceeds
+// its max size.
+func TestRepoCache_prune(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kpt-cache-test")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir)
+	os.Setenv(RepoCacheDirEnv, filepath.Join(dir, "cache"))
+	defer os.Unsetenv(RepoCacheDirEnv)
+	defer func(age time.Duration) { RepoCacheMinEvictAge = age }(RepoCacheMinEvictAge)
+	RepoCacheMinEvictAge = 0
+
+	a := upstreamRepo(t, "kpt-a-")
+	defer os.RemoveAll(a)
+	b := upstreamRepo(t, "kpt-b-")
+	defer os.RemoveAll(b)
+
+	for _, uri := range []string{a, a} {
+		_, err := NewUpstreamGitRunner(uri, "/", nil, []string{"master"})
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+	}
+	stats, err := GetRepoCacheStats()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	if !assert.Len(t, stats.Entries, 1) {
+		t.FailNow()
+	}
+	assert.Equal(t, a, stats.Entries[0].URI)
+	assert.Equal(t, 1, stats.Entries[0].Hits)
+	assert.Equal(t, 1, stats.Entries[0].Misses)
+	assert.Equal(t, 0.5, stats.HitRate())
+	assert.True(t, stats.Size() > 0)
+
+	// fetching b exceeds the max size, so a is pruned
+	os.Setenv(RepoCacheMaxSizeEnv, "1B")
+	defer os.Unsetenv(RepoCacheMaxSizeEnv)
+	if _, err := NewUpstreamGitRunner(b, "/", nil, []string{"master"}); !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	stats, err = GetRepoCacheStats()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	if !assert.Len(t, stats.Entries, 1) {
+		t.FailNow()
+	}
+	assert.Equal(t, b, stats.Entries[0].URI)
+	assert.Equal(t, 0, stats.Entries[0].Hits)
+	assert.Equal(t, 1, stats.Entries[0].Misses)
+	assert.Equal(t, int64(1), stats.MaxSize)
+}
+
+// upstreamRepo creates a git repo with a commit on master in a temp
+// directory with the given prefix.  The prefixes must differ, as repos are
+// cached by a prefix of their uri.
+func upstreamRepo(t *testing.T, prefix string) string {
+	dir, err := ioutil.TempDir("", prefix)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	if !assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "README.md"),
+		[]byte(strings.Repeat("kpt\n", 1000)), 0600)) {
+		t.FailNow()
+	}
+	for _, args := range [][]string{
+		{"init"},
+		{"checkout", "-b", "master"},
+		{"add", "."},
+		{"-c", "user.name=kpt", "-c", "user.email=kpt@example.com", "commit", "-m", "init"},
+	} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		out, err := cmd.CombinedOutput()
+		if !assert.NoError(t, err, string(out)) {
+			t.FailNow()
+		}
+	}
+	return dir
+}
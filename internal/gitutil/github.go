@@ -0,0 +1,285 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gitutil
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"sigs.k8s.io/kustomize/kyaml/errors"
+)
+
+// GitHubTokenEnv is the name of the environment variable containing the
+// token used to authenticate with the GitHub API.  Authenticated requests
+// have a much higher rate limit.
+const GitHubTokenEnv = "GITHUB_TOKEN"
+
+// GitHubAPIEnv is the name of the environment variable which controls the
+// GitHub API used to list the tags of GitHub repos, e.g. for GitHub
+// Enterprise.  Defaults to https://api.github.com.  Set to "off" to list the
+// tags with git instead.  Only the repos hosted on the host of the API are
+// listed with it, see gitHubHost.
+const GitHubAPIEnv = "KPT_GITHUB_API"
+
+// defaultGitHubAPI is the GitHub API of github.com.
+const defaultGitHubAPI = "https://api.github.com"
+
+// gitHubMaxWait is the longest kpt waits for the GitHub API rate limit to
+// reset before listing tags with git instead.
+const gitHubMaxWait = time.Minute
+
+// gitHubClient makes the GitHub API requests.
+var gitHubClient = &http.Client{Timeout: 30 * time.Second}
+
+// gitHubAPI returns the GitHub API serving repo, and the owner and name of
+// repo.  Returns false if repo isn't hosted on the host of the API, or the
+// API is off.
+func gitHubAPI(repo string) (api, owner, name string, ok bool) {
+	api, ok = gitHubAPIBase()
+	if !ok {
+		return "", "", "", false
+	}
+	pattern, err := gitHubRepoPattern(api)
+	if err != nil {
+		return "", "", "", false
+	}
+	m := pattern.FindStringSubmatch(repo)
+	if m == nil {
+		return "", "", "", false
+	}
+	return api, m[1], m[2], true
+}
+
+// gitHubHost returns the host serving the repos of the GitHub API api:
+// github.com for https://api.github.com, and the host of the API otherwise,
+// e.g. ghe.example.com for the GitHub Enterprise API
+// https://ghe.example.com/api/v3.
+func gitHubHost(api string) (*url.URL, error) {
+	u, err := url.Parse(api)
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	if u.Host == "" {
+		return nil, errors.Errorf("%s %q has no host", GitHubAPIEnv, api)
+	}
+	if u.Host == "api.github.com" {
+		u.Host = "github.com"
+	}
+	return u, nil
+}
+
+// gitHubRepoPattern returns a pattern matching the repos hosted on the host
+// of the GitHub API api, capturing the owner and name.
+func gitHubRepoPattern(api string) (*regexp.Regexp, error) {
+	host, err := gitHubHost(api)
+	if err != nil {
+		return nil, err
+	}
+	// ssh urls use the hostname, but not the port, of the api
+	return regexp.Compile(fmt.Sprintf(
+		`^(?:(?:https?://)?%s/|(?:ssh://)?git@%s(?::[0-9]+)?[/:])([^/]+)/([^/]+?)(?:\.git)?/?$`,
+		regexp.QuoteMeta(host.Host), regexp.QuoteMeta(host.Hostname())))
+}
+
+// gitHubAPIBase returns the url of the GitHub API, and false if it is off.
+func gitHubAPIBase() (string, bool) {
+	api := os.Getenv(GitHubAPIEnv)
+	if api == "off" {
+		return "", false
+	}
+	if api == "" {
+		api = defaultGitHubAPI
+	}
+	return strings.TrimSuffix(api, "/"), true
+}
+
+// gitHubTags returns the refs of the tags of repo, e.g. refs/tags/v1.0, using
+// the GitHub API.  Returns false if repo isn't hosted on GitHub.  Responses
+// are cached, and only refetched if they changed, which doesn't count
+// towards the rate limit.
+func gitHubTags(repo string) ([]string, bool, error) {
+	api, owner, name, ok := gitHubAPI(repo)
+	if !ok {
+		return nil, false, nil
+	}
+	var refs []string
+	next := fmt.Sprintf("%s/repos/%s/%s/tags?per_page=100", api, owner, name)
+	for next != "" {
+		page, err := gitHubGet(next)
+		if err != nil {
+			return nil, true, err
+		}
+		var tags []struct {
+			Name string `json:"name"`
+		}
+		if err := json.Unmarshal(page.Body, &tags); err != nil {
+			return nil, true, errors.Errorf("unable to parse tags of %q: %v", repo, err)
+		}
+		for _, t := range tags {
+			refs = append(refs, "refs/tags/"+t.Name)
+		}
+		next = page.Next
+	}
+	return refs, true, nil
+}
+
+// GitHubAPIGet gets path, e.g. /repos/OWNER/NAME/releases/latest, from the
+// GitHub API.  Like listing tags, responses are cached and requests wait
+// for the rate limit to reset.
+func GitHubAPIGet(path string) ([]byte, error) {
+	api, ok := gitHubAPIBase()
+	if !ok {
+		return nil, errors.Errorf("the GitHub API is required, but %s is off", GitHubAPIEnv)
+	}
+	page, err := gitHubGet(api + path)
+	if err != nil {
+		return nil, err
+	}
+	return page.Body, nil
+}
+
+// gitHubPage is a cached GitHub API response.
+type gitHubPage struct {
+	ETag string          `json:"etag"`
+	Next string          `json:"next,omitempty"`
+	Body json.RawMessage `json:"body"`
+}
+
+// linkNextPattern matches the url of the next page in a Link header.
+var linkNextPattern = regexp.MustCompile(`<([^>]+)>;\s*rel="next"`)
+
+// gitHubGet gets url from the GitHub API, revalidating the cached response
+// if there is one, and waiting for the rate limit to reset if it was hit.
+func gitHubGet(url string) (gitHubPage, error) {
+	cached, cacheFile := readGitHubCache(url)
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequest(http.MethodGet, url, nil)
+		if err != nil {
+			return gitHubPage{}, errors.Wrap(err)
+		}
+		req.Header.Set("Accept", "application/vnd.github.v3+json")
+		if token := os.Getenv(GitHubTokenEnv); token != "" {
+			req.Header.Set("Authorization", "token "+token)
+		}
+		if cached.ETag != "" {
+			req.Header.Set("If-None-Match", cached.ETag)
+		}
+		resp, err := gitHubClient.Do(req)
+		if err != nil {
+			return gitHubPage{}, errors.Errorf("GitHub API request failed: %v", err)
+		}
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return gitHubPage{}, errors.Wrap(err)
+		}
+
+		switch {
+		case resp.StatusCode == http.StatusNotModified:
+			return cached, nil
+		case resp.StatusCode == http.StatusOK:
+			page := gitHubPage{ETag: resp.Header.Get("ETag"), Body: body}
+			if m := linkNextPattern.FindStringSubmatch(resp.Header.Get("Link")); m != nil {
+				page.Next = m[1]
+			}
+			writeGitHubCache(cacheFile, page)
+			return page, nil
+		}
+
+		wait, limited := rateLimitWait(resp)
+		if !limited {
+			return gitHubPage{}, errors.Errorf("GitHub API request %s failed: %s: %s",
+				url, resp.Status, strings.TrimSpace(string(body)))
+		}
+		if attempt >= 3 || wait > gitHubMaxWait {
+			return gitHubPage{}, errors.Errorf("GitHub API rate limit exceeded, "+
+				"resets in %v -- set %s to raise the limit", wait.Round(time.Second), GitHubTokenEnv)
+		}
+		fmt.Fprintf(os.Stderr, "GitHub API rate limit exceeded, retrying in %v\n",
+			wait.Round(time.Second))
+		time.Sleep(wait)
+	}
+}
+
+// rateLimitWait returns how long to wait before retrying a request which
+// hit the rate limit, and false if resp didn't hit the rate limit.
+func rateLimitWait(resp *http.Response) (time.Duration, bool) {
+	if resp.StatusCode != http.StatusForbidden && resp.StatusCode != http.StatusTooManyRequests {
+		return 0, false
+	}
+	// secondary rate limits specify how long to wait
+	if s, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+		return time.Duration(s) * time.Second, true
+	}
+	if resp.Header.Get("X-RateLimit-Remaining") != "0" {
+		return 0, false
+	}
+	reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	wait := time.Until(time.Unix(reset, 0))
+	if wait < time.Second {
+		wait = time.Second
+	}
+	return wait, true
+}
+
+// readGitHubCache returns the cached response to url, and the file it is
+// cached in.  Returns an empty response if it isn't cached.
+func readGitHubCache(url string) (gitHubPage, string) {
+	dir, err := (&GitRunner{}).getRepoCacheDir()
+	if err != nil {
+		return gitHubPage{}, ""
+	}
+	sum := sha256.Sum256([]byte(url + "\n" + os.Getenv(GitHubTokenEnv)))
+	file := filepath.Join(dir, "github-api", hex.EncodeToString(sum[:])+".json")
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return gitHubPage{}, file
+	}
+	var page gitHubPage
+	if err := json.Unmarshal(b, &page); err != nil {
+		return gitHubPage{}, file
+	}
+	return page, file
+}
+
+// writeGitHubCache caches page in file.  The cache is best effort, failing
+// to write it only means the response is fetched again.
+func writeGitHubCache(file string, page gitHubPage) {
+	if file == "" || page.ETag == "" {
+		return
+	}
+	b, err := json.Marshal(page)
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		return
+	}
+	_ = ioutil.WriteFile(file, b, 0600)
+}
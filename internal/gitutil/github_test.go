@@ -0,0 +1,131 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gitutil_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	. "github.com/GoogleContainerTools/kpt/internal/gitutil"
+	"github.com/GoogleContainerTools/kpt/internal/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestListVersions_gitHubAPI verifies that the tags of the repos hosted on
+// the host of the GitHub API are listed with the API, following pages,
+// revalidating cached pages and retrying once the rate limit resets.
+func TestListVersions_gitHubAPI(t *testing.T) {
+	cacheDir, err := ioutil.TempDir("", "kpt-github-test")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer os.RemoveAll(cacheDir)
+
+	var requests []string
+	limited := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.RequestURI())
+		assert.Equal(t, "token secret", r.Header.Get("Authorization"))
+		if !limited {
+			limited = true
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		etag := `"` + r.URL.Query().Get("page") + `"`
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		switch r.URL.Query().Get("page") {
+		case "":
+			w.Header().Set("Link", fmt.Sprintf(
+				`<%s/repos/org/repo/tags?per_page=100&page=2>; rel="next"`, "http://"+r.Host))
+			fmt.Fprint(w, `[{"name": "v1.0.0"}, {"name": "latest"}]`)
+		case "2":
+			fmt.Fprint(w, `[{"name": "v1.1.0"}, {"name": "java/v0.1.0"}]`)
+		}
+	}))
+	defer server.Close()
+
+	for k, v := range map[string]string{
+		GitHubAPIEnv: server.URL, GitHubTokenEnv: "secret", RepoCacheDirEnv: cacheDir} {
+		os.Setenv(k, v)
+		defer os.Unsetenv(k)
+	}
+
+	host := strings.TrimPrefix(server.URL, "http://")
+	hostname := strings.Split(host, ":")[0]
+	for _, repo := range []string{"http://" + host + "/org/repo", "git@" + hostname + ":org/repo.git"} {
+		versions, err := ListVersions(repo, "/", "", nil)
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		var tags []string
+		for _, v := range versions {
+			tags = append(tags, v.Original)
+		}
+		assert.Equal(t, []string{"v1.1.0", "v1.0.0"}, tags)
+	}
+	assert.Equal(t, []string{
+		// rate limited
+		"/repos/org/repo/tags?per_page=100",
+		"/repos/org/repo/tags?per_page=100",
+		"/repos/org/repo/tags?per_page=100&page=2",
+		// revalidated
+		"/repos/org/repo/tags?per_page=100",
+		"/repos/org/repo/tags?per_page=100&page=2",
+	}, requests)
+
+	latest, err := LatestVersion("http://"+host+"/org/repo", "java", "", "", nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, "v0.1.0", latest)
+}
+
+// TestListVersions_gitHubAPIHost verifies that the repos hosted on github.com
+// aren't listed with a GitHub Enterprise API.
+func TestListVersions_gitHubAPIHost(t *testing.T) {
+	g, _, clean := testutil.SetupDefaultRepoAndWorkspace(t, testutil.Dataset1)
+	defer clean()
+	testutil.Tag(t, g, "v1.0.0")
+
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.RequestURI())
+		fmt.Fprint(w, `[{"name": "v2.0.0"}]`)
+	}))
+	defer server.Close()
+	os.Setenv(GitHubAPIEnv, server.URL+"/api/v3")
+	defer os.Unsetenv(GitHubAPIEnv)
+
+	// github.com is an alias of the local repo
+	config := map[string]string{"url." + g.RepoDirectory + ".insteadOf": "https://github.com/org/repo"}
+	versions, err := ListVersions("https://github.com/org/repo", "/", "", config)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	if assert.Len(t, versions, 1) {
+		assert.Equal(t, "v1.0.0", versions[0].Original)
+	}
+	assert.Empty(t, requests)
+}
@@ -23,7 +23,9 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
 
 	"sigs.k8s.io/kustomize/kyaml/errors"
 )
@@ -36,13 +38,27 @@ const RepoCacheDirEnv = "KPT_CACHE_DIR"
 // remote repository, falls back to "main" if master branch doesn't exist
 // Making it a var so that it can be overridden for local testing
 var DefaultRef = func(repo string) (string, error) {
+	return defaultRef(repo, nil)
+}
+
+// DefaultRefWithConfig is DefaultRef, passing config to git as -c flags.
+// Without config it uses DefaultRef.
+func DefaultRefWithConfig(repo string, config map[string]string) (string, error) {
+	if len(config) == 0 {
+		return DefaultRef(repo)
+	}
+	return defaultRef(repo, config)
+}
+
+// defaultRef returns the default ref of repo, passing config to git.
+func defaultRef(repo string, config map[string]string) (string, error) {
 	masterRef := "master"
 	mainRef := "main"
-	masterExists, err := branchExists(repo, masterRef)
+	masterExists, err := branchExists(repo, masterRef, config)
 	if err != nil {
 		return "", err
 	}
-	mainExists, err := branchExists(repo, mainRef)
+	mainExists, err := branchExists(repo, mainRef, config)
 	if err != nil {
 		return "", err
 	}
@@ -54,15 +70,43 @@ var DefaultRef = func(repo string) (string, error) {
 	return masterRef, nil
 }
 
+// ConfigArgs returns args prefixed with a -c flag for each git config
+// key=value in config, so the config applies only to that git invocation.
+func ConfigArgs(config map[string]string, args ...string) []string {
+	var keys []string
+	for k := range config {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var flags []string
+	for _, k := range keys {
+		flags = append(flags, "-c", k+"="+config[k])
+	}
+	return append(flags, args...)
+}
+
+// ParseConfig parses git config from key=value pairs.
+func ParseConfig(pairs []string) (map[string]string, error) {
+	config := map[string]string{}
+	for _, p := range pairs {
+		kv := strings.SplitN(p, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, errors.Errorf("invalid git config %q, must be key=value", p)
+		}
+		config[kv[0]] = kv[1]
+	}
+	return config, nil
+}
+
 // BranchExists checks if branch is present in the input repo
-func branchExists(repo, branch string) (bool, error) {
+func branchExists(repo, branch string, config map[string]string) (bool, error) {
 	gitProgram, err := exec.LookPath("git")
 	if err != nil {
 		return false, errors.Wrap(err)
 	}
 	stdOut := bytes.Buffer{}
 	stdErr := bytes.Buffer{}
-	cmd := exec.Command(gitProgram, "ls-remote", repo, branch)
+	cmd := exec.Command(gitProgram, ConfigArgs(config, "ls-remote", repo, branch)...)
 	cmd.Stderr = &stdErr
 	cmd.Stdout = &stdOut
 	err = cmd.Run()
@@ -85,7 +129,14 @@ func branchExists(repo, branch string) (bool, error) {
 // and hard reset to origin/main.
 // The refs will also be fetched so they are available locally.
 func NewUpstreamGitRunner(uri, dir string, required []string, optional []string) (*GitRunner, error) {
-	g := &GitRunner{}
+	return NewUpstreamGitRunnerWithConfig(uri, dir, required, optional, nil)
+}
+
+// NewUpstreamGitRunnerWithConfig is NewUpstreamGitRunner, passing config
+// to each git command the GitRunner runs as -c flags.
+func NewUpstreamGitRunnerWithConfig(uri, dir string, required []string, optional []string,
+	config map[string]string) (*GitRunner, error) {
+	g := &GitRunner{Config: config}
 
 	// make sure the repo is fetched
 	cacheDir, err := g.cacheRepo(uri, dir, required, optional)
@@ -121,6 +172,9 @@ type GitRunner struct {
 
 	// Verbose prints verbose command information
 	Verbose bool
+
+	// Config is passed as -c key=value flags to each git command
+	Config map[string]string
 }
 
 // Run runs a git command.
@@ -131,7 +185,7 @@ func (g *GitRunner) Run(args ...string) error {
 		return errors.WrapPrefixf(err, "no 'git' program on path")
 	}
 
-	cmd := exec.Command(p, args...)
+	cmd := exec.Command(p, ConfigArgs(g.Config, args...)...)
 	cmd.Dir = g.Dir
 	cmd.Env = os.Environ()
 
@@ -174,6 +228,44 @@ func (g *GitRunner) getRepoCacheDir() (string, error) {
 	return filepath.Join(dir, ".kpt", "repos"), nil
 }
 
+// cacheLocks serializes the use of each cached repo within the process.
+var cacheLocks = struct {
+	sync.Mutex
+	repos map[string]*sync.Mutex
+	// held counts the locks held or waited on, by repo directory, so the
+	// repos in use aren't pruned
+	held map[string]int
+}{repos: map[string]*sync.Mutex{}, held: map[string]int{}}
+
+// LockRepoCache locks the cached copy of the repo at uri, so that packages
+// can be updated from it concurrently, and returns the function to unlock it.
+func LockRepoCache(uri string) func() {
+	repoDir := (&GitRunner{}).getRepoDir(uri)
+	cacheLocks.Lock()
+	l := cacheLocks.repos[uri]
+	if l == nil {
+		l = &sync.Mutex{}
+		cacheLocks.repos[uri] = l
+	}
+	cacheLocks.held[repoDir]++
+	cacheLocks.Unlock()
+	l.Lock()
+	return func() {
+		l.Unlock()
+		cacheLocks.Lock()
+		cacheLocks.held[repoDir]--
+		cacheLocks.Unlock()
+	}
+}
+
+// repoCacheLocked returns true if the repo cached in repoDir is locked by
+// this process.
+func repoCacheLocked(repoDir string) bool {
+	cacheLocks.Lock()
+	defer cacheLocks.Unlock()
+	return cacheLocks.held[repoDir] > 0
+}
+
 // cacheRepo fetches a remote repo to a cache location, and fetches the provided refs.
 func (g *GitRunner) cacheRepo(uri, dir string,
 	requiredRefs []string, optionalRefs []string) (string, error) {
@@ -187,10 +279,12 @@ func (g *GitRunner) cacheRepo(uri, dir string,
 	}
 
 	// create the repo directory if it doesn't exist yet
-	gitRunner := GitRunner{Dir: kptCacheDir}
+	gitRunner := GitRunner{Dir: kptCacheDir, Config: g.Config}
 	uriSha := g.getRepoDir(uri)
 	repoCacheDir := filepath.Join(kptCacheDir, uriSha)
-	if _, err := os.Stat(repoCacheDir); os.IsNotExist(err) {
+	_, err = os.Stat(repoCacheDir)
+	cached := !os.IsNotExist(err)
+	if !cached {
 		if err := gitRunner.Run("init", uriSha); err != nil {
 			return "", errors.Errorf("failed to clone repo: trouble running init: %v", err)
 		}
@@ -242,7 +336,7 @@ func (g *GitRunner) cacheRepo(uri, dir string,
 			"please run 'git clone <REPO>; stat <DIR/SUBDIR>' to verify credentials", err)
 	}
 
-	defaultRef, err := DefaultRef(uri)
+	defaultRef, err := DefaultRefWithConfig(uri, g.Config)
 	if err != nil {
 		return "", errors.Errorf("%v, please run 'git clone <REPO>; stat <DIR/SUBDIR>' to verify credentials", err)
 	}
@@ -253,11 +347,15 @@ func (g *GitRunner) cacheRepo(uri, dir string,
 			"please run 'git clone <REPO>; stat <DIR/SUBDIR>' to verify credentials", defaultRef, err)
 	}
 
-	// TODO: make this safe for concurrent operations
+	// TODO: make this safe for concurrent kpt processes
 	if err = gitRunner.Run("reset", "--hard", "origin/"+defaultRef); err != nil {
 		return "", errors.Errorf("failed to clone repo: trouble reset to %s: %v, "+
 			"please run 'git clone <REPO>; stat <DIR/SUBDIR>' to verify credentials", defaultRef, err)
 	}
 	gitRunner.Dir = filepath.Join(repoCacheDir, dir)
+
+	// pruning is best effort, failing to prune only leaves the cache larger
+	recordRepoUse(kptCacheDir, uriSha, uri, cached)
+	_ = pruneRepoCache(kptCacheDir, uriSha)
 	return repoCacheDir, nil
 }
@@ -0,0 +1,183 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gitutil
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"sigs.k8s.io/kustomize/kyaml/errors"
+)
+
+// RequirePinnedUpstreamsEnv is the name of the environment variable that
+// defaults the --require-pinned-upstreams flag, so that the policy can be
+// enforced for every invocation, e.g. in CI.
+const RequirePinnedUpstreamsEnv = "KPT_REQUIRE_PINNED_UPSTREAMS"
+
+// RequirePinnedUpstreamsDefault returns the default value for the
+// --require-pinned-upstreams flag.
+func RequirePinnedUpstreamsDefault() bool {
+	v, err := strconv.ParseBool(os.Getenv(RequirePinnedUpstreamsEnv))
+	return err == nil && v
+}
+
+// commitPattern matches full and abbreviated commit SHAs.
+var commitPattern = regexp.MustCompile(`^[0-9a-f]{7,40}$`)
+
+// floatingRefs are refs which never identify a fixed version.
+var floatingRefs = map[string]bool{
+	"HEAD":   true,
+	"latest": true,
+}
+
+// CheckPinnedRef returns an error if ref does not pin the package in directory
+// of repo to a fixed version.  Tags and commits are pinned, branches are not.
+// tagTemplate names the tags of the package, see PackageTag.  config is
+// passed to git as -c flags.
+func CheckPinnedRef(repo, directory, tagTemplate, ref string, config map[string]string) error {
+	if ref == "" || floatingRefs[ref] {
+		return pinError(repo, ref, "is a floating ref")
+	}
+	switch {
+	case strings.HasPrefix(ref, "refs/tags/"):
+		return nil
+	case strings.HasPrefix(ref, "refs/heads/"):
+		return pinError(repo, ref, "is a branch")
+	}
+
+	// packages in subdirectories may be versioned with their own tags,
+	// e.g. package/v1.0.0
+	names := []string{ref}
+	tag, err := PackageTag(tagTemplate, directory, ref)
+	if err != nil {
+		return err
+	}
+	if tag != "" {
+		names = append(names, tag)
+	}
+	var patterns []string
+	for _, n := range names {
+		patterns = append(patterns, "refs/heads/"+n, "refs/tags/"+n)
+	}
+	refs, err := lsRemote(repo, config, patterns...)
+	if err != nil {
+		return err
+	}
+
+	var isTag bool
+	for _, r := range refs {
+		if strings.HasPrefix(r, "refs/heads/") {
+			return pinError(repo, ref, "is a branch")
+		}
+		isTag = true
+	}
+	if isTag || commitPattern.MatchString(ref) {
+		return nil
+	}
+	return pinError(repo, ref, "does not match a tag or commit")
+}
+
+func pinError(repo, ref, reason string) error {
+	return errors.Errorf("upstream %q ref %q %s, pinned upstreams are required "+
+		"-- use a tag or commit", repo, ref, reason)
+}
+
+// lsRemote returns the names of the refs in repo matching patterns.
+func lsRemote(repo string, config map[string]string, patterns ...string) ([]string, error) {
+	refs, err := lsRemoteRefs(repo, config, patterns...)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, r := range refs {
+		names = append(names, strings.TrimSuffix(r.name, "^{}"))
+	}
+	return names, nil
+}
+
+// remoteRef is a ref listed by git ls-remote.
+type remoteRef struct {
+	name   string
+	commit string
+}
+
+// lsRemoteRefs returns the refs in repo matching patterns.
+func lsRemoteRefs(repo string, config map[string]string, patterns ...string) ([]remoteRef, error) {
+	gitProgram, err := exec.LookPath("git")
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	stdOut := bytes.Buffer{}
+	stdErr := bytes.Buffer{}
+	cmd := exec.Command(gitProgram,
+		ConfigArgs(config, append([]string{"ls-remote", repo}, patterns...)...)...)
+	cmd.Stderr = &stdErr
+	cmd.Stdout = &stdOut
+	if err := cmd.Run(); err != nil {
+		return nil, errors.Errorf("failed to lookup refs in %q %q: %s",
+			repo, err, strings.TrimSpace(stdErr.String()))
+	}
+
+	var refs []remoteRef
+	for _, line := range strings.Split(stdOut.String(), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 {
+			refs = append(refs, remoteRef{name: fields[1], commit: fields[0]})
+		}
+	}
+	return refs, nil
+}
+
+// BranchCommit returns the commit branch of repo points at, and false if
+// repo doesn't have the branch.  config is passed to git as -c flags.
+func BranchCommit(repo, branch string, config map[string]string) (string, bool, error) {
+	name := "refs/heads/" + strings.TrimPrefix(branch, "refs/heads/")
+	refs, err := lsRemoteRefs(repo, config, name)
+	if err != nil {
+		return "", false, err
+	}
+	for _, r := range refs {
+		if r.name == name {
+			return r.commit, true, nil
+		}
+	}
+	return "", false, nil
+}
+
+// RemoteRefs returns the names of the branches and tags of repo, e.g. main
+// and v1.0, passing config to git as -c flags.  Making it a var so that it
+// can be overridden for local testing.
+var RemoteRefs = func(repo string, config map[string]string) ([]string, error) {
+	refs, err := lsRemoteRefs(repo, config)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	seen := map[string]bool{}
+	for _, r := range refs {
+		name := strings.TrimSuffix(r.name, "^{}")
+		for _, prefix := range []string{"refs/heads/", "refs/tags/"} {
+			if strings.HasPrefix(name, prefix) && !seen[name] {
+				seen[name] = true
+				names = append(names, strings.TrimPrefix(name, prefix))
+			}
+		}
+	}
+	return names, nil
+}
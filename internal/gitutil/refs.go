@@ -0,0 +1,146 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gitutil
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"sigs.k8s.io/kustomize/kyaml/errors"
+)
+
+// RefPrecedenceEnv is the name of the environment variable which chooses
+// between a tag and a branch with the same name.  It is either tag (the
+// default, matching git) or branch.
+const RefPrecedenceEnv = "KPT_REF_PRECEDENCE"
+
+// RefPrecedence returns the kind of ref, tag or branch, which is preferred
+// when a ref names both.
+func RefPrecedence() (string, error) {
+	switch p := os.Getenv(RefPrecedenceEnv); p {
+	case "", "tag":
+		return "tag", nil
+	case "branch":
+		return "branch", nil
+	default:
+		return "", errors.Errorf("%s must be tag or branch, not %q", RefPrecedenceEnv, p)
+	}
+}
+
+// ResolvedRef is the branch or tag a ref resolved to.
+type ResolvedRef struct {
+	// Name is the full name of the ref, e.g. refs/tags/v1
+	Name string
+
+	// Commit is the commit the ref points at
+	Commit string
+
+	// Ambiguous are the other refs the ref could have resolved to, in order
+	// of precedence
+	Ambiguous []ResolvedRef
+}
+
+// String describes the ref, e.g. tag v1 (0123abc).
+func (r ResolvedRef) String() string {
+	kind, name := "branch", strings.TrimPrefix(r.Name, "refs/heads/")
+	if strings.HasPrefix(r.Name, "refs/tags/") {
+		kind, name = "tag", strings.TrimPrefix(r.Name, "refs/tags/")
+	}
+	commit := r.Commit
+	if len(commit) > 7 {
+		commit = commit[:7]
+	}
+	return fmt.Sprintf("%s %s (%s)", kind, name, commit)
+}
+
+// Warning describes the choice between the refs ref could have resolved to,
+// or returns "" if ref is not ambiguous.
+func (r ResolvedRef) Warning(ref string) string {
+	if len(r.Ambiguous) == 0 {
+		return ""
+	}
+	var others []string
+	for _, a := range r.Ambiguous {
+		others = append(others, a.String())
+	}
+	return fmt.Sprintf("ref %q is ambiguous, using %s instead of %s "+
+		"-- set %s to tag or branch to choose, or use a full ref name",
+		ref, r, strings.Join(others, ", "), RefPrecedenceEnv)
+}
+
+// ResolveRef resolves ref to a branch or tag of repo.  Tags named by the
+// tag template, which version the package in directory independently, are
+// preferred to the branches and tags of the repo -- see PackageTag.  Whether tags or branches
+// are preferred is chosen by RefPrecedence.  Returns false if ref isn't a
+// branch or tag, e.g. it is a commit or a full ref name.  config is passed
+// to git as -c flags.
+func ResolveRef(repo, directory, tagTemplate, ref string,
+	config map[string]string) (ResolvedRef, bool, error) {
+	if ref == "" || strings.HasPrefix(ref, "refs/") {
+		return ResolvedRef{}, false, nil
+	}
+	precedence, err := RefPrecedence()
+	if err != nil {
+		return ResolvedRef{}, false, err
+	}
+
+	var names []string
+	kinds := []string{"refs/tags/", "refs/heads/"}
+	if precedence == "branch" {
+		kinds = []string{"refs/heads/", "refs/tags/"}
+	}
+	tag, err := PackageTag(tagTemplate, directory, ref)
+	if err != nil {
+		return ResolvedRef{}, false, err
+	}
+	if tag != "" {
+		for _, kind := range kinds {
+			names = append(names, kind+tag)
+		}
+	}
+	for _, kind := range kinds {
+		names = append(names, kind+ref)
+	}
+
+	refs, err := lsRemoteRefs(repo, config, names...)
+	if err != nil {
+		return ResolvedRef{}, false, err
+	}
+	commits := map[string]string{}
+	for _, r := range refs {
+		if strings.HasSuffix(r.name, "^{}") {
+			// the commit of an annotated tag
+			commits[strings.TrimSuffix(r.name, "^{}")] = r.commit
+		} else if _, found := commits[r.name]; !found {
+			commits[r.name] = r.commit
+		}
+	}
+
+	var found []ResolvedRef
+	for _, name := range names {
+		if commit, ok := commits[name]; ok {
+			found = append(found, ResolvedRef{Name: name, Commit: commit})
+		}
+	}
+	if len(found) == 0 {
+		return ResolvedRef{}, false, nil
+	}
+	resolved := found[0]
+	if len(found) > 1 {
+		resolved.Ambiguous = found[1:]
+	}
+	return resolved, true, nil
+}
@@ -0,0 +1,120 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gitutil_test
+
+import (
+	"os"
+	"testing"
+
+	. "github.com/GoogleContainerTools/kpt/internal/gitutil"
+	"github.com/GoogleContainerTools/kpt/internal/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestResolveRef verifies that a branch and tag with the same name are
+// resolved by precedence, and the other ref is reported.
+func TestResolveRef(t *testing.T) {
+	g, _, clean := testutil.SetupDefaultRepoAndWorkspace(t, testutil.Dataset1)
+	defer clean()
+	tagCommit, err := g.GetCommit()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	testutil.Tag(t, g, "v1")
+	if !assert.NoError(t, g.CheckoutBranch("v1", true)) {
+		t.FailNow()
+	}
+	if !assert.NoError(t, g.ReplaceData(testutil.Dataset2)) {
+		t.FailNow()
+	}
+	testutil.Commit(t, g, "branch v1")
+	branchCommit, err := g.GetCommit()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	testutil.Tag(t, g, "java/v1")
+	testutil.Tag(t, g, "v1-java")
+	if !assert.NoError(t, g.CheckoutBranch("master", false)) {
+		t.FailNow()
+	}
+
+	tag := ResolvedRef{Name: "refs/tags/v1", Commit: tagCommit}
+	branch := ResolvedRef{Name: "refs/heads/v1", Commit: branchCommit}
+	javaTag := ResolvedRef{Name: "refs/tags/java/v1", Commit: branchCommit}
+	templateTag := ResolvedRef{Name: "refs/tags/v1-java", Commit: branchCommit}
+	tests := []struct {
+		name        string
+		precedence  string
+		directory   string
+		tagTemplate string
+		ref         string
+		expected    ResolvedRef
+		found       bool
+		errMsg      string
+	}{
+		{name: "default", directory: "/", ref: "v1", found: true,
+			expected: ResolvedRef{Name: tag.Name, Commit: tag.Commit, Ambiguous: []ResolvedRef{branch}}},
+		{name: "tag", precedence: "tag", directory: "/", ref: "v1", found: true,
+			expected: ResolvedRef{Name: tag.Name, Commit: tag.Commit, Ambiguous: []ResolvedRef{branch}}},
+		{name: "branch", precedence: "branch", directory: "/", ref: "v1", found: true,
+			expected: ResolvedRef{Name: branch.Name, Commit: branch.Commit, Ambiguous: []ResolvedRef{tag}}},
+		{name: "directory", directory: "/java", ref: "v1", found: true,
+			expected: ResolvedRef{Name: javaTag.Name, Commit: javaTag.Commit,
+				Ambiguous: []ResolvedRef{tag, branch}}},
+		{name: "template", directory: "/java", tagTemplate: "{{.Ref}}-{{.PkgName}}", ref: "v1", found: true,
+			expected: ResolvedRef{Name: templateTag.Name, Commit: templateTag.Commit,
+				Ambiguous: []ResolvedRef{tag, branch}}},
+		{name: "unambiguous", directory: "/", ref: "master", found: true,
+			expected: ResolvedRef{Name: "refs/heads/master", Commit: tagCommit}},
+		{name: "commit", directory: "/", ref: tagCommit},
+		{name: "full", directory: "/", ref: "refs/heads/v1"},
+		{name: "invalid", precedence: "newest", directory: "/", ref: "v1",
+			errMsg: "KPT_REF_PRECEDENCE must be tag or branch"},
+	}
+	for i := range tests {
+		test := tests[i]
+		t.Run(test.name, func(t *testing.T) {
+			if test.precedence != "" {
+				os.Setenv(RefPrecedenceEnv, test.precedence)
+				defer os.Unsetenv(RefPrecedenceEnv)
+			}
+			actual, found, err := ResolveRef(g.RepoDirectory, test.directory, test.tagTemplate, test.ref, nil)
+			if test.errMsg != "" {
+				if assert.Error(t, err) {
+					assert.Contains(t, err.Error(), test.errMsg)
+				}
+				return
+			}
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			assert.Equal(t, test.found, found)
+			if test.found {
+				assert.Equal(t, test.expected, actual)
+			}
+		})
+	}
+}
+
+// TestResolvedRef_Warning verifies the warning describes the chosen ref and
+// the refs it was chosen over.
+func TestResolvedRef_Warning(t *testing.T) {
+	r := ResolvedRef{Name: "refs/tags/v1", Commit: "0123456789",
+		Ambiguous: []ResolvedRef{{Name: "refs/heads/v1", Commit: "abcdef0123"}}}
+	assert.Equal(t, `ref "v1" is ambiguous, using tag v1 (0123456) instead of `+
+		`branch v1 (abcdef0) -- set KPT_REF_PRECEDENCE to tag or branch to choose, `+
+		`or use a full ref name`, r.Warning("v1"))
+	assert.Equal(t, "", ResolvedRef{Name: "refs/heads/v1"}.Warning("v1"))
+}
@@ -0,0 +1,83 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gitutil
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+
+	"sigs.k8s.io/kustomize/kyaml/errors"
+)
+
+// IsRelativeRepo returns true if repo is a path to a package in the same git
+// repository, relative to the local package -- e.g. ./../../base-pkg
+func IsRelativeRepo(repo string) bool {
+	return repo == "." || repo == ".." ||
+		strings.HasPrefix(repo, "./") || strings.HasPrefix(repo, "../")
+}
+
+// ResolveRelativeRepo resolves the relative repo rel against dir, returning
+// the root of the enclosing git repository and the directory of the package
+// within it.
+func ResolveRelativeRepo(dir, rel string) (string, string, error) {
+	abs, err := filepath.Abs(filepath.Join(dir, filepath.FromSlash(rel)))
+	if err != nil {
+		return "", "", errors.Wrap(err)
+	}
+	// resolve symlinks so the path can be compared against the repo root
+	abs, err = filepath.EvalSymlinks(abs)
+	if err != nil {
+		return "", "", errors.Errorf("unable to resolve upstream %q: %v", rel, err)
+	}
+
+	g := NewLocalGitRunner(abs)
+	if err := g.Run("rev-parse", "--show-toplevel"); err != nil {
+		return "", "", errors.Errorf(
+			"upstream %q must be in a git repository: %v", rel, err)
+	}
+	root, err := filepath.EvalSymlinks(strings.TrimSpace(g.Stdout.String()))
+	if err != nil {
+		return "", "", errors.Wrap(err)
+	}
+
+	sub, err := filepath.Rel(root, abs)
+	if err != nil {
+		return "", "", errors.Wrap(err)
+	}
+	return root, path.Join("/", filepath.ToSlash(sub)), nil
+}
+
+// RelativeRepo returns the relative repo for the package at directory of the
+// repository at root, relative to the local package at dir.
+func RelativeRepo(dir, root, directory string) (string, error) {
+	from, err := filepath.Abs(dir)
+	if err != nil {
+		return "", errors.Wrap(err)
+	}
+	// dir may not exist yet, so only resolve symlinks in its parent
+	if parent, err := filepath.EvalSymlinks(filepath.Dir(from)); err == nil {
+		from = filepath.Join(parent, filepath.Base(from))
+	}
+	rel, err := filepath.Rel(from, filepath.Join(root, filepath.FromSlash(directory)))
+	if err != nil {
+		return "", errors.Wrap(err)
+	}
+	rel = filepath.ToSlash(rel)
+	if !IsRelativeRepo(rel) {
+		rel = "./" + rel
+	}
+	return rel, nil
+}
@@ -0,0 +1,117 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gitutil
+
+import (
+	"bytes"
+	"os"
+	"path"
+	"strings"
+	"text/template"
+
+	"sigs.k8s.io/kustomize/kyaml/errors"
+)
+
+// TagTemplateEnv is the name of the environment variable with the tag
+// template used for packages which don't set one in their Kptfile.
+const TagTemplateEnv = "KPT_TAG_TEMPLATE"
+
+// TagValues are the values a tag template is expanded with.
+type TagValues struct {
+	// Path is the directory of the package in the repo, e.g. pkgs/mysql
+	Path string
+
+	// PkgName is the name of the directory of the package, e.g. mysql
+	PkgName string
+
+	// Ref is the ref the package is fetched at, e.g. v1.2.0
+	Ref string
+
+	// Version is the ref without a leading v, e.g. 1.2.0
+	Version string
+}
+
+// TagTemplate returns the template the tags of a package are named with --
+// template if it is set, otherwise the value of TagTemplateEnv.  An empty
+// template names the tags of packages in subdirectories with the directory
+// as a prefix, e.g. pkgs/mysql/v1.2.0.
+func TagTemplate(template string) string {
+	if template != "" {
+		return template
+	}
+	return os.Getenv(TagTemplateEnv)
+}
+
+// PackageTag returns the name of the tag which versions the package in
+// directory at ref, e.g. {{.Path}}/v{{.Version}} or v{{.Version}}-{{.PkgName}}.
+// Returns "" if the package isn't versioned with its own tags, i.e. ref
+// names the tag.
+func PackageTag(tmpl, directory, ref string) (string, error) {
+	dir := strings.Trim(directory, "/")
+	if dir == "." {
+		dir = ""
+	}
+	tmpl = TagTemplate(tmpl)
+	if tmpl == "" {
+		if dir == "" {
+			return "", nil
+		}
+		return path.Join(dir, ref), nil
+	}
+	t, err := template.New("tag").Option("missingkey=error").Parse(tmpl)
+	if err != nil {
+		return "", errors.Errorf("invalid tag template %q: %v", tmpl, err)
+	}
+	values := TagValues{Path: dir, Ref: ref, Version: strings.TrimPrefix(ref, "v")}
+	if dir != "" {
+		values.PkgName = path.Base(dir)
+	}
+	b := &bytes.Buffer{}
+	if err := t.Execute(b, values); err != nil {
+		return "", errors.Errorf("invalid tag template %q: %v", tmpl, err)
+	}
+	tag := strings.TrimLeft(b.String(), "/")
+	if tag == ref {
+		return "", nil
+	}
+	return tag, nil
+}
+
+// tagPattern returns the prefix and suffix of the tags the template names
+// for the package in directory, around the ref.
+func tagPattern(tmpl, directory string) (string, string, error) {
+	// the placeholder doesn't start with a v, so it is also the version
+	const placeholder = "\x00ref\x00"
+	tag, err := PackageTag(tmpl, directory, placeholder)
+	if err != nil || tag == "" {
+		return "", "", err
+	}
+	i := strings.Index(tag, placeholder)
+	if i < 0 {
+		return "", "", errors.Errorf("tag template %q must contain {{.Ref}} or {{.Version}}",
+			TagTemplate(tmpl))
+	}
+	return tag[:i], tag[i+len(placeholder):], nil
+}
+
+// TagRef returns the ref named by the tag of the package in directory -- the
+// inverse of PackageTag.  Returns tag if it isn't named by the template.
+func TagRef(tmpl, directory, tag string) string {
+	prefix, suffix, err := tagPattern(tmpl, directory)
+	if err != nil || !matchTag(tag, prefix, suffix) {
+		return tag
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(tag, prefix), suffix)
+}
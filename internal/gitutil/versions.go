@@ -0,0 +1,121 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gitutil
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/GoogleContainerTools/kpt/internal/util/semver"
+	"sigs.k8s.io/kustomize/kyaml/errors"
+)
+
+// ListVersions returns the semantic versions the package in directory of
+// repo is tagged with, newest first.  Packages in subdirectories may be
+// versioned with their own tags, named by tagTemplate (see PackageTag), e.g.
+// package/v1.0.0, which are used in preference to the tags of the repo.  The
+// Original of each version is the ref named by the tag, which fetches the
+// package with kpt.  config is passed to git as -c flags.
+func ListVersions(repo, directory, tagTemplate string,
+	config map[string]string) ([]semver.Version, error) {
+	refs, err := tagRefs(repo, config)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix, suffix := "refs/tags/", ""
+	p, s, err := tagPattern(tagTemplate, directory)
+	if err != nil {
+		return nil, err
+	}
+	if p != "" || s != "" {
+		for _, r := range refs {
+			if matchTag(r, prefix+p, s) {
+				prefix, suffix = prefix+p, s
+				break
+			}
+		}
+	}
+
+	seen := map[string]bool{}
+	var versions []semver.Version
+	for _, r := range refs {
+		if !matchTag(r, prefix, suffix) {
+			continue
+		}
+		tag := strings.TrimSuffix(strings.TrimPrefix(r, prefix), suffix)
+		if seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		v, err := semver.Parse(tag)
+		if err != nil {
+			// not a version
+			continue
+		}
+		versions = append(versions, v)
+	}
+	sort.SliceStable(versions, func(i, j int) bool {
+		return versions[i].Compare(versions[j]) > 0
+	})
+	return versions, nil
+}
+
+// matchTag returns true if ref has the prefix and suffix around a non-empty
+// ref.
+func matchTag(ref, prefix, suffix string) bool {
+	return len(ref) > len(prefix)+len(suffix) &&
+		strings.HasPrefix(ref, prefix) && strings.HasSuffix(ref, suffix)
+}
+
+// tagRefs returns the refs of the tags of repo.  The tags of repos hosted on
+// GitHub are listed with the GitHub API, which is much faster for repos with
+// many refs, falling back to git if the API fails.
+func tagRefs(repo string, config map[string]string) ([]string, error) {
+	refs, ok, err := gitHubTags(repo)
+	if ok && err == nil {
+		return refs, nil
+	}
+	if ok {
+		fmt.Fprintf(os.Stderr, "listing tags of %q with git: %v\n", repo, err)
+	}
+	return lsRemote(repo, config, "refs/tags/*")
+}
+
+// LatestVersion returns the tag of the newest version of the package in
+// directory of repo which satisfies constraint, e.g. ">=1.2, <2".  Returns
+// an error if no version satisfies the constraint.
+func LatestVersion(repo, directory, tagTemplate, constraint string,
+	config map[string]string) (string, error) {
+	c, err := semver.ParseConstraint(constraint)
+	if err != nil {
+		return "", err
+	}
+	versions, err := ListVersions(repo, directory, tagTemplate, config)
+	if err != nil {
+		return "", err
+	}
+	for _, v := range versions {
+		if c.Match(v) {
+			return v.Original, nil
+		}
+	}
+	if constraint == "" {
+		return "", errors.Errorf("no version tags found for %q in %q", directory, repo)
+	}
+	return "", errors.Errorf("no version of %q in %q satisfies %q", directory, repo, constraint)
+}
@@ -0,0 +1,74 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gitutil_test
+
+import (
+	"testing"
+
+	. "github.com/GoogleContainerTools/kpt/internal/gitutil"
+	"github.com/GoogleContainerTools/kpt/internal/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestLatestVersion verifies that tags prefixed by the package directory are
+// preferred to the tags of the repo.
+func TestLatestVersion(t *testing.T) {
+	g, _, clean := testutil.SetupDefaultRepoAndWorkspace(t, testutil.Dataset1)
+	defer clean()
+	for _, tag := range []string{"v1.0.0", "v1.1.0", "v2.0.0-rc.1", "latest",
+		"java/v0.1.0", "java/v0.2.0", "java/v0.10.0",
+		"mysql-v3.0.0", "mysql-v3.1.0", "release/wordpress/4.0.0"} {
+		testutil.Tag(t, g, tag)
+	}
+
+	tests := []struct {
+		directory   string
+		tagTemplate string
+		constraint  string
+		expected    string
+		errMsg      string
+	}{
+		{directory: "/", expected: "v1.1.0"},
+		{directory: "/mysql", expected: "v1.1.0"},
+		{directory: "/mysql", constraint: "<1.1", expected: "v1.0.0"},
+		{directory: "/mysql", constraint: ">=2.0.0-rc.0", expected: "v2.0.0-rc.1"},
+		{directory: "/java", expected: "v0.10.0"},
+		{directory: "java", constraint: "~0.2", expected: "v0.2.0"},
+		{directory: "/java", constraint: "^1", errMsg: `no version of "/java"`},
+		{directory: "/mysql", tagTemplate: "{{.PkgName}}-{{.Ref}}", expected: "v3.1.0"},
+		{directory: "/mysql", tagTemplate: "{{.PkgName}}-v{{.Version}}", constraint: "<3.1",
+			expected: "3.0.0"},
+		{directory: "/wordpress", tagTemplate: "release/{{.Path}}/{{.Version}}", expected: "4.0.0"},
+		{directory: "/java", tagTemplate: "{{.PkgName}}-{{.Ref}}", expected: "v1.1.0"},
+		{directory: "/java", tagTemplate: "{{.Name}}", errMsg: "invalid tag template"},
+	}
+	for i := range tests {
+		test := tests[i]
+		t.Run(test.directory+test.tagTemplate+test.constraint, func(t *testing.T) {
+			actual, err := LatestVersion(g.RepoDirectory, test.directory, test.tagTemplate,
+				test.constraint, nil)
+			if test.errMsg != "" {
+				if assert.Error(t, err) {
+					assert.Contains(t, err.Error(), test.errMsg)
+				}
+				return
+			}
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+}
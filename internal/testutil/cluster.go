@@ -0,0 +1,92 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testutil
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	fakediscovery "k8s.io/client-go/discovery/fake"
+	fakedynamic "k8s.io/client-go/dynamic/fake"
+	clienttesting "k8s.io/client-go/testing"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// clusterScopedKinds are the kinds NewFakeCluster serves as cluster-scoped
+var clusterScopedKinds = map[string]bool{
+	"Namespace":                true,
+	"ClusterRole":              true,
+	"ClusterRoleBinding":       true,
+	"CustomResourceDefinition": true,
+}
+
+// NewFakeCluster returns a fake dynamic client and discovery client serving
+// resources, which are yaml strings.  Each kind is served as the resource
+// named by its lower-cased plural.
+func NewFakeCluster(t *testing.T, resources ...string) (*fakedynamic.FakeDynamicClient,
+	discovery.CachedDiscoveryInterface) {
+	var objs []runtime.Object
+	listKinds := map[schema.GroupVersionResource]string{}
+	apiResources := map[string]map[string]metav1.APIResource{}
+	for _, r := range resources {
+		node, err := yaml.Parse(r)
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		// round-trip through json so values have the types the client expects
+		b, err := node.MarshalJSON()
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		u := &unstructured.Unstructured{}
+		if !assert.NoError(t, u.UnmarshalJSON(b)) {
+			t.FailNow()
+		}
+		objs = append(objs, u)
+
+		gvk := u.GroupVersionKind()
+		gvr := gvk.GroupVersion().WithResource(strings.ToLower(gvk.Kind) + "s")
+		listKinds[gvr] = gvk.Kind + "List"
+		gv := gvk.GroupVersion().String()
+		if apiResources[gv] == nil {
+			apiResources[gv] = map[string]metav1.APIResource{}
+		}
+		apiResources[gv][gvr.Resource] = metav1.APIResource{
+			Name:       gvr.Resource,
+			Kind:       gvk.Kind,
+			Namespaced: !clusterScopedKinds[gvk.Kind],
+			Verbs:      []string{"get", "list"},
+		}
+	}
+
+	fake := &clienttesting.Fake{}
+	for gv, resources := range apiResources {
+		list := &metav1.APIResourceList{GroupVersion: gv}
+		for _, r := range resources {
+			list.APIResources = append(list.APIResources, r)
+		}
+		fake.Resources = append(fake.Resources, list)
+	}
+	client := fakedynamic.NewSimpleDynamicClientWithCustomListKinds(
+		runtime.NewScheme(), listKinds, objs...)
+	return client, memory.NewMemCacheClient(&fakediscovery.FakeDiscovery{Fake: fake})
+}
@@ -0,0 +1,78 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package testutil
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// FakeHelm is a fake helm binary which prints canned output.
+type FakeHelm struct {
+	// Binary is the path to the fake helm binary
+	Binary string
+
+	dir string
+}
+
+// NewFakeHelm returns a fake helm binary which prints rendered for
+// `helm template`, values for `helm show values` and chart for
+// `helm show chart`.
+func NewFakeHelm(t *testing.T, rendered, values, chart string) *FakeHelm {
+	dir, err := ioutil.TempDir("", "kpt-test-helm")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	files := map[string]string{
+		"template.yaml": rendered,
+		"values.yaml":   values,
+		"Chart.yaml":    chart,
+		"helm": fmt.Sprintf(`#!/bin/sh
+echo "$@" >> %[1]s/args
+case "$1 $2" in
+  "show values") cat %[1]s/values.yaml ;;
+  "show chart") cat %[1]s/Chart.yaml ;;
+  template*) cat %[1]s/template.yaml ;;
+  *) echo "unknown command $1" >&2; exit 1 ;;
+esac
+`, dir),
+	}
+	for name, content := range files {
+		if !assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0700)) {
+			t.FailNow()
+		}
+	}
+	return &FakeHelm{Binary: filepath.Join(dir, "helm"), dir: dir}
+}
+
+// Args returns the arguments of each invocation of the fake helm binary.
+func (h *FakeHelm) Args(t *testing.T) []string {
+	b, err := ioutil.ReadFile(filepath.Join(h.dir, "args"))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	return strings.Split(strings.TrimSpace(string(b)), "\n")
+}
+
+// Clean removes the fake helm binary.
+func (h *FakeHelm) Clean() {
+	os.RemoveAll(h.dir)
+}
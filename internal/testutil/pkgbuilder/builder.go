@@ -360,3 +360,22 @@ func ExpandPkg(t *testing.T, pkg *Pkg) string {
 	}
 	return filepath.Join(dir, pkg.Name)
 }
+
+// WritePackage writes files, keyed by their slash-separated paths, to a new
+// temp directory, creating their directories, and returns the directory.
+func WritePackage(t *testing.T, files map[string]string) string {
+	dir, err := ioutil.TempDir("", "test-kpt-package-")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if !assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0700)) {
+			t.FailNow()
+		}
+		if !assert.NoError(t, ioutil.WriteFile(path, []byte(content), 0600)) {
+			t.FailNow()
+		}
+	}
+	return dir
+}
@@ -26,6 +26,7 @@ import (
 	"testing"
 
 	"github.com/GoogleContainerTools/kpt/internal/gitutil"
+	"github.com/GoogleContainerTools/kpt/internal/util/update"
 	"github.com/GoogleContainerTools/kpt/pkg/kptfile"
 	"github.com/GoogleContainerTools/kpt/pkg/kptfile/kptfileutil"
 	"github.com/stretchr/testify/assert"
@@ -238,6 +239,8 @@ func Compare(t *testing.T, a, b string) {
 }
 
 // AssertKptfile verifies the contents of the KptFile matches the provided value.
+// The upstream digest is expected to be the digest of the cloned package unless
+// the provided value sets it.
 func (g *TestGitRepo) AssertKptfile(t *testing.T, cloned string, kpkg kptfile.KptFile) bool {
 	// read the actual generated KptFile
 	b, err := ioutil.ReadFile(filepath.Join(cloned, kptfile.KptFileName))
@@ -250,6 +253,12 @@ func (g *TestGitRepo) AssertKptfile(t *testing.T, cloned string, kpkg kptfile.Kp
 	if !assert.NoError(t, d.Decode(&actual)) {
 		return false
 	}
+	if kpkg.Upstream.Digest == "" && actual.Upstream.Digest != "" {
+		kpkg.Upstream.Digest, err = update.Digest(cloned)
+		if !assert.NoError(t, err) {
+			return false
+		}
+	}
 	return assert.Equal(t, kpkg, actual)
 }
 
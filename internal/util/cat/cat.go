@@ -0,0 +1,128 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cat selects the resources printed by cat, and writes them as a
+// yaml stream, json or a single yaml document.
+package cat
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/kustomize/kyaml/errors"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// Output formats
+const (
+	YAMLStreamOutput = "yaml-stream"
+	JSONOutput       = "json"
+	SingleDocOutput  = "single-doc"
+)
+
+// Selector selects resources by kind, name, namespace and labels.  Unset
+// fields select any resource.
+type Selector struct {
+	Kind      string
+	Name      string
+	Namespace string
+
+	// Labels if set selects the resources whose labels match it
+	Labels labels.Selector
+}
+
+// Empty returns true if s selects any resource.
+func (s Selector) Empty() bool {
+	return s.Kind == "" && s.Name == "" && s.Namespace == "" &&
+		(s.Labels == nil || s.Labels.Empty())
+}
+
+// Filter implements kio.Filter, keeping the resources s selects.
+func (s Selector) Filter(nodes []*yaml.RNode) ([]*yaml.RNode, error) {
+	var selected []*yaml.RNode
+	for _, n := range nodes {
+		m, err := n.GetMeta()
+		if err != nil {
+			return nil, err
+		}
+		if s.selects(m) {
+			selected = append(selected, n)
+		}
+	}
+	return selected, nil
+}
+
+// selects returns true if the resource with m is selected.
+func (s Selector) selects(m yaml.ResourceMeta) bool {
+	switch {
+	case s.Kind != "" && s.Kind != m.Kind,
+		s.Namespace != "" && s.Namespace != m.Namespace,
+		s.Name != "" && s.Name != m.Name:
+		return false
+	}
+	return s.Labels == nil || s.Labels.Matches(labels.Set(m.Labels))
+}
+
+// ValidateOutput returns an error if output isn't a supported format.
+func ValidateOutput(output string) error {
+	switch output {
+	case "", YAMLStreamOutput, JSONOutput, SingleDocOutput:
+		return nil
+	}
+	return errors.Errorf("unsupported output %q, must be one of %s, %s, %s",
+		output, YAMLStreamOutput, JSONOutput, SingleDocOutput)
+}
+
+// Write writes nodes to w in the format output.  The ResourceList or List
+// nodes are wrapped in is taken from bw; json and a single document are
+// wrapped in a v1 List unless bw wraps them.
+func Write(w io.Writer, output string, nodes []*yaml.RNode, bw kio.ByteWriter) error {
+	if err := ValidateOutput(output); err != nil {
+		return err
+	}
+	bw.Writer = w
+	if output == "" || output == YAMLStreamOutput {
+		return bw.Write(nodes)
+	}
+	if bw.WrappingKind == "" {
+		bw.WrappingKind, bw.WrappingAPIVersion = "List", "v1"
+	}
+	if output == SingleDocOutput {
+		return bw.Write(nodes)
+	}
+
+	doc := &bytes.Buffer{}
+	bw.Writer = doc
+	if err := bw.Write(nodes); err != nil {
+		return err
+	}
+	list, err := yaml.Parse(doc.String())
+	if err != nil {
+		return err
+	}
+	b, err := list.MarshalJSON()
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	out := &bytes.Buffer{}
+	if err := json.Indent(out, b, "", "  "); err != nil {
+		return errors.Wrap(err)
+	}
+	out.WriteString("\n")
+	_, err = out.WriteTo(w)
+	return errors.Wrap(err)
+}
@@ -0,0 +1,266 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cat_test
+
+import (
+	"bytes"
+	"testing"
+
+	. "github.com/GoogleContainerTools/kpt/internal/util/cat"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+const resources = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: prod
+  labels:
+    app: web
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+  namespace: prod
+  labels:
+    app: web
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cfg
+  namespace: dev
+`
+
+func TestSelector_Filter(t *testing.T) {
+	var tests = []struct {
+		name     string
+		selector Selector
+		label    string
+		expected []string
+	}{
+		{
+			name:     "empty",
+			expected: []string{"Deployment/web", "Service/web", "ConfigMap/cfg"},
+		},
+		{
+			name:     "kind",
+			selector: Selector{Kind: "Service"},
+			expected: []string{"Service/web"},
+		},
+		{
+			name:     "name",
+			selector: Selector{Name: "web"},
+			expected: []string{"Deployment/web", "Service/web"},
+		},
+		{
+			name:     "namespace",
+			selector: Selector{Namespace: "dev"},
+			expected: []string{"ConfigMap/cfg"},
+		},
+		{
+			name:     "label",
+			label:    "app=web",
+			expected: []string{"Deployment/web", "Service/web"},
+		},
+		{
+			name:     "label-not",
+			label:    "app!=web",
+			expected: []string{"ConfigMap/cfg"},
+		},
+		{
+			name:     "kind-label",
+			selector: Selector{Kind: "Deployment"},
+			label:    "app=web",
+			expected: []string{"Deployment/web"},
+		},
+		{
+			name:     "none",
+			selector: Selector{Kind: "Secret"},
+		},
+	}
+	for i := range tests {
+		test := tests[i]
+		t.Run(test.name, func(t *testing.T) {
+			nodes, err := kio.FromBytes([]byte(resources))
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			if test.label != "" {
+				if test.selector.Labels, err = labels.Parse(test.label); !assert.NoError(t, err) {
+					t.FailNow()
+				}
+			}
+			assert.Equal(t, test.label == "" && test.selector == (Selector{}), test.selector.Empty())
+			nodes, err = test.selector.Filter(nodes)
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			var actual []string
+			for _, n := range nodes {
+				actual = append(actual, n.GetKind()+"/"+n.GetName())
+			}
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+}
+
+func TestWrite(t *testing.T) {
+	var tests = []struct {
+		name     string
+		output   string
+		input    string
+		wrapping kio.ByteWriter
+		expected string
+		err      string
+	}{
+		{
+			name:   "yaml-stream",
+			output: YAMLStreamOutput,
+			input:  resources,
+			expected: `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: prod
+  labels:
+    app: web
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+  namespace: prod
+  labels:
+    app: web
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cfg
+  namespace: dev
+`,
+		},
+		{
+			name:   "single-doc",
+			output: SingleDocOutput,
+			input: `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cfg
+`,
+			expected: `apiVersion: v1
+kind: List
+items:
+- apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: cfg
+`,
+		},
+		{
+			name:   "single-doc-wrapped",
+			output: SingleDocOutput,
+			input: `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cfg
+`,
+			wrapping: kio.ByteWriter{
+				WrappingKind:       kio.ResourceListKind,
+				WrappingAPIVersion: kio.ResourceListAPIVersion,
+			},
+			expected: `apiVersion: config.kubernetes.io/v1alpha1
+kind: ResourceList
+items:
+- apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: cfg
+`,
+		},
+		{
+			name:   "json",
+			output: JSONOutput,
+			input: `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cfg
+data:
+  replicas: "3"
+`,
+			expected: `{
+  "apiVersion": "v1",
+  "items": [
+    {
+      "apiVersion": "v1",
+      "data": {
+        "replicas": "3"
+      },
+      "kind": "ConfigMap",
+      "metadata": {
+        "name": "cfg"
+      }
+    }
+  ],
+  "kind": "List"
+}
+`,
+		},
+		{
+			name:   "json-empty",
+			output: JSONOutput,
+			expected: `{
+  "apiVersion": "v1",
+  "items": [],
+  "kind": "List"
+}
+`,
+		},
+		{
+			name:   "unsupported",
+			output: "xml",
+			err:    `unsupported output "xml", must be one of yaml-stream, json, single-doc`,
+		},
+	}
+	for i := range tests {
+		test := tests[i]
+		t.Run(test.name, func(t *testing.T) {
+			var nodes []*yaml.RNode
+			if test.input != "" {
+				var err error
+				if nodes, err = kio.FromBytes([]byte(test.input)); !assert.NoError(t, err) {
+					t.FailNow()
+				}
+			}
+			out := &bytes.Buffer{}
+			err := Write(out, test.output, nodes, test.wrapping)
+			if test.err != "" {
+				if assert.Error(t, err) {
+					assert.Equal(t, test.err, err.Error())
+				}
+				return
+			}
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			assert.Equal(t, test.expected, out.String())
+		})
+	}
+}
@@ -0,0 +1,222 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cfgtree writes the resources printed by cfg tree as json, nested
+// by directory, for tooling to consume.
+package cfgtree
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	kptfile "github.com/GoogleContainerTools/kpt/pkg/kptfile"
+	"sigs.k8s.io/kustomize/cmd/config/runner"
+	"sigs.k8s.io/kustomize/kyaml/errors"
+	"sigs.k8s.io/kustomize/kyaml/kio/kioutil"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// Field is a field printed for each resource that has it.
+type Field struct {
+	// Name is the field path the field was given as, e.g. spec.replicas.
+	Name string
+
+	// Path is the parsed field path.
+	Path []string
+}
+
+// NewField returns the field at path, named by its dot-separated path.
+func NewField(path ...string) Field {
+	var name string
+	for i, p := range path {
+		if i > 0 && !strings.HasPrefix(p, "[") {
+			name += "."
+		}
+		name += p
+	}
+	return Field{Name: name, Path: path}
+}
+
+// ParseFields parses dot-separated field paths, such as
+// spec.template.spec.containers[name=nginx].image.
+func ParseFields(paths []string) ([]Field, error) {
+	var fields []Field
+	for _, p := range paths {
+		path, err := runner.ParseFieldPath(p)
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, Field{Name: p, Path: path})
+	}
+	return fields, nil
+}
+
+// Directory is a directory containing resources.
+type Directory struct {
+	// Path is the path of the directory, relative to the root.
+	Path string `json:"path"`
+
+	// Package is true if the directory contains a Kptfile.
+	Package bool `json:"package,omitempty"`
+
+	Resources   []Resource   `json:"resources,omitempty"`
+	Directories []*Directory `json:"directories,omitempty"`
+}
+
+// Resource is a resource and the values of the printed fields it has.
+type Resource struct {
+	File       string `json:"file,omitempty"`
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Name       string `json:"name"`
+	Namespace  string `json:"namespace,omitempty"`
+
+	// Fields are the values of the printed fields, keyed by field path.
+	// Fields whose path selects list elements have a list of values.
+	Fields map[string]interface{} `json:"fields,omitempty"`
+}
+
+// JSONWriter writes resources as a json tree of directories.
+type JSONWriter struct {
+	// Root is the directory the resources were read from, or "" if they
+	// weren't read from a directory.
+	Root string
+
+	// Writer is where the json is written.
+	Writer io.Writer
+
+	// Fields are the fields printed for each resource.
+	Fields []Field
+}
+
+// Write implements kio.Writer.
+func (w JSONWriter) Write(nodes []*yaml.RNode) error {
+	tree, err := w.Tree(nodes)
+	if err != nil {
+		return err
+	}
+	e := json.NewEncoder(w.Writer)
+	e.SetIndent("", "  ")
+	return errors.Wrap(e.Encode(tree))
+}
+
+// Tree returns the tree of directories containing nodes.
+func (w JSONWriter) Tree(nodes []*yaml.RNode) (*Directory, error) {
+	root := &Directory{Path: ".", Package: w.isPackage(".")}
+	dirs := map[string]*Directory{".": root}
+	for _, node := range nodes {
+		r, err := w.resource(node)
+		if err != nil {
+			return nil, err
+		}
+		d := w.directory(dirs, filepath.Dir(r.File))
+		d.Resources = append(d.Resources, r)
+	}
+	for _, d := range dirs {
+		sort.SliceStable(d.Resources, func(i, j int) bool {
+			return d.Resources[i].File < d.Resources[j].File
+		})
+		sort.Slice(d.Directories, func(i, j int) bool {
+			return d.Directories[i].Path < d.Directories[j].Path
+		})
+	}
+	return root, nil
+}
+
+// directory returns the directory at path, adding it and its parents to
+// dirs if missing.
+func (w JSONWriter) directory(dirs map[string]*Directory, path string) *Directory {
+	if d, found := dirs[path]; found {
+		return d
+	}
+	d := &Directory{Path: path, Package: w.isPackage(path)}
+	dirs[path] = d
+	parent := w.directory(dirs, filepath.Dir(path))
+	parent.Directories = append(parent.Directories, d)
+	return d
+}
+
+// isPackage returns true if the directory at path contains a Kptfile.
+func (w JSONWriter) isPackage(path string) bool {
+	if w.Root == "" {
+		return false
+	}
+	_, err := os.Stat(filepath.Join(w.Root, path, kptfile.KptFileName))
+	return err == nil
+}
+
+// resource returns the resource for node, with the values of its fields.
+func (w JSONWriter) resource(node *yaml.RNode) (Resource, error) {
+	m, err := node.GetMeta()
+	if err != nil {
+		return Resource{}, err
+	}
+	file, _, err := kioutil.GetFileAnnotations(node)
+	if err != nil {
+		return Resource{}, err
+	}
+	r := Resource{
+		APIVersion: m.APIVersion,
+		Kind:       m.Kind,
+		Name:       m.Name,
+		Namespace:  m.Namespace,
+	}
+	if file != "" {
+		r.File = filepath.Clean(file)
+	}
+	for _, f := range w.Fields {
+		v, found, err := value(node, f.Path)
+		if err != nil {
+			return Resource{}, errors.WrapPrefixf(err, "field %s of %s/%s", f.Name, m.Kind, m.Name)
+		}
+		if !found {
+			continue
+		}
+		if r.Fields == nil {
+			r.Fields = map[string]interface{}{}
+		}
+		r.Fields[f.Name] = v
+	}
+	return r, nil
+}
+
+// value returns the value of the field at path in node.  If path selects
+// list elements the values of all matching fields are returned as a list.
+func value(node *yaml.RNode, path []string) (interface{}, bool, error) {
+	matches, err := node.Pipe(&yaml.PathMatcher{Path: path, StripComments: true})
+	if err != nil {
+		return nil, false, err
+	}
+	if matches == nil || len(matches.Content()) == 0 {
+		return nil, false, nil
+	}
+	var values []interface{}
+	for _, n := range matches.Content() {
+		var v interface{}
+		if err := n.Decode(&v); err != nil {
+			return nil, false, errors.Wrap(err)
+		}
+		values = append(values, v)
+	}
+	for _, p := range path {
+		if strings.HasPrefix(p, "[") {
+			return values, true, nil
+		}
+	}
+	return values[0], true, nil
+}
@@ -0,0 +1,174 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cfgtree_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/GoogleContainerTools/kpt/internal/util/cfgtree"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+)
+
+// TestJSONWriter verifies that resources are nested by directory, with the
+// values of the printed fields they have.
+func TestJSONWriter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cfgtree")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir)
+
+	for path, content := range map[string]string{
+		"Kptfile": `apiVersion: kpt.dev/v1alpha1
+kind: Kptfile
+metadata:
+  name: app
+`,
+		"deploy.yaml": `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+spec:
+  replicas: 3
+  template:
+    spec:
+      containers:
+      - name: nginx
+        image: nginx:1.19
+      - name: sidecar
+        image: sidecar:1
+`,
+		filepath.Join("db", "cr.yaml"): `apiVersion: example.com/v1
+kind: Database
+metadata:
+  name: db
+  namespace: data
+spec:
+  storage:
+    size: 10Gi
+`,
+	} {
+		if !assert.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(dir, path)), 0700)) {
+			t.FailNow()
+		}
+		if !assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, path), []byte(content), 0600)) {
+			t.FailNow()
+		}
+	}
+
+	fields, err := ParseFields([]string{"spec.replicas", "spec.storage.size"})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	fields = append(fields,
+		NewField("spec", "template", "spec", "containers", "[name=.*]", "image"))
+
+	b := &bytes.Buffer{}
+	err = kio.Pipeline{
+		Inputs:  []kio.Reader{kio.LocalPackageReader{PackagePath: dir}},
+		Outputs: []kio.Writer{JSONWriter{Root: dir, Writer: b, Fields: fields}},
+	}.Execute()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, `{
+  "path": ".",
+  "package": true,
+  "resources": [
+    {
+      "file": "deploy.yaml",
+      "apiVersion": "apps/v1",
+      "kind": "Deployment",
+      "name": "app",
+      "fields": {
+        "spec.replicas": 3,
+        "spec.template.spec.containers[name=.*].image": [
+          "nginx:1.19",
+          "sidecar:1"
+        ]
+      }
+    }
+  ],
+  "directories": [
+    {
+      "path": "db",
+      "resources": [
+        {
+          "file": "db/cr.yaml",
+          "apiVersion": "example.com/v1",
+          "kind": "Database",
+          "name": "db",
+          "namespace": "data",
+          "fields": {
+            "spec.storage.size": "10Gi"
+          }
+        }
+      ]
+    }
+  ]
+}
+`, b.String())
+}
+
+// TestJSONWriter_stdin verifies that resources not read from a directory
+// are nested by the directory of their path annotation, if any.
+func TestJSONWriter_stdin(t *testing.T) {
+	b := &bytes.Buffer{}
+	err := kio.Pipeline{
+		Inputs: []kio.Reader{&kio.ByteReader{Reader: strings.NewReader(`apiVersion: v1
+kind: Service
+metadata:
+  name: web
+spec:
+  ports:
+  - port: 80
+`)}},
+		Outputs: []kio.Writer{JSONWriter{Writer: b, Fields: []Field{NewField("spec", "ports")}}},
+	}.Execute()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, `{
+  "path": ".",
+  "resources": [
+    {
+      "apiVersion": "v1",
+      "kind": "Service",
+      "name": "web",
+      "fields": {
+        "spec.ports": [
+          {
+            "port": 80
+          }
+        ]
+      }
+    }
+  ]
+}
+`, b.String())
+}
+
+func TestParseFields_invalid(t *testing.T) {
+	_, err := ParseFields([]string{"spec.a[b[c]"})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "unrecognized path element")
+	}
+}
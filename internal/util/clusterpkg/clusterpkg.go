@@ -0,0 +1,388 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package clusterpkg bootstraps packages from the resources in a cluster.
+package clusterpkg
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/GoogleContainerTools/kpt/pkg/kptfile"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
+	"sigs.k8s.io/kustomize/kyaml/errors"
+	"sigs.k8s.io/kustomize/kyaml/fieldmeta"
+	"sigs.k8s.io/kustomize/kyaml/kio/kioutil"
+	"sigs.k8s.io/kustomize/kyaml/openapi"
+	"sigs.k8s.io/kustomize/kyaml/setters2/settersutil"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// Reader reads the resources in a cluster which should be declared by a
+// package -- i.e. skipping the resources created by controllers and the
+// cluster itself.
+type Reader struct {
+	// Client reads the resources
+	Client dynamic.Interface
+
+	// Discovery lists the resource types in the cluster
+	Discovery discovery.DiscoveryInterface
+
+	// Namespaces are the namespaces to read namespaced resources from
+	Namespaces []string
+
+	// Selector is a label selector the resources must match.  If set,
+	// cluster-scoped resources matching it are also read.
+	Selector string
+}
+
+// skippedResources are the resources which are generated by the cluster or
+// by controllers and shouldn't be declared by a package.
+var skippedResources = map[schema.GroupResource]bool{
+	{Group: "", Resource: "events"}:                                        true,
+	{Group: "events.k8s.io", Resource: "events"}:                           true,
+	{Group: "", Resource: "endpoints"}:                                     true,
+	{Group: "discovery.k8s.io", Resource: "endpointslices"}:                true,
+	{Group: "apps", Resource: "controllerrevisions"}:                       true,
+	{Group: "coordination.k8s.io", Resource: "leases"}:                     true,
+	{Group: "", Resource: "componentstatuses"}:                             true,
+	{Group: "", Resource: "nodes"}:                                         true,
+	{Group: "metrics.k8s.io", Resource: "pods"}:                            true,
+	{Group: "metrics.k8s.io", Resource: "nodes"}:                           true,
+	{Group: "certificates.k8s.io", Resource: "certificatesigningrequests"}: true,
+}
+
+// Read returns the resources in the cluster.
+func (r Reader) Read(ctx context.Context) ([]*yaml.RNode, error) {
+	lists, err := discovery.ServerPreferredResources(r.Discovery)
+	if err != nil && !discovery.IsGroupDiscoveryFailedError(err) {
+		return nil, errors.WrapPrefixf(err, "failed to list resource types")
+	}
+
+	var nodes []*yaml.RNode
+	for _, list := range lists {
+		gv, err := schema.ParseGroupVersion(list.GroupVersion)
+		if err != nil {
+			continue
+		}
+		for _, res := range list.APIResources {
+			gvr := gv.WithResource(res.Name)
+			if strings.Contains(res.Name, "/") || !hasVerb(res, "list") ||
+				skippedResources[gvr.GroupResource()] {
+				continue
+			}
+
+			var objs []unstructured.Unstructured
+			switch {
+			case res.Namespaced:
+				for _, ns := range r.Namespaces {
+					l, err := r.Client.Resource(gvr).Namespace(ns).List(ctx,
+						metav1.ListOptions{LabelSelector: r.Selector})
+					if err != nil {
+						return nil, errors.WrapPrefixf(err, "failed to list %s", gvr.GroupResource())
+					}
+					objs = append(objs, l.Items...)
+				}
+			case gvr.GroupResource() == (schema.GroupResource{Resource: "namespaces"}):
+				// the selected namespaces are always declared by the package
+				for _, ns := range r.Namespaces {
+					o, err := r.Client.Resource(gvr).Get(ctx, ns, metav1.GetOptions{})
+					if err != nil {
+						return nil, errors.WrapPrefixf(err, "failed to get namespace %q", ns)
+					}
+					objs = append(objs, *o)
+				}
+			case r.Selector != "":
+				l, err := r.Client.Resource(gvr).List(ctx,
+					metav1.ListOptions{LabelSelector: r.Selector})
+				if err != nil {
+					return nil, errors.WrapPrefixf(err, "failed to list %s", gvr.GroupResource())
+				}
+				objs = append(objs, l.Items...)
+			}
+
+			for i := range objs {
+				if isGenerated(objs[i]) {
+					continue
+				}
+				n, err := yaml.FromMap(objs[i].Object)
+				if err != nil {
+					return nil, errors.Wrap(err)
+				}
+				nodes = append(nodes, n)
+			}
+		}
+	}
+	return nodes, nil
+}
+
+func hasVerb(res metav1.APIResource, verb string) bool {
+	for _, v := range res.Verbs {
+		if v == verb {
+			return true
+		}
+	}
+	return false
+}
+
+// isGenerated returns true if o was created by a controller or by the
+// cluster rather than by a user.
+func isGenerated(o unstructured.Unstructured) bool {
+	if len(o.GetOwnerReferences()) > 0 {
+		return true
+	}
+	switch o.GroupVersionKind().GroupKind() {
+	case schema.GroupKind{Kind: "ConfigMap"}:
+		return o.GetName() == "kube-root-ca.crt"
+	case schema.GroupKind{Kind: "ServiceAccount"}:
+		return o.GetName() == "default"
+	case schema.GroupKind{Kind: "Secret"}:
+		t, _, _ := unstructured.NestedString(o.Object, "type")
+		return t == "kubernetes.io/service-account-token"
+	}
+	return false
+}
+
+// serverFields are the fields which are set by the server and shouldn't be
+// declared by a package.
+var serverFields = [][]string{
+	{"status"},
+	{"metadata", "uid"},
+	{"metadata", "resourceVersion"},
+	{"metadata", "generation"},
+	{"metadata", "creationTimestamp"},
+	{"metadata", "deletionTimestamp"},
+	{"metadata", "deletionGracePeriodSeconds"},
+	{"metadata", "managedFields"},
+	{"metadata", "selfLink"},
+	{"metadata", "ownerReferences"},
+}
+
+// serverAnnotations are the annotations which are set by the server, by
+// controllers or by clients when applying resources.
+var serverAnnotations = []string{
+	"kubectl.kubernetes.io/last-applied-configuration",
+	"deployment.kubernetes.io/revision",
+	"config.k8s.io/owning-inventory",
+	"control-plane.alpha.kubernetes.io/leader",
+	"pv.kubernetes.io/bind-completed",
+	"pv.kubernetes.io/bound-by-controller",
+	"volume.beta.kubernetes.io/storage-provisioner",
+}
+
+// kindFields are the fields of specific kinds which are allocated by the
+// server.
+var kindFields = map[string][][]string{
+	"Namespace":             {{"spec"}},
+	"PersistentVolumeClaim": {{"spec", "volumeName"}},
+	"ServiceAccount":        {{"secrets"}},
+}
+
+// Scrub is a kio.Filter which removes the server-side fields from resources
+// read from a cluster.
+type Scrub struct{}
+
+func (Scrub) Filter(nodes []*yaml.RNode) ([]*yaml.RNode, error) {
+	for i := range nodes {
+		meta, err := nodes[i].GetMeta()
+		if err != nil {
+			return nil, err
+		}
+		fields := append(append([][]string{}, serverFields...), kindFields[meta.Kind]...)
+		if meta.Kind == "Service" {
+			// headless services must keep clusterIP: None
+			ip, err := nodes[i].Pipe(yaml.Lookup("spec", "clusterIP"))
+			if err != nil {
+				return nil, err
+			}
+			if yaml.GetValue(ip) != "None" {
+				fields = append(fields, []string{"spec", "clusterIP"}, []string{"spec", "clusterIPs"})
+			}
+		}
+		for _, f := range fields {
+			if err := nodes[i].PipeE(
+				yaml.Lookup(f[:len(f)-1]...), yaml.Clear(f[len(f)-1])); err != nil {
+				return nil, err
+			}
+		}
+		for _, a := range serverAnnotations {
+			if err := nodes[i].PipeE(yaml.ClearAnnotation(a)); err != nil {
+				return nil, err
+			}
+		}
+		// don't leave behind empty annotations
+		if err := nodes[i].PipeE(yaml.Lookup("metadata"), yaml.FieldClearer{
+			Name: "annotations", IfEmpty: true}); err != nil {
+			return nil, err
+		}
+	}
+	return nodes, nil
+}
+
+// Layout is a kio.Filter which sets the file each resource is written to --
+// namespaced resources are written to a directory per namespace.
+type Layout struct{}
+
+func (Layout) Filter(nodes []*yaml.RNode) ([]*yaml.RNode, error) {
+	for i := range nodes {
+		meta, err := nodes[i].GetMeta()
+		if err != nil {
+			return nil, err
+		}
+		file := fmt.Sprintf("%s_%s.yaml", meta.Name, strings.ToLower(meta.Kind))
+		if meta.Namespace != "" {
+			file = path.Join(meta.Namespace, file)
+		}
+		if err := nodes[i].PipeE(yaml.SetAnnotation(kioutil.PathAnnotation, file)); err != nil {
+			return nil, err
+		}
+		if err := nodes[i].PipeE(yaml.SetAnnotation(kioutil.IndexAnnotation, "0")); err != nil {
+			return nil, err
+		}
+	}
+	return nodes, nil
+}
+
+// Setter is a setter suggested for a package.
+type Setter struct {
+	// Name is the name of the setter
+	Name string
+
+	// FieldName is the field the setter applies to.  If empty, the setter
+	// applies to every field with Value.
+	FieldName string
+
+	// Value is the current value of the field
+	Value string
+}
+
+// CreateSetters adds the setters to the package in dir, writing a message to
+// w for each setter.
+func CreateSetters(w io.Writer, dir string, setters []Setter) error {
+	if len(setters) == 0 {
+		return nil
+	}
+	fieldmeta.SetShortHandRef("$kpt-set")
+	openAPIPath := filepath.Join(dir, kptfile.KptFileName)
+	for _, s := range setters {
+		sc, err := openapi.SchemaFromFile(openAPIPath)
+		if err != nil {
+			return err
+		}
+		err = settersutil.SetterCreator{
+			Name:            s.Name,
+			FieldName:       s.FieldName,
+			FieldValue:      s.Value,
+			Schema:          "{}",
+			OpenAPIFileName: kptfile.KptFileName,
+			OpenAPIPath:     openAPIPath,
+			ResourcesPath:   dir,
+			SettersSchema:   sc,
+		}.Create()
+		if err != nil {
+			return err
+		}
+		if s.FieldName == "" {
+			fmt.Fprintf(w, "created setter %q for %q\n", s.Name, s.Value)
+			continue
+		}
+		fmt.Fprintf(w, "created setter %q for %s %q\n", s.Name, s.FieldName, s.Value)
+	}
+	return nil
+}
+
+// SuggestSetters returns setters for the values which are likely to be
+// changed by the consumers of a package -- its namespace, if the resources
+// are all in one namespace, and its container images.
+func SuggestSetters(nodes []*yaml.RNode) ([]Setter, error) {
+	var setters []Setter
+	namespaces := map[string]bool{}
+	images := map[string]bool{}
+	for i := range nodes {
+		meta, err := nodes[i].GetMeta()
+		if err != nil {
+			return nil, err
+		}
+		if meta.Namespace != "" {
+			namespaces[meta.Namespace] = true
+		}
+		if err := walkImages(nodes[i], images); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(namespaces) == 1 {
+		for ns := range namespaces {
+			setters = append(setters, Setter{Name: "namespace", FieldName: "metadata.namespace", Value: ns})
+		}
+	}
+
+	names := map[string]bool{"namespace": true}
+	for _, image := range sortedKeys(images) {
+		name := imageSetterName(image)
+		for n := 2; names[name]; n++ {
+			name = fmt.Sprintf("%s-%d", imageSetterName(image), n)
+		}
+		names[name] = true
+		setters = append(setters, Setter{Name: name, FieldName: "image", Value: image})
+	}
+	return setters, nil
+}
+
+// walkImages adds the values of the image fields under n to images.
+func walkImages(n *yaml.RNode, images map[string]bool) error {
+	switch n.YNode().Kind {
+	case yaml.MappingNode:
+		return n.VisitFields(func(f *yaml.MapNode) error {
+			if f.Key.YNode().Value == "image" && f.Value.YNode().Kind == yaml.ScalarNode {
+				images[f.Value.YNode().Value] = true
+				return nil
+			}
+			return walkImages(f.Value, images)
+		})
+	case yaml.SequenceNode:
+		return n.VisitElements(func(e *yaml.RNode) error {
+			return walkImages(e, images)
+		})
+	}
+	return nil
+}
+
+// imageSetterName returns the setter name for image, e.g. nginx-image for
+// gcr.io/example/nginx:1.19.
+func imageSetterName(image string) string {
+	name := path.Base(image)
+	if i := strings.IndexAny(name, ":@"); i > 0 {
+		name = name[:i]
+	}
+	return name + "-image"
+}
+
+func sortedKeys(m map[string]bool) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,302 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package clusterpkg_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt/internal/testutil"
+	. "github.com/GoogleContainerTools/kpt/internal/util/clusterpkg"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+var clusterResources = []string{`
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: app
+  uid: 1234
+spec:
+  finalizers:
+  - kubernetes
+status:
+  phase: Active
+`, `
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: other
+`, `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: app
+  labels:
+    app: web
+  resourceVersion: "42"
+  annotations:
+    deployment.kubernetes.io/revision: "3"
+spec:
+  template:
+    spec:
+      containers:
+      - name: web
+        image: gcr.io/example/web:v1
+status:
+  replicas: 1
+`, `
+apiVersion: v1
+kind: Pod
+metadata:
+  name: web-abc
+  namespace: app
+  labels:
+    app: web
+  ownerReferences:
+  - apiVersion: apps/v1
+    kind: ReplicaSet
+    name: web-abc
+`, `
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+  namespace: app
+  labels:
+    app: web
+spec:
+  clusterIP: 10.0.0.1
+  clusterIPs:
+  - 10.0.0.1
+`, `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: kube-root-ca.crt
+  namespace: app
+`, `
+apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: default
+  namespace: app
+`, `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: other
+  namespace: other
+`, `
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: web
+  labels:
+    app: web
+`, `
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: admin
+`}
+
+func TestReader_Read(t *testing.T) {
+	var tests = []struct {
+		name       string
+		namespaces []string
+		selector   string
+		expected   []string
+	}{
+		{
+			name:       "namespace",
+			namespaces: []string{"app"},
+			expected: []string{
+				"Namespace/app", "Service/app/web", "Deployment/app/web",
+			},
+		},
+		{
+			name:       "selector",
+			namespaces: []string{"app"},
+			selector:   "app=web",
+			expected: []string{
+				"Namespace/app", "Service/app/web", "Deployment/app/web",
+				"ClusterRole/web",
+			},
+		},
+	}
+	for i := range tests {
+		test := tests[i]
+		t.Run(test.name, func(t *testing.T) {
+			client, dc := testutil.NewFakeCluster(t, clusterResources...)
+			nodes, err := Reader{
+				Client:     client,
+				Discovery:  dc,
+				Namespaces: test.namespaces,
+				Selector:   test.selector,
+			}.Read(context.Background())
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			var ids []string
+			for i := range nodes {
+				meta, err := nodes[i].GetMeta()
+				if !assert.NoError(t, err) {
+					t.FailNow()
+				}
+				ids = append(ids, strings.Join(nonEmpty(meta.Kind, meta.Namespace, meta.Name), "/"))
+			}
+			assert.ElementsMatch(t, test.expected, ids)
+		})
+	}
+}
+
+func TestScrub_Filter(t *testing.T) {
+	nodes := parse(t, clusterResources[0], clusterResources[2], clusterResources[4])
+	nodes, err := Scrub{}.Filter(nodes)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, `apiVersion: v1
+kind: Namespace
+metadata:
+  name: app
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: app
+  labels:
+    app: web
+spec:
+  template:
+    spec:
+      containers:
+      - name: web
+        image: gcr.io/example/web:v1
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+  namespace: app
+  labels:
+    app: web
+spec: {}
+`, toString(t, nodes))
+}
+
+func TestScrub_Filter_headlessService(t *testing.T) {
+	nodes := parse(t, `
+apiVersion: v1
+kind: Service
+metadata:
+  name: db
+spec:
+  clusterIP: None
+`)
+	nodes, err := Scrub{}.Filter(nodes)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Contains(t, toString(t, nodes), "clusterIP: None")
+}
+
+func TestLayout_Filter(t *testing.T) {
+	nodes := parse(t, clusterResources[0], clusterResources[2])
+	nodes, err := Layout{}.Filter(nodes)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	for i, expected := range []string{"app_namespace.yaml", "app/web_deployment.yaml"} {
+		p, err := nodes[i].Pipe(yaml.GetAnnotation("config.kubernetes.io/path"))
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		assert.Equal(t, expected, yaml.GetValue(p))
+	}
+}
+
+func TestSuggestSetters(t *testing.T) {
+	nodes := parse(t, clusterResources[2], clusterResources[4], `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: worker
+  namespace: app
+spec:
+  template:
+    spec:
+      initContainers:
+      - name: init
+        image: busybox
+      containers:
+      - name: worker
+        image: gcr.io/other/web@sha256:abcd
+`)
+	setters, err := SuggestSetters(nodes)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, []Setter{
+		{Name: "namespace", FieldName: "metadata.namespace", Value: "app"},
+		{Name: "busybox-image", FieldName: "image", Value: "busybox"},
+		{Name: "web-image", FieldName: "image", Value: "gcr.io/example/web:v1"},
+		{Name: "web-image-2", FieldName: "image", Value: "gcr.io/other/web@sha256:abcd"},
+	}, setters)
+
+	// resources in multiple namespaces don't get a namespace setter
+	setters, err = SuggestSetters(parse(t, clusterResources[4], clusterResources[7]))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Empty(t, setters)
+}
+
+func nonEmpty(values ...string) []string {
+	var out []string
+	for _, v := range values {
+		if v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func parse(t *testing.T, resources ...string) []*yaml.RNode {
+	var nodes []*yaml.RNode
+	for _, r := range resources {
+		n, err := yaml.Parse(r)
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		nodes = append(nodes, n)
+	}
+	return nodes
+}
+
+func toString(t *testing.T, nodes []*yaml.RNode) string {
+	var b strings.Builder
+	err := kio.ByteWriter{Writer: &b}.Write(nodes)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	return b.String()
+}
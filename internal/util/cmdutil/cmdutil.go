@@ -15,9 +15,12 @@
 package cmdutil
 
 import (
+	"context"
 	"fmt"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
 
 	"github.com/go-errors/errors"
 	"github.com/spf13/cobra"
@@ -28,6 +31,25 @@ const (
 	trueString         = "true"
 )
 
+// SignalContext returns a copy of parent which is cancelled when the process
+// is interrupted or terminated, so that commands can stop their subprocesses
+// and clean up before exiting.  Once cancelled, signals are handled as usual
+// again -- e.g. a second Ctrl-C exits immediately.
+func SignalContext(parent context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(parent)
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		select {
+		case <-sigs:
+		case <-ctx.Done():
+		}
+		signal.Stop(sigs)
+		cancel()
+	}()
+	return ctx, cancel
+}
+
 // FixDocs replaces instances of old with new in the docs for c
 func FixDocs(old, new string, c *cobra.Command) {
 	c.Use = strings.ReplaceAll(c.Use, old, new)
@@ -55,3 +77,15 @@ var K8sSchemaSource string
 // K8sSchemaPath defines the path to the openAPI schema if we are reading from
 // a file
 var K8sSchemaPath string
+
+// Concurrency if set is the number of operations of each kind, e.g. git
+// fetches, kpt runs at once.  0 uses the configured limits.
+var Concurrency int
+
+// ContainerRuntime if set is the runtime function containers are run with,
+// e.g. podman.  Empty uses $KPT_CONTAINER_RUNTIME or the runtime installed.
+var ContainerRuntime string
+
+// Version is the version of kpt, e.g. recorded in the provenance of
+// published packages.
+var Version = "unknown"
@@ -0,0 +1,159 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package concurrency contains the limits on the number of operations kpt
+// runs at once.
+package concurrency
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"sync"
+
+	"sigs.k8s.io/kustomize/kyaml/errors"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// ConfigEnv is the name of the environment variable containing the path to
+// the kpt config file.  Defaults to UserHomeDir/.kpt/config.yaml.
+const ConfigEnv = "KPT_CONFIG"
+
+// ConcurrencyEnv is the name of the environment variable that sets every
+// limit, e.g. to run operations one at a time on constrained CI runners.
+const ConcurrencyEnv = "KPT_CONCURRENCY"
+
+// Limits are the maximum number of operations of each kind run at once.
+type Limits struct {
+	// GitFetch is the number of packages fetched from git at once
+	GitFetch int `yaml:"gitFetch,omitempty"`
+
+	// Functions is the number of packages whose functions run at once
+	Functions int `yaml:"functions,omitempty"`
+}
+
+// config is the kpt config file
+type config struct {
+	Concurrency struct {
+		// All sets every limit, and is overridden by the individual limits
+		All int `yaml:"all,omitempty"`
+
+		Limits `yaml:",inline"`
+	} `yaml:"concurrency,omitempty"`
+}
+
+// Defaults returns the default limits for the host.  Fetching is mostly
+// waiting on the network, so more packages are fetched at once than there
+// are CPUs.
+func Defaults() Limits {
+	n := runtime.NumCPU()
+	return Limits{GitFetch: 2 * n, Functions: n}
+}
+
+// All returns limits which allow n operations of each kind at once.
+func All(n int) Limits {
+	return Limits{GitFetch: n, Functions: n}
+}
+
+// Load returns the limits from, in increasing precedence, the defaults, the
+// config file, KPT_CONCURRENCY and global -- the value of the --concurrency
+// flag, or 0 if it isn't set.
+func Load(global int) (Limits, error) {
+	l := Defaults()
+
+	c, err := readConfig()
+	if err != nil {
+		return l, err
+	}
+	if c.Concurrency.All > 0 {
+		l = All(c.Concurrency.All)
+	}
+	if c.Concurrency.GitFetch > 0 {
+		l.GitFetch = c.Concurrency.GitFetch
+	}
+	if c.Concurrency.Functions > 0 {
+		l.Functions = c.Concurrency.Functions
+	}
+
+	if v := os.Getenv(ConcurrencyEnv); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return l, errors.Errorf("invalid %s %q: must be a positive integer", ConcurrencyEnv, v)
+		}
+		l = All(n)
+	}
+
+	if global < 0 {
+		return l, errors.Errorf("invalid --concurrency %d: must be a positive integer", global)
+	}
+	if global > 0 {
+		l = All(global)
+	}
+	return l, nil
+}
+
+// readConfig reads the kpt config file, if it exists.
+func readConfig() (config, error) {
+	c := config{}
+	path := os.Getenv(ConfigEnv)
+	if path == "" {
+		dir, err := os.UserHomeDir()
+		if err != nil {
+			// no home directory to read the config from
+			return c, nil
+		}
+		path = filepath.Join(dir, ".kpt", "config.yaml")
+	}
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return c, errors.Wrap(err)
+	}
+	if err := yaml.Unmarshal(b, &c); err != nil {
+		return c, errors.WrapPrefixf(err, "failed to parse kpt config %q", path)
+	}
+	if c.Concurrency.All < 0 || c.Concurrency.GitFetch < 0 || c.Concurrency.Functions < 0 {
+		return c, errors.Errorf("invalid kpt config %q: concurrency limits must be positive", path)
+	}
+	return c, nil
+}
+
+// ForEach calls f with each index in [0, n), running at most limit calls at
+// once, and returns the error returned by each call.  A limit below 1 runs
+// the calls one at a time.
+func ForEach(limit, n int, f func(i int) error) []error {
+	if limit < 1 {
+		limit = 1
+	}
+	errs := make([]error, n)
+	sem := make(chan struct{}, limit)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(i int) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			errs[i] = f(i)
+		}(i)
+	}
+	wg.Wait()
+	return errs
+}
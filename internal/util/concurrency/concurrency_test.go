@@ -0,0 +1,173 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package concurrency_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	. "github.com/GoogleContainerTools/kpt/internal/util/concurrency"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoad(t *testing.T) {
+	cpus := runtime.NumCPU()
+	tests := []struct {
+		name     string
+		config   string
+		env      string
+		global   int
+		expected Limits
+		err      string
+	}{
+		{
+			name:     "defaults",
+			expected: Limits{GitFetch: 2 * cpus, Functions: cpus},
+		},
+		{
+			name: "config all",
+			config: `
+concurrency:
+  all: 3
+`,
+			expected: Limits{GitFetch: 3, Functions: 3},
+		},
+		{
+			name: "config per limit",
+			config: `
+concurrency:
+  all: 3
+  gitFetch: 5
+`,
+			expected: Limits{GitFetch: 5, Functions: 3},
+		},
+		{
+			name: "env overrides config",
+			config: `
+concurrency:
+  gitFetch: 5
+`,
+			env:      "2",
+			expected: Limits{GitFetch: 2, Functions: 2},
+		},
+		{
+			name:     "global overrides env",
+			env:      "2",
+			global:   1,
+			expected: Limits{GitFetch: 1, Functions: 1},
+		},
+		{
+			name: "invalid env",
+			env:  "lots",
+			err:  `invalid KPT_CONCURRENCY "lots": must be a positive integer`,
+		},
+		{
+			name:   "invalid global",
+			global: -1,
+			err:    "invalid --concurrency -1: must be a positive integer",
+		},
+		{
+			name: "invalid config",
+			config: `
+concurrency:
+  functions: -1
+`,
+			err: "concurrency limits must be positive",
+		},
+	}
+	for i := range tests {
+		test := tests[i]
+		t.Run(test.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "kpt-concurrency-test")
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			defer os.RemoveAll(dir)
+
+			config := filepath.Join(dir, "config.yaml")
+			if test.config != "" {
+				err := ioutil.WriteFile(config, []byte(test.config), 0600)
+				if !assert.NoError(t, err) {
+					t.FailNow()
+				}
+			}
+			defer setEnv(t, ConfigEnv, config)()
+			defer setEnv(t, ConcurrencyEnv, test.env)()
+
+			l, err := Load(test.global)
+			if test.err != "" {
+				if assert.Error(t, err) {
+					assert.Contains(t, err.Error(), test.err)
+				}
+				return
+			}
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			assert.Equal(t, test.expected, l)
+		})
+	}
+}
+
+// setEnv sets the environment variable and returns the function to restore it.
+func setEnv(t *testing.T, key, value string) func() {
+	old, found := os.LookupEnv(key)
+	assert.NoError(t, os.Setenv(key, value))
+	return func() {
+		if found {
+			_ = os.Setenv(key, old)
+		} else {
+			_ = os.Unsetenv(key)
+		}
+	}
+}
+
+func TestForEach(t *testing.T) {
+	var mu sync.Mutex
+	var running, max int
+	errs := ForEach(2, 6, func(i int) error {
+		mu.Lock()
+		running++
+		if running > max {
+			max = running
+		}
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+		running--
+		mu.Unlock()
+		if i == 3 {
+			return fmt.Errorf("failed %d", i)
+		}
+		return nil
+	})
+
+	assert.LessOrEqual(t, max, 2)
+	if assert.Len(t, errs, 6) {
+		for i, err := range errs {
+			if i == 3 {
+				assert.EqualError(t, err, "failed 3")
+			} else {
+				assert.NoError(t, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,143 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package conditions includes or excludes the resources of packages by the
+// conditions declared in their Kptfiles.
+package conditions
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/GoogleContainerTools/kpt/pkg/kptfile"
+	"github.com/GoogleContainerTools/kpt/pkg/kptfile/kptfileutil"
+	"sigs.k8s.io/kustomize/kyaml/errors"
+	"sigs.k8s.io/kustomize/kyaml/kio/kioutil"
+	"sigs.k8s.io/kustomize/kyaml/pathutil"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// Annotation guards a resource by a condition, e.g.
+//
+//	config.kpt.dev/condition: enable-monitoring
+//
+// The resource is only included when the condition is true, or when it is
+// false if the condition is negated with a leading !.
+const Annotation = "config.kpt.dev/condition"
+
+// Conditions are the conditions declared by the packages under a directory.
+type Conditions struct {
+	// packages are the conditions of each package, by its path relative to
+	// the directory
+	packages map[string]map[string]bool
+}
+
+// Read reads the conditions of the packages under root.
+func Read(root string) (*Conditions, error) {
+	dirs, err := pathutil.DirsWithFile(root, kptfile.KptFileName, true)
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	c := &Conditions{packages: map[string]map[string]bool{}}
+	for _, d := range dirs {
+		k, err := kptfileutil.ReadFile(d)
+		if err != nil {
+			return nil, err
+		}
+		rel, err := filepath.Rel(root, d)
+		if err != nil {
+			return nil, errors.Wrap(err)
+		}
+		c.packages[filepath.ToSlash(rel)] = k.Conditions
+	}
+	return c, nil
+}
+
+// Included returns true if the resource in file, relative to the directory
+// the conditions were read from, is included by the guard of its
+// Annotation.  The condition is declared by the package of the file or a
+// package containing it, the closest one first.
+func (c *Conditions) Included(file, guard string) (bool, error) {
+	name := strings.TrimPrefix(guard, "!")
+	negated := name != guard
+	if name == "" {
+		return false, errors.Errorf("%s %q of the resource in %s has no condition", Annotation, guard, file)
+	}
+	for dir := path.Dir(filepath.ToSlash(file)); ; dir = path.Dir(dir) {
+		if value, found := c.packages[dir][name]; found {
+			return value != negated, nil
+		}
+		if dir == "." || dir == "/" {
+			break
+		}
+	}
+	return false, errors.Errorf("the resource in %s is guarded by condition %q, "+
+		"which isn't declared in the Kptfile of its package or a package containing it", file, name)
+}
+
+// Filter splits nodes into the resources which are included and excluded
+// by their conditions.  Resources without the Annotation are included.
+func (c *Conditions) Filter(nodes []*yaml.RNode) (included, excluded []*yaml.RNode, err error) {
+	for _, n := range nodes {
+		meta, err := n.GetMeta()
+		if err != nil {
+			return nil, nil, errors.Wrap(err)
+		}
+		guard, found := meta.Annotations[Annotation]
+		if !found {
+			included = append(included, n)
+			continue
+		}
+		file, _, err := kioutil.GetFileAnnotations(n)
+		if err != nil {
+			return nil, nil, errors.Wrap(err)
+		}
+		ok, err := c.Included(file, guard)
+		if err != nil {
+			return nil, nil, err
+		}
+		if ok {
+			included = append(included, n)
+		} else {
+			excluded = append(excluded, n)
+		}
+	}
+	return included, excluded, nil
+}
+
+// Set sets the condition name declared by the Kptfile of the package at
+// dir to value.
+func Set(dir, name string, value bool) error {
+	p := filepath.Join(dir, kptfile.KptFileName)
+	kf, err := yaml.ReadFile(p)
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	field, err := kf.Pipe(yaml.Lookup("conditions", name))
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	if field == nil {
+		return errors.Errorf("condition %q is not declared in %s", name, p)
+	}
+	s := "false"
+	if value {
+		s = "true"
+	}
+	field.YNode().Value = s
+	field.YNode().Tag = yaml.NodeTagBool
+	field.YNode().Style = 0
+	return errors.Wrap(yaml.WriteFile(kf, p))
+}
@@ -0,0 +1,166 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package conditions_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt/internal/testutil/pkgbuilder"
+	"github.com/GoogleContainerTools/kpt/internal/util/conditions"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+func TestConditions_Filter(t *testing.T) {
+	dir := pkgbuilder.WritePackage(t, map[string]string{
+		"Kptfile": `apiVersion: kpt.dev/v1alpha1
+kind: Kptfile
+metadata:
+  name: app
+conditions:
+  enable-monitoring: true
+  enable-tls: false
+`,
+		"resources.yaml": `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: always
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: monitoring
+  annotations:
+    config.kpt.dev/condition: enable-monitoring
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: tls
+  annotations:
+    config.kpt.dev/condition: enable-tls
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: no-tls
+  annotations:
+    config.kpt.dev/condition: '!enable-tls'
+`,
+		"db/Kptfile": `apiVersion: kpt.dev/v1alpha1
+kind: Kptfile
+metadata:
+  name: db
+conditions:
+  enable-monitoring: false
+`,
+		"db/resources.yaml": `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: db-monitoring
+  annotations:
+    config.kpt.dev/condition: enable-monitoring
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: db-tls
+  annotations:
+    config.kpt.dev/condition: enable-tls
+`,
+	})
+	defer os.RemoveAll(dir)
+
+	conds, err := conditions.Read(dir)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	nodes, err := (&kio.LocalPackageReader{PackagePath: dir, IncludeSubpackages: true}).Read()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	included, excluded, err := conds.Filter(nodes)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	names := func(nodes []*yaml.RNode) []string {
+		var names []string
+		for _, n := range nodes {
+			names = append(names, n.GetName())
+		}
+		return names
+	}
+	// the subpackage overrides the condition of its parent
+	assert.ElementsMatch(t, []string{"always", "monitoring", "no-tls"}, names(included))
+	assert.ElementsMatch(t, []string{"tls", "db-monitoring", "db-tls"}, names(excluded))
+}
+
+func TestConditions_Included_undeclared(t *testing.T) {
+	dir := pkgbuilder.WritePackage(t, map[string]string{
+		"Kptfile": `apiVersion: kpt.dev/v1alpha1
+kind: Kptfile
+metadata:
+  name: app
+`,
+	})
+	defer os.RemoveAll(dir)
+
+	conds, err := conditions.Read(dir)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	_, err = conds.Included("deploy.yaml", "enable-monitoring")
+	assert.EqualError(t, err, `the resource in deploy.yaml is guarded by condition "enable-monitoring", `+
+		"which isn't declared in the Kptfile of its package or a package containing it")
+	_, err = conds.Included("deploy.yaml", "!")
+	assert.EqualError(t, err, `config.kpt.dev/condition "!" of the resource in deploy.yaml has no condition`)
+}
+
+func TestSet(t *testing.T) {
+	dir := pkgbuilder.WritePackage(t, map[string]string{
+		"Kptfile": `apiVersion: kpt.dev/v1alpha1
+kind: Kptfile
+metadata:
+  name: app
+conditions:
+  # deploy the ServiceMonitor
+  enable-monitoring: false
+`,
+	})
+	defer os.RemoveAll(dir)
+
+	if !assert.NoError(t, conditions.Set(dir, "enable-monitoring", true)) {
+		t.FailNow()
+	}
+	b, err := ioutil.ReadFile(filepath.Join(dir, "Kptfile"))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, `apiVersion: kpt.dev/v1alpha1
+kind: Kptfile
+metadata:
+  name: app
+conditions:
+  # deploy the ServiceMonitor
+  enable-monitoring: true
+`, string(b))
+
+	err = conditions.Set(dir, "enable-tls", true)
+	assert.EqualError(t, err, `condition "enable-tls" is not declared in `+filepath.Join(dir, "Kptfile"))
+}
@@ -0,0 +1,231 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package count counts resources grouped by dimensions such as kind,
+// namespace or label, for inventories and dashboards.
+package count
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/GoogleContainerTools/kpt/internal/util/pipe"
+	"sigs.k8s.io/kustomize/kyaml/errors"
+	"sigs.k8s.io/kustomize/kyaml/kio/kioutil"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// Output formats.
+const (
+	TableOutput = "table"
+	JSONOutput  = "json"
+	CSVOutput   = "csv"
+)
+
+// Dimensions resources may be grouped by, besides labels.
+const (
+	Kind       = "kind"
+	APIVersion = "apiVersion"
+	Namespace  = "namespace"
+	File       = "file"
+)
+
+// labelPrefix prefixes the dimensions grouping resources by a label.
+const labelPrefix = "label:"
+
+// ValidateGroupBy returns an error if any of groupBy isn't a dimension
+// resources may be grouped by: kind, apiVersion, namespace, file, or
+// label:KEY for the value of the label KEY.
+func ValidateGroupBy(groupBy []string) error {
+	for _, d := range groupBy {
+		switch {
+		case d == Kind, d == APIVersion, d == Namespace, d == File:
+		case strings.HasPrefix(d, labelPrefix) && len(d) > len(labelPrefix):
+		default:
+			return errors.Errorf("unsupported group-by %q, must be one of %s, %s, %s, %s or %sKEY",
+				d, Kind, APIVersion, Namespace, File, labelPrefix)
+		}
+	}
+	return nil
+}
+
+// Group is the number of resources with the same values of the dimensions.
+type Group struct {
+	// Values are the values of the dimensions, in the order of the
+	// dimensions.  Values are empty for resources without a namespace or
+	// label.
+	Values []string
+
+	// Count is the number of resources
+	Count int
+}
+
+// Report is the number of resources in each group.
+type Report struct {
+	// GroupBy are the dimensions the resources are grouped by.
+	GroupBy []string
+
+	// Groups are the groups, sorted by their values.
+	Groups []Group
+
+	// Total is the number of resources.
+	Total int
+}
+
+// Count groups nodes by the dimensions groupBy, and counts them.
+func Count(nodes []*yaml.RNode, groupBy []string) (Report, error) {
+	if err := ValidateGroupBy(groupBy); err != nil {
+		return Report{}, err
+	}
+	report := Report{GroupBy: groupBy, Total: len(nodes)}
+	counts := map[string]*Group{}
+	for _, node := range nodes {
+		m, err := node.GetMeta()
+		if err != nil {
+			return Report{}, err
+		}
+		var values []string
+		for _, d := range groupBy {
+			v, err := value(node, m, d)
+			if err != nil {
+				return Report{}, err
+			}
+			values = append(values, v)
+		}
+		key := strings.Join(values, "\x00")
+		g, found := counts[key]
+		if !found {
+			g = &Group{Values: values}
+			counts[key] = g
+		}
+		g.Count++
+	}
+	for _, g := range counts {
+		report.Groups = append(report.Groups, *g)
+	}
+	sort.Slice(report.Groups, func(i, j int) bool {
+		a, b := report.Groups[i].Values, report.Groups[j].Values
+		for k := range a {
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+		return false
+	})
+	return report, nil
+}
+
+// value returns the value of the dimension d of node.
+func value(node *yaml.RNode, m yaml.ResourceMeta, d string) (string, error) {
+	switch d {
+	case Kind:
+		return m.Kind, nil
+	case APIVersion:
+		return m.APIVersion, nil
+	case Namespace:
+		return m.Namespace, nil
+	case File:
+		if m.Annotations[pipe.SynthesizedPathAnnotation] == "true" {
+			return "", nil
+		}
+		file, _, err := kioutil.GetFileAnnotations(node)
+		return file, err
+	default:
+		return m.Labels[strings.TrimPrefix(d, labelPrefix)], nil
+	}
+}
+
+// Write writes report to w in the format output.
+func Write(w io.Writer, output string, report Report) error {
+	switch output {
+	case "", TableOutput:
+		return WriteTable(w, report)
+	case JSONOutput:
+		return WriteJSON(w, report)
+	case CSVOutput:
+		return WriteCSV(w, report)
+	}
+	return errors.Errorf("unsupported output %q, must be one of %s, %s, %s",
+		output, TableOutput, JSONOutput, CSVOutput)
+}
+
+// WriteTable writes report as a table, with a column for each dimension
+// and a row for each group, followed by the total.
+func WriteTable(w io.Writer, report Report) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	var header []string
+	for _, d := range report.GroupBy {
+		header = append(header, strings.ToUpper(d))
+	}
+	fmt.Fprintln(tw, strings.Join(append(header, "COUNT"), "\t"))
+	for _, g := range report.Groups {
+		var row []string
+		for _, v := range g.Values {
+			if v == "" {
+				v = "<none>"
+			}
+			row = append(row, v)
+		}
+		fmt.Fprintln(tw, strings.Join(append(row, fmt.Sprint(g.Count)), "\t"))
+	}
+	if len(report.GroupBy) > 0 {
+		fmt.Fprintf(tw, "TOTAL%s\t%d\n", strings.Repeat("\t", len(report.GroupBy)-1), report.Total)
+	}
+	return errors.Wrap(tw.Flush())
+}
+
+// WriteJSON writes report as json, with an object for each group keyed by
+// the dimensions.
+func WriteJSON(w io.Writer, report Report) error {
+	groups := []map[string]interface{}{}
+	for _, g := range report.Groups {
+		group := map[string]interface{}{"count": g.Count}
+		for i, d := range report.GroupBy {
+			group[d] = g.Values[i]
+		}
+		groups = append(groups, group)
+	}
+	groupBy := report.GroupBy
+	if groupBy == nil {
+		groupBy = []string{}
+	}
+	e := json.NewEncoder(w)
+	e.SetIndent("", "  ")
+	return errors.Wrap(e.Encode(struct {
+		GroupBy []string                 `json:"groupBy"`
+		Groups  []map[string]interface{} `json:"groups"`
+		Total   int                      `json:"total"`
+	}{groupBy, groups, report.Total}))
+}
+
+// WriteCSV writes report as csv, with a header naming the dimensions and a
+// record for each group.
+func WriteCSV(w io.Writer, report Report) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(append(append([]string{}, report.GroupBy...), "count")); err != nil {
+		return errors.Wrap(err)
+	}
+	for _, g := range report.Groups {
+		if err := cw.Write(append(append([]string{}, g.Values...), fmt.Sprint(g.Count))); err != nil {
+			return errors.Wrap(err)
+		}
+	}
+	cw.Flush()
+	return errors.Wrap(cw.Error())
+}
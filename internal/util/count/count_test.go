@@ -0,0 +1,164 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package count_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	. "github.com/GoogleContainerTools/kpt/internal/util/count"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+const resources = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: prod
+  labels:
+    app: web
+  annotations:
+    config.kubernetes.io/path: web.yaml
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+  namespace: prod
+  labels:
+    app: web
+  annotations:
+    config.kubernetes.io/path: web.yaml
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: db
+  namespace: prod
+  annotations:
+    config.kubernetes.io/path: db.yaml
+---
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: prod
+  annotations:
+    config.kubernetes.io/path: ns.yaml
+`
+
+func TestWrite(t *testing.T) {
+	var tests = []struct {
+		name     string
+		groupBy  []string
+		output   string
+		expected string
+	}{
+		{
+			name:    "table",
+			groupBy: []string{"kind", "namespace"},
+			expected: `KIND        NAMESPACE  COUNT
+Deployment  prod       2
+Namespace   <none>     1
+Service     prod       1
+TOTAL                  4
+`,
+		},
+		{
+			name:    "label",
+			groupBy: []string{"label:app"},
+			output:  TableOutput,
+			expected: `LABEL:APP  COUNT
+<none>     2
+web        2
+TOTAL      4
+`,
+		},
+		{
+			name:     "total",
+			output:   TableOutput,
+			expected: "COUNT\n4\n",
+		},
+		{
+			name:    "json",
+			groupBy: []string{"apiVersion"},
+			output:  JSONOutput,
+			expected: `{
+  "groupBy": [
+    "apiVersion"
+  ],
+  "groups": [
+    {
+      "apiVersion": "apps/v1",
+      "count": 2
+    },
+    {
+      "apiVersion": "v1",
+      "count": 2
+    }
+  ],
+  "total": 4
+}
+`,
+		},
+		{
+			name:    "csv",
+			groupBy: []string{"file", "kind"},
+			output:  CSVOutput,
+			expected: `file,kind,count
+db.yaml,Deployment,1
+ns.yaml,Namespace,1
+web.yaml,Deployment,1
+web.yaml,Service,1
+`,
+		},
+	}
+	for i := range tests {
+		test := tests[i]
+		t.Run(test.name, func(t *testing.T) {
+			var report Report
+			err := kio.Pipeline{
+				Inputs: []kio.Reader{&kio.ByteReader{Reader: strings.NewReader(resources)}},
+				Outputs: []kio.Writer{kio.WriterFunc(func(nodes []*yaml.RNode) error {
+					var err error
+					report, err = Count(nodes, test.groupBy)
+					return err
+				})},
+			}.Execute()
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			b := &bytes.Buffer{}
+			if !assert.NoError(t, Write(b, test.output, report)) {
+				t.FailNow()
+			}
+			assert.Equal(t, test.expected, b.String())
+		})
+	}
+}
+
+func TestValidateGroupBy(t *testing.T) {
+	assert.NoError(t, ValidateGroupBy([]string{"kind", "apiVersion", "namespace", "file", "label:app"}))
+	err := ValidateGroupBy([]string{"label:"})
+	if assert.Error(t, err) {
+		assert.Equal(t, `unsupported group-by "label:", must be one of kind, apiVersion, namespace, file or label:KEY`, err.Error())
+	}
+	err = Write(&bytes.Buffer{}, "xml", Report{})
+	if assert.Error(t, err) {
+		assert.Equal(t, `unsupported output "xml", must be one of table, json, csv`, err.Error())
+	}
+}
@@ -16,14 +16,24 @@
 package desc
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/GoogleContainerTools/kpt/pkg/kptfile"
 	"github.com/GoogleContainerTools/kpt/pkg/kptfile/kptfileutil"
 	"github.com/olekukonko/tablewriter"
+	"sigs.k8s.io/kustomize/kyaml/errors"
+)
+
+// Output formats
+const (
+	TableOutput    = "table"
+	JSONOutput     = "json"
+	MarkdownOutput = "markdown"
 )
 
 // Command prints information about the given packages.
@@ -35,12 +45,22 @@ type Command struct {
 	PkgPaths []string
 
 	PrintBasePath bool
+
+	// Output is the format the packages are described in -- table, json or
+	// markdown.  Defaults to table.
+	Output string
 }
 
 // Run prints information about given packages in a tabular format.
 // A directory containing KptFile is considered to be a valid package.
 // Invalid packages are ignored.
 func (c Command) Run() error {
+	switch c.Output {
+	case "", TableOutput, JSONOutput, MarkdownOutput:
+	default:
+		return errors.Errorf("unsupported output %q, must be one of %s, %s, %s",
+			c.Output, TableOutput, JSONOutput, MarkdownOutput)
+	}
 	var pkgs []pkgInfo
 	for _, p := range c.PkgPaths {
 		err := filepath.Walk(p, func(path string, info os.FileInfo, err error) error {
@@ -55,7 +75,6 @@ func (c Command) Run() error {
 				return nil
 			}
 			path = filepath.Clean(path)
-			fmt.Println(path)
 			pkgs = append(pkgs, pkgInfo{localDir: path, KptFile: kptFile})
 			return nil
 		})
@@ -64,7 +83,15 @@ func (c Command) Run() error {
 		}
 	}
 
+	switch c.Output {
+	case JSONOutput:
+		return c.printJSON(c.GetStdOut(), pkgs)
+	case MarkdownOutput:
+		c.printMarkdown(c.GetStdOut(), pkgs)
+		return nil
+	}
 	c.printPkgs(c.GetStdOut(), pkgs)
+	c.printMetadata(c.GetStdOut(), pkgs)
 	return nil
 }
 
@@ -88,13 +115,9 @@ func (c Command) printPkgs(w io.Writer, pkgs []pkgInfo) {
 		"Package Name", "Dir", "Remote",
 		"Remote Path", "Remote Ref", "Remote Commit"})
 	for _, pkg := range pkgs {
-		p := filepath.Dir(pkg.localDir)
-		if c.PrintBasePath {
-			p = filepath.Base(p)
-		}
 		table.Append([]string{
 			pkg.Name,
-			p,
+			c.dir(pkg),
 			pkg.Upstream.Git.Repo,
 			pkg.Upstream.Git.Directory,
 			pkg.Upstream.Git.Ref,
@@ -104,6 +127,205 @@ func (c Command) printPkgs(w io.Writer, pkgs []pkgInfo) {
 	table.Render()
 }
 
+// printMetadata prints a table of the metadata of the packages which have
+// any, after the table of packages.
+func (c Command) printMetadata(w io.Writer, pkgs []pkgInfo) {
+	var described []pkgInfo
+	for _, pkg := range pkgs {
+		if hasMetadata(pkg.PackageMeta) {
+			described = append(described, pkg)
+		}
+	}
+	if len(described) == 0 {
+		return
+	}
+	fmt.Fprintln(w)
+	table := tablewriter.NewWriter(w)
+	table.SetRowLine(false)
+	table.SetBorder(false)
+	table.SetHeaderLine(false)
+	table.SetColumnSeparator(" ")
+	table.SetCenterSeparator(" ")
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	table.SetAutoWrapText(false)
+	table.SetHeader([]string{
+		"Package Name", "Version", "License", "Maintainers", "Keywords",
+		"Min Kpt", "Min Kubernetes", "Changelog"})
+	for _, pkg := range described {
+		m := pkg.PackageMeta
+		table.Append([]string{
+			pkg.Name,
+			m.Version,
+			m.License,
+			strings.Join(maintainers(m.Maintainers), ", "),
+			strings.Join(m.Keywords, ","),
+			m.MinKptVersion,
+			m.MinKubernetesVersion,
+			m.ChangelogURL,
+		})
+	}
+	table.Render()
+}
+
+// hasMetadata returns true if m has any of the metadata printed by
+// printMetadata.
+func hasMetadata(m kptfile.PackageMeta) bool {
+	return m.Version != "" || m.License != "" || len(m.Maintainers) > 0 ||
+		len(m.Keywords) > 0 || m.MinKptVersion != "" || m.MinKubernetesVersion != "" ||
+		m.ChangelogURL != ""
+}
+
+// maintainers returns the maintainers formatted as "name <email>".
+func maintainers(ms []kptfile.Maintainer) []string {
+	var names []string
+	for _, m := range ms {
+		switch {
+		case m.Name != "" && m.Email != "":
+			names = append(names, fmt.Sprintf("%s <%s>", m.Name, m.Email))
+		case m.Name != "":
+			names = append(names, m.Name)
+		case m.Email != "":
+			names = append(names, m.Email)
+		default:
+			names = append(names, m.URL)
+		}
+	}
+	return names
+}
+
+// Description is the description of a package printed as json.
+type Description struct {
+	Name                 string       `json:"name"`
+	Dir                  string       `json:"dir"`
+	ShortDescription     string       `json:"shortDescription,omitempty"`
+	Version              string       `json:"version,omitempty"`
+	URL                  string       `json:"url,omitempty"`
+	Email                string       `json:"email,omitempty"`
+	License              string       `json:"license,omitempty"`
+	Maintainers          []Maintainer `json:"maintainers,omitempty"`
+	Keywords             []string     `json:"keywords,omitempty"`
+	Tags                 []string     `json:"tags,omitempty"`
+	ChangelogURL         string       `json:"changelogURL,omitempty"`
+	MinKptVersion        string       `json:"minKptVersion,omitempty"`
+	MinKubernetesVersion string       `json:"minKubernetesVersion,omitempty"`
+	Upstream             *Upstream    `json:"upstream,omitempty"`
+}
+
+// Maintainer is a maintainer of a package printed as json.
+type Maintainer struct {
+	Name  string `json:"name,omitempty"`
+	Email string `json:"email,omitempty"`
+	URL   string `json:"url,omitempty"`
+}
+
+// Upstream is the upstream of a package printed as json.
+type Upstream struct {
+	Repo      string `json:"repo,omitempty"`
+	Directory string `json:"directory,omitempty"`
+	Ref       string `json:"ref,omitempty"`
+	Commit    string `json:"commit,omitempty"`
+}
+
+// describe returns the description of pkg.
+func (c Command) describe(pkg pkgInfo) Description {
+	m := pkg.PackageMeta
+	d := Description{
+		Name:                 pkg.Name,
+		Dir:                  c.dir(pkg),
+		ShortDescription:     m.ShortDescription,
+		Version:              m.Version,
+		URL:                  m.URL,
+		Email:                m.Email,
+		License:              m.License,
+		Keywords:             m.Keywords,
+		Tags:                 m.Tags,
+		ChangelogURL:         m.ChangelogURL,
+		MinKptVersion:        m.MinKptVersion,
+		MinKubernetesVersion: m.MinKubernetesVersion,
+	}
+	for _, mt := range m.Maintainers {
+		d.Maintainers = append(d.Maintainers, Maintainer(mt))
+	}
+	if g := pkg.Upstream.Git; g.Repo != "" {
+		d.Upstream = &Upstream{Repo: g.Repo, Directory: g.Directory, Ref: g.Ref, Commit: g.Commit}
+	}
+	return d
+}
+
+// printJSON prints the descriptions of the packages as a json list.
+func (c Command) printJSON(w io.Writer, pkgs []pkgInfo) error {
+	descs := []Description{}
+	for _, pkg := range pkgs {
+		descs = append(descs, c.describe(pkg))
+	}
+	e := json.NewEncoder(w)
+	e.SetIndent("", "  ")
+	return errors.Wrap(e.Encode(descs))
+}
+
+// printMarkdown prints a section for each package, with a table of the
+// metadata it has.
+func (c Command) printMarkdown(w io.Writer, pkgs []pkgInfo) {
+	for i, pkg := range pkgs {
+		d := c.describe(pkg)
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "## %s\n\n", d.Name)
+		if d.ShortDescription != "" {
+			fmt.Fprintf(w, "%s\n\n", d.ShortDescription)
+		}
+		fmt.Fprintln(w, "| Field | Value |")
+		fmt.Fprintln(w, "| --- | --- |")
+		rows := [][2]string{
+			{"Dir", d.Dir},
+			{"Version", d.Version},
+			{"License", d.License},
+			{"Maintainers", strings.Join(maintainers(pkg.PackageMeta.Maintainers), ", ")},
+			{"Keywords", strings.Join(d.Keywords, ", ")},
+			{"Min kpt version", d.MinKptVersion},
+			{"Min Kubernetes version", d.MinKubernetesVersion},
+			{"Homepage", markdownLink(d.URL)},
+			{"Changelog", markdownLink(d.ChangelogURL)},
+		}
+		if d.Upstream != nil {
+			rows = append(rows,
+				[2]string{"Upstream", d.Upstream.Repo},
+				[2]string{"Upstream path", d.Upstream.Directory},
+				[2]string{"Upstream ref", d.Upstream.Ref},
+				[2]string{"Upstream commit", shortSHA(d.Upstream.Commit)})
+		}
+		for _, r := range rows {
+			if r[1] != "" {
+				fmt.Fprintf(w, "| %s | %s |\n", r[0], markdownEscape(r[1]))
+			}
+		}
+	}
+}
+
+// markdownLink returns url as a markdown link, or "" if it is empty.
+func markdownLink(url string) string {
+	if url == "" {
+		return ""
+	}
+	return fmt.Sprintf("[%s](%s)", url, url)
+}
+
+// markdownEscape escapes the pipes of s, which would end the table cell.
+func markdownEscape(s string) string {
+	return strings.ReplaceAll(s, "|", "\\|")
+}
+
+// dir returns the directory of pkg, or its base name if PrintBasePath is
+// set.
+func (c Command) dir(pkg pkgInfo) string {
+	p := filepath.Dir(pkg.localDir)
+	if c.PrintBasePath {
+		p = filepath.Base(p)
+	}
+	return p
+}
+
 // shortSHA returns short form (first 7 letters) of the commit SHA.
 func shortSHA(sha string) string {
 	if len(sha) > 7 {
@@ -27,6 +27,8 @@ import (
 
 	"github.com/GoogleContainerTools/kpt/internal/gitutil"
 	"github.com/GoogleContainerTools/kpt/internal/util/get"
+	"github.com/GoogleContainerTools/kpt/internal/util/tmputil"
+	"github.com/GoogleContainerTools/kpt/internal/util/update"
 	"github.com/GoogleContainerTools/kpt/pkg/kptfile"
 	"github.com/GoogleContainerTools/kpt/pkg/kptfile/kptfileutil"
 	"sigs.k8s.io/kustomize/kyaml/copyutil"
@@ -45,6 +47,10 @@ const (
 	DiffTypeCombined DiffType = "combined"
 	// 3way shows changes in local and remote changes side-by-side
 	DiffType3Way DiffType = "3way"
+	// DiffTypePreview shows the local changes, the upstream changes between
+	// original and target version, and the changes updating the local pkg to
+	// the target version would make
+	DiffTypePreview DiffType = "preview"
 )
 
 // A collection of user-readable "source" definitions for diffed packages.
@@ -55,6 +61,8 @@ const (
 	remotePackageSource string = "remote"
 	// targetRemotePackageSource represents the targeted remote version of a package
 	targetRemotePackageSource string = "target"
+	// mergedPackageSource represents the local package updated to the target version
+	mergedPackageSource string = "merged"
 )
 
 const (
@@ -70,7 +78,8 @@ func (dt DiffType) String() string {
 	return string(dt)
 }
 
-var SupportedDiffTypes = []DiffType{DiffTypeLocal, DiffTypeRemote, DiffTypeCombined, DiffType3Way}
+var SupportedDiffTypes = []DiffType{DiffTypeLocal, DiffTypeRemote, DiffTypeCombined, DiffType3Way,
+	DiffTypePreview}
 
 func SupportedDiffTypesLabel() string {
 	var labels []string
@@ -122,7 +131,7 @@ func (c *Command) Run() error {
 	}
 
 	// Create a staging directory to store all compared packages
-	stagingDirectory, err := ioutil.TempDir("", "kpt-")
+	stagingDirectory, err := tmputil.TempDir("kpt-diff-")
 	if err != nil {
 		return errors.Errorf("failed to create stage dir: %v", err)
 	}
@@ -172,7 +181,8 @@ func (c *Command) Run() error {
 
 	if c.DiffType == DiffTypeRemote ||
 		c.DiffType == DiffTypeCombined ||
-		c.DiffType == DiffType3Way {
+		c.DiffType == DiffType3Way ||
+		c.DiffType == DiffTypePreview {
 		// get the upstream pkg at the target version
 		upstreamTargetPkgName := NameStagingDirectory(targetRemotePackageSource,
 			c.Ref,
@@ -201,14 +211,61 @@ func (c *Command) Run() error {
 		return c.PkgDiffer.Diff(currPkg, upstreamTargetPkg)
 	case DiffType3Way:
 		return c.PkgDiffer.Diff(currPkg, upstreamPkg, upstreamTargetPkg)
+	case DiffTypePreview:
+		return c.preview(stagingDirectory, kptFile, currPkg, upstreamPkg, upstreamTargetPkg)
 	default:
 		return errors.Errorf("unsupported diff type '%s'", c.DiffType)
 	}
 }
 
+// preview shows the local changes, the upstream changes and the changes
+// updating the local package to the target version would make.  The update
+// is performed on a staged copy of the local package.
+func (c *Command) preview(stagingDirectory string, kptFile kptfile.KptFile,
+	currPkg, upstreamPkg, upstreamTargetPkg string) error {
+	mergedPkg, err := stageDirectory(stagingDirectory,
+		NameStagingDirectory(mergedPackageSource, c.Ref, c.Ref))
+	if err != nil {
+		return errors.Errorf("failed to create stage dir for merged package: %v", err)
+	}
+	if err := copyutil.CopyDir(c.Path, mergedPkg); err != nil {
+		return errors.Errorf("failed to stage merged package: %v", err)
+	}
+	err = update.ResourceMergeUpdater{}.Update(update.UpdateOptions{
+		KptFile:        kptFile,
+		ToRef:          c.Ref,
+		ToRepo:         kptFile.Upstream.Git.Repo,
+		PackagePath:    mergedPkg,
+		AbsPackagePath: mergedPkg,
+		Output:         ioutil.Discard,
+	})
+	if err != nil {
+		return errors.Errorf("failed to merge the package: %v", err)
+	}
+
+	panes := []struct {
+		title string
+		pkgs  []string
+	}{
+		{fmt.Sprintf("local changes since %s", shortSha(kptFile.Upstream.Git.Commit)),
+			[]string{currPkg, upstreamPkg}},
+		{fmt.Sprintf("upstream changes from %s to %s", shortSha(kptFile.Upstream.Git.Commit), c.Ref),
+			[]string{upstreamPkg, upstreamTargetPkg}},
+		{fmt.Sprintf("merged result of updating to %s", c.Ref),
+			[]string{currPkg, mergedPkg}},
+	}
+	for _, p := range panes {
+		fmt.Fprintf(c.Output, "=== %s ===\n", p.title)
+		if err := c.PkgDiffer.Diff(p.pkgs...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (c *Command) Validate() error {
 	switch c.DiffType {
-	case DiffTypeLocal, DiffTypeCombined, DiffTypeRemote, DiffType3Way:
+	case DiffTypeLocal, DiffTypeCombined, DiffTypeRemote, DiffType3Way, DiffTypePreview:
 	default:
 		return errors.Errorf("invalid diff-type '%s'. Supported diff-types are: %s",
 			c.DiffType, SupportedDiffTypesLabel())
@@ -301,8 +358,8 @@ func (d *defaultPkgDiffer) Diff(pkgs ...string) error {
 	return err
 }
 
-// prepareForDiff removes metadata such as .git and Kptfile from a staged package
-// to exclude them from diffing.
+// prepareForDiff removes metadata such as .git, Kptfile and setter histories
+// from a staged package to exclude them from diffing.
 func (d *defaultPkgDiffer) prepareForDiff(dir string) error {
 	excludePaths := []string{".git", kptfile.KptFileName}
 	for _, path := range excludePaths {
@@ -311,7 +368,15 @@ func (d *defaultPkgDiffer) prepareForDiff(dir string) error {
 			return err
 		}
 	}
-	return nil
+	return filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && info.Name() == kptfile.HistoryFileName {
+			return os.Remove(path)
+		}
+		return nil
+	})
 }
 
 // PkgGetter knows how to fetch a package given a git repo, path and ref.
@@ -18,6 +18,7 @@ package diff_test
 import (
 	"bufio"
 	"bytes"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
@@ -29,6 +30,7 @@ import (
 	. "github.com/GoogleContainerTools/kpt/internal/util/diff"
 	"github.com/GoogleContainerTools/kpt/internal/util/get"
 	"github.com/GoogleContainerTools/kpt/pkg/kptfile"
+	"github.com/GoogleContainerTools/kpt/pkg/kptfile/kptfileutil"
 	"github.com/stretchr/testify/assert"
 	"sigs.k8s.io/kustomize/kyaml/copyutil"
 )
@@ -248,6 +250,95 @@ func TestCommand_Run_LocalDiff(t *testing.T) {
 	assert.Equal(t, string(expOut), filteredOutput)
 }
 
+// recordingDiffer records the packages it is asked to diff.
+type recordingDiffer struct {
+	t     *testing.T
+	calls [][]string
+}
+
+func (d *recordingDiffer) Diff(pkgs ...string) error {
+	var names []string
+	for _, pkg := range pkgs {
+		names = append(names, filepath.Base(pkg))
+	}
+	d.calls = append(d.calls, names)
+	if strings.HasPrefix(names[len(names)-1], "merged-") {
+		// the merged package keeps local changes and is updated to the target
+		merged := pkgs[len(pkgs)-1]
+		k, err := kptfileutil.ReadFile(merged)
+		if assert.NoError(d.t, err) {
+			assert.Equal(d.t, "master", k.Upstream.Git.Ref)
+		}
+		assert.FileExists(d.t, filepath.Join(merged, "local.yaml"))
+	}
+	return nil
+}
+
+// TestCommand_Run_PreviewDiff verifies Command shows the local, upstream and
+// merged changes for preview diff without modifying the local package.
+func TestCommand_Run_PreviewDiff(t *testing.T) {
+	g, w, clean := testutil.SetupDefaultRepoAndWorkspace(t, testutil.Dataset1)
+	defer clean()
+
+	commit, err := g.GetCommit()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	if !assert.NoError(t, g.Tag("v1")) {
+		t.FailNow()
+	}
+	err = g.ReplaceData(testutil.Dataset2)
+	assert.NoError(t, err)
+	err = g.Commit("new-data for v2")
+	assert.NoError(t, err)
+
+	err = get.Command{Git: kptfile.Git{
+		Repo: g.RepoDirectory, Ref: "refs/tags/v1", Directory: "/"},
+		Destination: filepath.Base(g.RepoDirectory)}.Run()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	localPkg := filepath.Join(w.WorkspaceDirectory, g.RepoName)
+	err = ioutil.WriteFile(filepath.Join(localPkg, "local.yaml"), []byte(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: local
+`), 0600)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	differ := &recordingDiffer{t: t}
+	output := &bytes.Buffer{}
+	err = (&Command{
+		Path:      localPkg,
+		Ref:       "master",
+		DiffType:  DiffTypePreview,
+		Output:    output,
+		PkgDiffer: differ,
+	}).Run()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	local := NameStagingDirectory("local", "v1", commit)
+	remote := NameStagingDirectory("remote", "v1", commit)
+	assert.Equal(t, [][]string{
+		{local, remote},
+		{remote, "target-master"},
+		{local, "merged-master"},
+	}, differ.calls)
+	assert.Equal(t, fmt.Sprintf(`=== local changes since %s ===
+=== upstream changes from %s to master ===
+=== merged result of updating to master ===
+`, commit[:7], commit[:7]), output.String())
+
+	k, err := kptfileutil.ReadFile(localPkg)
+	if assert.NoError(t, err) {
+		assert.Equal(t, "refs/tags/v1", k.Upstream.Git.Ref)
+	}
+}
+
 // filterDiffMetadata removes information from the diff output that is test-run
 // specific for ex. removing directory name being used.
 func filterDiffMetadata(r io.Reader) string {
@@ -0,0 +1,120 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package digest hashes the contents of packages.
+package digest
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/GoogleContainerTools/kpt/pkg/kptfile"
+	"sigs.k8s.io/kustomize/kyaml/errors"
+)
+
+// Dir returns a sha256 hash, hex encoded, of the paths and contents of the
+// files under dir.  .git directories are skipped.
+func Dir(dir string) (string, error) {
+	var paths []string
+	err := filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && info.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		if !info.IsDir() {
+			paths = append(paths, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", errors.Wrap(err)
+	}
+	sort.Strings(paths)
+	h := sha256.New()
+	for _, p := range paths {
+		b, err := ioutil.ReadFile(p)
+		if err != nil {
+			return "", errors.Wrap(err)
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return "", errors.Wrap(err)
+		}
+		fmt.Fprintf(h, "%s\x00%d\x00", filepath.ToSlash(rel), len(b))
+		h.Write(b)
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+// Files returns the sha256 hash, hex encoded, of each file under dir keyed by
+// its slash separated path relative to dir.  .git directories, the Kptfile
+// of dir, setter histories and the directories in exclude, relative to dir,
+// are skipped.
+func Files(dir string, exclude ...string) (map[string]string, error) {
+	skip := map[string]bool{}
+	for _, e := range exclude {
+		skip[filepath.ToSlash(filepath.Clean(e))] = true
+	}
+	files := map[string]string{}
+	err := filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if info.IsDir() {
+			if info.Name() == ".git" || skip[rel] {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if rel == kptfile.KptFileName || info.Name() == kptfile.HistoryFileName {
+			return nil
+		}
+		b, err := ioutil.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		files[rel] = fmt.Sprintf("%x", sha256.Sum256(b))
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	return files, nil
+}
+
+// Sum returns the digest of the files returned by Files, prefixed with the
+// hash algorithm.
+func Sum(files map[string]string) string {
+	var paths []string
+	for p := range files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	h := sha256.New()
+	for _, p := range paths {
+		fmt.Fprintf(h, "%s\x00%s\n", p, files[p])
+	}
+	return fmt.Sprintf("sha256:%x", h.Sum(nil))
+}
@@ -0,0 +1,605 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package doctor diagnoses problems with the environment kpt runs in, and
+// with the packages under a directory.
+package doctor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/GoogleContainerTools/kpt/internal/gitutil"
+	"github.com/GoogleContainerTools/kpt/internal/util/validate"
+	"github.com/GoogleContainerTools/kpt/pkg/kptfile"
+	"github.com/GoogleContainerTools/kpt/pkg/kptfile/kptfileutil"
+	"github.com/GoogleContainerTools/kpt/pkg/live"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/cli-utils/pkg/common"
+	"sigs.k8s.io/kustomize/kyaml/errors"
+	"sigs.k8s.io/kustomize/kyaml/pathutil"
+)
+
+// Output formats.
+const (
+	TableOutput = "table"
+	JSONOutput  = "json"
+)
+
+// Statuses.
+const (
+	OK      = "ok"
+	Warning = "warning"
+	Failed  = "failed"
+	Skipped = "skipped"
+)
+
+// Checks.
+const (
+	GitCheck                  = "git"
+	ContainerRuntimeCheck     = "container-runtime"
+	GitConnectivityCheck      = "git-connectivity"
+	RegistryConnectivityCheck = "registry-connectivity"
+	GitCredentialsCheck       = "git-credentials"
+	RegistryCredentialsCheck  = "registry-credentials"
+	KptfileCheck              = "kptfile"
+	UpstreamCheck             = "upstream"
+	InventoryCheck            = "inventory"
+)
+
+// Defaults.
+const (
+	// DefaultRegistry is the registry function images are pulled from
+	DefaultRegistry = "gcr.io"
+
+	// DefaultGitURL is the repository git connectivity is checked against
+	DefaultGitURL = "https://github.com/GoogleContainerTools/kpt.git"
+
+	// DefaultTimeout is the time each network check may take
+	DefaultTimeout = 10 * time.Second
+)
+
+// commitPattern matches refs which are commits rather than branches or tags.
+var commitPattern = regexp.MustCompile(`^[0-9a-f]{7,40}$`)
+
+// Result is the result of a check.
+type Result struct {
+	// Check is the name of the check
+	Check string `json:"check"`
+
+	// Path is the package the result is for, relative to the checked
+	// directory.  Empty for environment checks.
+	Path string `json:"path,omitempty"`
+
+	// Status is OK, Warning, Failed or Skipped
+	Status string `json:"status"`
+
+	// Message describes the result
+	Message string `json:"message"`
+
+	// Fix suggests how to resolve a warning or failure
+	Fix string `json:"fix,omitempty"`
+}
+
+// Command diagnoses the environment and the packages under Dir.
+type Command struct {
+	// Dir is the directory to check the packages under
+	Dir string
+
+	// Offline skips the checks which need the network or a cluster
+	Offline bool
+
+	// Registry is the container registry to check connectivity and
+	// credentials for.  Defaults to DefaultRegistry.
+	Registry string
+
+	// GitURL is the repository to check git connectivity against.
+	// Defaults to DefaultGitURL.
+	GitURL string
+
+	// Timeout is the time each network check may take.  Defaults to
+	// DefaultTimeout.
+	Timeout time.Duration
+
+	// KubeClient returns the client the inventories are checked with.  The
+	// inventory checks are skipped if it is nil.
+	KubeClient func() (kubernetes.Interface, error)
+
+	// ResourceGroup is true if inventories are stored as ResourceGroups
+	// rather than ConfigMaps
+	ResourceGroup bool
+
+	// ContainerRuntime is the container runtime functions are run with,
+	// e.g. podman.  If empty the first runtime installed is checked.
+	ContainerRuntime string
+
+	// Output is TableOutput or JSONOutput
+	Output string
+
+	StdOut io.Writer
+}
+
+// Run runs the checks and writes the report, returning an error if any
+// check failed.
+func (c Command) Run() error {
+	if c.StdOut == nil {
+		c.StdOut = os.Stdout
+	}
+	results := c.Diagnose()
+	var err error
+	switch c.Output {
+	case "", TableOutput:
+		err = WriteTable(c.StdOut, results)
+	case JSONOutput:
+		e := json.NewEncoder(c.StdOut)
+		e.SetIndent("", "  ")
+		err = e.Encode(results)
+	default:
+		return errors.Errorf("unknown output format %q", c.Output)
+	}
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	failed := 0
+	for _, r := range results {
+		if r.Status == Failed {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return errors.Errorf("%d check(s) failed", failed)
+	}
+	return nil
+}
+
+// Diagnose runs the environment checks, then the checks of the packages
+// under Dir.
+func (c Command) Diagnose() []Result {
+	if c.Dir == "" {
+		c.Dir = "."
+	}
+	if c.Registry == "" {
+		c.Registry = DefaultRegistry
+	}
+	if c.GitURL == "" {
+		c.GitURL = DefaultGitURL
+	}
+	if c.Timeout == 0 {
+		c.Timeout = DefaultTimeout
+	}
+	results := []Result{
+		c.checkGit(),
+		c.checkContainerRuntime(),
+		c.checkGitConnectivity(),
+		c.checkRegistryConnectivity(),
+		c.checkGitCredentials(),
+		c.checkRegistryCredentials(),
+	}
+	return append(results, c.checkPackages()...)
+}
+
+// run runs a command, returning its trimmed output, and stderr if it fails.
+func (c Command) run(name string, args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.Timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	cmd.Stdout, cmd.Stderr = stdout, stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return "", errors.Errorf("timed out after %s", c.Timeout)
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", errors.Errorf("%s", firstLine(msg))
+		}
+		return "", err
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+func firstLine(s string) string {
+	return strings.SplitN(s, "\n", 2)[0]
+}
+
+func (c Command) checkGit() Result {
+	r := Result{Check: GitCheck}
+	if _, err := exec.LookPath("git"); err != nil {
+		r.Status, r.Message = Failed, "git is not installed"
+		r.Fix = "install git and add it to the PATH"
+		return r
+	}
+	out, err := c.run("git", "version")
+	if err != nil {
+		r.Status, r.Message = Failed, fmt.Sprintf("git version failed: %v", err)
+		r.Fix = "reinstall git"
+		return r
+	}
+	r.Status, r.Message = OK, out
+	return r
+}
+
+func (c Command) checkContainerRuntime() Result {
+	r := Result{Check: ContainerRuntimeCheck}
+	if c.ContainerRuntime == "kubernetes" {
+		r.Status, r.Message = Skipped, "functions are run as Jobs in the cluster"
+		return r
+	}
+	runtimes := []struct{ name, format string }{
+		{"docker", "{{.ServerVersion}}"},
+		{"podman", "{{.Version.Version}}"},
+		{"nerdctl", "{{.ServerVersion}}"},
+	}
+	for _, rt := range runtimes {
+		if c.ContainerRuntime != "" && c.ContainerRuntime != rt.name {
+			continue
+		}
+		if _, err := exec.LookPath(rt.name); err != nil {
+			if c.ContainerRuntime != "" {
+				r.Status, r.Message = Failed, fmt.Sprintf("%s is not installed", rt.name)
+				r.Fix = fmt.Sprintf("install %s, or choose another runtime with --container-runtime", rt.name)
+				return r
+			}
+			continue
+		}
+		out, err := c.run(rt.name, "info", "--format", rt.format)
+		if err != nil {
+			r.Status = Warning
+			r.Message = fmt.Sprintf("%s is installed but not running: %v", rt.name, err)
+			r.Fix = fmt.Sprintf("start %s, and check the user may run it", rt.name)
+			return r
+		}
+		r.Status, r.Message = OK, fmt.Sprintf("%s %s", rt.name, out)
+		return r
+	}
+	if c.ContainerRuntime != "" {
+		r.Status, r.Message = Failed, fmt.Sprintf("unsupported container runtime %q", c.ContainerRuntime)
+		r.Fix = "choose docker, podman, nerdctl or kubernetes with --container-runtime"
+		return r
+	}
+	r.Status, r.Message = Warning, "none of docker, podman and nerdctl is installed"
+	r.Fix = "install docker, podman or nerdctl to run functions"
+	return r
+}
+
+func (c Command) checkGitConnectivity() Result {
+	r := Result{Check: GitConnectivityCheck}
+	if c.Offline {
+		r.Status, r.Message = Skipped, "offline"
+		return r
+	}
+	if _, err := c.run("git", "ls-remote", "--heads", c.GitURL); err != nil {
+		r.Status, r.Message = Failed, fmt.Sprintf("unable to reach %s: %v", c.GitURL, err)
+		r.Fix = "check network access to the repository, and the HTTPS_PROXY " +
+			"environment variable if a proxy is required"
+		return r
+	}
+	r.Status, r.Message = OK, fmt.Sprintf("reached %s", c.GitURL)
+	return r
+}
+
+// registryURL returns the url of the registry API.
+func (c Command) registryURL() string {
+	if strings.Contains(c.Registry, "://") {
+		return strings.TrimSuffix(c.Registry, "/") + "/v2/"
+	}
+	return "https://" + c.Registry + "/v2/"
+}
+
+// registryHost returns the host of the registry.
+func (c Command) registryHost() string {
+	h := c.Registry
+	if i := strings.Index(h, "://"); i >= 0 {
+		h = h[i+3:]
+	}
+	return strings.SplitN(h, "/", 2)[0]
+}
+
+func (c Command) checkRegistryConnectivity() Result {
+	r := Result{Check: RegistryConnectivityCheck}
+	if c.Offline {
+		r.Status, r.Message = Skipped, "offline"
+		return r
+	}
+	client := &http.Client{Timeout: c.Timeout}
+	resp, err := client.Get(c.registryURL())
+	if err != nil {
+		r.Status, r.Message = Failed, fmt.Sprintf("unable to reach %s: %v", c.Registry, err)
+		r.Fix = "check network access to the registry, and the HTTPS_PROXY " +
+			"environment variable if a proxy is required"
+		return r
+	}
+	resp.Body.Close()
+	// the registry requiring authentication still means it is reachable
+	r.Status = OK
+	r.Message = fmt.Sprintf("reached %s (HTTP %d)", c.Registry, resp.StatusCode)
+	return r
+}
+
+func (c Command) checkGitCredentials() Result {
+	r := Result{Check: GitCredentialsCheck}
+	var sources []string
+	if out, err := c.run("git", "config", "--get-all", "credential.helper"); err == nil && out != "" {
+		sources = append(sources, "credential helper "+strings.Join(strings.Fields(out), ", "))
+	}
+	if os.Getenv("SSH_AUTH_SOCK") != "" {
+		sources = append(sources, "ssh agent")
+	}
+	if os.Getenv("GIT_ASKPASS") != "" {
+		sources = append(sources, "GIT_ASKPASS")
+	}
+	if len(sources) == 0 {
+		r.Status = Warning
+		r.Message = "no git credential helper or ssh agent is configured, private repositories can't be fetched"
+		r.Fix = "configure a credential helper, e.g. `git config --global credential.helper cache`, " +
+			"or add a key to ssh-agent"
+		return r
+	}
+	r.Status, r.Message = OK, "using "+strings.Join(sources, ", ")
+	return r
+}
+
+// dockerConfig is the part of the docker config file which configures
+// registry credentials.
+type dockerConfig struct {
+	Auths       map[string]interface{} `json:"auths"`
+	CredHelpers map[string]string      `json:"credHelpers"`
+	CredsStore  string                 `json:"credsStore"`
+}
+
+func (c Command) checkRegistryCredentials() Result {
+	r := Result{Check: RegistryCredentialsCheck}
+	dir := os.Getenv("DOCKER_CONFIG")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			r.Status, r.Message = Warning, fmt.Sprintf("unable to find the home directory: %v", err)
+			return r
+		}
+		dir = filepath.Join(home, ".docker")
+	}
+	file := filepath.Join(dir, "config.json")
+	fix := fmt.Sprintf("run `docker login %s`, or `gcloud auth configure-docker` for gcr.io, "+
+		"if images are pulled from a private registry", c.registryHost())
+
+	b, err := os.Open(file)
+	if os.IsNotExist(err) {
+		r.Status, r.Message, r.Fix = Warning, fmt.Sprintf("%s doesn't exist", file), fix
+		return r
+	}
+	if err != nil {
+		r.Status, r.Message = Warning, fmt.Sprintf("unable to read %s: %v", file, err)
+		return r
+	}
+	defer b.Close()
+	var config dockerConfig
+	if err := json.NewDecoder(b).Decode(&config); err != nil {
+		r.Status, r.Message = Failed, fmt.Sprintf("unable to parse %s: %v", file, err)
+		r.Fix = "fix or remove the file"
+		return r
+	}
+	host := c.registryHost()
+	switch {
+	case config.CredHelpers[host] != "":
+		r.Message = fmt.Sprintf("credential helper %s for %s", config.CredHelpers[host], host)
+	case config.Auths[host] != nil || config.Auths["https://"+host] != nil:
+		r.Message = fmt.Sprintf("credentials for %s", host)
+	case config.CredsStore != "":
+		r.Message = fmt.Sprintf("credential store %s", config.CredsStore)
+	default:
+		r.Status, r.Message, r.Fix = Warning, fmt.Sprintf("no credentials for %s", host), fix
+		return r
+	}
+	r.Status = OK
+	return r
+}
+
+// checkPackages checks the Kptfiles, upstreams and inventories of the
+// packages under Dir.
+func (c Command) checkPackages() []Result {
+	paths, err := pathutil.DirsWithFile(c.Dir, kptfile.KptFileName, true)
+	if err != nil {
+		return []Result{{Check: KptfileCheck, Status: Failed,
+			Message: fmt.Sprintf("unable to read %s: %v", c.Dir, err)}}
+	}
+	if len(paths) == 0 {
+		return []Result{{Check: KptfileCheck, Status: Skipped,
+			Message: fmt.Sprintf("no packages found under %s", c.Dir)}}
+	}
+
+	results := c.checkKptfiles(len(paths))
+	var upstreams, inventories []Result
+	for _, p := range paths {
+		rel, err := filepath.Rel(c.Dir, p)
+		if err != nil {
+			continue
+		}
+		k, err := kptfileutil.ReadFile(p)
+		if err != nil {
+			// reported by the Kptfile checks
+			continue
+		}
+		if k.Upstream.Git.Repo != "" {
+			upstreams = append(upstreams, c.checkUpstream(p, filepath.ToSlash(rel), k.Upstream.Git))
+		}
+		if k.Inventory != nil {
+			inventories = append(inventories, c.checkInventory(filepath.ToSlash(rel), *k.Inventory))
+		}
+	}
+	results = append(results, upstreams...)
+	return append(results, inventories...)
+}
+
+// checkKptfiles reports the Kptfile and upstream problems found by
+// validate.
+func (c Command) checkKptfiles(packages int) []Result {
+	findings, err := validate.Validate(c.Dir)
+	if err != nil {
+		return []Result{{Check: KptfileCheck, Status: Failed, Message: err.Error()}}
+	}
+	var results []Result
+	for _, f := range findings {
+		if f.Check != validate.KptfileCheck && f.Check != validate.UpstreamCheck {
+			continue
+		}
+		status := Warning
+		if f.Severity == validate.Error {
+			status = Failed
+		}
+		results = append(results, Result{Check: KptfileCheck, Path: f.Location(),
+			Status: status, Message: f.Message,
+			Fix: "fix the Kptfile, see `kpt pkg validate`"})
+	}
+	if len(results) == 0 {
+		results = append(results, Result{Check: KptfileCheck, Status: OK,
+			Message: fmt.Sprintf("%d Kptfile(s) are valid", packages)})
+	}
+	return results
+}
+
+// checkUpstream checks the ref of the upstream of the package at dir still
+// exists.
+func (c Command) checkUpstream(dir, rel string, g kptfile.Git) Result {
+	r := Result{Check: UpstreamCheck, Path: rel}
+	if c.Offline {
+		r.Status, r.Message = Skipped, "offline"
+		return r
+	}
+	if gitutil.IsRelativeRepo(g.Repo) {
+		var err error
+		g.Repo, g.Directory, err = gitutil.ResolveRelativeRepo(dir, g.Repo)
+		if err != nil {
+			r.Status, r.Message = Failed, err.Error()
+			r.Fix = "fix upstream.git.repo in the Kptfile"
+			return r
+		}
+	}
+	ref := strings.TrimPrefix(strings.TrimPrefix(g.Ref, "refs/heads/"), "refs/tags/")
+	if ref == "" || commitPattern.MatchString(ref) {
+		r.Status, r.Message = OK, fmt.Sprintf("pinned to commit %s of %s", g.Commit, g.Repo)
+		return r
+	}
+	resolved, found, err := gitutil.ResolveRef(g.Repo, g.Directory, g.TagTemplate, ref, nil)
+	if err != nil {
+		r.Status, r.Message = Failed, fmt.Sprintf("unable to reach %s: %v", g.Repo, err)
+		r.Fix = "check the repository exists and the git credentials can read it"
+		return r
+	}
+	if !found {
+		r.Status = Failed
+		r.Message = fmt.Sprintf("ref %s no longer exists in %s", g.Ref, g.Repo)
+		r.Fix = "update the package to an existing ref with `kpt pkg update " + rel + "@REF`"
+		return r
+	}
+	r.Status, r.Message = OK, fmt.Sprintf("%s of %s", resolved, g.Repo)
+	return r
+}
+
+// checkInventory checks the cluster the inventory is applied to is
+// reachable and can hold it.
+func (c Command) checkInventory(rel string, inv kptfile.Inventory) Result {
+	r := Result{Check: InventoryCheck, Path: rel}
+	if c.Offline || c.KubeClient == nil {
+		r.Status, r.Message = Skipped, "no cluster to check"
+		return r
+	}
+	client, err := c.KubeClient()
+	if err != nil {
+		r.Status, r.Message = Failed, fmt.Sprintf("unable to load the kubeconfig: %v", err)
+		r.Fix = "check KUBECONFIG and the current context with `kubectl config current-context`"
+		return r
+	}
+	if _, err := client.Discovery().ServerVersion(); err != nil {
+		r.Status, r.Message = Failed, fmt.Sprintf("cluster unreachable: %v", err)
+		r.Fix = "check the cluster is running and the credentials of the current context are valid"
+		return r
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), c.Timeout)
+	defer cancel()
+	if _, err := client.CoreV1().Namespaces().Get(ctx, inv.Namespace, metav1.GetOptions{}); err != nil {
+		r.Status = Failed
+		r.Message = fmt.Sprintf("inventory namespace %s: %v", inv.Namespace, err)
+		r.Fix = fmt.Sprintf("create the namespace with `kubectl create namespace %s`", inv.Namespace)
+		return r
+	}
+	if !c.ResourceGroup {
+		_, err := client.CoreV1().ConfigMaps(inv.Namespace).List(ctx, metav1.ListOptions{
+			LabelSelector: common.InventoryLabel + "=" + inv.InventoryID,
+		})
+		if err != nil {
+			r.Status = Failed
+			r.Message = fmt.Sprintf("unable to read inventory %s/%s: %v", inv.Namespace, inv.Name, err)
+			r.Fix = "check the current context is allowed to manage ConfigMaps in " + inv.Namespace
+			return r
+		}
+		r.Status, r.Message = OK, fmt.Sprintf("inventory %s/%s is reachable", inv.Namespace, inv.Name)
+		return r
+	}
+	gv := live.ResourceGroupGVK.GroupVersion().String()
+	resources, err := client.Discovery().ServerResourcesForGroupVersion(gv)
+	found := false
+	if err == nil {
+		for _, res := range resources.APIResources {
+			found = found || res.Kind == live.ResourceGroupGVK.Kind
+		}
+	}
+	if !found {
+		r.Status = Failed
+		r.Message = fmt.Sprintf("the ResourceGroup CRD inventory %s/%s is stored in isn't installed",
+			inv.Namespace, inv.Name)
+		r.Fix = "install it with `kpt live install-resource-group`"
+		return r
+	}
+	r.Status, r.Message = OK, fmt.Sprintf("inventory %s/%s is reachable", inv.Namespace, inv.Name)
+	return r
+}
+
+// WriteTable writes the results as a table.
+func WriteTable(w io.Writer, results []Result) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(tw, "CHECK\tPATH\tSTATUS\tMESSAGE")
+	for _, r := range results {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", r.Check, r.Path, r.Status, r.Message)
+	}
+	if err := tw.Flush(); err != nil {
+		return errors.Wrap(err)
+	}
+	var fixes []string
+	for _, r := range results {
+		if r.Fix == "" || (r.Status != Failed && r.Status != Warning) {
+			continue
+		}
+		name := r.Check
+		if r.Path != "" {
+			name += " " + r.Path
+		}
+		fixes = append(fixes, fmt.Sprintf("  %s: %s", name, r.Fix))
+	}
+	if len(fixes) > 0 {
+		fmt.Fprintf(w, "\nto fix:\n%s\n", strings.Join(fixes, "\n"))
+	}
+	return nil
+}
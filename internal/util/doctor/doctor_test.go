@@ -0,0 +1,179 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package doctor_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt/internal/testutil"
+	"github.com/GoogleContainerTools/kpt/internal/util/doctor"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+// results returns the results of check, keyed by path.
+func results(all []doctor.Result, check string) map[string]doctor.Result {
+	m := map[string]doctor.Result{}
+	for _, r := range all {
+		if r.Check == check {
+			m[r.Path] = r
+		}
+	}
+	return m
+}
+
+func writeKptfile(t *testing.T, dir, content string) {
+	if !assert.NoError(t, os.MkdirAll(dir, 0700)) {
+		t.FailNow()
+	}
+	err := ioutil.WriteFile(filepath.Join(dir, "Kptfile"), []byte(content), 0600)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+}
+
+func TestCommand_Diagnose(t *testing.T) {
+	g, w, clean := testutil.SetupDefaultRepoAndWorkspace(t, testutil.Dataset1)
+	defer clean()
+	commit, err := g.GetCommit()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	testutil.Tag(t, g, "v1")
+
+	dir := w.WorkspaceDirectory
+	writeKptfile(t, filepath.Join(dir, "tagged"), `apiVersion: kpt.dev/v1alpha1
+kind: Kptfile
+metadata:
+  name: tagged
+upstream:
+  type: git
+  git:
+    repo: `+g.RepoDirectory+`
+    directory: /
+    ref: v1
+    commit: `+commit+`
+inventory:
+  namespace: apps
+  name: inventory
+  inventoryID: tagged
+`)
+	writeKptfile(t, filepath.Join(dir, "dangling"), `apiVersion: kpt.dev/v1alpha1
+kind: Kptfile
+metadata:
+  name: dangling
+upstream:
+  type: git
+  git:
+    repo: `+g.RepoDirectory+`
+    directory: /
+    ref: v2
+    commit: `+commit+`
+inventory:
+  namespace: missing
+  name: inventory
+  inventoryID: dangling
+`)
+	writeKptfile(t, filepath.Join(dir, "invalid"), `apiVersion: kpt.dev/v1alpha1
+kind: Krmfile
+metadata:
+  name: invalid
+`)
+
+	registry := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer registry.Close()
+
+	client := fake.NewSimpleClientset(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "apps"}})
+	c := doctor.Command{
+		Dir:        dir,
+		Registry:   registry.URL,
+		GitURL:     g.RepoDirectory,
+		KubeClient: func() (kubernetes.Interface, error) { return client, nil },
+	}
+	all := c.Diagnose()
+
+	assert.Equal(t, doctor.OK, results(all, doctor.GitCheck)[""].Status)
+	assert.Equal(t, doctor.OK, results(all, doctor.GitConnectivityCheck)[""].Status)
+	r := results(all, doctor.RegistryConnectivityCheck)[""]
+	assert.Equal(t, doctor.OK, r.Status)
+	assert.Contains(t, r.Message, "HTTP 401")
+
+	kptfiles := results(all, doctor.KptfileCheck)
+	r = kptfiles[filepath.Join("invalid", "Kptfile")]
+	assert.Equal(t, doctor.Failed, r.Status)
+	assert.Contains(t, r.Message, "kind must be Kptfile")
+
+	upstreams := results(all, doctor.UpstreamCheck)
+	assert.Equal(t, doctor.OK, upstreams["tagged"].Status)
+	assert.Equal(t, doctor.Failed, upstreams["dangling"].Status)
+	assert.Contains(t, upstreams["dangling"].Message, "ref v2 no longer exists")
+
+	inventories := results(all, doctor.InventoryCheck)
+	assert.Equal(t, doctor.OK, inventories["tagged"].Status)
+	assert.Equal(t, doctor.Failed, inventories["dangling"].Status)
+	assert.Contains(t, inventories["dangling"].Message, "inventory namespace missing")
+
+	b := &bytes.Buffer{}
+	c.StdOut = b
+	err = c.Run()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "check(s) failed")
+	}
+	assert.Contains(t, b.String(), "to fix:")
+	assert.Contains(t, b.String(), "upstream dangling: update the package to an existing ref")
+}
+
+func TestCommand_Diagnose_offline(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kpt-doctor")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir)
+	writeKptfile(t, dir, `apiVersion: kpt.dev/v1alpha1
+kind: Kptfile
+metadata:
+  name: pkg
+upstream:
+  type: git
+  git:
+    repo: https://example.invalid/repo
+    directory: /
+    ref: v1
+    commit: 0123456789abcdef0123456789abcdef01234567
+inventory:
+  namespace: apps
+  name: inventory
+  inventoryID: pkg
+`)
+
+	all := doctor.Command{Dir: dir, Offline: true}.Diagnose()
+	for _, check := range []string{doctor.GitConnectivityCheck, doctor.RegistryConnectivityCheck} {
+		assert.Equal(t, doctor.Skipped, results(all, check)[""].Status, check)
+	}
+	assert.Equal(t, doctor.OK, results(all, doctor.KptfileCheck)[""].Status)
+	assert.Equal(t, doctor.Skipped, results(all, doctor.UpstreamCheck)["."].Status)
+	assert.Equal(t, doctor.Skipped, results(all, doctor.InventoryCheck)["."].Status)
+}
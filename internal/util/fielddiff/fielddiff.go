@@ -0,0 +1,427 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package fielddiff compares the resources of two packages field by field,
+// matching resources by their apiVersion, kind, namespace and name rather
+// than by the files they are in.
+package fielddiff
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"sigs.k8s.io/kustomize/kyaml/errors"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/kio/kioutil"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// Output formats.
+const (
+	TableOutput = "table"
+	JSONOutput  = "json"
+)
+
+// Types of differences.
+const (
+	Added   = "added"
+	Removed = "removed"
+	Changed = "changed"
+)
+
+// Resource identifies a resource.
+type Resource struct {
+	APIVersion string
+	Kind       string
+	Namespace  string
+	Name       string
+}
+
+// String returns the resource as apiVersion/kind namespace/name.
+func (r Resource) String() string {
+	if r.Namespace == "" {
+		return fmt.Sprintf("%s/%s %s", r.APIVersion, r.Kind, r.Name)
+	}
+	return fmt.Sprintf("%s/%s %s/%s", r.APIVersion, r.Kind, r.Namespace, r.Name)
+}
+
+// less orders resources by kind, namespace, name and apiVersion.
+func (r Resource) less(o Resource) bool {
+	if r.Kind != o.Kind {
+		return r.Kind < o.Kind
+	}
+	if r.Namespace != o.Namespace {
+		return r.Namespace < o.Namespace
+	}
+	if r.Name != o.Name {
+		return r.Name < o.Name
+	}
+	return r.APIVersion < o.APIVersion
+}
+
+// Difference is a difference between the resources of two packages.
+type Difference struct {
+	Resource
+
+	// Type is added, removed or changed.
+	Type string
+
+	// Field is the field path of the field which differs, e.g.
+	// spec.template.spec.containers[name=app].image, or empty if the whole
+	// resource was added or removed.
+	Field string
+
+	// From is the value of the field in the first package, if any.
+	From interface{}
+
+	// To is the value of the field in the second package, if any.
+	To interface{}
+}
+
+// Read reads the resources of the package at dir, including its
+// subpackages.
+func Read(dir string) ([]*yaml.RNode, error) {
+	return (&kio.LocalPackageReader{PackagePath: dir}).Read()
+}
+
+// Diff compares the resources from with the resources to, and returns
+// their differences sorted by resource.  Fields of resources in both are
+// compared recursively; the elements of lists whose elements all have a
+// unique name are matched by name, and those of other lists by index.
+// The annotations recording the files resources were read from are
+// ignored.
+func Diff(from, to []*yaml.RNode) ([]Difference, error) {
+	fromResources, fromIDs, err := index(from)
+	if err != nil {
+		return nil, err
+	}
+	toResources, toIDs, err := index(to)
+	if err != nil {
+		return nil, err
+	}
+	ids := fromIDs
+	for _, id := range toIDs {
+		if _, found := fromResources[id]; !found {
+			ids = append(ids, id)
+		}
+	}
+	sort.SliceStable(ids, func(i, j int) bool { return ids[i].less(ids[j]) })
+
+	var diffs []Difference
+	for _, id := range ids {
+		f, inFrom := fromResources[id]
+		t, inTo := toResources[id]
+		switch {
+		case !inTo:
+			diffs = append(diffs, Difference{Resource: id, Type: Removed})
+		case !inFrom:
+			diffs = append(diffs, Difference{Resource: id, Type: Added})
+		default:
+			d := &differ{resource: id}
+			if err := d.compare(nil, f.YNode(), t.YNode()); err != nil {
+				return nil, err
+			}
+			diffs = append(diffs, d.diffs...)
+		}
+	}
+	return diffs, nil
+}
+
+// index returns nodes by their resource, and the resources in the order of
+// nodes.  The path and index annotations are cleared from copies of nodes.
+func index(nodes []*yaml.RNode) (map[Resource]*yaml.RNode, []Resource, error) {
+	resources := map[Resource]*yaml.RNode{}
+	var ids []Resource
+	for _, node := range nodes {
+		m, err := node.GetMeta()
+		if err != nil {
+			return nil, nil, err
+		}
+		id := Resource{APIVersion: m.APIVersion, Kind: m.Kind, Namespace: m.Namespace, Name: m.Name}
+		if _, found := resources[id]; found {
+			return nil, nil, errors.Errorf("duplicate resource %s", id)
+		}
+		node = node.Copy()
+		for _, a := range []string{kioutil.PathAnnotation, kioutil.IndexAnnotation} {
+			if _, err := node.Pipe(yaml.ClearAnnotation(a)); err != nil {
+				return nil, nil, err
+			}
+		}
+		if err := yaml.ClearEmptyAnnotations(node); err != nil {
+			return nil, nil, err
+		}
+		resources[id] = node
+		ids = append(ids, id)
+	}
+	return resources, ids, nil
+}
+
+// differ records the differences of the fields of a resource.
+type differ struct {
+	resource Resource
+	diffs    []Difference
+}
+
+// compare compares the field at path of the resource, whose values are from
+// and to.  Either may be nil if the field doesn't exist.
+func (d *differ) compare(path []string, from, to *yaml.Node) error {
+	switch {
+	case from == nil && to == nil:
+		return nil
+	case from == nil:
+		return d.add(path, Added, nil, to)
+	case to == nil:
+		return d.add(path, Removed, from, nil)
+	}
+	if from.Kind != to.Kind {
+		return d.add(path, Changed, from, to)
+	}
+	switch from.Kind {
+	case yaml.MappingNode:
+		return d.compareMaps(path, from, to)
+	case yaml.SequenceNode:
+		return d.compareLists(path, from, to)
+	case yaml.ScalarNode:
+		if from.Value != to.Value || from.ShortTag() != to.ShortTag() {
+			return d.add(path, Changed, from, to)
+		}
+		return nil
+	case yaml.AliasNode:
+		return d.compare(path, from.Alias, to.Alias)
+	}
+	return nil
+}
+
+// compareMaps compares the fields of the maps from and to, in the order of
+// from followed by the fields only in to.
+func (d *differ) compareMaps(path []string, from, to *yaml.Node) error {
+	fromFields, keys := fields(from)
+	toFields, toKeys := fields(to)
+	for _, k := range toKeys {
+		if _, found := fromFields[k]; !found {
+			keys = append(keys, k)
+		}
+	}
+	for _, k := range keys {
+		if err := d.compare(append(path, k), fromFields[k], toFields[k]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fields returns the values of the fields of the map node by name, and
+// their names in order.
+func fields(node *yaml.Node) (map[string]*yaml.Node, []string) {
+	values := map[string]*yaml.Node{}
+	var keys []string
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		k := node.Content[i].Value
+		values[k] = node.Content[i+1]
+		keys = append(keys, k)
+	}
+	return values, keys
+}
+
+// compareLists compares the elements of the lists from and to, by name if
+// all the elements of both have a unique name, and by index otherwise.
+func (d *differ) compareLists(path []string, from, to *yaml.Node) error {
+	fromNames, fromOK := names(from)
+	toNames, toOK := names(to)
+	if !fromOK || !toOK {
+		last := len(path) - 1
+		for i := 0; i < len(from.Content) || i < len(to.Content); i++ {
+			var f, t *yaml.Node
+			if i < len(from.Content) {
+				f = from.Content[i]
+			}
+			if i < len(to.Content) {
+				t = to.Content[i]
+			}
+			p := append(append([]string{}, path[:last]...), fmt.Sprintf("%s[%d]", path[last], i))
+			if err := d.compare(p, f, t); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	keys := append([]string{}, fromNames...)
+	fromElements := map[string]*yaml.Node{}
+	for i, name := range fromNames {
+		fromElements[name] = from.Content[i]
+	}
+	toElements := map[string]*yaml.Node{}
+	for i, name := range toNames {
+		toElements[name] = to.Content[i]
+		if _, found := fromElements[name]; !found {
+			keys = append(keys, name)
+		}
+	}
+	last := len(path) - 1
+	for _, name := range keys {
+		p := append(append([]string{}, path[:last]...), fmt.Sprintf("%s[name=%s]", path[last], name))
+		if err := d.compare(p, fromElements[name], toElements[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// names returns the names of the elements of the list node, and whether
+// all of them are maps with a unique name.
+func names(node *yaml.Node) ([]string, bool) {
+	var result []string
+	seen := map[string]bool{}
+	for _, e := range node.Content {
+		if e.Kind != yaml.MappingNode {
+			return nil, false
+		}
+		values, _ := fields(e)
+		name, found := values["name"]
+		if !found || name.Kind != yaml.ScalarNode || seen[name.Value] {
+			return nil, false
+		}
+		seen[name.Value] = true
+		result = append(result, name.Value)
+	}
+	return result, true
+}
+
+// add records a difference of the field at path.
+func (d *differ) add(path []string, t string, from, to *yaml.Node) error {
+	diff := Difference{Resource: d.resource, Type: t, Field: strings.Join(path, ".")}
+	for _, v := range []struct {
+		node  *yaml.Node
+		value *interface{}
+	}{{from, &diff.From}, {to, &diff.To}} {
+		if v.node == nil {
+			continue
+		}
+		if err := v.node.Decode(v.value); err != nil {
+			return errors.Wrap(err)
+		}
+	}
+	d.diffs = append(d.diffs, diff)
+	return nil
+}
+
+// ValidateOutput returns an error if output isn't a supported format.
+func ValidateOutput(output string) error {
+	switch output {
+	case "", TableOutput, JSONOutput:
+		return nil
+	}
+	return errors.Errorf("unsupported output %q, must be one of %s, %s",
+		output, TableOutput, JSONOutput)
+}
+
+// Write writes diffs to w in the format output.
+func Write(w io.Writer, output string, diffs []Difference) error {
+	if err := ValidateOutput(output); err != nil {
+		return err
+	}
+	if output == JSONOutput {
+		return WriteJSON(w, diffs)
+	}
+	return WriteTable(w, diffs)
+}
+
+// WriteTable writes diffs as a table, with a row for each difference.
+// Values which aren't scalars are written as json.
+func WriteTable(w io.Writer, diffs []Difference) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "TYPE\tRESOURCE\tFIELD\tFROM\tTO")
+	for _, d := range diffs {
+		row := []string{d.Type, d.Resource.String(), d.Field, "", ""}
+		if d.Field != "" {
+			var err error
+			if row[3], err = tableValue(d.From); err != nil {
+				return err
+			}
+			if row[4], err = tableValue(d.To); err != nil {
+				return err
+			}
+		}
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return errors.Wrap(tw.Flush())
+}
+
+// tableValue returns the value v as written in a table.
+func tableValue(v interface{}) (string, error) {
+	if v == nil {
+		return "<none>", nil
+	}
+	if s, ok := v.(string); ok && !strings.ContainsAny(s, "\t\n") && s != "" {
+		return s, nil
+	}
+	b, err := json.Marshal(jsonValue(v))
+	return string(b), errors.Wrap(err)
+}
+
+// WriteJSON writes diffs as a json list, with an object for each
+// difference.
+func WriteJSON(w io.Writer, diffs []Difference) error {
+	type difference struct {
+		APIVersion string      `json:"apiVersion"`
+		Kind       string      `json:"kind"`
+		Namespace  string      `json:"namespace,omitempty"`
+		Name       string      `json:"name"`
+		Type       string      `json:"type"`
+		Field      string      `json:"field,omitempty"`
+		From       interface{} `json:"from,omitempty"`
+		To         interface{} `json:"to,omitempty"`
+	}
+	result := []difference{}
+	for _, d := range diffs {
+		result = append(result, difference{
+			APIVersion: d.APIVersion, Kind: d.Kind, Namespace: d.Namespace, Name: d.Name,
+			Type: d.Type, Field: d.Field, From: jsonValue(d.From), To: jsonValue(d.To),
+		})
+	}
+	e := json.NewEncoder(w)
+	e.SetIndent("", "  ")
+	return errors.Wrap(e.Encode(result))
+}
+
+// jsonValue converts the maps decoded from yaml, whose keys may not be
+// strings, to maps json can encode.
+func jsonValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		m := map[string]interface{}{}
+		for k, e := range v {
+			m[k] = jsonValue(e)
+		}
+		return m
+	case map[interface{}]interface{}:
+		m := map[string]interface{}{}
+		for k, e := range v {
+			m[fmt.Sprint(k)] = jsonValue(e)
+		}
+		return m
+	case []interface{}:
+		l := make([]interface{}, len(v))
+		for i, e := range v {
+			l[i] = jsonValue(e)
+		}
+		return l
+	}
+	return v
+}
@@ -0,0 +1,206 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fielddiff_test
+
+import (
+	"bytes"
+	"testing"
+
+	. "github.com/GoogleContainerTools/kpt/internal/util/fielddiff"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+)
+
+const from = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: prod
+  annotations:
+    config.kubernetes.io/path: web.yaml
+spec:
+  replicas: 2
+  template:
+    spec:
+      containers:
+      - name: web
+        image: nginx:1.7.9
+        args: [a, b]
+      - name: proxy
+        image: proxy:1
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+  namespace: prod
+  annotations:
+    config.kubernetes.io/path: config.yaml
+data:
+  a: "1"
+`
+
+const to = `apiVersion: v1
+kind: Service
+metadata:
+  name: web
+  namespace: prod
+  annotations:
+    config.kubernetes.io/path: all.yaml
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: prod
+  labels:
+    app: web
+  annotations:
+    config.kubernetes.io/path: all.yaml
+spec:
+  replicas: "2"
+  template:
+    spec:
+      containers:
+      - name: proxy
+        image: proxy:1
+      - name: web
+        image: nginx:1.8.0
+        args: [a]
+`
+
+func TestDiff(t *testing.T) {
+	fromNodes, err := (&kio.ByteReader{Reader: bytes.NewBufferString(from)}).Read()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	toNodes, err := (&kio.ByteReader{Reader: bytes.NewBufferString(to)}).Read()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	diffs, err := Diff(fromNodes, toNodes)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	configMap := Resource{APIVersion: "v1", Kind: "ConfigMap", Namespace: "prod", Name: "config"}
+	deployment := Resource{APIVersion: "apps/v1", Kind: "Deployment", Namespace: "prod", Name: "web"}
+	service := Resource{APIVersion: "v1", Kind: "Service", Namespace: "prod", Name: "web"}
+	assert.Equal(t, []Difference{
+		{Resource: configMap, Type: Removed},
+		{Resource: deployment, Type: Added, Field: "metadata.labels",
+			To: map[string]interface{}{"app": "web"}},
+		{Resource: deployment, Type: Changed, Field: "spec.replicas", From: 2, To: "2"},
+		{Resource: deployment, Type: Changed, Field: "spec.template.spec.containers[name=web].image",
+			From: "nginx:1.7.9", To: "nginx:1.8.0"},
+		{Resource: deployment, Type: Removed, Field: "spec.template.spec.containers[name=web].args[1]",
+			From: "b"},
+		{Resource: service, Type: Added},
+	}, diffs)
+
+	diffs, err = Diff(fromNodes, fromNodes)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Empty(t, diffs)
+
+	_, err = Diff(append(fromNodes, fromNodes[0]), toNodes)
+	if assert.Error(t, err) {
+		assert.Equal(t, "duplicate resource apps/v1/Deployment prod/web", err.Error())
+	}
+}
+
+func TestWrite(t *testing.T) {
+	deployment := Resource{APIVersion: "apps/v1", Kind: "Deployment", Namespace: "prod", Name: "web"}
+	diffs := []Difference{
+		{Resource: Resource{APIVersion: "v1", Kind: "Namespace", Name: "prod"}, Type: Removed},
+		{Resource: deployment, Type: Changed, Field: "spec.replicas", From: 2, To: 5},
+		{Resource: deployment, Type: Added, Field: "metadata.labels",
+			To: map[string]interface{}{"app": "web"}},
+	}
+
+	var tests = []struct {
+		name     string
+		output   string
+		expected string
+		err      string
+	}{
+		{
+			name:   "table",
+			output: TableOutput,
+			expected: `TYPE     RESOURCE                     FIELD            FROM    TO
+removed  v1/Namespace prod                                     
+changed  apps/v1/Deployment prod/web  spec.replicas    2       5
+added    apps/v1/Deployment prod/web  metadata.labels  <none>  {"app":"web"}
+`,
+		},
+		{
+			name:   "json",
+			output: JSONOutput,
+			expected: `[
+  {
+    "apiVersion": "v1",
+    "kind": "Namespace",
+    "name": "prod",
+    "type": "removed"
+  },
+  {
+    "apiVersion": "apps/v1",
+    "kind": "Deployment",
+    "namespace": "prod",
+    "name": "web",
+    "type": "changed",
+    "field": "spec.replicas",
+    "from": 2,
+    "to": 5
+  },
+  {
+    "apiVersion": "apps/v1",
+    "kind": "Deployment",
+    "namespace": "prod",
+    "name": "web",
+    "type": "added",
+    "field": "metadata.labels",
+    "to": {
+      "app": "web"
+    }
+  }
+]
+`,
+		},
+		{
+			name:   "unsupported",
+			output: "csv",
+			err:    `unsupported output "csv", must be one of table, json`,
+		},
+	}
+	for i := range tests {
+		test := tests[i]
+		t.Run(test.name, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			err := Write(out, test.output, diffs)
+			if test.err != "" {
+				if assert.Error(t, err) {
+					assert.Equal(t, test.err, err.Error())
+				}
+				return
+			}
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			assert.Equal(t, test.expected, out.String())
+		})
+	}
+}
@@ -0,0 +1,218 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package fieldpath selects the fields of resources by dot-separated field
+// paths, such as spec.template.spec.containers[name=nginx].image.
+package fieldpath
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"sigs.k8s.io/kustomize/kyaml/errors"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// Element is an element of a field path.
+type Element struct {
+	// Field is the name of the field
+	Field string
+
+	// Selector selects the elements of a list field, if set: * for every
+	// element, N for the element at index N, or key=value for the elements
+	// whose key field has value.
+	Selector string
+}
+
+// Path is a field path.
+type Path []Element
+
+// Match is a field selected by a field path.
+type Match struct {
+	// Path is the path of the field, with the elements of lists given by
+	// index, e.g. spec.template.spec.containers[0].image.
+	Path string
+
+	// Node is the value of the field.
+	Node *yaml.RNode
+}
+
+var elementPattern = regexp.MustCompile(`^([^.\[\]]+)(?:\[([^\[\]]+)\])?$`)
+
+// Parse parses a field path.
+func Parse(path string) (Path, error) {
+	var p Path
+	for _, e := range strings.Split(path, ".") {
+		m := elementPattern.FindStringSubmatch(e)
+		if m == nil {
+			return nil, errors.Errorf("invalid field path %q", path)
+		}
+		p = append(p, Element{Field: m[1], Selector: m[2]})
+	}
+	return p, nil
+}
+
+// String returns the field path p was parsed from.
+func (p Path) String() string {
+	var parts []string
+	for _, e := range p {
+		if e.Selector == "" {
+			parts = append(parts, e.Field)
+			continue
+		}
+		parts = append(parts, fmt.Sprintf("%s[%s]", e.Field, e.Selector))
+	}
+	return strings.Join(parts, ".")
+}
+
+// Fields returns the field path of p without its selectors, e.g.
+// containers.image for containers[*].image.
+func (p Path) Fields() string {
+	var parts []string
+	for _, e := range p {
+		parts = append(parts, e.Field)
+	}
+	return strings.Join(parts, ".")
+}
+
+// HasSelectors returns true if p selects the elements of any list.
+func (p Path) HasSelectors() bool {
+	for _, e := range p {
+		if e.Selector != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// Lookup returns the fields of n selected by p.
+func (p Path) Lookup(n *yaml.RNode) ([]Match, error) {
+	return p.lookup(Match{Node: n}, false)
+}
+
+// LookupSuffix returns the fields of n whose paths end with p, e.g.
+// containers[*].image selects the images of the containers of both pods
+// and pod templates.  Fields with the name of an element of p which aren't
+// lists where p selects their elements are other fields, so unlike Lookup
+// they aren't an error.
+func (p Path) LookupSuffix(n *yaml.RNode) ([]Match, error) {
+	var matches []Match
+	var visit func(m Match) error
+	visit = func(m Match) error {
+		switch m.Node.YNode().Kind {
+		case yaml.MappingNode:
+			l, err := p.lookup(m, true)
+			if err != nil {
+				return err
+			}
+			matches = append(matches, l...)
+			content := m.Node.YNode().Content
+			for i := 0; i+1 < len(content); i += 2 {
+				path := content[i].Value
+				if m.Path != "" {
+					path = m.Path + "." + path
+				}
+				if err := visit(Match{Path: path, Node: yaml.NewRNode(content[i+1])}); err != nil {
+					return err
+				}
+			}
+		case yaml.SequenceNode:
+			for i, c := range m.Node.YNode().Content {
+				path := fmt.Sprintf("%s[%d]", m.Path, i)
+				if err := visit(Match{Path: path, Node: yaml.NewRNode(c)}); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	if err := visit(Match{Node: n}); err != nil {
+		return nil, err
+	}
+	return matches, nil
+}
+
+// lookup returns the fields selected by p from m.  Fields which aren't
+// lists where p selects their elements are skipped if skipNonLists is set.
+func (p Path) lookup(from Match, skipNonLists bool) ([]Match, error) {
+	matches := []Match{from}
+	for _, e := range p {
+		var next []Match
+		for _, m := range matches {
+			l, err := e.lookup(m, skipNonLists)
+			if err != nil {
+				return nil, err
+			}
+			next = append(next, l...)
+		}
+		matches = next
+	}
+	return matches, nil
+}
+
+// lookup returns the fields selected by e from m.
+func (e Element) lookup(m Match, skipNonLists bool) ([]Match, error) {
+	if m.Node.YNode().Kind != yaml.MappingNode {
+		return nil, nil
+	}
+	f := m.Node.Field(e.Field)
+	if f == nil || yaml.IsMissingOrNull(f.Value) {
+		return nil, nil
+	}
+	path := e.Field
+	if m.Path != "" {
+		path = m.Path + "." + e.Field
+	}
+	if e.Selector == "" {
+		return []Match{{Path: path, Node: f.Value}}, nil
+	}
+	if f.Value.YNode().Kind != yaml.SequenceNode {
+		if skipNonLists {
+			return nil, nil
+		}
+		return nil, errors.Errorf("field %q is not a list", e.Field)
+	}
+	elements, err := f.Value.Elements()
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	element := func(i int) Match {
+		return Match{Path: fmt.Sprintf("%s[%d]", path, i), Node: elements[i]}
+	}
+	var selected []Match
+	if e.Selector == "*" {
+		for i := range elements {
+			selected = append(selected, element(i))
+		}
+		return selected, nil
+	}
+	if i, err := strconv.Atoi(e.Selector); err == nil {
+		if i < 0 || i >= len(elements) {
+			return nil, nil
+		}
+		return []Match{element(i)}, nil
+	}
+	kv := strings.SplitN(e.Selector, "=", 2)
+	if len(kv) != 2 {
+		return nil, errors.Errorf("invalid selector [%s] of field %q", e.Selector, e.Field)
+	}
+	for i, el := range elements {
+		if v := el.Field(kv[0]); v != nil && v.Value.YNode().Value == kv[1] {
+			selected = append(selected, element(i))
+		}
+	}
+	return selected, nil
+}
@@ -0,0 +1,172 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fieldpath_test
+
+import (
+	"testing"
+
+	. "github.com/GoogleContainerTools/kpt/internal/util/fieldpath"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+func TestPath_Lookup(t *testing.T) {
+	node := yaml.MustParse(`apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+spec:
+  template:
+    spec:
+      containers:
+      - name: nginx
+        image: nginx:1.19
+      - name: sidecar
+        image: envoy:1.16
+`)
+	var tests = []struct {
+		path     string
+		expected map[string]string
+	}{
+		{path: "metadata.name", expected: map[string]string{"metadata.name": "app"}},
+		{path: "spec.replicas", expected: map[string]string{}},
+		{
+			path: "spec.template.spec.containers[*].image",
+			expected: map[string]string{
+				"spec.template.spec.containers[0].image": "nginx:1.19",
+				"spec.template.spec.containers[1].image": "envoy:1.16",
+			},
+		},
+		{
+			path:     "spec.template.spec.containers[1].image",
+			expected: map[string]string{"spec.template.spec.containers[1].image": "envoy:1.16"},
+		},
+		{path: "spec.template.spec.containers[2].image", expected: map[string]string{}},
+		{
+			path:     "spec.template.spec.containers[name=nginx].image",
+			expected: map[string]string{"spec.template.spec.containers[0].image": "nginx:1.19"},
+		},
+	}
+	for i := range tests {
+		test := tests[i]
+		t.Run(test.path, func(t *testing.T) {
+			p, err := Parse(test.path)
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			assert.Equal(t, test.path, p.String())
+			matches, err := p.Lookup(node)
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			actual := map[string]string{}
+			for _, m := range matches {
+				actual[m.Path] = m.Node.YNode().Value
+			}
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+}
+
+func TestPath_Lookup_errors(t *testing.T) {
+	_, err := Parse("spec.containers[")
+	if assert.Error(t, err) {
+		assert.Equal(t, `invalid field path "spec.containers["`, err.Error())
+	}
+
+	p, err := Parse("metadata[0].name")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	_, err = p.Lookup(yaml.MustParse("metadata:\n  name: app\n"))
+	if assert.Error(t, err) {
+		assert.Equal(t, `field "metadata" is not a list`, err.Error())
+	}
+}
+
+func TestPath_LookupSuffix(t *testing.T) {
+	node := yaml.MustParse(`apiVersion: v1
+kind: List
+items:
+- kind: Deployment
+  spec:
+    template:
+      spec:
+        containers:
+        - name: nginx
+          image: nginx:1.19
+        - name: sidecar
+          image: envoy:1.16
+- kind: Pod
+  spec:
+    containers:
+    - name: nginx
+      image: nginx:1.19
+- kind: Ingress
+  spec:
+    rules:
+    - host: a.example.com
+    - host: b.example.com
+- kind: Other
+  rules: none
+`)
+	var tests = []struct {
+		path     string
+		expected map[string]string
+	}{
+		{
+			path: "containers[*].image",
+			expected: map[string]string{
+				"items[0].spec.template.spec.containers[0].image": "nginx:1.19",
+				"items[0].spec.template.spec.containers[1].image": "envoy:1.16",
+				"items[1].spec.containers[0].image":               "nginx:1.19",
+			},
+		},
+		{
+			path: "containers[name=nginx].image",
+			expected: map[string]string{
+				"items[0].spec.template.spec.containers[0].image": "nginx:1.19",
+				"items[1].spec.containers[0].image":               "nginx:1.19",
+			},
+		},
+		{
+			path:     "rules[0].host",
+			expected: map[string]string{"items[2].spec.rules[0].host": "a.example.com"},
+		},
+		{
+			path:     "spec.rules[1].host",
+			expected: map[string]string{"items[2].spec.rules[1].host": "b.example.com"},
+		},
+		{path: "rules[2].host", expected: map[string]string{}},
+	}
+	for i := range tests {
+		test := tests[i]
+		t.Run(test.path, func(t *testing.T) {
+			p, err := Parse(test.path)
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			matches, err := p.LookupSuffix(node)
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			actual := map[string]string{}
+			for _, m := range matches {
+				actual[m.Path] = m.Node.YNode().Value
+			}
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+}
@@ -17,8 +17,13 @@ package fix
 import (
 	"fmt"
 	"io"
+	"os"
 	"path/filepath"
 
+	"github.com/GoogleContainerTools/kpt/internal/util/tmputil"
+	"github.com/GoogleContainerTools/kpt/internal/util/update"
+	"sigs.k8s.io/kustomize/kyaml/copyutil"
+	"sigs.k8s.io/kustomize/kyaml/errors"
 	"sigs.k8s.io/kustomize/kyaml/fix/fixsetters"
 )
 
@@ -35,19 +40,45 @@ type Command struct {
 	StdOut io.Writer
 }
 
-// Run runs the Command.
+// Run runs the Command.  With DryRun the package is fixed in a copy, and
+// the diff of the fixes is printed.
 func (c Command) Run() error {
 	printFunc := printFunc(c.StdOut, c.DryRun)
 	printFunc("processing resource configs to identify possible fixes... ")
-	return c.fixV1Setters()
+
+	path := c.PkgPath
+	if c.DryRun {
+		dir, err := tmputil.TempDir("kpt-fix-")
+		if err != nil {
+			return err
+		}
+		defer os.RemoveAll(dir)
+		path = filepath.Join(dir, filepath.Base(c.PkgPath))
+		if err := copyutil.CopyDir(c.PkgPath, path); err != nil {
+			return errors.Wrap(err)
+		}
+	}
+	if err := c.migrateKptfile(path); err != nil {
+		return err
+	}
+	if err := c.fixV1Setters(path); err != nil {
+		return err
+	}
+	if !c.DryRun {
+		return nil
+	}
+	changes, err := update.DiffPackages(c.PkgPath, path)
+	if err != nil {
+		return err
+	}
+	return update.PrintChanges(c.StdOut, update.DryRunDiff, changes)
 }
 
-func (c Command) fixV1Setters() error {
+func (c Command) fixV1Setters(path string) error {
 	printFunc := printFunc(c.StdOut, c.DryRun)
 	f := &fixsetters.SetterFixer{
-		PkgPath:     c.PkgPath,
-		OpenAPIPath: filepath.Join(c.PkgPath, "Kptfile"),
-		DryRun:      c.DryRun,
+		PkgPath:     path,
+		OpenAPIPath: filepath.Join(path, "Kptfile"),
 	}
 	sfr, err := f.FixV1Setters()
 	if err != nil {
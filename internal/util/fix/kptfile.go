@@ -0,0 +1,133 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fix
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/GoogleContainerTools/kpt/pkg/kptfile"
+	"github.com/GoogleContainerTools/kpt/pkg/kptfile/kptfileutil"
+	"sigs.k8s.io/kustomize/kyaml/errors"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// migration migrates a Kptfile from the schema of one apiVersion to the
+// next.
+type migration struct {
+	// to is the apiVersion the Kptfile is migrated to
+	to string
+
+	// migrate changes the Kptfile, returning a description of each change
+	migrate func(k *yaml.RNode) ([]string, error)
+}
+
+// migrations are keyed by the apiVersion of the Kptfiles they migrate, and
+// are applied in turn until a Kptfile has the current apiVersion.  Fields
+// renamed or moved by a new apiVersion are migrated by adding its migration
+// here.
+var migrations = map[string]migration{
+	// Kptfiles predating the versioned schema have no apiVersion
+	"": {to: kptfile.KptFileAPIVersion, migrate: setTypeMeta},
+
+	// only the group changed
+	kptfile.KptFileLegacyAPIVersion: {to: kptfile.KptFileAPIVersion, migrate: noChanges},
+}
+
+// noChanges migrates Kptfiles whose schema is unchanged.
+func noChanges(*yaml.RNode) ([]string, error) {
+	return nil, nil
+}
+
+// setTypeMeta sets the kind of an unversioned Kptfile.  The apiVersion is
+// set by migrateKptfile.
+func setTypeMeta(k *yaml.RNode) ([]string, error) {
+	if k.Field(yaml.KindField) != nil {
+		return nil, nil
+	}
+	if err := k.PipeE(yaml.SetField(yaml.KindField, yaml.NewScalarRNode(kptfile.KptFileName))); err != nil {
+		return nil, err
+	}
+	return []string{"set kind " + kptfile.KptFileName}, nil
+}
+
+// migrateKptfile migrates the Kptfile of the package at path to the current
+// apiVersion, writing it unless nothing changed.  Files of other kinds,
+// such as the openAPI files of kustomize packages, aren't changed.
+func (c Command) migrateKptfile(path string) error {
+	printFunc := printFunc(c.StdOut, c.DryRun)
+	file := filepath.Join(path, kptfile.KptFileName)
+	b, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	k, err := yaml.Parse(string(b))
+	if err != nil {
+		return errors.WrapPrefixf(err, "unable to parse %q", file)
+	}
+	m, err := k.GetMeta()
+	if err != nil {
+		return errors.WrapPrefixf(err, "unable to parse %q", file)
+	}
+	if m.Kind != "" && m.Kind != kptfile.KptFileName {
+		return nil
+	}
+
+	version := m.APIVersion
+	if version == kptfile.KptFileAPIVersion {
+		printFunc("Kptfile has the latest apiVersion %s, no migration needed", version)
+		return nil
+	}
+	for version != kptfile.KptFileAPIVersion {
+		mig, found := migrations[version]
+		if !found {
+			return &kptfileutil.UnknownKptfileVersionError{Path: file, APIVersion: version}
+		}
+		changes, err := mig.migrate(k)
+		if err != nil {
+			return errors.WrapPrefixf(err, "unable to migrate %q from apiVersion %q", file, version)
+		}
+		if err := k.PipeE(yaml.SetField(yaml.APIVersionField, yaml.NewScalarRNode(mig.to))); err != nil {
+			return errors.Wrap(err)
+		}
+		for _, change := range changes {
+			printFunc("%s", change)
+		}
+		printFunc("migrated Kptfile from apiVersion %q to %q", version, mig.to)
+		version = mig.to
+	}
+
+	// the type meta comes first, as kpt writes it
+	if err := k.PipeE(yaml.FieldClearer{Name: yaml.KindField}); err != nil {
+		return errors.Wrap(err)
+	}
+	apiVersion, err := k.Pipe(yaml.FieldClearer{Name: yaml.APIVersionField})
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	k.YNode().Content = append([]*yaml.Node{
+		yaml.NewScalarRNode(yaml.APIVersionField).YNode(), apiVersion.YNode(),
+		yaml.NewScalarRNode(yaml.KindField).YNode(), yaml.NewScalarRNode(kptfile.KptFileName).YNode(),
+	}, k.YNode().Content...)
+	s, err := k.String()
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	return errors.Wrap(ioutil.WriteFile(file, []byte(s), 0600))
+}
@@ -0,0 +1,320 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package format formats the resources of packages with the yaml style
+// declared by their Kptfile, so that packages across an organization
+// converge on one canonical style.
+package format
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/GoogleContainerTools/kpt/internal/util/inherit"
+	"github.com/GoogleContainerTools/kpt/pkg/kptfile"
+	"sigs.k8s.io/kustomize/kyaml/errors"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/kio/filters"
+	"sigs.k8s.io/kustomize/kyaml/kio/kioutil"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// DefaultShortListMaxLength is the default length of the longest list
+// written in flow style.
+const DefaultShortListMaxLength = 40
+
+// Validate returns an error if p isn't a valid formatting policy.
+func Validate(p kptfile.Formatting) error {
+	switch p.FieldOrder {
+	case "", kptfile.FieldOrderKubernetes, kptfile.FieldOrderAlphabetical, kptfile.FieldOrderPreserve:
+	default:
+		return errors.Errorf("unsupported fieldOrder %q, must be one of %s, %s, %s", p.FieldOrder,
+			kptfile.FieldOrderKubernetes, kptfile.FieldOrderAlphabetical, kptfile.FieldOrderPreserve)
+	}
+	switch p.Indent {
+	case 0, 2, 4:
+	default:
+		return errors.Errorf("unsupported indent %d, must be 2 or 4", p.Indent)
+	}
+	switch p.ShortLists {
+	case "", kptfile.ListStyleFlow, kptfile.ListStyleBlock:
+	default:
+		return errors.Errorf("unsupported shortLists %q, must be one of %s, %s",
+			p.ShortLists, kptfile.ListStyleFlow, kptfile.ListStyleBlock)
+	}
+	if p.ShortListMaxLength < 0 {
+		return errors.Errorf("shortListMaxLength must not be negative, got %d", p.ShortListMaxLength)
+	}
+	switch p.Quoting {
+	case "", kptfile.QuotingMinimal, kptfile.QuotingDouble, kptfile.QuotingSingle:
+	default:
+		return errors.Errorf("unsupported quoting %q, must be one of %s, %s, %s",
+			p.Quoting, kptfile.QuotingMinimal, kptfile.QuotingDouble, kptfile.QuotingSingle)
+	}
+	return nil
+}
+
+// Policy returns the formatting policy of the package at dir, including
+// the fields it inherits, or nil if it doesn't declare or inherit one.
+func Policy(dir string) (*kptfile.Formatting, error) {
+	if _, err := os.Stat(filepath.Join(dir, kptfile.KptFileName)); os.IsNotExist(err) {
+		return nil, nil
+	}
+	kf, err := inherit.Kptfile(dir)
+	if err != nil {
+		return nil, err
+	}
+	if kf.Formatting == nil {
+		return nil, nil
+	}
+	if err := Validate(*kf.Formatting); err != nil {
+		return nil, errors.WrapPrefixf(err, "invalid formatting policy of package %q", dir)
+	}
+	return kf.Formatting, nil
+}
+
+// Filter formats resources with a formatting policy.  Resources annotated
+// with config.kubernetes.io/formatting: none aren't formatted.
+type Filter struct {
+	// Policy is the formatting policy.
+	Policy kptfile.Formatting
+
+	// UseSchema if set uses the openapi schema of resources to quote the
+	// values which aren't strings, with FieldOrderKubernetes.
+	UseSchema bool
+}
+
+// Filter implements kio.Filter.
+func (f Filter) Filter(nodes []*yaml.RNode) ([]*yaml.RNode, error) {
+	for _, node := range nodes {
+		s, err := node.Pipe(yaml.GetAnnotation(filters.FmtAnnotation))
+		if err != nil {
+			return nil, err
+		}
+		if s != nil && s.YNode().Value == filters.FmtStrategyNone {
+			continue
+		}
+		switch f.Policy.FieldOrder {
+		case "", kptfile.FieldOrderKubernetes:
+			if _, err := (filters.FormatFilter{UseSchema: f.UseSchema}).Filter([]*yaml.RNode{node}); err != nil {
+				return nil, err
+			}
+		case kptfile.FieldOrderAlphabetical:
+			sortFields(node.YNode())
+		}
+		f.style(node.YNode())
+	}
+	return nodes, nil
+}
+
+// sortFields sorts the fields of n and the nodes under it lexicographically.
+func sortFields(n *yaml.Node) {
+	if n.Kind == yaml.MappingNode {
+		type field struct{ key, value *yaml.Node }
+		var fields []field
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			fields = append(fields, field{n.Content[i], n.Content[i+1]})
+		}
+		sort.SliceStable(fields, func(i, j int) bool { return fields[i].key.Value < fields[j].key.Value })
+		for i, f := range fields {
+			n.Content[2*i], n.Content[2*i+1] = f.key, f.value
+		}
+	}
+	for _, c := range n.Content {
+		sortFields(c)
+	}
+}
+
+// style applies the list style and quoting of the policy to n and the nodes
+// under it.  Mapping keys are left as they are.
+func (f Filter) style(n *yaml.Node) {
+	switch n.Kind {
+	case yaml.ScalarNode:
+		f.quote(n)
+	case yaml.SequenceNode:
+		if f.Policy.ShortLists != "" {
+			n.Style &^= yaml.FlowStyle
+			if f.Policy.ShortLists == kptfile.ListStyleFlow && f.short(n) {
+				n.Style |= yaml.FlowStyle
+			}
+		}
+		for _, c := range n.Content {
+			f.style(c)
+		}
+	case yaml.MappingNode:
+		for i := 1; i < len(n.Content); i += 2 {
+			f.style(n.Content[i])
+		}
+	}
+}
+
+// short returns true if n is a list of scalars without comments, which fits
+// in ShortListMaxLength characters in flow style.
+func (f Filter) short(n *yaml.Node) bool {
+	max := f.Policy.ShortListMaxLength
+	if max == 0 {
+		max = DefaultShortListMaxLength
+	}
+	length := len("[]")
+	for i, c := range n.Content {
+		if c.Kind != yaml.ScalarNode || c.HeadComment != "" || c.LineComment != "" ||
+			c.FootComment != "" || strings.Contains(c.Value, "\n") {
+			return false
+		}
+		if i > 0 {
+			length += len(", ")
+		}
+		length += len(c.Value)
+		if c.Style&(yaml.DoubleQuotedStyle|yaml.SingleQuotedStyle) != 0 {
+			length += len(`""`)
+		}
+	}
+	return length <= max
+}
+
+// quote applies the quoting of the policy to the string scalar n.  Multi
+// line strings are left as they are.
+func (f Filter) quote(n *yaml.Node) {
+	if f.Policy.Quoting == "" || n.ShortTag() != yaml.NodeTagString ||
+		n.Style&(yaml.LiteralStyle|yaml.FoldedStyle|yaml.TaggedStyle) != 0 ||
+		strings.Contains(n.Value, "\n") {
+		return
+	}
+	n.Style &^= yaml.DoubleQuotedStyle | yaml.SingleQuotedStyle
+	switch f.Policy.Quoting {
+	case kptfile.QuotingDouble:
+		n.Style |= yaml.DoubleQuotedStyle
+	case kptfile.QuotingSingle:
+		n.Style |= yaml.SingleQuotedStyle
+	case kptfile.QuotingMinimal:
+		// the encoder quotes strings which would otherwise be read as
+		// another type, or aren't valid unquoted
+	}
+}
+
+// Package formats the resource files of the package at path with policy,
+// returning the paths of the files which weren't formatted.  The files are
+// only changed if write is set.  Subpackages aren't formatted.  If path is
+// a file only it is formatted.
+func Package(path string, policy kptfile.Formatting, useSchema, write bool) ([]string, error) {
+	if err := Validate(policy); err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	dir, only := path, ""
+	if !info.IsDir() {
+		dir, only = filepath.Dir(path), filepath.Base(path)
+	}
+	nodes, err := kio.LocalPackageReader{PackagePath: dir, PackageFileName: kptfile.KptFileName}.Read()
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	if only != "" {
+		var selected []*yaml.RNode
+		for _, node := range nodes {
+			if p, _, _ := kioutil.GetFileAnnotations(node); p == only {
+				selected = append(selected, node)
+			}
+		}
+		nodes = selected
+	}
+	if _, err := (Filter{Policy: policy, UseSchema: useSchema}).Filter(nodes); err != nil {
+		return nil, err
+	}
+	if err := kioutil.SortNodes(nodes); err != nil {
+		return nil, errors.Wrap(err)
+	}
+	files := map[string][]*yaml.RNode{}
+	var paths []string
+	for _, node := range nodes {
+		path, _, err := kioutil.GetFileAnnotations(node)
+		if err != nil {
+			return nil, errors.Wrap(err)
+		}
+		if _, found := files[path]; !found {
+			paths = append(paths, path)
+		}
+		files[path] = append(files[path], node)
+	}
+
+	var changed []string
+	for _, path := range paths {
+		b := &bytes.Buffer{}
+		err := kio.ByteWriter{
+			Writer:           b,
+			ClearAnnotations: []string{kioutil.PathAnnotation},
+		}.Write(files[path])
+		if err != nil {
+			return nil, errors.Wrap(err)
+		}
+		formatted := b.Bytes()
+		if policy.Indent != 0 && policy.Indent != 2 {
+			if formatted, err = indent(formatted, policy.Indent); err != nil {
+				return nil, errors.WrapPrefixf(err, "%s", path)
+			}
+		}
+		file := filepath.Join(dir, path)
+		info, err := os.Stat(file)
+		if err != nil {
+			return nil, errors.Wrap(err)
+		}
+		current, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, errors.Wrap(err)
+		}
+		if bytes.Equal(current, formatted) {
+			continue
+		}
+		changed = append(changed, file)
+		if !write {
+			continue
+		}
+		if err := ioutil.WriteFile(file, formatted, info.Mode()); err != nil {
+			return nil, errors.Wrap(err)
+		}
+	}
+	return changed, nil
+}
+
+// indent re-encodes the yaml documents in b indented by spaces.
+func indent(b []byte, spaces int) ([]byte, error) {
+	d := yaml.NewDecoder(bytes.NewReader(b))
+	out := &bytes.Buffer{}
+	e := yaml.NewEncoder(out)
+	e.SetIndent(spaces)
+	for {
+		doc := &yaml.Node{}
+		if err := d.Decode(doc); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, errors.Wrap(err)
+		}
+		if err := e.Encode(doc); err != nil {
+			return nil, errors.Wrap(err)
+		}
+	}
+	if err := e.Close(); err != nil {
+		return nil, errors.Wrap(err)
+	}
+	return out.Bytes(), nil
+}
@@ -0,0 +1,226 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package format_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/GoogleContainerTools/kpt/internal/util/format"
+	"github.com/GoogleContainerTools/kpt/pkg/kptfile"
+	"github.com/stretchr/testify/assert"
+)
+
+const deployment = `kind: Deployment
+apiVersion: apps/v1
+metadata:
+  name: app # {"$kpt-set":"name"}
+  labels:
+    version: "1.0"
+    tier: 'web'
+spec:
+  replicas: 3
+  template:
+    spec:
+      containers:
+      - name: nginx
+        image: "nginx:1.19"
+        args: ["--port", "8080", "--log-level", "debug", "--log-format", "json"]
+        command:
+        - nginx
+        ports:
+        - containerPort: 80
+`
+
+func TestPackage(t *testing.T) {
+	var tests = []struct {
+		name     string
+		policy   kptfile.Formatting
+		expected string
+	}{
+		{
+			name: "default",
+			expected: `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app # {"$kpt-set":"name"}
+  labels:
+    tier: 'web'
+    version: "1.0"
+spec:
+  replicas: 3
+  template:
+    spec:
+      containers:
+      - name: nginx
+        image: "nginx:1.19"
+        command:
+        - nginx
+        args: ["--port", "8080", "--log-level", "debug", "--log-format", "json"]
+        ports:
+        - containerPort: 80
+`,
+		},
+		{
+			name: "preserve minimal block",
+			policy: kptfile.Formatting{
+				FieldOrder: kptfile.FieldOrderPreserve,
+				ShortLists: kptfile.ListStyleBlock,
+				Quoting:    kptfile.QuotingMinimal,
+			},
+			expected: `kind: Deployment
+apiVersion: apps/v1
+metadata:
+  name: app # {"$kpt-set":"name"}
+  labels:
+    version: "1.0"
+    tier: web
+spec:
+  replicas: 3
+  template:
+    spec:
+      containers:
+      - name: nginx
+        image: nginx:1.19
+        args:
+        - --port
+        - "8080"
+        - --log-level
+        - debug
+        - --log-format
+        - json
+        command:
+        - nginx
+        ports:
+        - containerPort: 80
+`,
+		},
+		{
+			name: "alphabetical single flow indent",
+			policy: kptfile.Formatting{
+				FieldOrder: kptfile.FieldOrderAlphabetical,
+				Indent:     4,
+				ShortLists: kptfile.ListStyleFlow,
+				Quoting:    kptfile.QuotingSingle,
+			},
+			expected: `apiVersion: 'apps/v1'
+kind: 'Deployment'
+metadata:
+    labels:
+        tier: 'web'
+        version: '1.0'
+    name: 'app' # {"$kpt-set":"name"}
+spec:
+    replicas: 3
+    template:
+        spec:
+            containers:
+              - args:
+                  - '--port'
+                  - '8080'
+                  - '--log-level'
+                  - 'debug'
+                  - '--log-format'
+                  - 'json'
+                command: ['nginx']
+                image: 'nginx:1.19'
+                name: 'nginx'
+                ports:
+                  - containerPort: 80
+`,
+		},
+	}
+	for i := range tests {
+		test := tests[i]
+		t.Run(test.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "format")
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			defer os.RemoveAll(dir)
+			file := filepath.Join(dir, "deploy.yaml")
+			if !assert.NoError(t, ioutil.WriteFile(file, []byte(deployment), 0600)) {
+				t.FailNow()
+			}
+
+			// checking doesn't change the files
+			files, err := Package(dir, test.policy, false, false)
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			assert.Equal(t, []string{file}, files)
+			b, err := ioutil.ReadFile(file)
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			assert.Equal(t, deployment, string(b))
+
+			files, err = Package(dir, test.policy, false, true)
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			assert.Equal(t, []string{file}, files)
+			b, err = ioutil.ReadFile(file)
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			assert.Equal(t, test.expected, string(b))
+
+			// formatting is idempotent
+			files, err = Package(dir, test.policy, false, false)
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			assert.Empty(t, files)
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	assert.NoError(t, Validate(kptfile.Formatting{}))
+	var tests = []struct {
+		policy   kptfile.Formatting
+		expected string
+	}{
+		{
+			policy:   kptfile.Formatting{FieldOrder: "random"},
+			expected: `unsupported fieldOrder "random", must be one of kubernetes, alphabetical, preserve`,
+		},
+		{
+			policy:   kptfile.Formatting{Indent: 3},
+			expected: `unsupported indent 3, must be 2 or 4`,
+		},
+		{
+			policy:   kptfile.Formatting{ShortLists: "inline"},
+			expected: `unsupported shortLists "inline", must be one of flow, block`,
+		},
+		{
+			policy:   kptfile.Formatting{ShortListMaxLength: -1},
+			expected: `shortListMaxLength must not be negative, got -1`,
+		},
+		{
+			policy:   kptfile.Formatting{Quoting: "all"},
+			expected: `unsupported quoting "all", must be one of minimal, double, single`,
+		},
+	}
+	for _, test := range tests {
+		err := Validate(test.policy)
+		if assert.Error(t, err) {
+			assert.Equal(t, test.expected, err.Error())
+		}
+	}
+}
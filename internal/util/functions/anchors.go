@@ -0,0 +1,269 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package functions
+
+import (
+	"strings"
+
+	"sigs.k8s.io/kustomize/kyaml/errors"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/kio/kioutil"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// WithAnchors returns a filter running f which restores the yaml aliases f
+// expands in the resources it outputs.  Functions generally decode the
+// resources, which replaces each alias with a copy of its anchor, so
+// without restoring them running a function changes every alias of a
+// package.
+func WithAnchors(f kio.Filter) kio.Filter {
+	return kio.FilterFunc(func(nodes []*yaml.RNode) ([]*yaml.RNode, error) {
+		a := RecordAnchors(nodes)
+		nodes, err := f.Filter(nodes)
+		if err != nil {
+			return nil, err
+		}
+		a.Restore(nodes)
+		return nodes, nil
+	})
+}
+
+// PreserveAnchors calls run, which changes the resources of the package at
+// path, and restores the yaml aliases expanded by it.
+func PreserveAnchors(path string, run func() error) error {
+	rw := &kio.LocalPackageReadWriter{PackagePath: path, IncludeSubpackages: true, NoDeleteFiles: true}
+	nodes, err := rw.Read()
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	a := RecordAnchors(nodes)
+	if len(a) == 0 {
+		return run()
+	}
+	if err := run(); err != nil {
+		return err
+	}
+
+	if nodes, err = rw.Read(); err != nil {
+		return errors.Wrap(err)
+	}
+	changed := map[string]bool{}
+	for _, n := range nodes {
+		if a.Restore([]*yaml.RNode{n}) > 0 {
+			file, _, _ := kioutil.GetFileAnnotations(n)
+			changed[file] = true
+		}
+	}
+	var out []*yaml.RNode
+	for _, n := range nodes {
+		if file, _, _ := kioutil.GetFileAnnotations(n); changed[file] {
+			out = append(out, n)
+		}
+	}
+	return errors.Wrap(rw.Write(out))
+}
+
+// Anchors are the yaml anchors of resources, keyed by resource.
+type Anchors map[string][]anchor
+
+// anchor is a yaml anchor of a resource and its aliases.
+type anchor struct {
+	name    string
+	path    []interface{}
+	aliases []alias
+}
+
+// alias is an alias of an anchor.
+type alias struct {
+	path []interface{}
+
+	// the comments of the alias, which are restored with it
+	head, line, foot string
+}
+
+// RecordAnchors records the anchors of nodes which have aliases.
+func RecordAnchors(nodes []*yaml.RNode) Anchors {
+	a := Anchors{}
+	for _, n := range nodes {
+		anchors := map[string]*anchor{}
+		var names []string
+		walk(n.YNode(), nil, func(n *yaml.Node, p []interface{}) {
+			switch {
+			case n.Kind == yaml.AliasNode:
+				if x := anchors[n.Value]; x != nil {
+					x.aliases = append(x.aliases, alias{path: p,
+						head: n.HeadComment, line: n.LineComment, foot: n.FootComment})
+				}
+			case n.Anchor != "":
+				if anchors[n.Anchor] == nil {
+					names = append(names, n.Anchor)
+				}
+				anchors[n.Anchor] = &anchor{name: n.Anchor, path: p}
+			}
+		})
+		for _, name := range names {
+			if x := anchors[name]; len(x.aliases) > 0 {
+				a[resourceKey(n)] = append(a[resourceKey(n)], *x)
+			}
+		}
+	}
+	return a
+}
+
+// Restore restores the anchors of a in nodes, returning the number of
+// aliases restored.  Aliases are only restored where the value is still a
+// copy of the anchor, as values the function changed are no longer the
+// same, and anchors the function kept or moved after their aliases are
+// left as they are.
+func (a Anchors) Restore(nodes []*yaml.RNode) int {
+	count := 0
+	for _, n := range nodes {
+		anchors := a[resourceKey(n)]
+		if len(anchors) == 0 {
+			continue
+		}
+		// the anchors and aliases in n, and the order of its nodes
+		kept := map[string]bool{}
+		order := map[*yaml.Node]int{}
+		walk(n.YNode(), nil, func(n *yaml.Node, _ []interface{}) {
+			order[n] = len(order)
+			if n.Anchor != "" {
+				kept[n.Anchor] = true
+			}
+			if n.Kind == yaml.AliasNode {
+				kept[n.Value] = true
+			}
+		})
+		for _, x := range anchors {
+			if kept[x.name] {
+				continue
+			}
+			target, _, _ := lookup(n.YNode(), x.path)
+			if target == nil || target.Kind == yaml.AliasNode {
+				continue
+			}
+			for _, al := range x.aliases {
+				node, parent, i := lookup(n.YNode(), al.path)
+				if node == nil || order[node] < order[target] || !equal(node, target) {
+					continue
+				}
+				parent.Content[i] = &yaml.Node{Kind: yaml.AliasNode, Value: x.name, Alias: target,
+					HeadComment: al.head, LineComment: al.line, FootComment: al.foot}
+				target.Anchor = x.name
+				count++
+			}
+		}
+	}
+	return count
+}
+
+// resourceKey returns the key of the resource n, its file and index if it
+// was read from a package, or else its identity.
+func resourceKey(n *yaml.RNode) string {
+	if file, index, _ := kioutil.GetFileAnnotations(n); file != "" {
+		return file + "[" + index + "]"
+	}
+	meta, _ := n.GetMeta()
+	return strings.Join([]string{meta.APIVersion, meta.Kind, meta.Namespace, meta.Name}, "/")
+}
+
+// walk calls fn for n and its descendants in document order, with their
+// paths of mapping keys and list indices.  Aliases aren't followed.
+func walk(n *yaml.Node, p []interface{}, fn func(*yaml.Node, []interface{})) {
+	fn(n, p)
+	switch n.Kind {
+	case yaml.DocumentNode:
+		for _, c := range n.Content {
+			walk(c, p, fn)
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			walk(n.Content[i+1], append(p[:len(p):len(p)], n.Content[i].Value), fn)
+		}
+	case yaml.SequenceNode:
+		for i, c := range n.Content {
+			walk(c, append(p[:len(p):len(p)], i), fn)
+		}
+	}
+}
+
+// lookup returns the node at path p of n, its parent and its index in the
+// content of the parent, or nil if there is no node at p.
+func lookup(n *yaml.Node, p []interface{}) (*yaml.Node, *yaml.Node, int) {
+	var parent *yaml.Node
+	index := -1
+	for _, s := range p {
+		if n.Kind == yaml.DocumentNode && len(n.Content) == 1 {
+			n = n.Content[0]
+		}
+		parent, index = n, -1
+		switch s := s.(type) {
+		case string:
+			if n.Kind != yaml.MappingNode {
+				return nil, nil, -1
+			}
+			for i := 0; i+1 < len(n.Content); i += 2 {
+				if n.Content[i].Value == s {
+					index = i + 1
+				}
+			}
+		case int:
+			if n.Kind == yaml.SequenceNode && s < len(n.Content) {
+				index = s
+			}
+		}
+		if index < 0 {
+			return nil, nil, -1
+		}
+		n = n.Content[index]
+	}
+	return n, parent, index
+}
+
+// equal returns true if a and b have the same value, regardless of their
+// style, comments and the order of their fields.
+func equal(a, b *yaml.Node) bool {
+	for a.Kind == yaml.AliasNode && a.Alias != nil {
+		a = a.Alias
+	}
+	for b.Kind == yaml.AliasNode && b.Alias != nil {
+		b = b.Alias
+	}
+	if a.Kind != b.Kind || len(a.Content) != len(b.Content) {
+		return false
+	}
+	switch a.Kind {
+	case yaml.ScalarNode:
+		return a.Value == b.Value && a.ShortTag() == b.ShortTag()
+	case yaml.MappingNode:
+		fields := map[string]*yaml.Node{}
+		for i := 0; i+1 < len(b.Content); i += 2 {
+			fields[b.Content[i].Value] = b.Content[i+1]
+		}
+		for i := 0; i+1 < len(a.Content); i += 2 {
+			f, found := fields[a.Content[i].Value]
+			if !found || !equal(a.Content[i+1], f) {
+				return false
+			}
+		}
+	default:
+		for i := range a.Content {
+			if !equal(a.Content[i], b.Content[i]) {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,133 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package functions_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/GoogleContainerTools/kpt/internal/util/functions"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+const anchorsResource = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  labels: &labels
+    app: web
+spec:
+  selector:
+    matchLabels: *labels # selector
+  template:
+    metadata:
+      labels: *labels
+    spec:
+      containers:
+      - name: web
+        env: &env
+        - name: A
+          value: "1"
+      - name: sidecar
+        env: *env
+`
+
+// expand is a function which expands the aliases of the resources, like
+// functions decoding them do, and sets a label of the pod template.
+var expand = kio.FilterFunc(func(nodes []*yaml.RNode) ([]*yaml.RNode, error) {
+	var out []*yaml.RNode
+	for _, n := range nodes {
+		var v interface{}
+		if err := yaml.Unmarshal([]byte(n.MustString()), &v); err != nil {
+			return nil, err
+		}
+		b, err := yaml.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		e, err := yaml.Parse(string(b))
+		if err != nil {
+			return nil, err
+		}
+		if err := e.PipeE(yaml.Lookup("spec", "template", "metadata", "labels"),
+			yaml.SetField("tier", yaml.NewScalarRNode("frontend"))); err != nil {
+			return nil, err
+		}
+		out = append(out, e)
+	}
+	return out, nil
+})
+
+func TestWithAnchors(t *testing.T) {
+	nodes, err := WithAnchors(expand).Filter([]*yaml.RNode{yaml.MustParse(anchorsResource)})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	if assert.Len(t, nodes, 1) {
+		assert.Equal(t, `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  labels: &labels
+    app: web
+  name: web
+spec:
+  selector:
+    matchLabels: *labels # selector
+  template:
+    metadata:
+      labels:
+        app: web
+        tier: frontend
+    spec:
+      containers:
+      - env: &env
+        - name: A
+          value: "1"
+        name: web
+      - env: *env
+        name: sidecar
+`, nodes[0].MustString())
+	}
+}
+
+func TestPreserveAnchors(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kpt-anchors-test")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir)
+	if !assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "web.yaml"), []byte(anchorsResource), 0600)) {
+		t.FailNow()
+	}
+
+	rw := &kio.LocalPackageReadWriter{PackagePath: dir}
+	err = PreserveAnchors(dir, func() error {
+		return kio.Pipeline{Inputs: []kio.Reader{rw}, Filters: []kio.Filter{expand}, Outputs: []kio.Writer{rw}}.Execute()
+	})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	b, err := ioutil.ReadFile(filepath.Join(dir, "web.yaml"))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Contains(t, string(b), "matchLabels: *labels # selector\n")
+	assert.Contains(t, string(b), "- env: *env\n")
+	assert.Contains(t, string(b), "tier: frontend\n")
+}
@@ -0,0 +1,162 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package functions
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"github.com/GoogleContainerTools/kpt/internal/util/cmdutil"
+	"github.com/GoogleContainerTools/kpt/pkg/fncache"
+	"sigs.k8s.io/kustomize/kyaml/errors"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// CacheEnv is the name of the environment variable configuring the cache of
+// function results -- either a directory, or the url of a cache server
+// started with `kpt fn cache-server`.  Results aren't cached if it is unset.
+const CacheEnv = "KPT_FN_CACHE"
+
+// CacheTokenEnv is the name of the environment variable containing the
+// token to authenticate with the cache server.
+const CacheTokenEnv = "KPT_FN_CACHE_TOKEN"
+
+// CacheFromEnv returns the cache configured by CacheEnv, or nil if results
+// aren't cached.
+func CacheFromEnv() fncache.Cache {
+	v := os.Getenv(CacheEnv)
+	if v == "" {
+		return nil
+	}
+	if u, err := url.Parse(v); err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+		return &fncache.Remote{URL: v, Token: os.Getenv(CacheTokenEnv)}
+	}
+	return &fncache.Dir{Path: v}
+}
+
+// cacheWarning makes sure the cache failing is only reported once.
+var cacheWarning sync.Once
+
+// WithCache returns a filter which looks up the result of f in cache before
+// running it, and caches the result if it wasn't cached.  id identifies f,
+// including its config, so only results of the same function against the
+// same resources are reused.  Failing to reach the cache is reported, but
+// doesn't fail the filter.
+func WithCache(cache fncache.Cache, id string, f kio.Filter) kio.Filter {
+	if cache == nil {
+		return f
+	}
+	return kio.FilterFunc(func(nodes []*yaml.RNode) ([]*yaml.RNode, error) {
+		input, err := serialize(nodes)
+		if err != nil {
+			return nil, err
+		}
+		key := fncache.Key(id, []byte(input))
+		output, found, err := cache.Get(key)
+		if err != nil {
+			warnCache(err)
+		}
+		if found {
+			return kio.FromBytes(output)
+		}
+
+		nodes, err = f.Filter(nodes)
+		if err != nil {
+			return nil, err
+		}
+		result, err := serialize(nodes)
+		if err != nil {
+			return nil, err
+		}
+		if err := cache.Put(key, []byte(result)); err != nil {
+			warnCache(err)
+		}
+		return nodes, nil
+	})
+}
+
+// WithImageCache is WithCache for the function container image, keyed by
+// the digest of the image rather than its tag, so results aren't reused once
+// the tag is pushed again.  Results aren't cached if the digest can't be
+// resolved, e.g. as the image hasn't been pulled yet.
+func WithImageCache(cache fncache.Cache, image, config string, f kio.Filter) kio.Filter {
+	if cache == nil {
+		return f
+	}
+	return kio.FilterFunc(func(nodes []*yaml.RNode) ([]*yaml.RNode, error) {
+		digest, err := ImageDigest(image)
+		if err != nil {
+			return f.Filter(nodes)
+		}
+		return WithCache(cache, "image:"+digest+"\n"+config, f).Filter(nodes)
+	})
+}
+
+// ImageDigest returns the digest of image as run by the container runtime
+// chosen by --container-runtime -- the digest it is pinned to, or the id of
+// the local copy of the image.
+var ImageDigest = func(image string) (string, error) {
+	if i := strings.Index(image, "@sha256:"); i >= 0 {
+		return image[i+1:], nil
+	}
+	r, err := GetContainerRuntime(cmdutil.ContainerRuntime)
+	if err != nil {
+		return "", err
+	}
+	if r == Kubernetes {
+		return "", errors.Errorf("image %s isn't pinned to a digest", image)
+	}
+	b, err := exec.Command(string(r), "image", "inspect", image).Output()
+	if err != nil {
+		return "", errors.Errorf("failed to inspect image %s: %v", image, err)
+	}
+	var inspect []struct {
+		ID string `json:"Id"`
+	}
+	if err := json.Unmarshal(b, &inspect); err != nil {
+		return "", errors.Wrap(err)
+	}
+	if len(inspect) == 0 || !strings.HasPrefix(inspect[0].ID, "sha256:") {
+		return "", errors.Errorf("failed to inspect image %s: no image id", image)
+	}
+	return inspect[0].ID, nil
+}
+
+// serialize returns nodes as a multi-document yaml stream, including
+// their annotations.
+func serialize(nodes []*yaml.RNode) (string, error) {
+	var docs []string
+	for i := range nodes {
+		s, err := nodes[i].String()
+		if err != nil {
+			return "", err
+		}
+		docs = append(docs, s)
+	}
+	return strings.Join(docs, "---\n"), nil
+}
+
+// warnCache reports err the first time the cache fails.
+func warnCache(err error) {
+	cacheWarning.Do(func() {
+		fmt.Fprintf(os.Stderr, "function result cache unavailable, running functions: %v\n", err)
+	})
+}
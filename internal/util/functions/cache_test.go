@@ -0,0 +1,129 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package functions_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	. "github.com/GoogleContainerTools/kpt/internal/util/functions"
+	"github.com/GoogleContainerTools/kpt/pkg/fncache"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// TestWithCache verifies that functions only run against input they weren't
+// run against before, and cached results are returned otherwise.
+func TestWithCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kpt-fn-cache-test")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir)
+
+	runs := 0
+	fn := kio.FilterFunc(func(nodes []*yaml.RNode) ([]*yaml.RNode, error) {
+		runs++
+		for i := range nodes {
+			if err := nodes[i].PipeE(yaml.SetLabel("app", "cached")); err != nil {
+				return nil, err
+			}
+		}
+		return nodes, nil
+	})
+	fltr := WithCache(&fncache.Dir{Path: dir}, "label", fn)
+
+	input := func(name string) []*yaml.RNode {
+		return []*yaml.RNode{yaml.MustParse("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: " + name + "\n")}
+	}
+	var outputs []string
+	for _, name := range []string{"a", "a", "b"} {
+		nodes, err := fltr.Filter(input(name))
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		if assert.Len(t, nodes, 1) {
+			outputs = append(outputs, nodes[0].MustString())
+		}
+	}
+	assert.Equal(t, 2, runs)
+	assert.Equal(t, outputs[0], outputs[1])
+	assert.Equal(t, `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: a
+  labels:
+    app: 'cached'
+`, outputs[0])
+
+	// results of other functions aren't reused
+	_, err = WithCache(&fncache.Dir{Path: dir}, "other", fn).Filter(input("a"))
+	assert.NoError(t, err)
+	assert.Equal(t, 3, runs)
+}
+
+// TestWithImageCache verifies that results are keyed by the image digest, so
+// they aren't reused once the tag is pushed again.
+func TestWithImageCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kpt-fn-cache-test")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir)
+
+	// pinned images are resolved without the container runtime
+	d, err := ImageDigest("gcr.io/example/fn@sha256:abcd")
+	assert.NoError(t, err)
+	assert.Equal(t, "sha256:abcd", d)
+
+	digest, digestErr := "sha256:aaaa", error(nil)
+	defer func(f func(string) (string, error)) { ImageDigest = f }(ImageDigest)
+	ImageDigest = func(image string) (string, error) {
+		assert.Equal(t, "gcr.io/example/fn:v1", image)
+		return digest, digestErr
+	}
+
+	runs := 0
+	fn := kio.FilterFunc(func(nodes []*yaml.RNode) ([]*yaml.RNode, error) {
+		runs++
+		return nodes, nil
+	})
+	fltr := WithImageCache(&fncache.Dir{Path: dir}, "gcr.io/example/fn:v1", "", fn)
+	input := []*yaml.RNode{yaml.MustParse("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: a\n")}
+
+	run := func() {
+		_, err := fltr.Filter(input)
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+	}
+	run()
+	run()
+	assert.Equal(t, 1, runs)
+
+	// the tag was pushed again with different content
+	digest = "sha256:bbbb"
+	run()
+	assert.Equal(t, 2, runs)
+
+	// the digest can't be resolved -- the function is always run
+	digestErr = fmt.Errorf("no such image")
+	run()
+	run()
+	assert.Equal(t, 4, runs)
+}
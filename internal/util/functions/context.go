@@ -0,0 +1,128 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package functions
+
+import (
+	"path/filepath"
+	"strconv"
+
+	"github.com/GoogleContainerTools/kpt/pkg/kptfile"
+	"github.com/GoogleContainerTools/kpt/pkg/kptfile/kptfileutil"
+	"sigs.k8s.io/kustomize/kyaml/errors"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// PackageContextName is the name of the ConfigMap added to the input of
+// each function describing the package the function is run against.
+//
+//	apiVersion: v1
+//	kind: ConfigMap
+//	metadata:
+//	  name: kptfile.kpt.dev
+//	  annotations:
+//	    config.kubernetes.io/local-config: "true"
+//	data:
+//	  name: my-pkg
+//	  path: path/to/my-pkg
+//	  instance: "true"
+//	  upstreamRepo: https://github.com/example/repo
+//	  upstreamDirectory: /my-pkg
+//	  upstreamRef: v1.0.0
+//	  upstreamCommit: 8b8ecd5
+//
+// instance is "true" if the package was fetched from an upstream, and
+// "false" for abstract packages.  The upstream fields are only set for
+// instances.
+const PackageContextName = "kptfile.kpt.dev"
+
+// PackageContext returns the package context resource for the package at
+// path.
+func PackageContext(path string) (*yaml.RNode, error) {
+	k, err := kptfileutil.ReadFile(path)
+	if err != nil {
+		// packages without a Kptfile are abstract
+		k = kptfile.KptFile{}
+	}
+	name := k.Name
+	if name == "" {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return nil, errors.Wrap(err)
+		}
+		name = filepath.Base(abs)
+	}
+	upstream := k.Upstream.Git
+	instance := upstream.Repo != ""
+
+	data := map[string]string{
+		"name":     name,
+		"path":     filepath.ToSlash(filepath.Clean(path)),
+		"instance": strconv.FormatBool(instance),
+	}
+	if instance {
+		for key, value := range map[string]string{
+			"upstreamRepo":      upstream.Repo,
+			"upstreamDirectory": upstream.Directory,
+			"upstreamRef":       upstream.Ref,
+			"upstreamCommit":    upstream.Commit,
+		} {
+			if value != "" {
+				data[key] = value
+			}
+		}
+	}
+
+	n, err := yaml.Parse(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: ` + PackageContextName + `
+  annotations:
+    config.kubernetes.io/local-config: "true"
+`)
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	n.SetDataMap(data)
+	return n, nil
+}
+
+// WithPackageContext returns a filter which adds the package context to the
+// input of f, and removes it from the output.
+func WithPackageContext(context *yaml.RNode, f kio.Filter) kio.Filter {
+	return kio.FilterFunc(func(nodes []*yaml.RNode) ([]*yaml.RNode, error) {
+		nodes, err := f.Filter(append(nodes, context.Copy()))
+		if err != nil {
+			return nil, err
+		}
+		var out []*yaml.RNode
+		for i := range nodes {
+			if !isPackageContext(nodes[i]) {
+				out = append(out, nodes[i])
+			}
+		}
+		return out, nil
+	})
+}
+
+// isPackageContext returns true if n is the package context resource.
+func isPackageContext(n *yaml.RNode) bool {
+	meta, err := n.GetMeta()
+	if err != nil {
+		return false
+	}
+	return meta.APIVersion == "v1" && meta.Kind == "ConfigMap" &&
+		meta.Name == PackageContextName
+}
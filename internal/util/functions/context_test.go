@@ -0,0 +1,155 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package functions_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt/internal/util/functions"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+func TestPackageContext(t *testing.T) {
+	tests := []struct {
+		name     string
+		kptfile  string
+		expected string
+	}{
+		{
+			name: "instance",
+			kptfile: `
+apiVersion: kpt.dev/v1alpha1
+kind: Kptfile
+metadata:
+  name: my-pkg
+upstream:
+  type: git
+  git:
+    repo: https://github.com/example/repo
+    directory: /my-pkg
+    ref: v1.0.0
+    commit: 8b8ecd5
+`,
+			expected: `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: kptfile.kpt.dev
+  annotations:
+    config.kubernetes.io/local-config: "true"
+data:
+  instance: "true"
+  name: my-pkg
+  path: pkg
+  upstreamCommit: 8b8ecd5
+  upstreamDirectory: /my-pkg
+  upstreamRef: v1.0.0
+  upstreamRepo: https://github.com/example/repo
+`,
+		},
+		{
+			name: "abstract",
+			expected: `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: kptfile.kpt.dev
+  annotations:
+    config.kubernetes.io/local-config: "true"
+data:
+  instance: "false"
+  name: pkg
+  path: pkg
+`,
+		},
+	}
+	for i := range tests {
+		test := tests[i]
+		t.Run(test.name, func(t *testing.T) {
+			d, err := ioutil.TempDir("", "kpt")
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			defer os.RemoveAll(d)
+			cwd, err := os.Getwd()
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			defer func() { _ = os.Chdir(cwd) }()
+			if !assert.NoError(t, os.Chdir(d)) {
+				t.FailNow()
+			}
+			if !assert.NoError(t, os.Mkdir("pkg", 0700)) {
+				t.FailNow()
+			}
+			if test.kptfile != "" {
+				err := ioutil.WriteFile(filepath.Join("pkg", "Kptfile"), []byte(test.kptfile), 0600)
+				if !assert.NoError(t, err) {
+					t.FailNow()
+				}
+			}
+
+			n, err := functions.PackageContext("pkg")
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			actual, err := n.String()
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			assert.Equal(t, strings.TrimSpace(test.expected), strings.TrimSpace(actual))
+		})
+	}
+}
+
+func TestWithPackageContext(t *testing.T) {
+	context := yaml.MustParse(`
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: kptfile.kpt.dev
+data:
+  name: my-pkg
+`)
+	var input []*yaml.RNode
+	f := functions.WithPackageContext(context, kio.FilterFunc(
+		func(nodes []*yaml.RNode) ([]*yaml.RNode, error) {
+			input = nodes
+			return nodes, nil
+		}))
+
+	deploy := yaml.MustParse(`
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: nginx
+`)
+	output, err := f.Filter([]*yaml.RNode{deploy})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	if assert.Len(t, input, 2) {
+		meta, err := input[1].GetMeta()
+		assert.NoError(t, err)
+		assert.Equal(t, "kptfile.kpt.dev", meta.Name)
+	}
+	assert.Equal(t, []*yaml.RNode{deploy}, output)
+}
@@ -0,0 +1,234 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package functions
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"sigs.k8s.io/kustomize/kyaml/errors"
+	"sigs.k8s.io/kustomize/kyaml/fn/runtime/runtimeutil"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/kio/kioutil"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// EndpointTokenEnv is the name of the environment variable containing the
+// bearer token sent to function endpoints.  The token is only sent over
+// https.
+const EndpointTokenEnv = "KPT_FN_ENDPOINT_TOKEN"
+
+// EndpointCAEnv is the name of the environment variable containing the
+// path of a PEM bundle of the certificate authorities, in addition to the
+// system ones, trusted to sign the certificates of function endpoints.
+const EndpointCAEnv = "KPT_FN_ENDPOINT_CA"
+
+// EndpointCertEnv and EndpointKeyEnv are the names of the environment
+// variables containing the paths of the PEM client certificate and key
+// presented to function endpoints, for mutual TLS.
+const (
+	EndpointCertEnv = "KPT_FN_ENDPOINT_CERT"
+	EndpointKeyEnv  = "KPT_FN_ENDPOINT_KEY"
+)
+
+// DefaultEndpointTimeout is the time a function endpoint may take to
+// respond.
+const DefaultEndpointTimeout = 5 * time.Minute
+
+// GetEndpoint returns the http(s) url in the endpoint field of the
+// config.kubernetes.io/function annotation of the function config n, or
+// empty if it isn't a function served by an endpoint, e.g.
+//
+//	config.kubernetes.io/function: |
+//	  endpoint: https://functions.example.com/set-namespace
+func GetEndpoint(n *yaml.RNode) string {
+	meta, err := n.GetMeta()
+	if err != nil {
+		return ""
+	}
+	for _, key := range []string{runtimeutil.FunctionAnnotationKey, "config.k8s.io/function"} {
+		value, found := meta.Annotations[key]
+		if !found {
+			continue
+		}
+		var fn struct {
+			Endpoint string `yaml:"endpoint,omitempty"`
+		}
+		if err := yaml.Unmarshal([]byte(value), &fn); err != nil {
+			return ""
+		}
+		return fn.Endpoint
+	}
+	return ""
+}
+
+// EndpointFilter runs a function served by an endpoint.  The ResourceList
+// is POSTed to the endpoint as yaml, and the endpoint responds with the
+// resulting ResourceList.
+type EndpointFilter struct {
+	// Endpoint is the http(s) url of the function
+	Endpoint string
+
+	// Client is the client the endpoint is called with, and Token the
+	// bearer token sent to it.  They default to those of
+	// EndpointClientFromEnv.
+	Client *http.Client
+	Token  string
+
+	runtimeutil.FunctionFilter
+}
+
+func (f *EndpointFilter) Filter(nodes []*yaml.RNode) ([]*yaml.RNode, error) {
+	u, err := url.Parse(f.Endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, errors.Errorf("function endpoint %s must be an http(s) url", f.Endpoint)
+	}
+	client, token := f.Client, f.Token
+	if client == nil {
+		if client, token, err = EndpointClientFromEnv(); err != nil {
+			return nil, err
+		}
+	}
+	f.FunctionFilter.Run = func(reader io.Reader, writer io.Writer) error {
+		req, err := http.NewRequest(http.MethodPost, f.Endpoint, reader)
+		if err != nil {
+			return errors.Wrap(err)
+		}
+		req.Header.Set("Content-Type", "application/yaml")
+		req.Header.Set("Accept", "application/yaml")
+		if token != "" && u.Scheme == "https" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return errors.Errorf("function endpoint %s failed: %v", f.Endpoint, err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			b, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+			return errors.Errorf("function endpoint %s responded %s: %s",
+				f.Endpoint, resp.Status, strings.TrimSpace(string(b)))
+		}
+		_, err = io.Copy(writer, resp.Body)
+		return errors.Wrap(err)
+	}
+	return f.FunctionFilter.Filter(nodes)
+}
+
+func (f EndpointFilter) String() string {
+	return f.Endpoint
+}
+
+// EndpointClientFromEnv returns the client function endpoints are called
+// with, configured by EndpointCAEnv, EndpointCertEnv and EndpointKeyEnv,
+// and the token of EndpointTokenEnv.
+func EndpointClientFromEnv() (*http.Client, string, error) {
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+	if ca := os.Getenv(EndpointCAEnv); ca != "" {
+		b, err := ioutil.ReadFile(ca)
+		if err != nil {
+			return nil, "", errors.Wrap(err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(b) {
+			return nil, "", errors.Errorf("%s=%s contains no PEM certificates", EndpointCAEnv, ca)
+		}
+		config.RootCAs = pool
+	}
+	cert, key := os.Getenv(EndpointCertEnv), os.Getenv(EndpointKeyEnv)
+	if (cert == "") != (key == "") {
+		return nil, "", errors.Errorf("%s and %s must be set together", EndpointCertEnv, EndpointKeyEnv)
+	}
+	if cert != "" {
+		pair, err := tls.LoadX509KeyPair(cert, key)
+		if err != nil {
+			return nil, "", errors.Wrap(err)
+		}
+		config.Certificates = []tls.Certificate{pair}
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = config
+	client := &http.Client{Transport: transport, Timeout: DefaultEndpointTimeout}
+	return client, os.Getenv(EndpointTokenEnv), nil
+}
+
+// EndpointFunctions returns the function configs of the resources of the
+// package at path which are served by endpoints, deepest first.
+func EndpointFunctions(path string) ([]*yaml.RNode, error) {
+	nodes, err := (&kio.LocalPackageReader{PackagePath: path}).Read()
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	var fns []*yaml.RNode
+	for _, n := range nodes {
+		if GetEndpoint(n) != "" {
+			fns = append(fns, n)
+		}
+	}
+	depth := func(n *yaml.RNode) int {
+		p, _, _ := kioutil.GetFileAnnotations(n)
+		return strings.Count(filepath.ToSlash(p), "/")
+	}
+	sort.SliceStable(fns, func(i, j int) bool { return depth(fns[i]) > depth(fns[j]) })
+	return fns, nil
+}
+
+// RunEndpointFunctions runs the functions of the package at dir which are
+// served by endpoints.  Each function is run against the resources in the
+// directory of its function config and its subdirectories, or every
+// resource of the package if globalScope is set.  The results of each
+// function are written to resultsDir, if it is set, numbered after the
+// results already there.
+func RunEndpointFunctions(dir string, globalScope bool, resultsDir string) error {
+	fns, err := EndpointFunctions(dir)
+	if err != nil || len(fns) == 0 {
+		return err
+	}
+	next, err := NextResultsFile(resultsDir)
+	if err != nil {
+		return err
+	}
+	client, token, err := EndpointClientFromEnv()
+	if err != nil {
+		return err
+	}
+	var fltrs []kio.Filter
+	for i, fn := range fns {
+		fltrs = append(fltrs, &EndpointFilter{
+			Endpoint: GetEndpoint(fn),
+			Client:   client,
+			Token:    token,
+			FunctionFilter: runtimeutil.FunctionFilter{
+				FunctionConfig: fn,
+				GlobalScope:    globalScope,
+				ResultsFile:    ResultsFile(resultsDir, next+i),
+			},
+		})
+	}
+	rw := &kio.LocalPackageReadWriter{PackagePath: dir}
+	return errors.Wrap(kio.Pipeline{Inputs: []kio.Reader{rw}, Filters: fltrs, Outputs: []kio.Writer{rw}}.Execute())
+}
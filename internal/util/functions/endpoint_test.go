@@ -0,0 +1,192 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package functions_test
+
+import (
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/GoogleContainerTools/kpt/internal/util/functions"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// setEnv sets the environment variable key to value, returning a func
+// restoring it.
+func setEnv(t *testing.T, key, value string) func() {
+	old, found := os.LookupEnv(key)
+	if !assert.NoError(t, os.Setenv(key, value)) {
+		t.FailNow()
+	}
+	return func() {
+		if found {
+			os.Setenv(key, old)
+		} else {
+			os.Unsetenv(key)
+		}
+	}
+}
+
+// scaleHandler is a function endpoint which scales the replicas of the
+// resources from 1 to 3, recording the Authorization header of the
+// requests in auth.
+func scaleHandler(auth *[]string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		*auth = append(*auth, r.Header.Get("Authorization"))
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/yaml" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		b, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/yaml")
+		fmt.Fprint(w, strings.Replace(string(b), "replicas: 1", "replicas: 3", -1))
+	}
+}
+
+func TestRunEndpointFunctions(t *testing.T) {
+	var auth []string
+	s := httptest.NewTLSServer(scaleHandler(&auth))
+	defer s.Close()
+
+	dir, err := ioutil.TempDir("", "kpt-endpoint-test")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir)
+
+	// the server certificate is trusted through the CA bundle
+	ca := filepath.Join(dir, "ca.pem")
+	err = ioutil.WriteFile(ca, pem.EncodeToMemory(&pem.Block{
+		Type: "CERTIFICATE", Bytes: s.Certificate().Raw,
+	}), 0600)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer setEnv(t, EndpointCAEnv, ca)()
+	defer setEnv(t, EndpointTokenEnv, "secret")()
+
+	pkg := filepath.Join(dir, "pkg")
+	deploy := `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: %s
+spec:
+  replicas: 1
+`
+	for path, content := range map[string]string{
+		"app/fn.yaml": fmt.Sprintf(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: scale
+  annotations:
+    config.kubernetes.io/function: |
+      endpoint: %s/scale
+`, s.URL),
+		"app/deploy.yaml": fmt.Sprintf(deploy, "app"),
+		"db/deploy.yaml":  fmt.Sprintf(deploy, "db"),
+	} {
+		path = filepath.Join(pkg, path)
+		if !assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0700)) {
+			t.FailNow()
+		}
+		if !assert.NoError(t, ioutil.WriteFile(path, []byte(content), 0600)) {
+			t.FailNow()
+		}
+	}
+
+	if !assert.NoError(t, RunEndpointFunctions(pkg, false, "")) {
+		t.FailNow()
+	}
+	assert.Equal(t, []string{"Bearer secret"}, auth)
+	// the function is only run against the resources in its directory
+	b, err := ioutil.ReadFile(filepath.Join(pkg, "app", "deploy.yaml"))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Contains(t, string(b), "replicas: 3")
+	b, err = ioutil.ReadFile(filepath.Join(pkg, "db", "deploy.yaml"))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Contains(t, string(b), "replicas: 1")
+
+	if !assert.NoError(t, RunEndpointFunctions(pkg, true, "")) {
+		t.FailNow()
+	}
+	b, err = ioutil.ReadFile(filepath.Join(pkg, "db", "deploy.yaml"))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Contains(t, string(b), "replicas: 3")
+}
+
+func TestEndpointFilter(t *testing.T) {
+	var auth []string
+	s := httptest.NewServer(scaleHandler(&auth))
+	defer s.Close()
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "no such function", http.StatusNotFound)
+	}))
+	defer failing.Close()
+	defer setEnv(t, EndpointTokenEnv, "secret")()
+
+	nodes := []*yaml.RNode{yaml.MustParse("kind: Deployment\nspec:\n  replicas: 1\n")}
+	f := &EndpointFilter{Endpoint: s.URL}
+	out, err := f.Filter(nodes)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Contains(t, out[0].MustString(), "replicas: 3")
+	// the token is only sent over https
+	assert.Equal(t, []string{""}, auth)
+
+	f = &EndpointFilter{Endpoint: failing.URL}
+	_, err = f.Filter(nodes)
+	assert.EqualError(t, err, "function endpoint "+failing.URL+" responded 404 Not Found: no such function")
+
+	f = &EndpointFilter{Endpoint: "grpc://functions.example.com/scale"}
+	_, err = f.Filter(nodes)
+	assert.EqualError(t, err, "function endpoint grpc://functions.example.com/scale must be an http(s) url")
+}
+
+func TestEndpointClientFromEnv(t *testing.T) {
+	defer setEnv(t, EndpointCertEnv, "cert.pem")()
+	_, _, err := EndpointClientFromEnv()
+	assert.EqualError(t, err, EndpointCertEnv+" and "+EndpointKeyEnv+" must be set together")
+}
+
+func TestGetEndpoint(t *testing.T) {
+	n := yaml.MustParse(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: fn
+  annotations:
+    config.kubernetes.io/function: |
+      endpoint: https://functions.example.com/scale
+`)
+	assert.Equal(t, "https://functions.example.com/scale", GetEndpoint(n))
+	assert.Empty(t, GetEndpoint(yaml.MustParse("kind: ConfigMap\n")))
+}
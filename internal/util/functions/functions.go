@@ -15,11 +15,12 @@
 package functions
 
 import (
+	"io/ioutil"
 	"path/filepath"
 
 	"github.com/GoogleContainerTools/kpt/pkg/kptfile"
 	"github.com/GoogleContainerTools/kpt/pkg/kptfile/kptfileutil"
-	"sigs.k8s.io/kustomize/kyaml/fn/runtime/container"
+	"sigs.k8s.io/kustomize/kyaml/errors"
 	"sigs.k8s.io/kustomize/kyaml/fn/runtime/exec"
 	"sigs.k8s.io/kustomize/kyaml/fn/runtime/runtimeutil"
 	"sigs.k8s.io/kustomize/kyaml/fn/runtime/starlark"
@@ -28,23 +29,33 @@ import (
 	"sigs.k8s.io/kustomize/kyaml/yaml"
 )
 
+// RunFunctions runs functions against the package at path.
 func RunFunctions(path string, functions []kptfile.Function) error {
 	rw := &kio.LocalPackageReadWriter{
 		PackagePath:        path,
 		IncludeSubpackages: true,
 	}
+	context, err := PackageContext(path)
+	if err != nil {
+		return err
+	}
 
+	cache := CacheFromEnv()
 	var fltrs []kio.Filter
 	for i := range functions {
 		f := functions[i]
 		var e exec.Filter
 		e.FunctionConfig = yaml.NewRNode(&f.Config)
-		fltrs = append(fltrs, &container.Filter{
-			ContainerSpec: runtimeutil.ContainerSpec{
-				Image: f.Image,
-			},
-			Exec: e,
-		})
+		config, err := e.FunctionConfig.String()
+		if err != nil {
+			return errors.Wrap(err)
+		}
+		cf, err := ContainerFilter(runtimeutil.ContainerSpec{Image: f.Image}, e)
+		if err != nil {
+			return err
+		}
+		fltr := WithPackageContext(context, WithImageCache(cache, f.Image, config, cf))
+		fltrs = append(fltrs, WithAnchors(fltr))
 	}
 	if len(fltrs) == 0 {
 		return nil
@@ -54,13 +65,74 @@ func RunFunctions(path string, functions []kptfile.Function) error {
 		Execute()
 }
 
-// ReconcileFunctions runs functions specified by the Kptfile
+// RunHook runs the hook against the package at path, and its subpackages.
+func RunHook(path string, hook kptfile.Hook) error {
+	var config *yaml.RNode
+	if hook.Config.Kind != 0 {
+		config = yaml.NewRNode(&hook.Config)
+	}
+	configString := ""
+	if config != nil {
+		var err error
+		if configString, err = config.String(); err != nil {
+			return errors.Wrap(err)
+		}
+	}
+
+	var fltr kio.Filter
+	switch {
+	case hook.Image != "" && hook.Starlark != "":
+		return errors.Errorf("hook %s must set only one of image and starlark", hook)
+	case hook.Image != "":
+		var e exec.Filter
+		e.FunctionConfig = config
+		cf, err := ContainerFilter(runtimeutil.ContainerSpec{Image: hook.Image}, e)
+		if err != nil {
+			return err
+		}
+		fltr = WithImageCache(CacheFromEnv(), hook.Image, configString, cf)
+	case hook.Starlark != "":
+		program := filepath.Join(path, hook.Starlark)
+		sf := &starlark.Filter{Name: hook.String(), Path: program}
+		sf.FunctionConfig = config
+		sf.GlobalScope = true
+		fltr = sf
+		// the program is part of the key, so changing it invalidates its
+		// cached results
+		if b, err := ioutil.ReadFile(program); err == nil {
+			fltr = WithCache(CacheFromEnv(),
+				"starlark:"+hook.String()+"\n"+string(b)+"\n"+configString, fltr)
+		}
+	default:
+		return errors.Errorf("hook %s must set image or starlark", hook)
+	}
+
+	context, err := PackageContext(path)
+	if err != nil {
+		return err
+	}
+	rw := &kio.LocalPackageReadWriter{
+		PackagePath:        path,
+		IncludeSubpackages: true,
+	}
+	return kio.Pipeline{
+		Inputs:  []kio.Reader{rw},
+		Filters: []kio.Filter{WithPackageContext(context, fltr)},
+		Outputs: []kio.Writer{rw},
+	}.Execute()
+}
+
+// ReconcileFunctions applies the common metadata and runs functions
+// specified by the Kptfile
 func ReconcileFunctions(path string) error {
 	k, err := kptfileutil.ReadFile(path)
 	if err != nil {
 		// do nothing if the package doesn't have a Kptfile
 		return nil
 	}
+	if err := ApplyCommonMetadata(path); err != nil {
+		return err
+	}
 	if k.Functions.AutoRunStarlark {
 		err := runfn.RunFns{
 			EnableStarlark: k.Functions.AutoRunStarlark,
@@ -74,12 +146,23 @@ func ReconcileFunctions(path string) error {
 	}
 
 	if len(k.Functions.StarlarkFunctions) > 0 {
+		context, err := PackageContext(path)
+		if err != nil {
+			return err
+		}
+		cache := CacheFromEnv()
 		var fltrs []kio.Filter
 		for _, fn := range k.Functions.StarlarkFunctions {
-			fltrs = append(fltrs, &starlark.Filter{
+			var fltr kio.Filter = &starlark.Filter{
 				Name: fn.Name,
 				Path: filepath.Join(path, fn.Path),
-			})
+			}
+			// the program is part of the key, so changing it invalidates
+			// its cached results
+			if program, err := ioutil.ReadFile(filepath.Join(path, fn.Path)); err == nil {
+				fltr = WithCache(cache, "starlark:"+fn.Name+"\n"+string(program), fltr)
+			}
+			fltrs = append(fltrs, WithPackageContext(context, fltr))
 		}
 		rw := &kio.LocalPackageReadWriter{PackagePath: path}
 		err = kio.Pipeline{
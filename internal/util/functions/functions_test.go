@@ -314,6 +314,60 @@ spec:
 		},
 		err: "function path ../reconcile.star not allowed to start with ../",
 	},
+
+	// Test 3
+	{
+		name: "packageContext",
+		inputs: map[string]string{
+			"Kptfile": `
+apiVersion: kpt.dev/v1alpha1
+kind: Kptfile
+metadata:
+  name: my-pkg
+upstream:
+  type: git
+  git:
+    repo: https://github.com/example/repo
+    directory: /my-pkg
+    ref: v1.0.0
+functions:
+  starlarkFunctions:
+  - name: func
+    path: reconcile.star
+`,
+
+			"deploy.yaml": `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: nginx-deployment
+`,
+
+			"reconcile.star": `
+# annotate each resource with where the package came from
+def run(r):
+  pkg = [x for x in r if x["metadata"]["name"] == "kptfile.kpt.dev"][0]["data"]
+  for resource in r:
+    resource["metadata"]["annotations"]["name"] = pkg["name"]
+    resource["metadata"]["annotations"]["instance"] = pkg["instance"]
+    resource["metadata"]["annotations"]["upstream"] = pkg["upstreamRepo"] + "@" + pkg["upstreamRef"]
+
+run(ctx.resource_list["items"])
+`,
+		},
+		outputs: map[string]string{
+			"deploy.yaml": `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: nginx-deployment
+  annotations:
+    name: my-pkg
+    instance: "true"
+    upstream: https://github.com/example/repo@v1.0.0
+`,
+		},
+	},
 }
 
 type testCase struct {
@@ -0,0 +1,287 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package functions
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilrand "k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/remotecommand"
+	"sigs.k8s.io/kustomize/kyaml/errors"
+	"sigs.k8s.io/kustomize/kyaml/fn/runtime/runtimeutil"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// DefaultJobTimeout is the time the pod of a function Job may take to
+// start, e.g. pulling the image.
+const DefaultJobTimeout = 5 * time.Minute
+
+// jobContainer is the name of the function container in the Job pod.
+const jobContainer = "function"
+
+// NewJob returns the Job the kubernetes runtime runs functions with, for
+// the cluster and namespace of the kubeconfig flags.  It is set by the
+// root command.
+var NewJob func() (*Job, error)
+
+// GetJob returns the Job of NewJob.
+func GetJob() (*Job, error) {
+	if NewJob == nil {
+		return nil, errors.Errorf("the kubernetes container runtime needs a cluster")
+	}
+	return NewJob()
+}
+
+// Job runs function containers as Jobs in a cluster, for functions which
+// need access to the network next to the cluster, or when the local
+// machine can't run containers.  The ResourceList is sent to the stdin of
+// the pod, and the result read from its stdout, by attaching to the pod as
+// kubectl run -i does.  The Job is deleted once the function exits.
+//
+// Jobs run on the pod network, and can't mount local directories.
+type Job struct {
+	Client    kubernetes.Interface
+	Config    *rest.Config
+	Namespace string
+
+	// Timeout is the time the pod may take to start.  Defaults to
+	// DefaultJobTimeout.
+	Timeout time.Duration
+
+	// Attach streams stdin to the function container of pod, and its
+	// output to stdout and stderr, until the container exits.  Defaults
+	// to attaching through the API server.
+	Attach func(pod *corev1.Pod, stdin io.Reader, stdout, stderr io.Writer) error
+}
+
+// Run runs the function container spec as user, or the image user if user
+// is empty, with stdin as its input, writing its output to stdout and its
+// logs to stderr.
+func (j *Job) Run(spec runtimeutil.ContainerSpec, user string,
+	stdin io.Reader, stdout, stderr io.Writer) error {
+	if len(spec.StorageMounts) > 0 {
+		return errors.Errorf("the kubernetes container runtime can't mount directories")
+	}
+	job, err := j.job(spec, user)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	jobs := j.Client.BatchV1().Jobs(j.Namespace)
+	if job, err = jobs.Create(ctx, job, metav1.CreateOptions{}); err != nil {
+		return errors.WrapPrefixf(err, "unable to create the Job of %s", spec.Image)
+	}
+	defer func() {
+		propagation := metav1.DeletePropagationBackground
+		_ = jobs.Delete(ctx, job.Name, metav1.DeleteOptions{PropagationPolicy: &propagation})
+	}()
+
+	timeout := j.Timeout
+	if timeout == 0 {
+		timeout = DefaultJobTimeout
+	}
+	pod, err := j.waitForPod(job, timeout, func(pod *corev1.Pod) (bool, error) {
+		if pod.Status.Phase != corev1.PodPending {
+			return true, nil
+		}
+		for _, s := range pod.Status.ContainerStatuses {
+			if w := s.State.Waiting; w != nil && (w.Reason == "ErrImagePull" ||
+				w.Reason == "ImagePullBackOff" || w.Reason == "InvalidImageName") {
+				return false, errors.Errorf("unable to pull %s: %s", spec.Image, w.Message)
+			}
+		}
+		return false, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	attach := j.Attach
+	if attach == nil {
+		attach = j.attach
+	}
+	if pod.Status.Phase == corev1.PodRunning {
+		if err := attach(pod, stdin, stdout, stderr); err != nil {
+			return errors.WrapPrefixf(err, "unable to attach to %s", pod.Name)
+		}
+	}
+
+	// the container may still be stopping once the streams are closed
+	pod, err = j.waitForPod(job, timeout, func(pod *corev1.Pod) (bool, error) {
+		return pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed, nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, s := range pod.Status.ContainerStatuses {
+		if t := s.State.Terminated; s.Name == jobContainer && t != nil && t.ExitCode != 0 {
+			return errors.Errorf("function %s in pod %s exited with code %d",
+				spec.Image, pod.Name, t.ExitCode)
+		}
+	}
+	if pod.Status.Phase == corev1.PodFailed {
+		return errors.Errorf("function %s in pod %s failed: %s", spec.Image, pod.Name, pod.Status.Message)
+	}
+	return nil
+}
+
+// job returns the Job running the function container.
+func (j *Job) job(spec runtimeutil.ContainerSpec, user string) (*batchv1.Job, error) {
+	no := false
+	backoff := int32(0)
+	c := corev1.Container{
+		Name:            jobContainer,
+		Image:           spec.Image,
+		Stdin:           true,
+		StdinOnce:       true,
+		SecurityContext: &corev1.SecurityContext{AllowPrivilegeEscalation: &no},
+	}
+
+	env := runtimeutil.NewContainerEnvFromStringSlice(spec.Env)
+	for k, v := range env.EnvVars {
+		c.Env = append(c.Env, corev1.EnvVar{Name: k, Value: v})
+	}
+	// exported variables take their value from the environment of kpt
+	for _, k := range env.VarsToExport {
+		c.Env = append(c.Env, corev1.EnvVar{Name: k, Value: os.Getenv(k)})
+	}
+	sort.Slice(c.Env, func(i, j int) bool { return c.Env[i].Name < c.Env[j].Name })
+
+	// the pod can only run as a numeric user, nobody is conventionally 65534
+	if user == "nobody" {
+		user = "65534"
+	}
+	if user != "" {
+		parts := strings.SplitN(user, ":", 2)
+		uid, err := strconv.ParseInt(parts[0], 10, 64)
+		if err != nil {
+			return nil, errors.Errorf("the kubernetes container runtime requires a numeric user, not %q", user)
+		}
+		c.SecurityContext.RunAsUser = &uid
+		if len(parts) == 2 {
+			gid, err := strconv.ParseInt(parts[1], 10, 64)
+			if err != nil {
+				return nil, errors.Errorf("the kubernetes container runtime requires a numeric group, not %q", user)
+			}
+			c.SecurityContext.RunAsGroup = &gid
+		}
+	}
+
+	labels := map[string]string{"app.kubernetes.io/managed-by": "kpt"}
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "kpt-fn-" + utilrand.String(8),
+			Namespace: j.Namespace,
+			Labels:    labels,
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit: &backoff,
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec: corev1.PodSpec{
+					RestartPolicy: corev1.RestartPolicyNever,
+					Containers:    []corev1.Container{c},
+				},
+			},
+		},
+	}, nil
+}
+
+// waitForPod waits for done to return true for the pod of job, and returns
+// the pod.
+func (j *Job) waitForPod(job *batchv1.Job, timeout time.Duration,
+	done func(*corev1.Pod) (bool, error)) (*corev1.Pod, error) {
+	var pod *corev1.Pod
+	err := wait.PollImmediate(time.Second, timeout, func() (bool, error) {
+		pods, err := j.Client.CoreV1().Pods(j.Namespace).List(context.Background(),
+			metav1.ListOptions{LabelSelector: "job-name=" + job.Name})
+		if err != nil {
+			return false, err
+		}
+		if len(pods.Items) == 0 {
+			return false, nil
+		}
+		pod = &pods.Items[0]
+		return done(pod)
+	})
+	if err == wait.ErrWaitTimeout {
+		return nil, errors.Errorf("timed out waiting for the pod of Job %s", job.Name)
+	}
+	return pod, errors.Wrap(err)
+}
+
+// attach attaches to the pod through the API server.
+func (j *Job) attach(pod *corev1.Pod, stdin io.Reader, stdout, stderr io.Writer) error {
+	req := j.Client.CoreV1().RESTClient().Post().
+		Resource("pods").Namespace(pod.Namespace).Name(pod.Name).SubResource("attach").
+		VersionedParams(&corev1.PodAttachOptions{
+			Container: jobContainer,
+			Stdin:     true,
+			Stdout:    true,
+			Stderr:    true,
+		}, scheme.ParameterCodec)
+	e, err := remotecommand.NewSPDYExecutor(j.Config, "POST", req.URL())
+	if err != nil {
+		return err
+	}
+	return e.Stream(remotecommand.StreamOptions{Stdin: stdin, Stdout: stdout, Stderr: stderr})
+}
+
+// JobFilter runs a container function with the kubernetes runtime.
+type JobFilter struct {
+	runtimeutil.ContainerSpec
+
+	// User is the user the container is run as, or empty for the image
+	// user
+	User string
+
+	// Stderr receives the logs of the function.  Defaults to os.Stderr.
+	Stderr io.Writer
+
+	runtimeutil.FunctionFilter
+}
+
+func (f *JobFilter) Filter(nodes []*yaml.RNode) ([]*yaml.RNode, error) {
+	job, err := GetJob()
+	if err != nil {
+		return nil, err
+	}
+	stderr := f.Stderr
+	if stderr == nil {
+		stderr = os.Stderr
+	}
+	f.FunctionFilter.Run = func(reader io.Reader, writer io.Writer) error {
+		return job.Run(f.ContainerSpec, f.User, reader, writer, stderr)
+	}
+	return f.FunctionFilter.Filter(nodes)
+}
+
+func (f JobFilter) String() string {
+	return fmt.Sprintf("%s (kubernetes)", f.Image)
+}
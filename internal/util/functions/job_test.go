@@ -0,0 +1,159 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package functions_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	. "github.com/GoogleContainerTools/kpt/internal/util/functions"
+	"github.com/stretchr/testify/assert"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"sigs.k8s.io/kustomize/kyaml/fn/runtime/runtimeutil"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// fakeJob returns a Job whose pods start running once they are created,
+// and whose function scales the replicas from 1 to 3 and exits with code.
+func fakeJob(t *testing.T, code int32) (*Job, *fake.Clientset) {
+	client := fake.NewSimpleClientset()
+	client.PrependReactor("create", "jobs", func(a k8stesting.Action) (bool, runtime.Object, error) {
+		job := a.(k8stesting.CreateAction).GetObject().(*batchv1.Job)
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      job.Name + "-abcde",
+				Namespace: job.Namespace,
+				Labels:    map[string]string{"job-name": job.Name},
+			},
+			Spec:   job.Spec.Template.Spec,
+			Status: corev1.PodStatus{Phase: corev1.PodRunning},
+		}
+		return false, nil, client.Tracker().Add(pod)
+	})
+	attach := func(pod *corev1.Pod, stdin io.Reader, stdout, stderr io.Writer) error {
+		b, err := ioutil.ReadAll(stdin)
+		if err != nil {
+			return err
+		}
+		_, _ = io.WriteString(stderr, "scaling\n")
+		_, _ = io.WriteString(stdout, strings.Replace(string(b), "replicas: 1", "replicas: 3", -1))
+		pod.Status.Phase = corev1.PodSucceeded
+		if code != 0 {
+			pod.Status.Phase = corev1.PodFailed
+		}
+		pod.Status.ContainerStatuses = []corev1.ContainerStatus{{
+			Name:  "function",
+			State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{ExitCode: code}},
+		}}
+		_, err = client.CoreV1().Pods(pod.Namespace).UpdateStatus(context.Background(), pod, metav1.UpdateOptions{})
+		return err
+	}
+	return &Job{Client: client, Namespace: "fns", Attach: attach}, client
+}
+
+func TestJob_Run(t *testing.T) {
+	job, client := fakeJob(t, 0)
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	spec := runtimeutil.ContainerSpec{Image: "gcr.io/example/fn:v1", Env: []string{"FOO=bar"}}
+	err := job.Run(spec, "nobody", strings.NewReader("replicas: 1\n"), stdout, stderr)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, "replicas: 3\n", stdout.String())
+	assert.Equal(t, "scaling\n", stderr.String())
+
+	var created *batchv1.Job
+	var deleted bool
+	for _, a := range client.Actions() {
+		switch {
+		case a.Matches("create", "jobs"):
+			created = a.(k8stesting.CreateAction).GetObject().(*batchv1.Job)
+		case a.Matches("delete", "jobs"):
+			deleted = true
+		}
+	}
+	if !assert.NotNil(t, created) {
+		t.FailNow()
+	}
+	assert.True(t, deleted)
+	assert.Equal(t, "fns", created.Namespace)
+	assert.Equal(t, int32(0), *created.Spec.BackoffLimit)
+	c := created.Spec.Template.Spec.Containers[0]
+	assert.Equal(t, "gcr.io/example/fn:v1", c.Image)
+	assert.True(t, c.Stdin)
+	assert.True(t, c.StdinOnce)
+	assert.Equal(t, int64(65534), *c.SecurityContext.RunAsUser)
+	assert.False(t, *c.SecurityContext.AllowPrivilegeEscalation)
+	assert.Equal(t, []corev1.EnvVar{
+		{Name: "FOO", Value: "bar"},
+		{Name: "LOG_TO_STDERR", Value: "true"},
+		{Name: "STRUCTURED_RESULTS", Value: "true"},
+	}, c.Env)
+}
+
+func TestJob_Run_errors(t *testing.T) {
+	job, _ := fakeJob(t, 1)
+	spec := runtimeutil.ContainerSpec{Image: "gcr.io/example/fn:v1"}
+	err := job.Run(spec, "", strings.NewReader(""), ioutil.Discard, ioutil.Discard)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "exited with code 1")
+	}
+
+	err = job.Run(spec, "root", strings.NewReader(""), ioutil.Discard, ioutil.Discard)
+	assert.EqualError(t, err, `the kubernetes container runtime requires a numeric user, not "root"`)
+
+	spec.StorageMounts = []runtimeutil.StorageMount{{MountType: "bind", Src: "/a", DstPath: "/b"}}
+	err = job.Run(spec, "", strings.NewReader(""), ioutil.Discard, ioutil.Discard)
+	assert.EqualError(t, err, "the kubernetes container runtime can't mount directories")
+}
+
+func TestJobFilter(t *testing.T) {
+	job, _ := fakeJob(t, 0)
+	old := NewJob
+	NewJob = func() (*Job, error) { return job, nil }
+	defer func() { NewJob = old }()
+
+	f := &JobFilter{
+		ContainerSpec: runtimeutil.ContainerSpec{Image: "gcr.io/example/fn:v1"},
+		Stderr:        ioutil.Discard,
+	}
+	nodes, err := f.Filter([]*yaml.RNode{yaml.MustParse(`apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+spec:
+  replicas: 1
+`)})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	if !assert.Len(t, nodes, 1) {
+		t.FailNow()
+	}
+	replicas, err := nodes[0].Pipe(yaml.Lookup("spec", "replicas"))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, "3", yaml.GetValue(replicas))
+}
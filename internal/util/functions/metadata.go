@@ -0,0 +1,104 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package functions
+
+import (
+	"sort"
+
+	"github.com/GoogleContainerTools/kpt/pkg/kptfile"
+	"github.com/GoogleContainerTools/kpt/pkg/kptfile/kptfileutil"
+	"sigs.k8s.io/kustomize/kyaml/errors"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/pathutil"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// SkipCommonMetadataAnnotation opts a resource out of the commonLabels and
+// commonAnnotations of its package when set to "true".
+const SkipCommonMetadataAnnotation = "config.kpt.dev/skip-common-metadata"
+
+// localConfigAnnotation marks resources which aren't applied to the cluster,
+// e.g. function configs.
+const localConfigAnnotation = "config.kubernetes.io/local-config"
+
+// CommonMetadataFilter returns a filter which sets labels and annotations on
+// every resource, except local config and resources annotated with
+// SkipCommonMetadataAnnotation.
+func CommonMetadataFilter(labels, annotations map[string]string) kio.Filter {
+	return kio.FilterFunc(func(nodes []*yaml.RNode) ([]*yaml.RNode, error) {
+		for i := range nodes {
+			meta, err := nodes[i].GetMeta()
+			if err != nil {
+				return nil, err
+			}
+			if meta.Annotations[SkipCommonMetadataAnnotation] == "true" ||
+				meta.Annotations[localConfigAnnotation] == "true" {
+				continue
+			}
+			for _, key := range sortedKeys(labels) {
+				if err := nodes[i].PipeE(
+					yaml.LookupCreate(yaml.MappingNode, yaml.MetadataField, yaml.LabelsField),
+					yaml.SetField(key, yaml.NewStringRNode(labels[key]))); err != nil {
+					return nil, err
+				}
+			}
+			for _, key := range sortedKeys(annotations) {
+				if err := nodes[i].PipeE(
+					yaml.LookupCreate(yaml.MappingNode, yaml.MetadataField, yaml.AnnotationsField),
+					yaml.SetField(key, yaml.NewStringRNode(annotations[key]))); err != nil {
+					return nil, err
+				}
+			}
+		}
+		return nodes, nil
+	})
+}
+
+// ApplyCommonMetadata sets the commonLabels and commonAnnotations of each
+// package under path on the resources of that package.
+func ApplyCommonMetadata(path string) error {
+	paths, err := pathutil.DirsWithFile(path, kptfile.KptFileName, true)
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	for _, p := range paths {
+		k, err := kptfileutil.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		if len(k.CommonLabels) == 0 && len(k.CommonAnnotations) == 0 {
+			continue
+		}
+		rw := &kio.LocalPackageReadWriter{PackagePath: p, PackageFileName: kptfile.KptFileName}
+		err = kio.Pipeline{
+			Inputs:  []kio.Reader{rw},
+			Filters: []kio.Filter{CommonMetadataFilter(k.CommonLabels, k.CommonAnnotations)},
+			Outputs: []kio.Writer{rw},
+		}.Execute()
+		if err != nil {
+			return errors.WrapPrefixf(err, "failed to apply the common metadata of %q", p)
+		}
+	}
+	return nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
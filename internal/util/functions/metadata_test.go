@@ -0,0 +1,106 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package functions_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt/internal/util/functions"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyCommonMetadata(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kpt-test")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"Kptfile": `apiVersion: kpt.dev/v1alpha1
+kind: Kptfile
+metadata:
+  name: my-pkg
+commonLabels:
+  app: web
+  team: platform
+commonAnnotations:
+  owner: platform@example.com
+`,
+		"deploy.yaml": `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  labels:
+    app: old
+`,
+		"skip.yaml": `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: skip
+  annotations:
+    config.kpt.dev/skip-common-metadata: "true"
+`,
+		filepath.Join("sub", "Kptfile"): `apiVersion: kpt.dev/v1alpha1
+kind: Kptfile
+metadata:
+  name: sub
+`,
+		filepath.Join("sub", "cm.yaml"): `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: sub
+`,
+	}
+	for name, content := range files {
+		if !assert.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0700)) {
+			t.FailNow()
+		}
+		err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0600)
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+	}
+
+	if !assert.NoError(t, functions.ApplyCommonMetadata(dir)) {
+		t.FailNow()
+	}
+
+	expected := map[string]string{
+		"deploy.yaml": `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  labels:
+    app: web
+    team: platform
+  annotations:
+    owner: platform@example.com
+`,
+		"skip.yaml":                     files["skip.yaml"],
+		filepath.Join("sub", "cm.yaml"): files[filepath.Join("sub", "cm.yaml")],
+	}
+	for name, content := range expected {
+		b, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		assert.Equal(t, strings.TrimSpace(content), strings.TrimSpace(string(b)), name)
+	}
+}
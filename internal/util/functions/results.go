@@ -0,0 +1,243 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package functions
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/GoogleContainerTools/kpt/pkg/fnresult"
+	"sigs.k8s.io/kustomize/kyaml/errors"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/kio/kioutil"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// resultsFile matches the names of the files the results of each function
+// are written to, numbered in the order the functions run.
+var resultsFile = regexp.MustCompile(`^results-(\d+)\.yaml$`)
+
+// NextResultsFile returns the number of the next results file in dir, for
+// functions run after the functions whose results are already in dir.
+func NextResultsFile(dir string) (int, error) {
+	next := 0
+	if dir == "" {
+		return next, nil
+	}
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return next, errors.Wrap(err)
+	}
+	for _, f := range files {
+		if m := resultsFile.FindStringSubmatch(f.Name()); m != nil {
+			if n, _ := strconv.Atoi(m[1]); n >= next {
+				next = n + 1
+			}
+		}
+	}
+	return next, nil
+}
+
+// ResultsFile returns the path of the results file numbered n in dir, or
+// empty if dir is empty.
+func ResultsFile(dir string, n int) string {
+	if dir == "" {
+		return ""
+	}
+	return filepath.Join(dir, fmt.Sprintf("results-%d.yaml", n))
+}
+
+// ReadResults reads the results of the functions from the results files in
+// dir, in the order the functions ran.  Results without a name are named
+// by the number of their function.
+func ReadResults(dir string) ([]fnresult.Result, error) {
+	files, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	type numbered struct {
+		n    int
+		name string
+	}
+	var paths []numbered
+	for _, f := range files {
+		if m := resultsFile.FindStringSubmatch(f.Name()); m != nil {
+			n, _ := strconv.Atoi(m[1])
+			paths = append(paths, numbered{n: n, name: f.Name()})
+		}
+	}
+	sort.Slice(paths, func(i, j int) bool { return paths[i].n < paths[j].n })
+
+	var results []fnresult.Result
+	for _, p := range paths {
+		b, err := ioutil.ReadFile(filepath.Join(dir, p.name))
+		if err != nil {
+			return nil, errors.Wrap(err)
+		}
+		r, err := fnresult.Parse(b)
+		if err != nil {
+			return nil, errors.WrapPrefixf(err, "unable to parse results %s", p.name)
+		}
+		if r.Name == "" {
+			r.Name = fmt.Sprintf("function %d", p.n+1)
+		}
+		results = append(results, r)
+	}
+	return results, nil
+}
+
+// ResolveResults sets the file, and the line of the field or resource, of
+// the items which reference a resource of the package at dir without a
+// line.
+func ResolveResults(dir string, results []fnresult.Result) error {
+	var nodes []*yaml.RNode
+	// the lines of the resources are relative to their document, so are
+	// offset by the line the document starts on
+	offsets := map[string][]int{}
+	offset := func(path string, index int) int {
+		if _, found := offsets[path]; !found {
+			b, _ := ioutil.ReadFile(filepath.Join(dir, path))
+			offsets[path] = documentLines(string(b))
+		}
+		if index < len(offsets[path]) {
+			return offsets[path][index]
+		}
+		return 0
+	}
+	for i := range results {
+		for j := range results[i].Items {
+			item := &results[i].Items[j]
+			if item.ResourceRef == nil || (item.File != nil && item.File.Line > 0) {
+				continue
+			}
+			if nodes == nil {
+				var err error
+				nodes, err = (&kio.LocalPackageReader{PackagePath: dir}).Read()
+				if err != nil {
+					return errors.Wrap(err)
+				}
+			}
+			resolve(item, nodes, offset)
+		}
+	}
+	return nil
+}
+
+// documentLines returns the number of lines before each resource of the
+// yaml documents in content, indexed as kio.ByteReader indexes them.
+func documentLines(content string) []int {
+	var lines []int
+	line := 0
+	for _, value := range strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n---\n") {
+		var n yaml.Node
+		err := yaml.Unmarshal([]byte(value), &n)
+		if err == nil && len(n.Content) > 0 && !yaml.IsMissingOrNull(yaml.NewRNode(n.Content[0])) {
+			lines = append(lines, line)
+		}
+		// the document and the separator following it
+		line += strings.Count(value, "\n") + 2
+	}
+	return lines
+}
+
+// resolve sets the file and line of item from the resource it references.
+func resolve(item *fnresult.Item, nodes []*yaml.RNode, offset func(path string, index int) int) {
+	ref := item.ResourceRef
+	for _, n := range nodes {
+		meta, err := n.GetMeta()
+		if err != nil || meta.Kind != ref.Kind || meta.Name != ref.Name ||
+			(ref.APIVersion != "" && meta.APIVersion != ref.APIVersion) ||
+			(ref.Namespace != "" && meta.Namespace != ref.Namespace) {
+			continue
+		}
+		path, index, err := kioutil.GetFileAnnotations(n)
+		if err != nil {
+			return
+		}
+		if item.File == nil {
+			item.File = &fnresult.File{Path: path}
+			item.File.Index, _ = strconv.Atoi(index)
+		}
+		if item.File.Path != path {
+			return
+		}
+		line := n.YNode().Line
+		if item.Field != nil && item.Field.Path != "" {
+			if f := lookupField(n.YNode(), item.Field.Path); f != nil {
+				line = f.Line
+			}
+		}
+		i, _ := strconv.Atoi(index)
+		item.File.Line = offset(path, i) + line
+		return
+	}
+}
+
+// fieldSegment matches a segment of a field path, e.g. containers[0] or
+// containers[name=nginx].
+var fieldSegment = regexp.MustCompile(`^([^\[]*)((?:\[[^\]]*\])*)$`)
+
+// lookupField returns the node of the field path in n, e.g.
+// spec.containers[0].image or spec.containers[name=nginx].image, or nil if
+// the field doesn't exist.
+func lookupField(n *yaml.Node, path string) *yaml.Node {
+	for _, s := range strings.Split(path, ".") {
+		m := fieldSegment.FindStringSubmatch(s)
+		if m == nil {
+			return nil
+		}
+		if m[1] != "" {
+			f := yaml.NewRNode(n).Field(m[1])
+			if f == nil {
+				return nil
+			}
+			n = f.Value.YNode()
+		}
+		for _, index := range strings.Split(strings.Trim(m[2], "[]"), "][") {
+			if index == "" {
+				continue
+			}
+			if n.Kind != yaml.SequenceNode {
+				return nil
+			}
+			if i, err := strconv.Atoi(index); err == nil {
+				if i < 0 || i >= len(n.Content) {
+					return nil
+				}
+				n = n.Content[i]
+				continue
+			}
+			kv := strings.SplitN(index, "=", 2)
+			if len(kv) != 2 {
+				return nil
+			}
+			e, err := yaml.NewRNode(n).Pipe(yaml.ElementMatcher{Keys: []string{kv[0]}, Values: []string{kv[1]}})
+			if err != nil || e == nil {
+				return nil
+			}
+			n = e.YNode()
+		}
+	}
+	return n
+}
@@ -0,0 +1,132 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package functions_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/GoogleContainerTools/kpt/internal/util/functions"
+	"github.com/GoogleContainerTools/kpt/pkg/fnresult"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadResults(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kpt-results-test")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir)
+
+	n, err := NextResultsFile(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+
+	for name, content := range map[string]string{
+		"results-10.yaml": "- message: last\n",
+		"results-2.yaml":  "name: check\nitems:\n- message: first\n  severity: error\n",
+		"other.yaml":      "- message: ignored\n",
+	} {
+		if !assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0600)) {
+			t.FailNow()
+		}
+	}
+	n, err = NextResultsFile(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, 11, n)
+	assert.Equal(t, filepath.Join(dir, "results-11.yaml"), ResultsFile(dir, n))
+	assert.Empty(t, ResultsFile("", n))
+
+	// the results are in the order the functions ran, and named by the
+	// number of their function unless they are named
+	results, err := ReadResults(dir)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, []fnresult.Result{
+		{Name: "check", Items: []fnresult.Item{{Message: "first", Severity: fnresult.Error}}},
+		{Name: "function 11", Items: []fnresult.Item{{Message: "last", Severity: fnresult.Info}}},
+	}, results)
+
+	results, err = ReadResults(filepath.Join(dir, "missing"))
+	assert.NoError(t, err)
+	assert.Empty(t, results)
+}
+
+func TestResolveResults(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kpt-results-test")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir)
+	if !assert.NoError(t, os.MkdirAll(filepath.Join(dir, "app"), 0700)) {
+		t.FailNow()
+	}
+	err = ioutil.WriteFile(filepath.Join(dir, "app", "deploy.yaml"), []byte(`apiVersion: v1
+kind: Service
+metadata:
+  name: app
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+spec:
+  replicas: 5
+  template:
+    spec:
+      containers:
+      - name: sidecar
+        image: sidecar
+      - name: nginx
+        image: nginx
+`), 0600)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	deploy := &fnresult.ResourceRef{Kind: "Deployment", Name: "app"}
+	results := []fnresult.Result{{Items: []fnresult.Item{
+		{Message: "field", ResourceRef: deploy, Field: &fnresult.Field{Path: "spec.replicas"}},
+		{Message: "element", ResourceRef: deploy,
+			Field: &fnresult.Field{Path: "spec.template.spec.containers[name=nginx].image"}},
+		{Message: "index", ResourceRef: deploy,
+			Field: &fnresult.Field{Path: "spec.template.spec.containers[0]"}},
+		{Message: "missing field", ResourceRef: deploy, Field: &fnresult.Field{Path: "spec.paused"}},
+		{Message: "line set", ResourceRef: deploy, File: &fnresult.File{Path: "app/deploy.yaml", Line: 1}},
+		{Message: "missing resource", ResourceRef: &fnresult.ResourceRef{Kind: "Deployment", Name: "db"}},
+		{Message: "no resource"},
+	}}}
+	if !assert.NoError(t, ResolveResults(dir, results)) {
+		t.FailNow()
+	}
+	var files []*fnresult.File
+	for _, i := range results[0].Items {
+		files = append(files, i.File)
+	}
+	path := filepath.Join("app", "deploy.yaml")
+	assert.Equal(t, []*fnresult.File{
+		{Path: path, Index: 1, Line: 11},
+		{Path: path, Index: 1, Line: 18},
+		{Path: path, Index: 1, Line: 15},
+		// the line of the resource if the field doesn't exist
+		{Path: path, Index: 1, Line: 6},
+		{Path: "app/deploy.yaml", Line: 1},
+		nil,
+		nil,
+	}, files)
+}
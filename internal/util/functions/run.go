@@ -0,0 +1,209 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package functions
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"sigs.k8s.io/kustomize/kyaml/errors"
+	"sigs.k8s.io/kustomize/kyaml/fn/runtime/container"
+	runtimeexec "sigs.k8s.io/kustomize/kyaml/fn/runtime/exec"
+	"sigs.k8s.io/kustomize/kyaml/fn/runtime/runtimeutil"
+	"sigs.k8s.io/kustomize/kyaml/fn/runtime/starlark"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/kio/kioutil"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// RunStages runs the functions PlanStages plans, as kpt fn run runs them.
+// The containers are run with ContainerFilter, so with the runtime chosen
+// by --container-runtime.
+type RunStages struct {
+	PlanStages
+
+	// Input is read for the resources if Path is empty
+	Input io.Reader
+
+	// DryRun writes the resources to Output instead of the package
+	DryRun bool
+
+	// ResultsDir is where the results of each function are written
+	ResultsDir string
+
+	// EnableStarlark and EnableExec run the starlark and exec functions,
+	// which are skipped otherwise
+	EnableStarlark bool
+	EnableExec     bool
+
+	// LogSteps logs each function to LogWriter, or stderr, before it runs
+	LogSteps  bool
+	LogWriter io.Writer
+}
+
+// Run runs the functions against the package at Path, or the resources
+// read from Input.
+func (r RunStages) Run() error {
+	var in kio.Reader
+	var out kio.Writer = kio.ByteWriter{Writer: r.Output}
+	if r.Path != "" {
+		// starlark scripts are read relative to the package
+		p, err := filepath.Abs(r.Path)
+		if err != nil {
+			return errors.Wrap(err)
+		}
+		r.Path = p
+		// the package is read and written by the same ReadWriter, so the
+		// resources the functions delete are deleted
+		pkg := &kio.LocalPackageReadWriter{PackagePath: r.Path, MatchFilesGlob: kio.MatchAll}
+		in = pkg
+		if !r.DryRun {
+			out = pkg
+		}
+	} else {
+		in = &kio.ByteReader{Reader: r.Input}
+	}
+	nodes, err := in.Read()
+	if err != nil {
+		return err
+	}
+
+	fns, global, err := r.functions(nodes)
+	if err != nil {
+		return err
+	}
+	var fltrs []kio.Filter
+	for _, fn := range fns {
+		f, err := r.filter(fn, global, len(fltrs))
+		if err != nil {
+			return err
+		}
+		if f != nil {
+			fltrs = append(fltrs, f)
+		}
+	}
+
+	p := kio.Pipeline{
+		Inputs:  []kio.Reader{&kio.PackageBuffer{Nodes: nodes}},
+		Filters: fltrs,
+		Outputs: []kio.Writer{out},
+	}
+	if r.LogSteps {
+		w := r.LogWriter
+		if w == nil {
+			w = os.Stderr
+		}
+		err = p.ExecuteWithCallback(func(f kio.Filter) {
+			_, _ = fmt.Fprintf(w, "Running %s\n", filterName(f))
+		})
+	} else {
+		err = p.Execute()
+	}
+	if err != nil {
+		return err
+	}
+
+	// functions deferring their failure fail once the others have run
+	var errs []string
+	for _, f := range fltrs {
+		if d, ok := f.(runtimeutil.DeferFailureFunction); ok && d.GetExit() != nil {
+			errs = append(errs, d.GetExit().Error())
+		}
+	}
+	if len(errs) > 0 {
+		return errors.Errorf("%s", strings.Join(errs, "\n---\n"))
+	}
+	return nil
+}
+
+// filter returns the filter running the function configured by fn, the
+// i-th function run, or nil if the function isn't run.
+func (r RunStages) filter(fn *yaml.RNode, global bool, i int) (kio.Filter, error) {
+	spec := runtimeutil.GetFunctionSpec(fn)
+	if spec == nil {
+		return nil, nil
+	}
+	meta, err := fn.GetMeta()
+	if err != nil {
+		return nil, err
+	}
+	name := meta.Annotations[kioutil.PathAnnotation]
+	if name == "" {
+		name = meta.Name
+	}
+
+	f := runtimeutil.FunctionFilter{
+		FunctionConfig: fn,
+		GlobalScope:    global,
+		DeferFailure:   spec.DeferFailure,
+	}
+	if r.ResultsDir != "" {
+		f.ResultsFile = filepath.Join(r.ResultsDir, fmt.Sprintf("results-%d.yaml", i))
+	}
+
+	switch {
+	case spec.Container.Image != "":
+		if spec.Container.Network && !r.Network {
+			return nil, errors.Errorf("%s: network required but not enabled with --network", name)
+		}
+		u, err := r.user()
+		if err != nil {
+			return nil, err
+		}
+		return ContainerFilterAsUser(runtimeutil.ContainerSpec{
+			Image:         spec.Container.Image,
+			Network:       spec.Container.Network,
+			StorageMounts: r.StorageMounts,
+			Env:           r.env(spec.Container.Env),
+		}, u, runtimeexec.Filter{FunctionFilter: f})
+	case r.EnableStarlark && (spec.Starlark.Path != "" || spec.Starlark.URL != ""):
+		var p string
+		if spec.Starlark.Path != "" {
+			// the script is read relative to the function config
+			script := path.Clean(filepath.ToSlash(spec.Starlark.Path))
+			if path.IsAbs(script) || filepath.IsAbs(spec.Starlark.Path) {
+				return nil, errors.Errorf("%s: absolute function path %s not allowed", name, spec.Starlark.Path)
+			}
+			if strings.HasPrefix(script, "..") {
+				return nil, errors.Errorf("%s: function path %s not allowed to start with ../", name, spec.Starlark.Path)
+			}
+			dir := path.Dir(filepath.ToSlash(meta.Annotations[kioutil.PathAnnotation]))
+			p = filepath.Join(r.Path, filepath.FromSlash(dir), filepath.FromSlash(script))
+		}
+		return &starlark.Filter{Name: spec.Starlark.Name, Path: p, URL: spec.Starlark.URL, FunctionFilter: f}, nil
+	case r.EnableExec && spec.Exec.Path != "":
+		return &runtimeexec.Filter{Path: spec.Exec.Path, FunctionFilter: f}, nil
+	}
+	return nil, nil
+}
+
+// filterName returns the name f is logged with by --log-steps.
+func filterName(f kio.Filter) string {
+	switch f := f.(type) {
+	case *container.Filter:
+		return f.Image
+	case *JobFilter:
+		return f.Image
+	case *runtimeexec.Filter:
+		return f.Path
+	case *starlark.Filter:
+		return f.String()
+	}
+	return "unknown-type function"
+}
@@ -0,0 +1,102 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package functions_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt/internal/util/cmdutil"
+	"github.com/GoogleContainerTools/kpt/internal/util/functions"
+	"github.com/stretchr/testify/assert"
+)
+
+// writeRunPackage writes a package with a deployment and the config of a
+// function run with image gcr.io/example/fn:v1 to dir.
+func writeRunPackage(t *testing.T, dir string, network bool) string {
+	pkg := filepath.Join(dir, "pkg")
+	if !assert.NoError(t, os.MkdirAll(filepath.Join(pkg, "functions"), 0700)) {
+		t.FailNow()
+	}
+	spec := "container: {image: gcr.io/example/fn:v1}"
+	if network {
+		spec = "container: {image: gcr.io/example/fn:v1, network: true}"
+	}
+	files := map[string]string{
+		filepath.Join("functions", "fn.yaml"): `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: fn
+  annotations:
+    config.kubernetes.io/function: "` + spec + `"
+`,
+		"deploy.yaml": `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+`,
+	}
+	for name, content := range files {
+		if !assert.NoError(t, ioutil.WriteFile(filepath.Join(pkg, name), []byte(content), 0600)) {
+			t.FailNow()
+		}
+	}
+	return pkg
+}
+
+func TestRunStages(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kpt-run-test")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir)
+	defer fakeRuntime(t, dir, "podman")()
+	cmdutil.ContainerRuntime = "podman"
+	defer func() { cmdutil.ContainerRuntime = "" }()
+	pkg := writeRunPackage(t, dir, false)
+
+	out := &bytes.Buffer{}
+	err = functions.RunStages{
+		PlanStages: functions.PlanStages{Path: pkg, Output: out},
+		DryRun:     true,
+	}.Run()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	// the function is run with the chosen runtime, as nobody
+	args, err := ioutil.ReadFile(filepath.Join(dir, "args"))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Contains(t, string(args), "run --rm -i")
+	assert.Contains(t, string(args), "--user nobody")
+	assert.Contains(t, string(args), "gcr.io/example/fn:v1")
+	assert.Contains(t, out.String(), "name: app")
+}
+
+func TestRunStages_network(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kpt-run-test")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir)
+	pkg := writeRunPackage(t, dir, true)
+
+	err = functions.RunStages{PlanStages: functions.PlanStages{Path: pkg}}.Run()
+	assert.EqualError(t, err, "functions/fn.yaml: network required but not enabled with --network")
+}
@@ -0,0 +1,142 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package functions
+
+import (
+	"os"
+	"os/exec"
+
+	"github.com/GoogleContainerTools/kpt/internal/util/cmdutil"
+	"sigs.k8s.io/kustomize/kyaml/errors"
+	"sigs.k8s.io/kustomize/kyaml/fn/runtime/container"
+	runtimeexec "sigs.k8s.io/kustomize/kyaml/fn/runtime/exec"
+	"sigs.k8s.io/kustomize/kyaml/fn/runtime/runtimeutil"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+)
+
+// ContainerRuntimeEnv is the name of the environment variable naming the
+// container runtime functions are run with, if --container-runtime isn't
+// set.
+const ContainerRuntimeEnv = "KPT_CONTAINER_RUNTIME"
+
+// ContainerRuntime is the CLI function containers are run with.  Each
+// runtime accepts the run flags of the docker CLI.
+type ContainerRuntime string
+
+const (
+	Docker ContainerRuntime = "docker"
+
+	// Podman runs containers without a daemon, and rootless if kpt isn't
+	// run as root
+	Podman ContainerRuntime = "podman"
+
+	// Nerdctl runs containers with containerd
+	Nerdctl ContainerRuntime = "nerdctl"
+
+	// Kubernetes runs containers as Jobs in the cluster of the kubeconfig,
+	// see Job.  It is only used if chosen.
+	Kubernetes ContainerRuntime = "kubernetes"
+)
+
+// ContainerRuntimes are the supported runtimes, in the order they are
+// detected on the PATH.
+var ContainerRuntimes = []ContainerRuntime{Docker, Podman, Nerdctl}
+
+// GetContainerRuntime returns the runtime named name, or if name is empty
+// the runtime named by $KPT_CONTAINER_RUNTIME, or the first runtime on the
+// PATH.  containerd is accepted as the name of nerdctl.
+func GetContainerRuntime(name string) (ContainerRuntime, error) {
+	if name == "" {
+		name = os.Getenv(ContainerRuntimeEnv)
+	}
+	switch name {
+	case "containerd":
+		return Nerdctl, nil
+	case string(Kubernetes):
+		return Kubernetes, nil
+	}
+	if name != "" {
+		for _, r := range ContainerRuntimes {
+			if string(r) == name {
+				return r, nil
+			}
+		}
+		return "", errors.Errorf("unsupported container runtime %q, "+
+			"must be one of docker, podman, nerdctl and kubernetes", name)
+	}
+	for _, r := range ContainerRuntimes {
+		if _, err := exec.LookPath(string(r)); err == nil {
+			return r, nil
+		}
+	}
+	// none are installed, running docker fails with the clearest error
+	return Docker, nil
+}
+
+// Command returns the command running the function container spec as user,
+// or as the image user if user is empty.
+func (r ContainerRuntime) Command(spec runtimeutil.ContainerSpec, user string) []string {
+	var mounts []string
+	for i := range spec.StorageMounts {
+		mounts = append(mounts, spec.StorageMounts[i].String())
+	}
+	return r.command(spec.Image, spec.Network, user, mounts, spec.Env)
+}
+
+// command returns the command running image, with the mounts formatted as
+// the values of --mount.
+func (r ContainerRuntime) command(image string, hostNetwork bool, user string, mounts, env []string) []string {
+	network := runtimeutil.NetworkNameNone
+	if hostNetwork {
+		network = runtimeutil.NetworkNameHost
+	}
+	args := []string{string(r), "run", "--rm",
+		"-i", "-a", "STDIN", "-a", "STDOUT", "-a", "STDERR",
+		"--network", string(network),
+	}
+	// docker treats an empty user as the image user, the others reject it
+	if user != "" || r == Docker {
+		args = append(args, "--user", user)
+	}
+	args = append(args, "--security-opt=no-new-privileges")
+	for _, m := range mounts {
+		args = append(args, "--mount", m)
+	}
+	args = append(args, runtimeutil.NewContainerEnvFromStringSlice(env).GetDockerFlags()...)
+	return append(args, image)
+}
+
+// ContainerFilter returns the filter running the function container spec
+// with the runtime chosen by --container-runtime, as the image user.
+func ContainerFilter(spec runtimeutil.ContainerSpec, e runtimeexec.Filter) (kio.Filter, error) {
+	return ContainerFilterAsUser(spec, "", e)
+}
+
+// ContainerFilterAsUser returns the filter running the function container
+// spec as user, e.g. nobody or uid:gid.
+func ContainerFilterAsUser(spec runtimeutil.ContainerSpec, user string, e runtimeexec.Filter) (kio.Filter, error) {
+	r, err := GetContainerRuntime(cmdutil.ContainerRuntime)
+	if err != nil {
+		return nil, err
+	}
+	if r == Kubernetes {
+		return &JobFilter{ContainerSpec: spec, User: user, FunctionFilter: e.FunctionFilter}, nil
+	}
+	f := &container.Filter{ContainerSpec: spec, Exec: e, UIDGID: user}
+	// the filter only builds its docker command if the path is unset
+	args := r.Command(spec, f.UIDGID)
+	f.Exec.Path, f.Exec.Args = args[0], args[1:]
+	return f, nil
+}
@@ -0,0 +1,150 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package functions_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt/internal/util/cmdutil"
+	. "github.com/GoogleContainerTools/kpt/internal/util/functions"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/kustomize/kyaml/fn/runtime/exec"
+	"sigs.k8s.io/kustomize/kyaml/fn/runtime/runtimeutil"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// fakeRuntime writes a runtime CLI named name to dir, which records its
+// args in dir/args and echoes its input, and puts dir first on the PATH,
+// returning a func restoring the PATH.
+func fakeRuntime(t *testing.T, dir, name string) func() {
+	err := ioutil.WriteFile(filepath.Join(dir, name), []byte(`#!/bin/sh
+echo "$@" >> `+filepath.Join(dir, "args")+`
+cat
+`), 0700)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	path := os.Getenv("PATH")
+	if !assert.NoError(t, os.Setenv("PATH", dir+string(os.PathListSeparator)+path)) {
+		t.FailNow()
+	}
+	return func() { os.Setenv("PATH", path) }
+}
+
+func TestGetContainerRuntime(t *testing.T) {
+	old, found := os.LookupEnv(ContainerRuntimeEnv)
+	defer func() {
+		if found {
+			os.Setenv(ContainerRuntimeEnv, old)
+		} else {
+			os.Unsetenv(ContainerRuntimeEnv)
+		}
+	}()
+	path := os.Getenv("PATH")
+	defer os.Setenv("PATH", path)
+
+	dir, err := ioutil.TempDir("", "kpt-runtime-test")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir)
+	if !assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "podman"), nil, 0700)) {
+		t.FailNow()
+	}
+	if !assert.NoError(t, os.Setenv("PATH", dir)) {
+		t.FailNow()
+	}
+
+	// the first runtime installed is detected
+	os.Unsetenv(ContainerRuntimeEnv)
+	r, err := GetContainerRuntime("")
+	assert.NoError(t, err)
+	assert.Equal(t, Podman, r)
+
+	// the environment overrides detection, and the flag the environment
+	os.Setenv(ContainerRuntimeEnv, "containerd")
+	r, err = GetContainerRuntime("")
+	assert.NoError(t, err)
+	assert.Equal(t, Nerdctl, r)
+	r, err = GetContainerRuntime("docker")
+	assert.NoError(t, err)
+	assert.Equal(t, Docker, r)
+
+	_, err = GetContainerRuntime("rkt")
+	assert.EqualError(t, err,
+		`unsupported container runtime "rkt", must be one of docker, podman, nerdctl and kubernetes`)
+
+	// docker is the default if none are installed
+	os.Unsetenv(ContainerRuntimeEnv)
+	os.Setenv("PATH", filepath.Join(dir, "missing"))
+	r, err = GetContainerRuntime("")
+	assert.NoError(t, err)
+	assert.Equal(t, Docker, r)
+}
+
+func TestContainerRuntime_Command(t *testing.T) {
+	spec := runtimeutil.ContainerSpec{
+		Image:         "gcr.io/example/fn:v1",
+		Network:       true,
+		StorageMounts: []runtimeutil.StorageMount{{MountType: "bind", Src: "/a", DstPath: "/b", ReadWriteMode: true}},
+	}
+	assert.Equal(t, []string{"docker", "run", "--rm",
+		"-i", "-a", "STDIN", "-a", "STDOUT", "-a", "STDERR",
+		"--network", "host", "--user", "", "--security-opt=no-new-privileges",
+		"--mount", "type=bind,source=/a,target=/b",
+		"-e", "LOG_TO_STDERR=true", "-e", "STRUCTURED_RESULTS=true",
+		"gcr.io/example/fn:v1"}, Docker.Command(spec, ""))
+
+	// the empty user is only passed to docker
+	assert.Equal(t, []string{"podman", "run", "--rm",
+		"-i", "-a", "STDIN", "-a", "STDOUT", "-a", "STDERR",
+		"--network", "host", "--security-opt=no-new-privileges",
+		"--mount", "type=bind,source=/a,target=/b",
+		"-e", "LOG_TO_STDERR=true", "-e", "STRUCTURED_RESULTS=true",
+		"gcr.io/example/fn:v1"}, Podman.Command(spec, ""))
+	assert.Contains(t, strings.Join(Nerdctl.Command(spec, "nobody"), " "), "--user nobody")
+}
+
+func TestContainerFilter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kpt-runtime-test")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir)
+	defer fakeRuntime(t, dir, "podman")()
+	cmdutil.ContainerRuntime = "podman"
+	defer func() { cmdutil.ContainerRuntime = "" }()
+
+	f, err := ContainerFilter(runtimeutil.ContainerSpec{Image: "gcr.io/example/fn:v1"}, exec.Filter{})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	nodes, err := f.Filter([]*yaml.RNode{yaml.MustParse("kind: ConfigMap\n")})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Len(t, nodes, 1)
+	args, err := ioutil.ReadFile(filepath.Join(dir, "args"))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.True(t, strings.HasPrefix(string(args), "run --rm -i"))
+	assert.NotContains(t, string(args), "--user")
+	assert.Contains(t, string(args), "gcr.io/example/fn:v1")
+}
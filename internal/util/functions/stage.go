@@ -0,0 +1,366 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package functions
+
+import (
+	"fmt"
+	"io"
+	"os/user"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"sigs.k8s.io/kustomize/kyaml/errors"
+	"sigs.k8s.io/kustomize/kyaml/fn/runtime/runtimeutil"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/kio/kioutil"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// StagePlan is the plan for running a function pipeline as stages of an
+// external orchestrator, e.g. Tekton or Argo Workflows.  The orchestrator
+// runs Source, pipes its output through each step and pipes the result into
+// Sink.
+type StagePlan struct {
+	// Source is the command writing the input ResourceList to stdout
+	Source []string `yaml:"source,omitempty"`
+
+	// Steps are the functions to run, in order
+	Steps []Stage `yaml:"steps"`
+
+	// Sink is the command reading the output ResourceList from stdin
+	Sink []string `yaml:"sink,omitempty"`
+}
+
+// Stage is the container invocation of a single function.  It reads a
+// ResourceList on stdin, whose functionConfig is FunctionConfig, and writes
+// the result to stdout.
+type Stage struct {
+	// Name identifies the function, e.g. the path to its config
+	Name string `yaml:"name"`
+
+	// Image is the function image, and Digest its digest if the image is
+	// pinned to one
+	Image  string `yaml:"image"`
+	Digest string `yaml:"digest,omitempty"`
+
+	// Command is the command kpt fn run would run the function with
+	Command []string `yaml:"command"`
+
+	Network bool     `yaml:"network,omitempty"`
+	User    string   `yaml:"user"`
+	Mounts  []string `yaml:"mounts,omitempty"`
+	Env     []string `yaml:"env,omitempty"`
+
+	// Scope is the directory of the resources the function is run against,
+	// or empty if it is run against every resource
+	Scope string `yaml:"scope,omitempty"`
+
+	FunctionConfig *yaml.Node `yaml:"functionConfig"`
+}
+
+// PlanStages plans the functions kpt fn run would run, without running them.
+type PlanStages struct {
+	// Path is the package directory, or empty if the resources are read
+	// from stdin
+	Path string
+
+	// Functions are the explicit functions to run, and FunctionPaths the
+	// directories to read functions from.  If neither is set the functions
+	// are read from the package resources.
+	Functions     []*yaml.RNode
+	FunctionPaths []string
+
+	GlobalScope   bool
+	Network       bool
+	StorageMounts []runtimeutil.StorageMount
+	Env           []string
+	AsCurrentUser bool
+
+	// Runtime is the container runtime the functions would be run with,
+	// docker if empty
+	Runtime ContainerRuntime
+
+	Output io.Writer
+}
+
+// Run writes the plan to Output as yaml.
+func (p PlanStages) Run() error {
+	plan, err := p.Plan()
+	if err != nil {
+		return err
+	}
+	b, err := yaml.Marshal(plan)
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	// re-encode the plan so the function configs are indented like the
+	// rest of it
+	n, err := yaml.Parse(string(b))
+	if err != nil {
+		return err
+	}
+	s, err := n.String()
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(p.Output, s)
+	return errors.Wrap(err)
+}
+
+// Plan returns the plan.
+func (p PlanStages) Plan() (StagePlan, error) {
+	var plan StagePlan
+	if p.Path != "" {
+		plan.Source = []string{"kpt", "fn", "source", p.Path}
+		plan.Sink = []string{"kpt", "fn", "sink", p.Path}
+	}
+
+	var nodes []*yaml.RNode
+	switch {
+	case len(p.Functions) > 0 || len(p.FunctionPaths) > 0:
+	case p.Path != "":
+		var err error
+		nodes, err = kio.LocalPackageReader{PackagePath: p.Path, MatchFilesGlob: kio.MatchAll}.Read()
+		if err != nil {
+			return plan, err
+		}
+	default:
+		return plan, errors.Errorf("functions must be specified with --image or --fn-path " +
+			"when reading resources from stdin")
+	}
+	fns, global, err := p.functions(nodes)
+	if err != nil {
+		return plan, err
+	}
+	u, err := p.user()
+	if err != nil {
+		return plan, err
+	}
+
+	for _, fn := range fns {
+		spec := runtimeutil.GetFunctionSpec(fn)
+		if spec == nil {
+			continue
+		}
+		meta, err := fn.GetMeta()
+		if err != nil {
+			return plan, err
+		}
+		name := meta.Annotations[kioutil.PathAnnotation]
+		if name == "" {
+			name = meta.Name
+		}
+		if spec.Container.Image == "" {
+			return plan, errors.Errorf("%s: only container functions can be run as pipeline stages", name)
+		}
+		if spec.Container.Network && !p.Network {
+			return plan, errors.Errorf("%s: network required but not enabled with --network", name)
+		}
+
+		s := Stage{
+			Name:           name,
+			Image:          spec.Container.Image,
+			Network:        spec.Container.Network,
+			User:           u,
+			Env:            p.env(spec.Container.Env),
+			FunctionConfig: fn.YNode(),
+		}
+		if i := strings.Index(s.Image, "@"); i >= 0 {
+			s.Digest = s.Image[i+1:]
+		}
+		for i := range p.StorageMounts {
+			s.Mounts = append(s.Mounts, p.StorageMounts[i].String())
+		}
+		if !global {
+			s.Scope = functionScope(meta.Annotations[kioutil.PathAnnotation])
+		}
+		s.Command = s.command(p.Runtime)
+		plan.Steps = append(plan.Steps, s)
+	}
+	return plan, nil
+}
+
+// functions returns the functions to run against nodes, the package
+// resources, and whether they are run against every resource.  Explicit
+// functions and functions read from FunctionPaths are, while functions
+// read from the package are scoped to their directory unless GlobalScope
+// is set.
+func (p PlanStages) functions(nodes []*yaml.RNode) ([]*yaml.RNode, bool, error) {
+	if len(p.Functions) > 0 {
+		return p.Functions, true, nil
+	}
+	if len(p.FunctionPaths) > 0 {
+		var fns []*yaml.RNode
+		for _, dir := range p.FunctionPaths {
+			nodes, err := kio.LocalPackageReader{PackagePath: dir}.Read()
+			if err != nil {
+				return nil, false, err
+			}
+			fns = append(fns, nodes...)
+		}
+		return fns, true, nil
+	}
+	return sortFunctions(nodes), p.GlobalScope, nil
+}
+
+// user returns the user function containers are run as: nobody, or the
+// current user if AsCurrentUser is set.
+func (p PlanStages) user() (string, error) {
+	if !p.AsCurrentUser {
+		return "nobody", nil
+	}
+	current, err := user.Current()
+	if err != nil {
+		return "", errors.Wrap(err)
+	}
+	return fmt.Sprintf("%s:%s", current.Uid, current.Gid), nil
+}
+
+// env merges the env of the function with the env from the flags, which
+// take precedence.
+func (p PlanStages) env(fn []string) []string {
+	flags := runtimeutil.NewContainerEnvFromStringSlice(p.Env)
+	env := runtimeutil.NewContainerEnvFromStringSlice(fn)
+	for k, v := range flags.EnvVars {
+		env.AddKeyValue(k, v)
+	}
+	for _, k := range flags.VarsToExport {
+		env.AddKey(k)
+	}
+	// Raw lists the values in map order, sort them so plans are stable
+	raw := env.Raw()
+	values := raw[:len(env.EnvVars)]
+	sort.Strings(values)
+	return raw
+}
+
+// command returns the command running the function with runtime r, as kpt
+// fn run runs it.
+func (s Stage) command(r ContainerRuntime) []string {
+	// kpt runs the Jobs of the kubernetes runtime itself, so orchestrators
+	// run the container with docker
+	if r == "" || r == Kubernetes {
+		r = Docker
+	}
+	return r.command(s.Image, s.Network, s.User, s.Mounts, s.Env)
+}
+
+// sortFunctions returns the function configs in nodes, with the functions
+// deepest in the package first as kpt fn run runs them.
+func sortFunctions(nodes []*yaml.RNode) []*yaml.RNode {
+	var fns []*yaml.RNode
+	for _, n := range nodes {
+		if runtimeutil.GetFunctionSpec(n) != nil {
+			fns = append(fns, n)
+		}
+	}
+	scope := func(n *yaml.RNode) string {
+		meta, _ := n.GetMeta()
+		return functionScope(meta.Annotations[kioutil.PathAnnotation])
+	}
+	depth := func(dir string) int {
+		if dir == "." {
+			return 0
+		}
+		return len(strings.Split(dir, "/"))
+	}
+	sort.SliceStable(fns, func(i, j int) bool {
+		si, sj := scope(fns[i]), scope(fns[j])
+		if di, dj := depth(si), depth(sj); di != dj {
+			return di > dj
+		}
+		return si < sj
+	})
+	return fns
+}
+
+// functionScope returns the directory a function config at path is scoped
+// to.  Functions in a functions directory are scoped to its parent.
+func functionScope(p string) string {
+	dir := path.Dir(filepath.ToSlash(p))
+	if path.Base(dir) == "functions" {
+		dir = path.Dir(dir)
+	}
+	return dir
+}
+
+// ImageFunction returns the config of the function run with kpt fn run
+// --image, from the arguments after '--': an optional kind followed by
+// data items, e.g. ConfigMap foo=bar.
+func ImageFunction(image string, network bool, args []string) (*yaml.RNode, error) {
+	spec := fmt.Sprintf("container:\n  image: %s\n", image)
+	if network {
+		spec += "  network: true\n"
+	}
+	return argsFunction(spec, args)
+}
+
+// StarlarkFunction returns the config of the function run with kpt fn run
+// --star-path or --star-url, from the arguments after '--'.
+func StarlarkFunction(name, path, url string, args []string) (*yaml.RNode, error) {
+	spec := fmt.Sprintf("starlark:\n  name: %q\n", name)
+	if path != "" {
+		spec += fmt.Sprintf("  path: %q\n", path)
+	}
+	if url != "" {
+		spec += fmt.Sprintf("  url: %q\n", url)
+	}
+	return argsFunction(spec, args)
+}
+
+// ExecFunction returns the config of the function run with kpt fn run
+// --exec-path, from the arguments after '--'.
+func ExecFunction(path string, args []string) (*yaml.RNode, error) {
+	return argsFunction(fmt.Sprintf("exec:\n  path: %q\n", path), args)
+}
+
+// argsFunction returns the config of the function annotated with spec,
+// from the arguments after '--'.
+func argsFunction(spec string, args []string) (*yaml.RNode, error) {
+	fn := yaml.NewMapRNode(nil)
+	if err := fn.SetName("function-input"); err != nil {
+		return nil, err
+	}
+	if err := fn.SetAnnotations(map[string]string{runtimeutil.FunctionAnnotationKey: spec}); err != nil {
+		return nil, err
+	}
+	kind := "ConfigMap"
+	data := yaml.NewMapRNode(nil)
+	for i, arg := range args {
+		kv := strings.SplitN(arg, "=", 2)
+		if i == 0 && len(kv) == 1 {
+			kind = arg
+			continue
+		}
+		if len(kv) != 2 {
+			return nil, errors.Errorf("args must have keys and values separated by =")
+		}
+		if err := data.PipeE(yaml.SetField(kv[0], yaml.NewScalarRNode(kv[1]))); err != nil {
+			return nil, err
+		}
+	}
+	for _, f := range []yaml.Filter{
+		yaml.SetField("data", data),
+		yaml.SetField("kind", yaml.NewScalarRNode(kind)),
+		yaml.SetField("apiVersion", yaml.NewScalarRNode("v1")),
+	} {
+		if err := fn.PipeE(f); err != nil {
+			return nil, err
+		}
+	}
+	return fn, nil
+}
@@ -0,0 +1,168 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package functions_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt/internal/util/functions"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/kustomize/kyaml/fn/runtime/runtimeutil"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+func TestPlanStages(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kpt-test")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		filepath.Join("functions", "label.yaml"): `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: label
+  annotations:
+    config.kubernetes.io/function: |
+      container:
+        image: gcr.io/example/label@sha256:1234
+data:
+  color: blue
+`,
+		filepath.Join("sub", "fn.yaml"): `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: sub
+  annotations:
+    config.kubernetes.io/function: |
+      container:
+        image: gcr.io/example/sub:v1
+`,
+		"cm.yaml": `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cm
+`,
+	}
+	for name, content := range files {
+		if !assert.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0700)) {
+			t.FailNow()
+		}
+		err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0600)
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+	}
+
+	plan, err := functions.PlanStages{
+		Path:          dir,
+		StorageMounts: []runtimeutil.StorageMount{runtimeutil.StringToStorageMount("type=bind,src=/a,dst=/b")},
+		Env:           []string{"FOO=bar"},
+	}.Plan()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, []string{"kpt", "fn", "source", dir}, plan.Source)
+	assert.Equal(t, []string{"kpt", "fn", "sink", dir}, plan.Sink)
+	if !assert.Len(t, plan.Steps, 2) {
+		t.FailNow()
+	}
+
+	// the deeper function runs first
+	sub := plan.Steps[0]
+	assert.Equal(t, "sub/fn.yaml", sub.Name)
+	assert.Equal(t, "sub", sub.Scope)
+	assert.Equal(t, "gcr.io/example/sub:v1", sub.Image)
+	assert.Empty(t, sub.Digest)
+	assert.Equal(t, []string{"docker", "run", "--rm",
+		"-i", "-a", "STDIN", "-a", "STDOUT", "-a", "STDERR",
+		"--network", "none", "--user", "nobody", "--security-opt=no-new-privileges",
+		"--mount", "type=bind,source=/a,target=/b,readonly",
+		"-e", "FOO=bar", "-e", "LOG_TO_STDERR=true", "-e", "STRUCTURED_RESULTS=true",
+		"gcr.io/example/sub:v1"}, sub.Command)
+
+	// functions in a functions directory are scoped to its parent
+	label := plan.Steps[1]
+	assert.Equal(t, "functions/label.yaml", label.Name)
+	assert.Equal(t, ".", label.Scope)
+	assert.Equal(t, "sha256:1234", label.Digest)
+	color, err := yaml.NewRNode(label.FunctionConfig).Pipe(yaml.Lookup("data", "color"))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, "blue", yaml.GetValue(color))
+}
+
+func TestPlanStages_image(t *testing.T) {
+	fn, err := functions.ImageFunction("gcr.io/example/fn:v1", false, []string{"Foo", "a=b"})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	b := &bytes.Buffer{}
+	err = functions.PlanStages{Functions: []*yaml.RNode{fn}, Output: b}.Run()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, `steps:
+- name: function-input
+  image: gcr.io/example/fn:v1
+  command:
+  - docker
+  - run
+  - --rm
+  - -i
+  - -a
+  - STDIN
+  - -a
+  - STDOUT
+  - -a
+  - STDERR
+  - --network
+  - none
+  - --user
+  - nobody
+  - --security-opt=no-new-privileges
+  - -e
+  - LOG_TO_STDERR=true
+  - -e
+  - STRUCTURED_RESULTS=true
+  - gcr.io/example/fn:v1
+  user: nobody
+  env:
+  - LOG_TO_STDERR=true
+  - STRUCTURED_RESULTS=true
+  functionConfig:
+    metadata:
+      name: function-input
+      annotations:
+        config.kubernetes.io/function: |
+          container:
+            image: gcr.io/example/fn:v1
+    data:
+      a: b
+    kind: Foo
+    apiVersion: v1
+`, b.String())
+
+	// only explicit functions can be planned without a package
+	err = functions.PlanStages{Output: b}.Run()
+	assert.EqualError(t, err, "functions must be specified with --image or --fn-path "+
+		"when reading resources from stdin")
+}
@@ -0,0 +1,149 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package functions
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"sigs.k8s.io/kustomize/kyaml/errors"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// StepStats records the changes a single function made to the resources.
+type StepStats struct {
+	// Name identifies the function, e.g. its image
+	Name string `json:"name"`
+
+	// Added is the number of resources the function created
+	Added int `json:"added"`
+
+	// Removed is the number of resources the function deleted
+	Removed int `json:"removed"`
+
+	// Modified is the number of resources the function changed
+	Modified int `json:"modified"`
+
+	// Unchanged is the number of resources the function left as is
+	Unchanged int `json:"unchanged"`
+
+	// BytesChanged is the size of the added, removed and modified resources
+	BytesChanged int `json:"bytesChanged"`
+}
+
+// Churn is the number of resources the step added, removed or modified.
+func (s StepStats) Churn() int {
+	return s.Added + s.Removed + s.Modified
+}
+
+// Stats records the changes each function in a pipeline made to the
+// resources.  A step with a high churn relative to the number of resources
+// is likely rewriting resources it doesn't mean to -- e.g. reformatting them.
+type Stats struct {
+	// Steps contains the stats for each function, in the order they were run
+	Steps []StepStats `json:"steps"`
+
+	// Churn is the total number of resources added, removed or modified
+	// across all steps
+	Churn int `json:"churn"`
+
+	// BytesChanged is the total BytesChanged across all steps
+	BytesChanged int `json:"bytesChanged"`
+}
+
+// Filter returns a filter which runs f, recording its changes as a step
+// named name.
+func (s *Stats) Filter(name string, f kio.Filter) kio.Filter {
+	return kio.FilterFunc(func(nodes []*yaml.RNode) ([]*yaml.RNode, error) {
+		before, err := index(nodes)
+		if err != nil {
+			return nil, err
+		}
+		nodes, err = f.Filter(nodes)
+		if err != nil {
+			return nil, err
+		}
+		after, err := index(nodes)
+		if err != nil {
+			return nil, err
+		}
+
+		step := StepStats{Name: name}
+		for id, a := range after {
+			b, found := before[id]
+			switch {
+			case !found:
+				step.Added++
+				step.BytesChanged += len(a)
+			case a != b:
+				step.Modified++
+				step.BytesChanged += len(a)
+			default:
+				step.Unchanged++
+			}
+		}
+		for id, b := range before {
+			if _, found := after[id]; !found {
+				step.Removed++
+				step.BytesChanged += len(b)
+			}
+		}
+		s.Steps = append(s.Steps, step)
+		s.Churn += step.Churn()
+		s.BytesChanged += step.BytesChanged
+		return nodes, nil
+	})
+}
+
+// Write writes a table of the stats to w.
+func (s Stats) Write(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "STEP\tFUNCTION\tADDED\tREMOVED\tMODIFIED\tUNCHANGED\tBYTES")
+	for i, step := range s.Steps {
+		fmt.Fprintf(tw, "%d\t%s\t%d\t%d\t%d\t%d\t%d\n", i, step.Name,
+			step.Added, step.Removed, step.Modified, step.Unchanged, step.BytesChanged)
+	}
+	fmt.Fprintf(tw, "total churn: %d resources, %d bytes\n", s.Churn, s.BytesChanged)
+	return errors.Wrap(tw.Flush())
+}
+
+// index returns the serialized resources keyed by their identity.
+func index(nodes []*yaml.RNode) (map[string]string, error) {
+	m := map[string]string{}
+	for i := range nodes {
+		meta, err := nodes[i].GetMeta()
+		if err != nil {
+			return nil, err
+		}
+		s, err := nodes[i].String()
+		if err != nil {
+			return nil, err
+		}
+		id := fmt.Sprintf("%s/%s/%s/%s",
+			meta.APIVersion, meta.Kind, meta.Namespace, meta.Name)
+		// disambiguate resources with the same identity by their order
+		key := id
+		for n := 1; ; n++ {
+			if _, found := m[key]; !found {
+				break
+			}
+			key = fmt.Sprintf("%s#%d", id, n)
+		}
+		m[key] = s
+	}
+	return m, nil
+}
@@ -0,0 +1,90 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package functions_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt/internal/util/functions"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+func TestStats_Filter(t *testing.T) {
+	input := `
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: a
+data:
+  foo: bar
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: b
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: c
+`
+	// modifies a, removes b and adds d
+	churn := kio.FilterFunc(func(nodes []*yaml.RNode) ([]*yaml.RNode, error) {
+		if err := nodes[0].PipeE(yaml.SetField("data",
+			yaml.NewMapRNode(&map[string]string{"foo": "baz"}))); err != nil {
+			return nil, err
+		}
+		d, err := yaml.Parse("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: d\n")
+		if err != nil {
+			return nil, err
+		}
+		return []*yaml.RNode{nodes[0], nodes[2], d}, nil
+	})
+	noop := kio.FilterFunc(func(nodes []*yaml.RNode) ([]*yaml.RNode, error) {
+		return nodes, nil
+	})
+
+	stats := &functions.Stats{}
+	out := &bytes.Buffer{}
+	err := kio.Pipeline{
+		Inputs:  []kio.Reader{&kio.ByteReader{Reader: bytes.NewBufferString(input)}},
+		Filters: []kio.Filter{stats.Filter("churn", churn), stats.Filter("noop", noop)},
+		Outputs: []kio.Writer{&kio.ByteWriter{Writer: out}},
+	}.Execute()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	if !assert.Len(t, stats.Steps, 2) {
+		t.FailNow()
+	}
+	step := stats.Steps[0]
+	assert.Equal(t, "churn", step.Name)
+	assert.Equal(t, 1, step.Added)
+	assert.Equal(t, 1, step.Removed)
+	assert.Equal(t, 1, step.Modified)
+	assert.Equal(t, 1, step.Unchanged)
+	assert.NotZero(t, step.BytesChanged)
+	assert.Equal(t, functions.StepStats{Name: "noop", Unchanged: 3}, stats.Steps[1])
+	assert.Equal(t, 3, stats.Churn)
+	assert.Equal(t, step.BytesChanged, stats.BytesChanged)
+
+	table := &bytes.Buffer{}
+	assert.NoError(t, stats.Write(table))
+	assert.Contains(t, table.String(), "total churn: 3 resources")
+}
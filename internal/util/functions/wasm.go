@@ -0,0 +1,267 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package functions
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"sigs.k8s.io/kustomize/kyaml/errors"
+	"sigs.k8s.io/kustomize/kyaml/fn/runtime/exec"
+	"sigs.k8s.io/kustomize/kyaml/fn/runtime/runtimeutil"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/kio/kioutil"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// WasmRuntimeEnv is the name of the environment variable containing the
+// command of the WASI runtime functions compiled to WebAssembly are run
+// with, which is run with the path of the module as its last argument.
+// Defaults to "wasmtime run".
+const WasmRuntimeEnv = "KPT_WASM_RUNTIME"
+
+const defaultWasmRuntime = "wasmtime run"
+
+// wasmClient downloads the modules read from urls.
+var wasmClient = &http.Client{Timeout: 2 * time.Minute}
+
+// sha256Pattern matches a hex sha256 digest.
+var sha256Pattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// WasmSpec is the spec of a function compiled to WebAssembly, in the wasm
+// field of the config.kubernetes.io/function annotation of its function
+// config, e.g.
+//
+//	config.kubernetes.io/function: |
+//	  wasm:
+//	    module: fn/set-labels.wasm
+type WasmSpec struct {
+	// Module is the path of the module, relative to the function config,
+	// or its http(s) url
+	Module string `yaml:"module,omitempty"`
+
+	// SHA256 is the hex sha256 digest the module is verified against.
+	// Required if Module is a url.
+	SHA256 string `yaml:"sha256,omitempty"`
+}
+
+// GetWasmSpec returns the WasmSpec of the function config n, or nil if it
+// isn't a function compiled to WebAssembly.
+func GetWasmSpec(n *yaml.RNode) *WasmSpec {
+	meta, err := n.GetMeta()
+	if err != nil {
+		return nil
+	}
+	for _, key := range []string{runtimeutil.FunctionAnnotationKey, "config.k8s.io/function"} {
+		value, found := meta.Annotations[key]
+		if !found {
+			continue
+		}
+		var fn struct {
+			Wasm WasmSpec `yaml:"wasm,omitempty"`
+		}
+		if err := yaml.Unmarshal([]byte(value), &fn); err != nil || fn.Wasm.Module == "" {
+			return nil
+		}
+		return &fn.Wasm
+	}
+	return nil
+}
+
+// WasmFilter runs a function compiled to WASI.  The module reads the
+// ResourceList on stdin and writes it back on stdout, and is run by the
+// WASI runtime of WasmRuntimeEnv without access to the filesystem or the
+// network.
+type WasmFilter struct {
+	// Module is the path or http(s) url of the module.  Modules read from
+	// urls are cached, by digest, in the kpt directory of the user cache
+	// directory.
+	Module string
+
+	// SHA256 is the hex sha256 digest the module is verified against.
+	// Required if Module is a url.
+	SHA256 string
+
+	runtimeutil.FunctionFilter
+}
+
+func (f *WasmFilter) Filter(nodes []*yaml.RNode) ([]*yaml.RNode, error) {
+	module, err := wasmModule(f.Module, f.SHA256)
+	if err != nil {
+		return nil, err
+	}
+	runtime := strings.Fields(os.Getenv(WasmRuntimeEnv))
+	if len(runtime) == 0 {
+		runtime = strings.Fields(defaultWasmRuntime)
+	}
+	e := &exec.Filter{
+		Path:           runtime[0],
+		Args:           append(runtime[1:], module),
+		FunctionFilter: f.FunctionFilter,
+	}
+	return e.Filter(nodes)
+}
+
+// wasmModule returns the path of module, downloading it to the cache if
+// it is a url.  The module is verified against digest, which is required
+// for urls.
+func wasmModule(module, digest string) (string, error) {
+	digest = strings.ToLower(digest)
+	if digest != "" && !sha256Pattern.MatchString(digest) {
+		return "", errors.Errorf("invalid sha256 digest %q of wasm module %s", digest, module)
+	}
+	u, err := url.Parse(module)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		if digest == "" {
+			return module, nil
+		}
+		f, err := os.Open(module)
+		if err != nil {
+			return "", errors.Wrap(err)
+		}
+		defer f.Close()
+		return module, verifyWasmModule(module, digest, f)
+	}
+	if digest == "" {
+		return "", errors.Errorf("wasm module %s requires a sha256 digest", module)
+	}
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", errors.Wrap(err)
+	}
+	dir = filepath.Join(dir, "kpt", "wasm")
+	p := filepath.Join(dir, digest+".wasm")
+	if _, err := os.Stat(p); err == nil {
+		return p, nil
+	}
+
+	resp, err := wasmClient.Get(module)
+	if err != nil {
+		return "", errors.Wrap(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", errors.Errorf("failed to download wasm module %s: %s", module, resp.Status)
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", errors.Wrap(err)
+	}
+	// the module is downloaded to a temp file first, so an interrupted or
+	// unverified download isn't cached
+	tmp, err := ioutil.TempFile(dir, "download-")
+	if err != nil {
+		return "", errors.Wrap(err)
+	}
+	defer os.Remove(tmp.Name())
+	err = verifyWasmModule(module, digest, io.TeeReader(resp.Body, tmp))
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return "", err
+	}
+	return p, errors.Wrap(os.Rename(tmp.Name(), p))
+}
+
+// verifyWasmModule returns an error if the sha256 digest of the module read
+// from r isn't digest.
+func verifyWasmModule(module, digest string, r io.Reader) error {
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return errors.Errorf("failed to read wasm module %s: %v", module, err)
+	}
+	if actual := hex.EncodeToString(h.Sum(nil)); actual != digest {
+		return errors.Errorf("sha256 digest of wasm module %s is %s, expected %s",
+			module, actual, digest)
+	}
+	return nil
+}
+
+// WasmFunctions returns the function configs of the resources of the
+// package at path which are compiled to WebAssembly, deepest first.
+func WasmFunctions(path string) ([]*yaml.RNode, error) {
+	nodes, err := (&kio.LocalPackageReader{PackagePath: path}).Read()
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	var fns []*yaml.RNode
+	for _, n := range nodes {
+		if GetWasmSpec(n) != nil {
+			fns = append(fns, n)
+		}
+	}
+	depth := func(n *yaml.RNode) int {
+		p, _, _ := kioutil.GetFileAnnotations(n)
+		return strings.Count(filepath.ToSlash(p), "/")
+	}
+	sort.SliceStable(fns, func(i, j int) bool { return depth(fns[i]) > depth(fns[j]) })
+	return fns, nil
+}
+
+// RunWasmFunctions runs the functions of the package at dir which are
+// compiled to WebAssembly.  Each function is run against the resources in
+// the directory of its function config and its subdirectories, or every
+// resource of the package if globalScope is set.  The results of each
+// function are written to resultsDir, if it is set, numbered after the
+// results already there.
+func RunWasmFunctions(dir string, globalScope bool, resultsDir string) error {
+	fns, err := WasmFunctions(dir)
+	if err != nil || len(fns) == 0 {
+		return err
+	}
+	next, err := NextResultsFile(resultsDir)
+	if err != nil {
+		return err
+	}
+	var fltrs []kio.Filter
+	for i, fn := range fns {
+		file, _, err := kioutil.GetFileAnnotations(fn)
+		if err != nil {
+			return errors.Wrap(err)
+		}
+		spec := GetWasmSpec(fn)
+		module := spec.Module
+		if u, err := url.Parse(module); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			// the module path is relative to the function config, like
+			// the path of starlark functions
+			module = path.Clean(filepath.ToSlash(module))
+			if filepath.IsAbs(module) || path.IsAbs(module) {
+				return errors.Errorf("absolute function path %s not allowed", module)
+			}
+			if strings.HasPrefix(module, "..") {
+				return errors.Errorf("function path %s not allowed to start with ../", module)
+			}
+			module = filepath.Join(dir, filepath.Dir(file), filepath.FromSlash(module))
+		}
+		fltrs = append(fltrs, &WasmFilter{Module: module, SHA256: spec.SHA256, FunctionFilter: runtimeutil.FunctionFilter{
+			FunctionConfig: fn,
+			GlobalScope:    globalScope,
+			ResultsFile:    ResultsFile(resultsDir, next+i),
+		}})
+	}
+	rw := &kio.LocalPackageReadWriter{PackagePath: dir}
+	return errors.Wrap(kio.Pipeline{Inputs: []kio.Reader{rw}, Filters: fltrs, Outputs: []kio.Writer{rw}}.Execute())
+}
@@ -0,0 +1,223 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package functions_test
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/GoogleContainerTools/kpt/internal/util/functions"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/kustomize/kyaml/fn/runtime/runtimeutil"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// fakeWasmRuntime sets the WASI runtime to a script in dir which records
+// its args in dir/args and scales the replicas of the resources from 1 to
+// 3, returning a func restoring the runtime.
+func fakeWasmRuntime(t *testing.T, dir string) func() {
+	runtime := filepath.Join(dir, "runtime")
+	err := ioutil.WriteFile(runtime, []byte(fmt.Sprintf(`#!/bin/sh
+echo "$@" >> %s/args
+sed 's/replicas: 1/replicas: 3/'
+`, dir)), 0700)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	old, found := os.LookupEnv(WasmRuntimeEnv)
+	if !assert.NoError(t, os.Setenv(WasmRuntimeEnv, runtime+" run")) {
+		t.FailNow()
+	}
+	return func() {
+		if found {
+			os.Setenv(WasmRuntimeEnv, old)
+		} else {
+			os.Unsetenv(WasmRuntimeEnv)
+		}
+	}
+}
+
+func TestRunWasmFunctions(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kpt-wasm-test")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir)
+	defer fakeWasmRuntime(t, dir)()
+
+	pkg := filepath.Join(dir, "pkg")
+	deploy := `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: %s
+spec:
+  replicas: 1
+`
+	for path, content := range map[string]string{
+		"app/fn.yaml": `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: scale
+  annotations:
+    config.kubernetes.io/function: |
+      wasm:
+        module: fns/scale.wasm
+`,
+		"app/deploy.yaml": fmt.Sprintf(deploy, "app"),
+		"db/deploy.yaml":  fmt.Sprintf(deploy, "db"),
+	} {
+		path = filepath.Join(pkg, path)
+		if !assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0700)) {
+			t.FailNow()
+		}
+		if !assert.NoError(t, ioutil.WriteFile(path, []byte(content), 0600)) {
+			t.FailNow()
+		}
+	}
+
+	if !assert.NoError(t, RunWasmFunctions(pkg, false, "")) {
+		t.FailNow()
+	}
+	args, err := ioutil.ReadFile(filepath.Join(dir, "args"))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, "run "+filepath.Join(pkg, "app", "fns", "scale.wasm")+"\n", string(args))
+	// the function is only run against the resources in its directory
+	b, err := ioutil.ReadFile(filepath.Join(pkg, "app", "deploy.yaml"))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Contains(t, string(b), "replicas: 3")
+	b, err = ioutil.ReadFile(filepath.Join(pkg, "db", "deploy.yaml"))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Contains(t, string(b), "replicas: 1")
+
+	if !assert.NoError(t, RunWasmFunctions(pkg, true, "")) {
+		t.FailNow()
+	}
+	b, err = ioutil.ReadFile(filepath.Join(pkg, "db", "deploy.yaml"))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Contains(t, string(b), "replicas: 3")
+}
+
+func TestRunWasmFunctions_absolutePath(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kpt-wasm-test")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir)
+	err = ioutil.WriteFile(filepath.Join(dir, "fn.yaml"), []byte(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: scale
+  annotations:
+    config.kubernetes.io/function: |
+      wasm:
+        module: /bin/scale.wasm
+`), 0600)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.EqualError(t, RunWasmFunctions(dir, false, ""), "absolute function path /bin/scale.wasm not allowed")
+}
+
+func TestWasmFilter_url(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kpt-wasm-test")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir)
+	defer fakeWasmRuntime(t, dir)()
+	// modules are cached in the user cache directory
+	for _, env := range []string{"XDG_CACHE_HOME", "HOME"} {
+		old := os.Getenv(env)
+		os.Setenv(env, dir)
+		defer os.Setenv(env, old)
+	}
+
+	downloads := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		downloads++
+		fmt.Fprint(w, "\x00asm")
+	}))
+	defer server.Close()
+	digest := fmt.Sprintf("%x", sha256.Sum256([]byte("\x00asm")))
+
+	newFilter := func(module, digest string) *WasmFilter {
+		return &WasmFilter{Module: server.URL + module, SHA256: digest,
+			FunctionFilter: runtimeutil.FunctionFilter{GlobalScope: true}}
+	}
+	newNodes := func() []*yaml.RNode {
+		return []*yaml.RNode{yaml.MustParse(`apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+spec:
+  replicas: 1
+`)}
+	}
+
+	// modules are cached by digest, so a module moved to another url
+	// isn't downloaded again
+	for _, module := range []string{"/scale.wasm", "/v2/scale.wasm"} {
+		nodes, err := newFilter(module, digest).Filter(newNodes())
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		if assert.Len(t, nodes, 1) {
+			assert.Contains(t, nodes[0].MustString(), "replicas: 3")
+		}
+	}
+	assert.Equal(t, 1, downloads)
+
+	args, err := ioutil.ReadFile(filepath.Join(dir, "args"))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	lines := strings.Split(strings.TrimSpace(string(args)), "\n")
+	if assert.Len(t, lines, 2) {
+		module := strings.TrimPrefix(lines[0], "run ")
+		assert.Equal(t, lines[0], lines[1])
+		assert.Equal(t, digest+".wasm", filepath.Base(module))
+		b, err := ioutil.ReadFile(module)
+		if assert.NoError(t, err) {
+			assert.Equal(t, "\x00asm", string(b))
+		}
+	}
+
+	// modules not matching their digest aren't run or cached
+	other := fmt.Sprintf("%x", sha256.Sum256([]byte("other")))
+	_, err = newFilter("/scale.wasm", other).Filter(newNodes())
+	assert.EqualError(t, err, fmt.Sprintf("sha256 digest of wasm module %s/scale.wasm is %s, expected %s",
+		server.URL, digest, other))
+	_, err = os.Stat(filepath.Join(filepath.Dir(strings.TrimPrefix(lines[0], "run ")), other+".wasm"))
+	assert.True(t, os.IsNotExist(err))
+
+	_, err = newFilter("/scale.wasm", "").Filter(newNodes())
+	assert.EqualError(t, err, fmt.Sprintf("wasm module %s/scale.wasm requires a sha256 digest", server.URL))
+	assert.Equal(t, 2, downloads)
+}
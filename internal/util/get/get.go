@@ -17,16 +17,22 @@ package get
 
 import (
 	"bytes"
+	"context"
 	"fmt"
-	"io/ioutil"
+	"io"
 	"os"
 	"os/exec"
 	"path"
 	"path/filepath"
 	"strings"
+	"text/template"
 
 	"github.com/GoogleContainerTools/kpt/internal/gitutil"
 	"github.com/GoogleContainerTools/kpt/internal/util/git"
+	"github.com/GoogleContainerTools/kpt/internal/util/license"
+	"github.com/GoogleContainerTools/kpt/internal/util/progress"
+	"github.com/GoogleContainerTools/kpt/internal/util/tmputil"
+	"github.com/GoogleContainerTools/kpt/internal/util/vendored"
 	"github.com/GoogleContainerTools/kpt/pkg/kptfile"
 	"github.com/GoogleContainerTools/kpt/pkg/kptfile/kptfileutil"
 	"sigs.k8s.io/kustomize/kyaml/copyutil"
@@ -41,6 +47,8 @@ type Command struct {
 
 	// Destination is the output directory to clone the package to.  Defaults to the name of the package --
 	// either the base repo name, or the base subdirectory name.
+	// Destination may be a template, e.g. `vendor/{{.Org}}/{{.Repo}}/{{.Ref}}`, which is
+	// expanded using DestinationValues.
 	Destination string
 
 	// Name is the name to give the package.  Defaults to the destination.
@@ -48,61 +56,317 @@ type Command struct {
 
 	// Remove directory before copying to it.
 	Clean bool
+
+	// Progress receives progress updates while the package is fetched.
+	// Defaults to discarding updates.
+	Progress progress.Reporter
+
+	// RequirePinned if set rejects refs which do not pin the package to a
+	// fixed version -- i.e. branches rather than tags or commits.
+	RequirePinned bool
+
+	// GitConfig is passed as -c key=value flags to each git command run
+	// while fetching, e.g. to set http.extraHeader or http.sslCAInfo
+	// without changing the user's git config.
+	GitConfig map[string]string
+
+	// Licenses is the policy the license of the package is checked against
+	// before it is copied to the destination.
+	Licenses license.Policy
+
+	// license is the license detected for the package, recorded in the
+	// Kptfile upstream
+	license string
+
+	// relativeRepo is set if Repo was a path relative to the working
+	// directory, and is recorded in the Kptfile relative to the package
+	relativeRepo bool
 }
 
 // Run runs the Command.
 func (c Command) Run() error {
+	return c.RunContext(context.Background())
+}
+
+// RunContext runs the Command.  If ctx is cancelled or its deadline is
+// exceeded, any running git subprocesses are killed and the temporary clone
+// is removed.  The destination is left untouched unless copying had started.
+func (c Command) RunContext(ctx context.Context) error {
+	_, err := c.FetchContext(ctx)
+	return err
+}
+
+// FetchContext runs the Command like RunContext, and returns the
+// directories the packages were written to -- the Destination, or the
+// directories under it of the packages matching a Directory glob.
+func (c Command) FetchContext(ctx context.Context) ([]string, error) {
+	// relative upstreams are fetched from the enclosing repository
+	if gitutil.IsRelativeRepo(c.Repo) {
+		root, dir, err := gitutil.ResolveRelativeRepo(".", c.Repo)
+		if err != nil {
+			return nil, err
+		}
+		c.Repo, c.Directory, c.relativeRepo = root, dir, true
+	}
+
 	if err := (&c).DefaultValues(); err != nil {
-		return err
+		return nil, err
+	}
+
+	// packages matching a glob are fetched to directories under the destination
+	glob := IsDirectoryGlob(c.Directory)
+	if _, err := os.Stat(c.Destination); !glob && !c.Clean && !os.IsNotExist(err) {
+		return nil, errors.Errorf("destination directory %q already exists", c.Destination)
+	}
+
+	// the packages matching a glob don't share a directory prefixed tag, so
+	// the repo is cloned from its root
+	clonePath := c.Directory
+	if glob {
+		clonePath = "/"
 	}
 
-	if _, err := os.Stat(c.Destination); !c.Clean && !os.IsNotExist(err) {
-		return errors.Errorf("destination directory %q already exists", c.Destination)
+	if c.RequirePinned {
+		if err := gitutil.CheckPinnedRef(c.Repo, clonePath, c.TagTemplate, c.Ref, c.GitConfig); err != nil {
+			return nil, err
+		}
 	}
 
 	// normalize path to a filepath
 	if !strings.HasSuffix(c.Directory, "file://") {
 		c.Directory = filepath.Join(path.Split(c.Directory))
+		clonePath = filepath.Join(path.Split(clonePath))
 	}
 
 	// define where we are going to clone the package from
-	r := &git.RepoSpec{OrgRepo: c.Repo, Path: c.Directory, Ref: c.Ref}
+	r := &git.RepoSpec{OrgRepo: c.Repo, Path: clonePath, Ref: c.Ref, TagTemplate: c.TagTemplate,
+		GitConfig: c.GitConfig}
 
-	defaultRef, err := gitutil.DefaultRef(c.Repo)
-	if err != nil {
-		return err
-	}
+	c.Progress.Report(progress.Event{Phase: progress.ResolvingRef, Repo: c.Repo, Ref: c.Ref})
 
 	// clone the repo to a tmp directory.
 	// delete the tmp directory later.
-	err = ClonerUsingGitExec(r, defaultRef)
+	err := CloneUpstreamContext(ctx, r, c.Progress)
 	if err != nil {
-		return errors.Errorf("failed to clone git repo: %v", err)
+		return nil, errors.Errorf("failed to clone git repo: %v", err)
 	}
 	defer os.RemoveAll(r.Dir)
+	if err := ctx.Err(); err != nil {
+		return nil, errors.Wrap(err)
+	}
+	if glob {
+		return c.getGlob(r)
+	}
+
+	if err := (&c).checkLicense(r.AbsPath(), r.Dir); err != nil {
+		return nil, err
+	}
 
 	// delete the existing package if it exists
 	if c.Clean {
 		err = os.RemoveAll(c.Destination)
 		if err != nil {
-			return errors.Wrap(err)
+			return nil, errors.Wrap(err)
 		}
 	}
 
 	// copy the git sub directory to the destination
+	files, size := dirSize(r.AbsPath())
+	c.Progress.Report(progress.Event{Phase: progress.Copying, Repo: c.Repo, Ref: c.Ref,
+		Objects: files, Bytes: size})
 	err = copyutil.CopyDir(r.AbsPath(), c.Destination)
 	if err != nil {
-		return errors.WrapPrefixf(err, "missing subdirectory %q in repo %q at ref %q\n",
+		return nil, errors.WrapPrefixf(err, "missing subdirectory %q in repo %q at ref %q\n",
 			r.Path, r.OrgRepo, r.Ref)
 	}
 
 	// create or update the KptFile with the values from git
 	if err = (&c).upsertKptfile(r); err != nil {
+		return nil, errors.Wrap(err)
+	}
+	c.Progress.Report(progress.Event{Phase: progress.Done, Repo: c.Repo, Ref: c.Ref})
+	return []string{c.Destination}, nil
+}
+
+// IsDirectoryGlob returns true if dir is a glob matching multiple package
+// directories, e.g. /packages/*.
+func IsDirectoryGlob(dir string) bool {
+	return strings.ContainsAny(dir, "*?[")
+}
+
+// getGlob copies each package matching the Directory glob from the clone r
+// to a directory of the same name under the Destination, and returns the
+// directories written.  Each package is given its own Kptfile recording the
+// directory it was fetched from.  If a package can't be written, the
+// packages already written are removed.
+func (c Command) getGlob(r *git.RepoSpec) ([]string, error) {
+	matches, err := filepath.Glob(filepath.Join(r.Dir, c.Directory))
+	if err != nil {
+		return nil, errors.Errorf("invalid directory glob %q: %v", c.Directory, err)
+	}
+
+	var pkgs []Command
+	seen := map[string]string{}
+	for _, m := range matches {
+		if info, err := os.Stat(m); err != nil || !info.IsDir() || info.Name() == ".git" {
+			continue
+		}
+		dir, err := filepath.Rel(r.Dir, m)
+		if err != nil {
+			return nil, errors.Wrap(err)
+		}
+		name := filepath.Base(m)
+		if other, found := seen[name]; found {
+			return nil, errors.Errorf("directories %q and %q matching %q would both be "+
+				"fetched to %q", other, dir, c.Directory, name)
+		}
+		seen[name] = dir
+
+		pkg := c
+		pkg.Directory = "/" + filepath.ToSlash(dir)
+		pkg.Destination = filepath.Join(c.Destination, name)
+		pkg.Name = name
+		if _, err := os.Stat(pkg.Destination); !c.Clean && !os.IsNotExist(err) {
+			return nil, errors.Errorf("destination directory %q already exists", pkg.Destination)
+		}
+		pkgs = append(pkgs, pkg)
+	}
+	if len(pkgs) == 0 {
+		return nil, errors.Errorf("no directories match %q in repo %q at ref %q",
+			c.Directory, c.Repo, c.Ref)
+	}
+
+	for i := range pkgs {
+		if err := pkgs[i].checkLicense(filepath.Join(r.Dir, pkgs[i].Directory), r.Dir); err != nil {
+			return nil, err
+		}
+	}
+
+	var written []string
+	for i := range pkgs {
+		if err := c.copyGlobPackage(r, &pkgs[i], &written); err != nil {
+			for _, p := range written {
+				_ = os.RemoveAll(p)
+			}
+			return nil, err
+		}
+	}
+	c.Progress.Report(progress.Event{Phase: progress.Done, Repo: c.Repo, Ref: c.Ref})
+	return written, nil
+}
+
+// copyGlobPackage copies the package pkg matching the Directory glob from the
+// clone r to its destination, appending it to written once the destination
+// has been changed.
+func (c Command) copyGlobPackage(r *git.RepoSpec, pkg *Command, written *[]string) error {
+	if c.Clean {
+		if err := os.RemoveAll(pkg.Destination); err != nil {
+			return errors.Wrap(err)
+		}
+	}
+	*written = append(*written, pkg.Destination)
+	src := filepath.Join(r.Dir, pkg.Directory)
+	files, size := dirSize(src)
+	c.Progress.Report(progress.Event{Phase: progress.Copying, Repo: c.Repo, Ref: c.Ref,
+		Objects: files, Bytes: size})
+	if err := copyutil.CopyDir(src, pkg.Destination); err != nil {
+		return errors.WrapPrefixf(err, "failed to copy %q to %q", pkg.Directory, pkg.Destination)
+	}
+	if err := pkg.upsertKptfile(r); err != nil {
 		return errors.Wrap(err)
 	}
 	return nil
 }
 
+// checkLicense detects the license of the package cloned to dir in the repo
+// cloned to root, and checks it against the license policy.
+func (c *Command) checkLicense(dir, root string) error {
+	l, err := license.Detect(dir, root)
+	if err != nil {
+		return err
+	}
+	c.license = l
+	pkg := strings.TrimSuffix(c.Repo, "/")
+	if d := strings.Trim(filepath.ToSlash(c.Directory), "/"); d != "" && d != "." {
+		pkg += "/" + d
+	}
+	return c.Licenses.Check(pkg, l)
+}
+
+// dirSize returns the number of files and total bytes under dir, skipping
+// the .git directory.
+func dirSize(dir string) (int64, int64) {
+	var files, size int64
+	_ = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info.IsDir() && info.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		if !info.IsDir() {
+			files++
+			size += info.Size()
+		}
+		return nil
+	})
+	return files, size
+}
+
+// CloneUpstream clones the package of repoSpec, copying it from the vendor
+// tree of the working directory instead if it was vendored.
+func CloneUpstream(repoSpec *git.RepoSpec) error {
+	return CloneUpstreamContext(context.Background(), repoSpec, progress.Discard)
+}
+
+// CloneUpstreamContext is CloneUpstream, reporting the progress of the clone
+// to reporter and stopping if ctx is done.
+func CloneUpstreamContext(ctx context.Context, repoSpec *git.RepoSpec, reporter progress.Reporter) error {
+	if found, err := cloneVendored(repoSpec); found || err != nil {
+		return err
+	}
+	defaultRef, err := gitutil.DefaultRefWithConfig(repoSpec.OrgRepo, repoSpec.GitConfig)
+	if err != nil {
+		return err
+	}
+	return ClonerUsingGitExecContext(ctx, repoSpec, defaultRef, reporter)
+}
+
+// cloneVendored copies the package of repoSpec from the vendor tree to a
+// tmp directory, if it was vendored.
+func cloneVendored(repoSpec *git.RepoSpec) (bool, error) {
+	src, commit, found, err := vendored.Lookup(repoSpec.OrgRepo, repoSpec.Path, repoSpec.Ref)
+	if !found || err != nil {
+		return false, err
+	}
+	repoSpec.Dir, err = tmputil.TempDir("kpt-get-")
+	if err != nil {
+		return true, err
+	}
+	if err := copyutil.CopyDir(src, repoSpec.AbsPath()); err != nil {
+		_ = os.RemoveAll(repoSpec.Dir)
+		return true, errors.Wrap(err)
+	}
+	repoSpec.Commit = commit
+	return true, nil
+}
+
+// ClonedCommit returns the commit repoSpec was cloned at.
+func ClonedCommit(repoSpec *git.RepoSpec) (string, error) {
+	if repoSpec.Commit != "" {
+		return repoSpec.Commit, nil
+	}
+	cmd := exec.Command("git", gitutil.ConfigArgs(repoSpec.GitConfig, "rev-parse", "--verify", "HEAD")...)
+	cmd.Dir = repoSpec.AbsPath()
+	cmd.Env = os.Environ()
+	cmd.Stderr = os.Stderr
+	b, err := cmd.Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
 // Cloner is a function that can clone a git repo.
 type Cloner func(repoSpec *git.RepoSpec) error
 
@@ -110,22 +374,70 @@ type Cloner func(repoSpec *git.RepoSpec) error
 // to say, some remote API, to obtain a local clone of
 // a remote repo.
 func ClonerUsingGitExec(repoSpec *git.RepoSpec, defaultRef string) error {
-	// look for a tag with the directory as a prefix for versioning
-	// subdirectories independently
+	return ClonerUsingGitExecWithProgress(repoSpec, defaultRef, progress.Discard)
+}
+
+// ClonerUsingGitExecWithProgress is ClonerUsingGitExec, reporting the progress
+// of the clone to reporter.
+func ClonerUsingGitExecWithProgress(repoSpec *git.RepoSpec, defaultRef string,
+	reporter progress.Reporter) error {
+	return ClonerUsingGitExecContext(context.Background(), repoSpec, defaultRef, reporter)
+}
+
+// ClonerUsingGitExecContext is ClonerUsingGitExecWithProgress, killing the
+// git subprocesses and removing the temporary clone if ctx is done before the
+// clone completes.
+func ClonerUsingGitExecContext(ctx context.Context, repoSpec *git.RepoSpec,
+	defaultRef string, reporter progress.Reporter) error {
+	if reporter == nil {
+		reporter = progress.Discard
+	}
+
+	// resolve the ref to a branch or tag up front, so that a branch and tag
+	// with the same name are chosen between explicitly rather than by git
+	if _, err := gitutil.RefPrecedence(); err != nil {
+		return err
+	}
+	// failing to list the refs is reported by fetching them
+	resolved, found, err := gitutil.ResolveRef(
+		repoSpec.CloneSpec(), repoSpec.Path, repoSpec.TagTemplate, repoSpec.Ref, repoSpec.GitConfig)
+	if err == nil && found {
+		if w := resolved.Warning(repoSpec.Ref); w != "" {
+			reporter.Report(progress.Event{Phase: progress.Warning, Repo: repoSpec.OrgRepo,
+				Ref: repoSpec.Ref, Message: w})
+		}
+		repoSpec.Ref = resolved.Name
+	}
+
+	// look for a tag named by the tag template, e.g. with the directory as
+	// a prefix, for versioning subdirectories independently
 	originalRef := repoSpec.Ref
 	if repoSpec.Path != "" && !strings.Contains(repoSpec.Ref, "refs") {
-		// join the directory with the Ref (stripping the preceding '/' if it exists)
-		repoSpec.Ref = path.Join(strings.TrimLeft(repoSpec.Path, "/"), repoSpec.Ref)
+		tag, err := gitutil.PackageTag(repoSpec.TagTemplate, repoSpec.Path, repoSpec.Ref)
+		if err != nil {
+			return err
+		}
+		if tag != "" {
+			repoSpec.Ref = tag
+		}
+	}
+
+	// make sure there is room to clone the repo before starting
+	if err := tmputil.CheckFreeSpace(); err != nil {
+		return err
 	}
 
 	// clone the repo to a tmp directory.
 	// delete the tmp directory later.
-	err := clonerUsingGitExec(repoSpec)
-	if err != nil && originalRef != repoSpec.Ref {
+	err = clonerUsingGitExec(ctx, repoSpec, reporter)
+	if err != nil && ctx.Err() == nil && originalRef != repoSpec.Ref {
 		repoSpec.Ref = originalRef
-		err = clonerUsingGitExec(repoSpec)
+		err = clonerUsingGitExec(ctx, repoSpec, reporter)
 	}
 
+	if ctx.Err() != nil {
+		return errors.Errorf("failed to clone git repo: %v", ctx.Err())
+	}
 	if err != nil {
 		if strings.HasPrefix(repoSpec.Path, "blob/") {
 			return errors.Errorf("failed to clone git repo containing /blob/, "+
@@ -137,33 +449,48 @@ func ClonerUsingGitExec(repoSpec *git.RepoSpec, defaultRef string) error {
 	return nil
 }
 
-func clonerUsingGitExec(repoSpec *git.RepoSpec) error {
+func clonerUsingGitExec(ctx context.Context, repoSpec *git.RepoSpec,
+	reporter progress.Reporter) (err error) {
 	gitProgram, err := exec.LookPath("git")
 	if err != nil {
 		return errors.WrapPrefixf(err, "no 'git' program on path")
 	}
 
-	repoSpec.Dir, err = ioutil.TempDir("", "kpt-get-")
+	repoSpec.Dir, err = tmputil.TempDir("kpt-get-")
 	if err != nil {
 		return err
 	}
-	cmd := exec.Command(gitProgram, "init", repoSpec.Dir)
+	defer func() {
+		// don't leave partial clones behind
+		if err != nil {
+			_ = os.RemoveAll(repoSpec.Dir)
+		}
+	}()
+	cmd := exec.CommandContext(ctx, gitProgram,
+		gitutil.ConfigArgs(repoSpec.GitConfig, "init", repoSpec.Dir)...)
 	var out bytes.Buffer
 	cmd.Stdout = &out
 	cmd.Stderr = &out
 	err = cmd.Run()
 	if err != nil {
+		if ctx.Err() != nil {
+			return errors.Wrap(ctx.Err())
+		}
 		fmt.Fprintf(os.Stderr, "Error initializing empty git repo: %s", out.String())
 		return errors.WrapPrefixf(err, "trouble initializing empty git repo in %q",
 			repoSpec.Dir)
 	}
 
-	cmd = exec.Command(gitProgram, "remote", "add", "origin", repoSpec.CloneSpec())
+	cmd = exec.CommandContext(ctx, gitProgram,
+		gitutil.ConfigArgs(repoSpec.GitConfig, "remote", "add", "origin", repoSpec.CloneSpec())...)
 	cmd.Stdout = &out
 	cmd.Stderr = &out
 	cmd.Dir = repoSpec.Dir
 	err = cmd.Run()
 	if err != nil {
+		if ctx.Err() != nil {
+			return errors.Wrap(ctx.Err())
+		}
 		fmt.Fprintf(os.Stderr, "Error setting git remote: %s", out.String())
 		return errors.WrapPrefixf(
 			err,
@@ -171,23 +498,31 @@ func clonerUsingGitExec(repoSpec *git.RepoSpec) error {
 			repoSpec.CloneSpec())
 	}
 	if repoSpec.Ref == "" {
-		repoSpec.Ref, err = gitutil.DefaultRef(repoSpec.Dir)
+		reporter.Report(progress.Event{Phase: progress.ResolvingRef, Repo: repoSpec.OrgRepo})
+		repoSpec.Ref, err = gitutil.DefaultRefWithConfig(repoSpec.Dir, repoSpec.GitConfig)
 		if err != nil {
 			return err
 		}
 	}
 
+	// fetching reports the objects received by git fetch
+	fetching := progress.Event{Phase: progress.Fetching, Repo: repoSpec.OrgRepo, Ref: repoSpec.Ref}
+	checkingOut := progress.Event{Phase: progress.CheckingOut, Repo: repoSpec.OrgRepo, Ref: repoSpec.Ref}
 	err = func() error {
-		cmd = exec.Command(gitProgram, "fetch", "origin", "--depth=1", repoSpec.Ref)
+		reporter.Report(fetching)
+		cmd = exec.CommandContext(ctx, gitProgram,
+			gitutil.ConfigArgs(repoSpec.GitConfig, "fetch", "--progress", "origin", "--depth=1", repoSpec.Ref)...)
 		cmd.Stdout = &out
-		cmd.Stderr = &out
+		cmd.Stderr = io.MultiWriter(&out, &progress.GitWriter{Reporter: reporter, Event: fetching})
 		cmd.Dir = repoSpec.Dir
 		err = cmd.Run()
 		if err != nil {
 			return errors.WrapPrefixf(err, "trouble fetching %q, "+
 				"please run 'git clone <REPO>; stat <DIR/SUBDIR>' to verify credentials", repoSpec.Ref)
 		}
-		cmd = exec.Command(gitProgram, "reset", "--hard", "FETCH_HEAD")
+		reporter.Report(checkingOut)
+		cmd = exec.CommandContext(ctx, gitProgram,
+			gitutil.ConfigArgs(repoSpec.GitConfig, "reset", "--hard", "FETCH_HEAD")...)
 		cmd.Stdout = &out
 		cmd.Stderr = &out
 		cmd.Dir = repoSpec.Dir
@@ -198,16 +533,29 @@ func clonerUsingGitExec(repoSpec *git.RepoSpec) error {
 		}
 		return nil
 	}()
+	if err != nil && ctx.Err() != nil {
+		// cancelled -- don't fall back to fetching everything
+		return err
+	}
 	if err != nil {
-		cmd = exec.Command(gitProgram, "fetch", "origin")
+		reporter.Report(fetching)
+		cmd = exec.CommandContext(ctx, gitProgram,
+			gitutil.ConfigArgs(repoSpec.GitConfig, "fetch", "--progress", "origin")...)
 		cmd.Stdout = &out
-		cmd.Stderr = &out
+		cmd.Stderr = io.MultiWriter(&out, &progress.GitWriter{Reporter: reporter, Event: fetching})
 		cmd.Dir = repoSpec.Dir
 		if err = cmd.Run(); err != nil {
 			return errors.WrapPrefixf(err, "trouble fetching origin, "+
 				"please run 'git clone <REPO>; stat <DIR/SUBDIR>' to verify credentials")
 		}
-		cmd = exec.Command(gitProgram, "reset", "--hard", repoSpec.Ref)
+		reporter.Report(checkingOut)
+		// fetching origin stores its branches as remote tracking branches
+		target := repoSpec.Ref
+		if strings.HasPrefix(target, "refs/heads/") {
+			target = "refs/remotes/origin/" + strings.TrimPrefix(target, "refs/heads/")
+		}
+		cmd = exec.CommandContext(ctx, gitProgram,
+			gitutil.ConfigArgs(repoSpec.GitConfig, "reset", "--hard", target)...)
 		cmd.Stdout = &out
 		cmd.Stderr = &out
 		cmd.Dir = repoSpec.Dir
@@ -218,7 +566,8 @@ func clonerUsingGitExec(repoSpec *git.RepoSpec) error {
 		}
 	}
 
-	cmd = exec.Command(gitProgram, "submodule", "update", "--init", "--recursive")
+	cmd = exec.CommandContext(ctx, gitProgram,
+		gitutil.ConfigArgs(repoSpec.GitConfig, "submodule", "update", "--init", "--recursive")...)
 	cmd.Stdout = &out
 	cmd.Dir = repoSpec.Dir
 	err = cmd.Run()
@@ -238,6 +587,9 @@ func (c *Command) DefaultValues() error {
 	if len(c.Ref) == 0 {
 		return errors.Errorf("must specify ref")
 	}
+	if err := c.expandDestination(); err != nil {
+		return err
+	}
 	if len(c.Destination) == 0 {
 		return errors.Errorf("must specify destination")
 	}
@@ -250,6 +602,65 @@ func (c *Command) DefaultValues() error {
 		c.Name = filepath.Base(c.Destination)
 	}
 
+	if c.Progress == nil {
+		c.Progress = progress.Discard
+	}
+
+	return nil
+}
+
+// DestinationValues contains the values which may be referenced by a templated
+// Destination.
+type DestinationValues struct {
+	// Org is the organization of the repo, e.g. kubernetes-sigs
+	Org string
+
+	// Repo is the name of the repo without the .git suffix, e.g. kustomize
+	Repo string
+
+	// Ref is the git ref being fetched, e.g. v1.0.0
+	Ref string
+
+	// Directory is the package subdirectory in the repo
+	Directory string
+}
+
+// IsDestinationTemplate returns true if dest should be expanded as a template.
+func IsDestinationTemplate(dest string) bool {
+	return strings.Contains(dest, "{{")
+}
+
+// expandDestination expands the Destination as a template using the values
+// from the RepoSpec of the package being fetched.
+func (c *Command) expandDestination() error {
+	if !IsDestinationTemplate(c.Destination) {
+		return nil
+	}
+	t, err := template.New("destination").Option("missingkey=error").Parse(c.Destination)
+	if err != nil {
+		return errors.Errorf("invalid destination template %q: %v", c.Destination, err)
+	}
+
+	r := git.RepoSpec{OrgRepo: c.Repo, Path: c.Directory, Ref: c.Ref}
+	var b bytes.Buffer
+	err = t.Execute(&b, DestinationValues{
+		Org:       r.Org(),
+		Repo:      r.RepoName(),
+		Ref:       r.Ref,
+		Directory: strings.Trim(r.Path, "/"),
+	})
+	if err != nil {
+		return errors.Errorf("failed to expand destination template %q: %v", c.Destination, err)
+	}
+	// the values come from the upstream, which mustn't place the package
+	// outside of the destination
+	for _, e := range strings.Split(filepath.ToSlash(b.String()), "/") {
+		if e == ".." {
+			return errors.Errorf("destination template %q expanded to %q, which must not contain '..'",
+				c.Destination, b.String())
+		}
+	}
+	c.Destination = filepath.Clean(b.String())
 	return nil
 }
 
@@ -276,15 +687,10 @@ func (c *Command) upsertKptfile(spec *git.RepoSpec) error {
 	}
 
 	// find the git commit sha that we cloned the package at so we can write it to the KptFile
-	cmd := exec.Command("git", "rev-parse", "--verify", "HEAD")
-	cmd.Dir = spec.AbsPath()
-	cmd.Env = os.Environ()
-	cmd.Stderr = os.Stderr
-	b, err := cmd.Output()
+	commit, err := ClonedCommit(spec)
 	if err != nil {
 		return err
 	}
-	commit := strings.TrimSpace(string(b))
 
 	// populate the cloneFrom values so we know where the package came from
 	kpgfile.Upstream = kptfile.Upstream{
@@ -292,5 +698,15 @@ func (c *Command) upsertKptfile(spec *git.RepoSpec) error {
 		Git:  c.Git,
 	}
 	kpgfile.Upstream.Git.Commit = commit
+	kpgfile.Upstream.License = c.license
+	// the aliases are of the upstream's own upstream
+	kpgfile.UpstreamAliases = nil
+	if c.relativeRepo {
+		kpgfile.Upstream.Git.Repo, err = gitutil.RelativeRepo(
+			c.Destination, c.Repo, c.Directory)
+		if err != nil {
+			return err
+		}
+	}
 	return kptfileutil.WriteFile(c.Destination, kpgfile)
 }
@@ -15,13 +15,22 @@
 package get_test
 
 import (
+	"bytes"
+	"context"
 	"fmt"
+	"io/ioutil"
+	"os"
 	"path/filepath"
 	"testing"
 
+	"github.com/GoogleContainerTools/kpt/internal/gitutil"
 	"github.com/GoogleContainerTools/kpt/internal/testutil"
 	. "github.com/GoogleContainerTools/kpt/internal/util/get"
+	"github.com/GoogleContainerTools/kpt/internal/util/license"
+	"github.com/GoogleContainerTools/kpt/internal/util/progress"
+	"github.com/GoogleContainerTools/kpt/internal/util/tmputil"
 	"github.com/GoogleContainerTools/kpt/pkg/kptfile"
+	"github.com/GoogleContainerTools/kpt/pkg/kptfile/kptfileutil"
 	"github.com/stretchr/testify/assert"
 	"sigs.k8s.io/kustomize/kyaml/yaml"
 )
@@ -172,6 +181,76 @@ func TestCommand_Run_destination(t *testing.T) {
 	})
 }
 
+// TestCommand_Run_destinationTemplate verifies Command expands a templated destination
+// using the repo, ref and directory being fetched.
+func TestCommand_Run_destinationTemplate(t *testing.T) {
+	g, w, clean := testutil.SetupDefaultRepoAndWorkspace(t, testutil.Dataset1)
+	defer clean()
+
+	err := Command{
+		Git:         kptfile.Git{Repo: g.RepoDirectory, Ref: "master", Directory: "java"},
+		Destination: "vendor/{{.Repo}}/{{.Directory}}/{{.Ref}}",
+	}.Run()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	// verify the cloned contents matches the repository
+	r := filepath.Join(w.WorkspaceDirectory, "vendor", g.RepoName, "java", "master")
+	g.AssertEqual(t, filepath.Join(g.DatasetDirectory, testutil.Dataset1, "java"), r)
+}
+
+// TestCommand_Run_failDestinationTemplate verifies Command fails if the destination
+// template references an unknown value.
+func TestCommand_Run_failDestinationTemplate(t *testing.T) {
+	err := Command{
+		Git:         kptfile.Git{Repo: "foo", Ref: "master", Directory: "/"},
+		Destination: "vendor/{{.Branch}}",
+	}.Run()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "failed to expand destination template")
+	}
+}
+
+// TestCommand_Run_destinationTemplateParent verifies Command fails if the
+// expanded destination template contains '..'.
+func TestCommand_Run_destinationTemplateParent(t *testing.T) {
+	err := Command{
+		Git:         kptfile.Git{Repo: "foo", Ref: "master", Directory: "java/../../.."},
+		Destination: "vendor/{{.Directory}}",
+	}.Run()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "must not contain '..'")
+	}
+}
+
+// phaseRecorder records the phases reported while fetching a package
+type phaseRecorder struct {
+	phases []progress.Phase
+}
+
+func (r *phaseRecorder) Report(e progress.Event) {
+	if len(r.phases) == 0 || r.phases[len(r.phases)-1] != e.Phase {
+		r.phases = append(r.phases, e.Phase)
+	}
+}
+
+// TestCommand_Run_progress verifies Command reports the progress of each phase.
+func TestCommand_Run_progress(t *testing.T) {
+	g, _, clean := testutil.SetupDefaultRepoAndWorkspace(t, testutil.Dataset1)
+	defer clean()
+
+	r := &phaseRecorder{}
+	err := Command{
+		Git:         kptfile.Git{Repo: g.RepoDirectory, Ref: "master", Directory: "/"},
+		Destination: "my-dataset",
+		Progress:    r,
+	}.Run()
+	assert.NoError(t, err)
+	assert.Equal(t, []progress.Phase{progress.ResolvingRef, progress.Fetching,
+		progress.CheckingOut, progress.Copying, progress.Done}, r.phases)
+}
+
 // TestCommand_Run_subdirAndDestination verifies that Command will copy a subdirectory of a repo to a
 // specific destination.
 //
@@ -344,6 +423,51 @@ func TestCommand_Run_tag(t *testing.T) {
 	})
 }
 
+// TestCommand_Run_ambiguousRef verifies that a ref naming both a branch and
+// a tag fetches the ref chosen by KPT_REF_PRECEDENCE.
+func TestCommand_Run_ambiguousRef(t *testing.T) {
+	g, w, clean := testutil.SetupDefaultRepoAndWorkspace(t, testutil.Dataset1)
+	defer clean()
+
+	// tag v1 has dataset1, branch v1 has dataset2
+	testutil.Tag(t, g, "v1")
+	if !assert.NoError(t, g.CheckoutBranch("v1", true)) {
+		t.FailNow()
+	}
+	if !assert.NoError(t, g.ReplaceData(testutil.Dataset2)) {
+		t.FailNow()
+	}
+	testutil.Commit(t, g, "branch v1")
+	if !assert.NoError(t, g.CheckoutBranch("master", false)) {
+		t.FailNow()
+	}
+
+	for precedence, dataset := range map[string]string{
+		"tag": testutil.Dataset1, "branch": testutil.Dataset2} {
+		os.Setenv(gitutil.RefPrecedenceEnv, precedence)
+		b := &bytes.Buffer{}
+		err := Command{Git: kptfile.Git{
+			Repo: g.RepoDirectory, Ref: "v1", Directory: "/"},
+			Destination: precedence, Progress: &progress.WarningReporter{Writer: b}}.Run()
+		os.Unsetenv(gitutil.RefPrecedenceEnv)
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+
+		// the ambiguity is reported to the progress reporter
+		assert.Contains(t, b.String(), `warning: ref "v1" is ambiguous`)
+		dir := filepath.Join(w.WorkspaceDirectory, precedence)
+		g.AssertEqual(t, filepath.Join(g.DatasetDirectory, dataset), dir)
+
+		// the Kptfile keeps the ref as it was given
+		k, err := kptfileutil.ReadFile(dir)
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		assert.Equal(t, "v1", k.Upstream.Git.Ref)
+	}
+}
+
 // TestCommand_Run_clean verifies that the Command delete the existing directory if Clean is set.
 //
 // 1. clone the master branch
@@ -660,3 +784,318 @@ func TestCommand_DefaultValues_AtVersion(t *testing.T) {
 	c = Command{Git: kptfile.Git{Repo: "foo", Directory: "/", Ref: "r"}}
 	assert.EqualError(t, c.DefaultValues(), "must specify destination")
 }
+
+// TestCommand_Run_requirePinned verifies that only tags and commits may be
+// fetched when pinned upstreams are required.
+func TestCommand_Run_requirePinned(t *testing.T) {
+	g, w, clean := testutil.SetupDefaultRepoAndWorkspace(t, testutil.Dataset1)
+	defer clean()
+
+	commit, err := g.GetCommit()
+	assert.NoError(t, err)
+	assert.NoError(t, g.Tag("v1"))
+
+	tests := []struct {
+		ref    string
+		errMsg string
+	}{
+		{ref: "master", errMsg: `ref "master" is a branch`},
+		{ref: "refs/heads/master", errMsg: `ref "refs/heads/master" is a branch`},
+		{ref: "latest", errMsg: `ref "latest" is a floating ref`},
+		{ref: "v2", errMsg: `ref "v2" does not match a tag or commit`},
+		{ref: "v1"},
+		{ref: "refs/tags/v1"},
+		{ref: commit},
+	}
+	for i := range tests {
+		test := tests[i]
+		t.Run(test.ref, func(t *testing.T) {
+			dest := filepath.Join(w.WorkspaceDirectory, fmt.Sprintf("pkg-%d", i))
+			err := Command{
+				Git:           kptfile.Git{Repo: g.RepoDirectory, Ref: test.ref, Directory: "/"},
+				Destination:   dest,
+				RequirePinned: true,
+			}.Run()
+			if test.errMsg != "" {
+				if assert.Error(t, err) {
+					assert.Contains(t, err.Error(), test.errMsg)
+				}
+				assert.NoDirExists(t, dest)
+				return
+			}
+			assert.NoError(t, err)
+			g.AssertEqual(t, filepath.Join(g.DatasetDirectory, testutil.Dataset1), dest)
+		})
+	}
+}
+
+// cancelOnPhase cancels a context once phase is reported
+type cancelOnPhase struct {
+	phase  progress.Phase
+	cancel context.CancelFunc
+}
+
+func (r cancelOnPhase) Report(e progress.Event) {
+	if e.Phase == r.phase {
+		r.cancel()
+	}
+}
+
+// TestCommand_RunContext_cancel verifies that cancelling the context while
+// fetching fails without writing the package or leaving temporary clones.
+func TestCommand_RunContext_cancel(t *testing.T) {
+	g, w, clean := testutil.SetupDefaultRepoAndWorkspace(t, testutil.Dataset1)
+	defer clean()
+
+	tmp, err := ioutil.TempDir("", "kpt-test-tmp")
+	assert.NoError(t, err)
+	defer os.RemoveAll(tmp)
+	assert.NoError(t, os.Setenv(tmputil.TmpDirEnv, tmp))
+	defer os.Unsetenv(tmputil.TmpDirEnv)
+
+	for _, phase := range []progress.Phase{progress.Fetching, progress.CheckingOut} {
+		t.Run(string(phase), func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			dest := filepath.Join(w.WorkspaceDirectory, "my-dataset")
+			err := Command{
+				Git:         kptfile.Git{Repo: g.RepoDirectory, Ref: "master", Directory: "/"},
+				Destination: dest,
+				Progress:    cancelOnPhase{phase: phase, cancel: cancel},
+			}.RunContext(ctx)
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), context.Canceled.Error())
+			}
+			assert.NoDirExists(t, dest)
+
+			files, err := ioutil.ReadDir(tmp)
+			assert.NoError(t, err)
+			assert.Empty(t, files)
+		})
+	}
+}
+
+// TestCommand_RunContext_deadline verifies that an exceeded deadline fails
+// the fetch.
+func TestCommand_RunContext_deadline(t *testing.T) {
+	g, w, clean := testutil.SetupDefaultRepoAndWorkspace(t, testutil.Dataset1)
+	defer clean()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 0)
+	defer cancel()
+	dest := filepath.Join(w.WorkspaceDirectory, "my-dataset")
+	err := Command{
+		Git:         kptfile.Git{Repo: g.RepoDirectory, Ref: "master", Directory: "/"},
+		Destination: dest,
+	}.RunContext(ctx)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), context.DeadlineExceeded.Error())
+	}
+	assert.NoDirExists(t, dest)
+}
+
+// TestCommand_Run_relativeRepo verifies Command can fetch a package from the
+// enclosing repository using a relative path, and records the path in the
+// Kptfile relative to the fetched package.
+func TestCommand_Run_relativeRepo(t *testing.T) {
+	g, _, clean := testutil.SetupDefaultRepoAndWorkspace(t, testutil.Dataset1)
+	defer clean()
+	if !assert.NoError(t, os.Chdir(filepath.Join(g.RepoDirectory, "mysql"))) {
+		t.FailNow()
+	}
+
+	err := Command{
+		Git:         kptfile.Git{Repo: "./../java", Ref: "master"},
+		Destination: "java-copy",
+	}.Run()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	g.AssertEqual(t, filepath.Join(g.DatasetDirectory, testutil.Dataset1, "java"), "java-copy")
+	k, err := kptfileutil.ReadFile("java-copy")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, "../../java", k.Upstream.Git.Repo)
+	assert.Equal(t, "/java", k.Upstream.Git.Directory)
+	assert.Equal(t, "master", k.Upstream.Git.Ref)
+}
+
+// TestCommand_Run_gitConfig verifies that GitConfig is passed to each git
+// command, by fetching the repo through a url.insteadOf alias.
+func TestCommand_Run_gitConfig(t *testing.T) {
+	g, w, clean := testutil.SetupDefaultRepoAndWorkspace(t, testutil.Dataset1)
+	defer clean()
+
+	dest := filepath.Join(w.WorkspaceDirectory, g.RepoName)
+	err := Command{
+		Git: kptfile.Git{
+			Repo:      "kpt-test-alias:repo",
+			Ref:       "master",
+			Directory: "/",
+		},
+		Destination: dest,
+		GitConfig: map[string]string{
+			"url." + g.RepoDirectory + ".insteadOf": "kpt-test-alias:repo",
+		},
+	}.Run()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	g.AssertEqual(t, filepath.Join(g.DatasetDirectory, testutil.Dataset1), dest)
+
+	// without the config the alias can't be fetched
+	err = Command{
+		Git: kptfile.Git{
+			Repo:      "kpt-test-alias:repo",
+			Ref:       "master",
+			Directory: "/",
+		},
+		Destination: filepath.Join(w.WorkspaceDirectory, "no-config"),
+	}.Run()
+	assert.Error(t, err)
+}
+
+func TestCommand_Run_invalidGitConfig(t *testing.T) {
+	_, err := gitutil.ParseConfig([]string{"http.sslCAInfo"})
+	assert.EqualError(t, err, `invalid git config "http.sslCAInfo", must be key=value`)
+
+	config, err := gitutil.ParseConfig([]string{"http.extraHeader=Authorization: Bearer a=b"})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, []string{"-c", "http.extraHeader=Authorization: Bearer a=b", "fetch"},
+		gitutil.ConfigArgs(config, "fetch"))
+}
+
+// TestCommand_Run_directoryGlob verifies that each directory matching a glob
+// is fetched to a sibling package with its own Kptfile.
+func TestCommand_Run_directoryGlob(t *testing.T) {
+	g, w, clean := testutil.SetupDefaultRepoAndWorkspace(t, testutil.Dataset1)
+	defer clean()
+
+	dest := filepath.Join(w.WorkspaceDirectory, "catalog")
+	// a package fetched earlier from a matching directory isn't reported
+	err := Command{
+		Git: kptfile.Git{
+			Repo:      g.RepoDirectory,
+			Ref:       "master",
+			Directory: "/mysql",
+		},
+		Destination: filepath.Join(dest, "db"),
+	}.Run()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	paths, err := Command{
+		Git: kptfile.Git{
+			Repo:      g.RepoDirectory,
+			Ref:       "master",
+			Directory: "/*s*",
+		},
+		Destination: dest,
+	}.FetchContext(context.Background())
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, []string{filepath.Join(dest, "mysql"), filepath.Join(dest, "wordpress")}, paths)
+
+	commit, err := g.GetCommit()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	for _, name := range []string{"mysql", "wordpress"} {
+		g.AssertEqual(t, filepath.Join(g.DatasetDirectory, testutil.Dataset1, name),
+			filepath.Join(dest, name))
+		k, err := kptfileutil.ReadFile(filepath.Join(dest, name))
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		assert.Equal(t, name, k.Name)
+		assert.Equal(t, kptfile.Git{
+			Repo:      g.RepoDirectory,
+			Ref:       "master",
+			Directory: "/" + name,
+			Commit:    commit,
+		}, k.Upstream.Git)
+	}
+	assert.NoDirExists(t, filepath.Join(dest, "java"))
+
+	// fetching again fails without modifying the existing packages
+	err = Command{
+		Git: kptfile.Git{
+			Repo:      g.RepoDirectory,
+			Ref:       "master",
+			Directory: "/*",
+		},
+		Destination: dest,
+	}.Run()
+	assert.EqualError(t, err, fmt.Sprintf("destination directory %q already exists",
+		filepath.Join(dest, "mysql")))
+	assert.NoDirExists(t, filepath.Join(dest, "java"))
+}
+
+// TestCommand_Run_directoryGlobNoMatches verifies that get fails if no
+// directories match the glob.
+func TestCommand_Run_directoryGlobNoMatches(t *testing.T) {
+	g, w, clean := testutil.SetupDefaultRepoAndWorkspace(t, testutil.Dataset1)
+	defer clean()
+
+	dest := filepath.Join(w.WorkspaceDirectory, "catalog")
+	err := Command{
+		Git: kptfile.Git{
+			Repo:      g.RepoDirectory,
+			Ref:       "master",
+			Directory: "/python*",
+		},
+		Destination: dest,
+	}.Run()
+	assert.EqualError(t, err, fmt.Sprintf("no directories match %q in repo %q at ref %q",
+		"/python*", g.RepoDirectory, "master"))
+	assert.NoDirExists(t, dest)
+}
+
+// TestCommand_Run_licenses verifies that the license of the package is
+// recorded and checked against the license policy.
+func TestCommand_Run_licenses(t *testing.T) {
+	g, w, clean := testutil.SetupDefaultRepoAndWorkspace(t, testutil.Dataset1)
+	defer clean()
+
+	err := ioutil.WriteFile(filepath.Join(g.RepoDirectory, "LICENSE"),
+		[]byte("Apache License\nVersion 2.0, January 2004\n"), 0600)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	if !assert.NoError(t, gitutil.NewLocalGitRunner(g.RepoDirectory).Run("add", ".")) {
+		t.FailNow()
+	}
+	testutil.Commit(t, g, "add license")
+
+	dest := filepath.Join(w.WorkspaceDirectory, "disallowed")
+	err = Command{
+		Git:         kptfile.Git{Repo: g.RepoDirectory, Ref: "master", Directory: "/java"},
+		Destination: dest,
+		Licenses:    license.Policy{Allowed: []string{"MIT"}},
+	}.Run()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), fmt.Sprintf(`package %q has license "Apache-2.0" `+
+			`which is not in the allowed licenses MIT`, g.RepoDirectory+"/java"))
+	}
+	assert.NoDirExists(t, dest)
+
+	dest = filepath.Join(w.WorkspaceDirectory, "allowed")
+	err = Command{
+		Git:         kptfile.Git{Repo: g.RepoDirectory, Ref: "master", Directory: "/java"},
+		Destination: dest,
+		Licenses:    license.Policy{Allowed: []string{"MIT", "Apache-2.0"}},
+	}.Run()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	k, err := kptfileutil.ReadFile(dest)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, "Apache-2.0", k.Upstream.License)
+}
@@ -0,0 +1,323 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package get
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/GoogleContainerTools/kpt/internal/gitutil"
+	"github.com/GoogleContainerTools/kpt/internal/util/concurrency"
+	"github.com/GoogleContainerTools/kpt/internal/util/license"
+	"github.com/GoogleContainerTools/kpt/internal/util/progress"
+	"github.com/GoogleContainerTools/kpt/pkg/kptfile"
+	"sigs.k8s.io/kustomize/kyaml/errors"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// ReplaceStrategy is the manifest strategy which replaces an existing
+// destination rather than failing.  It matches the update strategy of the
+// same name.
+const ReplaceStrategy = "force-delete-replace"
+
+// Manifest declares a list of packages to fetch together.
+type Manifest struct {
+	yaml.ResourceMeta `yaml:",inline"`
+
+	// Packages are the packages to fetch
+	Packages []ManifestPackage `yaml:"packages,omitempty"`
+}
+
+// ManifestPackage declares a single package to fetch.
+type ManifestPackage struct {
+	// Git contains information about the git repo to fetch.  The ref defaults
+	// to the default branch of the repo, and the directory to the repo root.
+	Git kptfile.Git `yaml:"git,omitempty"`
+
+	// Destination is the local directory to fetch the package to.  Relative
+	// paths are resolved against the directory containing the manifest.
+	// Destination may be a template -- see DestinationValues.
+	Destination string `yaml:"destination,omitempty"`
+
+	// Strategy controls what happens if the destination already exists.
+	// Defaults to failing, or may be set to force-delete-replace.
+	Strategy string `yaml:"strategy,omitempty"`
+}
+
+// ReadManifest reads the packages manifest at path.
+func ReadManifest(path string) (Manifest, error) {
+	m := Manifest{}
+	f, err := os.Open(path)
+	if err != nil {
+		return m, errors.Errorf("unable to read packages manifest %q: %v", path, err)
+	}
+	defer f.Close()
+
+	d := yaml.NewDecoder(f)
+	d.KnownFields(true)
+	if err := d.Decode(&m); err != nil {
+		return m, errors.Errorf("unable to parse packages manifest %q: %v", path, err)
+	}
+	return m, nil
+}
+
+// BatchCommand fetches all of the packages declared in a manifest.
+//
+// Packages are fetched to staging directories and only moved to their
+// destinations once every package has been fetched successfully, so either
+// all of the packages are written or none of them are.
+type BatchCommand struct {
+	// ManifestPath is the path to the packages manifest
+	ManifestPath string
+
+	// StdOut is where the per-package results are written
+	StdOut io.Writer
+
+	// Progress receives progress updates while the packages are fetched
+	Progress progress.Reporter
+
+	// RequirePinned if set rejects packages which are not pinned to a tag
+	// or commit
+	RequirePinned bool
+
+	// GitConfig is passed as -c key=value flags to each git command
+	GitConfig map[string]string
+
+	// Licenses is the policy the license of each package is checked against
+	Licenses license.Policy
+
+	// Concurrency is the number of packages fetched at once.  Defaults to 1.
+	Concurrency int
+
+	// Destinations is populated with the directories the packages were
+	// written to after a successful Run
+	Destinations []string
+}
+
+// stagedPackage is a package which has been fetched, but not yet moved to
+// its destination.
+type stagedPackage struct {
+	stageDir    string
+	path        string
+	destination string
+	clean       bool
+
+	// get fetches the package to path
+	get Command
+
+	// moved and replaced record what move did, so it can be undone
+	moved    bool
+	replaced bool
+}
+
+// backup is where the package replaced by s is kept until every package has
+// been moved.
+func (s *stagedPackage) backup() string {
+	return s.path + ".previous"
+}
+
+// move moves the package from its staging directory to its destination,
+// moving aside the package it replaces.
+func (s *stagedPackage) move() error {
+	if s.clean {
+		if _, err := os.Lstat(s.destination); err == nil {
+			if err := os.Rename(s.destination, s.backup()); err != nil {
+				return errors.Wrap(err)
+			}
+			s.replaced = true
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(s.destination), 0700); err != nil {
+		return errors.Wrap(err)
+	}
+	if err := os.Rename(s.path, s.destination); err != nil {
+		return errors.Wrap(err)
+	}
+	s.moved = true
+	return nil
+}
+
+// restore undoes move, moving the package back to its staging directory and
+// the package it replaced back to the destination.
+func (s *stagedPackage) restore() error {
+	if s.moved {
+		if err := os.Rename(s.destination, s.path); err != nil {
+			return errors.Wrap(err)
+		}
+		s.moved = false
+	}
+	if s.replaced {
+		if err := os.Rename(s.backup(), s.destination); err != nil {
+			return errors.Wrap(err)
+		}
+		s.replaced = false
+	}
+	return nil
+}
+
+// Run fetches the packages declared in the manifest.
+func (c *BatchCommand) Run() error {
+	return c.RunContext(context.Background())
+}
+
+// RunContext fetches the packages declared in the manifest.  If ctx is done
+// before every package has been fetched, no packages are written.
+func (c *BatchCommand) RunContext(ctx context.Context) error {
+	if c.StdOut == nil {
+		c.StdOut = os.Stdout
+	}
+	m, err := ReadManifest(c.ManifestPath)
+	if err != nil {
+		return err
+	}
+	if len(m.Packages) == 0 {
+		return errors.Errorf("no packages declared in %q", c.ManifestPath)
+	}
+
+	var staged []stagedPackage
+	defer func() {
+		for _, s := range staged {
+			// keep replaced packages which couldn't be restored
+			if s.replaced {
+				continue
+			}
+			_ = os.RemoveAll(s.stageDir)
+		}
+	}()
+
+	// resolve the destinations in order, then fetch the packages at once
+	seen := map[string]bool{}
+	errs := make([]error, len(m.Packages))
+	stages := make([]stagedPackage, len(m.Packages))
+	for i := range m.Packages {
+		if err := ctx.Err(); err != nil {
+			return errors.Errorf("failed to fetch packages, no packages were written: %v", err)
+		}
+		stages[i], errs[i] = c.stage(m.Packages[i], seen)
+		if stages[i].stageDir != "" {
+			staged = append(staged, stages[i])
+		}
+	}
+	fetchErrs := concurrency.ForEach(c.Concurrency, len(m.Packages), func(i int) error {
+		if errs[i] != nil {
+			return errs[i]
+		}
+		return stages[i].get.RunContext(ctx)
+	})
+
+	var failed int
+	for i, err := range fetchErrs {
+		if err != nil {
+			failed++
+			fmt.Fprintf(c.StdOut, "failed to fetch package %q from %q: %v\n",
+				m.Packages[i].Git.Directory, m.Packages[i].Git.Repo, err)
+			continue
+		}
+		fmt.Fprintf(c.StdOut, "fetched package %q from %q to %q\n",
+			m.Packages[i].Git.Directory, m.Packages[i].Git.Repo, stages[i].destination)
+	}
+	if err := ctx.Err(); err != nil {
+		return errors.Errorf("failed to fetch packages, no packages were written: %v", err)
+	}
+	if failed > 0 {
+		return errors.Errorf("failed to fetch %d of %d packages, no packages were written",
+			failed, len(m.Packages))
+	}
+
+	// every package was fetched -- move them to their destinations, and
+	// move them back again if any of them can't be moved
+	c.Destinations = nil
+	for i := range staged {
+		if err := staged[i].move(); err != nil {
+			for j := i; j >= 0; j-- {
+				if rerr := staged[j].restore(); rerr != nil {
+					return errors.Errorf("failed to write package to %q: %v, "+
+						"and failed to restore %q from %q: %v", staged[i].destination, err,
+						staged[j].destination, staged[j].stageDir, rerr)
+				}
+			}
+			return errors.WrapPrefixf(err,
+				"failed to write package to %q, no packages were written", staged[i].destination)
+		}
+	}
+	for i := range staged {
+		// the replaced packages are removed with the staging directories
+		staged[i].replaced = false
+		c.Destinations = append(c.Destinations, staged[i].destination)
+	}
+	return nil
+}
+
+// stage creates a staging directory next to the destination of p, and
+// returns the command to fetch p to it.
+func (c *BatchCommand) stage(p ManifestPackage, seen map[string]bool) (stagedPackage, error) {
+	s := stagedPackage{}
+	switch p.Strategy {
+	case "":
+	case ReplaceStrategy:
+		s.clean = true
+	default:
+		return s, errors.Errorf("unsupported strategy %q, must be one of: %s",
+			p.Strategy, ReplaceStrategy)
+	}
+
+	get := Command{Git: p.Git, Destination: p.Destination, Progress: c.Progress,
+		RequirePinned: c.RequirePinned, GitConfig: c.GitConfig, Licenses: c.Licenses}
+	if get.Directory == "" {
+		get.Directory = "/"
+	}
+	if get.Repo != "" && get.Ref == "" {
+		defaultRef, err := gitutil.DefaultRefWithConfig(get.Repo, c.GitConfig)
+		if err != nil {
+			return s, err
+		}
+		get.Ref = defaultRef
+	}
+	if err := get.DefaultValues(); err != nil {
+		return s, err
+	}
+	s.destination = get.Destination
+	if !filepath.IsAbs(s.destination) {
+		s.destination = filepath.Join(filepath.Dir(c.ManifestPath), s.destination)
+	}
+	if seen[s.destination] {
+		return s, errors.Errorf("destination %q is used by more than one package", s.destination)
+	}
+	seen[s.destination] = true
+	if _, err := os.Stat(s.destination); !s.clean && !os.IsNotExist(err) {
+		return s, errors.Errorf("destination directory %q already exists", s.destination)
+	}
+
+	// stage next to the destination so the package can be renamed into place
+	parent := filepath.Dir(s.destination)
+	if err := os.MkdirAll(parent, 0700); err != nil {
+		return s, errors.Wrap(err)
+	}
+	var err error
+	s.stageDir, err = ioutil.TempDir(parent, ".kpt-get-")
+	if err != nil {
+		return s, errors.Wrap(err)
+	}
+	s.path = filepath.Join(s.stageDir, filepath.Base(s.destination))
+
+	get.Destination = s.path
+	get.Name = filepath.Base(s.destination)
+	s.get = get
+	return s, nil
+}
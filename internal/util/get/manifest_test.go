@@ -0,0 +1,282 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package get_test
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt/internal/testutil"
+	. "github.com/GoogleContainerTools/kpt/internal/util/get"
+	"github.com/GoogleContainerTools/kpt/internal/util/progress"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestBatchCommand_Run verifies that BatchCommand fetches every package declared
+// in the manifest.
+func TestBatchCommand_Run(t *testing.T) {
+	g, w, clean := testutil.SetupDefaultRepoAndWorkspace(t, testutil.Dataset1)
+	defer clean()
+
+	m := filepath.Join(w.WorkspaceDirectory, "packages.yaml")
+	err := ioutil.WriteFile(m, []byte(fmt.Sprintf(`
+packages:
+- git:
+    repo: %[1]s
+    directory: java
+    ref: master
+  destination: vendor/java
+- git:
+    repo: %[1]s
+    directory: mysql
+  destination: vendor/{{.Directory}}
+`, g.RepoDirectory)), 0600)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	b := &bytes.Buffer{}
+	c := &BatchCommand{ManifestPath: m, StdOut: b}
+	if !assert.NoError(t, c.Run()) {
+		t.FailNow()
+	}
+
+	java := filepath.Join(w.WorkspaceDirectory, "vendor", "java")
+	mysql := filepath.Join(w.WorkspaceDirectory, "vendor", "mysql")
+	assert.Equal(t, []string{java, mysql}, c.Destinations)
+	g.AssertEqual(t, filepath.Join(g.DatasetDirectory, testutil.Dataset1, "java"), java)
+	g.AssertEqual(t, filepath.Join(g.DatasetDirectory, testutil.Dataset1, "mysql"), mysql)
+	assert.Contains(t, b.String(), fmt.Sprintf("fetched package \"java\" from %q to %q", g.RepoDirectory, java))
+
+	// staging directories should be cleaned up
+	files, err := ioutil.ReadDir(filepath.Join(w.WorkspaceDirectory, "vendor"))
+	assert.NoError(t, err)
+	assert.Len(t, files, 2)
+}
+
+// TestBatchCommand_Run_concurrency verifies that BatchCommand fetches packages
+// at once, and prints the results in manifest order.
+func TestBatchCommand_Run_concurrency(t *testing.T) {
+	g, w, clean := testutil.SetupDefaultRepoAndWorkspace(t, testutil.Dataset1)
+	defer clean()
+
+	m := filepath.Join(w.WorkspaceDirectory, "packages.yaml")
+	err := ioutil.WriteFile(m, []byte(fmt.Sprintf(`
+packages:
+- git:
+    repo: %[1]s
+    directory: java
+    ref: master
+  destination: vendor/java
+- git:
+    repo: %[1]s
+    directory: mysql
+    ref: master
+  destination: vendor/mysql
+- git:
+    repo: %[1]s
+    directory: /
+    ref: master
+  destination: vendor/all
+`, g.RepoDirectory)), 0600)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	b := &bytes.Buffer{}
+	c := &BatchCommand{ManifestPath: m, StdOut: b, Concurrency: 3}
+	if !assert.NoError(t, c.Run()) {
+		t.FailNow()
+	}
+
+	vendor := filepath.Join(w.WorkspaceDirectory, "vendor")
+	assert.Equal(t, fmt.Sprintf(`fetched package "java" from %[1]q to %[2]q
+fetched package "mysql" from %[1]q to %[3]q
+fetched package "/" from %[1]q to %[4]q
+`, g.RepoDirectory, filepath.Join(vendor, "java"), filepath.Join(vendor, "mysql"),
+		filepath.Join(vendor, "all")), b.String())
+	g.AssertEqual(t, filepath.Join(g.DatasetDirectory, testutil.Dataset1, "mysql"),
+		filepath.Join(vendor, "mysql"))
+}
+
+// TestBatchCommand_Run_failure verifies that BatchCommand doesn't write any packages
+// if one of them can't be fetched.
+func TestBatchCommand_Run_failure(t *testing.T) {
+	g, w, clean := testutil.SetupDefaultRepoAndWorkspace(t, testutil.Dataset1)
+	defer clean()
+
+	m := filepath.Join(w.WorkspaceDirectory, "packages.yaml")
+	err := ioutil.WriteFile(m, []byte(fmt.Sprintf(`
+packages:
+- git:
+    repo: %[1]s
+    directory: java
+    ref: master
+  destination: vendor/java
+- git:
+    repo: %[1]s
+    directory: mysql
+    ref: not-a-branch
+  destination: vendor/mysql
+`, g.RepoDirectory)), 0600)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	b := &bytes.Buffer{}
+	err = (&BatchCommand{ManifestPath: m, StdOut: b}).Run()
+	assert.EqualError(t, err, "failed to fetch 1 of 2 packages, no packages were written")
+	assert.Contains(t, b.String(), "fetched package \"java\"")
+	assert.Contains(t, b.String(), "failed to fetch package \"mysql\"")
+
+	files, err := ioutil.ReadDir(filepath.Join(w.WorkspaceDirectory, "vendor"))
+	assert.NoError(t, err)
+	assert.Empty(t, files)
+}
+
+// blockOnDone creates a non-empty directory at dir once a package has been
+// fetched, so that the package to be moved there can't be.
+type blockOnDone struct {
+	dir string
+}
+
+func (r blockOnDone) Report(e progress.Event) {
+	if e.Phase != progress.Done {
+		return
+	}
+	_ = os.MkdirAll(r.dir, 0700)
+	_ = ioutil.WriteFile(filepath.Join(r.dir, "blocker"), nil, 0600)
+}
+
+// TestBatchCommand_Run_moveFailure verifies that BatchCommand restores the
+// packages it already moved if one of them can't be moved to its destination.
+func TestBatchCommand_Run_moveFailure(t *testing.T) {
+	g, w, clean := testutil.SetupDefaultRepoAndWorkspace(t, testutil.Dataset1)
+	defer clean()
+
+	m := filepath.Join(w.WorkspaceDirectory, "packages.yaml")
+	err := ioutil.WriteFile(m, []byte(fmt.Sprintf(`
+packages:
+- git:
+    repo: %[1]s
+    directory: java
+    ref: master
+  destination: vendor/java
+  strategy: force-delete-replace
+- git:
+    repo: %[1]s
+    directory: mysql
+    ref: master
+  destination: vendor/mysql
+`, g.RepoDirectory)), 0600)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	// the package replaced by java should be restored
+	vendor := filepath.Join(w.WorkspaceDirectory, "vendor")
+	java := filepath.Join(vendor, "java")
+	if !assert.NoError(t, os.MkdirAll(java, 0700)) {
+		t.FailNow()
+	}
+	if !assert.NoError(t, ioutil.WriteFile(filepath.Join(java, "previous"), nil, 0600)) {
+		t.FailNow()
+	}
+
+	b := &bytes.Buffer{}
+	c := &BatchCommand{ManifestPath: m, StdOut: b,
+		Progress: blockOnDone{dir: filepath.Join(vendor, "mysql")}}
+	err = c.Run()
+	if !assert.Error(t, err) {
+		t.FailNow()
+	}
+	assert.Contains(t, err.Error(), "failed to write package to")
+	assert.Contains(t, err.Error(), "no packages were written")
+	assert.Empty(t, c.Destinations)
+
+	files, err := ioutil.ReadDir(java)
+	assert.NoError(t, err)
+	if assert.Len(t, files, 1) {
+		assert.Equal(t, "previous", files[0].Name())
+	}
+
+	// staging directories should be cleaned up
+	files, err = ioutil.ReadDir(vendor)
+	assert.NoError(t, err)
+	var names []string
+	for _, f := range files {
+		names = append(names, f.Name())
+	}
+	assert.Equal(t, []string{"java", "mysql"}, names)
+}
+
+// TestBatchCommand_Run_duplicateDestination verifies that BatchCommand fails if
+// packages share a destination.
+func TestBatchCommand_Run_duplicateDestination(t *testing.T) {
+	g, w, clean := testutil.SetupDefaultRepoAndWorkspace(t, testutil.Dataset1)
+	defer clean()
+
+	m := filepath.Join(w.WorkspaceDirectory, "packages.yaml")
+	err := ioutil.WriteFile(m, []byte(fmt.Sprintf(`
+packages:
+- git:
+    repo: %[1]s
+    directory: java
+    ref: master
+  destination: vendor/{{.Repo}}
+- git:
+    repo: %[1]s
+    directory: mysql
+    ref: master
+  destination: vendor/{{.Repo}}
+`, g.RepoDirectory)), 0600)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	b := &bytes.Buffer{}
+	err = (&BatchCommand{ManifestPath: m, StdOut: b}).Run()
+	assert.Error(t, err)
+	assert.Contains(t, b.String(), "is used by more than one package")
+	_, err = os.Stat(filepath.Join(w.WorkspaceDirectory, "vendor", g.RepoName))
+	assert.True(t, os.IsNotExist(err))
+}
+
+// TestBatchCommand_Run_unknownField verifies that BatchCommand fails for
+// malformed manifests.
+func TestBatchCommand_Run_unknownField(t *testing.T) {
+	d, err := ioutil.TempDir("", "kpt-manifest")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer os.RemoveAll(d)
+
+	m := filepath.Join(d, "packages.yaml")
+	err = ioutil.WriteFile(m, []byte(`
+packages:
+- repo: foo
+`), 0600)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	err = (&BatchCommand{ManifestPath: m}).Run()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "unable to parse packages manifest")
+	}
+}
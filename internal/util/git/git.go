@@ -39,8 +39,20 @@ type RepoSpec struct {
 	// Branch or tag reference.
 	Ref string
 
+	// TagTemplate names the tags which version the package in Path, see
+	// gitutil.PackageTag.
+	TagTemplate string
+
 	// e.g. .git or empty in case of _git is present
 	GitSuffix string
+
+	// GitConfig is passed as -c key=value flags to each git command run
+	// against the repo, e.g. http.extraHeader or http.sslCAInfo
+	GitConfig map[string]string
+
+	// Commit is the commit Dir was copied from, if it isn't a git clone,
+	// e.g. a package copied from a vendor tree
+	Commit string
 }
 
 // AbsPath is the absolute path to the subdirectory
@@ -48,6 +60,46 @@ func (rs RepoSpec) AbsPath() string {
 	return filepath.Join(rs.Dir, rs.Path)
 }
 
+// Org returns the organization, or parent path, of the repository,
+// e.g. kubernetes-sigs
+func (rs RepoSpec) Org() string {
+	parts := rs.orgRepoParts()
+	if len(parts) < 2 {
+		return ""
+	}
+	return parts[len(parts)-2]
+}
+
+// RepoName returns the base name of the repository without the .git suffix,
+// e.g. kustomize
+func (rs RepoSpec) RepoName() string {
+	parts := rs.orgRepoParts()
+	if len(parts) == 0 {
+		return ""
+	}
+	return parts[len(parts)-1]
+}
+
+// orgRepoParts splits OrgRepo into its path elements, dropping the scheme
+// and the .git suffix.
+func (rs RepoSpec) orgRepoParts() []string {
+	v := rs.OrgRepo
+	if i := strings.Index(v, "://"); i >= 0 {
+		v = v[i+len("://"):]
+	}
+	v = strings.TrimSuffix(strings.TrimSuffix(v, "/"), ".git")
+	// support scp-like syntax, e.g. git@github.com:org/repo
+	v = strings.ReplaceAll(v, ":", "/")
+
+	var parts []string
+	for _, p := range strings.Split(v, "/") {
+		if p != "" {
+			parts = append(parts, p)
+		}
+	}
+	return parts
+}
+
 // CloneSpec returns the string to pass to git to clone
 func (rs *RepoSpec) CloneSpec() string {
 	if isAzureHost(rs.Host) || isAWSHost(rs.Host) {
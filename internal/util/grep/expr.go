@@ -0,0 +1,87 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package grep selects resources matching JSONPath queries over their
+// fields, such as `[?(@.spec.replicas > 3)]`.
+package grep
+
+import (
+	"reflect"
+	"strings"
+
+	"k8s.io/client-go/util/jsonpath"
+	"sigs.k8s.io/kustomize/kyaml/errors"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// Expression is a JSONPath query over the fields of a resource.
+type Expression struct {
+	path *jsonpath.JSONPath
+}
+
+// Parse parses a JSONPath query, in the syntax of kubectl -o jsonpath, with
+// or without the surrounding braces.  Queries starting with a field, such as
+// .metadata.annotations.team, select the fields of each resource.  Queries
+// starting with a filter, such as [?(@.spec.replicas > 3)], filter the
+// resources themselves.
+func Parse(query string) (*Expression, error) {
+	q := strings.TrimSpace(query)
+	if strings.HasPrefix(q, "{") && strings.HasSuffix(q, "}") {
+		q = strings.TrimSpace(q[1 : len(q)-1])
+	}
+	switch {
+	case q == "":
+		return nil, errors.Errorf("invalid expression %q: empty query", query)
+	case strings.HasPrefix(q, "[?("):
+		// resources are matched as the elements of a list
+	case strings.HasPrefix(q, "."):
+		q = "[*]" + q
+	default:
+		q = "[*]." + q
+	}
+	p := jsonpath.New("grep").AllowMissingKeys(true)
+	if err := p.Parse("{" + q + "}"); err != nil {
+		return nil, errors.WrapPrefixf(err, "invalid expression %q", query)
+	}
+	return &Expression{path: p}, nil
+}
+
+// Match returns true if the query selects any value of the resource, and
+// the selected values.  Resources selected by a filter have no values.
+func (e *Expression) Match(node *yaml.RNode) (bool, []interface{}, error) {
+	var resource interface{}
+	if err := node.YNode().Decode(&resource); err != nil {
+		return false, nil, errors.Wrap(err)
+	}
+	results, err := e.path.FindResults([]interface{}{resource})
+	if err != nil {
+		return false, nil, errors.Wrap(err)
+	}
+	match := false
+	var values []interface{}
+	for _, r := range results {
+		for _, v := range r {
+			match = true
+			if v.Kind() == reflect.Interface {
+				v = v.Elem()
+			}
+			if v.Kind() == reflect.Map && v.Pointer() == reflect.ValueOf(resource).Pointer() {
+				// the resource itself
+				continue
+			}
+			values = append(values, v.Interface())
+		}
+	}
+	return match, values, nil
+}
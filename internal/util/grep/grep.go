@@ -0,0 +1,103 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grep
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/GoogleContainerTools/kpt/internal/util/pipe"
+	"sigs.k8s.io/kustomize/kyaml/errors"
+	"sigs.k8s.io/kustomize/kyaml/kio/kioutil"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// Filter filters resources by whether an expression selects any of their
+// values.
+type Filter struct {
+	// Expression is the expression resources are matched against.
+	Expression *Expression
+
+	// InvertMatch if set selects the resources not matching Expression.
+	InvertMatch bool
+
+	// Results are the results for the selected resources, set by Filter.
+	Results []Result
+}
+
+// Result is a resource selected by an expression.
+type Result struct {
+	File       string `json:"file,omitempty"`
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Name       string `json:"name"`
+	Namespace  string `json:"namespace,omitempty"`
+
+	// Values are the values the expression selected.  There are none for
+	// resources selected by a filter, or by InvertMatch.
+	Values []interface{} `json:"values,omitempty"`
+}
+
+// Filter implements kio.Filter.
+func (f *Filter) Filter(nodes []*yaml.RNode) ([]*yaml.RNode, error) {
+	f.Results = nil
+	var selected []*yaml.RNode
+	for _, node := range nodes {
+		m, err := node.GetMeta()
+		if err != nil {
+			return nil, err
+		}
+		match, values, err := f.Expression.Match(node)
+		if err != nil {
+			return nil, errors.WrapPrefixf(err, "%s/%s", m.Kind, m.Name)
+		}
+		if match == f.InvertMatch {
+			continue
+		}
+		r, err := result(node, m)
+		if err != nil {
+			return nil, err
+		}
+		if !f.InvertMatch {
+			r.Values = values
+		}
+		selected = append(selected, node)
+		f.Results = append(f.Results, r)
+	}
+	return selected, nil
+}
+
+// result returns the result for node.
+func result(node *yaml.RNode, m yaml.ResourceMeta) (Result, error) {
+	file, _, err := kioutil.GetFileAnnotations(node)
+	if err != nil {
+		return Result{}, err
+	}
+	if m.Annotations[pipe.SynthesizedPathAnnotation] == "true" {
+		file = ""
+	}
+	return Result{File: file, APIVersion: m.APIVersion, Kind: m.Kind, Name: m.Name,
+		Namespace: m.Namespace}, nil
+}
+
+// WriteResults writes results to w as json.
+func WriteResults(w io.Writer, results []Result) error {
+	if results == nil {
+		results = []Result{}
+	}
+	e := json.NewEncoder(w)
+	e.SetIndent("", "  ")
+	return errors.Wrap(e.Encode(results))
+}
@@ -0,0 +1,173 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package grep_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	. "github.com/GoogleContainerTools/kpt/internal/util/grep"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+)
+
+const resources = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  annotations:
+    team: frontend
+    config.kubernetes.io/path: web.yaml
+spec:
+  replicas: 5
+  template:
+    spec:
+      containers:
+      - name: nginx
+        image: nginx:1.19
+        resources:
+          limits:
+            memory: 1Gi
+      - name: sidecar
+        image: envoy:1.16
+        args: [--debug]
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: db
+  annotations:
+    config.kubernetes.io/path: db.yaml
+spec:
+  replicas: 1
+  template:
+    spec:
+      containers:
+      - name: mysql
+        image: mysql:8
+        resources:
+          limits:
+            memory: 512Mi
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+  annotations:
+    config.kubernetes.io/path: web.yaml
+`
+
+func TestFilter(t *testing.T) {
+	var tests = []struct {
+		name     string
+		expr     string
+		invert   bool
+		expected []string
+	}{
+		{name: "set", expr: ".spec.replicas", expected: []string{"Deployment/web", "Deployment/db"}},
+		{name: "braces", expr: "{.spec.replicas}", expected: []string{"Deployment/web", "Deployment/db"}},
+		{name: "no dot", expr: "metadata.annotations.team", expected: []string{"Deployment/web"}},
+		{name: "equal", expr: `[?(@.kind == "Service")]`, expected: []string{"Service/web"}},
+		{name: "not equal", expr: `[?(@.metadata.name != "web")]`, expected: []string{"Deployment/db"}},
+		{name: "greater", expr: "[?(@.spec.replicas > 3)]", expected: []string{"Deployment/web"}},
+		{name: "less or equal", expr: "[?(@.spec.replicas <= 1)]", expected: []string{"Deployment/db"}},
+		{
+			name:     "list filter",
+			expr:     `.spec.template.spec.containers[?(@.image == "mysql:8")]`,
+			expected: []string{"Deployment/db"},
+		},
+		{
+			name:     "list field",
+			expr:     `.spec.template.spec.containers[?(@.name == "sidecar")].args`,
+			expected: []string{"Deployment/web"},
+		},
+		{name: "invert", expr: `[?(@.kind == "Deployment")]`, invert: true, expected: []string{"Service/web"}},
+	}
+	for i := range tests {
+		test := tests[i]
+		t.Run(test.name, func(t *testing.T) {
+			e, err := Parse(test.expr)
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			f := &Filter{Expression: e, InvertMatch: test.invert}
+			err = kio.Pipeline{
+				Inputs:  []kio.Reader{&kio.ByteReader{Reader: strings.NewReader(resources)}},
+				Filters: []kio.Filter{f},
+			}.Execute()
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			var actual []string
+			for _, r := range f.Results {
+				actual = append(actual, r.Kind+"/"+r.Name)
+			}
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+}
+
+func TestParse_errors(t *testing.T) {
+	var tests = []struct {
+		expr     string
+		expected string
+	}{
+		{expr: "", expected: `invalid expression "": empty query`},
+		{expr: "{}", expected: `invalid expression "{}": empty query`},
+		{expr: "[?(@.spec.replicas > 3", expected: `invalid expression "[?(@.spec.replicas > 3": unterminated filter`},
+		{expr: ".spec.containers[0", expected: `invalid expression ".spec.containers[0": unterminated array`},
+	}
+	for i := range tests {
+		test := tests[i]
+		t.Run(test.expr, func(t *testing.T) {
+			_, err := Parse(test.expr)
+			if assert.Error(t, err) {
+				assert.Equal(t, test.expected, err.Error())
+			}
+		})
+	}
+}
+
+func TestWriteResults(t *testing.T) {
+	e, err := Parse(".spec.template.spec.containers[?(@.name == \"nginx\")].image")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	f := &Filter{Expression: e}
+	err = kio.Pipeline{
+		Inputs:  []kio.Reader{&kio.ByteReader{Reader: strings.NewReader(resources)}},
+		Filters: []kio.Filter{f},
+	}.Execute()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	b := &bytes.Buffer{}
+	if !assert.NoError(t, WriteResults(b, f.Results)) {
+		t.FailNow()
+	}
+	assert.Equal(t, `[
+  {
+    "file": "web.yaml",
+    "apiVersion": "apps/v1",
+    "kind": "Deployment",
+    "name": "web",
+    "values": [
+      "nginx:1.19"
+    ]
+  }
+]
+`, b.String())
+}
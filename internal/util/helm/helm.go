@@ -0,0 +1,373 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package helm converts Helm charts into packages.
+package helm
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/GoogleContainerTools/kpt/internal/util/clusterpkg"
+	"sigs.k8s.io/kustomize/kyaml/errors"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/kio/kioutil"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// Chart renders a Helm chart using the helm binary.
+type Chart struct {
+	// Binary is the helm binary to run.  Defaults to helm.
+	Binary string
+
+	// Chart is the chart reference, path or url
+	Chart string
+
+	// Repo is the chart repository url to fetch the chart from
+	Repo string
+
+	// Version is the version of the chart to fetch
+	Version string
+
+	// ReleaseName is the release name to render the chart with
+	ReleaseName string
+
+	// Namespace is the namespace to render the chart with
+	Namespace string
+
+	// ValuesFiles are the values files to render the chart with
+	ValuesFiles []string
+}
+
+// Render renders the chart and returns its resources.
+func (c Chart) Render() ([]*yaml.RNode, error) {
+	args := []string{"template", c.ReleaseName, c.Chart, "--include-crds"}
+	if c.Namespace != "" {
+		args = append(args, "--namespace", c.Namespace)
+	}
+	args = append(args, c.chartArgs()...)
+	for _, f := range c.ValuesFiles {
+		args = append(args, "--values", f)
+	}
+	out, err := c.run(args...)
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := (&kio.ByteReader{
+		Reader: bytes.NewReader(out), OmitReaderAnnotations: true}).Read()
+	if err != nil {
+		return nil, errors.WrapPrefixf(err, "failed to parse the rendered chart")
+	}
+	return nodes, nil
+}
+
+// Values returns the values the chart is rendered with -- the default values
+// of the chart overridden by the values files.
+func (c Chart) Values() (*yaml.RNode, error) {
+	out, err := c.run(append([]string{"show", "values", c.Chart}, c.chartArgs()...)...)
+	if err != nil {
+		return nil, err
+	}
+	values, err := parseValues(out)
+	if err != nil {
+		return nil, errors.WrapPrefixf(err, "failed to parse the values of %q", c.Chart)
+	}
+	for _, f := range c.ValuesFiles {
+		b, err := ioutil.ReadFile(f)
+		if err != nil {
+			return nil, errors.Wrap(err)
+		}
+		v, err := parseValues(b)
+		if err != nil {
+			return nil, errors.WrapPrefixf(err, "failed to parse %q", f)
+		}
+		mergeValues(values.YNode(), v.YNode())
+	}
+	return values, nil
+}
+
+// ChartVersion returns the version of the chart.
+func (c Chart) ChartVersion() (string, error) {
+	out, err := c.run(append([]string{"show", "chart", c.Chart}, c.chartArgs()...)...)
+	if err != nil {
+		return "", err
+	}
+	n, err := yaml.Parse(string(out))
+	if err != nil {
+		return "", errors.WrapPrefixf(err, "failed to parse the metadata of %q", c.Chart)
+	}
+	v, err := n.Pipe(yaml.Lookup("version"))
+	if err != nil {
+		return "", err
+	}
+	return yaml.GetValue(v), nil
+}
+
+func (c Chart) chartArgs() []string {
+	var args []string
+	if c.Repo != "" {
+		args = append(args, "--repo", c.Repo)
+	}
+	if c.Version != "" {
+		args = append(args, "--version", c.Version)
+	}
+	return args
+}
+
+func (c Chart) run(args ...string) ([]byte, error) {
+	binary := c.Binary
+	if binary == "" {
+		binary = "helm"
+	}
+	cmd := exec.Command(binary, args...)
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+	cmd.Env = os.Environ()
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, errors.Errorf("%s %s failed: %v\n%s",
+			binary, strings.Join(args, " "), err, stderr.String())
+	}
+	return out, nil
+}
+
+func parseValues(b []byte) (*yaml.RNode, error) {
+	if len(bytes.TrimSpace(b)) == 0 {
+		return yaml.NewMapRNode(nil), nil
+	}
+	return yaml.Parse(string(b))
+}
+
+// mergeValues merges src into dst the same way as Helm -- maps are merged
+// recursively and all other values are replaced.
+func mergeValues(dst, src *yaml.Node) {
+	if dst.Kind != yaml.MappingNode || src.Kind != yaml.MappingNode {
+		*dst = *src
+		return
+	}
+	for i := 0; i < len(src.Content); i += 2 {
+		found := false
+		for j := 0; j < len(dst.Content); j += 2 {
+			if dst.Content[j].Value == src.Content[i].Value {
+				mergeValues(dst.Content[j+1], src.Content[i+1])
+				found = true
+				break
+			}
+		}
+		if !found {
+			dst.Content = append(dst.Content, src.Content[i], src.Content[i+1])
+		}
+	}
+}
+
+const (
+	sourceComment  = "# Source: "
+	hookAnnotation = "helm.sh/hook"
+)
+
+// helmLabels are the labels set by charts which don't apply to packages.
+var helmLabels = map[string]string{
+	"helm.sh/chart":                "",
+	"app.kubernetes.io/managed-by": "Helm",
+	"heritage":                     "Helm",
+}
+
+// Layout is a kio.Filter which organizes rendered resources into a package.
+//
+// Resources are written to a file per chart template, with subchart templates
+// written to a directory per subchart.  Chart tests and the labels recording
+// the resources were rendered by Helm are removed.
+type Layout struct{}
+
+func (Layout) Filter(nodes []*yaml.RNode) ([]*yaml.RNode, error) {
+	var out []*yaml.RNode
+	index := map[string]int{}
+	for i := range nodes {
+		meta, err := nodes[i].GetMeta()
+		if err != nil {
+			return nil, err
+		}
+		if meta.Kind == "" || isTest(meta) {
+			continue
+		}
+
+		file := sourcePath(nodes[i])
+		if file == "" {
+			file = fmt.Sprintf("%s_%s.yaml", meta.Name, strings.ToLower(meta.Kind))
+		}
+		if err := nodes[i].PipeE(yaml.SetAnnotation(kioutil.PathAnnotation, file)); err != nil {
+			return nil, err
+		}
+		if err := nodes[i].PipeE(yaml.SetAnnotation(
+			kioutil.IndexAnnotation, fmt.Sprintf("%d", index[file]))); err != nil {
+			return nil, err
+		}
+		index[file]++
+
+		for _, p := range [][]string{
+			{"metadata", "labels"},
+			{"spec", "template", "metadata", "labels"},
+		} {
+			if err := clearHelmLabels(nodes[i], p...); err != nil {
+				return nil, err
+			}
+		}
+		out = append(out, nodes[i])
+	}
+	return out, nil
+}
+
+// sourcePath returns the package path for the template the resource was
+// rendered from, and removes the comment recording the template.  e.g.
+// web/templates/service.yaml is written to service.yaml, and
+// web/charts/redis/templates/service.yaml to redis/service.yaml.
+func sourcePath(node *yaml.RNode) string {
+	if len(node.YNode().Content) == 0 {
+		return ""
+	}
+	first := node.YNode().Content[0]
+	var source string
+	var comments []string
+	for _, line := range strings.Split(first.HeadComment, "\n") {
+		if strings.HasPrefix(line, sourceComment) {
+			source = strings.TrimPrefix(line, sourceComment)
+			continue
+		}
+		comments = append(comments, line)
+	}
+	first.HeadComment = strings.Join(comments, "\n")
+
+	parts := strings.SplitN(source, "/templates/", 2)
+	if len(parts) != 2 {
+		return ""
+	}
+	var dir []string
+	for i, p := range strings.Split(parts[0], "/") {
+		// skip the chart name and the charts directories of subcharts
+		if i > 0 && p != "charts" {
+			dir = append(dir, p)
+		}
+	}
+	return path.Join(append(dir, parts[1])...)
+}
+
+// isTest returns true if the resource is a chart test.
+func isTest(meta yaml.ResourceMeta) bool {
+	for _, hook := range strings.Split(meta.Annotations[hookAnnotation], ",") {
+		if hook := strings.TrimSpace(hook); hook == "test" || strings.HasPrefix(hook, "test-") {
+			return true
+		}
+	}
+	return false
+}
+
+func clearHelmLabels(node *yaml.RNode, labelsPath ...string) error {
+	labels, err := node.Pipe(yaml.Lookup(labelsPath...))
+	if err != nil || labels == nil {
+		return err
+	}
+	for label, value := range helmLabels {
+		l := labels.Field(label)
+		if l == nil || (value != "" && yaml.GetValue(l.Value) != value) {
+			continue
+		}
+		if _, err := labels.Pipe(yaml.Clear(label)); err != nil {
+			return err
+		}
+	}
+	parent, err := node.Pipe(yaml.Lookup(labelsPath[:len(labelsPath)-1]...))
+	if err != nil {
+		return err
+	}
+	return parent.PipeE(yaml.FieldClearer{Name: labelsPath[len(labelsPath)-1], IfEmpty: true})
+}
+
+// SuggestSetters returns setters for the chart values which appear as field
+// values in the rendered resources.  Values which are only part of a field
+// value, e.g. an image tag, aren't converted.  Numeric values are only
+// converted if they appear in a single field, since small numbers are likely
+// to match unrelated fields.
+func SuggestSetters(values *yaml.RNode, nodes []*yaml.RNode) ([]clusterpkg.Setter, error) {
+	counts := map[string]int{}
+	for i := range nodes {
+		countValues(nodes[i].YNode(), counts)
+	}
+
+	var leaves []leaf
+	walkValues(values.YNode(), nil, &leaves)
+	sort.Slice(leaves, func(i, j int) bool {
+		return strings.Join(leaves[i].path, ".") < strings.Join(leaves[j].path, ".")
+	})
+
+	var setters []clusterpkg.Setter
+	seen := map[string]bool{}
+	for _, l := range leaves {
+		v := l.node.Value
+		switch tag := l.node.ShortTag(); {
+		case v == "" || seen[v] || counts[v] == 0:
+			continue
+		case tag == yaml.NodeTagBool || tag == yaml.NodeTagNull:
+			continue
+		case tag != yaml.NodeTagString && counts[v] > 1:
+			continue
+		}
+		seen[v] = true
+		setters = append(setters, clusterpkg.Setter{Name: strings.Join(l.path, "-"), Value: v})
+	}
+	return setters, nil
+}
+
+type leaf struct {
+	path []string
+	node *yaml.Node
+}
+
+// walkValues appends the scalar values under node to leaves.
+func walkValues(node *yaml.Node, p []string, leaves *[]leaf) {
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i < len(node.Content); i += 2 {
+			walkValues(node.Content[i+1],
+				append(append([]string{}, p...), node.Content[i].Value), leaves)
+		}
+	case yaml.ScalarNode:
+		if len(p) > 0 {
+			*leaves = append(*leaves, leaf{path: p, node: node})
+		}
+	}
+}
+
+// countValues counts the scalar field values under node.
+func countValues(node *yaml.Node, counts map[string]int) {
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 1; i < len(node.Content); i += 2 {
+			if node.Content[i].Kind == yaml.ScalarNode {
+				counts[node.Content[i].Value]++
+				continue
+			}
+			countValues(node.Content[i], counts)
+		}
+	case yaml.SequenceNode:
+		for _, e := range node.Content {
+			countValues(e, counts)
+		}
+	}
+}
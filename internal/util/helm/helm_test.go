@@ -0,0 +1,285 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package helm_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt/internal/testutil"
+	"github.com/GoogleContainerTools/kpt/internal/util/clusterpkg"
+	. "github.com/GoogleContainerTools/kpt/internal/util/helm"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+const rendered = `---
+# Source: web/templates/serviceaccount.yaml
+apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: web
+  labels:
+    helm.sh/chart: web-0.1.0
+    app.kubernetes.io/managed-by: Helm
+---
+# Source: web/charts/redis/templates/service.yaml
+apiVersion: v1
+kind: Service
+metadata:
+  name: web-redis
+  labels:
+    app.kubernetes.io/name: redis
+    app.kubernetes.io/managed-by: Helm
+spec:
+  type: ClusterIP
+  ports:
+  - port: 6379
+---
+# Source: web/templates/deployment.yaml
+# the web frontend
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  replicas: 3
+  template:
+    metadata:
+      labels:
+        app.kubernetes.io/name: web
+        helm.sh/chart: web-0.1.0
+    spec:
+      containers:
+      - name: web
+        image: "nginx:1.19"
+        ports:
+        - containerPort: 80
+---
+# Source: web/templates/deployment.yaml
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+spec:
+  type: ClusterIP
+  ports:
+  - port: 80
+---
+# Source: web/templates/tests/test-connection.yaml
+apiVersion: v1
+kind: Pod
+metadata:
+  name: web-test-connection
+  annotations:
+    "helm.sh/hook": test
+`
+
+const values = `replicaCount: 1
+image:
+  repository: nginx
+  tag: "1.19"
+service:
+  type: ClusterIP
+  port: 80
+serviceAccount:
+  create: true
+  name: ""
+`
+
+const chart = `apiVersion: v2
+name: web
+version: 0.1.0
+`
+
+func TestChart_Render(t *testing.T) {
+	h := testutil.NewFakeHelm(t, rendered, values, chart)
+	defer h.Clean()
+
+	nodes, err := Chart{
+		Binary:      h.Binary,
+		Chart:       "./web",
+		Version:     "0.1.0",
+		ReleaseName: "web",
+		Namespace:   "app",
+		ValuesFiles: []string{"a.yaml", "b.yaml"},
+	}.Render()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Len(t, nodes, 5)
+	assert.Equal(t, []string{
+		"template web ./web --include-crds --namespace app --version 0.1.0 --values a.yaml --values b.yaml",
+	}, h.Args(t))
+}
+
+func TestChart_Render_error(t *testing.T) {
+	_, err := Chart{Binary: "false", Chart: "./web", ReleaseName: "web"}.Render()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "false template web ./web --include-crds failed")
+	}
+}
+
+func TestChart_Values(t *testing.T) {
+	h := testutil.NewFakeHelm(t, rendered, values, chart)
+	defer h.Clean()
+
+	dir, err := ioutil.TempDir("", "kpt-test-helm-values")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir)
+	f := filepath.Join(dir, "values.yaml")
+	err = ioutil.WriteFile(f, []byte(`replicaCount: 3
+image:
+  tag: "1.20"
+ingress:
+  enabled: true
+`), 0600)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	v, err := Chart{Binary: h.Binary, Chart: "web", Repo: "https://example.com/charts",
+		ValuesFiles: []string{f}}.Values()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, `replicaCount: 3
+image:
+  repository: nginx
+  tag: "1.20"
+service:
+  type: ClusterIP
+  port: 80
+serviceAccount:
+  create: true
+  name: ""
+ingress:
+  enabled: true
+`, v.MustString())
+	assert.Equal(t, []string{"show values web --repo https://example.com/charts"}, h.Args(t))
+}
+
+func TestChart_ChartVersion(t *testing.T) {
+	h := testutil.NewFakeHelm(t, rendered, values, chart)
+	defer h.Clean()
+
+	v, err := Chart{Binary: h.Binary, Chart: "./web"}.ChartVersion()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, "0.1.0", v)
+}
+
+func TestLayout_Filter(t *testing.T) {
+	nodes, err := (&kio.ByteReader{Reader: strings.NewReader(rendered),
+		OmitReaderAnnotations: true}).Read()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	nodes, err = Layout{}.Filter(nodes)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	var b strings.Builder
+	err = kio.ByteWriter{Writer: &b, KeepReaderAnnotations: true}.Write(nodes)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, `apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: web
+  annotations:
+    config.kubernetes.io/path: 'serviceaccount.yaml'
+    config.kubernetes.io/index: '0'
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web-redis
+  labels:
+    app.kubernetes.io/name: redis
+  annotations:
+    config.kubernetes.io/path: 'redis/service.yaml'
+    config.kubernetes.io/index: '0'
+spec:
+  type: ClusterIP
+  ports:
+  - port: 6379
+---
+# the web frontend
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  annotations:
+    config.kubernetes.io/path: 'deployment.yaml'
+    config.kubernetes.io/index: '0'
+spec:
+  replicas: 3
+  template:
+    metadata:
+      labels:
+        app.kubernetes.io/name: web
+    spec:
+      containers:
+      - name: web
+        image: "nginx:1.19"
+        ports:
+        - containerPort: 80
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+  annotations:
+    config.kubernetes.io/path: 'deployment.yaml'
+    config.kubernetes.io/index: '1'
+spec:
+  type: ClusterIP
+  ports:
+  - port: 80
+`, b.String())
+}
+
+func TestSuggestSetters(t *testing.T) {
+	nodes, err := (&kio.ByteReader{Reader: strings.NewReader(rendered),
+		OmitReaderAnnotations: true}).Read()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	v, err := yaml.Parse(strings.Replace(values, "replicaCount: 1", "replicaCount: 3", 1))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	setters, err := SuggestSetters(v, nodes)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	// image values are only part of the image field, and port 80 is used by
+	// multiple fields
+	assert.Equal(t, []clusterpkg.Setter{
+		{Name: "replicaCount", Value: "3"},
+		{Name: "service-type", Value: "ClusterIP"},
+	}, setters)
+}
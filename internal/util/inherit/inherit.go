@@ -0,0 +1,288 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package inherit merges the settings subpackages inherit from the Kptfiles
+// of the packages containing them into their own.
+package inherit
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/GoogleContainerTools/kpt/pkg/kptfile"
+	"github.com/GoogleContainerTools/kpt/pkg/kptfile/kptfileutil"
+	"sigs.k8s.io/kustomize/kyaml/errors"
+	"sigs.k8s.io/kustomize/kyaml/fieldmeta"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// Parent returns the directory of the nearest package containing the
+// package at dir, or "" if there is none.
+func Parent(dir string) (string, error) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return "", errors.Wrap(err)
+	}
+	for abs != filepath.Dir(abs) {
+		abs = filepath.Dir(abs)
+		if _, err := os.Stat(filepath.Join(abs, kptfile.KptFileName)); err == nil {
+			return abs, nil
+		}
+	}
+	return "", nil
+}
+
+// Kptfile reads the Kptfile of the package at dir, with the settings it
+// inherits from the packages containing it merged into its own.
+func Kptfile(dir string) (kptfile.KptFile, error) {
+	k, err := kptfileutil.ReadFile(dir)
+	if err != nil || k.Inherit == nil {
+		return k, err
+	}
+	parent, err := Parent(dir)
+	if err != nil || parent == "" {
+		return k, err
+	}
+	p, err := Kptfile(parent)
+	if err != nil {
+		return k, err
+	}
+	if k.Inherit.Pipeline {
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return k, errors.Wrap(err)
+		}
+		if k.Pipeline, err = mergePipeline(p.Pipeline, k.Pipeline, parent, abs); err != nil {
+			return k, err
+		}
+	}
+	if k.Inherit.Setters {
+		if k.OpenAPI, err = mergeSetters(p.OpenAPI, k.OpenAPI); err != nil {
+			return k, errors.WrapPrefixf(err, "unable to inherit the setters of %q", parent)
+		}
+	}
+	if k.Inherit.Formatting {
+		k.Formatting = mergeFormatting(p.Formatting, k.Formatting)
+	}
+	return k, nil
+}
+
+// PipelineAncestors returns the directories of the packages, nearest first,
+// whose pipelines the package at dir inherits.
+func PipelineAncestors(dir string) ([]string, error) {
+	var dirs []string
+	for {
+		k, err := kptfileutil.ReadFile(dir)
+		if err != nil || k.Inherit == nil || !k.Inherit.Pipeline {
+			return dirs, err
+		}
+		if dir, err = Parent(dir); err != nil || dir == "" {
+			return dirs, err
+		}
+		dirs = append(dirs, dir)
+	}
+}
+
+// mergePipeline returns the pipeline of the package at dir, inheriting the
+// pipeline of the package at parent.  The inherited functions run first,
+// unless the package has a function with the same name, which replaces
+// them.  The paths of the inherited functions are made relative to dir.
+func mergePipeline(parent, own kptfile.Pipeline, parentDir, dir string) (kptfile.Pipeline, error) {
+	rel, err := filepath.Rel(dir, parentDir)
+	if err != nil {
+		return own, errors.Wrap(err)
+	}
+	merge := func(inherited, own []kptfile.PipelineFunction) []kptfile.PipelineFunction {
+		overrides := map[string]int{}
+		for i, f := range own {
+			overrides[f.String()] = i
+		}
+		replaced := map[int]bool{}
+		var merged []kptfile.PipelineFunction
+		for _, f := range inherited {
+			if i, found := overrides[f.String()]; found {
+				if !replaced[i] {
+					merged = append(merged, own[i])
+					replaced[i] = true
+				}
+				continue
+			}
+			merged = append(merged, rebase(f, rel))
+		}
+		for i, f := range own {
+			if !replaced[i] {
+				merged = append(merged, f)
+			}
+		}
+		return merged
+	}
+	return kptfile.Pipeline{
+		Mutators:   merge(parent.Mutators, own.Mutators),
+		Validators: merge(parent.Validators, own.Validators),
+	}, nil
+}
+
+// rebase returns the inherited function f with its paths, relative to the
+// package declaring it, made relative to the inheriting package.  rel is the
+// path of the declaring package relative to the inheriting one.
+func rebase(f kptfile.PipelineFunction, rel string) kptfile.PipelineFunction {
+	join := func(p string) string {
+		if p == "" {
+			return p
+		}
+		return filepath.ToSlash(filepath.Join(rel, filepath.FromSlash(p)))
+	}
+	if f.Name == "" {
+		// keep the name the function is known by in messages
+		f.Name = f.String()
+	}
+	if strings.ContainsRune(f.Exec, '/') && !filepath.IsAbs(f.Exec) {
+		f.Exec = join(f.Exec)
+	}
+	f.Starlark = join(f.Starlark)
+	if u, err := url.Parse(f.Wasm); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		f.Wasm = join(f.Wasm)
+	}
+	f.ConfigPath = join(f.ConfigPath)
+	return f
+}
+
+// mergeSetters returns the openAPI of a package, own, inheriting the
+// setters and substitutions of the openAPI of the package containing it.
+// The setters it doesn't define are added, and those it defines but hasn't
+// set take the inherited values if they have been set.
+func mergeSetters(parent, own interface{}) (interface{}, error) {
+	p, err := toRNode(parent)
+	if err != nil || p == nil {
+		return own, err
+	}
+	parentDefs, err := p.Pipe(yaml.Lookup("definitions"))
+	if err != nil || parentDefs == nil {
+		return own, err
+	}
+	o, err := toRNode(own)
+	if err != nil {
+		return own, err
+	}
+	if o == nil {
+		o = yaml.NewMapRNode(nil)
+	}
+	defs, err := o.Pipe(yaml.LookupCreate(yaml.MappingNode, "definitions"))
+	if err != nil {
+		return own, err
+	}
+
+	err = parentDefs.VisitFields(func(field *yaml.MapNode) error {
+		key := field.Key.YNode().Value
+		if !strings.HasPrefix(key, fieldmeta.SetterDefinitionPrefix) &&
+			!strings.HasPrefix(key, fieldmeta.SubstitutionDefinitionPrefix) {
+			return nil
+		}
+		def := defs.Field(key)
+		if def == nil {
+			return defs.PipeE(yaml.SetField(key, field.Value.Copy()))
+		}
+		if !strings.HasPrefix(key, fieldmeta.SetterDefinitionPrefix) || isSet(def.Value) ||
+			!isSet(field.Value) {
+			return nil
+		}
+		setter, err := def.Value.Pipe(yaml.Lookup("x-k8s-cli", "setter"))
+		if err != nil || setter == nil {
+			return err
+		}
+		inherited, err := field.Value.Pipe(yaml.Lookup("x-k8s-cli", "setter"))
+		if err != nil {
+			return err
+		}
+		for _, name := range []string{"value", "listValues", "isSet"} {
+			if err := setter.PipeE(yaml.Clear(name)); err != nil {
+				return err
+			}
+			if v := inherited.Field(name); v != nil {
+				if err := setter.PipeE(yaml.SetField(name, v.Value.Copy())); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return own, err
+	}
+	return fromRNode(o)
+}
+
+// isSet returns true if the setter definition def has been set.
+func isSet(def *yaml.RNode) bool {
+	v, err := def.Pipe(yaml.Lookup("x-k8s-cli", "setter", "isSet"))
+	return err == nil && v != nil && v.YNode().Value == "true"
+}
+
+// toRNode returns the openAPI of a Kptfile as an RNode, or nil if it has
+// none.
+func toRNode(openAPI interface{}) (*yaml.RNode, error) {
+	if openAPI == nil {
+		return nil, nil
+	}
+	b, err := yaml.Marshal(openAPI)
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	return yaml.Parse(string(b))
+}
+
+// fromRNode returns the openAPI of a Kptfile from an RNode.
+func fromRNode(n *yaml.RNode) (interface{}, error) {
+	s, err := n.String()
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	var openAPI interface{}
+	if err := yaml.Unmarshal([]byte(s), &openAPI); err != nil {
+		return nil, errors.Wrap(err)
+	}
+	return openAPI, nil
+}
+
+// mergeFormatting returns the formatting policy of a package, own,
+// inheriting the fields it doesn't set from the policy of the package
+// containing it.
+func mergeFormatting(parent, own *kptfile.Formatting) *kptfile.Formatting {
+	switch {
+	case parent == nil:
+		return own
+	case own == nil:
+		p := *parent
+		return &p
+	}
+	f := *own
+	if f.FieldOrder == "" {
+		f.FieldOrder = parent.FieldOrder
+	}
+	if f.Indent == 0 {
+		f.Indent = parent.Indent
+	}
+	if f.ShortLists == "" {
+		f.ShortLists = parent.ShortLists
+	}
+	if f.ShortListMaxLength == 0 {
+		f.ShortListMaxLength = parent.ShortListMaxLength
+	}
+	if f.Quoting == "" {
+		f.Quoting = parent.Quoting
+	}
+	return &f
+}
@@ -0,0 +1,201 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package inherit_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt/internal/util/inherit"
+	"github.com/GoogleContainerTools/kpt/pkg/kptfile"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+const root = `apiVersion: kpt.dev/v1alpha1
+kind: Kptfile
+metadata:
+  name: root
+openAPI:
+  definitions:
+    io.k8s.cli.setters.replicas:
+      x-k8s-cli:
+        setter:
+          name: replicas
+          value: "5"
+          isSet: true
+    io.k8s.cli.setters.image:
+      x-k8s-cli:
+        setter:
+          name: image
+          value: nginx
+formatting:
+  indent: 4
+  quoting: double
+pipeline:
+  mutators:
+  - name: label
+    starlark: fns/label.star
+  - exec: ./bin/namespace
+  validators:
+  - image: gcr.io/kpt-functions/kubeval
+`
+
+const app = `apiVersion: kpt.dev/v1alpha1
+kind: Kptfile
+metadata:
+  name: app
+openAPI:
+  definitions:
+    io.k8s.cli.setters.replicas:
+      x-k8s-cli:
+        setter:
+          name: replicas
+          value: "1"
+    io.k8s.cli.setters.tier:
+      x-k8s-cli:
+        setter:
+          name: tier
+          value: web
+          isSet: true
+formatting:
+  quoting: minimal
+pipeline:
+  mutators:
+  - name: label
+    starlark: label.star
+  - exec: kubectl
+inherit:
+  pipeline: true
+  setters: true
+  formatting: true
+`
+
+const db = `apiVersion: kpt.dev/v1alpha1
+kind: Kptfile
+metadata:
+  name: db
+inherit:
+  pipeline: true
+`
+
+func writePackages(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "kpt-inherit-test")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	for path, content := range map[string]string{
+		"Kptfile":        root,
+		"app/Kptfile":    app,
+		"app/db/Kptfile": db,
+	} {
+		path = filepath.Join(dir, filepath.FromSlash(path))
+		if !assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0700)) {
+			t.FailNow()
+		}
+		if !assert.NoError(t, ioutil.WriteFile(path, []byte(content), 0600)) {
+			t.FailNow()
+		}
+	}
+	return dir
+}
+
+func TestKptfile(t *testing.T) {
+	dir := writePackages(t)
+	defer os.RemoveAll(dir)
+
+	k, err := inherit.Kptfile(filepath.Join(dir, "app"))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, kptfile.Pipeline{
+		Mutators: []kptfile.PipelineFunction{
+			{Name: "label", Starlark: "label.star"},
+			{Name: "./bin/namespace", Exec: "../bin/namespace"},
+			{Exec: "kubectl"},
+		},
+		Validators: []kptfile.PipelineFunction{
+			{Name: "gcr.io/kpt-functions/kubeval", Image: "gcr.io/kpt-functions/kubeval"},
+		},
+	}, k.Pipeline)
+	assert.Equal(t, &kptfile.Formatting{Indent: 4, Quoting: kptfile.QuotingMinimal}, k.Formatting)
+
+	b, err := yaml.Marshal(k.OpenAPI)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, `definitions:
+    io.k8s.cli.setters.image:
+        x-k8s-cli:
+            setter:
+                name: image
+                value: nginx
+    io.k8s.cli.setters.replicas:
+        x-k8s-cli:
+            setter:
+                isSet: true
+                name: replicas
+                value: "5"
+    io.k8s.cli.setters.tier:
+        x-k8s-cli:
+            setter:
+                isSet: true
+                name: tier
+                value: web
+`, string(b))
+
+	// db inherits the pipeline app inherits, but not its setters
+	k, err = inherit.Kptfile(filepath.Join(dir, "app", "db"))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, []kptfile.PipelineFunction{
+		{Name: "label", Starlark: "../label.star"},
+		{Name: "./bin/namespace", Exec: "../../bin/namespace"},
+		{Name: "kubectl", Exec: "kubectl"},
+	}, k.Pipeline.Mutators)
+	assert.Nil(t, k.OpenAPI)
+	assert.Nil(t, k.Formatting)
+
+	// the root package has nothing to inherit
+	k, err = inherit.Kptfile(dir)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, "fns/label.star", k.Pipeline.Mutators[0].Starlark)
+}
+
+func TestPipelineAncestors(t *testing.T) {
+	dir := writePackages(t)
+	defer os.RemoveAll(dir)
+	abs, err := filepath.Abs(dir)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	dirs, err := inherit.PipelineAncestors(filepath.Join(abs, "app", "db"))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, []string{filepath.Join(abs, "app"), abs}, dirs)
+
+	dirs, err = inherit.PipelineAncestors(abs)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Empty(t, dirs)
+}
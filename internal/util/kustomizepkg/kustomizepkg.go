@@ -0,0 +1,963 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package kustomizepkg converts trees of kustomizations into packages.
+package kustomizepkg
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/GoogleContainerTools/kpt/pkg/kptfile"
+	"github.com/GoogleContainerTools/kpt/pkg/kptfile/kptfileutil"
+	"sigs.k8s.io/kustomize/kyaml/copyutil"
+	"sigs.k8s.io/kustomize/kyaml/errors"
+	"sigs.k8s.io/kustomize/kyaml/fieldmeta"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/kio/kioutil"
+	"sigs.k8s.io/kustomize/kyaml/openapi"
+	"sigs.k8s.io/kustomize/kyaml/setters2"
+	"sigs.k8s.io/kustomize/kyaml/setters2/settersutil"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+	"sigs.k8s.io/kustomize/kyaml/yaml/merge2"
+)
+
+// kustomizationFiles are the names of kustomization files
+var kustomizationFiles = []string{"kustomization.yaml", "kustomization.yml", "Kustomization"}
+
+// Finding is a kustomization construct which wasn't converted, or was only
+// approximated, and needs manual attention.
+type Finding struct {
+	// Path is the path to the kustomization, relative to the source directory
+	Path string
+
+	// Field is the kustomization field
+	Field string
+
+	// Message describes what needs attention
+	Message string
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("%s: %s: %s", f.Path, f.Field, f.Message)
+}
+
+// Converter converts the kustomizations under SourcePath into packages under
+// DestPath.
+//
+// Each kustomization is converted to a package at the same path.  The bases
+// of an overlay are copied into the overlay package as subpackages, with a
+// setter for each field the overlay patches so the patched values are kept
+// when the base package is updated.  Transformers such as namespace and
+// commonLabels are applied to the overlay package directly.
+type Converter struct {
+	// SourcePath is the directory containing the kustomizations
+	SourcePath string
+
+	// DestPath is the directory to write the packages to
+	DestPath string
+
+	// Packages are the paths of the packages written, relative to DestPath
+	Packages []string
+
+	kustomizations map[string]*kustomization
+	converted      map[string]bool
+	findings       []Finding
+
+	// setters are the setters created for patched fields, keyed by
+	// package, resource and field
+	setters map[string]string
+
+	// setterNames are the setter names used in each package
+	setterNames map[string]map[string]bool
+}
+
+// kustomization contains the fields of a kustomization which are converted
+type kustomization struct {
+	Resources             []string          `yaml:"resources,omitempty"`
+	Bases                 []string          `yaml:"bases,omitempty"`
+	Namespace             string            `yaml:"namespace,omitempty"`
+	NamePrefix            string            `yaml:"namePrefix,omitempty"`
+	NameSuffix            string            `yaml:"nameSuffix,omitempty"`
+	CommonLabels          map[string]string `yaml:"commonLabels,omitempty"`
+	CommonAnnotations     map[string]string `yaml:"commonAnnotations,omitempty"`
+	Images                []image           `yaml:"images,omitempty"`
+	Replicas              []replicas        `yaml:"replicas,omitempty"`
+	PatchesStrategicMerge []string          `yaml:"patchesStrategicMerge,omitempty"`
+	Patches               []patch           `yaml:"patches,omitempty"`
+	ConfigMapGenerator    []generator       `yaml:"configMapGenerator,omitempty"`
+	SecretGenerator       []generator       `yaml:"secretGenerator,omitempty"`
+	GeneratorOptions      *generatorOptions `yaml:"generatorOptions,omitempty"`
+
+	// path is the path to the kustomization file, relative to the source
+	// directory
+	path string
+
+	// unknown are the fields which aren't converted
+	unknown []string
+}
+
+// knownFields are the kustomization fields which are converted
+var knownFields = map[string]bool{
+	"apiVersion": true, "kind": true, "resources": true, "bases": true,
+	"namespace": true, "namePrefix": true, "nameSuffix": true,
+	"commonLabels": true, "commonAnnotations": true, "images": true,
+	"replicas": true, "patchesStrategicMerge": true, "patches": true,
+	"configMapGenerator": true, "secretGenerator": true, "generatorOptions": true,
+}
+
+type image struct {
+	Name    string `yaml:"name,omitempty"`
+	NewName string `yaml:"newName,omitempty"`
+	NewTag  string `yaml:"newTag,omitempty"`
+	Digest  string `yaml:"digest,omitempty"`
+}
+
+type replicas struct {
+	Name  string `yaml:"name,omitempty"`
+	Count int    `yaml:"count"`
+}
+
+type patch struct {
+	Path   string     `yaml:"path,omitempty"`
+	Patch  string     `yaml:"patch,omitempty"`
+	Target *yaml.Node `yaml:"target,omitempty"`
+}
+
+type generator struct {
+	Name      string            `yaml:"name,omitempty"`
+	Namespace string            `yaml:"namespace,omitempty"`
+	Behavior  string            `yaml:"behavior,omitempty"`
+	Type      string            `yaml:"type,omitempty"`
+	Literals  []string          `yaml:"literals,omitempty"`
+	Files     []string          `yaml:"files,omitempty"`
+	Envs      []string          `yaml:"envs,omitempty"`
+	Env       string            `yaml:"env,omitempty"`
+	Options   *generatorOptions `yaml:"options,omitempty"`
+}
+
+type generatorOptions struct {
+	DisableNameSuffixHash bool `yaml:"disableNameSuffixHash,omitempty"`
+}
+
+// Convert converts the kustomizations and returns the constructs which need
+// manual attention.
+func (c *Converter) Convert() ([]Finding, error) {
+	c.kustomizations = map[string]*kustomization{}
+	c.converted = map[string]bool{}
+	c.setters = map[string]string{}
+	c.setterNames = map[string]map[string]bool{}
+	fieldmeta.SetShortHandRef("$kpt-set")
+
+	if err := c.read(); err != nil {
+		return nil, err
+	}
+	if len(c.kustomizations) == 0 {
+		return nil, errors.Errorf("no kustomizations found in %q", c.SourcePath)
+	}
+	var dirs []string
+	for dir := range c.kustomizations {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	for _, dir := range dirs {
+		if err := c.convert(dir, nil); err != nil {
+			return nil, err
+		}
+	}
+	sort.Strings(c.Packages)
+	return c.findings, nil
+}
+
+// read reads the kustomizations under the source directory.
+func (c *Converter) read() error {
+	return filepath.Walk(c.SourcePath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return errors.Wrap(err)
+		}
+		if info.IsDir() {
+			if path != c.SourcePath && strings.HasPrefix(info.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !isKustomizationFile(info.Name()) {
+			return nil
+		}
+		rel, err := filepath.Rel(c.SourcePath, path)
+		if err != nil {
+			return errors.Wrap(err)
+		}
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return errors.Wrap(err)
+		}
+		k := &kustomization{path: rel}
+		if err := yaml.Unmarshal(b, k); err != nil {
+			return errors.WrapPrefixf(err, "failed to parse %q", path)
+		}
+		node, err := yaml.Parse(string(b))
+		if err != nil {
+			return errors.WrapPrefixf(err, "failed to parse %q", path)
+		}
+		fields, err := node.Fields()
+		if err != nil {
+			return errors.WrapPrefixf(err, "failed to parse %q", path)
+		}
+		for _, f := range fields {
+			if !knownFields[f] {
+				k.unknown = append(k.unknown, f)
+			}
+		}
+		c.kustomizations[filepath.Dir(rel)] = k
+		return nil
+	})
+}
+
+func isKustomizationFile(name string) bool {
+	for _, f := range kustomizationFiles {
+		if name == f {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Converter) report(k *kustomization, field, format string, args ...interface{}) {
+	c.findings = append(c.findings, Finding{
+		Path: k.path, Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// base is a base of an overlay
+type base struct {
+	// dir is the path to the base, relative to the source directory
+	dir string
+
+	// name is the name of the subpackage the base is copied to
+	name string
+}
+
+// convert converts the kustomization in dir to a package, after converting
+// its bases.  visiting contains the kustomizations being converted, to
+// detect cycles.
+func (c *Converter) convert(dir string, visiting []string) error {
+	if c.converted[dir] {
+		return nil
+	}
+	for _, v := range visiting {
+		if v == dir {
+			return errors.Errorf("kustomizations have a cycle: %s",
+				strings.Join(append(visiting, dir), " -> "))
+		}
+	}
+	visiting = append(visiting, dir)
+	k := c.kustomizations[dir]
+	out := filepath.Join(c.DestPath, dir)
+	if err := os.MkdirAll(out, 0700); err != nil {
+		return errors.Wrap(err)
+	}
+
+	// copy the resources, and convert the bases first
+	var bases []base
+	names := map[string]bool{}
+	for _, r := range append(append([]string{}, k.Resources...), k.Bases...) {
+		if isRemote(r) {
+			c.report(k, "resources", "remote resource %q is not converted -- "+
+				"fetch it with kpt pkg get", r)
+			continue
+		}
+		src := filepath.Join(c.SourcePath, dir, r)
+		rel, err := filepath.Rel(c.SourcePath, src)
+		if err != nil {
+			return errors.Wrap(err)
+		}
+		info, err := os.Stat(src)
+		if err != nil {
+			return errors.WrapPrefixf(err, "%s: resource %q", k.path, r)
+		}
+		if !info.IsDir() {
+			dest := r
+			if strings.HasPrefix(filepath.Clean(r), "..") {
+				dest = filepath.Base(r)
+			}
+			if err := copyutil.SyncFile(src, filepath.Join(out, dest)); err != nil {
+				return errors.Wrap(err)
+			}
+			continue
+		}
+		if strings.HasPrefix(rel, "..") || c.kustomizations[rel] == nil {
+			c.report(k, "resources", "directory %q is not a kustomization under %q and is not converted",
+				r, c.SourcePath)
+			continue
+		}
+		if err := c.convert(rel, visiting); err != nil {
+			return err
+		}
+		name := filepath.Base(rel)
+		for i := 2; names[name]; i++ {
+			name = fmt.Sprintf("%s-%d", filepath.Base(rel), i)
+		}
+		names[name] = true
+		bases = append(bases, base{dir: rel, name: name})
+	}
+	if err := c.generate(k, out); err != nil {
+		return err
+	}
+
+	kf := kptfile.KptFile{ResourceMeta: kptfile.TypeMeta}
+	kf.Name = filepath.Base(filepath.Clean(out))
+	if err := kptfileutil.WriteFile(out, kf); err != nil {
+		return err
+	}
+
+	patches, err := c.patches(k, dir, bases)
+	if err != nil {
+		return err
+	}
+
+	// create setters in the bases for the fields patched by the overlay,
+	// then copy the bases and set the patched values
+	var sets []setterValue
+	var direct []*yaml.RNode
+	for _, p := range patches {
+		s, remainder, err := c.createSetters(k, p, bases)
+		if err != nil {
+			return err
+		}
+		sets = append(sets, s...)
+		if remainder != nil {
+			direct = append(direct, remainder)
+		}
+	}
+	for _, b := range bases {
+		if err := copyutil.CopyDir(filepath.Join(c.DestPath, b.dir), filepath.Join(out, b.name)); err != nil {
+			return errors.Wrap(err)
+		}
+	}
+	for _, s := range sets {
+		pkg := filepath.Join(out, s.pkg)
+		_, err := settersutil.FieldSetter{
+			Name:            s.name,
+			Value:           s.value,
+			OpenAPIPath:     filepath.Join(pkg, kptfile.KptFileName),
+			OpenAPIFileName: kptfile.KptFileName,
+			ResourcesPath:   pkg,
+		}.Set()
+		if err != nil {
+			return err
+		}
+	}
+
+	rw := &kio.LocalPackageReadWriter{
+		PackagePath:        out,
+		IncludeSubpackages: true,
+		PackageFileName:    kptfile.KptFileName,
+	}
+	err = kio.Pipeline{
+		Inputs: []kio.Reader{rw},
+		Filters: []kio.Filter{
+			kio.FilterFunc(func(nodes []*yaml.RNode) ([]*yaml.RNode, error) {
+				return c.apply(k, nodes, direct)
+			}),
+		},
+		Outputs: []kio.Writer{rw},
+	}.Execute()
+	if err != nil {
+		return err
+	}
+
+	for _, f := range k.unknown {
+		c.report(k, f, "%s is not converted", f)
+	}
+	c.converted[dir] = true
+	c.Packages = append(c.Packages, dir)
+	return nil
+}
+
+// isRemote returns true if the resource is a url rather than a local path.
+func isRemote(r string) bool {
+	return strings.Contains(r, "://") || strings.HasPrefix(r, "git@") ||
+		strings.HasPrefix(r, "github.com/") || strings.Contains(r, "?ref=")
+}
+
+// generate writes the resources generated by the configMapGenerator and
+// secretGenerator of k to out.
+func (c *Converter) generate(k *kustomization, out string) error {
+	disableHash := k.GeneratorOptions != nil && k.GeneratorOptions.DisableNameSuffixHash
+	for _, g := range []struct {
+		field      string
+		kind       string
+		generators []generator
+	}{
+		{"configMapGenerator", "ConfigMap", k.ConfigMapGenerator},
+		{"secretGenerator", "Secret", k.SecretGenerator},
+	} {
+		for _, gen := range g.generators {
+			if gen.Behavior == "merge" || gen.Behavior == "replace" {
+				c.report(k, g.field, "%s %q with behavior %s is not converted -- "+
+					"update the %s in the base by hand", g.kind, gen.Name, gen.Behavior, g.kind)
+				continue
+			}
+			node, err := c.generateNode(k, g.kind, gen)
+			if err != nil {
+				return err
+			}
+			name := fmt.Sprintf("%s_%s.yaml", gen.Name, strings.ToLower(g.kind))
+			if err := ioutil.WriteFile(filepath.Join(out, name), []byte(node.MustString()), 0600); err != nil {
+				return errors.Wrap(err)
+			}
+			if !disableHash && (gen.Options == nil || !gen.Options.DisableNameSuffixHash) {
+				c.report(k, g.field, "%s %q is generated without a content hash suffix -- "+
+					"workloads aren't restarted when it changes", g.kind, gen.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// generateNode returns the resource generated by gen.
+func (c *Converter) generateNode(k *kustomization, kind string, gen generator) (*yaml.RNode, error) {
+	node := yaml.NewMapRNode(nil)
+	if err := node.PipeE(yaml.SetField("apiVersion", yaml.NewScalarRNode("v1"))); err != nil {
+		return nil, err
+	}
+	if err := node.PipeE(yaml.SetField("kind", yaml.NewScalarRNode(kind))); err != nil {
+		return nil, err
+	}
+	if err := node.PipeE(yaml.SetK8sName(gen.Name)); err != nil {
+		return nil, err
+	}
+	if gen.Namespace != "" {
+		if err := node.PipeE(yaml.SetK8sNamespace(gen.Namespace)); err != nil {
+			return nil, err
+		}
+	}
+	if kind == "Secret" && gen.Type != "" {
+		if err := node.PipeE(yaml.SetField("type", yaml.NewScalarRNode(gen.Type))); err != nil {
+			return nil, err
+		}
+	}
+
+	data := map[string]string{}
+	dir := filepath.Join(c.SourcePath, filepath.Dir(k.path))
+	for _, l := range gen.Literals {
+		parts := strings.SplitN(l, "=", 2)
+		if len(parts) != 2 {
+			return nil, errors.Errorf("%s: invalid literal %q", k.path, l)
+		}
+		data[parts[0]] = strings.Trim(parts[1], `"'`)
+	}
+	for _, f := range gen.Files {
+		key, path := filepath.Base(f), f
+		if parts := strings.SplitN(f, "=", 2); len(parts) == 2 {
+			key, path = parts[0], parts[1]
+		}
+		b, err := ioutil.ReadFile(filepath.Join(dir, path))
+		if err != nil {
+			return nil, errors.Wrap(err)
+		}
+		data[key] = string(b)
+	}
+	envs := gen.Envs
+	if gen.Env != "" {
+		envs = append(envs, gen.Env)
+	}
+	for _, e := range envs {
+		b, err := ioutil.ReadFile(filepath.Join(dir, e))
+		if err != nil {
+			return nil, errors.Wrap(err)
+		}
+		for _, line := range strings.Split(string(b), "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			parts := strings.SplitN(line, "=", 2)
+			if len(parts) != 2 {
+				return nil, errors.Errorf("%s: invalid env line %q in %q", k.path, line, e)
+			}
+			data[parts[0]] = parts[1]
+		}
+	}
+	if len(data) == 0 {
+		return node, nil
+	}
+	field := "data"
+	if kind == "Secret" {
+		field = "stringData"
+	}
+	d, err := node.Pipe(yaml.LookupCreate(yaml.MappingNode, field))
+	if err != nil {
+		return nil, err
+	}
+	var keys []string
+	for key := range data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if err := d.PipeE(yaml.SetField(key, yaml.NewStringRNode(data[key]))); err != nil {
+			return nil, err
+		}
+	}
+	return node, nil
+}
+
+// patches returns the strategic merge patches of k, including the patches
+// setting the replicas of k.
+func (c *Converter) patches(k *kustomization, dir string, bases []base) ([]*yaml.RNode, error) {
+	var patches []*yaml.RNode
+	parse := func(field, content string) error {
+		nodes, err := (&kio.ByteReader{Reader: strings.NewReader(content),
+			OmitReaderAnnotations: true}).Read()
+		if err != nil {
+			// JSON 6902 patches are lists of operations
+			c.report(k, field, "JSON patches are not converted -- apply them by hand")
+			return nil
+		}
+		for _, n := range nodes {
+			meta, err := n.GetMeta()
+			if err != nil || meta.Kind == "" || meta.Name == "" {
+				c.report(k, field, "JSON patches and patches without a kind and name "+
+					"are not converted -- apply them by hand")
+				continue
+			}
+			patches = append(patches, n)
+		}
+		return nil
+	}
+	read := func(p string) (string, error) {
+		b, err := ioutil.ReadFile(filepath.Join(c.SourcePath, dir, p))
+		return string(b), errors.Wrap(err)
+	}
+
+	for _, p := range k.PatchesStrategicMerge {
+		content := p
+		if !strings.Contains(p, "\n") {
+			var err error
+			if content, err = read(p); err != nil {
+				return nil, err
+			}
+		}
+		if err := parse("patchesStrategicMerge", content); err != nil {
+			return nil, err
+		}
+	}
+	for _, p := range k.Patches {
+		if p.Target != nil {
+			c.report(k, "patches", "patch targets are not converted -- "+
+				"apply the patch to each target by hand")
+			continue
+		}
+		content := p.Patch
+		if p.Path != "" {
+			var err error
+			if content, err = read(p.Path); err != nil {
+				return nil, err
+			}
+		}
+		if err := parse("patches", content); err != nil {
+			return nil, err
+		}
+	}
+
+	// replicas are patches of the replicas of the resources with the name
+	for _, r := range k.Replicas {
+		var found bool
+		for _, b := range bases {
+			nodes, err := c.readBase(b)
+			if err != nil {
+				return nil, err
+			}
+			for _, n := range nodes {
+				if n.GetName() != r.Name || n.Field("spec") == nil ||
+					n.Field("spec").Value.Field("replicas") == nil {
+					continue
+				}
+				meta, err := n.GetMeta()
+				if err != nil {
+					return nil, err
+				}
+				p, err := yaml.Parse(fmt.Sprintf("apiVersion: %s\nkind: %s\nmetadata:\n  name: %s\nspec:\n  replicas: %d\n",
+					meta.APIVersion, meta.Kind, r.Name, r.Count))
+				if err != nil {
+					return nil, err
+				}
+				patches = append(patches, p)
+				found = true
+			}
+		}
+		if !found {
+			c.report(k, "replicas", "no resource %q with replicas in the bases", r.Name)
+		}
+	}
+	return patches, nil
+}
+
+// setterValue is a setter value to set in a copy of a base
+type setterValue struct {
+	// pkg is the package containing the setter, relative to the overlay
+	pkg   string
+	name  string
+	value string
+}
+
+// readBase returns the resources of the converted base b, including its
+// subpackages.
+func (c *Converter) readBase(b base) ([]*yaml.RNode, error) {
+	return (&kio.LocalPackageReader{
+		PackagePath:        filepath.Join(c.DestPath, b.dir),
+		IncludeSubpackages: true,
+		PackageFileName:    kptfile.KptFileName,
+	}).Read()
+}
+
+// createSetters creates setters in the base targeted by patch p for the
+// scalar fields p changes, and returns the setter values for the overlay and
+// the remainder of p which isn't applied by the setters.
+func (c *Converter) createSetters(k *kustomization, p *yaml.RNode, bases []base) (
+	[]setterValue, *yaml.RNode, error) {
+	for _, b := range bases {
+		nodes, err := c.readBase(b)
+		if err != nil {
+			return nil, nil, err
+		}
+		target := find(nodes, p)
+		if target == nil {
+			continue
+		}
+		file, _, err := kioutil.GetFileAnnotations(target)
+		if err != nil {
+			return nil, nil, err
+		}
+		pkg := packageDir(filepath.Join(c.DestPath, b.dir), filepath.Dir(file))
+
+		remainder := yaml.NewRNode(copyNode(p.YNode()))
+		var sets []setterValue
+		for _, l := range scalarFields(p.YNode(), nil) {
+			tv, err := target.Pipe(yaml.Lookup(l.path...))
+			if err != nil || tv == nil || tv.YNode().Kind != yaml.ScalarNode {
+				// new fields are added by the remainder
+				continue
+			}
+			name, err := c.setter(pkg, target, l.path, tv.YNode().Value)
+			if err != nil {
+				return nil, nil, err
+			}
+			rel, err := filepath.Rel(filepath.Join(c.DestPath, b.dir), pkg)
+			if err != nil {
+				return nil, nil, errors.Wrap(err)
+			}
+			sets = append(sets, setterValue{
+				pkg: filepath.Join(b.name, rel), name: name, value: l.node.Value})
+			if err := remainder.PipeE(yaml.Lookup(l.path[:len(l.path)-1]...),
+				yaml.Clear(l.path[len(l.path)-1])); err != nil {
+				return nil, nil, err
+			}
+		}
+		if isEmptyPatch(remainder) {
+			remainder = nil
+		}
+		return sets, remainder, nil
+	}
+	// the target isn't in a base -- apply the patch directly
+	return nil, p, nil
+}
+
+// setter returns the setter for the field at path of target in the package
+// pkg, creating it with value if it doesn't exist.
+func (c *Converter) setter(pkg string, target *yaml.RNode, path []string, value string) (string, error) {
+	key := strings.Join([]string{pkg, target.GetKind(), target.GetName(),
+		strings.Join(path, ".")}, "|")
+	if name, found := c.setters[key]; found {
+		return name, nil
+	}
+	if c.setterNames[pkg] == nil {
+		c.setterNames[pkg] = map[string]bool{}
+	}
+	name := target.GetName() + "-" + path[len(path)-1]
+	for i := 2; c.setterNames[pkg][name]; i++ {
+		name = fmt.Sprintf("%s-%s-%d", target.GetName(), path[len(path)-1], i)
+	}
+	c.setterNames[pkg][name] = true
+	c.setters[key] = name
+
+	openAPIPath := filepath.Join(pkg, kptfile.KptFileName)
+	if err := (setters2.SetterDefinition{Name: name, Value: value}).AddToFile(openAPIPath); err != nil {
+		return "", err
+	}
+	sc, err := openapi.SchemaFromFile(openAPIPath)
+	if err != nil {
+		return "", err
+	}
+
+	// only reference the setter from the patched resource
+	rw := &kio.LocalPackageReadWriter{PackagePath: pkg, PackageFileName: kptfile.KptFileName}
+	err = kio.Pipeline{
+		Inputs: []kio.Reader{rw},
+		Filters: []kio.Filter{kio.FilterFunc(func(nodes []*yaml.RNode) ([]*yaml.RNode, error) {
+			if n := find(nodes, target); n != nil {
+				_, err := (&setters2.Add{
+					FieldName:     strings.Join(path, "."),
+					Ref:           fieldmeta.DefinitionsPrefix + fieldmeta.SetterDefinitionPrefix + name,
+					SettersSchema: sc,
+				}).Filter(n)
+				return nodes, err
+			}
+			return nodes, nil
+		})},
+		Outputs: []kio.Writer{rw},
+	}.Execute()
+	return name, err
+}
+
+// packageDir returns the directory of the package containing dir, which is
+// relative to root.
+func packageDir(root, dir string) string {
+	for d := filepath.Join(root, dir); ; d = filepath.Dir(d) {
+		if _, err := os.Stat(filepath.Join(d, kptfile.KptFileName)); err == nil || d == root {
+			return d
+		}
+	}
+}
+
+// apply applies the direct patches and the transformers of k to nodes.
+func (c *Converter) apply(k *kustomization, nodes []*yaml.RNode, patches []*yaml.RNode) (
+	[]*yaml.RNode, error) {
+	for _, p := range patches {
+		target := find(nodes, p)
+		if target == nil {
+			c.report(k, "patches", "no resource %s %q to patch", p.GetKind(), p.GetName())
+			continue
+		}
+		if _, err := merge2.Merge(p, target, yaml.MergeOptions{
+			ListIncreaseDirection: yaml.MergeOptionsListAppend}); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, n := range nodes {
+		if k.Namespace != "" && !clusterScoped[n.GetKind()] {
+			if err := n.PipeE(yaml.SetK8sNamespace(k.Namespace)); err != nil {
+				return nil, err
+			}
+		}
+		if k.NamePrefix != "" || k.NameSuffix != "" {
+			if err := n.PipeE(yaml.SetK8sName(k.NamePrefix + n.GetName() + k.NameSuffix)); err != nil {
+				return nil, err
+			}
+		}
+		for _, l := range sortedKeys(k.CommonLabels) {
+			for _, p := range labelPaths(n.GetKind()) {
+				if err := n.PipeE(yaml.LookupCreate(yaml.MappingNode, p...),
+					yaml.SetField(l, yaml.NewStringRNode(k.CommonLabels[l]))); err != nil {
+					return nil, err
+				}
+			}
+		}
+		for _, a := range sortedKeys(k.CommonAnnotations) {
+			if err := n.PipeE(yaml.SetAnnotation(a, k.CommonAnnotations[a])); err != nil {
+				return nil, err
+			}
+		}
+		if err := setImages(n.YNode(), k.Images); err != nil {
+			return nil, err
+		}
+	}
+	if k.NamePrefix != "" || k.NameSuffix != "" {
+		c.report(k, "namePrefix", "references to the renamed resources, e.g. "+
+			"configMapRef and serviceAccountName, are not updated")
+	}
+	return nodes, nil
+}
+
+// clusterScoped are the kinds which namespace isn't set on
+var clusterScoped = map[string]bool{
+	"Namespace": true, "ClusterRole": true, "ClusterRoleBinding": true,
+	"CustomResourceDefinition": true, "PersistentVolume": true,
+	"StorageClass": true, "PriorityClass": true, "APIService": true,
+	"MutatingWebhookConfiguration": true, "ValidatingWebhookConfiguration": true,
+	"PodSecurityPolicy": true,
+}
+
+// labelPaths returns the paths commonLabels are set on for kind
+func labelPaths(kind string) [][]string {
+	paths := [][]string{{"metadata", "labels"}}
+	switch kind {
+	case "Deployment", "StatefulSet", "DaemonSet", "ReplicaSet":
+		paths = append(paths, []string{"spec", "selector", "matchLabels"},
+			[]string{"spec", "template", "metadata", "labels"})
+	case "Job":
+		paths = append(paths, []string{"spec", "template", "metadata", "labels"})
+	case "CronJob":
+		paths = append(paths, []string{"spec", "jobTemplate", "spec", "template", "metadata", "labels"})
+	case "Service":
+		paths = append(paths, []string{"spec", "selector"})
+	}
+	return paths
+}
+
+// setImages applies the images transformer to the image fields under node.
+func setImages(node *yaml.Node, images []image) error {
+	if len(images) == 0 {
+		return nil
+	}
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			v := node.Content[i+1]
+			if node.Content[i].Value == "image" && v.Kind == yaml.ScalarNode {
+				v.Value = transformImage(v.Value, images)
+				continue
+			}
+			if err := setImages(v, images); err != nil {
+				return err
+			}
+		}
+	case yaml.SequenceNode:
+		for _, e := range node.Content {
+			if err := setImages(e, images); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// transformImage returns value with the first matching image applied.
+func transformImage(value string, images []image) string {
+	name, tag := splitImage(value)
+	for _, i := range images {
+		if i.Name != name {
+			continue
+		}
+		if i.NewName != "" {
+			name = i.NewName
+		}
+		switch {
+		case i.Digest != "":
+			tag = "@" + i.Digest
+		case i.NewTag != "":
+			tag = ":" + i.NewTag
+		}
+		return name + tag
+	}
+	return value
+}
+
+// splitImage splits an image into its name and its tag or digest, including
+// the separator.
+func splitImage(value string) (string, string) {
+	if i := strings.Index(value, "@"); i >= 0 {
+		return value[:i], value[i:]
+	}
+	// the tag follows the last colon after the last slash -- colons before
+	// it are registry ports
+	if i := strings.LastIndex(value, ":"); i > strings.LastIndex(value, "/") {
+		return value[:i], value[i:]
+	}
+	return value, ""
+}
+
+// find returns the resource in nodes with the kind and name of r.
+func find(nodes []*yaml.RNode, r *yaml.RNode) *yaml.RNode {
+	for _, n := range nodes {
+		if n.GetKind() == r.GetKind() && n.GetName() == r.GetName() {
+			return n
+		}
+	}
+	return nil
+}
+
+type field struct {
+	path []string
+	node *yaml.Node
+}
+
+// scalarFields returns the scalar fields of the patch which are reached
+// through mappings only, skipping the fields identifying the resource.
+func scalarFields(node *yaml.Node, path []string) []field {
+	var fields []field
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		p := append(append([]string{}, path...), node.Content[i].Value)
+		switch key := strings.Join(p, "."); {
+		case key == "apiVersion" || key == "kind" || key == "metadata.name" ||
+			key == "metadata.namespace" || strings.HasPrefix(node.Content[i].Value, "$"):
+			continue
+		}
+		switch v := node.Content[i+1]; v.Kind {
+		case yaml.ScalarNode:
+			if v.ShortTag() != yaml.NodeTagNull {
+				fields = append(fields, field{path: p, node: v})
+			}
+		case yaml.MappingNode:
+			fields = append(fields, scalarFields(v, p)...)
+		}
+	}
+	return fields
+}
+
+// isEmptyPatch returns true if p only contains the fields identifying the
+// resource, and mappings without fields.
+func isEmptyPatch(p *yaml.RNode) bool {
+	var empty func(n *yaml.Node, top bool) bool
+	empty = func(n *yaml.Node, top bool) bool {
+		if n.Kind != yaml.MappingNode {
+			return false
+		}
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			key := n.Content[i].Value
+			if top && (key == "apiVersion" || key == "kind") {
+				continue
+			}
+			if top && key == "metadata" {
+				m := yaml.NewRNode(n.Content[i+1])
+				fields, _ := m.Fields()
+				for _, f := range fields {
+					if f != "name" && f != "namespace" {
+						return false
+					}
+				}
+				continue
+			}
+			if !empty(n.Content[i+1], false) {
+				return false
+			}
+		}
+		return true
+	}
+	return empty(p.YNode(), true)
+}
+
+func copyNode(node *yaml.Node) *yaml.Node {
+	n := *node
+	n.Content = nil
+	for _, c := range node.Content {
+		n.Content = append(n.Content, copyNode(c))
+	}
+	return &n
+}
+
+func sortedKeys(m map[string]string) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,183 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kustomizepkg_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/GoogleContainerTools/kpt/internal/util/kustomizepkg"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConverter_Convert(t *testing.T) {
+	d, err := ioutil.TempDir("", "kpt-test-kustomizepkg")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer os.RemoveAll(d)
+
+	c := &Converter{SourcePath: "testdata", DestPath: d}
+	findings, err := c.Convert()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, []string{"base", filepath.Join("overlays", "prod")}, c.Packages)
+
+	var report []string
+	for _, f := range findings {
+		report = append(report, f.String())
+	}
+	assert.Equal(t, []string{
+		`base/kustomization.yaml: configMapGenerator: ConfigMap "web-config" is generated ` +
+			`without a content hash suffix -- workloads aren't restarted when it changes`,
+		`overlays/prod/kustomization.yaml: resources: remote resource ` +
+			`"https://github.com/example/monitoring//config?ref=v1" is not converted -- ` +
+			`fetch it with kpt pkg get`,
+		`overlays/prod/kustomization.yaml: namePrefix: references to the renamed resources, ` +
+			`e.g. configMapRef and serviceAccountName, are not updated`,
+		`overlays/prod/kustomization.yaml: patchesJson6902: patchesJson6902 is not converted`,
+	}, report)
+
+	// the base has setters for the fields patched by the overlay
+	assertFile(t, filepath.Join(d, "base", "service.yaml"), `apiVersion: v1
+kind: Service
+metadata:
+  name: web
+spec:
+  type: ClusterIP # {"$kpt-set":"web-type"}
+  selector:
+    app: web
+  ports:
+  - port: 80
+`)
+	assertFile(t, filepath.Join(d, "base", "web-config_configmap.yaml"), `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: web-config
+data:
+  LOG_LEVEL: info
+`)
+
+	// the overlay sets the setters in its copy of the base, and applies the
+	// transformers
+	assertFile(t, filepath.Join(d, "overlays", "prod", "base", "Kptfile"), `apiVersion: kpt.dev/v1alpha1
+kind: Kptfile
+metadata:
+  name: base
+openAPI:
+  definitions:
+    io.k8s.cli.setters.web-type:
+      x-k8s-cli:
+        setter:
+          name: web-type
+          value: LoadBalancer
+    io.k8s.cli.setters.web-replicas:
+      x-k8s-cli:
+        setter:
+          name: web-replicas
+          value: "5"
+`)
+	assertFile(t, filepath.Join(d, "overlays", "prod", "base", "deployment.yaml"), `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: prod-web
+  labels:
+    app: web
+    env: prod
+  namespace: prod
+spec:
+  replicas: 5 # {"$kpt-set":"web-replicas"}
+  selector:
+    matchLabels:
+      app: web
+      env: prod
+  template:
+    metadata:
+      labels:
+        app: web
+        env: prod
+    spec:
+      containers:
+      - name: web
+        image: nginx:1.20
+        resources:
+          limits:
+            memory: 128Mi
+`)
+	assertFile(t, filepath.Join(d, "overlays", "prod", "base", "service.yaml"), `apiVersion: v1
+kind: Service
+metadata:
+  name: prod-web
+  namespace: prod
+  labels:
+    env: prod
+spec:
+  type: LoadBalancer # {"$kpt-set":"web-type"}
+  selector:
+    app: web
+    env: prod
+  ports:
+  - port: 80
+  externalTrafficPolicy: Local
+`)
+}
+
+func TestConverter_Convert_cycle(t *testing.T) {
+	src, err := ioutil.TempDir("", "kpt-test-kustomizepkg")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer os.RemoveAll(src)
+	for _, dir := range []string{"a", "b"} {
+		other := map[string]string{"a": "b", "b": "a"}[dir]
+		if !assert.NoError(t, os.MkdirAll(filepath.Join(src, dir), 0700)) {
+			t.FailNow()
+		}
+		err := ioutil.WriteFile(filepath.Join(src, dir, "kustomization.yaml"),
+			[]byte("resources:\n- ../"+other+"\n"), 0600)
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+	}
+
+	_, err = (&Converter{SourcePath: src, DestPath: filepath.Join(src, "out")}).Convert()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "kustomizations have a cycle: a -> b -> a")
+	}
+}
+
+func TestConverter_Convert_noKustomizations(t *testing.T) {
+	src, err := ioutil.TempDir("", "kpt-test-kustomizepkg")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer os.RemoveAll(src)
+
+	_, err = (&Converter{SourcePath: src, DestPath: filepath.Join(src, "out")}).Convert()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "no kustomizations found")
+	}
+}
+
+func assertFile(t *testing.T, path, expected string) {
+	b, err := ioutil.ReadFile(path)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, expected, string(b))
+}
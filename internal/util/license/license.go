@@ -0,0 +1,223 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package license detects the licenses of packages and checks them against
+// a policy.
+package license
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/GoogleContainerTools/kpt/pkg/kptfile/kptfileutil"
+	"sigs.k8s.io/kustomize/kyaml/errors"
+)
+
+// Unknown is recorded for packages with a license file which isn't
+// recognized.
+const Unknown = "NOASSERTION"
+
+// files are the names of license files, in order of preference.
+var files = []string{"LICENSE", "LICENSE.md", "LICENSE.txt", "LICENCE", "COPYING"}
+
+// signatures identify licenses by their SPDX id.  More specific licenses
+// come first, e.g. LGPL before GPL.
+var signatures = []struct {
+	id string
+	re *regexp.Regexp
+}{
+	{"AGPL-3.0", regexp.MustCompile(`GNU AFFERO GENERAL PUBLIC LICENSE`)},
+	{"LGPL-3.0", regexp.MustCompile(`GNU LESSER GENERAL PUBLIC LICENSE\s+Version 3`)},
+	{"LGPL-2.1", regexp.MustCompile(`GNU LESSER GENERAL PUBLIC LICENSE\s+Version 2\.1`)},
+	{"GPL-3.0", regexp.MustCompile(`GNU GENERAL PUBLIC LICENSE\s+Version 3`)},
+	{"GPL-2.0", regexp.MustCompile(`GNU GENERAL PUBLIC LICENSE\s+Version 2`)},
+	{"Apache-2.0", regexp.MustCompile(`Apache License,?\s+Version 2\.0`)},
+	{"MPL-2.0", regexp.MustCompile(`Mozilla Public License,?\s+(v\.|version)\s*2\.0`)},
+	{"MIT", regexp.MustCompile(`Permission is hereby granted, free of charge`)},
+	{"BSD-3-Clause", regexp.MustCompile(`(?s)Redistribution and use in source and binary forms.*` +
+		`Neither the name`)},
+	{"BSD-2-Clause", regexp.MustCompile(`Redistribution and use in source and binary forms`)},
+	{"ISC", regexp.MustCompile(`Permission to use, copy, modify, and(/or)? distribute this software`)},
+	{"Unlicense", regexp.MustCompile(`This is free and unencumbered software released into the public domain`)},
+}
+
+// Detect returns the license of the package at dir.  The license declared
+// by the package Kptfile takes precedence over license files.  License
+// files are looked up in dir and its parents up to root, e.g. the root of
+// the repository the package was fetched from.  Returns "" if the package
+// has no license, and Unknown if the license file isn't recognized.
+func Detect(dir, root string) (string, error) {
+	if k, err := kptfileutil.ReadFile(dir); err == nil && k.PackageMeta.License != "" {
+		return k.PackageMeta.License, nil
+	}
+	root = filepath.Clean(root)
+	for dir = filepath.Clean(dir); ; dir = filepath.Dir(dir) {
+		for _, name := range files {
+			b, err := ioutil.ReadFile(filepath.Join(dir, name))
+			if err == nil {
+				return identify(string(b)), nil
+			}
+		}
+		if dir == root || dir == filepath.Dir(dir) || !strings.HasPrefix(dir, root) {
+			return "", nil
+		}
+	}
+}
+
+// identify returns the SPDX id of the license text.
+func identify(text string) string {
+	for _, s := range signatures {
+		if s.re.MatchString(text) {
+			return s.id
+		}
+	}
+	return Unknown
+}
+
+// Modes of the license policy.
+const (
+	// FailMode fails fetching packages with disallowed licenses
+	FailMode = "fail"
+	// WarnMode warns about packages with disallowed licenses
+	WarnMode = "warn"
+)
+
+// NewPolicy returns the policy allowing licenses in mode, which must be one
+// of FailMode or WarnMode.
+func NewPolicy(allowed []string, mode string, out io.Writer) (Policy, error) {
+	switch mode {
+	case FailMode, WarnMode:
+	default:
+		return Policy{}, errors.Errorf("invalid license policy %q: must be one of %s,%s",
+			mode, FailMode, WarnMode)
+	}
+	return Policy{Allowed: allowed, Warn: mode == WarnMode, Out: out}, nil
+}
+
+// Policy restricts the licenses packages may be fetched under.
+type Policy struct {
+	// Allowed are the SPDX ids of the allowed licenses, e.g. Apache-2.0.
+	// All licenses are allowed if empty.
+	Allowed []string
+
+	// Warn if set prints a warning to Out for disallowed licenses rather
+	// than failing.
+	Warn bool
+
+	// Out receives warnings.  Defaults to discarding them.
+	Out io.Writer
+}
+
+// Check returns an error if license is not allowed for the package pkg.
+// Packages without a license, or with an unrecognized one, are not allowed.
+// License expressions are allowed if any of the alternatives joined by OR
+// only uses allowed licenses, e.g. "MIT OR GPL-3.0" is allowed if MIT is.
+func (p Policy) Check(pkg, license string) error {
+	if len(p.Allowed) == 0 || p.allows(license) {
+		return nil
+	}
+	display := license
+	if display == "" {
+		display = "none"
+	}
+	if p.Warn {
+		if p.Out != nil {
+			fmt.Fprintf(p.Out, "warning: package %q has license %q which is not in the "+
+				"allowed licenses %s\n", pkg, display, strings.Join(p.Allowed, ","))
+		}
+		return nil
+	}
+	return errors.Errorf("package %q has license %q which is not in the allowed "+
+		"licenses %s", pkg, display, strings.Join(p.Allowed, ","))
+}
+
+func (p Policy) allows(license string) bool {
+	e := &expression{
+		tokens: strings.Fields(strings.NewReplacer("(", " ( ", ")", " ) ").Replace(license)),
+		allows: p.allowsID,
+	}
+	allowed := e.or()
+	return allowed && len(e.tokens) == 0
+}
+
+func (p Policy) allowsID(id string) bool {
+	if id == "" || id == Unknown {
+		return false
+	}
+	for _, a := range p.Allowed {
+		if strings.EqualFold(strings.TrimSpace(a), id) {
+			return true
+		}
+	}
+	return false
+}
+
+// expression evaluates SPDX license expressions, e.g.
+// "(MIT OR GPL-3.0) AND Apache-2.0".  AND binds tighter than OR.
+type expression struct {
+	tokens []string
+	allows func(id string) bool
+}
+
+func (e *expression) next() string {
+	if len(e.tokens) == 0 {
+		return ""
+	}
+	t := e.tokens[0]
+	e.tokens = e.tokens[1:]
+	return t
+}
+
+func (e *expression) peek(word string) bool {
+	return len(e.tokens) > 0 && strings.EqualFold(e.tokens[0], word)
+}
+
+func (e *expression) or() bool {
+	allowed := e.and()
+	for e.peek("OR") {
+		e.next()
+		// evaluate both sides to consume the tokens
+		right := e.and()
+		allowed = allowed || right
+	}
+	return allowed
+}
+
+func (e *expression) and() bool {
+	allowed := e.license()
+	for e.peek("AND") {
+		e.next()
+		right := e.license()
+		allowed = allowed && right
+	}
+	return allowed
+}
+
+func (e *expression) license() bool {
+	t := e.next()
+	if t == "(" {
+		allowed := e.or()
+		return e.next() == ")" && allowed
+	}
+	if e.peek("WITH") {
+		// exceptions only grant additional permissions
+		e.next()
+		e.next()
+	}
+	return e.allows(t)
+}
@@ -0,0 +1,152 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package license_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/GoogleContainerTools/kpt/internal/util/license"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetect(t *testing.T) {
+	var tests = []struct {
+		name     string
+		files    map[string]string
+		expected string
+	}{
+		{
+			name: "kptfile",
+			files: map[string]string{
+				"pkg/Kptfile": `apiVersion: kpt.dev/v1alpha1
+kind: Kptfile
+metadata:
+  name: pkg
+packageMetadata:
+  license: MIT
+`,
+				"pkg/LICENSE": "Apache License\nVersion 2.0, January 2004\n",
+			},
+			expected: "MIT",
+		},
+		{
+			name:     "package license",
+			files:    map[string]string{"pkg/LICENSE": "Apache License\nVersion 2.0, January 2004\n"},
+			expected: "Apache-2.0",
+		},
+		{
+			name: "repo license",
+			files: map[string]string{
+				"LICENSE.md":   "GNU GENERAL PUBLIC LICENSE\n  Version 3, 29 June 2007\n",
+				"pkg/foo.yaml": "kind: Foo\n",
+			},
+			expected: "GPL-3.0",
+		},
+		{
+			name:     "lgpl",
+			files:    map[string]string{"COPYING": "GNU LESSER GENERAL PUBLIC LICENSE\n  Version 2.1, February 1999\n"},
+			expected: "LGPL-2.1",
+		},
+		{
+			name:     "unknown",
+			files:    map[string]string{"pkg/LICENSE": "All rights reserved.\n"},
+			expected: Unknown,
+		},
+		{
+			name:     "none",
+			files:    map[string]string{"pkg/foo.yaml": "kind: Foo\n"},
+			expected: "",
+		},
+	}
+	for i := range tests {
+		test := tests[i]
+		t.Run(test.name, func(t *testing.T) {
+			d, err := ioutil.TempDir("", "kptlicense")
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			defer os.RemoveAll(d)
+			if !assert.NoError(t, os.MkdirAll(filepath.Join(d, "pkg"), 0700)) {
+				t.FailNow()
+			}
+			for path, content := range test.files {
+				err := ioutil.WriteFile(filepath.Join(d, path), []byte(content), 0600)
+				if !assert.NoError(t, err) {
+					t.FailNow()
+				}
+			}
+
+			actual, err := Detect(filepath.Join(d, "pkg"), d)
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+}
+
+func TestPolicy_Check(t *testing.T) {
+	var tests = []struct {
+		name    string
+		allowed []string
+		license string
+		allow   bool
+	}{
+		{name: "no policy", license: "GPL-3.0", allow: true},
+		{name: "allowed", allowed: []string{"Apache-2.0", "MIT"}, license: "MIT", allow: true},
+		{name: "case insensitive", allowed: []string{"apache-2.0"}, license: "Apache-2.0", allow: true},
+		{name: "disallowed", allowed: []string{"Apache-2.0"}, license: "GPL-3.0"},
+		{name: "none", allowed: []string{"Apache-2.0"}, license: ""},
+		{name: "unknown", allowed: []string{"Apache-2.0"}, license: Unknown},
+		{name: "or", allowed: []string{"MIT"}, license: "GPL-3.0 OR MIT", allow: true},
+		{name: "and", allowed: []string{"MIT"}, license: "MIT AND GPL-3.0"},
+		{name: "precedence", allowed: []string{"MIT"}, license: "(MIT OR GPL-3.0) AND Apache-2.0"},
+		{name: "parentheses", allowed: []string{"MIT", "Apache-2.0"},
+			license: "(MIT OR GPL-3.0) AND Apache-2.0", allow: true},
+		{name: "exception", allowed: []string{"GPL-2.0"}, license: "GPL-2.0 WITH Classpath-exception-2.0",
+			allow: true},
+	}
+	for i := range tests {
+		test := tests[i]
+		t.Run(test.name, func(t *testing.T) {
+			err := Policy{Allowed: test.allowed}.Check("pkg", test.license)
+			if test.allow {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestPolicy_Check_warn(t *testing.T) {
+	b := &bytes.Buffer{}
+	p, err := NewPolicy([]string{"Apache-2.0"}, WarnMode, b)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	if !assert.NoError(t, p.Check("example.com/pkg", "GPL-3.0")) {
+		t.FailNow()
+	}
+	assert.Equal(t, "warning: package \"example.com/pkg\" has license \"GPL-3.0\" which is "+
+		"not in the allowed licenses Apache-2.0\n", b.String())
+
+	_, err = NewPolicy(nil, "ignore", b)
+	assert.EqualError(t, err, `invalid license policy "ignore": must be one of fail,warn`)
+}
@@ -25,6 +25,7 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/GoogleContainerTools/kpt/internal/util/tmputil"
 	"github.com/GoogleContainerTools/kpt/pkg/kptfile/kptfileutil"
 	"github.com/cpuguy83/go-md2man/v2/md2man"
 	"sigs.k8s.io/kustomize/kyaml/errors"
@@ -87,7 +88,7 @@ func (m Command) Run() error {
 	}
 
 	// write the formatted manual to a tmp file so it can be displayed
-	f, err := ioutil.TempFile("", "kpt-man")
+	f, err := tmputil.TempFile("kpt-man")
 	if err != nil {
 		return err
 	}
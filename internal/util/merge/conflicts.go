@@ -0,0 +1,465 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package merge
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"sigs.k8s.io/kustomize/kyaml/errors"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/openapi"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// Conflict is a resource or field which was changed both upstream and
+// locally, to different values.
+type Conflict struct {
+	// File is the path to the local file containing the resource, relative
+	// to the package
+	File string
+
+	// Resource identifies the resource, e.g. Deployment/nginx
+	Resource string
+
+	// Field is the path to the field, or empty if the resource itself
+	// conflicts.  Elements of associative lists are identified by their key,
+	// e.g. [name=nginx].
+	Field []string
+
+	// Original, Updated and Local are the values of the field, or "" if the
+	// field doesn't exist.
+	Original string
+	Updated  string
+	Local    string
+
+	// key identifies the resource in the packages
+	key string
+}
+
+func (c Conflict) String() string {
+	name := c.Resource
+	if len(c.Field) > 0 {
+		name += " " + c.FieldPath()
+	}
+	return fmt.Sprintf("%s: local %s, upstream %s", name, display(c.Local), display(c.Updated))
+}
+
+// FieldPath returns the path to the field, e.g.
+// spec.containers[name=nginx].image
+func (c Conflict) FieldPath() string {
+	var b strings.Builder
+	for i, f := range c.Field {
+		if i > 0 && !strings.HasPrefix(f, "[") {
+			b.WriteString(".")
+		}
+		b.WriteString(f)
+	}
+	return b.String()
+}
+
+// Kind returns the kind of the conflicting resource.
+func (c Conflict) Kind() string {
+	return strings.SplitN(c.Resource, "/", 2)[0]
+}
+
+// MatchField returns true if the conflicting field, or one of the fields
+// containing it, matches pattern.  Patterns are field paths in the form
+// returned by FieldPath, in which * matches any field or list element, or
+// part of one, e.g. [name=*], and ** matches any number of them.
+// Resource conflicts match no pattern.
+func (c Conflict) MatchField(pattern string) bool {
+	if len(c.Field) == 0 {
+		return false
+	}
+	return matchPath(splitFieldPath(pattern), c.Field)
+}
+
+// splitFieldPath splits a field path into its fields and list elements.
+func splitFieldPath(p string) []string {
+	var fields []string
+	var b strings.Builder
+	inElement := false
+	flush := func() {
+		if b.Len() > 0 {
+			fields = append(fields, b.String())
+			b.Reset()
+		}
+	}
+	for _, r := range p {
+		switch {
+		case r == '[' && !inElement:
+			flush()
+			inElement = true
+		case r == ']' && inElement:
+			b.WriteRune(r)
+			flush()
+			inElement = false
+			continue
+		case r == '.' && !inElement:
+			flush()
+			continue
+		}
+		b.WriteRune(r)
+	}
+	flush()
+	return fields
+}
+
+// matchPath returns true if the pattern matches a prefix of path.
+func matchPath(pattern, path []string) bool {
+	if len(pattern) == 0 {
+		return true
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(path); i++ {
+			if matchPath(pattern[1:], path[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(path) == 0 || !matchField(pattern[0], path[0]) {
+		return false
+	}
+	return matchPath(pattern[1:], path[1:])
+}
+
+// matchField returns true if the field, or list element, matches pattern.
+func matchField(pattern, field string) bool {
+	if pattern == "*" || pattern == field {
+		return true
+	}
+	if !strings.Contains(pattern, "*") {
+		return false
+	}
+	re := "^" + strings.Replace(regexp.QuoteMeta(pattern), `\*`, ".*", -1) + "$"
+	matched, _ := regexp.MatchString(re, field)
+	return matched
+}
+
+func display(value string) string {
+	if value == "" {
+		return "(deleted)"
+	}
+	return value
+}
+
+// Conflicts returns the resources and fields changed between the original
+// and updated packages which were also changed in the local package at
+// localPath, to a different value.  Conflicts are sorted by file.
+func Conflicts(originalPath, updatedPath, localPath string) ([]Conflict, error) {
+	original, err := readPackage(originalPath)
+	if err != nil {
+		return nil, err
+	}
+	updated, err := readPackage(updatedPath)
+	if err != nil {
+		return nil, err
+	}
+	local, err := readPackage(localPath)
+	if err != nil {
+		return nil, err
+	}
+
+	schemas := newSchemas(original, updated, local)
+	var conflicts []Conflict
+	keys := append(append([]string{}, original.keys...), updated.keys...)
+	seen := map[string]bool{}
+	for _, key := range keys {
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		o, u, l := node(original, key), node(updated, key), node(local, key)
+		if o == nil && l == nil {
+			// added upstream
+			continue
+		}
+		c := Conflict{File: local.paths[key], key: key}
+		if l == nil {
+			c.File = updated.paths[key]
+		}
+		for _, n := range []*yaml.RNode{local.nodes[key], updated.nodes[key]} {
+			if n == nil {
+				continue
+			}
+			if meta, err := n.GetMeta(); err == nil {
+				c.Resource = resourceName(meta)
+				break
+			}
+		}
+		if o == nil || u == nil || l == nil {
+			// added or deleted on either side
+			if !equal(o, u) && !equal(o, l) && !equal(u, l) {
+				c.Original, c.Updated, c.Local = value(o), value(u), value(l)
+				conflicts = append(conflicts, c)
+			}
+			continue
+		}
+		schema := schemas.forResource(local.nodes[key])
+		conflicts = append(conflicts, fieldConflicts(c, schema, nil, o, u, l)...)
+	}
+	sort.SliceStable(conflicts, func(i, j int) bool {
+		return conflicts[i].File < conflicts[j].File
+	})
+	return conflicts, nil
+}
+
+func node(p pkg, key string) *yaml.Node {
+	if n := p.nodes[key]; n != nil {
+		return n.YNode()
+	}
+	return nil
+}
+
+func resourceName(meta yaml.ResourceMeta) string {
+	if meta.Namespace != "" {
+		return fmt.Sprintf("%s/%s/%s", meta.Kind, meta.Namespace, meta.Name)
+	}
+	return fmt.Sprintf("%s/%s", meta.Kind, meta.Name)
+}
+
+// fieldConflicts returns the conflicts between the fields of the nodes at
+// path, whose schema is schema.
+func fieldConflicts(c Conflict, schema *openapi.ResourceSchema, path []string,
+	original, updated, local *yaml.Node) []Conflict {
+	if equal(original, updated) || equal(original, local) || equal(updated, local) {
+		return nil
+	}
+	if original != nil && updated != nil && local != nil &&
+		original.Kind == updated.Kind && updated.Kind == local.Kind {
+		switch local.Kind {
+		case yaml.MappingNode:
+			var conflicts []Conflict
+			for _, name := range fieldNames(original, updated, local) {
+				_, o := field(original, name)
+				_, u := field(updated, name)
+				_, l := field(local, name)
+				conflicts = append(conflicts, fieldConflicts(c, fieldSchema(schema, name),
+					appendPath(path, name), o, u, l)...)
+			}
+			return conflicts
+		case yaml.SequenceNode:
+			key := associativeKey(schema, original, updated, local)
+			if key == "" {
+				break
+			}
+			var conflicts []Conflict
+			for _, v := range elementKeys(key, original, updated, local) {
+				conflicts = append(conflicts, fieldConflicts(c, elementSchema(schema),
+					appendPath(path, fmt.Sprintf("[%s=%s]", key, v)),
+					element(original, key, v), element(updated, key, v), element(local, key, v))...)
+			}
+			return conflicts
+		}
+	}
+	c.Field = path
+	c.Original, c.Updated, c.Local = value(original), value(updated), value(local)
+	return []Conflict{c}
+}
+
+func appendPath(path []string, name string) []string {
+	return append(append([]string{}, path...), name)
+}
+
+// fieldNames returns the names of the fields of the mapping nodes, in the
+// order they first appear.
+func fieldNames(nodes ...*yaml.Node) []string {
+	var names []string
+	seen := map[string]bool{}
+	for _, n := range nodes {
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			if name := n.Content[i].Value; !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+// elementKeys returns the values of the key field of the elements of the
+// lists, in the order they first appear.
+func elementKeys(key string, lists ...*yaml.Node) []string {
+	var values []string
+	seen := map[string]bool{}
+	for _, l := range lists {
+		for _, e := range l.Content {
+			if v := elementKey(e, key); !seen[v] {
+				seen[v] = true
+				values = append(values, v)
+			}
+		}
+	}
+	return values
+}
+
+// value returns node formatted on a single line, or "" if node is nil.
+func value(node *yaml.Node) string {
+	if node == nil {
+		return ""
+	}
+	if node.Kind == yaml.ScalarNode {
+		if node.Value == "" {
+			return `""`
+		}
+		return node.Value
+	}
+	n := copyNode(node)
+	n.HeadComment, n.LineComment, n.FootComment = "", "", ""
+	n.Style = yaml.FlowStyle
+	s, err := yaml.NewRNode(n).String()
+	if err != nil {
+		return "(invalid)"
+	}
+	return strings.TrimSpace(s)
+}
+
+// KeepLocal resolves the conflicts in the merged file at path by restoring
+// the local resources and fields.  local is the content of the file before
+// it was merged, or nil if it didn't exist.
+func KeepLocal(path string, local []byte, conflicts []Conflict) error {
+	localNodes, err := readNodes(local)
+	if err != nil {
+		return err
+	}
+	merged, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err)
+	}
+	mergedNodes, err := readNodes(merged)
+	if err != nil {
+		return err
+	}
+
+	for _, c := range conflicts {
+		l := findResource(c, localNodes)
+		i := indexResource(c, mergedNodes)
+		switch {
+		case len(c.Field) > 0:
+			if l == nil || i < 0 {
+				continue
+			}
+			if err := setPath(mergedNodes[i].YNode(), c.Field, lookupPath(l.YNode(), c.Field)); err != nil {
+				return err
+			}
+		case l == nil && i >= 0:
+			mergedNodes = append(mergedNodes[:i], mergedNodes[i+1:]...)
+		case l != nil && i >= 0:
+			mergedNodes[i] = l
+		case l != nil:
+			mergedNodes = append(mergedNodes, l)
+		}
+	}
+	if len(mergedNodes) == 0 && len(merged) == 0 {
+		return nil
+	}
+	return writeFile(path, mergedNodes)
+}
+
+func readNodes(b []byte) ([]*yaml.RNode, error) {
+	if len(b) == 0 {
+		return nil, nil
+	}
+	return (&kio.ByteReader{Reader: bytes.NewReader(b), OmitReaderAnnotations: true}).Read()
+}
+
+// findResource returns the resource in nodes the conflict is for, or nil.
+func findResource(c Conflict, nodes []*yaml.RNode) *yaml.RNode {
+	if i := indexResource(c, nodes); i >= 0 {
+		return nodes[i]
+	}
+	return nil
+}
+
+// indexResource returns the index of the resource in nodes the conflict is
+// for, or -1.
+func indexResource(c Conflict, nodes []*yaml.RNode) int {
+	for i := range nodes {
+		// resources with the same identity aren't distinguished
+		key, err := resourceKey(c.File, nodes[i])
+		if err == nil && key != "" && key == strings.SplitN(c.key, "#", 2)[0] {
+			return i
+		}
+	}
+	return -1
+}
+
+// lookupPath returns the node at path under node, or nil.
+func lookupPath(node *yaml.Node, path []string) *yaml.Node {
+	for _, p := range path {
+		if node == nil {
+			return nil
+		}
+		if key, value, ok := elementPath(p); ok {
+			node = element(node, key, value)
+			continue
+		}
+		_, node = field(node, p)
+	}
+	return node
+}
+
+// setPath sets the node at path under node to value, or removes it if value
+// is nil.
+func setPath(node *yaml.Node, path []string, value *yaml.Node) error {
+	parent := lookupPath(node, path[:len(path)-1])
+	if parent == nil {
+		return errors.Errorf("unable to find %s", strings.Join(path[:len(path)-1], "."))
+	}
+	last := path[len(path)-1]
+	if key, v, ok := elementPath(last); ok {
+		i := elementIndex(parent, key, v)
+		switch {
+		case value == nil && i >= 0:
+			parent.Content = append(parent.Content[:i], parent.Content[i+1:]...)
+		case value != nil && i >= 0:
+			parent.Content[i] = copyNode(value)
+		case value != nil:
+			parent.Content = append(parent.Content, copyNode(value))
+		}
+		return nil
+	}
+	i := fieldIndex(parent, last)
+	switch {
+	case value == nil && i >= 0:
+		parent.Content = append(parent.Content[:i], parent.Content[i+2:]...)
+	case value != nil && i >= 0:
+		parent.Content[i+1] = copyNode(value)
+	case value != nil:
+		parent.Content = append(parent.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Value: last}, copyNode(value))
+	}
+	return nil
+}
+
+// elementPath parses a path element identifying a list element, e.g.
+// [name=nginx].
+func elementPath(p string) (string, string, bool) {
+	if !strings.HasPrefix(p, "[") || !strings.HasSuffix(p, "]") {
+		return "", "", false
+	}
+	parts := strings.SplitN(p[1:len(p)-1], "=", 2)
+	if len(parts) != 2 {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
@@ -0,0 +1,545 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package merge performs structural three-way merges of packages which
+// preserve the comments, field order and formatting of the local resources.
+package merge
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"sigs.k8s.io/kustomize/kyaml/errors"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/openapi"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// Merge3 merges the changes between the original and updated packages into
+// the local package at DestPath.
+//
+// Unlike filters.Merge3, the changes are applied to the local resources in
+// place rather than building new resources, so local comments, field order
+// and resources which only exist locally are kept.  Only the files containing
+// resources which changed are rewritten.
+//
+// Conflicts are resolved the same way as filters.Merge3 -- fields changed both
+// upstream and locally take the upstream value, and resources and fields
+// deleted upstream are deleted locally.
+type Merge3 struct {
+	// OriginalPath is the path to the package the local package was fetched from
+	OriginalPath string
+
+	// UpdatedPath is the path to the package to update to
+	UpdatedPath string
+
+	// DestPath is the path to the local package, which is updated in place
+	DestPath string
+}
+
+// pkg contains the resources of a package
+type pkg struct {
+	// keys are the resource keys in the order they were read
+	keys []string
+
+	// nodes are the resources keyed by their identity
+	nodes map[string]*yaml.RNode
+
+	// paths are the files containing each resource, keyed by identity
+	paths map[string]string
+
+	// files are the documents in each file, keyed by path relative to the
+	// package
+	files map[string][]*yaml.RNode
+}
+
+// Merge merges the packages.
+func (m Merge3) Merge() error {
+	original, err := readPackage(m.OriginalPath)
+	if err != nil {
+		return err
+	}
+	updated, err := readPackage(m.UpdatedPath)
+	if err != nil {
+		return err
+	}
+	local, err := readPackage(m.DestPath)
+	if err != nil {
+		return err
+	}
+
+	schemas := newSchemas(original, updated, local)
+	changed := map[string]bool{}
+	for _, key := range updated.keys {
+		u, o, l := updated.nodes[key], original.nodes[key], local.nodes[key]
+		switch {
+		case l != nil:
+			// merge the upstream changes into the local resource
+			var origin *yaml.Node
+			if o != nil {
+				origin = o.YNode()
+			}
+			if mergeNode(schemas.forResource(u), origin, u.YNode(), l.YNode()) {
+				changed[local.paths[key]] = true
+			}
+		case o == nil:
+			// added upstream -- write it to the same file as upstream
+			p := updated.paths[key]
+			local.files[p] = append(local.files[p], yaml.NewRNode(copyNode(u.YNode())))
+			changed[p] = true
+		default:
+			// deleted locally -- keep it deleted
+		}
+	}
+
+	// delete the resources which were deleted upstream
+	for _, key := range original.keys {
+		l := local.nodes[key]
+		if updated.nodes[key] != nil || l == nil {
+			continue
+		}
+		p := local.paths[key]
+		for i := range local.files[p] {
+			if local.files[p][i] == l {
+				local.files[p] = append(local.files[p][:i], local.files[p][i+1:]...)
+				break
+			}
+		}
+		changed[p] = true
+	}
+
+	for p := range changed {
+		if err := writeFile(filepath.Join(m.DestPath, p), local.files[p]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readPackage reads the resources in the package at root, including its
+// subpackages.
+func readPackage(root string) (pkg, error) {
+	p := pkg{
+		nodes: map[string]*yaml.RNode{},
+		paths: map[string]string{},
+		files: map[string][]*yaml.RNode{},
+	}
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return errors.Wrap(err)
+		}
+		if info.IsDir() {
+			if path != root && strings.HasPrefix(info.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if match, err := isResourceFile(info.Name()); err != nil || !match {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return errors.Wrap(err)
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return errors.Wrap(err)
+		}
+		defer f.Close()
+		nodes, err := (&kio.ByteReader{Reader: f, OmitReaderAnnotations: true}).Read()
+		if err != nil {
+			return errors.WrapPrefixf(err, "failed to read %q", path)
+		}
+		p.files[rel] = nodes
+
+		for i := range nodes {
+			key, err := resourceKey(rel, nodes[i])
+			if err != nil || key == "" {
+				// not a resource -- kept in the file, but not merged
+				continue
+			}
+			// disambiguate resources with the same identity by their order
+			id := key
+			for n := 1; p.nodes[key] != nil; n++ {
+				key = fmt.Sprintf("%s#%d", id, n)
+			}
+			p.keys = append(p.keys, key)
+			p.nodes[key] = nodes[i]
+			p.paths[key] = rel
+		}
+		return nil
+	})
+	return p, err
+}
+
+func isResourceFile(name string) (bool, error) {
+	for _, pattern := range kio.DefaultMatch {
+		if match, err := filepath.Match(pattern, name); err != nil || match {
+			return match, errors.Wrap(err)
+		}
+	}
+	return false, nil
+}
+
+// resourceKey identifies a resource by its directory, group, kind, namespace
+// and name.  The version is omitted so resources whose version is changed
+// upstream are merged rather than replaced.  Returns "" for documents which
+// aren't resources.
+func resourceKey(path string, node *yaml.RNode) (string, error) {
+	meta, err := node.GetMeta()
+	if err != nil {
+		return "", err
+	}
+	if meta.Kind == "" {
+		return "", nil
+	}
+	group := ""
+	if i := strings.LastIndex(meta.APIVersion, "/"); i >= 0 {
+		group = meta.APIVersion[:i]
+	}
+	return strings.Join([]string{filepath.Dir(path), group, meta.Kind,
+		meta.Namespace, meta.Name}, "|"), nil
+}
+
+// writeFile writes nodes to path, or removes it if there are no nodes.
+func writeFile(path string, nodes []*yaml.RNode) error {
+	if len(nodes) == 0 {
+		return errors.Wrap(os.Remove(path))
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return errors.Wrap(err)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	defer f.Close()
+	return kio.ByteWriter{Writer: f}.Write(nodes)
+}
+
+// mergeNode merges the changes from original to updated into local, in
+// place.  original is nil if the node was added upstream.  schema is the
+// OpenAPI schema of the node, or nil if it isn't known.  Returns true if
+// local was changed.
+func mergeNode(schema *openapi.ResourceSchema, original, updated, local *yaml.Node) bool {
+	if original != nil && identical(original, updated) {
+		// unchanged upstream -- keep local
+		return false
+	}
+	changed := mergeComments(original, updated, local)
+
+	if updated.Kind != local.Kind {
+		replaceContent(updated, local)
+		return true
+	}
+	switch local.Kind {
+	case yaml.MappingNode:
+		return mergeMap(schema, original, updated, local) || changed
+	case yaml.SequenceNode:
+		if key := associativeKey(schema, original, updated, local); key != "" {
+			return mergeList(elementSchema(schema), key, original, updated, local) || changed
+		}
+		// non-associative lists are replaced if they changed upstream
+		if original == nil || !equal(original, updated) {
+			replaceContent(updated, local)
+			return true
+		}
+		return changed
+	default:
+		if original != nil && equal(original, updated) {
+			return changed
+		}
+		if local.Value == updated.Value && local.Tag == updated.Tag {
+			return changed
+		}
+		local.Value, local.Tag, local.Style = updated.Value, updated.Tag, updated.Style
+		return true
+	}
+}
+
+// mergeMap merges the fields of the mapping nodes.
+func mergeMap(schema *openapi.ResourceSchema, original, updated, local *yaml.Node) bool {
+	var changed bool
+	// the local index of the last field of updated found in local, so added
+	// fields are inserted in the upstream order
+	last := -1
+	for i := 0; i < len(updated.Content); i += 2 {
+		key, uv := updated.Content[i], updated.Content[i+1]
+		ok, ov := field(original, key.Value)
+		lk, lv := field(local, key.Value)
+		if lv != nil {
+			if mergeComments(ok, key, lk) {
+				changed = true
+			}
+			if mergeNode(fieldSchema(schema, key.Value), ov, uv, lv) {
+				changed = true
+			}
+			last = fieldIndex(local, key.Value)
+			continue
+		}
+		if ov != nil && equal(ov, uv) {
+			// deleted locally and unchanged upstream -- keep it deleted
+			continue
+		}
+		// added upstream, or changed upstream after being deleted locally
+		pos := last + 2
+		if last < 0 {
+			pos = 0
+		}
+		local.Content = insert(local.Content, pos, copyNode(key), copyNode(uv))
+		last = pos
+		changed = true
+	}
+
+	// delete the fields which were deleted upstream
+	if original != nil {
+		for i := 0; i < len(original.Content); i += 2 {
+			key := original.Content[i].Value
+			if _, uv := field(updated, key); uv != nil {
+				continue
+			}
+			if j := fieldIndex(local, key); j >= 0 {
+				local.Content = append(local.Content[:j], local.Content[j+2:]...)
+				changed = true
+			}
+		}
+	}
+	return changed
+}
+
+// mergeList merges the elements of the associative lists, matching elements
+// by the value of their key fields.  schema is the schema of the elements.
+func mergeList(schema *openapi.ResourceSchema, key string, original, updated, local *yaml.Node) bool {
+	var changed bool
+	last := -1
+	for _, ue := range updated.Content {
+		value := elementKey(ue, key)
+		oe := element(original, key, value)
+		if i := elementIndex(local, key, value); i >= 0 {
+			if mergeNode(schema, oe, ue, local.Content[i]) {
+				changed = true
+			}
+			last = i
+			continue
+		}
+		if oe != nil && equal(oe, ue) {
+			// deleted locally and unchanged upstream -- keep it deleted
+			continue
+		}
+		last++
+		local.Content = insert(local.Content, last, copyNode(ue))
+		changed = true
+	}
+
+	// delete the elements which were deleted upstream
+	if original != nil {
+		for _, oe := range original.Content {
+			value := elementKey(oe, key)
+			if element(updated, key, value) != nil {
+				continue
+			}
+			if i := elementIndex(local, key, value); i >= 0 {
+				local.Content = append(local.Content[:i], local.Content[i+1:]...)
+				changed = true
+			}
+		}
+	}
+	return changed
+}
+
+// associativeKey returns the key used to match the elements of the lists,
+// or "" if they aren't associative.  The key is the merge key from the
+// OpenAPI schema of the lists if they have one, e.g. containerPort for
+// ports, otherwise one of yaml.AssociativeSequenceKeys.  Lists are only
+// associative if every element of every list is a mapping containing the key.
+func associativeKey(schema *openapi.ResourceSchema, lists ...*yaml.Node) string {
+	keys := yaml.AssociativeSequenceKeys
+	if key := mergeKey(schema); key != "" {
+		keys = append([]string{key}, keys...)
+	}
+	for _, key := range keys {
+		found := true
+		for _, l := range lists {
+			if l == nil {
+				continue
+			}
+			for _, e := range l.Content {
+				if e.Kind != yaml.MappingNode || elementKey(e, key) == "" {
+					found = false
+				}
+			}
+		}
+		if found {
+			return key
+		}
+	}
+	return ""
+}
+
+// mergeComments merges the comments changed upstream into local, unless
+// they were also changed locally.
+func mergeComments(original, updated, local *yaml.Node) bool {
+	if local == nil || updated == nil {
+		return false
+	}
+	var o yaml.Node
+	if original != nil {
+		o = *original
+	}
+	var changed bool
+	for _, c := range []struct {
+		original, updated string
+		local             *string
+	}{
+		{o.HeadComment, updated.HeadComment, &local.HeadComment},
+		{o.LineComment, updated.LineComment, &local.LineComment},
+		{o.FootComment, updated.FootComment, &local.FootComment},
+	} {
+		if c.original != c.updated && *c.local == c.original {
+			*c.local = c.updated
+			changed = true
+		}
+	}
+	return changed
+}
+
+// replaceContent replaces the value of local with a copy of updated, keeping
+// the local comments.
+func replaceContent(updated, local *yaml.Node) {
+	n := copyNode(updated)
+	n.HeadComment, n.LineComment, n.FootComment =
+		local.HeadComment, local.LineComment, local.FootComment
+	*local = *n
+}
+
+// field returns the key and value nodes of the field name, or nils.
+func field(node *yaml.Node, name string) (*yaml.Node, *yaml.Node) {
+	if i := fieldIndex(node, name); i >= 0 {
+		return node.Content[i], node.Content[i+1]
+	}
+	return nil, nil
+}
+
+// fieldIndex returns the index of the key node of the field name, or -1.
+func fieldIndex(node *yaml.Node, name string) int {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return -1
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// elementKey returns the value of the key field of the list element.  Keys
+// with several fields, separated by commas, have their values separated by
+// commas, e.g. 80,TCP for containerPort,protocol.  Returns "" if the element
+// has none of the key fields.
+func elementKey(element *yaml.Node, key string) string {
+	var values []string
+	var found bool
+	for _, name := range strings.Split(key, ",") {
+		_, v := field(element, name)
+		if v == nil || v.Kind != yaml.ScalarNode {
+			values = append(values, "")
+			continue
+		}
+		values = append(values, v.Value)
+		found = true
+	}
+	if !found {
+		return ""
+	}
+	return strings.Join(values, ",")
+}
+
+// element returns the element of list whose key field has value, or nil.
+func element(list *yaml.Node, key, value string) *yaml.Node {
+	if i := elementIndex(list, key, value); i >= 0 {
+		return list.Content[i]
+	}
+	return nil
+}
+
+// elementIndex returns the index of the element of list whose key field has
+// value, or -1.
+func elementIndex(list *yaml.Node, key, value string) int {
+	if list == nil {
+		return -1
+	}
+	for i, e := range list.Content {
+		if elementKey(e, key) == value {
+			return i
+		}
+	}
+	return -1
+}
+
+func insert(nodes []*yaml.Node, i int, values ...*yaml.Node) []*yaml.Node {
+	if i > len(nodes) {
+		i = len(nodes)
+	}
+	return append(nodes[:i], append(values, nodes[i:]...)...)
+}
+
+// equal returns true if the nodes have the same values, ignoring comments
+// and formatting.
+func equal(a, b *yaml.Node) bool {
+	return compare(a, b, false)
+}
+
+// identical returns true if the nodes have the same values and comments.
+func identical(a, b *yaml.Node) bool {
+	return compare(a, b, true)
+}
+
+func compare(a, b *yaml.Node, comments bool) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	if a.Kind != b.Kind || a.Value != b.Value || len(a.Content) != len(b.Content) {
+		return false
+	}
+	if a.Kind == yaml.ScalarNode && a.ShortTag() != b.ShortTag() {
+		return false
+	}
+	if comments && (a.HeadComment != b.HeadComment ||
+		a.LineComment != b.LineComment || a.FootComment != b.FootComment) {
+		return false
+	}
+	for i := range a.Content {
+		if !compare(a.Content[i], b.Content[i], comments) {
+			return false
+		}
+	}
+	return true
+}
+
+// copyNode returns a deep copy of node.
+func copyNode(node *yaml.Node) *yaml.Node {
+	if node == nil {
+		return nil
+	}
+	n := *node
+	n.Content = nil
+	for _, c := range node.Content {
+		n.Content = append(n.Content, copyNode(c))
+	}
+	return &n
+}
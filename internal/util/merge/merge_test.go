@@ -0,0 +1,376 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package merge_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt/internal/testutil/pkgbuilder"
+	. "github.com/GoogleContainerTools/kpt/internal/util/merge"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/kustomize/kyaml/copyutil"
+)
+
+// TestMerge3 merges each testdata/<case>/updated package into the
+// testdata/<case>/local package and compares the result with
+// testdata/<case>/expected.
+func TestMerge3(t *testing.T) {
+	cases, err := ioutil.ReadDir("testdata")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	for i := range cases {
+		name := cases[i].Name()
+		t.Run(name, func(t *testing.T) {
+			data := filepath.Join("testdata", name)
+			dir, err := ioutil.TempDir("", "kpt-merge-test")
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			defer os.RemoveAll(dir)
+			if !assert.NoError(t, copyutil.CopyDir(filepath.Join(data, "local"), dir)) {
+				t.FailNow()
+			}
+
+			err = Merge3{
+				OriginalPath: filepath.Join(data, "original"),
+				UpdatedPath:  filepath.Join(data, "updated"),
+				DestPath:     dir,
+			}.Merge()
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+
+			expected := readFiles(t, filepath.Join(data, "expected"))
+			assert.Equal(t, expected, readFiles(t, dir))
+		})
+	}
+}
+
+const conflictsOriginal = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  replicas: 1
+  template:
+    spec:
+      containers:
+      - name: web
+        image: web:v1
+        args:
+        - --port=80
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+spec:
+  type: ClusterIP
+`
+
+const conflictsUpdated = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  replicas: 5
+  template:
+    spec:
+      containers:
+      - name: web
+        image: web:v2
+        args:
+        - --port=8080
+`
+
+const conflictsLocal = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  replicas: 3
+  template:
+    spec:
+      containers:
+      - name: web
+        image: web:v1
+        args:
+        - --port=80
+        - --verbose
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+spec:
+  type: LoadBalancer
+`
+
+func TestConflicts(t *testing.T) {
+	original := pkgbuilder.WritePackage(t, map[string]string{"resources.yaml": conflictsOriginal})
+	defer os.RemoveAll(original)
+	updated := pkgbuilder.WritePackage(t, map[string]string{"resources.yaml": conflictsUpdated})
+	defer os.RemoveAll(updated)
+	local := pkgbuilder.WritePackage(t, map[string]string{"resources.yaml": conflictsLocal})
+	defer os.RemoveAll(local)
+
+	conflicts, err := Conflicts(original, updated, local)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	var actual []string
+	for _, c := range conflicts {
+		assert.Equal(t, "resources.yaml", c.File)
+		actual = append(actual, c.String())
+	}
+	// the image is only changed upstream
+	assert.Equal(t, []string{
+		"Deployment/web spec.replicas: local 3, upstream 5",
+		"Deployment/web spec.template.spec.containers[name=web].args: " +
+			"local [--port=80, --verbose], upstream [--port=8080]",
+		"Service/web: local {apiVersion: v1, kind: Service, metadata: {name: web}, " +
+			"spec: {type: LoadBalancer}}, upstream (deleted)",
+	}, actual)
+}
+
+func TestConflict_MatchField(t *testing.T) {
+	c := Conflict{
+		Resource: "Deployment/web",
+		Field:    []string{"spec", "template", "spec", "containers", "[name=web]", "image"},
+	}
+	for pattern, expected := range map[string]bool{
+		"spec.template.spec.containers[name=web].image": true,
+		"spec.template.spec.containers[*].image":        true,
+		"spec.template.spec.containers[name=w*].image":  true,
+		"spec.template.spec.containers.*.image":         true,
+		"spec.template":                                 true,
+		"**.image":                                      true,
+		"spec.**.containers":                            true,
+		"spec.template.spec.containers[name=db].image":  false,
+		"spec.replicas":                                 false,
+		"**.args":                                       false,
+		"spec.template.spec.containers[*].image.tag":    false,
+	} {
+		assert.Equal(t, expected, c.MatchField(pattern), pattern)
+	}
+	assert.Equal(t, "Deployment", c.Kind())
+	assert.False(t, Conflict{Resource: "Service/web"}.MatchField("**"))
+}
+
+func TestKeepLocal(t *testing.T) {
+	original := pkgbuilder.WritePackage(t, map[string]string{"resources.yaml": conflictsOriginal})
+	defer os.RemoveAll(original)
+	updated := pkgbuilder.WritePackage(t, map[string]string{"resources.yaml": conflictsUpdated})
+	defer os.RemoveAll(updated)
+	local := pkgbuilder.WritePackage(t, map[string]string{"resources.yaml": conflictsLocal})
+	defer os.RemoveAll(local)
+
+	conflicts, err := Conflicts(original, updated, local)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	err = Merge3{OriginalPath: original, UpdatedPath: updated, DestPath: local}.Merge()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	err = KeepLocal(filepath.Join(local, "resources.yaml"), []byte(conflictsLocal), conflicts)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	// the local values are kept, and the image is updated
+	b, err := ioutil.ReadFile(filepath.Join(local, "resources.yaml"))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  replicas: 3
+  template:
+    spec:
+      containers:
+      - name: web
+        image: web:v2
+        args:
+        - --port=80
+        - --verbose
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+spec:
+  type: LoadBalancer
+`, string(b))
+}
+
+func TestProtected(t *testing.T) {
+	original := pkgbuilder.WritePackage(t, map[string]string{"resources.yaml": protectedOriginal})
+	defer os.RemoveAll(original)
+	updated := pkgbuilder.WritePackage(t, map[string]string{"resources.yaml": protectedUpdated})
+	defer os.RemoveAll(updated)
+	local := pkgbuilder.WritePackage(t, map[string]string{"resources.yaml": protectedLocal})
+	defer os.RemoveAll(local)
+
+	protected, err := Protected(original, updated, local)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	if !assert.Len(t, protected, 2) {
+		t.FailNow()
+	}
+	// the marked image wasn't changed upstream, so isn't reported
+	assert.Equal(t, "Deployment/web spec.replicas: local 5, upstream 3", protected[0].String())
+	assert.Equal(t, "ConfigMap/web", protected[1].Resource)
+	assert.Empty(t, protected[1].Field)
+
+	err = Merge3{OriginalPath: original, UpdatedPath: updated, DestPath: local}.Merge()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	err = KeepLocal(filepath.Join(local, "resources.yaml"), []byte(protectedLocal), protected)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	// the protected values are kept, and the args are updated
+	b, err := ioutil.ReadFile(filepath.Join(local, "resources.yaml"))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  replicas: 5 # kpt-merge: keep-local
+  template:
+    spec:
+      containers:
+      - name: web
+        image: web:v1 # kpt-merge: keep-local
+        args:
+        - --port=8080
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: web
+  annotations:
+    config.kpt.dev/merge: keep-local
+data:
+  level: debug
+`, string(b))
+}
+
+const protectedOriginal = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  replicas: 1
+  template:
+    spec:
+      containers:
+      - name: web
+        image: web:v1
+        args:
+        - --port=80
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: web
+data:
+  level: info
+`
+
+const protectedUpdated = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  replicas: 3
+  template:
+    spec:
+      containers:
+      - name: web
+        image: web:v1
+        args:
+        - --port=8080
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: web
+data:
+  level: warn
+`
+
+const protectedLocal = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  replicas: 5 # kpt-merge: keep-local
+  template:
+    spec:
+      containers:
+      - name: web
+        image: web:v1 # kpt-merge: keep-local
+        args:
+        - --port=80
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: web
+  annotations:
+    config.kpt.dev/merge: keep-local
+data:
+  level: debug
+`
+
+// readFiles returns the contents of the files under root keyed by their
+// relative paths.
+func readFiles(t *testing.T, root string) map[string]string {
+	files := map[string]string{}
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		files[rel] = string(b)
+		return nil
+	})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	return files
+}
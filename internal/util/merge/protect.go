@@ -0,0 +1,135 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package merge
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"sigs.k8s.io/kustomize/kyaml/openapi"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+const (
+	// KeepLocalMarker is the comment marking a field whose local value is
+	// kept when the package is updated, e.g.
+	//
+	//	replicas: 5 # kpt-merge: keep-local
+	KeepLocalMarker = "kpt-merge: keep-local"
+
+	// KeepLocalAnnotation marks a resource which is kept as it is locally
+	// when the package is updated, if set to KeepLocalValue.
+	KeepLocalAnnotation = "config.kpt.dev/merge"
+	KeepLocalValue      = "keep-local"
+)
+
+// Protected returns the local resources and fields marked to keep their
+// local value which were changed upstream, to a value other than the local
+// one -- i.e. those an update would otherwise overwrite.  They are returned
+// as conflicts so they can be restored with KeepLocal, sorted by file.
+func Protected(originalPath, updatedPath, localPath string) ([]Conflict, error) {
+	original, err := readPackage(originalPath)
+	if err != nil {
+		return nil, err
+	}
+	updated, err := readPackage(updatedPath)
+	if err != nil {
+		return nil, err
+	}
+	local, err := readPackage(localPath)
+	if err != nil {
+		return nil, err
+	}
+
+	schemas := newSchemas(original, updated, local)
+	var protected []Conflict
+	for _, key := range local.keys {
+		meta, err := local.nodes[key].GetMeta()
+		if err != nil {
+			continue
+		}
+		c := Conflict{File: local.paths[key], Resource: resourceName(meta), key: key}
+		o, u, l := node(original, key), node(updated, key), node(local, key)
+		if meta.Annotations[KeepLocalAnnotation] == KeepLocalValue {
+			if !equal(o, u) && !equal(u, l) {
+				c.Original, c.Updated, c.Local = value(o), value(u), value(l)
+				protected = append(protected, c)
+			}
+			continue
+		}
+		for _, path := range markedFields(schemas.forResource(local.nodes[key]), nil, l) {
+			of, uf, lf := lookupPath(o, path), lookupPath(u, path), lookupPath(l, path)
+			if equal(of, uf) || equal(uf, lf) {
+				continue
+			}
+			f := c
+			f.Field = path
+			f.Original, f.Updated, f.Local = value(of), value(uf), value(lf)
+			protected = append(protected, f)
+		}
+	}
+	sort.SliceStable(protected, func(i, j int) bool {
+		return protected[i].File < protected[j].File
+	})
+	return protected, nil
+}
+
+// markedFields returns the paths of the fields under node, at path, marked
+// with KeepLocalMarker.  schema is the schema of node, or nil.  Elements of
+// lists are only addressable if the list has an associative key, otherwise
+// the list itself must be marked.
+func markedFields(schema *openapi.ResourceSchema, path []string, node *yaml.Node) [][]string {
+	var paths [][]string
+	switch node.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			k, v := node.Content[i], node.Content[i+1]
+			p := appendPath(path, k.Value)
+			if marked(k, v) {
+				paths = append(paths, p)
+				continue
+			}
+			paths = append(paths, markedFields(fieldSchema(schema, k.Value), p, v)...)
+		}
+	case yaml.SequenceNode:
+		key := associativeKey(schema, node)
+		if key == "" {
+			break
+		}
+		for _, e := range node.Content {
+			p := appendPath(path, fmt.Sprintf("[%s=%s]", key, elementKey(e, key)))
+			if marked(e) {
+				paths = append(paths, p)
+				continue
+			}
+			paths = append(paths, markedFields(elementSchema(schema), p, e)...)
+		}
+	}
+	return paths
+}
+
+// marked returns true if any of nodes has a comment containing
+// KeepLocalMarker.
+func marked(nodes ...*yaml.Node) bool {
+	for _, n := range nodes {
+		for _, c := range []string{n.HeadComment, n.LineComment} {
+			if strings.Contains(c, KeepLocalMarker) {
+				return true
+			}
+		}
+	}
+	return false
+}
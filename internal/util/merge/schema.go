@@ -0,0 +1,170 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package merge
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/go-openapi/spec"
+	"sigs.k8s.io/kustomize/kyaml/openapi"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+const (
+	// patchMergeKey is the OpenAPI extension naming the field which
+	// identifies the elements of a list, e.g. name for containers.
+	patchMergeKey = "x-kubernetes-patch-merge-key"
+
+	// listMapKeys is the OpenAPI extension listing the fields which together
+	// identify the elements of a list, e.g. containerPort and protocol for
+	// ports.  CRDs use it for lists with x-kubernetes-list-type: map.
+	listMapKeys = "x-kubernetes-list-map-keys"
+)
+
+// schemas are the OpenAPI schemas of the resources being merged -- those of
+// the CRDs in the packages, and the builtin Kubernetes schema.
+type schemas map[yaml.TypeMeta]*spec.Schema
+
+// newSchemas returns the schemas of the CRDs in the packages.  Later
+// packages take precedence, so a CRD changed upstream or locally uses the
+// changed schema.
+func newSchemas(pkgs ...pkg) schemas {
+	s := schemas{}
+	for _, p := range pkgs {
+		for _, key := range p.keys {
+			s.addCRD(p.nodes[key])
+		}
+	}
+	return s
+}
+
+// addCRD adds the schemas of the versions of node if it's a CRD.  CRDs
+// without a schema, or with an invalid one, are skipped -- their resources
+// are merged without one.
+func (s schemas) addCRD(node *yaml.RNode) {
+	meta, err := node.GetMeta()
+	if err != nil || meta.Kind != "CustomResourceDefinition" ||
+		!strings.HasPrefix(meta.APIVersion, "apiextensions.k8s.io/") {
+		return
+	}
+	group, _ := node.GetString("spec.group")
+	kind, _ := node.GetString("spec.names.kind")
+	if group == "" || kind == "" {
+		return
+	}
+
+	// v1beta1 CRDs may have a single schema for all versions
+	common := crdSchema(node, "spec", "validation", "openAPIV3Schema")
+	var versions []string
+	if v, _ := node.GetString("spec.version"); v != "" {
+		versions = append(versions, v)
+	}
+	elements, _ := node.Pipe(yaml.Lookup("spec", "versions"))
+	if elements != nil {
+		items, _ := elements.Elements()
+		for _, item := range items {
+			name, _ := item.GetString("name")
+			if name == "" {
+				continue
+			}
+			if schema := crdSchema(item, "schema", "openAPIV3Schema"); schema != nil {
+				s[yaml.TypeMeta{APIVersion: group + "/" + name, Kind: kind}] = schema
+				continue
+			}
+			versions = append(versions, name)
+		}
+	}
+	if common == nil {
+		return
+	}
+	for _, name := range versions {
+		s[yaml.TypeMeta{APIVersion: group + "/" + name, Kind: kind}] = common
+	}
+}
+
+// crdSchema parses the schema at path under node, or returns nil.
+func crdSchema(node *yaml.RNode, path ...string) *spec.Schema {
+	n, err := node.Pipe(yaml.Lookup(path...))
+	if err != nil || n == nil {
+		return nil
+	}
+	b, err := n.MarshalJSON()
+	if err != nil {
+		return nil
+	}
+	schema := &spec.Schema{}
+	if err := json.Unmarshal(b, schema); err != nil {
+		return nil
+	}
+	return schema
+}
+
+// forResource returns the schema of the resource, from its CRD or the
+// builtin Kubernetes schema, or nil if it has none.
+func (s schemas) forResource(node *yaml.RNode) *openapi.ResourceSchema {
+	if node == nil {
+		return nil
+	}
+	meta, err := node.GetMeta()
+	if err != nil {
+		return nil
+	}
+	if schema := s[meta.TypeMeta]; schema != nil {
+		return &openapi.ResourceSchema{Schema: schema}
+	}
+	return openapi.SchemaForResourceType(meta.TypeMeta)
+}
+
+// fieldSchema returns the schema of the field name of schema, or nil.
+func fieldSchema(schema *openapi.ResourceSchema, name string) *openapi.ResourceSchema {
+	if schema.IsMissingOrNull() {
+		return nil
+	}
+	return schema.Field(name)
+}
+
+// elementSchema returns the schema of the elements of the list schema, or
+// nil.
+func elementSchema(schema *openapi.ResourceSchema) *openapi.ResourceSchema {
+	if schema.IsMissingOrNull() {
+		return nil
+	}
+	return schema.Elements()
+}
+
+// mergeKey returns the fields identifying the elements of the list schema,
+// separated by commas, or "" if it doesn't have any.
+func mergeKey(schema *openapi.ResourceSchema) string {
+	if schema.IsMissingOrNull() {
+		return ""
+	}
+	if key, ok := schema.Schema.Extensions.GetString(patchMergeKey); ok && key != "" {
+		return key
+	}
+	keys, ok := schema.Schema.Extensions[listMapKeys].([]interface{})
+	if !ok {
+		return ""
+	}
+	var names []string
+	for _, k := range keys {
+		name, ok := k.(string)
+		if !ok || name == "" {
+			return ""
+		}
+		names = append(names, name)
+	}
+	return strings.Join(names, ",")
+}
@@ -0,0 +1,281 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package metadata sets annotations and labels on the resources of
+// packages which match a selection.
+package metadata
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/GoogleContainerTools/kpt/internal/util/pipe"
+	"github.com/GoogleContainerTools/kpt/pkg/kptfile"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/kustomize/cmd/config/runner"
+	"sigs.k8s.io/kustomize/kyaml/errors"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/kio/kioutil"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// The metadata fields set.
+const (
+	Annotations = "annotations"
+	Labels      = "labels"
+)
+
+// Value is an annotation or label to set, or to remove if Remove is set.
+type Value struct {
+	Key    string
+	Value  string
+	Remove bool
+}
+
+// ParseValues parses KEY=VALUE pairs to set, and KEY- keys to remove.
+// Keys, and the values of labels, are validated.
+func ParseValues(field string, args []string) ([]Value, error) {
+	var values []Value
+	for _, arg := range args {
+		var v Value
+		switch kv := strings.SplitN(arg, "=", 2); {
+		case len(kv) == 2:
+			v = Value{Key: kv[0], Value: kv[1]}
+		case strings.HasSuffix(arg, "-"):
+			v = Value{Key: strings.TrimSuffix(arg, "-"), Remove: true}
+		default:
+			return nil, errors.Errorf("must specify %s as KEY=VALUE, or KEY- to remove them: %s", field, arg)
+		}
+		if errs := validation.IsQualifiedName(v.Key); len(errs) > 0 {
+			return nil, errors.Errorf("invalid key %q: %s", v.Key, strings.Join(errs, ", "))
+		}
+		if errs := validation.IsValidLabelValue(v.Value); field == Labels && len(errs) > 0 {
+			return nil, errors.Errorf("invalid value %q of label %q: %s", v.Value, v.Key, strings.Join(errs, ", "))
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+// SplitArgs splits the args of the annotate and label commands into the
+// package directory, "" if omitted, and the values.  The directory may
+// only be omitted if it is followed by no values, or the first value is a
+// KEY=VALUE pair.
+func SplitArgs(args []string) (string, []string) {
+	if len(args) == 0 || strings.Contains(args[0], "=") {
+		return "", args
+	}
+	return args[0], args[1:]
+}
+
+// Change is a change made to the metadata of a resource.
+type Change struct {
+	// File is the file of the resource
+	File string
+
+	// Resource is the kind and name of the resource, e.g. Deployment/app
+	Resource string
+
+	Value Value
+}
+
+// Setter sets annotations or labels on the resources matching its
+// selection.
+type Setter struct {
+	// Field is the metadata field set, Annotations or Labels
+	Field string
+
+	// Values are the annotations or labels set
+	Values []Value
+
+	// Kind, APIVersion, Name and Namespace if set select the resources
+	// with the kind, apiVersion, name and namespace
+	Kind       string
+	APIVersion string
+	Name       string
+	Namespace  string
+
+	// Selector if set selects the resources whose labels match it
+	Selector labels.Selector
+
+	// Changes are the changes made, set by Filter
+	Changes []Change
+}
+
+// Filter implements kio.Filter.
+func (s *Setter) Filter(nodes []*yaml.RNode) ([]*yaml.RNode, error) {
+	s.Changes = nil
+	for _, n := range nodes {
+		m, err := n.GetMeta()
+		if err != nil {
+			return nil, err
+		}
+		if !s.selects(m) {
+			continue
+		}
+		current := m.Annotations
+		if s.Field == Labels {
+			current = m.Labels
+		}
+		file, _, err := kioutil.GetFileAnnotations(n)
+		if err != nil {
+			return nil, err
+		}
+		if m.Annotations[pipe.SynthesizedPathAnnotation] == "true" {
+			file = ""
+		}
+		for _, v := range s.Values {
+			old, found := current[v.Key]
+			if v.Remove && !found || !v.Remove && found && old == v.Value {
+				continue
+			}
+			if err := n.PipeE(s.filter(v)); err != nil {
+				return nil, err
+			}
+			s.Changes = append(s.Changes, Change{File: file, Resource: m.Kind + "/" + m.Name, Value: v})
+		}
+		if err := yaml.ClearEmptyAnnotations(n); err != nil {
+			return nil, err
+		}
+		if err := clearEmptyLabels(n); err != nil {
+			return nil, err
+		}
+	}
+	return nodes, nil
+}
+
+// clearEmptyLabels removes the labels of n if there are none.
+func clearEmptyLabels(n *yaml.RNode) error {
+	l, err := n.Pipe(yaml.Lookup("metadata", "labels"))
+	if err != nil || l == nil || len(l.Content()) > 0 {
+		return err
+	}
+	_, err = n.Pipe(yaml.Lookup("metadata"), yaml.FieldClearer{Name: "labels"})
+	return err
+}
+
+// selects returns true if the resource with m is selected.
+func (s *Setter) selects(m yaml.ResourceMeta) bool {
+	switch {
+	case s.Kind != "" && s.Kind != m.Kind,
+		s.APIVersion != "" && s.APIVersion != m.APIVersion,
+		s.Namespace != "" && s.Namespace != m.Namespace,
+		s.Name != "" && s.Name != m.Name:
+		return false
+	}
+	return s.Selector == nil || s.Selector.Matches(labels.Set(m.Labels))
+}
+
+// filter returns the filter setting or removing v.
+func (s *Setter) filter(v Value) yaml.Filter {
+	if s.Field == Labels {
+		if v.Remove {
+			return yaml.Tee(yaml.PathGetter{Path: []string{"metadata", "labels"}}, yaml.FieldClearer{Name: v.Key})
+		}
+		return yaml.SetLabel(v.Key, v.Value)
+	}
+	if v.Remove {
+		return yaml.ClearAnnotation(v.Key)
+	}
+	return yaml.SetAnnotation(v.Key, v.Value)
+}
+
+// Runner runs a Setter against packages.
+type Runner struct {
+	Setter *Setter
+
+	// DryRun if set prints the changes rather than making them
+	DryRun bool
+
+	// RecurseSubPackages if set also runs against the nested subpackages
+	RecurseSubPackages bool
+}
+
+// Run runs the setter against the package at path, writing its messages
+// to w.
+func (r *Runner) Run(w io.Writer, path string) error {
+	return runner.ExecuteCmdOnPkgs{
+		Writer:             w,
+		NeedOpenAPI:        false,
+		RecurseSubPackages: r.RecurseSubPackages,
+		CmdRunner:          r,
+		RootPkgPath:        path,
+	}.Execute()
+}
+
+// RunStream runs the setter against the resources read from in, writing
+// them to out.  With DryRun the changes are written instead.
+func (r *Runner) RunStream(in io.Reader, out io.Writer) error {
+	rw := &kio.ByteReadWriter{Reader: in, Writer: out}
+	var outputs []kio.Writer
+	if !r.DryRun {
+		outputs = append(outputs, rw)
+	}
+	err := kio.Pipeline{Inputs: []kio.Reader{rw}, Filters: []kio.Filter{r.Setter}, Outputs: outputs}.Execute()
+	if err != nil || !r.DryRun {
+		return err
+	}
+	return r.writeChanges(out)
+}
+
+// ExecuteCmd implements runner.ExecuteCmdOnPkgs.
+func (r *Runner) ExecuteCmd(w io.Writer, pkgPath string) error {
+	rw := &kio.LocalPackageReadWriter{
+		PackagePath:     pkgPath,
+		NoDeleteFiles:   true,
+		PackageFileName: kptfile.KptFileName,
+	}
+	var outputs []kio.Writer
+	if !r.DryRun {
+		outputs = append(outputs, rw)
+	}
+	err := kio.Pipeline{Inputs: []kio.Reader{rw}, Filters: []kio.Filter{r.Setter}, Outputs: outputs}.Execute()
+	switch {
+	case err != nil && !r.RecurseSubPackages:
+		return err
+	case err != nil:
+		// print the error and continue with the other packages
+		fmt.Fprintf(w, "%s\n", err.Error())
+	case r.DryRun:
+		return r.writeChanges(w)
+	default:
+		fmt.Fprintf(w, "added %s in the package\n", r.Setter.Field)
+	}
+	return nil
+}
+
+// writeChanges writes the changes made by the setter.
+func (r *Runner) writeChanges(w io.Writer) error {
+	changes := r.Setter.Changes
+	sort.SliceStable(changes, func(i, j int) bool { return changes[i].File < changes[j].File })
+	kind := strings.TrimSuffix(r.Setter.Field, "s")
+	for _, c := range changes {
+		where := c.Resource
+		if c.File != "" {
+			where = fmt.Sprintf("%s in %s", c.Resource, c.File)
+		}
+		if c.Value.Remove {
+			fmt.Fprintf(w, "would remove %s %q from %s\n", kind, c.Value.Key, where)
+			continue
+		}
+		fmt.Fprintf(w, "would set %s %q to %q on %s\n", kind, c.Value.Key, c.Value.Value, where)
+	}
+	if len(changes) == 0 {
+		fmt.Fprintf(w, "no %s would change\n", r.Setter.Field)
+	}
+	return nil
+}
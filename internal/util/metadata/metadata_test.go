@@ -0,0 +1,209 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metadata_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt/internal/util/metadata"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+const resources = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: prod
+  labels:
+    app: web
+    tier: frontend
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+  namespace: prod
+  labels:
+    app: web
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: db
+  namespace: dev
+  labels:
+    app: db
+`
+
+func TestParseValues(t *testing.T) {
+	values, err := metadata.ParseValues(metadata.Labels, []string{"app=web", "tier-", "empty="})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, []metadata.Value{
+		{Key: "app", Value: "web"},
+		{Key: "tier", Remove: true},
+		{Key: "empty", Value: ""},
+	}, values)
+
+	_, err = metadata.ParseValues(metadata.Labels, []string{"app"})
+	assert.EqualError(t, err, "must specify labels as KEY=VALUE, or KEY- to remove them: app")
+
+	_, err = metadata.ParseValues(metadata.Annotations, []string{"bad key=x"})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), `invalid key "bad key"`)
+	}
+
+	_, err = metadata.ParseValues(metadata.Labels, []string{"app=not valid"})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), `invalid value "not valid" of label "app"`)
+	}
+
+	// annotation values are not restricted
+	_, err = metadata.ParseValues(metadata.Annotations, []string{"note=not valid"})
+	assert.NoError(t, err)
+}
+
+func TestSplitArgs(t *testing.T) {
+	dir, values := metadata.SplitArgs([]string{"pkg", "a=b"})
+	assert.Equal(t, "pkg", dir)
+	assert.Equal(t, []string{"a=b"}, values)
+
+	dir, values = metadata.SplitArgs([]string{"a=b", "c-"})
+	assert.Equal(t, "", dir)
+	assert.Equal(t, []string{"a=b", "c-"}, values)
+
+	dir, values = metadata.SplitArgs(nil)
+	assert.Equal(t, "", dir)
+	assert.Empty(t, values)
+}
+
+func TestRunStream(t *testing.T) {
+	var tests = []struct {
+		name     string
+		setter   metadata.Setter
+		selector string
+		dryRun   bool
+		expected string
+	}{
+		{
+			name: "set-kind",
+			setter: metadata.Setter{
+				Field:  metadata.Annotations,
+				Values: []metadata.Value{{Key: "owner", Value: "team-a"}},
+				Kind:   "Service",
+			},
+			expected: `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: prod
+  labels:
+    app: web
+    tier: frontend
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+  namespace: prod
+  labels:
+    app: web
+  annotations:
+    owner: 'team-a'
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: db
+  namespace: dev
+  labels:
+    app: db
+`,
+		},
+		{
+			name: "selector-remove",
+			setter: metadata.Setter{
+				Field:  metadata.Labels,
+				Values: []metadata.Value{{Key: "app", Remove: true}, {Key: "tier", Remove: true}},
+			},
+			selector: "tier=frontend",
+			expected: `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: prod
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: web
+  namespace: prod
+  labels:
+    app: web
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: db
+  namespace: dev
+  labels:
+    app: db
+`,
+		},
+		{
+			name: "dry-run",
+			setter: metadata.Setter{
+				Field:     metadata.Labels,
+				Values:    []metadata.Value{{Key: "app", Value: "web"}, {Key: "tier", Remove: true}},
+				Namespace: "prod",
+			},
+			dryRun: true,
+			expected: `would remove label "tier" from Deployment/web
+`,
+		},
+		{
+			name: "dry-run-nothing",
+			setter: metadata.Setter{
+				Field:  metadata.Annotations,
+				Values: []metadata.Value{{Key: "owner", Remove: true}},
+			},
+			dryRun: true,
+			expected: `no annotations would change
+`,
+		},
+	}
+	for i := range tests {
+		test := tests[i]
+		t.Run(test.name, func(t *testing.T) {
+			s := test.setter
+			if test.selector != "" {
+				var err error
+				if s.Selector, err = labels.Parse(test.selector); !assert.NoError(t, err) {
+					t.FailNow()
+				}
+			}
+			out := &bytes.Buffer{}
+			r := metadata.Runner{Setter: &s, DryRun: test.dryRun}
+			if !assert.NoError(t, r.RunStream(strings.NewReader(resources), out)) {
+				t.FailNow()
+			}
+			assert.Equal(t, test.expected, out.String())
+		})
+	}
+}
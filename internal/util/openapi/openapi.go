@@ -18,10 +18,14 @@ import (
 	"context"
 	"fmt"
 	"io/ioutil"
+	"path/filepath"
 
+	"github.com/GoogleContainerTools/kpt/pkg/kptfile"
+	"github.com/go-openapi/spec"
 	"k8s.io/kubectl/pkg/cmd/util"
 	"sigs.k8s.io/kustomize/kyaml/openapi"
 	"sigs.k8s.io/kustomize/kyaml/openapi/kustomizationapi"
+	"sigs.k8s.io/kustomize/kyaml/pathutil"
 )
 
 const (
@@ -30,6 +34,11 @@ const (
 	SchemaSourceCluster = "cluster"
 )
 
+// SchemasDir is the directory of a package containing the OpenAPI schemas
+// of its custom resources, as json files in the format of the
+// --k8s-schema-path file.
+const SchemasDir = "schemas"
+
 // ConfigureOpenAPI sets the openAPI schema in kyaml. It can either
 // fetch the schema from a cluster, read it from file, or just the
 // schema built into kyaml.
@@ -86,3 +95,79 @@ func ConfigureOpenAPISchema(openAPISchema []byte) error {
 	// know the name of the kustomize asset here.
 	return openapi.AddSchema(kustomizationapi.MustAsset("kustomizationapi/swagger.json"))
 }
+
+// AddPackageSchemas adds the OpenAPI schemas in the SchemasDir of the
+// package at path to the schema, so the custom resources of the package
+// are typed without reading the schema of their CRDs from a cluster.
+func AddPackageSchemas(path string) error {
+	files, err := filepath.Glob(filepath.Join(path, SchemasDir, "*.json"))
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return nil
+	}
+	// the schema is initialized first, so the package schemas take
+	// precedence over the builtin schema
+	openapi.Schema()
+	for _, f := range files {
+		b, err := ioutil.ReadFile(f)
+		if err != nil {
+			return err
+		}
+		if err := checkSchema(b); err != nil {
+			return fmt.Errorf("invalid OpenAPI schema %s: %v", f, err)
+		}
+		if err := openapi.AddSchema(b); err != nil {
+			return fmt.Errorf("invalid OpenAPI schema %s: %v", f, err)
+		}
+	}
+	return nil
+}
+
+// AddPackageTreeSchemas adds the OpenAPI schemas of the package at path and
+// its subpackages.
+func AddPackageTreeSchemas(path string) error {
+	dirs, err := pathutil.DirsWithFile(path, kptfile.KptFileName, true)
+	if err != nil {
+		return err
+	}
+	for _, d := range dirs {
+		if err := AddPackageSchemas(d); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkSchema returns an error if the schema b isn't an OpenAPI document,
+// or the group, version and kind of a definition aren't strings, as kyaml
+// expects them to be.
+func checkSchema(b []byte) error {
+	var swagger spec.Swagger
+	if err := swagger.UnmarshalJSON(b); err != nil {
+		return err
+	}
+	for name, d := range swagger.Definitions {
+		gvk, found := d.Extensions["x-kubernetes-group-version-kind"]
+		if !found {
+			continue
+		}
+		exts, ok := gvk.([]interface{})
+		if !ok {
+			return fmt.Errorf("definition %s: x-kubernetes-group-version-kind must be a list", name)
+		}
+		for _, e := range exts {
+			m, ok := e.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("definition %s: x-kubernetes-group-version-kind must be a list of objects", name)
+			}
+			for _, key := range []string{"group", "version", "kind"} {
+				if _, ok := m[key].(string); !ok {
+					return fmt.Errorf("definition %s: x-kubernetes-group-version-kind %s must be a string", name, key)
+				}
+			}
+		}
+	}
+	return nil
+}
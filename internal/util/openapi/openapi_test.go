@@ -19,6 +19,8 @@ import (
 	"io"
 	"io/ioutil"
 	"net/http"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/go-openapi/spec"
@@ -28,6 +30,7 @@ import (
 	"k8s.io/client-go/rest/fake"
 	cmdtesting "k8s.io/kubectl/pkg/cmd/testing"
 	"sigs.k8s.io/kustomize/kyaml/openapi"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
 )
 
 func TestSomething(t *testing.T) {
@@ -152,3 +155,74 @@ func getSchema(t *testing.T, filename string) io.ReadCloser {
 	}
 	return ioutil.NopCloser(bytes.NewBuffer(b))
 }
+
+func TestAddPackageTreeSchemas(t *testing.T) {
+	testCases := []struct {
+		name        string
+		schema      string
+		expectError string
+	}{
+		{
+			name: "valid schema",
+			schema: `{"definitions": {"com.example.v1.Database": {"type": "object",
+  "x-kubernetes-group-version-kind": [{"group": "example.com", "kind": "Database", "version": "v1"}]}}}`,
+		},
+		{
+			name:        "not a schema",
+			schema:      `[]`,
+			expectError: "invalid OpenAPI schema",
+		},
+		{
+			name: "version isn't a string",
+			schema: `{"definitions": {"com.example.v1.Database": {"type": "object",
+  "x-kubernetes-group-version-kind": [{"group": "example.com", "kind": "Database", "version": 1}]}}}`,
+			expectError: "x-kubernetes-group-version-kind version must be a string",
+		},
+	}
+
+	for i := range testCases {
+		test := testCases[i]
+		t.Run(test.name, func(t *testing.T) {
+			openapi.ResetOpenAPI()
+			defer openapi.ResetOpenAPI()
+
+			dir, err := ioutil.TempDir("", "kpt-openapi-")
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			defer os.RemoveAll(dir)
+			// the schema is bundled in a subpackage
+			for path, content := range map[string]string{
+				"Kptfile":                      "apiVersion: kpt.dev/v1alpha1\nkind: Kptfile\n",
+				"sub/Kptfile":                  "apiVersion: kpt.dev/v1alpha1\nkind: Kptfile\n",
+				"sub/schemas/database.json":    test.schema,
+				"sub/schemas/not-a-schema.txt": "ignored",
+			} {
+				path = filepath.Join(dir, path)
+				if !assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0700)) {
+					t.FailNow()
+				}
+				if !assert.NoError(t, ioutil.WriteFile(path, []byte(content), 0600)) {
+					t.FailNow()
+				}
+			}
+
+			err = AddPackageTreeSchemas(dir)
+			if test.expectError != "" {
+				if assert.Error(t, err) {
+					assert.Contains(t, err.Error(), test.expectError)
+				}
+				return
+			}
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+
+			assert.NotNil(t, openapi.SchemaForResourceType(yaml.TypeMeta{
+				APIVersion: "example.com/v1", Kind: "Database"}))
+			// the builtin schema is kept
+			assert.NotNil(t, openapi.SchemaForResourceType(yaml.TypeMeta{
+				APIVersion: "apps/v1", Kind: "Deployment"}))
+		})
+	}
+}
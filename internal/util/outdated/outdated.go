@@ -0,0 +1,288 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package outdated reports packages whose upstream has newer versions.
+package outdated
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/GoogleContainerTools/kpt/internal/gitutil"
+	"github.com/GoogleContainerTools/kpt/internal/util/concurrency"
+	"github.com/GoogleContainerTools/kpt/internal/util/semver"
+	"github.com/GoogleContainerTools/kpt/pkg/kptfile"
+	"github.com/GoogleContainerTools/kpt/pkg/kptfile/kptfileutil"
+	"sigs.k8s.io/kustomize/kyaml/errors"
+	"sigs.k8s.io/kustomize/kyaml/pathutil"
+)
+
+// Ref types.
+const (
+	// BranchRef packages track a branch
+	BranchRef = "branch"
+
+	// VersionRef packages are fetched at a semantic version tag
+	VersionRef = "version"
+
+	// PinnedRef packages are fetched at a commit, or a tag which isn't a
+	// version, and are never behind
+	PinnedRef = "pinned"
+)
+
+// Output formats.
+const (
+	TableOutput = "table"
+	JSONOutput  = "json"
+)
+
+// Package is the upstream state of a package.
+type Package struct {
+	// Path is the path of the package, relative to the scanned directory
+	Path string `json:"path"`
+
+	// Repo, Directory, Ref and Commit are the upstream the package was
+	// fetched from
+	Repo      string `json:"repo"`
+	Directory string `json:"directory"`
+	Ref       string `json:"ref"`
+	Commit    string `json:"commit"`
+
+	// TagTemplate names the tags which version the package
+	TagTemplate string `json:"tagTemplate,omitempty"`
+
+	// RefType is BranchRef, VersionRef or PinnedRef
+	RefType string `json:"refType,omitempty"`
+
+	// Latest is the newest version of the package, or the commit the branch
+	// points at for packages tracking a branch
+	Latest string `json:"latest,omitempty"`
+
+	// Newer are the versions newer than Ref, newest first.  Pre-releases
+	// are only included if Ref is a pre-release.
+	Newer []string `json:"newer,omitempty"`
+
+	// Outdated is true if the upstream has a newer version or commit
+	Outdated bool `json:"outdated"`
+
+	// Error is the error querying the upstream, if any
+	Error string `json:"error,omitempty"`
+}
+
+// Behind describes how far the package is behind its upstream.
+func (p Package) Behind() string {
+	switch {
+	case p.Error != "":
+		return "unknown"
+	case !p.Outdated:
+		return "-"
+	case len(p.Newer) == 1:
+		return "1 version"
+	case len(p.Newer) > 0:
+		return fmt.Sprintf("%d versions", len(p.Newer))
+	default:
+		return "new commits"
+	}
+}
+
+// Command reports the packages under Dir which are behind their upstream.
+type Command struct {
+	// Dir is the directory to scan for packages
+	Dir string
+
+	// All includes packages which are up to date, and packages which could
+	// not be checked, in the report
+	All bool
+
+	// Output is the format of the report, TableOutput or JSONOutput
+	Output string
+
+	// Concurrency is the number of upstreams queried at once
+	Concurrency int
+
+	// StdOut is where the report is written
+	StdOut io.Writer
+}
+
+// Run writes the report to StdOut.
+func (c Command) Run() error {
+	if c.Output == "" {
+		c.Output = TableOutput
+	}
+	if c.Output != TableOutput && c.Output != JSONOutput {
+		return errors.Errorf("unsupported output %q, must be one of %s, %s",
+			c.Output, TableOutput, JSONOutput)
+	}
+	pkgs, err := Check(c.Dir, c.Concurrency)
+	if err != nil {
+		return err
+	}
+	var report []Package
+	for _, p := range pkgs {
+		if c.All || p.Outdated || p.Error != "" {
+			report = append(report, p)
+		}
+	}
+	if c.Output == JSONOutput {
+		if report == nil {
+			report = []Package{}
+		}
+		e := json.NewEncoder(c.StdOut)
+		e.SetIndent("", "  ")
+		return errors.Wrap(e.Encode(report))
+	}
+	return WriteTable(c.StdOut, report)
+}
+
+// Check returns the upstream state of the packages under dir with git
+// upstreams, sorted by path.  Up to limit upstreams are queried at once.
+func Check(dir string, limit int) ([]Package, error) {
+	paths, err := pathutil.DirsWithFile(dir, kptfile.KptFileName, true)
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	var pkgs []Package
+	var dirs []string
+	for _, p := range paths {
+		k, err := kptfileutil.ReadFile(p)
+		if err != nil {
+			return nil, err
+		}
+		g := k.Upstream.Git
+		if g.Repo == "" {
+			continue
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return nil, errors.Wrap(err)
+		}
+		pkgs = append(pkgs, Package{Path: filepath.ToSlash(rel), Repo: g.Repo,
+			Directory: g.Directory, Ref: g.Ref, Commit: g.Commit, TagTemplate: g.TagTemplate})
+		dirs = append(dirs, p)
+	}
+	sort.Sort(byPath{pkgs, dirs})
+
+	concurrency.ForEach(limit, len(pkgs), func(i int) error {
+		if err := check(&pkgs[i], dirs[i]); err != nil {
+			pkgs[i].Error = err.Error()
+		}
+		return nil
+	})
+	return pkgs, nil
+}
+
+// byPath sorts packages, and their directories, by path.
+type byPath struct {
+	pkgs []Package
+	dirs []string
+}
+
+func (b byPath) Len() int           { return len(b.pkgs) }
+func (b byPath) Less(i, j int) bool { return b.pkgs[i].Path < b.pkgs[j].Path }
+func (b byPath) Swap(i, j int) {
+	b.pkgs[i], b.pkgs[j] = b.pkgs[j], b.pkgs[i]
+	b.dirs[i], b.dirs[j] = b.dirs[j], b.dirs[i]
+}
+
+// check queries the upstream of the package at dir for newer versions or
+// commits.
+func check(p *Package, dir string) error {
+	repo, directory := p.Repo, p.Directory
+	if gitutil.IsRelativeRepo(repo) {
+		var err error
+		repo, directory, err = gitutil.ResolveRelativeRepo(dir, repo)
+		if err != nil {
+			return err
+		}
+	}
+
+	// packages tracking a branch are behind if the branch moved
+	commit, isBranch, err := gitutil.BranchCommit(repo, p.Ref, nil)
+	if err != nil {
+		return err
+	}
+	if isBranch {
+		p.RefType = BranchRef
+		p.Latest = commit
+		p.Outdated = commit != p.Commit
+		return nil
+	}
+
+	versions, err := gitutil.ListVersions(repo, directory, p.TagTemplate, nil)
+	if err != nil {
+		return err
+	}
+	ref := gitutil.TagRef(p.TagTemplate, directory, strings.TrimPrefix(p.Ref, "refs/tags/"))
+	current, err := semver.Parse(ref)
+	if err != nil {
+		// pinned packages may be moved to a version
+		p.RefType = PinnedRef
+		for _, v := range versions {
+			if v.Prerelease == "" {
+				p.Latest = v.Original
+				break
+			}
+		}
+		return nil
+	}
+	p.RefType = VersionRef
+	for _, v := range versions {
+		if v.Compare(current) <= 0 {
+			break
+		}
+		if v.Prerelease == "" || current.Prerelease != "" {
+			p.Newer = append(p.Newer, v.Original)
+		}
+	}
+	p.Latest = p.Ref
+	if len(p.Newer) > 0 {
+		p.Latest = p.Newer[0]
+		p.Outdated = true
+	}
+	return nil
+}
+
+// WriteTable writes a table of the packages to w.
+func WriteTable(w io.Writer, pkgs []Package) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "PACKAGE\tREPO\tCURRENT\tLATEST\tBEHIND")
+	for _, p := range pkgs {
+		current, latest := p.Ref, p.Latest
+		if p.RefType == BranchRef {
+			current = fmt.Sprintf("%s@%s", p.Ref, shortSha(p.Commit))
+			latest = fmt.Sprintf("%s@%s", p.Ref, shortSha(p.Latest))
+		}
+		if p.Error != "" {
+			latest = "error: " + strings.ReplaceAll(p.Error, "\n", " ")
+		}
+		if latest == "" {
+			latest = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", p.Path, p.Repo, current, latest, p.Behind())
+	}
+	return errors.Wrap(tw.Flush())
+}
+
+// shortSha returns a shortened version of a commit SHA
+func shortSha(sha string) string {
+	if len(sha) > 7 {
+		return sha[:7]
+	}
+	return sha
+}
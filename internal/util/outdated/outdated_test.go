@@ -0,0 +1,121 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outdated_test
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt/internal/testutil"
+	. "github.com/GoogleContainerTools/kpt/internal/util/outdated"
+	"github.com/stretchr/testify/assert"
+)
+
+// writeKptfile writes a Kptfile fetched from the upstream to the package
+// at path under dir.
+func writeKptfile(t *testing.T, dir, path, repo, directory, ref, commit string) {
+	err := os.MkdirAll(filepath.Join(dir, path), 0700)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	err = ioutil.WriteFile(filepath.Join(dir, path, "Kptfile"), []byte(fmt.Sprintf(`apiVersion: kpt.dev/v1alpha1
+kind: Kptfile
+metadata:
+  name: %s
+upstream:
+  type: git
+  git:
+    repo: %s
+    directory: %s
+    ref: %s
+    commit: %s
+`, filepath.Base(path), repo, directory, ref, commit)), 0600)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+}
+
+func TestCheck(t *testing.T) {
+	g, w, clean := testutil.SetupDefaultRepoAndWorkspace(t, testutil.Dataset1)
+	defer clean()
+
+	commit, err := g.GetCommit()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	for _, tag := range []string{"v1.0.0", "v1.1.0", "v1.2.0", "v2.0.0-rc.1"} {
+		testutil.Tag(t, g, tag)
+	}
+	err = g.ReplaceData(testutil.Dataset2)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	testutil.Commit(t, g, "new data")
+	head, err := g.GetCommit()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	dir := w.WorkspaceDirectory
+	writeKptfile(t, dir, "behind", g.RepoDirectory, "/java", "v1.0.0", commit)
+	writeKptfile(t, dir, "latest", g.RepoDirectory, "/java", "refs/tags/v1.2.0", commit)
+	writeKptfile(t, dir, "rc", g.RepoDirectory, "/java", "v2.0.0-rc.0", commit)
+	writeKptfile(t, dir, "branch", g.RepoDirectory, "/mysql", "master", commit)
+	writeKptfile(t, dir, filepath.Join("branch", "current"), g.RepoDirectory, "/mysql", "master", head)
+	writeKptfile(t, dir, "pinned", g.RepoDirectory, "/mysql", commit, commit)
+	writeKptfile(t, dir, "missing", filepath.Join(dir, "missing-repo"), "/", "master", commit)
+
+	pkgs, err := Check(dir, 2)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	if !assert.Len(t, pkgs, 7) {
+		t.FailNow()
+	}
+	for i := range pkgs {
+		pkgs[i].Repo = ""
+		if pkgs[i].Error != "" {
+			pkgs[i].Error = "error"
+		}
+	}
+	assert.Equal(t, []Package{
+		{Path: "behind", Directory: "/java", Ref: "v1.0.0", Commit: commit, RefType: VersionRef,
+			Latest: "v1.2.0", Newer: []string{"v1.2.0", "v1.1.0"}, Outdated: true},
+		{Path: "branch", Directory: "/mysql", Ref: "master", Commit: commit, RefType: BranchRef,
+			Latest: head, Outdated: true},
+		{Path: "branch/current", Directory: "/mysql", Ref: "master", Commit: head, RefType: BranchRef,
+			Latest: head},
+		{Path: "latest", Directory: "/java", Ref: "refs/tags/v1.2.0", Commit: commit, RefType: VersionRef,
+			Latest: "refs/tags/v1.2.0"},
+		{Path: "missing", Directory: "/", Ref: "master", Commit: commit, Error: "error"},
+		{Path: "pinned", Directory: "/mysql", Ref: commit, Commit: commit, RefType: PinnedRef,
+			Latest: "v1.2.0"},
+		{Path: "rc", Directory: "/java", Ref: "v2.0.0-rc.0", Commit: commit, RefType: VersionRef,
+			Latest: "v2.0.0-rc.1", Newer: []string{"v2.0.0-rc.1"}, Outdated: true},
+	}, pkgs)
+
+	b := &bytes.Buffer{}
+	err = Command{Dir: dir, StdOut: b, Output: JSONOutput}.Run()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Contains(t, b.String(), `"path": "behind"`)
+	assert.Contains(t, b.String(), `"path": "missing"`)
+	assert.NotContains(t, b.String(), `"path": "latest"`)
+}
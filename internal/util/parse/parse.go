@@ -15,12 +15,14 @@
 package parse
 
 import (
+	"net/url"
 	"os"
 	"path"
 	"path/filepath"
 	"strings"
 
 	"github.com/GoogleContainerTools/kpt/internal/gitutil"
+	"github.com/GoogleContainerTools/kpt/internal/util/get"
 	"github.com/GoogleContainerTools/kpt/pkg/kptfile"
 	"sigs.k8s.io/kustomize/kyaml/errors"
 )
@@ -31,11 +33,62 @@ type Target struct {
 }
 
 func GitParseArgs(args []string) (Target, error) {
+	return GitParseArgsWithConfig(args, nil)
+}
+
+// GitParseArgsWithConfig is GitParseArgs, passing config to the git
+// commands resolving the default ref and the refs of web UI URLs as -c
+// flags.
+func GitParseArgsWithConfig(args []string, config map[string]string) (Target, error) {
 	g := Target{}
 	if args[0] == "-" {
 		return g, nil
 	}
 
+	// packages in the same repository, e.g. ./../../base-pkg@v1
+	if gitutil.IsRelativeRepo(args[0]) {
+		rel, version, err := getURIAndVersion(args[0])
+		if err != nil {
+			return g, err
+		}
+		root, dir, err := gitutil.ResolveRelativeRepo(".", rel)
+		if err != nil {
+			return g, err
+		}
+		if version == "" {
+			defaultRef, err := gitutil.DefaultRefWithConfig(root, config)
+			if err != nil {
+				return g, err
+			}
+			version = defaultRef
+		}
+		destination, err := getDest(args[1], root, dir)
+		if err != nil {
+			return g, err
+		}
+		g.Ref = version
+		g.Directory = dir
+		g.Repo = rel
+		g.Destination = filepath.Clean(destination)
+		return g, nil
+	}
+
+	// directories and files in the GitHub and GitLab web UI, e.g.
+	// https://github.com/org/repo/tree/main/pkg
+	if repo, dir, version, ok, err := webURL(args[0], config); err != nil {
+		return g, err
+	} else if ok {
+		destination, err := getDest(args[1], repo, dir)
+		if err != nil {
+			return g, err
+		}
+		g.Ref = version
+		g.Directory = dir
+		g.Repo = repo
+		g.Destination = filepath.Clean(destination)
+		return g, nil
+	}
+
 	// Simple parsing if repo name ends in .git
 	if strings.Contains(args[0], ".git/") ||
 		strings.HasSuffix(args[0], ".git") {
@@ -55,7 +108,7 @@ func GitParseArgs(args []string) (Target, error) {
 			dir = parts[1]
 		}
 		if version == "" {
-			defaultRef, err := gitutil.DefaultRef(repo)
+			defaultRef, err := gitutil.DefaultRefWithConfig(repo, config)
 			if err != nil {
 				return g, err
 			}
@@ -84,7 +137,7 @@ func GitParseArgs(args []string) (Target, error) {
 		return g, err
 	}
 	if version == "" {
-		defaultRef, err := gitutil.DefaultRef(repo)
+		defaultRef, err := gitutil.DefaultRefWithConfig(repo, config)
 		if err != nil {
 			return g, err
 		}
@@ -102,6 +155,65 @@ func GitParseArgs(args []string) (Target, error) {
 	return g, nil
 }
 
+// webURL parses the URL of a directory or file in the GitHub or GitLab web
+// UI, e.g. https://github.com/org/repo/tree/main/pkg or
+// https://gitlab.com/group/repo/-/blob/main/pkg/Kptfile, into the repo,
+// package directory and ref.  The package of a file is its directory.
+// Refs containing slashes are matched against the branches and tags of the
+// repo, listed with git config.  Returns false if v isn't such a URL.
+func webURL(v string, config map[string]string) (string, string, string, bool, error) {
+	u, err := url.Parse(v)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") {
+		return "", "", "", false, nil
+	}
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+
+	var repoPath, rest []string
+	var kind string
+	switch {
+	case u.Host == "github.com" && len(segments) >= 4 &&
+		(segments[2] == "tree" || segments[2] == "blob"):
+		repoPath, kind, rest = segments[:2], segments[2], segments[3:]
+	default:
+		// GitLab separates the project, which may be in nested groups, from
+		// the page with a "-" segment
+		for i := 2; i+2 < len(segments); i++ {
+			if segments[i] == "-" && (segments[i+1] == "tree" || segments[i+1] == "blob") {
+				repoPath, kind, rest = segments[:i], segments[i+1], segments[i+2:]
+				break
+			}
+		}
+	}
+	if repoPath == nil {
+		return "", "", "", false, nil
+	}
+	repo := u.Scheme + "://" + u.Host + "/" +
+		strings.TrimSuffix(strings.Join(repoPath, "/"), ".git")
+
+	refs, err := gitutil.RemoteRefs(repo, config)
+	if err != nil {
+		return "", "", "", false, err
+	}
+	known := map[string]bool{}
+	for _, r := range refs {
+		known[r] = true
+	}
+	// the longest matching ref, or else the first segment, e.g. a commit
+	n := 1
+	for i := len(rest); i > 1; i-- {
+		if known[strings.Join(rest[:i], "/")] {
+			n = i
+			break
+		}
+	}
+	ref := strings.Join(rest[:n], "/")
+	dir := path.Join(append([]string{"/"}, rest[n:]...)...)
+	if kind == "blob" {
+		dir = path.Dir(dir)
+	}
+	return repo, dir, ref, true, nil
+}
+
 // getURIAndVersion parses the repo+pkgURI and the version from v
 func getURIAndVersion(v string) (string, string, error) {
 	if strings.Count(v, "://") > 1 {
@@ -151,6 +263,19 @@ func getRepoAndPkg(v string) (string, string, error) {
 func getDest(v, repo, subdir string) (string, error) {
 	v = filepath.Clean(v)
 
+	// templated destinations are expanded and validated when the package is fetched
+	if get.IsDestinationTemplate(v) {
+		return v, nil
+	}
+
+	// packages matching a directory glob are fetched under the destination
+	if strings.ContainsAny(subdir, "*?[") {
+		if _, err := os.Stat(filepath.Dir(v)); os.IsNotExist(err) {
+			return "", errors.Errorf("parent directory %q does not exist", filepath.Dir(v))
+		}
+		return v, nil
+	}
+
 	f, err := os.Stat(v)
 	if os.IsNotExist(err) {
 		parent := filepath.Dir(v)
@@ -0,0 +1,325 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package pipe lets commands read resources from stdin and write them to
+// stdout, so kpt composes with tools such as kustomize, helm and kubectl in
+// unix pipelines.
+package pipe
+
+import (
+	"bytes"
+	"io"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"sigs.k8s.io/kustomize/kyaml/errors"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/kio/kioutil"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// Stdin is the argument naming stdin, or stdout, instead of a directory.
+const Stdin = "-"
+
+const (
+	// IndexAnnotation records the position of a resource read from stdin,
+	// so the resources are written in the order they were read.
+	IndexAnnotation = "config.kpt.dev/stdin-index"
+
+	// SynthesizedPathAnnotation marks the resources read from stdin whose
+	// path annotation was synthesized, so it isn't written to stdout.
+	SynthesizedPathAnnotation = "config.kpt.dev/stdin-path"
+)
+
+// sourcePrefix prefixes the comment helm template writes before each
+// resource, naming the template it was rendered from.
+const sourcePrefix = "# Source: "
+
+// Reader reads resources from a multi-document yaml stream, or a
+// ResourceList or List.  Resources without a path annotation are given the
+// path of the helm template they were rendered from, if any, and otherwise
+// a path derived from their kind, name and namespace -- e.g.
+// deployment_app.yaml.  The position of each resource is recorded, so
+// Writer writes them in the order they were read.
+type Reader struct {
+	// Reader is where the resources are read from.
+	Reader io.Reader
+
+	// wrapping is the ResourceList or List the resources were wrapped in.
+	wrapping kio.ByteReader
+}
+
+// Read implements kio.Reader.
+func (r *Reader) Read() ([]*yaml.RNode, error) {
+	r.wrapping = kio.ByteReader{Reader: r.Reader, OmitReaderAnnotations: true}
+	nodes, err := r.wrapping.Read()
+	if err != nil {
+		return nil, err
+	}
+	for i := range nodes {
+		if err := nodes[i].PipeE(
+			yaml.SetAnnotation(IndexAnnotation, strconv.Itoa(i))); err != nil {
+			return nil, err
+		}
+		m, err := nodes[i].GetMeta()
+		if err != nil {
+			return nil, err
+		}
+		if _, found := m.Annotations[kioutil.PathAnnotation]; found {
+			continue
+		}
+		p := sourcePath(nodes[i])
+		if p == "" {
+			p = kioutil.CreatePathAnnotationValue("", m)
+		}
+		if err := nodes[i].PipeE(yaml.SetAnnotation(kioutil.PathAnnotation, p)); err != nil {
+			return nil, err
+		}
+		if err := nodes[i].PipeE(
+			yaml.SetAnnotation(SynthesizedPathAnnotation, "true")); err != nil {
+			return nil, err
+		}
+	}
+	return nodes, nil
+}
+
+// sourcePath returns the path of the helm template node was rendered from,
+// or "" if it has no '# Source:' comment.
+func sourcePath(node *yaml.RNode) string {
+	comments := []string{node.YNode().HeadComment}
+	if c := node.YNode().Content; len(c) > 0 {
+		comments = append(comments, c[0].HeadComment)
+	}
+	for _, comment := range comments {
+		for _, line := range strings.Split(comment, "\n") {
+			if strings.HasPrefix(line, sourcePrefix) {
+				return path.Clean(strings.TrimSpace(strings.TrimPrefix(line, sourcePrefix)))
+			}
+		}
+	}
+	return ""
+}
+
+// Writer writes resources read by Reader to a yaml stream, in the order
+// they were read followed by any new resources, wrapped in the same
+// ResourceList or List as they were read from.  The annotations recorded by
+// Reader, and the path annotations it synthesized, are removed.
+type Writer struct {
+	// Writer is where the resources are written.
+	Writer io.Writer
+
+	// Reader is the reader the resources were read with, if any.
+	Reader *Reader
+}
+
+// Write implements kio.Writer.
+func (w Writer) Write(nodes []*yaml.RNode) error {
+	if err := Clean(nodes); err != nil {
+		return err
+	}
+	bw := kio.ByteWriter{Writer: w.Writer}
+	if w.Reader != nil {
+		bw.WrappingKind = w.Reader.wrapping.WrappingKind
+		bw.WrappingAPIVersion = w.Reader.wrapping.WrappingAPIVersion
+		bw.FunctionConfig = w.Reader.wrapping.FunctionConfig
+		bw.Results = w.Reader.wrapping.Results
+	}
+	return bw.Write(nodes)
+}
+
+// Clean sorts nodes in the order they were read by Reader, followed by any
+// new resources, and removes the annotations recorded by Reader and the
+// path annotations it synthesized.
+func Clean(nodes []*yaml.RNode) error {
+	index := make([]int, len(nodes))
+	for i := range nodes {
+		m, err := nodes[i].GetMeta()
+		if err != nil {
+			return err
+		}
+		index[i] = len(nodes)
+		if v, found := m.Annotations[IndexAnnotation]; found {
+			if n, err := strconv.Atoi(v); err == nil {
+				index[i] = n
+			}
+		}
+	}
+	if err := clearAnnotations(nodes, true); err != nil {
+		return err
+	}
+
+	sorted := make([]int, len(nodes))
+	for i := range sorted {
+		sorted[i] = i
+	}
+	sort.SliceStable(sorted, func(i, j int) bool { return index[sorted[i]] < index[sorted[j]] })
+	result := make([]*yaml.RNode, len(nodes))
+	for i, s := range sorted {
+		result[i] = nodes[s]
+	}
+	copy(nodes, result)
+	return nil
+}
+
+// clearAnnotations removes the annotations recorded by Reader, and the path
+// annotations it synthesized if paths is set.
+func clearAnnotations(nodes []*yaml.RNode, paths bool) error {
+	for i := range nodes {
+		m, err := nodes[i].GetMeta()
+		if err != nil {
+			return err
+		}
+		clear := []string{IndexAnnotation, SynthesizedPathAnnotation}
+		if paths && m.Annotations[SynthesizedPathAnnotation] == "true" {
+			clear = append(clear, kioutil.PathAnnotation)
+		}
+		for _, a := range clear {
+			if _, err := nodes[i].Pipe(yaml.ClearAnnotation(a)); err != nil {
+				return err
+			}
+		}
+		if err := yaml.ClearEmptyAnnotations(nodes[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Command configures how a command reads resources from stdin.
+type Command struct {
+	// Arg is the index of the directory argument which may be "-".
+	Arg int
+
+	// Omitted if set also reads stdin when the argument is omitted.
+	Omitted bool
+
+	// Sink if set reads stdin regardless of the argument, which is the
+	// directory the resources are written to.
+	Sink bool
+
+	// Text if set doesn't post-process the output, as it isn't resources.
+	Text bool
+
+	// TextFlag if set names a flag which, when set, makes the output text
+	// as with Text.
+	TextFlag string
+
+	// KeepArg if set passes "-" on to the command, which already reads
+	// stdin when given it.
+	KeepArg bool
+}
+
+// Wrap makes c read resources from stdin with Reader when its argument is
+// "-", and write its output with Writer.  c must read stdin and write
+// stdout when the argument is omitted.
+func (p Command) Wrap(c *cobra.Command) {
+	preRunE, runE := c.PreRunE, c.RunE
+	var reader *Reader
+	var out io.Writer
+	var output *bytes.Buffer
+
+	c.PreRunE = func(c *cobra.Command, args []string) error {
+		reader, output = nil, nil
+		stdin := p.stdin(args)
+		if stdin || p.Sink {
+			reader = &Reader{Reader: c.InOrStdin()}
+			nodes, err := reader.Read()
+			if err != nil {
+				return err
+			}
+			if !stdin {
+				// the resources are sunk to a directory, so only the
+				// synthesized paths are kept
+				if err := clearAnnotations(nodes, false); err != nil {
+					return err
+				}
+			}
+			input := &bytes.Buffer{}
+			err = kio.ByteWriter{
+				Writer:                input,
+				KeepReaderAnnotations: true,
+				WrappingKind:          reader.wrapping.WrappingKind,
+				WrappingAPIVersion:    reader.wrapping.WrappingAPIVersion,
+				FunctionConfig:        reader.wrapping.FunctionConfig,
+				Results:               reader.wrapping.Results,
+			}.Write(nodes)
+			if err != nil {
+				return errors.Wrap(err)
+			}
+			c.SetIn(input)
+		}
+		if stdin && !p.Text && (p.TextFlag == "" || !c.Flags().Changed(p.TextFlag)) {
+			out, output = c.OutOrStdout(), &bytes.Buffer{}
+			c.SetOut(output)
+		}
+		if stdin && len(args) > p.Arg && !p.KeepArg {
+			var err error
+			if args, err = dropArg(c, args, p.Arg); err != nil {
+				return err
+			}
+		}
+		if preRunE == nil {
+			return nil
+		}
+		return preRunE(c, args)
+	}
+
+	c.RunE = func(c *cobra.Command, args []string) error {
+		if p.stdin(args) && len(args) > p.Arg && !p.KeepArg {
+			args = append(args[:p.Arg:p.Arg], args[p.Arg+1:]...)
+		}
+		if err := runE(c, args); err != nil {
+			return err
+		}
+		if output == nil {
+			return nil
+		}
+		c.SetOut(out)
+		r := &kio.ByteReader{Reader: output, OmitReaderAnnotations: true}
+		nodes, err := r.Read()
+		if err != nil {
+			return err
+		}
+		return Writer{Writer: out, Reader: reader}.Write(nodes)
+	}
+}
+
+// stdin returns true if the command reads stdin, given its args.
+func (p Command) stdin(args []string) bool {
+	if len(args) > p.Arg {
+		return args[p.Arg] == Stdin
+	}
+	return p.Omitted || p.Sink
+}
+
+// dropArg removes the argument at index i, and reparses the flags so
+// arguments after "--" are still counted from the dash.
+func dropArg(c *cobra.Command, args []string, i int) ([]string, error) {
+	dash := c.ArgsLenAtDash()
+	result := append(args[:i:i], args[i+1:]...)
+	if dash <= i {
+		return result, nil
+	}
+	var reparse []string
+	reparse = append(reparse, result[:dash-1]...)
+	reparse = append(reparse, "--")
+	reparse = append(reparse, result[dash-1:]...)
+	if err := c.Flags().Parse(reparse); err != nil {
+		return nil, errors.Wrap(err)
+	}
+	return result, nil
+}
@@ -0,0 +1,179 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pipe_test
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt/internal/util/pipe"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/kustomize/cmd/config/configcobra"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/kio/kioutil"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+const input = `# Source: chart/templates/service.yaml
+apiVersion: v1
+kind: Service
+metadata:
+  name: svc
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+  namespace: prod
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cm
+  annotations:
+    config.kubernetes.io/path: config/cm.yaml
+`
+
+func TestReader(t *testing.T) {
+	nodes, err := (&pipe.Reader{Reader: strings.NewReader(input)}).Read()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	var paths []string
+	for i := range nodes {
+		m, err := nodes[i].GetMeta()
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		paths = append(paths, m.Annotations[kioutil.PathAnnotation])
+	}
+	assert.Equal(t, []string{
+		"chart/templates/service.yaml", "prod/deployment_app.yaml", "config/cm.yaml",
+	}, paths)
+}
+
+func TestWriter(t *testing.T) {
+	r := &pipe.Reader{Reader: strings.NewReader(`apiVersion: config.kubernetes.io/v1alpha1
+kind: ResourceList
+items:
+- apiVersion: v1
+  kind: Service
+  metadata:
+    name: a
+- apiVersion: v1
+  kind: Service
+  metadata:
+    name: b
+`)}
+	nodes, err := r.Read()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	// reorder the resources, and add one
+	added, err := yaml.Parse("apiVersion: v1\nkind: Service\nmetadata:\n  name: c\n")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	nodes = []*yaml.RNode{added, nodes[1], nodes[0]}
+
+	out := &bytes.Buffer{}
+	if !assert.NoError(t, pipe.Writer{Writer: out, Reader: r}.Write(nodes)) {
+		t.FailNow()
+	}
+	assert.Equal(t, `apiVersion: config.kubernetes.io/v1alpha1
+kind: ResourceList
+items:
+- apiVersion: v1
+  kind: Service
+  metadata:
+    name: a
+- apiVersion: v1
+  kind: Service
+  metadata:
+    name: b
+- apiVersion: v1
+  kind: Service
+  metadata:
+    name: c
+`, out.String())
+}
+
+func TestCommand_Wrap(t *testing.T) {
+	// cat a stream with "-", which writes the resources in order without
+	// their paths
+	cat := configcobra.Cat("kpt")
+	pipe.Command{Omitted: true}.Wrap(cat)
+	out := &bytes.Buffer{}
+	cat.SetArgs([]string{"-"})
+	cat.SetIn(strings.NewReader(input))
+	cat.SetOut(out)
+	if !assert.NoError(t, cat.Execute()) {
+		t.FailNow()
+	}
+	assert.Equal(t, strings.TrimSuffix(input,
+		"  annotations:\n    config.kubernetes.io/path: config/cm.yaml\n"), out.String())
+
+	// "-" is dropped from the args counted from "--"
+	var args, dataItems []string
+	c := &cobra.Command{
+		Use: "run",
+		PreRunE: func(c *cobra.Command, a []string) error {
+			args, dataItems = a[:c.ArgsLenAtDash()], a[c.ArgsLenAtDash():]
+			return nil
+		},
+		RunE: func(c *cobra.Command, a []string) error {
+			nodes, err := (&kio.ByteReader{Reader: c.InOrStdin()}).Read()
+			if err != nil {
+				return err
+			}
+			return kio.ByteWriter{Writer: c.OutOrStdout()}.Write(nodes)
+		},
+	}
+	pipe.Command{Omitted: true}.Wrap(c)
+	out = &bytes.Buffer{}
+	c.SetArgs([]string{"-", "--", "a=b"})
+	c.SetIn(strings.NewReader(input))
+	c.SetOut(out)
+	if !assert.NoError(t, c.Execute()) {
+		t.FailNow()
+	}
+	assert.Empty(t, args)
+	assert.Equal(t, []string{"a=b"}, dataItems)
+	assert.Equal(t, input, out.String())
+
+	// the output isn't post-processed when the text flag is set
+	var output string
+	c = &cobra.Command{
+		Use: "list",
+		RunE: func(c *cobra.Command, a []string) error {
+			_, err := fmt.Fprintf(c.OutOrStdout(), "[%s]\n", output)
+			return err
+		},
+	}
+	c.Flags().StringVar(&output, "output", "", "")
+	pipe.Command{Omitted: true, TextFlag: "output"}.Wrap(c)
+	out = &bytes.Buffer{}
+	c.SetArgs([]string{"-", "--output", "json"})
+	c.SetIn(strings.NewReader(input))
+	c.SetOut(out)
+	if !assert.NoError(t, c.Execute()) {
+		t.FailNow()
+	}
+	assert.Equal(t, "[json]\n", out.String())
+}
@@ -0,0 +1,307 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package pkgtree reports the hierarchy of packages under a directory, with
+// the upstream each package was fetched from and how it has drifted from it.
+package pkgtree
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/GoogleContainerTools/kpt/internal/gitutil"
+	"github.com/GoogleContainerTools/kpt/internal/util/concurrency"
+	"github.com/GoogleContainerTools/kpt/internal/util/outdated"
+	"github.com/GoogleContainerTools/kpt/internal/util/update"
+	"github.com/GoogleContainerTools/kpt/pkg/kptfile"
+	"sigs.k8s.io/kustomize/kyaml/errors"
+	"sigs.k8s.io/kustomize/kyaml/pathutil"
+)
+
+// Output formats.
+const (
+	TreeOutput  = "tree"
+	TableOutput = outdated.TableOutput
+	JSONOutput  = outdated.JSONOutput
+)
+
+// Package is a package in the tree.
+type Package struct {
+	// Package is the upstream state of the package.  Only Path is set for
+	// packages without an upstream.
+	outdated.Package
+
+	// Drifted is true if the package differs from the upstream commit it
+	// was fetched at, and nil if drift wasn't checked
+	Drifted *bool `json:"drifted,omitempty"`
+
+	// Modified are the files which differ from the upstream commit
+	Modified []string `json:"modified,omitempty"`
+
+	// DriftError is the error comparing the package to its upstream, if any
+	DriftError string `json:"driftError,omitempty"`
+
+	// Packages are the packages nested in the package
+	Packages []*Package `json:"packages,omitempty"`
+}
+
+// HasUpstream returns true if the package was fetched from an upstream.
+func (p *Package) HasUpstream() bool {
+	return p.Repo != ""
+}
+
+// Command reports the tree of packages under Dir.
+type Command struct {
+	// Dir is the directory to scan for packages
+	Dir string
+
+	// Output is the format of the report, TreeOutput, TableOutput or
+	// JSONOutput
+	Output string
+
+	// Drift if set compares each package to the upstream commit it was
+	// fetched at
+	Drift bool
+
+	// Concurrency is the number of upstreams queried at once
+	Concurrency int
+
+	// StdOut is where the report is written
+	StdOut io.Writer
+}
+
+// Run writes the report to StdOut.
+func (c Command) Run() error {
+	if c.Output == "" {
+		c.Output = TreeOutput
+	}
+	if c.Output != TreeOutput && c.Output != TableOutput && c.Output != JSONOutput {
+		return errors.Errorf("unsupported output %q, must be one of %s, %s, %s",
+			c.Output, TreeOutput, TableOutput, JSONOutput)
+	}
+	root, err := Build(c.Dir, c.Drift, c.Concurrency)
+	if err != nil {
+		return err
+	}
+	switch c.Output {
+	case JSONOutput:
+		e := json.NewEncoder(c.StdOut)
+		e.SetIndent("", "  ")
+		return errors.Wrap(e.Encode(root))
+	case TableOutput:
+		return WriteTable(c.StdOut, root)
+	default:
+		return WriteTree(c.StdOut, root)
+	}
+}
+
+// Build returns the tree of packages under dir.  The root is the package
+// at dir, or a package without an upstream if dir doesn't contain a Kptfile.
+// If drift is set each package is compared to its upstream commit.  Up to
+// limit upstreams are queried at once.
+func Build(dir string, drift bool, limit int) (*Package, error) {
+	paths, err := pathutil.DirsWithFile(dir, kptfile.KptFileName, true)
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	upstreams, err := outdated.Check(dir, limit)
+	if err != nil {
+		return nil, err
+	}
+	byPath := map[string]*Package{}
+	for i := range upstreams {
+		byPath[upstreams[i].Path] = &Package{Package: upstreams[i]}
+	}
+
+	root := &Package{Package: outdated.Package{Path: "."}}
+	var pkgs []*Package
+	for _, p := range paths {
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return nil, errors.Wrap(err)
+		}
+		rel = filepath.ToSlash(rel)
+		pkg, found := byPath[rel]
+		if !found {
+			pkg = &Package{Package: outdated.Package{Path: rel}}
+		}
+		if rel == "." {
+			root = pkg
+			continue
+		}
+		pkgs = append(pkgs, pkg)
+	}
+
+	// nest each package under the closest package containing it
+	sort.Slice(pkgs, func(i, j int) bool { return pkgs[i].Path < pkgs[j].Path })
+	byPath = map[string]*Package{".": root}
+	for _, pkg := range pkgs {
+		parent := root
+		for d := path.Dir(pkg.Path); d != "."; d = path.Dir(d) {
+			if p, found := byPath[d]; found {
+				parent = p
+				break
+			}
+		}
+		parent.Packages = append(parent.Packages, pkg)
+		byPath[pkg.Path] = pkg
+	}
+
+	if drift {
+		all := append([]*Package{root}, pkgs...)
+		concurrency.ForEach(limit, len(all), func(i int) error {
+			checkDrift(all[i], filepath.Join(dir, filepath.FromSlash(all[i].Path)))
+			return nil
+		})
+	}
+	return root, nil
+}
+
+// checkDrift compares the package at dir to its upstream commit, ignoring
+// its nested packages.
+func checkDrift(p *Package, dir string) {
+	if !p.HasUpstream() {
+		return
+	}
+	g := kptfile.Git{Repo: p.Repo, Directory: p.Directory, Ref: p.Ref, Commit: p.Commit}
+	if gitutil.IsRelativeRepo(g.Repo) {
+		var err error
+		g.Repo, g.Directory, err = gitutil.ResolveRelativeRepo(dir, g.Repo)
+		if err != nil {
+			p.DriftError = err.Error()
+			return
+		}
+	}
+	var nested []string
+	for _, n := range p.Packages {
+		rel, err := filepath.Rel(filepath.FromSlash(p.Path), filepath.FromSlash(n.Path))
+		if err != nil {
+			p.DriftError = err.Error()
+			return
+		}
+		nested = append(nested, rel)
+	}
+	modified, err := update.LocalChanges(g, dir, nested)
+	if err != nil {
+		p.DriftError = err.Error()
+		return
+	}
+	drifted := len(modified) > 0
+	p.Drifted, p.Modified = &drifted, modified
+}
+
+// Status summarizes the drift of the package from its upstream, and how far
+// it is behind it.
+func (p *Package) Status() string {
+	if !p.HasUpstream() {
+		return "local"
+	}
+	var status []string
+	switch {
+	case p.DriftError != "":
+		status = append(status, "drift unknown")
+	case len(p.Modified) == 1:
+		status = append(status, "1 file modified")
+	case len(p.Modified) > 0:
+		status = append(status, fmt.Sprintf("%d files modified", len(p.Modified)))
+	}
+	switch behind := p.Behind(); behind {
+	case "-":
+		status = append(status, "up to date")
+	case "unknown":
+		status = append(status, "upstream unknown")
+	default:
+		status = append(status, behind+" behind")
+	}
+	return strings.Join(status, ", ")
+}
+
+// upstream returns the upstream of the package as REPO/DIRECTORY@REF.
+func (p *Package) upstream() string {
+	if !p.HasUpstream() {
+		return "-"
+	}
+	repo := p.Repo
+	if d := strings.Trim(p.Directory, "/"); d != "" && d != "." {
+		repo = strings.TrimSuffix(repo, "/") + "/" + d
+	}
+	return repo + "@" + p.Ref
+}
+
+// WriteTree writes the packages as a tree to w.
+func WriteTree(w io.Writer, root *Package) error {
+	fmt.Fprintf(w, "%s\n", describe(root, root.Path))
+	var write func(pkgs []*Package, parent, indent string)
+	write = func(pkgs []*Package, parent, indent string) {
+		for i, p := range pkgs {
+			branch, next := "├── ", "│   "
+			if i == len(pkgs)-1 {
+				branch, next = "└── ", "    "
+			}
+			name := strings.TrimPrefix(p.Path, parent+"/")
+			fmt.Fprintf(w, "%s%s%s\n", indent, branch, describe(p, name))
+			write(p.Packages, p.Path, indent+next)
+		}
+	}
+	write(root.Packages, root.Path, "")
+	return nil
+}
+
+// describe describes the package on a line of the tree.
+func describe(p *Package, name string) string {
+	if !p.HasUpstream() {
+		return fmt.Sprintf("%s (%s)", name, p.Status())
+	}
+	return fmt.Sprintf("%s %s %s (%s)", name, p.upstream(), shortSha(p.Commit), p.Status())
+}
+
+// WriteTable writes a table of the packages to w.
+func WriteTable(w io.Writer, root *Package) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "PACKAGE\tUPSTREAM\tCOMMIT\tMODIFIED\tBEHIND")
+	var write func(p *Package)
+	write = func(p *Package) {
+		commit, modified, behind := "-", "-", "-"
+		if p.HasUpstream() {
+			commit = shortSha(p.Commit)
+			switch {
+			case p.DriftError != "":
+				modified = "unknown"
+			case p.Drifted != nil:
+				modified = fmt.Sprintf("%d", len(p.Modified))
+			}
+			behind = p.Behind()
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", p.Path, p.upstream(), commit, modified, behind)
+		for _, n := range p.Packages {
+			write(n)
+		}
+	}
+	write(root)
+	return errors.Wrap(tw.Flush())
+}
+
+// shortSha returns a shortened version of a commit SHA
+func shortSha(sha string) string {
+	if len(sha) > 7 {
+		return sha[:7]
+	}
+	return sha
+}
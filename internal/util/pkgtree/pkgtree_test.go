@@ -0,0 +1,113 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package pkgtree_test
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt/internal/testutil"
+	"github.com/GoogleContainerTools/kpt/internal/util/get"
+	. "github.com/GoogleContainerTools/kpt/internal/util/pkgtree"
+	"github.com/GoogleContainerTools/kpt/pkg/kptfile"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestBuild verifies that packages are nested under the closest package
+// containing them, and compared to their upstream commit.
+func TestBuild(t *testing.T) {
+	g, w, clean := testutil.SetupDefaultRepoAndWorkspace(t, testutil.Dataset1)
+	defer clean()
+	commit, err := g.GetCommit()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	dir := w.WorkspaceDirectory
+	// app is fetched before the package nested in it
+	for _, p := range []struct{ path, directory string }{
+		{"app", "/java"},
+		{filepath.Join("app", "db"), "/mysql"},
+		{filepath.Join("local", "blog"), "/wordpress"},
+	} {
+		err := get.Command{Destination: filepath.Join(dir, p.path),
+			Git: kptfile.Git{Repo: g.RepoDirectory, Directory: p.directory, Ref: "master"}}.Run()
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+	}
+	err = ioutil.WriteFile(filepath.Join(dir, "local", "Kptfile"), []byte(`apiVersion: kpt.dev/v1alpha1
+kind: Kptfile
+metadata:
+  name: local
+`), 0600)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	err = ioutil.WriteFile(filepath.Join(dir, "app", "java-configmap.resource.yaml"), []byte(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: modified
+`), 0600)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	root, err := Build(dir, true, 2)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	b := &bytes.Buffer{}
+	if !assert.NoError(t, WriteTree(b, root)) {
+		t.FailNow()
+	}
+	short := commit[:7]
+	assert.Equal(t, fmt.Sprintf(`. (local)
+├── app %[1]s/java@master %[2]s (1 file modified, up to date)
+│   └── db %[1]s/mysql@master %[2]s (up to date)
+└── local (local)
+    └── blog %[1]s/wordpress@master %[2]s (up to date)
+`, g.RepoDirectory, short), b.String())
+
+	app := root.Packages[0]
+	assert.Equal(t, []string{"java-configmap.resource.yaml"}, app.Modified)
+	if assert.NotNil(t, app.Drifted) {
+		assert.True(t, *app.Drifted)
+	}
+	if assert.NotNil(t, app.Packages[0].Drifted) {
+		assert.False(t, *app.Packages[0].Drifted)
+	}
+
+	b.Reset()
+	if !assert.NoError(t, WriteTable(b, root)) {
+		t.FailNow()
+	}
+	var rows [][]string
+	for _, line := range strings.Split(strings.TrimSpace(b.String()), "\n") {
+		rows = append(rows, strings.Fields(line))
+	}
+	assert.Equal(t, [][]string{
+		{"PACKAGE", "UPSTREAM", "COMMIT", "MODIFIED", "BEHIND"},
+		{".", "-", "-", "-", "-"},
+		{"app", g.RepoDirectory + "/java@master", short, "1", "-"},
+		{"app/db", g.RepoDirectory + "/mysql@master", short, "0", "-"},
+		{"local", "-", "-", "-", "-"},
+		{"local/blog", g.RepoDirectory + "/wordpress@master", short, "0", "-"},
+	}, rows)
+}
@@ -0,0 +1,75 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package progress
+
+import (
+	"bytes"
+	"regexp"
+	"strconv"
+)
+
+// gitProgress matches the progress lines written by git --progress, e.g.
+// "Receiving objects:  45% (450/1000), 1.20 MiB | 2.30 MiB/s"
+var gitProgress = regexp.MustCompile(
+	`Receiving objects:\s+\d+% \((\d+)/(\d+)\)(?:, ([\d.]+) (B|KiB|MiB|GiB|bytes))?`)
+
+var units = map[string]float64{
+	"B":     1,
+	"bytes": 1,
+	"KiB":   1 << 10,
+	"MiB":   1 << 20,
+	"GiB":   1 << 30,
+}
+
+// GitWriter parses the progress written by git to stderr and reports it.
+type GitWriter struct {
+	// Reporter receives the parsed progress
+	Reporter Reporter
+
+	// Event is the template for the reported events
+	Event Event
+
+	buf []byte
+}
+
+// Write parses complete progress lines from p.  git separates updates to
+// the same line with '\r'.
+func (w *GitWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexAny(w.buf, "\r\n")
+		if i < 0 {
+			break
+		}
+		w.parse(w.buf[:i])
+		w.buf = w.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+func (w *GitWriter) parse(line []byte) {
+	m := gitProgress.FindSubmatch(line)
+	if m == nil {
+		return
+	}
+	e := w.Event
+	e.Objects, _ = strconv.ParseInt(string(m[1]), 10, 64)
+	e.TotalObjects, _ = strconv.ParseInt(string(m[2]), 10, 64)
+	if len(m[3]) > 0 {
+		size, _ := strconv.ParseFloat(string(m[3]), 64)
+		e.Bytes = int64(size * units[string(m[4])])
+	}
+	w.Reporter.Report(e)
+}
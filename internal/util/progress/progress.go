@@ -0,0 +1,232 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package progress contains libraries for reporting the progress of
+// long running operations such as fetching packages.
+package progress
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/GoogleContainerTools/kpt/internal/util/tmputil"
+	"sigs.k8s.io/kustomize/kyaml/errors"
+)
+
+// Phase is a step of fetching a package.
+type Phase string
+
+const (
+	// ResolvingRef is reported while resolving the ref to fetch
+	ResolvingRef Phase = "resolving ref"
+
+	// Fetching is reported while fetching objects from the remote
+	Fetching Phase = "fetching"
+
+	// CheckingOut is reported while checking out the fetched ref
+	CheckingOut Phase = "checking out"
+
+	// Copying is reported while copying the package to its destination
+	Copying Phase = "copying"
+
+	// Done is reported once the package has been fetched
+	Done Phase = "done"
+
+	// Warning is reported for problems which don't stop the package from
+	// being fetched, e.g. an ambiguous ref
+	Warning Phase = "warning"
+)
+
+// Event is a single progress update.
+type Event struct {
+	// Phase is the current phase
+	Phase Phase `json:"phase"`
+
+	// Repo is the repo being fetched
+	Repo string `json:"repo,omitempty"`
+
+	// Ref is the ref being fetched
+	Ref string `json:"ref,omitempty"`
+
+	// Objects is the number of objects or files transferred so far
+	Objects int64 `json:"objects,omitempty"`
+
+	// TotalObjects is the total number of objects or files to transfer,
+	// if known
+	TotalObjects int64 `json:"totalObjects,omitempty"`
+
+	// Bytes is the number of bytes transferred so far
+	Bytes int64 `json:"bytes,omitempty"`
+
+	// Message is the text of a Warning
+	Message string `json:"message,omitempty"`
+}
+
+// Reporter receives progress updates.
+type Reporter interface {
+	Report(e Event)
+}
+
+// Formats are the supported progress formats.
+const (
+	FormatNone  = "none"
+	FormatPlain = "plain"
+	FormatTTY   = "tty"
+	FormatJSON  = "json"
+	FormatAuto  = "auto"
+)
+
+// Formats is the list of supported progress formats.
+var Formats = []string{FormatAuto, FormatNone, FormatPlain, FormatTTY, FormatJSON}
+
+// Discard is a Reporter which drops all updates.
+var Discard Reporter = discard{}
+
+type discard struct{}
+
+func (discard) Report(Event) {}
+
+// WarningReporter writes only warnings, dropping all other updates.
+type WarningReporter struct {
+	Writer io.Writer
+}
+
+func (r *WarningReporter) Report(e Event) {
+	if e.Phase == Warning {
+		fmt.Fprintln(r.Writer, describe(e))
+	}
+}
+
+// NewReporter returns a Reporter writing updates to w in the given format.
+// FormatAuto uses FormatTTY if w is a terminal, and FormatNone otherwise.
+// FormatNone still writes warnings.
+func NewReporter(format string, w io.Writer) (Reporter, error) {
+	switch format {
+	case FormatAuto, "":
+		if isTerminal(w) {
+			return &TTYReporter{Writer: w}, nil
+		}
+		return &WarningReporter{Writer: w}, nil
+	case FormatNone:
+		return &WarningReporter{Writer: w}, nil
+	case FormatPlain:
+		return &PlainReporter{Writer: w}, nil
+	case FormatTTY:
+		return &TTYReporter{Writer: w}, nil
+	case FormatJSON:
+		return &JSONReporter{Writer: w}, nil
+	}
+	return nil, errors.Errorf("unsupported progress format %q, must be one of: %s",
+		format, strings.Join(Formats, ","))
+}
+
+// isTerminal returns true if w is a character device.
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// PlainReporter writes a line each time the phase changes.
+type PlainReporter struct {
+	Writer io.Writer
+
+	mu    sync.Mutex
+	phase Phase
+}
+
+func (r *PlainReporter) Report(e Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if e.Phase == r.phase {
+		return
+	}
+	r.phase = e.Phase
+	fmt.Fprintln(r.Writer, describe(e))
+}
+
+// TTYReporter rewrites a single status line for every update.
+type TTYReporter struct {
+	Writer io.Writer
+
+	mu  sync.Mutex
+	len int
+}
+
+func (r *TTYReporter) Report(e Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	line := describe(e)
+	pad := ""
+	if n := r.len - len(line); n > 0 {
+		pad = strings.Repeat(" ", n)
+	}
+	r.len = len(line)
+	fmt.Fprintf(r.Writer, "\r%s%s", line, pad)
+	if e.Phase == Done || e.Phase == Warning {
+		fmt.Fprintln(r.Writer)
+		r.len = 0
+	}
+}
+
+// JSONReporter writes every update as a line of json.
+type JSONReporter struct {
+	Writer io.Writer
+
+	mu sync.Mutex
+}
+
+func (r *JSONReporter) Report(e Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	b, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	fmt.Fprintln(r.Writer, string(b))
+}
+
+// describe returns a human readable description of e.
+func describe(e Event) string {
+	if e.Phase == Warning {
+		return "warning: " + e.Message
+	}
+	s := string(e.Phase)
+	if e.Repo != "" {
+		s += " " + e.Repo
+	}
+	if e.Ref != "" {
+		s += "@" + e.Ref
+	}
+	switch {
+	case e.TotalObjects > 0:
+		s += fmt.Sprintf(" (%d/%d objects)", e.Objects, e.TotalObjects)
+	case e.Objects > 0:
+		s += fmt.Sprintf(" (%d objects)", e.Objects)
+	}
+	if e.Bytes > 0 {
+		s += ", " + tmputil.FormatBytes(e.Bytes)
+	}
+	return s
+}
@@ -0,0 +1,144 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package progress_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt/internal/util/progress"
+	"github.com/stretchr/testify/assert"
+)
+
+// recorder records the reported events
+type recorder struct {
+	events []progress.Event
+}
+
+func (r *recorder) Report(e progress.Event) {
+	r.events = append(r.events, e)
+}
+
+func TestGitWriter(t *testing.T) {
+	r := &recorder{}
+	w := &progress.GitWriter{
+		Reporter: r,
+		Event:    progress.Event{Phase: progress.Fetching, Repo: "foo"},
+	}
+	_, err := w.Write([]byte("remote: Counting objects:  50% (1/2)\rReceiving objects:  50% (1/2)\r" +
+		"Receiving objects: 100% (2/2), 1.50 MiB | 2.00 MiB/s, done.\nResolving"))
+	assert.NoError(t, err)
+	assert.Equal(t, []progress.Event{
+		{Phase: progress.Fetching, Repo: "foo", Objects: 1, TotalObjects: 2},
+		{Phase: progress.Fetching, Repo: "foo", Objects: 2, TotalObjects: 2, Bytes: 3 << 19},
+	}, r.events)
+}
+
+func TestNewReporter(t *testing.T) {
+	tests := []struct {
+		format   string
+		expected string
+	}{
+		{
+			format: progress.FormatPlain,
+			expected: `fetching foo@master
+done foo@master
+`,
+		},
+		{
+			format: progress.FormatTTY,
+			expected: "\rfetching foo@master\rfetching foo@master (1/2 objects), 1.0KiB" +
+				"\rdone foo@master" + strings.Repeat(" ", 26) + "\n",
+		},
+		{
+			format: progress.FormatJSON,
+			expected: `{"phase":"fetching","repo":"foo","ref":"master"}
+{"phase":"fetching","repo":"foo","ref":"master","objects":1,"totalObjects":2,"bytes":1024}
+{"phase":"done","repo":"foo","ref":"master"}
+`,
+		},
+		{
+			format: progress.FormatAuto,
+		},
+	}
+	for i := range tests {
+		test := tests[i]
+		t.Run(test.format, func(t *testing.T) {
+			b := &bytes.Buffer{}
+			r, err := progress.NewReporter(test.format, b)
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			r.Report(progress.Event{Phase: progress.Fetching, Repo: "foo", Ref: "master"})
+			r.Report(progress.Event{Phase: progress.Fetching, Repo: "foo", Ref: "master",
+				Objects: 1, TotalObjects: 2, Bytes: 1024})
+			r.Report(progress.Event{Phase: progress.Done, Repo: "foo", Ref: "master"})
+			assert.Equal(t, test.expected, b.String())
+		})
+	}
+
+	_, err := progress.NewReporter("foo", &bytes.Buffer{})
+	assert.EqualError(t, err, "unsupported progress format \"foo\", must be one of: auto,none,plain,tty,json")
+}
+
+// TestNewReporter_warning verifies that warnings are written by every format,
+// including the ones which don't report progress.
+func TestNewReporter_warning(t *testing.T) {
+	tests := []struct {
+		format   string
+		expected string
+	}{
+		{
+			format: progress.FormatNone,
+			expected: `warning: ref "v1" is ambiguous
+`,
+		},
+		{
+			format: progress.FormatAuto,
+			expected: `warning: ref "v1" is ambiguous
+`,
+		},
+		{
+			format: progress.FormatPlain,
+			expected: `fetching foo@v1
+warning: ref "v1" is ambiguous
+done foo@v1
+`,
+		},
+		{
+			format: progress.FormatJSON,
+			expected: `{"phase":"fetching","repo":"foo","ref":"v1"}
+{"phase":"warning","repo":"foo","ref":"v1","message":"ref \"v1\" is ambiguous"}
+{"phase":"done","repo":"foo","ref":"v1"}
+`,
+		},
+	}
+	for i := range tests {
+		test := tests[i]
+		t.Run(test.format, func(t *testing.T) {
+			b := &bytes.Buffer{}
+			r, err := progress.NewReporter(test.format, b)
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			r.Report(progress.Event{Phase: progress.Fetching, Repo: "foo", Ref: "v1"})
+			r.Report(progress.Event{Phase: progress.Warning, Repo: "foo", Ref: "v1",
+				Message: `ref "v1" is ambiguous`})
+			r.Report(progress.Event{Phase: progress.Done, Repo: "foo", Ref: "v1"})
+			assert.Equal(t, test.expected, b.String())
+		})
+	}
+}
@@ -0,0 +1,343 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package publish tags package versions in git and optionally pushes them
+// to an OCI registry.
+package publish
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/GoogleContainerTools/kpt/internal/gitutil"
+	"github.com/GoogleContainerTools/kpt/internal/util/cmdutil"
+	"github.com/GoogleContainerTools/kpt/internal/util/digest"
+	"github.com/GoogleContainerTools/kpt/internal/util/semver"
+	"github.com/GoogleContainerTools/kpt/internal/util/tmputil"
+	"github.com/GoogleContainerTools/kpt/pkg/kptfile/kptfileutil"
+	"sigs.k8s.io/kustomize/kyaml/errors"
+)
+
+const (
+	// ArtifactType is the OCI artifact type of published packages.
+	ArtifactType = "application/vnd.kpt.package.v1"
+
+	// LayerMediaType is the media type of the package contents layer.
+	LayerMediaType = "application/vnd.kpt.package.layer.v1.tar+gzip"
+
+	// ContentDigestAnnotation records the digest of the package contents.
+	ContentDigestAnnotation = "dev.kpt.package.content-digest"
+
+	// KptVersionAnnotation records the kpt version which published the package.
+	KptVersionAnnotation = "dev.kpt.package.kpt-version"
+)
+
+// Command publishes a version of a package.
+type Command struct {
+	// Path is the local package directory, inside a git repo.
+	Path string
+
+	// Version is the semantic version to publish.
+	Version string
+
+	// Remote is the git remote to push the tag to.  Defaults to origin.
+	Remote string
+
+	// TagTemplate names the tag of the version, see gitutil.PackageTag.
+	// Defaults to the directory of the package joined with the version.
+	TagTemplate string
+
+	// NoPush creates the tag without pushing it.
+	NoPush bool
+
+	// Message is prepended to the tag message.
+	Message string
+
+	// OCI is the repository to push the package to as an OCI artifact,
+	// e.g. gcr.io/project/pkg.  The version is used as the OCI tag if
+	// the reference has none.
+	OCI string
+
+	// OrasBinary is the oras binary used to push OCI artifacts.
+	// Defaults to oras.
+	OrasBinary string
+
+	// Output is where results are printed.  Defaults to stdout.
+	Output io.Writer
+}
+
+// Provenance describes where a published package version came from.
+type Provenance struct {
+	// Tag is the git tag of the version.
+	Tag string
+
+	// Commit is the source commit the tag points to.
+	Commit string
+
+	// KptVersion is the version of kpt which published the package.
+	KptVersion string
+
+	// ContentDigest is the digest of the package contents, see digest.Dir.
+	ContentDigest string
+}
+
+// String returns the provenance formatted for a tag message.
+func (p Provenance) String() string {
+	return fmt.Sprintf("source-commit: %s\nkpt-version: %s\ncontent-digest: %s\n",
+		p.Commit, p.KptVersion, p.ContentDigest)
+}
+
+// Run publishes the package version.
+func (c Command) Run() (Provenance, error) {
+	if c.Remote == "" {
+		c.Remote = "origin"
+	}
+	if c.Output == nil {
+		c.Output = os.Stdout
+	}
+	var p Provenance
+	if _, err := semver.Parse(c.Version); err != nil {
+		return p, err
+	}
+	k, err := kptfileutil.ReadFile(c.Path)
+	if err != nil {
+		return p, errors.WrapPrefixf(err, "%s is not a package", c.Path)
+	}
+	if v := k.PackageMeta.Version; v != "" && v != c.Version {
+		return p, errors.Errorf(
+			"the Kptfile packageMetadata.version is %q, not %q", v, c.Version)
+	}
+
+	g := gitutil.NewLocalGitRunner(c.Path)
+	if err := c.git(g, "status", "--porcelain", "."); err != nil {
+		return p, err
+	}
+	if strings.TrimSpace(g.Stdout.String()) != "" {
+		return p, errors.Errorf(
+			"%s has uncommitted changes, commit them before publishing", c.Path)
+	}
+	root, rel, err := c.repoPath(g)
+	if err != nil {
+		return p, err
+	}
+	// tag subdirectories with the tag template, e.g. with the directory as
+	// a prefix, so that `kpt pkg get` resolves them independently
+	p.Tag, err = gitutil.PackageTag(c.TagTemplate, filepath.ToSlash(rel), c.Version)
+	if err != nil {
+		return p, err
+	}
+	if p.Tag == "" {
+		p.Tag = c.Version
+	}
+	if err := c.checkTag(g, p.Tag); err != nil {
+		return p, err
+	}
+	if err := c.git(g, "rev-parse", "HEAD"); err != nil {
+		return p, err
+	}
+	p.Commit = strings.TrimSpace(g.Stdout.String())
+	p.KptVersion = cmdutil.Version
+
+	tmp, err := tmputil.TempDir("kpt-publish")
+	if err != nil {
+		return p, err
+	}
+	defer os.RemoveAll(tmp)
+	archive := filepath.Join(tmp, "package.tar.gz")
+	tree := "HEAD"
+	if rel != "." {
+		tree = "HEAD:" + rel
+	}
+	// archive from the root, archives from subdirectories are restricted to
+	// the working directory
+	if err := c.git(g, "-C", root, "archive", "--format=tar.gz", "-o", archive, tree); err != nil {
+		return p, err
+	}
+	contents := filepath.Join(tmp, "contents")
+	if err := extract(archive, contents); err != nil {
+		return p, err
+	}
+	d, err := digest.Dir(contents)
+	if err != nil {
+		return p, err
+	}
+	p.ContentDigest = "sha256:" + d
+
+	msg := fmt.Sprintf("%s\n\n%s", c.Version, p)
+	if c.Message != "" {
+		msg = fmt.Sprintf("%s\n\n%s", c.Message, p)
+	}
+	if err := c.git(g, "tag", "-a", p.Tag, "-m", msg); err != nil {
+		return p, err
+	}
+	fmt.Fprintf(c.Output, "created tag %s at %s\n", p.Tag, p.Commit)
+
+	if c.OCI != "" {
+		ref, err := c.pushOCI(g, tmp, p)
+		if err != nil {
+			// leave no tag behind so the publish can be retried
+			_ = c.git(g, "tag", "-d", p.Tag)
+			return p, err
+		}
+		fmt.Fprintf(c.Output, "pushed %s\n", ref)
+	}
+	if c.NoPush {
+		return p, nil
+	}
+	if err := c.git(g, "push", c.Remote, "refs/tags/"+p.Tag); err != nil {
+		return p, err
+	}
+	fmt.Fprintf(c.Output, "pushed tag %s to %s\n", p.Tag, c.Remote)
+	return p, nil
+}
+
+// repoPath returns the root of the package's git repo, and the package path
+// relative to it.
+func (c Command) repoPath(g *gitutil.GitRunner) (string, string, error) {
+	if err := c.git(g, "rev-parse", "--show-toplevel"); err != nil {
+		return "", "", err
+	}
+	root, err := filepath.EvalSymlinks(strings.TrimSpace(g.Stdout.String()))
+	if err != nil {
+		return "", "", errors.Wrap(err)
+	}
+	dir, err := filepath.Abs(c.Path)
+	if err != nil {
+		return "", "", errors.Wrap(err)
+	}
+	if dir, err = filepath.EvalSymlinks(dir); err != nil {
+		return "", "", errors.Wrap(err)
+	}
+	rel, err := filepath.Rel(root, dir)
+	if err != nil {
+		return "", "", errors.Wrap(err)
+	}
+	return root, filepath.ToSlash(rel), nil
+}
+
+// checkTag returns an error if tag already exists locally or on the remote.
+func (c Command) checkTag(g *gitutil.GitRunner, tag string) error {
+	if err := g.Run("rev-parse", "-q", "--verify", "refs/tags/"+tag); err == nil {
+		return errors.Errorf("tag %s already exists", tag)
+	}
+	if c.NoPush {
+		return nil
+	}
+	if err := c.git(g, "ls-remote", "--tags", c.Remote, "refs/tags/"+tag); err != nil {
+		return err
+	}
+	if strings.TrimSpace(g.Stdout.String()) != "" {
+		return errors.Errorf("tag %s already exists on %s", tag, c.Remote)
+	}
+	return nil
+}
+
+// pushOCI pushes the package archive in dir to the OCI repository and
+// returns the pushed reference.
+func (c Command) pushOCI(g *gitutil.GitRunner, dir string, p Provenance) (string, error) {
+	ref := c.OCI
+	if i := strings.LastIndex(ref, "/"); !strings.Contains(ref[i+1:], ":") &&
+		!strings.Contains(ref, "@") {
+		// OCI tags may not contain '+'
+		ref += ":" + strings.Replace(c.Version, "+", "_", -1)
+	}
+	args := []string{"push", ref, "--artifact-type", ArtifactType,
+		"--annotation", "org.opencontainers.image.revision=" + p.Commit,
+		"--annotation", "org.opencontainers.image.version=" + c.Version,
+		"--annotation", ContentDigestAnnotation + "=" + p.ContentDigest,
+		"--annotation", KptVersionAnnotation + "=" + p.KptVersion,
+	}
+	if err := g.Run("remote", "get-url", c.Remote); err == nil {
+		args = append(args, "--annotation",
+			"org.opencontainers.image.source="+strings.TrimSpace(g.Stdout.String()))
+	}
+	args = append(args, "package.tar.gz:"+LayerMediaType)
+
+	binary := c.OrasBinary
+	if binary == "" {
+		binary = "oras"
+	}
+	cmd := exec.Command(binary, args...)
+	cmd.Dir = dir
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		return "", errors.Errorf("failed to push %s: %v\n%s", ref, err, stderr.String())
+	}
+	return ref, nil
+}
+
+func (c Command) git(g *gitutil.GitRunner, args ...string) error {
+	if err := g.Run(args...); err != nil {
+		return errors.Errorf("git %s failed: %v\n%s",
+			strings.Join(args, " "), err, g.Stderr.String())
+	}
+	return nil
+}
+
+// extract extracts the tar.gz archive into dir.
+func extract(archive, dir string) error {
+	f, err := os.Open(archive)
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	r := tar.NewReader(gz)
+	for {
+		h, err := r.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return errors.Wrap(err)
+		}
+		p := filepath.Join(dir, filepath.FromSlash(h.Name))
+		if !strings.HasPrefix(p, filepath.Clean(dir)+string(filepath.Separator)) {
+			return errors.Errorf("invalid path %q in archive", h.Name)
+		}
+		switch h.Typeflag {
+		case tar.TypeDir:
+			err = os.MkdirAll(p, 0700)
+		case tar.TypeReg:
+			err = writeFile(p, r, os.FileMode(h.Mode))
+		}
+		if err != nil {
+			return errors.Wrap(err)
+		}
+	}
+}
+
+func writeFile(p string, r io.Reader, mode os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(p), 0700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(p, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(f, r)
+	return err
+}
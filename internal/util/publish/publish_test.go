@@ -0,0 +1,154 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package publish_test
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt/internal/gitutil"
+	"github.com/GoogleContainerTools/kpt/internal/util/publish"
+	"github.com/stretchr/testify/assert"
+)
+
+// setupRepo returns a clone of a bare repo containing a package in the pkg
+// directory.
+func setupRepo(t *testing.T) (string, string) {
+	remote, err := ioutil.TempDir("", "kpt-publish-remote")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	local, err := ioutil.TempDir("", "kpt-publish-local")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	pkg := filepath.Join(local, "pkg")
+	if !assert.NoError(t, os.MkdirAll(pkg, 0700)) {
+		t.FailNow()
+	}
+	if !assert.NoError(t, ioutil.WriteFile(filepath.Join(pkg, "Kptfile"), []byte(`apiVersion: kpt.dev/v1alpha1
+kind: Kptfile
+metadata:
+  name: pkg
+`), 0600)) {
+		t.FailNow()
+	}
+	for _, args := range [][]string{
+		{"init", "--bare", remote},
+		{"init", local},
+		{"-C", local, "add", "."},
+		{"-C", local, "commit", "-m", "init"},
+		{"-C", local, "remote", "add", "origin", remote},
+		{"-C", local, "push", "origin", "HEAD"},
+	} {
+		g := gitutil.NewLocalGitRunner(local)
+		if !assert.NoError(t, g.Run(args...), g.Stderr.String()) {
+			t.FailNow()
+		}
+	}
+	return remote, local
+}
+
+func TestCommand_Run(t *testing.T) {
+	remote, local := setupRepo(t)
+	defer os.RemoveAll(remote)
+	defer os.RemoveAll(local)
+
+	out := &bytes.Buffer{}
+	p, err := publish.Command{
+		Path:    filepath.Join(local, "pkg"),
+		Version: "v1.0.0",
+		Output:  out,
+	}.Run()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, "pkg/v1.0.0", p.Tag)
+	assert.True(t, strings.HasPrefix(p.ContentDigest, "sha256:"))
+	assert.Contains(t, out.String(), "pushed tag pkg/v1.0.0 to origin")
+
+	// the tag is pushed with the provenance in its message
+	g := gitutil.NewLocalGitRunner(remote)
+	if !assert.NoError(t, g.Run("tag", "-l", "--format=%(contents)", "pkg/v1.0.0")) {
+		t.FailNow()
+	}
+	assert.Equal(t, fmt.Sprintf("v1.0.0\n\n%s", p), strings.TrimRight(g.Stdout.String(), "\n")+"\n")
+
+	// the version can't be published twice
+	_, err = publish.Command{Path: filepath.Join(local, "pkg"), Version: "v1.0.0"}.Run()
+	assert.EqualError(t, err, "tag pkg/v1.0.0 already exists")
+}
+
+func TestCommand_Run_dirty(t *testing.T) {
+	remote, local := setupRepo(t)
+	defer os.RemoveAll(remote)
+	defer os.RemoveAll(local)
+
+	pkg := filepath.Join(local, "pkg")
+	if !assert.NoError(t, ioutil.WriteFile(filepath.Join(pkg, "a.yaml"), []byte("a: b\n"), 0600)) {
+		t.FailNow()
+	}
+	_, err := publish.Command{Path: pkg, Version: "v1.0.0"}.Run()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "has uncommitted changes")
+	}
+}
+
+func TestCommand_Run_oci(t *testing.T) {
+	remote, local := setupRepo(t)
+	defer os.RemoveAll(remote)
+	defer os.RemoveAll(local)
+
+	oras := filepath.Join(local, "oras")
+	if !assert.NoError(t, ioutil.WriteFile(oras, []byte(fmt.Sprintf(`#!/bin/sh
+echo "$@" > %[1]s/args
+test -f package.tar.gz
+`, local)), 0700)) {
+		t.FailNow()
+	}
+
+	p, err := publish.Command{
+		Path:       filepath.Join(local, "pkg"),
+		Version:    "v1.0.0+build.1",
+		NoPush:     true,
+		OCI:        "gcr.io/project/pkg",
+		OrasBinary: oras,
+		Output:     ioutil.Discard,
+	}.Run()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	b, err := ioutil.ReadFile(filepath.Join(local, "args"))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	args := string(b)
+	assert.True(t, strings.HasPrefix(args, "push gcr.io/project/pkg:v1.0.0_build.1 "), args)
+	assert.Contains(t, args, "org.opencontainers.image.revision="+p.Commit)
+	assert.Contains(t, args, publish.ContentDigestAnnotation+"="+p.ContentDigest)
+	assert.Contains(t, args, "package.tar.gz:"+publish.LayerMediaType)
+
+	// the tag was created but not pushed
+	g := gitutil.NewLocalGitRunner(remote)
+	if !assert.NoError(t, g.Run("tag", "-l")) {
+		t.FailNow()
+	}
+	assert.Empty(t, g.Stdout.String())
+}
@@ -0,0 +1,318 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package rbac generates the RBAC permissions required to apply a package.
+package rbac
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/GoogleContainerTools/kpt/pkg/kptfile"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/cli-utils/pkg/common"
+	"sigs.k8s.io/kustomize/kyaml/errors"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// ApplyVerbs are the verbs required to apply, prune and wait for resources.
+var ApplyVerbs = []string{"get", "list", "watch", "create", "update", "patch", "delete"}
+
+// InventoryVerbs are the verbs required to read and write the ConfigMap
+// inventory object created from the inventory template of the package.
+var InventoryVerbs = []string{"get", "list", "create", "update", "patch", "delete"}
+
+// Mapper returns the resource name for gvk, and whether it is namespaced.
+type Mapper func(gvk schema.GroupVersionKind) (resource string, namespaced bool, err error)
+
+// Generator generates the Roles, ClusterRoles and bindings a service account
+// needs to apply a package with kpt live apply.
+type Generator struct {
+	// Name is the name of the generated roles and bindings
+	Name string
+
+	// ServiceAccount is the name of the service account to bind the roles to
+	ServiceAccount string
+
+	// ServiceAccountNamespace is the namespace of the service account
+	ServiceAccountNamespace string
+
+	// DefaultNamespace is the namespace namespaced resources without a
+	// namespace are applied to
+	DefaultNamespace string
+
+	// Mapper maps resource kinds to resource names.  Optional -- kinds which
+	// it can't map are defaulted from the CustomResourceDefinitions in the
+	// package, or from the kind.
+	Mapper Mapper
+}
+
+// scope is the namespace rules apply to, or "" for cluster-scoped rules
+type scope string
+
+// rules are the resources in each group
+type rules map[string]map[string]bool
+
+func (r rules) add(group, resource string) {
+	if r[group] == nil {
+		r[group] = map[string]bool{}
+	}
+	r[group][resource] = true
+}
+
+// crdName is the resource name and scope defined by a package CRD
+type crdName struct {
+	plural     string
+	namespaced bool
+}
+
+// Generate returns the RBAC resources required to apply nodes and the
+// inventory declared by kf, which may be nil.
+func (g Generator) Generate(nodes []*yaml.RNode, kf *kptfile.KptFile) ([]*yaml.RNode, error) {
+	crds, err := crdNames(nodes)
+	if err != nil {
+		return nil, err
+	}
+
+	scopes := map[scope]rules{}
+	add := func(ns scope, group, resource string) {
+		if scopes[ns] == nil {
+			scopes[ns] = rules{}
+		}
+		scopes[ns].add(group, resource)
+	}
+	// the namespaces of the inventory templates, whose ConfigMaps are
+	// written by every apply
+	inventories := map[scope]bool{}
+
+	for i := range nodes {
+		meta, err := nodes[i].GetMeta()
+		if err != nil {
+			return nil, err
+		}
+		if isInventoryTemplate(meta) {
+			ns := meta.Namespace
+			if ns == "" {
+				ns = g.DefaultNamespace
+			}
+			inventories[scope(ns)] = true
+			continue
+		}
+		gvk := schema.FromAPIVersionAndKind(meta.APIVersion, meta.Kind)
+		resource, namespaced := g.resourceFor(gvk, meta.Namespace != "", crds)
+		if !namespaced {
+			add("", gvk.Group, resource)
+			continue
+		}
+		ns := meta.Namespace
+		if ns == "" {
+			ns = g.DefaultNamespace
+		}
+		add(scope(ns), gvk.Group, resource)
+	}
+
+	// the inventory object is read and written by every apply
+	if kf != nil && kf.Inventory != nil && kf.Inventory.Namespace != "" {
+		add(scope(kf.Inventory.Namespace), "kpt.dev", "resourcegroups")
+	}
+
+	var out []*yaml.RNode
+	var names []string
+	for ns := range scopes {
+		names = append(names, string(ns))
+	}
+	for ns := range inventories {
+		if scopes[ns] == nil {
+			names = append(names, string(ns))
+		}
+	}
+	sort.Strings(names)
+	for _, ns := range names {
+		var inventory rules
+		if inventories[scope(ns)] && !scopes[scope(ns)][""]["configmaps"] {
+			inventory = rules{"": {"configmaps": true}}
+		}
+		role, binding, err := g.roleAndBinding(ns, scopes[scope(ns)], inventory)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, role, binding)
+	}
+	return out, nil
+}
+
+// isInventoryTemplate returns true if meta is the ConfigMap inventory
+// template created by kpt live init.  kpt live apply replaces it with the
+// inventory object rather than applying it.
+func isInventoryTemplate(meta yaml.ResourceMeta) bool {
+	_, found := meta.Labels[common.InventoryLabel]
+	return found && meta.APIVersion == "v1" && meta.Kind == "ConfigMap"
+}
+
+// resourceFor returns the resource name of gvk and whether it's namespaced.
+func (g Generator) resourceFor(gvk schema.GroupVersionKind, hasNamespace bool,
+	crds map[schema.GroupKind]crdName) (string, bool) {
+	if g.Mapper != nil {
+		if resource, namespaced, err := g.Mapper(gvk); err == nil {
+			return resource, namespaced
+		}
+	}
+	if crd, found := crds[gvk.GroupKind()]; found {
+		return crd.plural, crd.namespaced
+	}
+	return plural(gvk.Kind), hasNamespace || !clusterScopedKinds[gvk.Kind]
+}
+
+// clusterScopedKinds are the built-in kinds which aren't namespaced.  Other
+// kinds are assumed to be namespaced if they can't be mapped.
+var clusterScopedKinds = map[string]bool{
+	"APIService":                     true,
+	"CSIDriver":                      true,
+	"CSINode":                        true,
+	"CertificateSigningRequest":      true,
+	"ClusterRole":                    true,
+	"ClusterRoleBinding":             true,
+	"CustomResourceDefinition":       true,
+	"IngressClass":                   true,
+	"MutatingWebhookConfiguration":   true,
+	"Namespace":                      true,
+	"Node":                           true,
+	"PersistentVolume":               true,
+	"PodSecurityPolicy":              true,
+	"PriorityClass":                  true,
+	"RuntimeClass":                   true,
+	"StorageClass":                   true,
+	"ValidatingWebhookConfiguration": true,
+	"VolumeAttachment":               true,
+}
+
+// roleAndBinding returns the role granting rules, and inventory with the
+// InventoryVerbs, and its binding to the service account.  ns is the
+// namespace of the role, or "" for a ClusterRole.
+func (g Generator) roleAndBinding(ns string, r, inventory rules) (*yaml.RNode, *yaml.RNode, error) {
+	roleKind, bindingKind := "Role", "RoleBinding"
+	meta := map[string]interface{}{"name": g.Name, "namespace": ns}
+	if ns == "" {
+		roleKind, bindingKind = "ClusterRole", "ClusterRoleBinding"
+		meta = map[string]interface{}{"name": g.Name}
+	}
+
+	ruleList := append(policyRules(r, ApplyVerbs), policyRules(inventory, InventoryVerbs)...)
+
+	role, err := yaml.FromMap(map[string]interface{}{
+		"apiVersion": "rbac.authorization.k8s.io/v1",
+		"kind":       roleKind,
+		"metadata":   meta,
+		"rules":      ruleList,
+	})
+	if err != nil {
+		return nil, nil, errors.Wrap(err)
+	}
+	binding, err := yaml.FromMap(map[string]interface{}{
+		"apiVersion": "rbac.authorization.k8s.io/v1",
+		"kind":       bindingKind,
+		"metadata":   meta,
+		"roleRef": map[string]interface{}{
+			"apiGroup": "rbac.authorization.k8s.io",
+			"kind":     roleKind,
+			"name":     g.Name,
+		},
+		"subjects": []interface{}{map[string]interface{}{
+			"kind":      "ServiceAccount",
+			"name":      g.ServiceAccount,
+			"namespace": g.ServiceAccountNamespace,
+		}},
+	})
+	if err != nil {
+		return nil, nil, errors.Wrap(err)
+	}
+	return role, binding, nil
+}
+
+// policyRules returns the rules granting verbs on the resources of r.
+func policyRules(r rules, verbs []string) []interface{} {
+	var groups []string
+	for group := range r {
+		groups = append(groups, group)
+	}
+	sort.Strings(groups)
+	var ruleList []interface{}
+	for _, group := range groups {
+		var resources []interface{}
+		for _, resource := range sortedKeys(r[group]) {
+			resources = append(resources, resource)
+		}
+		var v []interface{}
+		for _, verb := range verbs {
+			v = append(v, verb)
+		}
+		ruleList = append(ruleList, map[string]interface{}{
+			"apiGroups": []interface{}{group},
+			"resources": resources,
+			"verbs":     v,
+		})
+	}
+	return ruleList
+}
+
+// crdNames returns the names of the resources defined by CRDs in nodes, so
+// that custom resources can be mapped before the CRDs are installed.
+func crdNames(nodes []*yaml.RNode) (map[schema.GroupKind]crdName, error) {
+	crds := map[schema.GroupKind]crdName{}
+	for i := range nodes {
+		meta, err := nodes[i].GetMeta()
+		if err != nil {
+			return nil, err
+		}
+		if meta.Kind != "CustomResourceDefinition" ||
+			!strings.HasPrefix(meta.APIVersion, "apiextensions.k8s.io/") {
+			continue
+		}
+		var values []string
+		for _, field := range []string{"group", "names.kind", "names.plural", "scope"} {
+			n, err := nodes[i].Pipe(yaml.Lookup(append([]string{"spec"},
+				strings.Split(field, ".")...)...))
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, yaml.GetValue(n))
+		}
+		crds[schema.GroupKind{Group: values[0], Kind: values[1]}] = crdName{
+			plural:     values[2],
+			namespaced: values[3] != "Cluster",
+		}
+	}
+	return crds, nil
+}
+
+// plural guesses the resource name for kind.
+func plural(kind string) string {
+	r := strings.ToLower(kind)
+	switch {
+	case strings.HasSuffix(r, "s"), strings.HasSuffix(r, "x"), strings.HasSuffix(r, "ch"):
+		return r + "es"
+	case len(r) > 1 && strings.HasSuffix(r, "y") && !strings.ContainsAny(r[len(r)-2:len(r)-1], "aeiou"):
+		return r[:len(r)-1] + "ies"
+	}
+	return r + "s"
+}
+
+func sortedKeys(m map[string]bool) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
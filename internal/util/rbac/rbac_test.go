@@ -0,0 +1,273 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rbac_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt/internal/util/rbac"
+	"github.com/GoogleContainerTools/kpt/pkg/kptfile"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+)
+
+func TestGenerator_Generate(t *testing.T) {
+	input := `
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+  namespace: prod
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: app
+---
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: prod
+---
+apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: widgets.example.com
+spec:
+  group: example.com
+  names:
+    kind: Widget
+    plural: widgetz
+  scope: Namespaced
+---
+apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: w
+  namespace: prod
+`
+	nodes, err := kio.FromBytes([]byte(input))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	kf := &kptfile.KptFile{Inventory: &kptfile.Inventory{Namespace: "prod", Name: "inv"}}
+
+	out, err := rbac.Generator{
+		Name:                    "deployer",
+		ServiceAccount:          "sa",
+		ServiceAccountNamespace: "ci",
+		DefaultNamespace:        "default",
+	}.Generate(nodes, kf)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	b := &bytes.Buffer{}
+	if !assert.NoError(t, kio.ByteWriter{Writer: b}.Write(out)) {
+		t.FailNow()
+	}
+	verbs := `
+  verbs:
+  - get
+  - list
+  - watch
+  - create
+  - update
+  - patch
+  - delete`
+	expected := `apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: deployer
+rules:
+- apiGroups:
+  - ""
+  resources:
+  - namespaces` + verbs + `
+- apiGroups:
+  - apiextensions.k8s.io
+  resources:
+  - customresourcedefinitions` + verbs + `
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRoleBinding
+metadata:
+  name: deployer
+roleRef:
+  apiGroup: rbac.authorization.k8s.io
+  kind: ClusterRole
+  name: deployer
+subjects:
+- kind: ServiceAccount
+  name: sa
+  namespace: ci
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: Role
+metadata:
+  name: deployer
+  namespace: default
+rules:
+- apiGroups:
+  - ""
+  resources:
+  - services` + verbs + `
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: RoleBinding
+metadata:
+  name: deployer
+  namespace: default
+roleRef:
+  apiGroup: rbac.authorization.k8s.io
+  kind: Role
+  name: deployer
+subjects:
+- kind: ServiceAccount
+  name: sa
+  namespace: ci
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: Role
+metadata:
+  name: deployer
+  namespace: prod
+rules:
+- apiGroups:
+  - apps
+  resources:
+  - deployments` + verbs + `
+- apiGroups:
+  - example.com
+  resources:
+  - widgetz` + verbs + `
+- apiGroups:
+  - kpt.dev
+  resources:
+  - resourcegroups` + verbs + `
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: RoleBinding
+metadata:
+  name: deployer
+  namespace: prod
+roleRef:
+  apiGroup: rbac.authorization.k8s.io
+  kind: Role
+  name: deployer
+subjects:
+- kind: ServiceAccount
+  name: sa
+  namespace: ci
+`
+	assert.Equal(t, expected, b.String())
+}
+
+func TestGenerator_Generate_mapper(t *testing.T) {
+	nodes, err := kio.FromBytes([]byte(`
+apiVersion: policy/v1beta1
+kind: PodSecurityPolicy
+metadata:
+  name: psp
+`))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	out, err := rbac.Generator{
+		Name: "deployer",
+		Mapper: func(gvk schema.GroupVersionKind) (string, bool, error) {
+			return "psps", false, nil
+		},
+	}.Generate(nodes, nil)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	if !assert.Len(t, out, 2) {
+		t.FailNow()
+	}
+	s, err := out[0].String()
+	assert.NoError(t, err)
+	assert.True(t, strings.Contains(s, "kind: ClusterRole\n"), s)
+	assert.True(t, strings.Contains(s, "- psps\n"), s)
+}
+
+func TestGenerator_Generate_inventoryTemplate(t *testing.T) {
+	nodes, err := kio.FromBytes([]byte(`
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: inventory-12345
+  namespace: prod
+  labels:
+    cli-utils.sigs.k8s.io/inventory-id: 12345
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+  namespace: prod
+`))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	out, err := rbac.Generator{
+		Name:                    "deployer",
+		ServiceAccount:          "sa",
+		ServiceAccountNamespace: "ci",
+	}.Generate(nodes, &kptfile.KptFile{})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	if !assert.Len(t, out, 2) {
+		t.FailNow()
+	}
+	s, err := out[0].String()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, `apiVersion: rbac.authorization.k8s.io/v1
+kind: Role
+metadata:
+  name: deployer
+  namespace: prod
+rules:
+- apiGroups:
+  - apps
+  resources:
+  - deployments
+  verbs:
+  - get
+  - list
+  - watch
+  - create
+  - update
+  - patch
+  - delete
+- apiGroups:
+  - ""
+  resources:
+  - configmaps
+  verbs:
+  - get
+  - list
+  - create
+  - update
+  - patch
+  - delete
+`, s)
+}
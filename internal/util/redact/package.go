@@ -0,0 +1,273 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redact
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io/ioutil"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"sigs.k8s.io/kustomize/kyaml/errors"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// Sanitizer copies packages with their secret and internal values removed,
+// so they can be shared externally -- e.g. attached to a support ticket.
+//
+// The data of Secrets and the fields listed by SensitiveFieldsAnnotation are
+// replaced with Placeholder, and their values are also redacted from the
+// other resources and files.  Hostnames and IP addresses are masked
+// wherever they appear, including comments.
+type Sanitizer struct {
+	// Annotations are patterns matching the keys of the annotations to
+	// remove, e.g. internal.example.com/*, using path.Match syntax.
+	Annotations []string
+
+	// Hosts are patterns matching the hostnames to mask, e.g.
+	// *.corp.example.com, using path.Match syntax.
+	Hosts []string
+
+	// PrivateIPs masks private, loopback and link-local IPv4 addresses.
+	PrivateIPs bool
+}
+
+var (
+	// hostPattern matches hostnames, which have at least two labels
+	hostPattern = regexp.MustCompile(
+		`[a-zA-Z0-9]([a-zA-Z0-9-]*[a-zA-Z0-9])?(\.[a-zA-Z0-9]([a-zA-Z0-9-]*[a-zA-Z0-9])?)+`)
+
+	// ipPattern matches IPv4 addresses
+	ipPattern = regexp.MustCompile(`\b\d{1,3}(\.\d{1,3}){3}\b`)
+
+	// privateNetworks are the networks masked by PrivateIPs
+	privateNetworks = parseNetworks("10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16",
+		"100.64.0.0/10", "127.0.0.0/8", "169.254.0.0/16")
+)
+
+func parseNetworks(cidrs ...string) []*net.IPNet {
+	var networks []*net.IPNet
+	for _, c := range cidrs {
+		_, n, err := net.ParseCIDR(c)
+		if err != nil {
+			panic(err)
+		}
+		networks = append(networks, n)
+	}
+	return networks
+}
+
+// Copy copies the package at src, including its subpackages, to dest with
+// its secret and internal values removed.  dest must not exist.  Resource
+// files and Kptfiles are sanitized structurally, other text files are
+// masked line by line and binary files are copied unchanged.
+func (s Sanitizer) Copy(src, dest string) error {
+	for _, p := range append(append([]string{}, s.Annotations...), s.Hosts...) {
+		if _, err := path.Match(p, ""); err != nil {
+			return errors.Errorf("invalid pattern %q: %v", p, err)
+		}
+	}
+	if _, err := os.Stat(dest); err == nil {
+		return errors.Errorf("destination %s already exists", dest)
+	} else if !os.IsNotExist(err) {
+		return errors.Wrap(err)
+	}
+
+	// redact the secret values wherever they are copied to
+	r := &Redactor{}
+	if err := r.AddPackage(src); err != nil {
+		return err
+	}
+
+	return filepath.Walk(src, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return errors.Wrap(err)
+		}
+		if info.IsDir() && info.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return errors.Wrap(err)
+		}
+		target := filepath.Join(dest, rel)
+		if info.IsDir() {
+			return errors.Wrap(os.MkdirAll(target, 0700))
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		b, err := ioutil.ReadFile(p)
+		if err != nil {
+			return errors.Wrap(err)
+		}
+		if isResourceFile(info.Name()) {
+			b, err = s.sanitizeResources(r, b)
+			if err != nil {
+				return errors.WrapPrefixf(err, "failed to sanitize %s", rel)
+			}
+		} else if !bytes.Contains(b, []byte{0}) {
+			b = []byte(s.mask(r, string(b)))
+		}
+		return errors.Wrap(ioutil.WriteFile(target, b, info.Mode().Perm()))
+	})
+}
+
+func isResourceFile(name string) bool {
+	if name == "Kptfile" {
+		return true
+	}
+	for _, pattern := range kio.DefaultMatch {
+		if match, _ := filepath.Match(pattern, name); match {
+			return true
+		}
+	}
+	return false
+}
+
+// sanitizeResources returns the resources in b, sanitized.
+func (s Sanitizer) sanitizeResources(r *Redactor, b []byte) ([]byte, error) {
+	nodes, err := (&kio.ByteReader{
+		Reader:                bytes.NewReader(b),
+		OmitReaderAnnotations: true,
+	}).Read()
+	if err != nil {
+		return nil, err
+	}
+	for i := range nodes {
+		if err := s.sanitize(r, nodes[i]); err != nil {
+			return nil, err
+		}
+	}
+	var out bytes.Buffer
+	if err := (kio.ByteWriter{Writer: &out}).Write(nodes); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+// sanitize stubs the secret values of the resource, removes the matching
+// annotations and masks the remaining values and comments.
+func (s Sanitizer) sanitize(r *Redactor, node *yaml.RNode) error {
+	meta, err := node.GetMeta()
+	if err != nil {
+		// not a resource -- only mask it
+		s.maskNode(r, node.YNode())
+		return nil
+	}
+
+	if meta.Kind == "Secret" && meta.APIVersion == "v1" {
+		// keep data valid base64 so the stubbed Secret can still be applied
+		if err := stubValues(node, "data", base64.StdEncoding.EncodeToString([]byte(Placeholder))); err != nil {
+			return err
+		}
+		if err := stubValues(node, "stringData", Placeholder); err != nil {
+			return err
+		}
+	}
+	for _, field := range strings.Split(meta.Annotations[SensitiveFieldsAnnotation], ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		v, err := node.Pipe(yaml.Lookup(strings.Split(field, ".")...))
+		if err != nil {
+			return err
+		}
+		if v != nil && v.YNode().Kind == yaml.ScalarNode {
+			v.YNode().Value, v.YNode().Tag, v.YNode().Style = Placeholder, yaml.NodeTagString, 0
+		}
+	}
+
+	for key := range meta.Annotations {
+		if !matchAny(s.Annotations, key) {
+			continue
+		}
+		if _, err := node.Pipe(yaml.ClearAnnotation(key)); err != nil {
+			return err
+		}
+	}
+
+	s.maskNode(r, node.YNode())
+	return nil
+}
+
+// stubValues replaces the values of the map field with value.
+func stubValues(node *yaml.RNode, field, value string) error {
+	m, err := node.Pipe(yaml.Lookup(field))
+	if err != nil || m == nil {
+		return err
+	}
+	return m.VisitFields(func(n *yaml.MapNode) error {
+		n.Value.YNode().Value, n.Value.YNode().Tag, n.Value.YNode().Style = value, yaml.NodeTagString, 0
+		return nil
+	})
+}
+
+// maskNode masks the scalar values and comments under node.
+func (s Sanitizer) maskNode(r *Redactor, node *yaml.Node) {
+	node.HeadComment = s.mask(r, node.HeadComment)
+	node.LineComment = s.mask(r, node.LineComment)
+	node.FootComment = s.mask(r, node.FootComment)
+	if node.Kind == yaml.ScalarNode {
+		if v := s.mask(r, node.Value); v != node.Value {
+			node.Value, node.Tag = v, yaml.NodeTagString
+		}
+	}
+	for i := range node.Content {
+		s.maskNode(r, node.Content[i])
+	}
+}
+
+// mask returns v with the secret values redacted, and the matching
+// hostnames and IP addresses replaced with Placeholder.
+func (s Sanitizer) mask(r *Redactor, v string) string {
+	v = r.String(v)
+	if len(s.Hosts) > 0 {
+		v = hostPattern.ReplaceAllStringFunc(v, func(host string) string {
+			if matchAny(s.Hosts, strings.ToLower(host)) {
+				return Placeholder
+			}
+			return host
+		})
+	}
+	if s.PrivateIPs {
+		v = ipPattern.ReplaceAllStringFunc(v, func(addr string) string {
+			ip := net.ParseIP(addr)
+			for _, n := range privateNetworks {
+				if ip != nil && n.Contains(ip) {
+					return Placeholder
+				}
+			}
+			return addr
+		})
+	}
+	return v
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, p := range patterns {
+		if match, _ := path.Match(p, name); match {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,146 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redact_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt/internal/util/redact"
+	"github.com/stretchr/testify/assert"
+)
+
+const deployment = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+  annotations:
+    internal.example.com/owner: team-a
+    config.kpt.dev/merge: keep-local
+spec:
+  template:
+    spec:
+      containers:
+      - name: app
+        image: registry.corp.example.com/app:v1 # mirrored from gcr.io
+        env:
+        - name: DB
+          value: db.corp.example.com:5432
+        - name: CACHE
+          value: 10.1.2.3
+        - name: DNS
+          value: 8.8.8.8
+`
+
+const readme = `Connect to db.corp.example.com with password hunter2.
+`
+
+func TestSanitizer_Copy(t *testing.T) {
+	src, err := ioutil.TempDir("", "kpt-redact-test")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer os.RemoveAll(src)
+	for name, content := range map[string]string{
+		"resources.yaml":  resources,
+		"deployment.yaml": deployment,
+		"README.md":       readme,
+	} {
+		err := ioutil.WriteFile(filepath.Join(src, name), []byte(content), 0600)
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+	}
+	dest := filepath.Join(src, "..", filepath.Base(src)+"-redacted")
+	defer os.RemoveAll(dest)
+
+	err = redact.Sanitizer{
+		Annotations: []string{"internal.example.com/*"},
+		Hosts:       []string{"*.corp.example.com"},
+		PrivateIPs:  true,
+	}.Copy(src, dest)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	b, err := ioutil.ReadFile(filepath.Join(dest, "resources.yaml"))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, `apiVersion: v1
+kind: Secret
+metadata:
+  name: creds
+data:
+  password: PHJlZGFjdGVkPg== # <redacted>
+stringData:
+  token: <redacted>
+---
+apiVersion: example.com/v1
+kind: Database
+metadata:
+  name: db
+  annotations:
+    config.kpt.dev/sensitive-fields: spec.auth.key, spec.missing
+spec:
+  auth:
+    key: <redacted>
+  host: db.example.com
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cm
+data:
+  value: not-a-secret
+`, string(b))
+
+	b, err = ioutil.ReadFile(filepath.Join(dest, "deployment.yaml"))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+  annotations:
+    config.kpt.dev/merge: keep-local
+spec:
+  template:
+    spec:
+      containers:
+      - name: app
+        image: <redacted>/app:v1 # mirrored from gcr.io
+        env:
+        - name: DB
+          value: <redacted>:5432
+        - name: CACHE
+          value: <redacted>
+        - name: DNS
+          value: 8.8.8.8
+`, string(b))
+
+	b, err = ioutil.ReadFile(filepath.Join(dest, "README.md"))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, "Connect to <redacted> with password <redacted>.\n", string(b))
+
+	// the destination isn't overwritten
+	err = redact.Sanitizer{}.Copy(src, dest)
+	assert.Error(t, err)
+}
@@ -0,0 +1,227 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package redact removes secret values from command output, and sanitizes
+// copies of packages to share externally.
+package redact
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"sigs.k8s.io/kustomize/kyaml/errors"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// SensitiveFieldsAnnotation lists the fields of a resource, in addition to
+// Secret data, whose values are redacted.  Fields are separated by commas and
+// paths by dots, e.g. `spec.password,spec.auth.token`.
+const SensitiveFieldsAnnotation = "config.kpt.dev/sensitive-fields"
+
+// Placeholder replaces redacted values.
+const Placeholder = "<redacted>"
+
+// MinLength is the length of the shortest value which is redacted.  Shorter
+// values, e.g. "true", are too common for redacting them to be useful.
+const MinLength = 5
+
+// Redactor redacts the secret values of resources from output.
+type Redactor struct {
+	// Disabled if set writes output unchanged -- i.e. --show-secrets
+	Disabled bool
+
+	mu       sync.RWMutex
+	values   map[string]bool
+	replacer *strings.Replacer
+}
+
+// AddPackage adds the secret values of the resources under path.
+func (r *Redactor) AddPackage(path string) error {
+	nodes, err := (&kio.LocalPackageReader{PackagePath: path}).Read()
+	if err != nil {
+		return err
+	}
+	return r.AddResources(nodes)
+}
+
+// AddResources adds the values of Secret data and of the fields listed by
+// SensitiveFieldsAnnotation.  Secret data is added both encoded and decoded.
+func (r *Redactor) AddResources(nodes []*yaml.RNode) error {
+	var values []string
+	for i := range nodes {
+		meta, err := nodes[i].GetMeta()
+		if err != nil {
+			return err
+		}
+		if meta.Kind == "Secret" && meta.APIVersion == "v1" {
+			data, err := fieldValues(nodes[i], "data")
+			if err != nil {
+				return err
+			}
+			for _, v := range data {
+				values = append(values, v)
+				if d, err := base64.StdEncoding.DecodeString(v); err == nil {
+					values = append(values, string(d))
+				}
+			}
+			stringData, err := fieldValues(nodes[i], "stringData")
+			if err != nil {
+				return err
+			}
+			for _, v := range stringData {
+				values = append(values, v, base64.StdEncoding.EncodeToString([]byte(v)))
+			}
+		}
+
+		fields := meta.Annotations[SensitiveFieldsAnnotation]
+		for _, field := range strings.Split(fields, ",") {
+			field = strings.TrimSpace(field)
+			if field == "" {
+				continue
+			}
+			v, err := nodes[i].Pipe(yaml.Lookup(strings.Split(field, ".")...))
+			if err != nil {
+				return err
+			}
+			if v != nil && v.YNode().Kind == yaml.ScalarNode {
+				values = append(values, v.YNode().Value)
+			}
+		}
+	}
+	r.Add(values...)
+	return nil
+}
+
+// fieldValues returns the values of the map field.
+func fieldValues(node *yaml.RNode, field string) ([]string, error) {
+	m, err := node.Pipe(yaml.Lookup(field))
+	if err != nil || m == nil {
+		return nil, err
+	}
+	var values []string
+	err = m.VisitFields(func(n *yaml.MapNode) error {
+		values = append(values, yaml.GetValue(n.Value))
+		return nil
+	})
+	return values, err
+}
+
+// Add adds values to redact.
+func (r *Redactor) Add(values ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.values == nil {
+		r.values = map[string]bool{}
+	}
+	for _, v := range values {
+		// also add the lines of multi-line values, e.g. certificates, in
+		// case the value is split by the output
+		for _, l := range append(strings.Split(v, "\n"), v) {
+			if l = strings.TrimSpace(l); len(l) >= MinLength {
+				r.values[l] = true
+			}
+		}
+	}
+
+	// replace longer values first so values containing others are
+	// redacted completely
+	var sorted []string
+	for v := range r.values {
+		sorted = append(sorted, v)
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if len(sorted[i]) != len(sorted[j]) {
+			return len(sorted[i]) > len(sorted[j])
+		}
+		return sorted[i] < sorted[j]
+	})
+	var oldnew []string
+	for _, v := range sorted {
+		oldnew = append(oldnew, v, Placeholder)
+	}
+	r.replacer = strings.NewReplacer(oldnew...)
+}
+
+// String returns s with the secret values redacted.
+func (r *Redactor) String(s string) string {
+	if r == nil || r.Disabled {
+		return s
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.replacer == nil {
+		return s
+	}
+	return r.replacer.Replace(s)
+}
+
+// Error returns err with the secret values redacted from its message.
+func (r *Redactor) Error(err error) error {
+	if err == nil {
+		return nil
+	}
+	msg := r.String(err.Error())
+	if msg == err.Error() {
+		return err
+	}
+	return errors.Errorf("%s", msg)
+}
+
+// Writer returns a writer which redacts the secret values from the output
+// written to w.  Output is written a line at a time so values aren't split
+// between writes -- Flush must be called to write a trailing partial line.
+func (r *Redactor) Writer(w io.Writer) *Writer {
+	return &Writer{redactor: r, w: w}
+}
+
+// Writer redacts secret values from output.
+type Writer struct {
+	redactor *Redactor
+	w        io.Writer
+	mu       sync.Mutex
+	buf      bytes.Buffer
+}
+
+// Write writes the complete lines in p, redacted, and buffers the rest.
+func (w *Writer) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.buf.Write(p)
+	i := bytes.LastIndexByte(w.buf.Bytes(), '\n')
+	if i < 0 {
+		return len(p), nil
+	}
+	lines := string(w.buf.Next(i + 1))
+	if _, err := io.WriteString(w.w, w.redactor.String(lines)); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Flush writes any buffered partial line.
+func (w *Writer) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.buf.Len() == 0 {
+		return nil
+	}
+	_, err := io.WriteString(w.w, w.redactor.String(w.buf.String()))
+	w.buf.Reset()
+	return err
+}
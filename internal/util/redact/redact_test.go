@@ -0,0 +1,135 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package redact_test
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt/internal/util/redact"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+)
+
+const resources = `
+apiVersion: v1
+kind: Secret
+metadata:
+  name: creds
+data:
+  password: aHVudGVyMg== # hunter2
+stringData:
+  token: s3cr3t-t0ken
+---
+apiVersion: example.com/v1
+kind: Database
+metadata:
+  name: db
+  annotations:
+    config.kpt.dev/sensitive-fields: spec.auth.key, spec.missing
+spec:
+  auth:
+    key: my-api-key
+  host: db.example.com
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cm
+data:
+  value: not-a-secret
+`
+
+func newRedactor(t *testing.T) *redact.Redactor {
+	nodes, err := kio.FromBytes([]byte(resources))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	r := &redact.Redactor{}
+	if !assert.NoError(t, r.AddResources(nodes)) {
+		t.FailNow()
+	}
+	return r
+}
+
+func TestRedactor_String(t *testing.T) {
+	r := newRedactor(t)
+	tests := map[string]string{
+		"password aHVudGVyMg== set":         "password <redacted> set",
+		"decoded hunter2":                   "decoded <redacted>",
+		"token s3cr3t-t0ken":                "token <redacted>",
+		"encoded token czNjcjN0LXQwa2Vu":    "encoded token <redacted>",
+		"key my-api-key for db.example.com": "key <redacted> for db.example.com",
+		"configmap not-a-secret":            "configmap not-a-secret",
+	}
+	for in, expected := range tests {
+		assert.Equal(t, expected, r.String(in))
+	}
+
+	r.Disabled = true
+	assert.Equal(t, "decoded hunter2", r.String("decoded hunter2"))
+}
+
+// TestRedactor_minLength verifies that values shorter than MinLength, such
+// as "true", aren't redacted.
+func TestRedactor_minLength(t *testing.T) {
+	nodes, err := kio.FromBytes([]byte(`apiVersion: v1
+kind: Secret
+metadata:
+  name: flags
+stringData:
+  enabled: "true"
+  user: admin
+`))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	r := &redact.Redactor{}
+	if !assert.NoError(t, r.AddResources(nodes)) {
+		t.FailNow()
+	}
+	assert.Equal(t, redact.MinLength, len("admin"))
+	assert.Equal(t, "enabled true for <redacted>", r.String("enabled true for admin"))
+}
+
+func TestRedactor_Error(t *testing.T) {
+	r := newRedactor(t)
+	err := fmt.Errorf("failed to apply secret with password hunter2")
+	assert.EqualError(t, r.Error(err), "failed to apply secret with password <redacted>")
+
+	// errors without secret values are returned unchanged
+	err = fmt.Errorf("failed to apply configmap not-a-secret")
+	assert.Equal(t, err, r.Error(err))
+	assert.NoError(t, r.Error(nil))
+}
+
+func TestWriter(t *testing.T) {
+	r := newRedactor(t)
+	out := &bytes.Buffer{}
+	w := r.Writer(out)
+
+	// values split between writes are redacted once the line is complete
+	_, err := w.Write([]byte("password: hun"))
+	assert.NoError(t, err)
+	assert.Empty(t, out.String())
+	_, err = w.Write([]byte("ter2\nkey: my-api"))
+	assert.NoError(t, err)
+	assert.Equal(t, "password: <redacted>\n", out.String())
+	_, err = w.Write([]byte("-key"))
+	assert.NoError(t, err)
+	assert.NoError(t, w.Flush())
+	assert.Equal(t, "password: <redacted>\nkey: <redacted>", out.String())
+}
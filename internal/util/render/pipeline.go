@@ -0,0 +1,436 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package render
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/GoogleContainerTools/kpt/internal/gitutil"
+	"github.com/GoogleContainerTools/kpt/internal/util/conditions"
+	"github.com/GoogleContainerTools/kpt/internal/util/functions"
+	"github.com/GoogleContainerTools/kpt/internal/util/inherit"
+	"github.com/GoogleContainerTools/kpt/internal/util/setters"
+	"github.com/GoogleContainerTools/kpt/pkg/kptfile"
+	"github.com/GoogleContainerTools/kpt/pkg/kptfile/kptfileutil"
+	"github.com/go-openapi/spec"
+	"sigs.k8s.io/kustomize/kyaml/errors"
+	"sigs.k8s.io/kustomize/kyaml/fieldmeta"
+	"sigs.k8s.io/kustomize/kyaml/fn/runtime/exec"
+	"sigs.k8s.io/kustomize/kyaml/fn/runtime/runtimeutil"
+	"sigs.k8s.io/kustomize/kyaml/fn/runtime/starlark"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/kio/kioutil"
+	"sigs.k8s.io/kustomize/kyaml/pathutil"
+	"sigs.k8s.io/kustomize/kyaml/setters2"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// Command renders the package at Path.
+type Command struct {
+	// Path is the package to render
+	Path string
+
+	// DryRun writes the rendered resources to Output rather than to the
+	// package.  The values of secret setters are only injected into the
+	// resources written to Output.
+	DryRun bool
+
+	// Values are setter values injected into the resources written to
+	// Output, without changing the package.  They require DryRun.
+	Values map[string]string
+
+	// Output is where the functions run, or the rendered resources, are
+	// written.  Defaults to stdout.
+	Output io.Writer
+
+	// Log is where the functions run are written when DryRun is set.
+	// Defaults to stderr.
+	Log io.Writer
+
+	// Stats if set records the resources each mutator added, removed and
+	// modified
+	Stats *functions.Stats
+
+	// RequirePinned if set fails the render before running any function if
+	// the upstream or a dependency of a package in the rendered tree isn't
+	// pinned to a tag or commit.
+	RequirePinned bool
+}
+
+// Run renders the package.  The subpackages are rendered first, deepest
+// first, each running the mutators of its Kptfile against its own
+// resources and those of its subpackages, then its validators.  The
+// resources are only written once every pipeline has succeeded.
+func (c Command) Run() error {
+	if c.Output == nil {
+		c.Output = os.Stdout
+	}
+	if c.Log == nil {
+		c.Log = os.Stderr
+	}
+	log := c.Output
+	if c.DryRun {
+		log = c.Log
+	}
+
+	if len(c.Values) > 0 && !c.DryRun {
+		return errors.Errorf("setter values can only be injected into a dry run, " +
+			"set them in the package with kpt cfg set")
+	}
+
+	packages, err := packages(c.Path)
+	if err != nil {
+		return err
+	}
+	if c.RequirePinned {
+		if err := checkPinned(c.Path, packages); err != nil {
+			return err
+		}
+	}
+	rw := &kio.LocalPackageReadWriter{PackagePath: c.Path, IncludeSubpackages: true}
+	nodes, err := rw.Read()
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	// the resources excluded by their conditions aren't rendered, and are
+	// written back to the package unchanged
+	conds, err := conditions.Read(c.Path)
+	if err != nil {
+		return err
+	}
+	nodes, excluded, err := conds.Filter(nodes)
+	if err != nil {
+		return err
+	}
+	inherits, err := pipelineInheritance(c.Path, packages)
+	if err != nil {
+		return err
+	}
+	for _, p := range packages {
+		if nodes, err = renderPackage(c.Path, p, packages, inherits, nodes, log, c.Stats); err != nil {
+			return err
+		}
+	}
+	if err := kioutil.DefaultPathAndIndexAnnotation("", nodes); err != nil {
+		return errors.Wrap(err)
+	}
+	if c.DryRun {
+		if _, err := setters.Inject(c.Path, nodes, c.Values); err != nil {
+			return err
+		}
+		return errors.Wrap(kio.ByteWriter{Writer: c.Output, KeepReaderAnnotations: true}.Write(nodes))
+	}
+	return errors.Wrap(rw.Write(append(nodes, excluded...)))
+}
+
+// packages returns the packages under path, relative to it, deepest first
+// and otherwise sorted by path so they are always rendered in the same
+// order.
+func packages(path string) ([]string, error) {
+	dirs, err := pathutil.DirsWithFile(path, kptfile.KptFileName, true)
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	if len(dirs) == 0 {
+		return nil, errors.Errorf("%s is not a package, it has no %s", path, kptfile.KptFileName)
+	}
+	var packages []string
+	for _, d := range dirs {
+		rel, err := filepath.Rel(path, d)
+		if err != nil {
+			return nil, errors.Wrap(err)
+		}
+		packages = append(packages, filepath.ToSlash(rel))
+	}
+	depth := func(p string) int {
+		if p == "." {
+			return 0
+		}
+		return strings.Count(p, "/") + 1
+	}
+	sort.SliceStable(packages, func(i, j int) bool {
+		if di, dj := depth(packages[i]), depth(packages[j]); di != dj {
+			return di > dj
+		}
+		return packages[i] < packages[j]
+	})
+	return packages, nil
+}
+
+// checkPinned returns an error if the upstream or a dependency of any of
+// packages, relative to root, isn't pinned to a tag or commit.
+func checkPinned(root string, packages []string) error {
+	for _, p := range packages {
+		k, err := kptfileutil.ReadFile(filepath.Join(root, filepath.FromSlash(p)))
+		if err != nil {
+			return err
+		}
+		if k.Upstream.Type == kptfile.GitOrigin {
+			g := k.Upstream.Git
+			if err := gitutil.CheckPinnedRef(g.Repo, g.Directory, g.TagTemplate, g.Ref, nil); err != nil {
+				return errors.WrapPrefixf(err, "package %q", p)
+			}
+		}
+		for _, dep := range k.Dependencies {
+			if dep.EnsureNotExists {
+				continue
+			}
+			err := gitutil.CheckPinnedRef(dep.Git.Repo, dep.Git.Directory, dep.Git.TagTemplate,
+				dep.Git.Ref, nil)
+			if err != nil {
+				return errors.WrapPrefixf(err, "package %q dependency %q", p, dep.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// pipelineInheritance returns the packages, relative to root, whose
+// pipelines each of the packages inherits.
+func pipelineInheritance(root string, packages []string) (map[string]map[string]bool, error) {
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	inherits := map[string]map[string]bool{}
+	for _, p := range packages {
+		dirs, err := inherit.PipelineAncestors(filepath.Join(abs, filepath.FromSlash(p)))
+		if err != nil {
+			return nil, err
+		}
+		inherits[p] = map[string]bool{}
+		for _, d := range dirs {
+			rel, err := filepath.Rel(abs, d)
+			if err != nil {
+				return nil, errors.Wrap(err)
+			}
+			inherits[p][filepath.ToSlash(rel)] = true
+		}
+	}
+	return inherits, nil
+}
+
+// renderPackage runs the pipeline of the package rel, relative to root,
+// against its resources in nodes, returning the nodes with its resources
+// replaced by the mutated ones.  The resources of subpackages which inherit
+// the pipeline have already been run by it, so they are skipped.  The
+// changes of the mutators are recorded in stats if it is non-nil.
+func renderPackage(root, rel string, packages []string, inherits map[string]map[string]bool,
+	nodes []*yaml.RNode, log io.Writer, stats *functions.Stats) ([]*yaml.RNode, error) {
+	dir := filepath.Join(root, filepath.FromSlash(rel))
+	k, err := inherit.Kptfile(dir)
+	if err != nil {
+		return nil, err
+	}
+	p := k.Pipeline
+	setters := k.Inherit != nil && k.Inherit.Setters
+	if len(p.Mutators) == 0 && len(p.Validators) == 0 && !setters {
+		return nodes, nil
+	}
+
+	var in, out, own []*yaml.RNode
+	for _, n := range nodes {
+		path, _, err := kioutil.GetFileAnnotations(n)
+		if err != nil {
+			return nil, errors.Wrap(err)
+		}
+		pkg := owner(packages, path)
+		if inPackage(rel, path) && !inherits[pkg][rel] {
+			in = append(in, n)
+		} else {
+			out = append(out, n)
+		}
+		if pkg == rel {
+			own = append(own, n)
+		}
+	}
+
+	if setters {
+		// the inherited setter values are set on the resources of the
+		// package itself, its subpackages have their own setters
+		if err := setInheritedSetters(k, own); err != nil {
+			return nil, errors.Errorf("unable to set the inherited setters of %s: %v", rel, err)
+		}
+	}
+
+	for _, f := range p.Mutators {
+		fmt.Fprintf(log, "running mutator %s on %s\n", f, rel)
+		fltr, err := filter(dir, f)
+		if err != nil {
+			return nil, err
+		}
+		if stats != nil {
+			fltr = stats.Filter(fmt.Sprintf("%s on %s", f, rel), fltr)
+		}
+		if in, err = fltr.Filter(in); err != nil {
+			return nil, errors.Errorf("mutator %s of %s failed: %v", f, rel, err)
+		}
+		// resources created by the function are written to the package
+		if err := kioutil.DefaultPathAndIndexAnnotation(rel, in); err != nil {
+			return nil, errors.Wrap(err)
+		}
+	}
+	for _, f := range p.Validators {
+		fmt.Fprintf(log, "running validator %s on %s\n", f, rel)
+		fltr, err := filter(dir, f)
+		if err != nil {
+			return nil, err
+		}
+		// validators see a copy so they can't change the package
+		var copies []*yaml.RNode
+		for _, n := range in {
+			copies = append(copies, n.Copy())
+		}
+		if _, err := fltr.Filter(copies); err != nil {
+			return nil, errors.Errorf("validator %s of %s failed: %v", f, rel, err)
+		}
+	}
+	return append(out, in...), nil
+}
+
+// inPackage returns true if the file path, relative to the root package,
+// is part of the package rel.
+func inPackage(rel, path string) bool {
+	return rel == "." || strings.HasPrefix(filepath.ToSlash(path), rel+"/")
+}
+
+// owner returns the deepest of the packages, sorted deepest first, which
+// contains the file path.
+func owner(packages []string, path string) string {
+	for _, p := range packages {
+		if inPackage(p, path) {
+			return p
+		}
+	}
+	return "."
+}
+
+// setInheritedSetters sets the setters of the Kptfile k, which has
+// inherited setters, on nodes.
+func setInheritedSetters(k kptfile.KptFile, nodes []*yaml.RNode) error {
+	if k.OpenAPI == nil {
+		return nil
+	}
+	b, err := json.Marshal(k.OpenAPI)
+	if err != nil {
+		return err
+	}
+	var sc spec.Schema
+	if err := sc.UnmarshalJSON(b); err != nil {
+		return err
+	}
+	fieldmeta.SetShortHandRef("$kpt-set")
+	for _, n := range nodes {
+		if err := n.PipeE(&setters2.Set{SetAll: true, SettersSchema: &sc}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// filter returns the filter running the function f of the package at dir.
+func filter(dir string, f kptfile.PipelineFunction) (kio.Filter, error) {
+	config, err := functionConfig(dir, f)
+	if err != nil {
+		return nil, err
+	}
+	configString := ""
+	if config != nil {
+		if configString, err = config.String(); err != nil {
+			return nil, errors.Wrap(err)
+		}
+	}
+	ff := runtimeutil.FunctionFilter{FunctionConfig: config, GlobalScope: true}
+
+	set := 0
+	for _, s := range []string{f.Image, f.Exec, f.Starlark, f.Wasm, f.Endpoint} {
+		if s != "" {
+			set++
+		}
+	}
+	if set != 1 {
+		return nil, errors.Errorf("function %s must set exactly one of image, exec, starlark, wasm and endpoint", f)
+	}
+
+	var fltr kio.Filter
+	switch {
+	case f.Image != "":
+		cf, err := functions.ContainerFilter(runtimeutil.ContainerSpec{Image: f.Image},
+			exec.Filter{FunctionFilter: ff})
+		if err != nil {
+			return nil, err
+		}
+		fltr = functions.WithImageCache(functions.CacheFromEnv(), f.Image, configString, cf)
+	case f.Exec != "":
+		path := f.Exec
+		if strings.ContainsRune(path, '/') && !filepath.IsAbs(path) {
+			path = filepath.Join(dir, filepath.FromSlash(path))
+		}
+		fltr = &exec.Filter{Path: path, Args: f.Args, FunctionFilter: ff}
+	case f.Wasm != "":
+		module := f.Wasm
+		if u, err := url.Parse(module); err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			module = filepath.Join(dir, filepath.FromSlash(module))
+		}
+		fltr = &functions.WasmFilter{Module: module, SHA256: f.SHA256, FunctionFilter: ff}
+	case f.Endpoint != "":
+		fltr = &functions.EndpointFilter{Endpoint: f.Endpoint, FunctionFilter: ff}
+	default:
+		program := filepath.Join(dir, filepath.FromSlash(f.Starlark))
+		b, err := ioutil.ReadFile(program)
+		if err != nil {
+			return nil, errors.Errorf("function %s: %v", f, err)
+		}
+		fltr = functions.WithCache(functions.CacheFromEnv(),
+			"starlark:"+f.String()+"\n"+string(b)+"\n"+configString,
+			&starlark.Filter{Name: f.String(), Path: program, FunctionFilter: ff})
+	}
+
+	context, err := functions.PackageContext(dir)
+	if err != nil {
+		return nil, err
+	}
+	return functions.WithAnchors(functions.WithPackageContext(context, fltr)), nil
+}
+
+// functionConfig returns the functionConfig of f, inline or read from its
+// file, or nil if it has none.
+func functionConfig(dir string, f kptfile.PipelineFunction) (*yaml.RNode, error) {
+	switch {
+	case f.Config.Kind != 0 && f.ConfigPath != "":
+		return nil, errors.Errorf("function %s must set only one of config and configPath", f)
+	case f.Config.Kind != 0:
+		return yaml.NewRNode(&f.Config), nil
+	case f.ConfigPath != "":
+		b, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(f.ConfigPath)))
+		if err != nil {
+			return nil, errors.Errorf("function %s: %v", f, err)
+		}
+		config, err := yaml.Parse(string(b))
+		if err != nil {
+			return nil, errors.Errorf("function %s: invalid configPath %s: %v", f, f.ConfigPath, err)
+		}
+		return config, nil
+	}
+	return nil, nil
+}
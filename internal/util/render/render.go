@@ -0,0 +1,92 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package render renders packages -- running the functions and pipelines
+// their Kptfiles declare -- and verifies that packages are committed as they
+// render, so that what is applied is what was reviewed.
+package render
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/GoogleContainerTools/kpt/internal/gitutil"
+	"github.com/GoogleContainerTools/kpt/internal/util/functions"
+	"github.com/GoogleContainerTools/kpt/internal/util/tmputil"
+	"github.com/GoogleContainerTools/kpt/internal/util/update"
+	"github.com/GoogleContainerTools/kpt/pkg/kptfile"
+	"sigs.k8s.io/kustomize/kyaml/copyutil"
+	"sigs.k8s.io/kustomize/kyaml/errors"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/pathutil"
+)
+
+// Render renders the package at path, and its subpackages, in place --
+// applying their common metadata, running the functions their Kptfiles
+// declare and then their pipelines.
+func Render(path string) error {
+	paths, err := pathutil.DirsWithFile(path, kptfile.KptFileName, true)
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	for _, p := range paths {
+		if err := functions.ReconcileFunctions(p); err != nil {
+			return err
+		}
+	}
+	if len(paths) == 0 {
+		return nil
+	}
+	return Command{Path: path, Output: ioutil.Discard}.Run()
+}
+
+// Verify renders a copy of the package at path and returns the changes the
+// render made to it, which are empty if the package is rendered.  If the
+// package is in a git repo it must not have uncommitted changes, so that
+// the committed files are verified.
+func Verify(path string) ([]update.FileChange, error) {
+	g := gitutil.NewLocalGitRunner(path)
+	if err := g.Run("status", "--porcelain", "."); err == nil &&
+		strings.TrimSpace(g.Stdout.String()) != "" {
+		return nil, errors.Errorf("package %q has uncommitted changes", path)
+	}
+
+	dir, err := tmputil.WorkspaceDir("kpt-render-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+
+	// both copies are written by kio, so the render isn't confused with
+	// formatting changes
+	committed := filepath.Join(dir, "committed")
+	rendered := filepath.Join(dir, "rendered")
+	for _, p := range []string{committed, rendered} {
+		if err := copyutil.CopyDir(path, p); err != nil {
+			return nil, errors.Wrap(err)
+		}
+	}
+	if err := Render(rendered); err != nil {
+		return nil, err
+	}
+	for _, p := range []string{committed, rendered} {
+		rw := &kio.LocalPackageReadWriter{PackagePath: p, IncludeSubpackages: true}
+		if err := (kio.Pipeline{Inputs: []kio.Reader{rw}, Outputs: []kio.Writer{rw}}).Execute(); err != nil {
+			return nil, errors.Wrap(err)
+		}
+	}
+	return update.DiffPackages(committed, rendered)
+}
@@ -0,0 +1,697 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package render_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt/internal/gitutil"
+	"github.com/GoogleContainerTools/kpt/internal/testutil/pkgbuilder"
+	"github.com/GoogleContainerTools/kpt/internal/util/functions"
+	. "github.com/GoogleContainerTools/kpt/internal/util/render"
+	"github.com/GoogleContainerTools/kpt/internal/util/update"
+	"github.com/stretchr/testify/assert"
+)
+
+const kptfile = `apiVersion: kpt.dev/v1alpha1
+kind: Kptfile
+metadata:
+  name: app
+commonLabels:
+  app: web
+`
+
+// TestVerify verifies that packages which differ from their render are
+// reported, and that formatting isn't mistaken for a difference.
+func TestVerify(t *testing.T) {
+	tests := []struct {
+		name     string
+		deploy   string
+		expected []string
+	}{
+		{
+			name: "rendered",
+			// the sequence isn't indented as kpt writes it
+			deploy: `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  labels:
+    app: web
+spec:
+  template:
+    spec:
+      containers:
+      - name: web
+        image: nginx
+`,
+		},
+		{
+			name: "not rendered",
+			deploy: `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+`,
+			expected: []string{"deploy.yaml"},
+		},
+	}
+	for i := range tests {
+		test := tests[i]
+		t.Run(test.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "kpt-render-test")
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			defer os.RemoveAll(dir)
+			for name, data := range map[string]string{"Kptfile": kptfile, "deploy.yaml": test.deploy} {
+				if !assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(data), 0600)) {
+					t.FailNow()
+				}
+			}
+
+			changes, err := Verify(dir)
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			var paths []string
+			for _, c := range changes {
+				assert.Equal(t, update.Modified, c.Type)
+				paths = append(paths, c.Path)
+			}
+			assert.Equal(t, test.expected, paths)
+
+			// the package is unchanged
+			b, err := ioutil.ReadFile(filepath.Join(dir, "deploy.yaml"))
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			assert.Equal(t, test.deploy, string(b))
+		})
+	}
+}
+
+// TestVerify_uncommitted verifies that packages in git must not have
+// uncommitted changes.
+func TestVerify_uncommitted(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kpt-render-test")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir)
+	if !assert.NoError(t, gitutil.NewLocalGitRunner(dir).Run("init")) {
+		t.FailNow()
+	}
+	if !assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "Kptfile"), []byte(kptfile), 0600)) {
+		t.FailNow()
+	}
+
+	_, err = Verify(dir)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "has uncommitted changes")
+	}
+}
+
+const label = `
+def label(items, config):
+  for item in items:
+    item["metadata"].setdefault("labels", {})[config["data"]["key"]] = config["data"]["value"]
+label(ctx.resource_list["items"], ctx.resource_list["functionConfig"])
+`
+
+// TestCommand_Run verifies that the pipelines of subpackages are run before
+// those of their parents, and only against their own resources.
+func TestCommand_Run(t *testing.T) {
+	dir := pkgbuilder.WritePackage(t, map[string]string{
+		"Kptfile": `apiVersion: kpt.dev/v1alpha1
+kind: Kptfile
+metadata:
+  name: app
+pipeline:
+  mutators:
+  - name: label
+    starlark: label.star
+    config:
+      apiVersion: v1
+      kind: ConfigMap
+      metadata:
+        name: label
+      data:
+        key: app
+        value: web
+`,
+		"label.star": label,
+		"deploy.yaml": `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+`,
+		"db/Kptfile": `apiVersion: kpt.dev/v1alpha1
+kind: Kptfile
+metadata:
+  name: db
+pipeline:
+  mutators:
+  - name: tier
+    starlark: label.star
+    configPath: tier.yaml
+  validators:
+  - name: tier
+    starlark: check.star
+`,
+		"db/label.star": label,
+		"db/check.star": `
+def check(items):
+  for item in items:
+    if item["kind"] == "StatefulSet" and item["metadata"]["labels"]["tier"] != "db":
+      fail("tier isn't db")
+    # subpackages are rendered before their parents
+    if "app" in item["metadata"].get("labels", {}):
+      fail("rendered after its parent")
+check(ctx.resource_list["items"])
+`,
+		"db/tier.yaml": `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: tier
+  annotations:
+    config.kubernetes.io/local-config: "true"
+data:
+  key: tier
+  value: db
+`,
+		"db/statefulset.yaml": `apiVersion: apps/v1
+kind: StatefulSet
+metadata:
+  name: db
+`,
+	})
+	defer os.RemoveAll(dir)
+
+	if !assert.NoError(t, Command{Path: dir, Output: ioutil.Discard}.Run()) {
+		t.FailNow()
+	}
+
+	b, err := ioutil.ReadFile(filepath.Join(dir, "deploy.yaml"))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  labels:
+    app: web
+`, string(b))
+
+	b, err = ioutil.ReadFile(filepath.Join(dir, "db", "statefulset.yaml"))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, `apiVersion: apps/v1
+kind: StatefulSet
+metadata:
+  name: db
+  labels:
+    app: web
+    tier: db
+`, string(b))
+}
+
+// TestCommand_Run_stats verifies that the changes of each mutator are
+// recorded.
+func TestCommand_Run_stats(t *testing.T) {
+	dir := pkgbuilder.WritePackage(t, map[string]string{
+		"Kptfile": `apiVersion: kpt.dev/v1alpha1
+kind: Kptfile
+metadata:
+  name: app
+pipeline:
+  mutators:
+  - name: label
+    starlark: label.star
+    configPath: label.yaml
+  - name: relabel
+    starlark: label.star
+    configPath: label.yaml
+`,
+		"label.star": label,
+		"label.yaml": `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: label
+  annotations:
+    config.kubernetes.io/local-config: "true"
+data:
+  key: app
+  value: web
+`,
+		"deploy.yaml": `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+`,
+		"service.yaml": `apiVersion: v1
+kind: Service
+metadata:
+  name: web
+`,
+	})
+	defer os.RemoveAll(dir)
+
+	stats := &functions.Stats{}
+	if !assert.NoError(t, Command{Path: dir, Output: ioutil.Discard, Stats: stats}.Run()) {
+		t.FailNow()
+	}
+	if !assert.Len(t, stats.Steps, 2) {
+		t.FailNow()
+	}
+	// the label config is labeled too, and relabeling changes nothing
+	assert.Equal(t, "label on .", stats.Steps[0].Name)
+	assert.Equal(t, 3, stats.Steps[0].Modified)
+	assert.Equal(t, "relabel on .", stats.Steps[1].Name)
+	assert.Equal(t, 0, stats.Steps[1].Churn())
+	assert.Equal(t, 3, stats.Steps[1].Unchanged)
+	assert.Equal(t, 3, stats.Churn)
+}
+
+// TestCommand_Run_validator verifies that a failing validator fails the
+// render without writing the package, and that dry runs don't write it.
+func TestCommand_Run_validator(t *testing.T) {
+	deploy := `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+`
+	tests := []struct {
+		name      string
+		validator string
+		dryRun    bool
+		err       string
+		output    string
+	}{
+		{
+			name: "failed",
+			validator: `
+fail("not allowed")
+`,
+			err:    "validator check of . failed",
+			output: "running mutator label on .\nrunning validator check on .\n",
+		},
+		{
+			name:      "dry run",
+			validator: "\n",
+			dryRun:    true,
+			output: `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  labels:
+    app: web
+  annotations:
+    config.kubernetes.io/index: "0"
+    config.kubernetes.io/path: deploy.yaml
+`,
+		},
+	}
+	for i := range tests {
+		test := tests[i]
+		t.Run(test.name, func(t *testing.T) {
+			dir := pkgbuilder.WritePackage(t, map[string]string{
+				"Kptfile": `apiVersion: kpt.dev/v1alpha1
+kind: Kptfile
+metadata:
+  name: app
+pipeline:
+  mutators:
+  - name: label
+    starlark: label.star
+    config:
+      apiVersion: v1
+      kind: ConfigMap
+      metadata:
+        name: label
+      data:
+        key: app
+        value: web
+  validators:
+  - name: check
+    starlark: check.star
+`,
+				"label.star":  label,
+				"check.star":  test.validator,
+				"deploy.yaml": deploy,
+			})
+			defer os.RemoveAll(dir)
+
+			out := &bytes.Buffer{}
+			err := Command{Path: dir, DryRun: test.dryRun, Output: out, Log: ioutil.Discard}.Run()
+			if test.err != "" {
+				if assert.Error(t, err) {
+					assert.Contains(t, err.Error(), test.err)
+				}
+			} else if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			assert.Equal(t, test.output, out.String())
+
+			b, err := ioutil.ReadFile(filepath.Join(dir, "deploy.yaml"))
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			assert.Equal(t, deploy, string(b))
+		})
+	}
+}
+
+// TestCommand_Run_inherit verifies that subpackages run the pipeline
+// functions they inherit, overridden by their own, in place of the packages
+// containing them, and are set with the setter values they inherit.
+func TestCommand_Run_inherit(t *testing.T) {
+	dir := pkgbuilder.WritePackage(t, map[string]string{
+		"Kptfile": `apiVersion: kpt.dev/v1alpha1
+kind: Kptfile
+metadata:
+  name: app
+openAPI:
+  definitions:
+    io.k8s.cli.setters.replicas:
+      x-k8s-cli:
+        setter:
+          name: replicas
+          value: "5"
+          isSet: true
+pipeline:
+  mutators:
+  - name: label
+    starlark: label.star
+    config:
+      apiVersion: v1
+      kind: ConfigMap
+      metadata:
+        name: label
+      data:
+        key: app
+        value: web
+  - name: count
+    starlark: count.star
+`,
+		"label.star": label,
+		"count.star": `
+def count(items):
+  for item in items:
+    annotations = item["metadata"].setdefault("annotations", {})
+    annotations["runs"] = annotations.get("runs", "") + "x"
+count(ctx.resource_list["items"])
+`,
+		"deploy.yaml": `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+`,
+		"db/Kptfile": `apiVersion: kpt.dev/v1alpha1
+kind: Kptfile
+metadata:
+  name: db
+openAPI:
+  definitions:
+    io.k8s.cli.setters.replicas:
+      x-k8s-cli:
+        setter:
+          name: replicas
+          value: "1"
+pipeline:
+  mutators:
+  - name: label
+    starlark: label.star
+    configPath: tier.yaml
+inherit:
+  pipeline: true
+  setters: true
+`,
+		"db/label.star": label,
+		"db/tier.yaml": `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: tier
+  annotations:
+    config.kubernetes.io/local-config: "true"
+data:
+  key: tier
+  value: db
+`,
+		"db/statefulset.yaml": `apiVersion: apps/v1
+kind: StatefulSet
+metadata:
+  name: db
+spec:
+  replicas: 1 # {"$kpt-set":"replicas"}
+`,
+	})
+	defer os.RemoveAll(dir)
+
+	out := &bytes.Buffer{}
+	if !assert.NoError(t, Command{Path: filepath.Join(dir, "db"), Output: ioutil.Discard}.Run()) {
+		t.FailNow()
+	}
+	if !assert.NoError(t, Command{Path: dir, Output: out}.Run()) {
+		t.FailNow()
+	}
+	assert.Equal(t, `running mutator label on db
+running mutator count on db
+running mutator label on .
+running mutator count on .
+`, out.String())
+
+	b, err := ioutil.ReadFile(filepath.Join(dir, "deploy.yaml"))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  labels:
+    app: web
+  annotations:
+    runs: x
+`, string(b))
+
+	// the subpackage was rendered on its own, then with its parent
+	b, err = ioutil.ReadFile(filepath.Join(dir, "db", "statefulset.yaml"))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, `apiVersion: apps/v1
+kind: StatefulSet
+metadata:
+  name: db
+  labels:
+    tier: db
+  annotations:
+    runs: xx
+spec:
+  replicas: 5 # {"$kpt-set":"replicas"}
+`, string(b))
+}
+
+// TestCommand_Run_secrets verifies the values of secret setters are only
+// injected into the resources rendered to the output.
+func TestCommand_Run_secrets(t *testing.T) {
+	secret := `apiVersion: v1
+kind: Secret
+metadata:
+  name: db
+stringData:
+  password: changeme # {"$kpt-set":"db-password"}
+`
+	dir := pkgbuilder.WritePackage(t, map[string]string{
+		"Kptfile": `apiVersion: kpt.dev/v1alpha1
+kind: Kptfile
+metadata:
+  name: app
+openAPI:
+  definitions:
+    io.k8s.cli.setters.db-password:
+      type: string
+      x-k8s-cli:
+        setter:
+          name: db-password
+          value: changeme
+      x-kpt:
+        secret:
+          from: file:password.txt
+`,
+		"password.txt": "s3cr3t\n",
+		"secret.yaml":  secret,
+	})
+	defer os.RemoveAll(dir)
+
+	out := &bytes.Buffer{}
+	if !assert.NoError(t, Command{Path: dir, Output: out, DryRun: true}.Run()) {
+		t.FailNow()
+	}
+	assert.Contains(t, out.String(), `password: s3cr3t # {"$kpt-set":"db-password"}`)
+
+	if !assert.NoError(t, Command{Path: dir, Output: ioutil.Discard}.Run()) {
+		t.FailNow()
+	}
+	b, err := ioutil.ReadFile(filepath.Join(dir, "secret.yaml"))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, secret, string(b))
+}
+
+// TestCommand_Run_conditions verifies that the resources excluded by their
+// conditions aren't rendered or output, and are written back unchanged.
+func TestCommand_Run_conditions(t *testing.T) {
+	monitor := `apiVersion: monitoring.coreos.com/v1
+kind: ServiceMonitor
+metadata:
+  name: web
+  annotations:
+    config.kpt.dev/condition: enable-monitoring
+`
+	dir := pkgbuilder.WritePackage(t, map[string]string{
+		"Kptfile": `apiVersion: kpt.dev/v1alpha1
+kind: Kptfile
+metadata:
+  name: app
+conditions:
+  enable-monitoring: false
+pipeline:
+  mutators:
+  - name: label
+    starlark: label.star
+    config:
+      apiVersion: v1
+      kind: ConfigMap
+      metadata:
+        name: label
+      data:
+        key: app
+        value: web
+`,
+		"label.star":   label,
+		"monitor.yaml": monitor,
+		"deploy.yaml": `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  annotations:
+    config.kpt.dev/condition: '!enable-monitoring'
+`,
+	})
+	defer os.RemoveAll(dir)
+
+	out := &bytes.Buffer{}
+	if !assert.NoError(t, Command{Path: dir, Output: out, DryRun: true}.Run()) {
+		t.FailNow()
+	}
+	assert.Contains(t, out.String(), "kind: Deployment")
+	assert.NotContains(t, out.String(), "kind: ServiceMonitor")
+
+	if !assert.NoError(t, Command{Path: dir, Output: ioutil.Discard}.Run()) {
+		t.FailNow()
+	}
+	b, err := ioutil.ReadFile(filepath.Join(dir, "monitor.yaml"))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, monitor, string(b))
+	b, err = ioutil.ReadFile(filepath.Join(dir, "deploy.yaml"))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Contains(t, string(b), "app: web")
+}
+
+// TestCommand_Run_requirePinned verifies that the upstreams and dependencies
+// of every package in the rendered tree must be pinned before any function
+// is run.
+func TestCommand_Run_requirePinned(t *testing.T) {
+	dir := pkgbuilder.WritePackage(t, map[string]string{
+		"Kptfile": `apiVersion: kpt.dev/v1alpha1
+kind: Kptfile
+metadata:
+  name: app
+upstream:
+  type: git
+  git:
+    repo: https://example.com/app
+    directory: /
+    ref: refs/tags/v1.0.0
+pipeline:
+  mutators:
+  - name: label
+    starlark: label.star
+    config:
+      apiVersion: v1
+      kind: ConfigMap
+      data:
+        key: app
+        value: web
+`,
+		"label.star": label,
+		"deploy.yaml": `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+`,
+		"db/Kptfile": `apiVersion: kpt.dev/v1alpha1
+kind: Kptfile
+metadata:
+  name: db
+dependencies:
+- name: mysql
+  git:
+    repo: https://example.com/mysql
+    directory: /
+    ref: refs/heads/main
+- name: removed
+  ensureNotExists: true
+  git:
+    repo: https://example.com/removed
+    ref: latest
+`,
+	})
+	defer os.RemoveAll(dir)
+
+	err := Command{Path: dir, RequirePinned: true, Output: ioutil.Discard}.Run()
+	if assert.Error(t, err) {
+		assert.Equal(t, `package "db" dependency "mysql": upstream "https://example.com/mysql" `+
+			`ref "refs/heads/main" is a branch, pinned upstreams are required -- use a tag or commit`,
+			err.Error())
+	}
+	b, err := ioutil.ReadFile(filepath.Join(dir, "deploy.yaml"))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.NotContains(t, string(b), "app: web")
+
+	if !assert.NoError(t, Command{Path: dir, Output: ioutil.Discard}.Run()) {
+		t.FailNow()
+	}
+	b, err = ioutil.ReadFile(filepath.Join(dir, "deploy.yaml"))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Contains(t, string(b), "app: web")
+}
@@ -0,0 +1,349 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package resources estimates the cluster resources requested by packages.
+package resources
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	"sigs.k8s.io/kustomize/kyaml/errors"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/kio/filters"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// Output formats.
+const (
+	TableOutput = "table"
+	JSONOutput  = "json"
+)
+
+// Usage is the resources requested by the workloads in a namespace.
+type Usage struct {
+	// Namespace is the namespace of the workloads, empty for workloads
+	// which don't declare one
+	Namespace string `json:"namespace"`
+
+	// Replicas is the number of pods.  DaemonSets count as one pod, and Jobs
+	// as their parallelism.
+	Replicas int64 `json:"replicas"`
+
+	// CPU and memory requests and limits of the pods
+	CPURequests    resource.Quantity `json:"cpuRequests"`
+	CPULimits      resource.Quantity `json:"cpuLimits"`
+	MemoryRequests resource.Quantity `json:"memoryRequests"`
+	MemoryLimits   resource.Quantity `json:"memoryLimits"`
+
+	// Storage is the storage requested by PersistentVolumeClaims and
+	// StatefulSet volumeClaimTemplates
+	Storage resource.Quantity `json:"storage"`
+
+	// Unbounded is the number of containers, across the replicas, without
+	// a cpu or memory limit
+	Unbounded int64 `json:"unbounded"`
+}
+
+// Report is the resources requested by a package.
+type Report struct {
+	// Namespaces is the usage of each namespace, sorted by namespace
+	Namespaces []Usage `json:"namespaces"`
+
+	// Total is the usage of every namespace
+	Total Usage `json:"total"`
+}
+
+// Command reports the resources requested by the package at Dir.
+type Command struct {
+	// Dir is the package directory
+	Dir string
+
+	// Output is the format of the report, TableOutput or JSONOutput
+	Output string
+
+	// StdOut is where the report is written
+	StdOut io.Writer
+}
+
+// Run writes the report to StdOut.
+func (c Command) Run() error {
+	if c.Output == "" {
+		c.Output = TableOutput
+	}
+	if c.Output != TableOutput && c.Output != JSONOutput {
+		return errors.Errorf("unsupported output %q, must be one of %s, %s",
+			c.Output, TableOutput, JSONOutput)
+	}
+	var report Report
+	err := kio.Pipeline{
+		Inputs: []kio.Reader{&kio.LocalPackageReader{PackagePath: c.Dir}},
+		Filters: []kio.Filter{
+			&filters.IsLocalConfig{},
+			kio.FilterFunc(func(nodes []*yaml.RNode) ([]*yaml.RNode, error) {
+				var err error
+				report, err = Estimate(nodes)
+				return nodes, err
+			}),
+		},
+	}.Execute()
+	if err != nil {
+		return err
+	}
+	if c.Output == JSONOutput {
+		e := json.NewEncoder(c.StdOut)
+		e.SetIndent("", "  ")
+		return errors.Wrap(e.Encode(report))
+	}
+	return WriteTable(c.StdOut, report)
+}
+
+// container is the resources of a container.
+type container struct {
+	Name      string `yaml:"name"`
+	Resources struct {
+		Requests map[string]string `yaml:"requests"`
+		Limits   map[string]string `yaml:"limits"`
+	} `yaml:"resources"`
+}
+
+// podSpec is the containers of a pod.
+type podSpec struct {
+	Containers     []container `yaml:"containers"`
+	InitContainers []container `yaml:"initContainers"`
+}
+
+// podTemplates are the paths of the pod spec of each workload kind.
+var podTemplates = map[string][]string{
+	"Pod":                   {"spec"},
+	"Deployment":            {"spec", "template", "spec"},
+	"ReplicaSet":            {"spec", "template", "spec"},
+	"ReplicationController": {"spec", "template", "spec"},
+	"StatefulSet":           {"spec", "template", "spec"},
+	"DaemonSet":             {"spec", "template", "spec"},
+	"Job":                   {"spec", "template", "spec"},
+	"CronJob":               {"spec", "jobTemplate", "spec", "template", "spec"},
+}
+
+// replicaFields are the paths of the number of pods of each workload kind,
+// which default to 1.
+var replicaFields = map[string][]string{
+	"Deployment":            {"spec", "replicas"},
+	"ReplicaSet":            {"spec", "replicas"},
+	"ReplicationController": {"spec", "replicas"},
+	"StatefulSet":           {"spec", "replicas"},
+	"Job":                   {"spec", "parallelism"},
+	"CronJob":               {"spec", "jobTemplate", "spec", "parallelism"},
+}
+
+// Estimate returns the resources requested by nodes.
+func Estimate(nodes []*yaml.RNode) (Report, error) {
+	usage := map[string]*Usage{}
+	for _, node := range nodes {
+		meta, err := node.GetMeta()
+		if err != nil {
+			return Report{}, err
+		}
+		u, found := usage[meta.Namespace]
+		if !found {
+			u = &Usage{Namespace: meta.Namespace}
+			usage[meta.Namespace] = u
+		}
+		if err := add(u, node, meta); err != nil {
+			return Report{}, errors.Errorf("%s %s: %v", meta.Kind, meta.Name, err)
+		}
+	}
+
+	var report Report
+	for _, u := range usage {
+		report.Namespaces = append(report.Namespaces, *u)
+		report.Total.add(*u)
+	}
+	sort.Slice(report.Namespaces, func(i, j int) bool {
+		return report.Namespaces[i].Namespace < report.Namespaces[j].Namespace
+	})
+	if report.Namespaces == nil {
+		report.Namespaces = []Usage{}
+	}
+	return report, nil
+}
+
+// add adds the resources requested by node to u.
+func add(u *Usage, node *yaml.RNode, meta yaml.ResourceMeta) error {
+	if meta.Kind == "PersistentVolumeClaim" {
+		return addStorage(&u.Storage, node, 1)
+	}
+	path, found := podTemplates[meta.Kind]
+	if !found {
+		return nil
+	}
+	replicas := int64(1)
+	if field, found := replicaFields[meta.Kind]; found {
+		r, err := node.Pipe(yaml.Lookup(field...))
+		if err != nil {
+			return err
+		}
+		if r != nil {
+			if err := r.Document().Decode(&replicas); err != nil {
+				return errors.Errorf("invalid %s: %v", field[len(field)-1], err)
+			}
+		}
+	}
+
+	spec, err := node.Pipe(yaml.Lookup(path...))
+	if err != nil || spec == nil {
+		return err
+	}
+	var pod podSpec
+	if err := spec.Document().Decode(&pod); err != nil {
+		return err
+	}
+	var p Usage
+	for _, f := range []struct {
+		total  *resource.Quantity
+		name   string
+		limits bool
+	}{
+		{&p.CPURequests, "cpu", false},
+		{&p.CPULimits, "cpu", true},
+		{&p.MemoryRequests, "memory", false},
+		{&p.MemoryLimits, "memory", true},
+	} {
+		q, err := podQuantity(pod, f.name, f.limits)
+		if err != nil {
+			return err
+		}
+		*f.total = q
+	}
+	for _, c := range pod.Containers {
+		if c.Resources.Limits["cpu"] == "" || c.Resources.Limits["memory"] == "" {
+			p.Unbounded++
+		}
+	}
+	p.Replicas = 1
+
+	for i := int64(0); i < replicas; i++ {
+		u.add(p)
+	}
+
+	if meta.Kind == "StatefulSet" {
+		templates, err := node.Pipe(yaml.Lookup("spec", "volumeClaimTemplates"))
+		if err != nil || templates == nil {
+			return err
+		}
+		elements, err := templates.Elements()
+		if err != nil {
+			return err
+		}
+		for _, t := range elements {
+			if err := addStorage(&u.Storage, t, replicas); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// podQuantity returns the request, or limit, of resource name of pod: the
+// larger of the sum over its containers, and the largest init container.
+func podQuantity(pod podSpec, name string, limits bool) (resource.Quantity, error) {
+	quantity := func(c container) (resource.Quantity, error) {
+		values := c.Resources.Requests
+		if limits {
+			values = c.Resources.Limits
+		}
+		if values[name] == "" {
+			return resource.Quantity{}, nil
+		}
+		q, err := resource.ParseQuantity(values[name])
+		if err != nil {
+			return q, errors.Errorf("container %s: invalid %s %q: %v", c.Name, name, values[name], err)
+		}
+		return q, nil
+	}
+
+	var sum, init resource.Quantity
+	for _, c := range pod.Containers {
+		q, err := quantity(c)
+		if err != nil {
+			return sum, err
+		}
+		sum.Add(q)
+	}
+	for _, c := range pod.InitContainers {
+		q, err := quantity(c)
+		if err != nil {
+			return sum, err
+		}
+		if q.Cmp(init) > 0 {
+			init = q
+		}
+	}
+	if init.Cmp(sum) > 0 {
+		return init, nil
+	}
+	return sum, nil
+}
+
+// addStorage adds the storage requested by the PersistentVolumeClaim node,
+// times count, to total.
+func addStorage(total *resource.Quantity, node *yaml.RNode, count int64) error {
+	s, err := node.Pipe(yaml.Lookup("spec", "resources", "requests", "storage"))
+	if err != nil || s == nil {
+		return err
+	}
+	q, err := resource.ParseQuantity(yaml.GetValue(s))
+	if err != nil {
+		return errors.Errorf("invalid storage %q: %v", yaml.GetValue(s), err)
+	}
+	for i := int64(0); i < count; i++ {
+		total.Add(q)
+	}
+	return nil
+}
+
+// add adds the usage of o to u.
+func (u *Usage) add(o Usage) {
+	u.Replicas += o.Replicas
+	u.CPURequests.Add(o.CPURequests)
+	u.CPULimits.Add(o.CPULimits)
+	u.MemoryRequests.Add(o.MemoryRequests)
+	u.MemoryLimits.Add(o.MemoryLimits)
+	u.Storage.Add(o.Storage)
+	u.Unbounded += o.Unbounded
+}
+
+// WriteTable writes report as a table to w.
+func WriteTable(w io.Writer, report Report) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAMESPACE\tREPLICAS\tCPU REQUESTS\tCPU LIMITS\tMEMORY REQUESTS\tMEMORY LIMITS\tSTORAGE\tUNBOUNDED")
+	row := func(name string, u Usage) {
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t%s\t%s\t%s\t%d\n", name, u.Replicas,
+			u.CPURequests.String(), u.CPULimits.String(), u.MemoryRequests.String(),
+			u.MemoryLimits.String(), u.Storage.String(), u.Unbounded)
+	}
+	for _, u := range report.Namespaces {
+		name := u.Namespace
+		if name == "" {
+			name = "<none>"
+		}
+		row(name, u)
+	}
+	row("TOTAL", report.Total)
+	return errors.Wrap(tw.Flush())
+}
@@ -0,0 +1,172 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resources_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	. "github.com/GoogleContainerTools/kpt/internal/util/resources"
+	"github.com/stretchr/testify/assert"
+)
+
+const pkg = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: prod
+spec:
+  replicas: 3
+  template:
+    spec:
+      initContainers:
+      - name: migrate
+        resources:
+          requests:
+            cpu: "2"
+      containers:
+      - name: web
+        resources:
+          requests:
+            cpu: 250m
+            memory: 256Mi
+          limits:
+            cpu: 500m
+            memory: 512Mi
+      - name: sidecar
+        resources:
+          requests:
+            cpu: 250m
+            memory: 64Mi
+---
+apiVersion: apps/v1
+kind: StatefulSet
+metadata:
+  name: db
+  namespace: prod
+spec:
+  replicas: 2
+  template:
+    spec:
+      containers:
+      - name: db
+        resources:
+          requests:
+            cpu: "1"
+            memory: 1Gi
+          limits:
+            cpu: "1"
+            memory: 1Gi
+  volumeClaimTemplates:
+  - metadata:
+      name: data
+    spec:
+      resources:
+        requests:
+          storage: 10Gi
+---
+apiVersion: v1
+kind: PersistentVolumeClaim
+metadata:
+  name: cache
+spec:
+  resources:
+    requests:
+      storage: 5Gi
+---
+apiVersion: batch/v1
+kind: Job
+metadata:
+  name: backfill
+spec:
+  parallelism: 4
+  template:
+    spec:
+      containers:
+      - name: backfill
+        resources:
+          requests:
+            memory: 128Mi
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: local
+  annotations:
+    config.kubernetes.io/local-config: "true"
+data: {}
+`
+
+func TestCommand_Run(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kpt-resources")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir)
+	err = ioutil.WriteFile(filepath.Join(dir, "resources.yaml"), []byte(pkg), 0600)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	b := &bytes.Buffer{}
+	err = Command{Dir: dir, StdOut: b}.Run()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, `NAMESPACE  REPLICAS  CPU REQUESTS  CPU LIMITS  MEMORY REQUESTS  MEMORY LIMITS  STORAGE  UNBOUNDED
+<none>     4         0             0           512Mi            0              5Gi      4
+prod       5         8             3500m       3008Mi           3584Mi         20Gi     3
+TOTAL      9         8             3500m       3520Mi           3584Mi         25Gi     7
+`, b.String())
+
+	b.Reset()
+	err = Command{Dir: dir, StdOut: b, Output: JSONOutput}.Run()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Contains(t, b.String(), `"namespace": "prod",
+      "replicas": 5,
+      "cpuRequests": "8",`)
+}
+
+func TestCommand_Run_invalidQuantity(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kpt-resources")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir)
+	err = ioutil.WriteFile(filepath.Join(dir, "pod.yaml"), []byte(`apiVersion: v1
+kind: Pod
+metadata:
+  name: app
+spec:
+  containers:
+  - name: app
+    resources:
+      requests:
+        cpu: lots
+`), 0600)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	err = Command{Dir: dir, StdOut: &bytes.Buffer{}}.Run()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), `Pod app: container app: invalid cpu "lots"`)
+	}
+}
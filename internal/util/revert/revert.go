@@ -0,0 +1,240 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package revert snapshots packages before they are updated, so the most
+// recent update of a package can be reverted.
+package revert
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/GoogleContainerTools/kpt/internal/util/digest"
+	"github.com/GoogleContainerTools/kpt/pkg/kptfile"
+	"github.com/GoogleContainerTools/kpt/pkg/kptfile/kptfileutil"
+	"sigs.k8s.io/kustomize/kyaml/copyutil"
+	"sigs.k8s.io/kustomize/kyaml/errors"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// DirEnv is the name of the environment variable that controls where the
+// snapshots are stored.  Defaults to UserHomeDir/.kpt/revert.
+const DirEnv = "KPT_REVERT_DIR"
+
+const (
+	// snapshotFile records the snapshot metadata
+	snapshotFile = "snapshot.yaml"
+
+	// filesDir contains the files of the package before the update
+	filesDir = "files"
+)
+
+// Snapshot is the state of a package before it was updated.
+type Snapshot struct {
+	// Path is the absolute path to the package
+	Path string `yaml:"path"`
+
+	// Time is when the package was updated
+	Time time.Time `yaml:"time"`
+
+	// Upstream is the upstream of the package before the update
+	Upstream kptfile.Git `yaml:"upstream"`
+
+	// Updated is the hash of the package contents after the update, or
+	// empty if the update didn't complete
+	Updated string `yaml:"updated,omitempty"`
+}
+
+// Dir returns the directory the snapshots are stored in.
+func Dir() (string, error) {
+	if dir := os.Getenv(DirEnv); dir != "" {
+		return dir, nil
+	}
+	dir, err := os.UserHomeDir()
+	if err != nil {
+		return "", errors.Wrap(err)
+	}
+	return filepath.Join(dir, ".kpt", "revert"), nil
+}
+
+// snapshotDir returns the directory storing the snapshot of the package at
+// the absolute path.
+func snapshotDir(path string) (string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, fmt.Sprintf("%x", sha256.Sum256([]byte(path)))[:16]), nil
+}
+
+// Save snapshots the package at path before it is updated, replacing the
+// snapshot of its previous update.
+func Save(path string) error {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	dir, err := snapshotDir(path)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(dir), 0700); err != nil {
+		return errors.Wrap(err)
+	}
+	k, err := kptfileutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	// stage the snapshot so a failure doesn't leave a partial one
+	staging, err := ioutil.TempDir(filepath.Dir(dir), ".snapshot-")
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	defer os.RemoveAll(staging)
+	if err := copyutil.CopyDir(path, filepath.Join(staging, filesDir)); err != nil {
+		return errors.Wrap(err)
+	}
+	s := Snapshot{Path: path, Time: time.Now().UTC(), Upstream: k.Upstream.Git}
+	if err := s.write(staging); err != nil {
+		return err
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return errors.Wrap(err)
+	}
+	return errors.Wrap(os.Rename(staging, dir))
+}
+
+// Complete records that the update of the package at path completed, so
+// changes made to the package after the update can be detected.
+func Complete(path string) error {
+	s, dir, err := Load(path)
+	if err != nil || s == nil {
+		return err
+	}
+	if s.Updated, err = digest.Dir(s.Path); err != nil {
+		return err
+	}
+	return s.write(dir)
+}
+
+// Load returns the snapshot of the package at path and the directory it is
+// stored in, or nil if there is none.
+func Load(path string) (*Snapshot, string, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, "", errors.Wrap(err)
+	}
+	dir, err := snapshotDir(path)
+	if err != nil {
+		return nil, "", err
+	}
+	b, err := ioutil.ReadFile(filepath.Join(dir, snapshotFile))
+	if os.IsNotExist(err) {
+		return nil, dir, nil
+	}
+	if err != nil {
+		return nil, "", errors.Wrap(err)
+	}
+	s := &Snapshot{}
+	if err := yaml.Unmarshal(b, s); err != nil {
+		return nil, "", errors.Errorf("invalid snapshot %q: %v", dir, err)
+	}
+	return s, dir, nil
+}
+
+func (s Snapshot) write(dir string) error {
+	b, err := yaml.Marshal(s)
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	return errors.Wrap(ioutil.WriteFile(filepath.Join(dir, snapshotFile), b, 0600))
+}
+
+// Command reverts the most recent update of a package, restoring its
+// contents from before the update.  Only the package directory is changed.
+type Command struct {
+	// Path is the path to the package
+	Path string
+
+	// Force reverts the package even if it was changed after the update,
+	// discarding those changes
+	Force bool
+
+	StdOut io.Writer
+}
+
+// Run runs the Command.
+func (c Command) Run() error {
+	s, dir, err := Load(c.Path)
+	if err != nil {
+		return err
+	}
+	if s == nil {
+		return errors.Errorf("no update of package %q to revert", c.Path)
+	}
+	if s.Updated != "" && !c.Force {
+		current, err := digest.Dir(s.Path)
+		if err != nil {
+			return err
+		}
+		if current != s.Updated {
+			return errors.Errorf("package %q was changed after it was updated -- "+
+				"use --force to revert it, discarding the changes", c.Path)
+		}
+	}
+
+	// restore the package from a copy of the snapshot, so a failure leaves
+	// the snapshot intact
+	staging, err := ioutil.TempDir(filepath.Dir(s.Path), ".kpt-revert-")
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	defer os.RemoveAll(staging)
+	files := filepath.Join(dir, filesDir)
+	if err := copyutil.CopyDir(files, staging); err != nil {
+		return errors.Wrap(err)
+	}
+	info, err := os.Stat(files)
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	if err := os.Chmod(staging, info.Mode()); err != nil {
+		return errors.Wrap(err)
+	}
+	if err := os.RemoveAll(s.Path); err != nil {
+		return errors.Wrap(err)
+	}
+	if err := os.Rename(staging, s.Path); err != nil {
+		return errors.Wrap(err)
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return errors.Wrap(err)
+	}
+
+	if c.StdOut != nil {
+		ref := s.Upstream.Ref
+		if s.Upstream.Commit != "" {
+			ref = fmt.Sprintf("%s (%s)", ref, s.Upstream.Commit)
+		}
+		fmt.Fprintf(c.StdOut, "reverted package %q to %s, from before its update at %s\n",
+			c.Path, ref, s.Time.Format(time.RFC3339))
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package revert_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt/internal/testutil"
+	"github.com/GoogleContainerTools/kpt/internal/util/revert"
+	"github.com/GoogleContainerTools/kpt/internal/util/update"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCommand_Run(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kpt-revert-test")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir)
+	defer os.Unsetenv(revert.DirEnv)
+	if !assert.NoError(t, os.Setenv(revert.DirEnv, dir)) {
+		t.FailNow()
+	}
+
+	g := &testutil.TestSetupManager{
+		T:               t,
+		UpstreamChanges: []testutil.Content{{Data: testutil.Dataset2}},
+	}
+	defer g.Clean()
+	if !g.Init(testutil.Dataset1) {
+		t.FailNow()
+	}
+	kf, err := ioutil.ReadFile(filepath.Join(g.UpstreamRepo.RepoName, "Kptfile"))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	// nothing to revert before the package is updated
+	err = revert.Command{Path: g.UpstreamRepo.RepoName}.Run()
+	assert.EqualError(t, err, `no update of package "`+g.UpstreamRepo.RepoName+`" to revert`)
+
+	err = update.Command{
+		Path:            g.UpstreamRepo.RepoName,
+		FullPackagePath: filepath.Join(g.LocalWorkspace.WorkspaceDirectory, g.UpstreamRepo.RepoName),
+		Strategy:        update.KResourceMerge,
+	}.Run()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	if !g.AssertLocalDataEquals(testutil.Dataset2) {
+		t.FailNow()
+	}
+
+	// changes made after the update aren't discarded without --force
+	extra := filepath.Join(g.UpstreamRepo.RepoName, "extra.yaml")
+	if !assert.NoError(t, ioutil.WriteFile(extra, []byte("a: b\n"), 0600)) {
+		t.FailNow()
+	}
+	err = revert.Command{Path: g.UpstreamRepo.RepoName}.Run()
+	assert.EqualError(t, err, `package "`+g.UpstreamRepo.RepoName+`" was changed after it was updated -- `+
+		`use --force to revert it, discarding the changes`)
+
+	b := &bytes.Buffer{}
+	err = revert.Command{Path: g.UpstreamRepo.RepoName, Force: true, StdOut: b}.Run()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Contains(t, b.String(), `reverted package "`+g.UpstreamRepo.RepoName+`" to master (`)
+	if !g.AssertLocalDataEquals(testutil.Dataset1) {
+		t.FailNow()
+	}
+	reverted, err := ioutil.ReadFile(filepath.Join(g.UpstreamRepo.RepoName, "Kptfile"))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, string(kf), string(reverted))
+
+	// only the most recent update can be reverted
+	err = revert.Command{Path: g.UpstreamRepo.RepoName}.Run()
+	assert.Error(t, err)
+}
@@ -0,0 +1,251 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scaffold
+
+import (
+	"github.com/GoogleContainerTools/kpt/internal/util/clusterpkg"
+	"github.com/GoogleContainerTools/kpt/pkg/kptfile"
+)
+
+// builtins are the built-in templates, by name.
+var builtins = map[string]builtin{
+	"app":          app,
+	"namespace":    namespace,
+	"crd-operator": crdOperator,
+}
+
+// app is a Deployment exposed by a Service.
+var app = builtin{
+	files: map[string]string{
+		"deployment.yaml": `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: {{.Name}}
+spec:
+  replicas: 1
+  selector:
+    matchLabels:
+      app.kubernetes.io/name: {{.Name}}
+  template:
+    metadata:
+      labels:
+        app.kubernetes.io/name: {{.Name}}
+    spec:
+      containers:
+      - name: {{.Name}}
+        image: nginx:1.19
+        ports:
+        - containerPort: 80
+`,
+		"service.yaml": `apiVersion: v1
+kind: Service
+metadata:
+  name: {{.Name}}
+spec:
+  selector:
+    app.kubernetes.io/name: {{.Name}}
+  ports:
+  - port: 80
+    targetPort: 80
+`,
+		"fns/default-requests.star": `# sets default resource requests on the containers which don't request any
+def default_requests(resources):
+  for r in resources:
+    if r["kind"] not in ["Deployment", "StatefulSet", "DaemonSet"]:
+      continue
+    for c in r["spec"]["template"]["spec"]["containers"]:
+      if "resources" not in c:
+        c["resources"] = {"requests": {"cpu": "100m", "memory": "128Mi"}}
+
+default_requests(ctx.resource_list["items"])
+`,
+	},
+	kptfile: kptfile.KptFile{
+		CommonLabels: map[string]string{"app.kubernetes.io/part-of": "{{.Name}}"},
+		Functions: kptfile.Functions{StarlarkFunctions: []kptfile.StarlarkFunction{
+			{Name: "default-requests", Path: "fns/default-requests.star"},
+		}},
+	},
+	setters: []clusterpkg.Setter{
+		{Name: "image", FieldName: "image", Value: "nginx:1.19"},
+		{Name: "replicas", FieldName: "replicas", Value: "1"},
+	},
+}
+
+// namespace is a Namespace with a quota and default limits.
+var namespace = builtin{
+	files: map[string]string{
+		"namespace.yaml": `apiVersion: v1
+kind: Namespace
+metadata:
+  name: {{.Name}}
+`,
+		"quota.yaml": `apiVersion: v1
+kind: ResourceQuota
+metadata:
+  name: quota
+  namespace: {{.Name}}
+spec:
+  hard:
+    pods: "20"
+    requests.cpu: "4"
+    requests.memory: 8Gi
+`,
+		"limits.yaml": `apiVersion: v1
+kind: LimitRange
+metadata:
+  name: limits
+  namespace: {{.Name}}
+spec:
+  limits:
+  - type: Container
+    default:
+      cpu: 500m
+      memory: 512Mi
+    defaultRequest:
+      cpu: 100m
+      memory: 128Mi
+`,
+		"fns/set-namespace.star": `# sets the namespace of the namespaced resources to the package's Namespace
+cluster_scoped = ["Namespace", "ClusterRole", "ClusterRoleBinding", "CustomResourceDefinition"]
+
+def set_namespace(resources):
+  namespaces = [r["metadata"]["name"] for r in resources if r["kind"] == "Namespace"]
+  if len(namespaces) != 1:
+    return
+  for r in resources:
+    if r["kind"] not in cluster_scoped:
+      r["metadata"]["namespace"] = namespaces[0]
+
+set_namespace(ctx.resource_list["items"])
+`,
+	},
+	kptfile: kptfile.KptFile{
+		Functions: kptfile.Functions{StarlarkFunctions: []kptfile.StarlarkFunction{
+			{Name: "set-namespace", Path: "fns/set-namespace.star"},
+		}},
+	},
+	setters: []clusterpkg.Setter{
+		{Name: "namespace", Value: "{{.Name}}"},
+		{Name: "max-pods", FieldName: "pods", Value: "20"},
+	},
+}
+
+// crdOperator is a CustomResourceDefinition and the controller reconciling
+// it.
+var crdOperator = builtin{
+	files: map[string]string{
+		"crd.yaml": `apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: {{.Plural}}.example.com
+spec:
+  group: example.com
+  names:
+    kind: {{.Kind}}
+    plural: {{.Plural}}
+  scope: Namespaced
+  versions:
+  - name: v1alpha1
+    served: true
+    storage: true
+    subresources:
+      status: {}
+    schema:
+      openAPIV3Schema:
+        type: object
+        x-kubernetes-preserve-unknown-fields: true
+`,
+		"rbac.yaml": `apiVersion: v1
+kind: ServiceAccount
+metadata:
+  name: {{.Name}}
+  namespace: {{.Name}}-system
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRole
+metadata:
+  name: {{.Name}}-manager
+rules:
+- apiGroups: [""]
+  resources: [events]
+  verbs: [create, patch]
+---
+apiVersion: rbac.authorization.k8s.io/v1
+kind: ClusterRoleBinding
+metadata:
+  name: {{.Name}}-manager
+roleRef:
+  apiGroup: rbac.authorization.k8s.io
+  kind: ClusterRole
+  name: {{.Name}}-manager
+subjects:
+- kind: ServiceAccount
+  name: {{.Name}}
+  namespace: {{.Name}}-system
+`,
+		"manager.yaml": `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: {{.Name}}
+  namespace: {{.Name}}-system
+spec:
+  replicas: 1
+  selector:
+    matchLabels:
+      app.kubernetes.io/name: {{.Name}}
+  template:
+    metadata:
+      labels:
+        app.kubernetes.io/name: {{.Name}}
+    spec:
+      serviceAccountName: {{.Name}}
+      containers:
+      - name: manager
+        image: example.com/{{.Name}}:v0.1.0
+`,
+		"fns/grant-crds.star": `# grants the manager access to the CustomResourceDefinitions of the package
+def grant_crds(resources):
+  for role in resources:
+    if role["kind"] != "ClusterRole" or role["metadata"]["name"] != "{{.Name}}-manager":
+      continue
+    rules = role.get("rules") or []
+    granted = [g for rule in rules for g in rule.get("apiGroups", [])]
+    for crd in resources:
+      if crd["kind"] != "CustomResourceDefinition" or crd["spec"]["group"] in granted:
+        continue
+      plural = crd["spec"]["names"]["plural"]
+      rules.append({
+        "apiGroups": [crd["spec"]["group"]],
+        "resources": [plural, plural + "/status"],
+        "verbs": ["get", "list", "watch", "create", "update", "patch", "delete"],
+      })
+      granted.append(crd["spec"]["group"])
+    role["rules"] = rules
+
+grant_crds(ctx.resource_list["items"])
+`,
+	},
+	kptfile: kptfile.KptFile{
+		CommonLabels: map[string]string{"app.kubernetes.io/part-of": "{{.Name}}"},
+		Functions: kptfile.Functions{StarlarkFunctions: []kptfile.StarlarkFunction{
+			{Name: "grant-crds", Path: "fns/grant-crds.star"},
+		}},
+	},
+	setters: []clusterpkg.Setter{
+		{Name: "namespace", FieldName: "namespace", Value: "{{.Name}}-system"},
+		{Name: "image", FieldName: "image", Value: "example.com/{{.Name}}:v0.1.0"},
+	},
+}
@@ -0,0 +1,224 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package scaffold generates the starter contents of new packages from
+// templates.
+package scaffold
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/GoogleContainerTools/kpt/internal/util/clusterpkg"
+	"github.com/GoogleContainerTools/kpt/internal/util/get"
+	"github.com/GoogleContainerTools/kpt/internal/util/parse"
+	"github.com/GoogleContainerTools/kpt/internal/util/tmputil"
+	"github.com/GoogleContainerTools/kpt/pkg/kptfile"
+	"github.com/GoogleContainerTools/kpt/pkg/kptfile/kptfileutil"
+	"sigs.k8s.io/kustomize/kyaml/errors"
+)
+
+// Template is the starter contents of a package.
+type Template struct {
+	// Files are the contents of the files of the package, other than its
+	// Kptfile, keyed by their path
+	Files map[string]string
+
+	// Kptfile contains the function pipeline, and for templates fetched
+	// from git the setter definitions, of the package
+	Kptfile kptfile.KptFile
+
+	// Setters are created for the resources of the package
+	Setters []clusterpkg.Setter
+}
+
+// Builtin returns the names of the built-in templates.
+func Builtin() []string {
+	var names []string
+	for name := range builtins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Load returns the built-in template called name, generated for the package
+// called pkgName, or the template package fetched from the git repo name,
+// e.g. https://github.com/org/templates/app@v1.
+func Load(name, pkgName string) (Template, error) {
+	if b, found := builtins[name]; found {
+		return b.generate(pkgName)
+	}
+	if !strings.Contains(name, "/") {
+		return Template{}, errors.Errorf("unknown template %q -- must be one of %s, "+
+			"or a git repository", name, strings.Join(Builtin(), ", "))
+	}
+	return fetch(name)
+}
+
+// Write writes the files of the template to dir.  Returns an error, without
+// writing any files, if one of them already exists.
+func (t Template) Write(dir string) error {
+	var paths []string
+	for p := range t.Files {
+		if _, err := os.Stat(filepath.Join(dir, p)); err == nil {
+			return errors.Errorf("%q already exists", filepath.Join(dir, p))
+		}
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	for _, p := range paths {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(dir, p)), 0700); err != nil {
+			return errors.Wrap(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, p), []byte(t.Files[p]), 0600); err != nil {
+			return errors.Wrap(err)
+		}
+	}
+	return nil
+}
+
+// Apply sets the function pipeline and setter definitions of the template
+// on k.
+func (t Template) Apply(k *kptfile.KptFile) {
+	k.CommonLabels = t.Kptfile.CommonLabels
+	k.CommonAnnotations = t.Kptfile.CommonAnnotations
+	k.Functions = t.Kptfile.Functions
+	k.OpenAPI = t.Kptfile.OpenAPI
+	k.Dependencies = t.Kptfile.Dependencies
+}
+
+// fetch fetches the template package from the git repo.
+func fetch(repo string) (Template, error) {
+	dir, err := tmputil.TempDir("kpt-template-")
+	if err != nil {
+		return Template{}, err
+	}
+	defer os.RemoveAll(dir)
+	target, err := parse.GitParseArgs([]string{repo, filepath.Join(dir, "template")})
+	if err != nil {
+		return Template{}, err
+	}
+	if err := (get.Command{Git: target.Git, Destination: target.Destination}).Run(); err != nil {
+		return Template{}, errors.Errorf("failed to fetch template %q: %v", repo, err)
+	}
+
+	t := Template{Files: map[string]string{}}
+	t.Kptfile, err = kptfileutil.ReadFile(target.Destination)
+	if err != nil {
+		return Template{}, err
+	}
+	err = filepath.Walk(target.Destination, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return errors.Wrap(err)
+		}
+		rel, err := filepath.Rel(target.Destination, p)
+		if err != nil {
+			return errors.Wrap(err)
+		}
+		if info.IsDir() || rel == kptfile.KptFileName {
+			return nil
+		}
+		b, err := ioutil.ReadFile(p)
+		if err != nil {
+			return errors.Wrap(err)
+		}
+		t.Files[rel] = string(b)
+		return nil
+	})
+	return t, err
+}
+
+// builtin is a built-in template.
+type builtin struct {
+	// files are text/templates of the files of the package, executed with
+	// the names of the package
+	files map[string]string
+
+	// kptfile is the function pipeline of the package
+	kptfile kptfile.KptFile
+
+	// setters are the setters of the package.  Their values are
+	// text/templates.
+	setters []clusterpkg.Setter
+}
+
+// names are the names a built-in template is executed with.
+type names struct {
+	// Name is the name of the package, e.g. my-app
+	Name string
+
+	// Kind is the name as a kind, e.g. MyApp
+	Kind string
+
+	// Plural is the lower case plural of Kind, e.g. myapps
+	Plural string
+}
+
+// generate generates the template for the package called name.
+func (b builtin) generate(name string) (Template, error) {
+	n := names{Name: name}
+	for _, part := range strings.FieldsFunc(name, func(r rune) bool {
+		return !('a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9')
+	}) {
+		n.Kind += strings.ToUpper(part[:1]) + part[1:]
+	}
+	n.Plural = strings.ToLower(n.Kind) + "s"
+
+	t := Template{Files: map[string]string{}, Kptfile: b.kptfile}
+	if len(b.kptfile.CommonLabels) > 0 {
+		t.Kptfile.CommonLabels = map[string]string{}
+		for k, v := range b.kptfile.CommonLabels {
+			s, err := execute(k, v, n)
+			if err != nil {
+				return Template{}, err
+			}
+			t.Kptfile.CommonLabels[k] = s
+		}
+	}
+	for p, f := range b.files {
+		s, err := execute(p, f, n)
+		if err != nil {
+			return Template{}, err
+		}
+		t.Files[p] = s
+	}
+	for _, s := range b.setters {
+		v, err := execute(s.Name, s.Value, n)
+		if err != nil {
+			return Template{}, err
+		}
+		s.Value = v
+		t.Setters = append(t.Setters, s)
+	}
+	return t, nil
+}
+
+// execute executes the text/template text with n.
+func execute(name, text string, n names) (string, error) {
+	tmpl, err := template.New(name).Parse(text)
+	if err != nil {
+		return "", errors.Wrap(err)
+	}
+	var b bytes.Buffer
+	if err := tmpl.Execute(&b, n); err != nil {
+		return "", errors.Wrap(err)
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,329 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package selfupdate replaces the running kpt binary with a kpt release.
+package selfupdate
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/GoogleContainerTools/kpt/internal/gitutil"
+	"sigs.k8s.io/kustomize/kyaml/errors"
+)
+
+const (
+	// StableChannel updates to the latest release.
+	StableChannel = "stable"
+
+	// LatestChannel updates to the latest release or pre-release.
+	LatestChannel = "latest"
+)
+
+// Repo is the GitHub repo kpt is released from.
+const Repo = "GoogleContainerTools/kpt"
+
+// ChecksumsAsset is the release asset listing the sha256 checksums of the
+// other assets, and SignatureAsset is its ed25519 signature.
+const (
+	ChecksumsAsset = "checksums.txt"
+	SignatureAsset = ChecksumsAsset + ".sig"
+)
+
+// PublicKey is the base64 encoded ed25519 public key verifying the
+// signature of the releases.  It is set when releases are built, kpt
+// built without it can't update itself.
+var PublicKey = ""
+
+// downloadClient downloads the release assets.
+var downloadClient = &http.Client{Timeout: 10 * time.Minute}
+
+// Command updates the kpt binary.
+type Command struct {
+	// Channel is the channel to update from, StableChannel or LatestChannel.
+	Channel string
+
+	// Version is the version of the running kpt.
+	Version string
+
+	// Executable is the kpt binary to replace.  Defaults to the running
+	// executable.
+	Executable string
+
+	// Out is where progress is written.
+	Out io.Writer
+}
+
+// Release is a kpt release.
+type Release struct {
+	// Version is the tag of the release, e.g. v0.37.0.
+	Version string
+
+	// Assets are the download urls of the assets of the release by name.
+	Assets map[string]string
+}
+
+// Run replaces the kpt binary with the release for the current platform,
+// if it isn't already that release.  The binary is verified against the
+// signed checksums of the release before it is swapped in.
+func (c Command) Run() error {
+	if c.Channel == "" {
+		c.Channel = StableChannel
+	}
+	if c.Out == nil {
+		c.Out = ioutil.Discard
+	}
+	key, err := publicKey()
+	if err != nil {
+		return err
+	}
+	if c.Executable == "" {
+		if c.Executable, err = os.Executable(); err != nil {
+			return errors.Wrap(err)
+		}
+	}
+	if c.Executable, err = filepath.EvalSymlinks(c.Executable); err != nil {
+		return errors.Wrap(err)
+	}
+
+	release, err := FindRelease(c.Channel)
+	if err != nil {
+		return err
+	}
+	if strings.TrimPrefix(release.Version, "v") == strings.TrimPrefix(c.Version, "v") {
+		fmt.Fprintf(c.Out, "kpt is up to date (%s)\n", release.Version)
+		return nil
+	}
+
+	asset := AssetName(runtime.GOOS, runtime.GOARCH)
+	sum, err := release.checksum(asset, key)
+	if err != nil {
+		return err
+	}
+	url, found := release.Assets[asset]
+	if !found {
+		return errors.Errorf("release %s has no binary for %s/%s",
+			release.Version, runtime.GOOS, runtime.GOARCH)
+	}
+	fmt.Fprintf(c.Out, "downloading kpt %s for %s/%s\n",
+		release.Version, runtime.GOOS, runtime.GOARCH)
+	tmp, err := download(url, filepath.Dir(c.Executable), sum)
+	if err != nil {
+		return err
+	}
+	if err := replace(c.Executable, tmp); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	fmt.Fprintf(c.Out, "updated kpt from %s to %s\n", c.Version, release.Version)
+	return nil
+}
+
+// AssetName returns the name of the release asset containing the kpt
+// binary for goos and goarch.
+func AssetName(goos, goarch string) string {
+	name := fmt.Sprintf("kpt_%s_%s", goos, goarch)
+	if goos == "windows" {
+		name += ".exe"
+	}
+	return name
+}
+
+// FindRelease returns the release channel updates to.
+func FindRelease(channel string) (Release, error) {
+	type release struct {
+		TagName string `json:"tag_name"`
+		Draft   bool   `json:"draft"`
+		Assets  []struct {
+			Name string `json:"name"`
+			URL  string `json:"browser_download_url"`
+		} `json:"assets"`
+	}
+
+	var releases []release
+	switch channel {
+	case StableChannel:
+		// the latest release excludes pre-releases
+		b, err := gitutil.GitHubAPIGet("/repos/" + Repo + "/releases/latest")
+		if err != nil {
+			return Release{}, err
+		}
+		var r release
+		if err := json.Unmarshal(b, &r); err != nil {
+			return Release{}, errors.Errorf("unable to parse release: %v", err)
+		}
+		releases = append(releases, r)
+	case LatestChannel:
+		b, err := gitutil.GitHubAPIGet("/repos/" + Repo + "/releases?per_page=10")
+		if err != nil {
+			return Release{}, err
+		}
+		if err := json.Unmarshal(b, &releases); err != nil {
+			return Release{}, errors.Errorf("unable to parse releases: %v", err)
+		}
+	default:
+		return Release{}, errors.Errorf("unknown channel %q -- must be one of %s, %s",
+			channel, StableChannel, LatestChannel)
+	}
+
+	for _, r := range releases {
+		if r.Draft || r.TagName == "" {
+			continue
+		}
+		result := Release{Version: r.TagName, Assets: map[string]string{}}
+		for _, a := range r.Assets {
+			result.Assets[a.Name] = a.URL
+		}
+		return result, nil
+	}
+	return Release{}, errors.Errorf("no %s release of kpt found", channel)
+}
+
+// publicKey decodes PublicKey.
+func publicKey() (ed25519.PublicKey, error) {
+	if PublicKey == "" {
+		return nil, errors.Errorf("this build of kpt can't verify releases, " +
+			"so it can't update itself -- install a kpt release instead")
+	}
+	key, err := base64.StdEncoding.DecodeString(PublicKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return nil, errors.Errorf("invalid release public key %q", PublicKey)
+	}
+	return key, nil
+}
+
+// checksum returns the sha256 checksum of asset from the checksums of the
+// release, after verifying their signature with key.
+func (r Release) checksum(asset string, key ed25519.PublicKey) ([]byte, error) {
+	for _, name := range []string{ChecksumsAsset, SignatureAsset} {
+		if _, found := r.Assets[name]; !found {
+			return nil, errors.Errorf("release %s has no %s, so it can't be verified",
+				r.Version, name)
+		}
+	}
+	checksums, err := get(r.Assets[ChecksumsAsset])
+	if err != nil {
+		return nil, err
+	}
+	sig, err := get(r.Assets[SignatureAsset])
+	if err != nil {
+		return nil, err
+	}
+	if !ed25519.Verify(key, checksums, sig) {
+		return nil, errors.Errorf("the signature of the checksums of release %s is invalid",
+			r.Version)
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(checksums))
+	for scanner.Scan() {
+		// lines are formatted as by sha256sum: CHECKSUM  NAME
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 || strings.TrimPrefix(fields[1], "*") != asset {
+			continue
+		}
+		sum, err := hex.DecodeString(fields[0])
+		if err != nil || len(sum) != sha256.Size {
+			return nil, errors.Errorf("invalid checksum of %s: %q", asset, fields[0])
+		}
+		return sum, nil
+	}
+	return nil, errors.Errorf("release %s has no checksum for %s", r.Version, asset)
+}
+
+// get returns the body of url.
+func get(url string) ([]byte, error) {
+	resp, err := downloadClient.Get(url)
+	if err != nil {
+		return nil, errors.Errorf("unable to download %s: %v", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("unable to download %s: %s", url, resp.Status)
+	}
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, errors.Errorf("unable to download %s: %v", url, err)
+	}
+	return b, nil
+}
+
+// download downloads url to a new executable file in dir, and returns its
+// path.  Returns an error if the sha256 checksum of the file isn't sum.
+func download(url, dir string, sum []byte) (string, error) {
+	resp, err := downloadClient.Get(url)
+	if err != nil {
+		return "", errors.Errorf("unable to download %s: %v", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", errors.Errorf("unable to download %s: %s", url, resp.Status)
+	}
+
+	// create the file next to the binary, so it can be renamed over it
+	f, err := ioutil.TempFile(dir, ".kpt-update-")
+	if err != nil {
+		return "", errors.Wrap(err)
+	}
+	h := sha256.New()
+	_, err = io.Copy(io.MultiWriter(f, h), resp.Body)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(f.Name())
+		return "", errors.Errorf("unable to download %s: %v", url, err)
+	}
+	if !bytes.Equal(h.Sum(nil), sum) {
+		_ = os.Remove(f.Name())
+		return "", errors.Errorf("checksum of %s is %x, expected %x", url, h.Sum(nil), sum)
+	}
+	if err := os.Chmod(f.Name(), 0755); err != nil {
+		_ = os.Remove(f.Name())
+		return "", errors.Wrap(err)
+	}
+	return f.Name(), nil
+}
+
+// replace atomically replaces the executable with the file at path.
+func replace(executable, path string) error {
+	if runtime.GOOS != "windows" {
+		return errors.Wrap(os.Rename(path, executable))
+	}
+	// windows can't replace a running executable, but it can rename it.
+	// The renamed binary is removed by the next update.
+	old := executable + ".old"
+	_ = os.Remove(old)
+	if err := os.Rename(executable, old); err != nil {
+		return errors.Wrap(err)
+	}
+	if err := os.Rename(path, executable); err != nil {
+		_ = os.Rename(old, executable)
+		return errors.Wrap(err)
+	}
+	return nil
+}
@@ -0,0 +1,198 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package selfupdate_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt/internal/gitutil"
+	. "github.com/GoogleContainerTools/kpt/internal/util/selfupdate"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestCommand_Run verifies that kpt is only replaced with a release whose
+// checksums are signed, and whose binary matches its checksum.
+func TestCommand_Run(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	asset := AssetName(runtime.GOOS, runtime.GOARCH)
+
+	var tests = []struct {
+		name     string
+		channel  string
+		version  string
+		binary   string
+		sign     func(checksums []byte) []byte
+		expected string
+		err      string
+	}{
+		{
+			name:     "stable",
+			channel:  StableChannel,
+			version:  "v0.1.0",
+			expected: "kpt v0.2.0",
+		},
+		{
+			name:     "latest",
+			channel:  LatestChannel,
+			version:  "v0.1.0",
+			expected: "kpt v0.3.0-rc.1",
+		},
+		{
+			name:     "up to date",
+			channel:  StableChannel,
+			version:  "0.2.0",
+			expected: "kpt v0.1.0",
+		},
+		{
+			name:     "tampered binary",
+			channel:  StableChannel,
+			version:  "v0.1.0",
+			binary:   "evil kpt",
+			expected: "kpt v0.1.0",
+			err:      "checksum of",
+		},
+		{
+			name:    "invalid signature",
+			channel: StableChannel,
+			version: "v0.1.0",
+			sign: func(checksums []byte) []byte {
+				_, other, _ := ed25519.GenerateKey(rand.Reader)
+				return ed25519.Sign(other, checksums)
+			},
+			expected: "kpt v0.1.0",
+			err:      "signature of the checksums of release v0.2.0 is invalid",
+		},
+		{
+			name:     "unknown channel",
+			channel:  "nightly",
+			version:  "v0.1.0",
+			expected: "kpt v0.1.0",
+			err:      `unknown channel "nightly"`,
+		},
+	}
+
+	for i := range tests {
+		test := tests[i]
+		t.Run(test.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "kpt-selfupdate-test")
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			defer os.RemoveAll(dir)
+
+			var server *httptest.Server
+			server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				release := func(version string) string {
+					url := server.URL + "/download/" + version + "/"
+					return fmt.Sprintf(`{"tag_name": %q, "assets": [
+{"name": %q, "browser_download_url": %q},
+{"name": "checksums.txt", "browser_download_url": %q},
+{"name": "checksums.txt.sig", "browser_download_url": %q}]}`,
+						version, asset, url+asset, url+ChecksumsAsset, url+SignatureAsset)
+				}
+				binary := func(version string) []byte { return []byte("kpt " + version) }
+				checksums := func(version string) []byte {
+					return []byte(fmt.Sprintf("%x  %s\n%x  other\n",
+						sha256.Sum256(binary(version)), asset, sha256.Sum256(nil)))
+				}
+
+				switch r.URL.Path {
+				case "/repos/" + Repo + "/releases/latest":
+					fmt.Fprint(w, release("v0.2.0"))
+				case "/repos/" + Repo + "/releases":
+					fmt.Fprintf(w, "[%s, %s]", release("v0.3.0-rc.1"), release("v0.2.0"))
+				default:
+					version, name := filepath.Split(r.URL.Path)
+					version = filepath.Base(version)
+					switch name {
+					case asset:
+						if test.binary != "" {
+							fmt.Fprint(w, test.binary)
+							return
+						}
+						_, _ = w.Write(binary(version))
+					case ChecksumsAsset:
+						_, _ = w.Write(checksums(version))
+					case SignatureAsset:
+						sign := func(b []byte) []byte { return ed25519.Sign(priv, b) }
+						if test.sign != nil {
+							sign = test.sign
+						}
+						_, _ = w.Write(sign(checksums(version)))
+					default:
+						w.WriteHeader(http.StatusNotFound)
+					}
+				}
+			}))
+			defer server.Close()
+
+			for k, v := range map[string]string{
+				gitutil.GitHubAPIEnv: server.URL, gitutil.RepoCacheDirEnv: dir} {
+				os.Setenv(k, v)
+				defer os.Unsetenv(k)
+			}
+			PublicKey = base64.StdEncoding.EncodeToString(pub)
+			defer func() { PublicKey = "" }()
+
+			executable := filepath.Join(dir, "kpt")
+			if !assert.NoError(t, ioutil.WriteFile(executable, []byte("kpt v0.1.0"), 0755)) {
+				t.FailNow()
+			}
+			err = Command{Channel: test.channel, Version: test.version,
+				Executable: executable}.Run()
+			if test.err != "" {
+				if assert.Error(t, err) {
+					assert.Contains(t, err.Error(), test.err)
+				}
+			} else {
+				assert.NoError(t, err)
+			}
+
+			b, err := ioutil.ReadFile(executable)
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			assert.Equal(t, test.expected, string(b))
+
+			// no partial downloads are left behind
+			files, err := filepath.Glob(filepath.Join(dir, ".kpt-update-*"))
+			assert.NoError(t, err)
+			assert.Empty(t, files)
+		})
+	}
+}
+
+// TestCommand_Run_noPublicKey verifies that kpt built without the release
+// public key refuses to update itself.
+func TestCommand_Run_noPublicKey(t *testing.T) {
+	err := Command{Version: "v0.1.0", Executable: os.Args[0]}.Run()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "can't update itself")
+	}
+}
@@ -0,0 +1,262 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package semver parses semantic versions, e.g. v1.2.3, and matches them
+// against constraints, e.g. ">=1.2, <2".
+package semver
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"sigs.k8s.io/kustomize/kyaml/errors"
+)
+
+// Version is a semantic version.
+type Version struct {
+	Major, Minor, Patch int
+
+	// Prerelease is the dot separated pre-release, e.g. rc.1
+	Prerelease string
+
+	// Original is the string the version was parsed from
+	Original string
+}
+
+var versionPattern = regexp.MustCompile(
+	`^v?(0|[1-9]\d*)(?:\.(0|[1-9]\d*))?(?:\.(0|[1-9]\d*))?` +
+		`(?:-([0-9A-Za-z-]+(?:\.[0-9A-Za-z-]+)*))?(?:\+[0-9A-Za-z-]+(?:\.[0-9A-Za-z-]+)*)?$`)
+
+// Parse parses a version, e.g. v1.2.3 or 1.2.3-rc.1.  The minor and patch
+// versions default to 0.
+func Parse(s string) (Version, error) {
+	m := versionPattern.FindStringSubmatch(s)
+	if m == nil {
+		return Version{}, errors.Errorf("invalid semantic version %q", s)
+	}
+	v := Version{Prerelease: m[4], Original: s}
+	v.Major, _ = strconv.Atoi(m[1])
+	v.Minor, _ = strconv.Atoi(m[2])
+	v.Patch, _ = strconv.Atoi(m[3])
+	return v, nil
+}
+
+// Compare returns -1, 0 or 1 if v is less than, equal to or greater than o.
+// Pre-releases are less than the release, and are compared by identifier.
+func (v Version) Compare(o Version) int {
+	for _, d := range []int{v.Major - o.Major, v.Minor - o.Minor, v.Patch - o.Patch} {
+		if d != 0 {
+			return sign(d)
+		}
+	}
+	switch {
+	case v.Prerelease == o.Prerelease:
+		return 0
+	case v.Prerelease == "":
+		return 1
+	case o.Prerelease == "":
+		return -1
+	}
+	a, b := strings.Split(v.Prerelease, "."), strings.Split(o.Prerelease, ".")
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if c := compareIdentifier(a[i], b[i]); c != 0 {
+			return c
+		}
+	}
+	return sign(len(a) - len(b))
+}
+
+// compareIdentifier compares pre-release identifiers.  Numeric identifiers
+// are compared numerically, and are less than alphanumeric identifiers.
+func compareIdentifier(a, b string) int {
+	x, errA := strconv.Atoi(a)
+	y, errB := strconv.Atoi(b)
+	switch {
+	case errA == nil && errB == nil:
+		return sign(x - y)
+	case errA == nil:
+		return -1
+	case errB == nil:
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+func sign(i int) int {
+	switch {
+	case i < 0:
+		return -1
+	case i > 0:
+		return 1
+	}
+	return 0
+}
+
+// Constraint restricts versions, e.g. ">=1.2.0, <2.0.0", "~1.2" or "^1.2.3".
+// Comparators separated by commas or spaces must all match; alternatives
+// separated by "||" are matched if any of them match.  Pre-releases only
+// match comparators which include a pre-release.
+type Constraint struct {
+	alternatives [][]comparator
+	prerelease   bool
+}
+
+type comparator struct {
+	op      string
+	version Version
+}
+
+var comparatorPattern = regexp.MustCompile(`^(=|!=|>=|<=|>|<|~|\^)?\s*(.+)$`)
+
+// ParseConstraint parses a constraint.  An empty constraint matches all
+// releases.
+func ParseConstraint(s string) (Constraint, error) {
+	c := Constraint{}
+	for _, alternative := range strings.Split(s, "||") {
+		var comparators []comparator
+		fields := strings.FieldsFunc(alternative, func(r rune) bool {
+			return r == ',' || r == ' '
+		})
+		// join operators separated from their version, e.g. ">= 1.2"
+		for i := 0; i < len(fields); i++ {
+			f := fields[i]
+			if strings.Trim(f, "=!<>~^") == "" && i+1 < len(fields) {
+				f += fields[i+1]
+				i++
+			}
+			cs, err := parseComparator(f)
+			if err != nil {
+				return Constraint{}, errors.Errorf("invalid version constraint %q: %v", s, err)
+			}
+			for _, cmp := range cs {
+				if cmp.version.Prerelease != "" {
+					c.prerelease = true
+				}
+			}
+			comparators = append(comparators, cs...)
+		}
+		c.alternatives = append(c.alternatives, comparators)
+	}
+	return c, nil
+}
+
+// parseComparator parses a single comparator, expanding ranges, e.g. ~1.2
+// or 1.x, into a pair of comparators.
+func parseComparator(s string) ([]comparator, error) {
+	m := comparatorPattern.FindStringSubmatch(s)
+	if m == nil {
+		return nil, errors.Errorf("invalid comparator %q", s)
+	}
+	op, vs := m[1], m[2]
+
+	// n is the number of parts which constrain the version
+	parts := strings.SplitN(strings.TrimPrefix(vs, "v"), ".", 3)
+	n := len(parts)
+	for i, p := range parts {
+		if p == "x" || p == "X" || p == "*" {
+			n = i
+			break
+		}
+	}
+	if n == 0 {
+		// matches any version
+		return nil, nil
+	}
+	partial := len(parts) < 3 && (op == "" || op == "=") && !strings.ContainsAny(vs, "-+")
+	if n < len(parts) || partial {
+		// wildcards and partial versions are ranges, e.g. 1.x and 1 are ^1,
+		// 1.2.x and 1.2 are ~1.2
+		if op != "" && op != "=" {
+			return nil, errors.Errorf("invalid comparator %q: wildcards may not be used with %s", s, op)
+		}
+		op, vs = "~", strings.Join(parts[:n], ".")
+		if n == 1 {
+			op = "^"
+		}
+	}
+
+	v, err := Parse(vs)
+	if err != nil {
+		return nil, err
+	}
+	specified := len(strings.SplitN(strings.TrimPrefix(strings.SplitN(vs, "-", 2)[0], "v"), ".", 3))
+	switch op {
+	case "~":
+		// ~1.2.3 and ~1.2 allow patch updates, ~1 allows minor updates
+		upper := Version{Major: v.Major + 1}
+		if specified > 1 {
+			upper = Version{Major: v.Major, Minor: v.Minor + 1}
+		}
+		return []comparator{{">=", v}, {"<", prerelease0(upper)}}, nil
+	case "^":
+		// ^1.2.3 allows updates which don't change the left most non-zero part
+		upper := Version{Major: v.Major + 1}
+		switch {
+		case v.Major == 0 && (v.Minor > 0 || specified == 2):
+			upper = Version{Minor: v.Minor + 1}
+		case v.Major == 0 && specified == 3:
+			upper = Version{Minor: v.Minor, Patch: v.Patch + 1}
+		}
+		return []comparator{{">=", v}, {"<", prerelease0(upper)}}, nil
+	case "":
+		op = "="
+	}
+	return []comparator{{op, v}}, nil
+}
+
+// prerelease0 returns the lowest pre-release of v, so that upper bounds
+// exclude the pre-releases of the next version.
+func prerelease0(v Version) Version {
+	v.Prerelease = "0"
+	return v
+}
+
+// Match returns true if v satisfies the constraint.
+func (c Constraint) Match(v Version) bool {
+	if v.Prerelease != "" && !c.prerelease {
+		return false
+	}
+	for _, alternative := range c.alternatives {
+		if matchAll(alternative, v) {
+			return true
+		}
+	}
+	return len(c.alternatives) == 0
+}
+
+func matchAll(comparators []comparator, v Version) bool {
+	for _, cmp := range comparators {
+		d := v.Compare(cmp.version)
+		var ok bool
+		switch cmp.op {
+		case "=":
+			ok = d == 0
+		case "!=":
+			ok = d != 0
+		case ">":
+			ok = d > 0
+		case ">=":
+			ok = d >= 0
+		case "<":
+			ok = d < 0
+		case "<=":
+			ok = d <= 0
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
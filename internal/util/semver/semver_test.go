@@ -0,0 +1,123 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package semver_test
+
+import (
+	"testing"
+
+	. "github.com/GoogleContainerTools/kpt/internal/util/semver"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	v, err := Parse("v1.2.3-rc.1+build.5")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, Version{Major: 1, Minor: 2, Patch: 3, Prerelease: "rc.1",
+		Original: "v1.2.3-rc.1+build.5"}, v)
+
+	v, err = Parse("2.1")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, Version{Major: 2, Minor: 1, Original: "2.1"}, v)
+
+	for _, s := range []string{"", "latest", "v1.2.3.4", "01.2.3", "release-1.2"} {
+		_, err := Parse(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestVersion_Compare(t *testing.T) {
+	// ascending order
+	versions := []string{"0.9.0", "v1.0.0-alpha", "v1.0.0-alpha.1", "v1.0.0-alpha.beta",
+		"v1.0.0-beta.2", "v1.0.0-beta.11", "v1.0.0-rc.1", "v1.0.0", "v1.0.1", "v1.10.0", "v2.0.0"}
+	for i := range versions {
+		for j := range versions {
+			a, err := Parse(versions[i])
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			b, err := Parse(versions[j])
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			expected := 0
+			switch {
+			case i < j:
+				expected = -1
+			case i > j:
+				expected = 1
+			}
+			assert.Equal(t, expected, a.Compare(b), "%s %s", versions[i], versions[j])
+		}
+	}
+}
+
+func TestConstraint_Match(t *testing.T) {
+	tests := []struct {
+		constraint string
+		match      []string
+		noMatch    []string
+	}{
+		{constraint: "", match: []string{"v0.1.0", "v3.0.0"}, noMatch: []string{"v1.0.0-rc.1"}},
+		{constraint: "*", match: []string{"v0.1.0", "v3.0.0"}},
+		{constraint: ">=1.2, <2", match: []string{"v1.2.0", "v1.9.9"}, noMatch: []string{"v1.1.9", "v2.0.0"}},
+		{constraint: ">= 1.2 < 2", match: []string{"v1.2.0"}, noMatch: []string{"v2.0.0"}},
+		{constraint: "~1.2.3", match: []string{"v1.2.3", "v1.2.9"}, noMatch: []string{"v1.2.2", "v1.3.0"}},
+		{constraint: "~1", match: []string{"v1.0.0", "v1.9.0"}, noMatch: []string{"v2.0.0"}},
+		{constraint: "^1.2.3", match: []string{"v1.2.3", "v1.9.0"}, noMatch: []string{"v1.2.2", "v2.0.0"}},
+		{constraint: "^0.2.3", match: []string{"v0.2.3", "v0.2.9"}, noMatch: []string{"v0.3.0"}},
+		{constraint: "^0.0.3", match: []string{"v0.0.3"}, noMatch: []string{"v0.0.4"}},
+		{constraint: "1.x", match: []string{"v1.0.0", "v1.9.0"}, noMatch: []string{"v2.0.0"}},
+		{constraint: "1.2", match: []string{"v1.2.0", "v1.2.9"}, noMatch: []string{"v1.3.0"}},
+		{constraint: "=1.2.3", match: []string{"v1.2.3"}, noMatch: []string{"v1.2.4"}},
+		{constraint: "!=1.2.3", match: []string{"v1.2.4"}, noMatch: []string{"v1.2.3"}},
+		{constraint: "<1 || >=3", match: []string{"v0.9.0", "v3.0.0"}, noMatch: []string{"v2.0.0"}},
+		{constraint: ">=1.0.0-rc.1", match: []string{"v1.0.0-rc.2", "v1.0.0"}, noMatch: []string{"v1.0.0-beta.1"}},
+		{constraint: "^1", noMatch: []string{"v2.0.0-rc.1"}},
+	}
+	for i := range tests {
+		test := tests[i]
+		t.Run(test.constraint, func(t *testing.T) {
+			c, err := ParseConstraint(test.constraint)
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			for _, s := range test.match {
+				v, err := Parse(s)
+				if !assert.NoError(t, err) {
+					t.FailNow()
+				}
+				assert.True(t, c.Match(v), s)
+			}
+			for _, s := range test.noMatch {
+				v, err := Parse(s)
+				if !assert.NoError(t, err) {
+					t.FailNow()
+				}
+				assert.False(t, c.Match(v), s)
+			}
+		})
+	}
+}
+
+func TestParseConstraint_invalid(t *testing.T) {
+	for _, s := range []string{">=latest", "~1.x", ">"} {
+		_, err := ParseConstraint(s)
+		assert.Error(t, err, s)
+	}
+}
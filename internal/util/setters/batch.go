@@ -0,0 +1,293 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package setters
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/GoogleContainerTools/kpt/internal/util/tmputil"
+	"github.com/GoogleContainerTools/kpt/pkg/kptfile"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/kustomize/kyaml/copyutil"
+	"sigs.k8s.io/kustomize/kyaml/setters2/settersutil"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// Batch sets many setters of a package at once.  Either every setter is
+// set or, if any fails, the package is left unchanged.
+type Batch struct {
+	// Values are the values of the setters, by name.  Array setters may
+	// have several values, other setters must have one.
+	Values map[string][]string
+
+	// SetBy and Description are recorded on the setter definitions
+	SetBy       string
+	Description string
+
+	// Cascade cascades the values to the subpackages which don't set them
+	// locally
+	Cascade bool
+}
+
+// ReadValuesFile reads setter values from a yaml file mapping setter names
+// to values, or to lists of values for array setters.
+func ReadValuesFile(path string) (map[string][]string, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	n, err := yaml.Parse(string(b))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse values file %q", path)
+	}
+	return parseValues(n, fmt.Sprintf("values file %q", path))
+}
+
+// parseValues parses the setter values of n, which maps setter names to
+// values, or to lists of values for array setters.  source describes where
+// the values were read from in errors.
+func parseValues(n *yaml.RNode, source string) (map[string][]string, error) {
+	if n.YNode().Kind != yaml.MappingNode {
+		return nil, errors.Errorf("%s must map setter names to values", source)
+	}
+	values := map[string][]string{}
+	err := n.VisitFields(func(f *yaml.MapNode) error {
+		name := f.Key.YNode().Value
+		switch v := f.Value.YNode(); v.Kind {
+		case yaml.ScalarNode:
+			values[name] = []string{v.Value}
+		case yaml.SequenceNode:
+			for _, e := range v.Content {
+				if e.Kind != yaml.ScalarNode {
+					return errors.Errorf("%s: values of setter %q must be scalars", source, name)
+				}
+				values[name] = append(values[name], e.Value)
+			}
+			if len(values[name]) == 0 {
+				return errors.Errorf("%s: setter %q has no values", source, name)
+			}
+		default:
+			return errors.Errorf("%s: value of setter %q must be a scalar or a list", source, name)
+		}
+		return nil
+	})
+	return values, err
+}
+
+// ReadEnvFile reads setter values from a file of NAME=VALUE lines.  Blank
+// lines and lines starting with # are ignored, and quoted values are
+// unquoted.
+func ReadEnvFile(path string) (map[string][]string, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	values := map[string][]string{}
+	s := bufio.NewScanner(bytes.NewReader(b))
+	for line := 1; s.Scan(); line++ {
+		text := strings.TrimSpace(s.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		parts := strings.SplitN(text, "=", 2)
+		name := strings.TrimSpace(parts[0])
+		if len(parts) != 2 || name == "" {
+			return nil, errors.Errorf("env file %q line %d must be NAME=VALUE", path, line)
+		}
+		value := strings.TrimSpace(parts[1])
+		switch {
+		case len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"':
+			if value, err = strconv.Unquote(value); err != nil {
+				return nil, errors.Errorf("env file %q line %d: invalid quoted value", path, line)
+			}
+		case len(value) >= 2 && value[0] == '\'' && value[len(value)-1] == '\'':
+			value = value[1 : len(value)-1]
+		}
+		values[name] = []string{value}
+	}
+	return values, errors.WithStack(s.Err())
+}
+
+// ParseSetValues parses setter values given as NAME=VALUE pairs, e.g. the
+// values of --set flags.
+func ParseSetValues(pairs []string) (map[string]string, error) {
+	values := map[string]string{}
+	for _, p := range pairs {
+		parts := strings.SplitN(p, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, errors.Errorf("invalid setter value %q, must be NAME=VALUE", p)
+		}
+		if _, found := values[parts[0]]; found {
+			return nil, errors.Errorf("setter %q is set more than once", parts[0])
+		}
+		values[parts[0]] = parts[1]
+	}
+	return values, nil
+}
+
+// MergeValues merges setter values read from several files, failing if a
+// setter is in more than one.
+func MergeValues(values ...map[string][]string) (map[string][]string, error) {
+	merged := map[string][]string{}
+	for _, v := range values {
+		for name, value := range v {
+			if _, found := merged[name]; found {
+				return nil, errors.Errorf("setter %q is set more than once", name)
+			}
+			merged[name] = value
+		}
+	}
+	return merged, nil
+}
+
+// names returns the names of the setters, sorted.
+func (b Batch) names() []string {
+	var names []string
+	for name := range b.Values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// validate checks every setter is defined by the package at root and its
+// values are valid, reporting every problem rather than the first.
+func (b Batch) validate(root string) error {
+	var problems []string
+	overrides := map[string]string{}
+	for _, name := range b.names() {
+		values := b.Values[name]
+		if !DefExists(root, name) {
+			problems = append(problems, fmt.Sprintf("setter %q is not defined", name))
+			continue
+		}
+		if err := ValidateValue(root, name, values[0], values[1:]); err != nil {
+			problems = append(problems, err.Error())
+			continue
+		}
+		overrides[name] = values[0]
+	}
+	if len(problems) == 0 {
+		if _, err := substituteCaptures(root, overrides, false); err != nil {
+			problems = append(problems, err.Error())
+		} else if _, err := computeSetters(root, overrides, false); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+	if len(problems) > 0 {
+		return errors.Errorf("no setters were set:\n  %s", strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+// Set sets the setters of the package at root, returning the number of
+// fields set by each.  The setters are set in a copy of the package, which
+// is only written back once they have all been set.
+func (b Batch) Set(root string) (map[string]int, error) {
+	if len(b.Values) == 0 {
+		return nil, errors.Errorf("no setter values to set")
+	}
+	if err := b.validate(root); err != nil {
+		return nil, err
+	}
+
+	dir, err := tmputil.TempDir("kpt-set-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	staging := filepath.Join(dir, filepath.Base(filepath.Clean(root)))
+	if err := copyutil.CopyDir(root, staging); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	counts := map[string]int{}
+	for _, name := range b.names() {
+		values := b.Values[name]
+		counts[name], err = setValidated(&settersutil.FieldSetter{
+			Name:            name,
+			Value:           values[0],
+			ListValues:      values[1:],
+			Description:     b.Description,
+			SetBy:           b.SetBy,
+			OpenAPIPath:     filepath.Join(staging, kptfile.KptFileName),
+			OpenAPIFileName: kptfile.KptFileName,
+			ResourcesPath:   staging,
+			IsSet:           true,
+		})
+		if err != nil {
+			return nil, errors.Wrapf(err, "no setters were set, failed to set %q", name)
+		}
+	}
+	if b.Cascade {
+		if _, err := Cascade(staging, true); err != nil {
+			return nil, errors.Wrap(err, "no setters were set")
+		}
+	}
+	if _, err := Recompute(staging); err != nil {
+		return nil, errors.Wrap(err, "no setters were set")
+	}
+	if _, err := ApplyOverrides(staging); err != nil {
+		return nil, errors.Wrap(err, "no setters were set")
+	}
+	if _, err := SubstituteCaptures(staging); err != nil {
+		return nil, errors.Wrap(err, "no setters were set")
+	}
+	return counts, writeBack(staging, root)
+}
+
+// writeBack writes the files of staging which differ from those of dst to
+// dst.  Setting doesn't add or remove files.
+func writeBack(staging, dst string) error {
+	return filepath.Walk(staging, func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(staging, path)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		updated, err := ioutil.ReadFile(path)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		target := filepath.Join(dst, rel)
+		current, err := ioutil.ReadFile(target)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		if bytes.Equal(current, updated) {
+			return nil
+		}
+		return errors.WithStack(ioutil.WriteFile(target, updated, info.Mode().Perm()))
+	})
+}
+
+// WriteBatchResults writes the number of fields set by each setter to w.
+func WriteBatchResults(w io.Writer, b Batch, counts map[string]int) {
+	for _, name := range b.names() {
+		fmt.Fprintf(w, "set %d field(s) of setter %q to value %q\n",
+			counts[name], name, strings.Join(b.Values[name], ","))
+	}
+}
@@ -0,0 +1,161 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package setters
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt/internal/testutil/pkgbuilder"
+	"github.com/stretchr/testify/assert"
+)
+
+const batchDeploy = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+spec:
+  replicas: 3 # {"$ref":"#/definitions/io.k8s.cli.setters.replicas"}
+`
+
+// batchPackage is a package with the typed setters.
+var batchPackage = map[string]string{
+	"Kptfile":     typedKptfile,
+	"deploy.yaml": batchDeploy,
+}
+
+func TestReadValues(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir)
+	valuesFile := filepath.Join(dir, "values.yaml")
+	if !assert.NoError(t, ioutil.WriteFile(valuesFile, []byte(`replicas: 5
+env: prod
+ports: [80, 443]
+`), 0600)) {
+		t.FailNow()
+	}
+	envFile := filepath.Join(dir, "prod.env")
+	if !assert.NoError(t, ioutil.WriteFile(envFile, []byte(`# production
+name = "web app"
+debug='true'
+`), 0600)) {
+		t.FailNow()
+	}
+
+	values, err := ReadValuesFile(valuesFile)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	env, err := ReadEnvFile(envFile)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	merged, err := MergeValues(values, env)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, map[string][]string{
+		"replicas": {"5"},
+		"env":      {"prod"},
+		"ports":    {"80", "443"},
+		"name":     {"web app"},
+		"debug":    {"true"},
+	}, merged)
+
+	_, err = MergeValues(values, values)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "is set more than once")
+	}
+}
+
+func TestParseSetValues(t *testing.T) {
+	values, err := ParseSetValues([]string{"replicas=5", "url=https://web?a=b"})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, map[string]string{"replicas": "5", "url": "https://web?a=b"}, values)
+
+	_, err = ParseSetValues([]string{"replicas"})
+	if assert.Error(t, err) {
+		assert.Equal(t, `invalid setter value "replicas", must be NAME=VALUE`, err.Error())
+	}
+	_, err = ParseSetValues([]string{"replicas=5", "replicas=7"})
+	if assert.Error(t, err) {
+		assert.Equal(t, `setter "replicas" is set more than once`, err.Error())
+	}
+}
+
+func TestBatch_Set(t *testing.T) {
+	dir := pkgbuilder.WritePackage(t, batchPackage)
+	defer os.RemoveAll(dir)
+
+	b := Batch{Values: map[string][]string{"replicas": {"5"}, "env": {"prod"}, "ports": {"80", "443"}}}
+	counts, err := b.Set(dir)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, map[string]int{"replicas": 1, "env": 0, "ports": 0}, counts)
+
+	setters, err := setterDefinitions(filepath.Join(dir, "Kptfile"))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	values := map[string][]string{}
+	for _, s := range setters {
+		value, listValues := splitListValues(s.Value, s.ListValues)
+		values[s.Name] = append([]string{value}, listValues...)
+	}
+	assert.Equal(t, []string{"5"}, values["replicas"])
+	assert.Equal(t, []string{"prod"}, values["env"])
+	assert.Equal(t, []string{"80", "443"}, values["ports"])
+
+	deploy, err := ioutil.ReadFile(filepath.Join(dir, "deploy.yaml"))
+	if assert.NoError(t, err) {
+		assert.Contains(t, string(deploy), "replicas: 5 ")
+	}
+}
+
+// TestBatch_Set_invalid verifies that nothing is set if any value is
+// invalid, and that every problem is reported.
+func TestBatch_Set_invalid(t *testing.T) {
+	dir := pkgbuilder.WritePackage(t, batchPackage)
+	defer os.RemoveAll(dir)
+	kf, err := ioutil.ReadFile(filepath.Join(dir, "Kptfile"))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	b := Batch{Values: map[string][]string{"replicas": {"5"}, "env": {"staging"}, "unknown": {"x"}}}
+	_, err = b.Set(dir)
+	if assert.Error(t, err) {
+		assert.Equal(t, `no setters were set:
+  invalid value for setter "env": violates enum [dev, prod]: got "staging"
+  setter "unknown" is not defined`, err.Error())
+	}
+
+	after, err := ioutil.ReadFile(filepath.Join(dir, "Kptfile"))
+	if assert.NoError(t, err) {
+		assert.Equal(t, string(kf), string(after))
+	}
+	deploy, err := ioutil.ReadFile(filepath.Join(dir, "deploy.yaml"))
+	if assert.NoError(t, err) {
+		assert.Equal(t, batchDeploy, string(deploy))
+	}
+}
@@ -0,0 +1,371 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package setters
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/GoogleContainerTools/kpt/pkg/kptfile"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/kustomize/kyaml/fieldmeta"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/kio/kioutil"
+	"sigs.k8s.io/kustomize/kyaml/pathutil"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+const (
+	// CaptureDefinitionPrefix prefixes the definitions of capture
+	// substitutions in the Kptfile.
+	CaptureDefinitionPrefix = "io.kpt.substitutions."
+
+	// CaptureRef is the key of the field comments referencing capture
+	// substitutions, e.g. # {"$kpt-subst":"image-tag"}
+	CaptureRef = "$kpt-subst"
+)
+
+// CaptureSubstitution substitutes setter values into the capture groups of
+// a regular expression, leaving the rest of the field values it matches
+// unchanged.  Unlike substitutions, the fields referencing a capture
+// substitution may have different values -- e.g. only the tag of several
+// images may be substituted.
+type CaptureSubstitution struct {
+	// Name is the name of the substitution
+	Name string `yaml:"name"`
+
+	// Pattern is the regular expression matching the field values.  Every
+	// match in a value is substituted.
+	Pattern string `yaml:"pattern"`
+
+	// Values bind the named groups of Pattern to setters
+	Values []CaptureValue `yaml:"values"`
+}
+
+// CaptureValue binds a named group of a capture substitution to a setter.
+type CaptureValue struct {
+	// Group is the name of the group
+	Group string `yaml:"group"`
+
+	// Ref is the reference to the setter, e.g.
+	// #/definitions/io.k8s.cli.setters.tag
+	Ref string `yaml:"ref"`
+}
+
+// capture is a compiled capture substitution.
+type capture struct {
+	CaptureSubstitution
+	re *regexp.Regexp
+
+	// setters are the setters bound to the groups, by group index
+	setters map[int]string
+}
+
+// compile compiles the substitution, checking its groups and setters
+// exist.
+func (c CaptureSubstitution) compile(setters map[string]string) (*capture, error) {
+	re, err := regexp.Compile(c.Pattern)
+	if err != nil {
+		return nil, errors.Errorf("capture substitution %q has an invalid pattern: %v", c.Name, err)
+	}
+	if len(c.Values) == 0 {
+		return nil, errors.Errorf("capture substitution %q has no values", c.Name)
+	}
+	compiled := &capture{CaptureSubstitution: c, re: re, setters: map[int]string{}}
+	for _, v := range c.Values {
+		i := re.SubexpIndex(v.Group)
+		if v.Group == "" || i < 0 {
+			return nil, errors.Errorf("capture substitution %q pattern has no group %q", c.Name, v.Group)
+		}
+		setter := strings.TrimPrefix(v.Ref, fieldmeta.DefinitionsPrefix+fieldmeta.SetterDefinitionPrefix)
+		if _, found := setters[setter]; !found || setter == v.Ref {
+			return nil, errors.Errorf("capture substitution %q references undefined setter %q", c.Name, v.Ref)
+		}
+		compiled.setters[i] = setter
+	}
+	return compiled, nil
+}
+
+// substitute returns value with the setter values substituted into the
+// bound groups of every match of the pattern.  The result must still
+// match the pattern, capturing the setter values, so that it can be
+// substituted again.
+func (c *capture) substitute(value string, setters map[string]string) (string, error) {
+	matches := c.re.FindAllStringSubmatchIndex(value, -1)
+	if len(matches) == 0 {
+		return "", errors.Errorf("%q doesn't match the pattern %q of capture substitution %q",
+			value, c.Pattern, c.Name)
+	}
+
+	var groups []int
+	for i := range c.setters {
+		groups = append(groups, i)
+	}
+	sort.Ints(groups)
+
+	var b strings.Builder
+	last := 0
+	for _, m := range matches {
+		for _, i := range groups {
+			start, end := m[2*i], m[2*i+1]
+			if start < 0 {
+				// the group didn't participate in the match
+				continue
+			}
+			if start < last {
+				return "", errors.Errorf("capture substitution %q binds groups which overlap", c.Name)
+			}
+			b.WriteString(value[last:start])
+			b.WriteString(setters[c.setters[i]])
+			last = end
+		}
+	}
+	b.WriteString(value[last:])
+	result := b.String()
+
+	// check the substitution is reversible
+	after := c.re.FindAllStringSubmatchIndex(result, -1)
+	if len(after) != len(matches) {
+		return "", errors.Errorf("substituting into %q of capture substitution %q isn't reversible, "+
+			"%q matches the pattern %d times rather than %d", value, c.Name, result, len(after), len(matches))
+	}
+	for j, m := range after {
+		for _, i := range groups {
+			if matches[j][2*i] < 0 {
+				continue
+			}
+			if m[2*i] < 0 || result[m[2*i]:m[2*i+1]] != setters[c.setters[i]] {
+				return "", errors.Errorf("substituting into %q of capture substitution %q isn't reversible, "+
+					"group %q of %q doesn't capture the value of setter %q",
+					value, c.Name, c.re.SubexpNames()[i], result, c.setters[i])
+			}
+		}
+	}
+	return result, nil
+}
+
+// captureDefinitions returns the capture substitutions of the Kptfile at
+// path, sorted by name.
+func captureDefinitions(path string) ([]CaptureSubstitution, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	k := struct {
+		OpenAPI struct {
+			Definitions map[string]struct {
+				Ext struct {
+					Substitution *CaptureSubstitution `yaml:"substitution"`
+				} `yaml:"x-kpt"`
+			} `yaml:"definitions"`
+		} `yaml:"openAPI"`
+	}{}
+	if err := yaml.Unmarshal(b, &k); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse %q", path)
+	}
+	var defs []CaptureSubstitution
+	for key, def := range k.OpenAPI.Definitions {
+		if !strings.HasPrefix(key, CaptureDefinitionPrefix) || def.Ext.Substitution == nil {
+			continue
+		}
+		s := *def.Ext.Substitution
+		if s.Name == "" {
+			s.Name = strings.TrimPrefix(key, CaptureDefinitionPrefix)
+		}
+		defs = append(defs, s)
+	}
+	sort.Slice(defs, func(i, j int) bool { return defs[i].Name < defs[j].Name })
+	return defs, nil
+}
+
+// captureReference returns the name of the capture substitution referenced
+// by a field comment, if any.
+func captureReference(comment string) (string, bool) {
+	m := captureRefPattern.FindStringSubmatch(comment)
+	if m == nil {
+		return "", false
+	}
+	return m[1], true
+}
+
+var captureRefPattern = regexp.MustCompile(`\{\s*"` + regexp.QuoteMeta(CaptureRef) + `"\s*:\s*"([^"]+)"\s*\}`)
+
+// substituteCaptures substitutes the capture substitutions of the package
+// at path into its resources, with the setter values of its Kptfile
+// overridden by overrides.  If write is false the package isn't changed,
+// only checked.  It returns the number of fields changed.
+func substituteCaptures(path string, overrides map[string]string, write bool) (int, error) {
+	kf := filepath.Join(path, kptfile.KptFileName)
+	defs, err := captureDefinitions(kf)
+	if err != nil {
+		return 0, err
+	}
+	starlarkDefs, err := starlarkDefinitions(kf)
+	if err != nil || len(defs)+len(starlarkDefs) == 0 {
+		return 0, err
+	}
+	setterDefs, err := setterDefinitions(kf)
+	if err != nil {
+		return 0, err
+	}
+	setters := map[string]string{}
+	for _, d := range setterDefs {
+		setters[d.Name] = d.Value
+	}
+	for name, value := range overrides {
+		if _, found := setters[name]; found {
+			setters[name] = value
+		}
+	}
+	captures := map[string]*capture{}
+	for _, d := range defs {
+		c, err := d.compile(setters)
+		if err != nil {
+			return 0, err
+		}
+		captures[d.Name] = c
+	}
+	// starlark substitutions set the whole value, which is evaluated once
+	evaluated := map[string]string{}
+	for _, d := range starlarkDefs {
+		if evaluated[d.Name], err = d.evaluate(setters); err != nil {
+			return 0, err
+		}
+	}
+
+	rw := &kio.LocalPackageReadWriter{PackagePath: path, NoDeleteFiles: true, PackageFileName: kptfile.KptFileName}
+	nodes, err := rw.Read()
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	count := 0
+	changed := map[string]bool{}
+	for _, n := range nodes {
+		file, _, err := kioutil.GetFileAnnotations(n)
+		if err != nil {
+			return 0, errors.WithStack(err)
+		}
+		var walk func(*yaml.Node) error
+		walk = func(y *yaml.Node) error {
+			if y.Kind == yaml.ScalarNode {
+				for _, comment := range []string{y.LineComment, y.HeadComment} {
+					name, ok := captureReference(comment)
+					if !ok {
+						continue
+					}
+					value, found := evaluated[name]
+					if c, isCapture := captures[name]; isCapture {
+						var err error
+						if value, err = c.substitute(y.Value, setters); err != nil {
+							return errors.Wrap(err, file)
+						}
+					} else if !found {
+						// like undefined setters, these are reported by
+						// kpt pkg validate
+						continue
+					}
+					if value != y.Value {
+						y.Value = value
+						count++
+						changed[file] = true
+					}
+				}
+			}
+			for _, c := range y.Content {
+				if err := walk(c); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		if err := walk(n.YNode()); err != nil {
+			return 0, err
+		}
+	}
+	if !write || count == 0 {
+		return count, nil
+	}
+
+	var out []*yaml.RNode
+	for _, n := range nodes {
+		if file, _, _ := kioutil.GetFileAnnotations(n); changed[file] {
+			out = append(out, n)
+		}
+	}
+	return count, errors.WithStack(rw.Write(out))
+}
+
+// SubstituteCaptures substitutes the capture substitutions of the package
+// at root, and its subpackages, into their resources using the current
+// setter values.  It returns the number of fields changed.
+func SubstituteCaptures(root string) (int, error) {
+	paths, err := pathutil.DirsWithFile(root, kptfile.KptFileName, true)
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, p := range paths {
+		n, err := substituteCaptures(p, nil, true)
+		if err != nil {
+			return count, errors.Wrapf(err, "package %q", p)
+		}
+		count += n
+	}
+	return count, nil
+}
+
+// CheckCaptures returns the errors of the capture and Starlark
+// substitutions of the package at path, keyed by name, including fields
+// which don't match their patterns.
+func CheckCaptures(path string) (map[string]error, error) {
+	kf := filepath.Join(path, kptfile.KptFileName)
+	defs, err := captureDefinitions(kf)
+	if err != nil {
+		return nil, err
+	}
+	starlarkDefs, err := starlarkDefinitions(kf)
+	if err != nil || len(defs)+len(starlarkDefs) == 0 {
+		return nil, err
+	}
+	setterDefs, err := setterDefinitions(kf)
+	if err != nil {
+		return nil, err
+	}
+	setters := map[string]string{}
+	for _, d := range setterDefs {
+		setters[d.Name] = d.Value
+	}
+	errs := map[string]error{}
+	for _, d := range defs {
+		if _, err := d.compile(setters); err != nil {
+			errs[d.Name] = err
+		}
+	}
+	for _, d := range starlarkDefs {
+		if _, err := d.evaluate(setters); err != nil {
+			errs[d.Name] = err
+		}
+	}
+	if len(errs) > 0 {
+		return errs, nil
+	}
+	if _, err := substituteCaptures(path, nil, false); err != nil {
+		return nil, err
+	}
+	return errs, nil
+}
@@ -0,0 +1,211 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package setters
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt/internal/testutil/pkgbuilder"
+	"github.com/stretchr/testify/assert"
+)
+
+const capturesKptfile = `apiVersion: kpt.dev/v1alpha1
+kind: Kptfile
+metadata:
+  name: captures
+openAPI:
+  definitions:
+    io.k8s.cli.setters.tag:
+      x-k8s-cli:
+        setter:
+          name: tag
+          value: "1.0"
+    io.k8s.cli.setters.version:
+      x-k8s-cli:
+        setter:
+          name: version
+          value: v1
+    io.kpt.substitutions.image-tag:
+      x-kpt:
+        substitution:
+          name: image-tag
+          pattern: '(?P<image>[a-z./-]+):(?P<tag>[^,]+)'
+          values:
+          - group: tag
+            ref: '#/definitions/io.k8s.cli.setters.tag'
+    io.kpt.substitutions.versions:
+      x-kpt:
+        substitution:
+          name: versions
+          pattern: '^api-(?P<api>v[0-9]+)-client-(?P<client>v[0-9]+)$'
+          values:
+          - group: api
+            ref: '#/definitions/io.k8s.cli.setters.version'
+          - group: client
+            ref: '#/definitions/io.k8s.cli.setters.version'
+`
+
+const capturesDeploy = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+  annotations:
+    images: gcr.io/app:1.0,gcr.io/sidecar:1.0 # {"$kpt-subst":"image-tag"}
+    versions: api-v1-client-v1 # {"$kpt-subst":"versions"}
+spec:
+  template:
+    spec:
+      containers:
+      - name: app
+        image: gcr.io/app:1.0 # {"$kpt-subst":"image-tag"}
+      - name: sidecar
+        image: docker.io/sidecar:1.0 # {"$kpt-subst":"image-tag"}
+`
+
+// capturesPackage returns a package with the Kptfile kptfile and resources
+// referencing substitutions with capture groups.
+func capturesPackage(kptfile string) map[string]string {
+	return map[string]string{
+		"Kptfile":     kptfile,
+		"deploy.yaml": capturesDeploy,
+	}
+}
+
+func TestSubstituteCaptures(t *testing.T) {
+	dir := pkgbuilder.WritePackage(t, capturesPackage(capturesKptfile))
+	defer os.RemoveAll(dir)
+
+	// only the captured groups are substituted, in every match
+	if !assert.NoError(t, ValidateSet(dir, "tag", "2.1", nil, false)) {
+		t.FailNow()
+	}
+	if !assert.NoError(t, ValidateSet(dir, "version", "v2", nil, false)) {
+		t.FailNow()
+	}
+	for name, value := range map[string]string{"tag": "2.1", "version": "v2"} {
+		if _, err := (RecursiveSet{Name: name, Value: value}).Set(dir); !assert.NoError(t, err) {
+			t.FailNow()
+		}
+	}
+	n, err := SubstituteCaptures(dir)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, 4, n)
+	b, err := ioutil.ReadFile(filepath.Join(dir, "deploy.yaml"))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+  annotations:
+    images: gcr.io/app:2.1,gcr.io/sidecar:2.1 # {"$kpt-subst":"image-tag"}
+    versions: api-v2-client-v2 # {"$kpt-subst":"versions"}
+spec:
+  template:
+    spec:
+      containers:
+      - name: app
+        image: gcr.io/app:2.1 # {"$kpt-subst":"image-tag"}
+      - name: sidecar
+        image: docker.io/sidecar:2.1 # {"$kpt-subst":"image-tag"}
+`, string(b))
+
+	// substituting again changes nothing
+	n, err = SubstituteCaptures(dir)
+	if assert.NoError(t, err) {
+		assert.Equal(t, 0, n)
+	}
+}
+
+func TestValidateSet_captures(t *testing.T) {
+	dir := pkgbuilder.WritePackage(t, capturesPackage(capturesKptfile))
+	defer os.RemoveAll(dir)
+
+	// the value would be captured by the image group of the second match
+	err := ValidateSet(dir, "tag", "2.1,gcr.io/x", nil, false)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), `capture substitution "image-tag" isn't reversible`)
+	}
+	err = ValidateSet(dir, "version", "2", nil, false)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), `capture substitution "versions" isn't reversible`)
+	}
+
+	// the package is unchanged
+	b, err := ioutil.ReadFile(filepath.Join(dir, "deploy.yaml"))
+	if assert.NoError(t, err) {
+		assert.Equal(t, capturesDeploy, string(b))
+	}
+}
+
+func TestCheckCaptures(t *testing.T) {
+	var tests = []struct {
+		name     string
+		pattern  string
+		group    string
+		ref      string
+		expected string
+	}{
+		{name: "valid", pattern: `(?P<tag>[^,]+)`, group: "tag", ref: "#/definitions/io.k8s.cli.setters.tag"},
+		{name: "invalid pattern", pattern: `(?P<tag>`, group: "tag", ref: "#/definitions/io.k8s.cli.setters.tag",
+			expected: `capture substitution "image-tag" has an invalid pattern`},
+		{name: "missing group", pattern: `(?P<version>.+)`, group: "tag", ref: "#/definitions/io.k8s.cli.setters.tag",
+			expected: `capture substitution "image-tag" pattern has no group "tag"`},
+		{name: "undefined setter", pattern: `(?P<tag>.+)`, group: "tag", ref: "#/definitions/io.k8s.cli.setters.nope",
+			expected: `capture substitution "image-tag" references undefined setter "#/definitions/io.k8s.cli.setters.nope"`},
+	}
+	for i := range tests {
+		test := tests[i]
+		t.Run(test.name, func(t *testing.T) {
+			dir := pkgbuilder.WritePackage(t, capturesPackage(`apiVersion: kpt.dev/v1alpha1
+kind: Kptfile
+metadata:
+  name: captures
+openAPI:
+  definitions:
+    io.k8s.cli.setters.tag:
+      x-k8s-cli:
+        setter:
+          name: tag
+          value: "1.0"
+    io.kpt.substitutions.image-tag:
+      x-kpt:
+        substitution:
+          name: image-tag
+          pattern: '`+test.pattern+`'
+          values:
+          - group: `+test.group+`
+            ref: '`+test.ref+`'
+`))
+			defer os.RemoveAll(dir)
+
+			errs, err := CheckCaptures(dir)
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			if test.expected == "" {
+				assert.Empty(t, errs)
+			} else if assert.Error(t, errs["image-tag"]) {
+				assert.Contains(t, errs["image-tag"].Error(), test.expected)
+			}
+		})
+	}
+}
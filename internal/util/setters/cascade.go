@@ -0,0 +1,203 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package setters
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/GoogleContainerTools/kpt/pkg/kptfile"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/kustomize/kyaml/fieldmeta"
+	"sigs.k8s.io/kustomize/kyaml/pathutil"
+	"sigs.k8s.io/kustomize/kyaml/setters2"
+	"sigs.k8s.io/kustomize/kyaml/setters2/settersutil"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// InheritedSetBy is the setBy of setters whose value was inherited from a
+// parent package.  Setters which are set, but not by InheritedSetBy, are
+// local overrides of the parent value.
+const InheritedSetBy = "kpt-inherited"
+
+// EffectiveSetter is the value a setter of a package resolves to.
+type EffectiveSetter struct {
+	// Package is the path of the package, relative to the root package
+	Package string
+
+	// Name is the name of the setter
+	Name string
+
+	// Value and ListValues are the effective value of the setter
+	Value      string
+	ListValues []string
+
+	// Source is the path of the package the value is defined by, relative
+	// to the root package
+	Source string
+
+	// IsSet is true if the value was explicitly set
+	IsSet bool
+}
+
+// DisplayValue returns the value, or the list values for list setters.
+func (e EffectiveSetter) DisplayValue() string {
+	if len(e.ListValues) > 0 {
+		return "[" + strings.Join(e.ListValues, ",") + "]"
+	}
+	return e.Value
+}
+
+// Cascade resolves the setters of the packages under root.  A setter which
+// is also defined by a parent package takes the parent's value, unless it
+// has been set locally.  If apply is true, the inherited values are set in
+// the packages.  Setters are returned by package, parent packages first.
+func Cascade(root string, apply bool) ([]EffectiveSetter, error) {
+	paths, err := pathutil.DirsWithFile(root, kptfile.KptFileName, true)
+	if err != nil {
+		return nil, err
+	}
+	var packages []string
+	for _, p := range paths {
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		packages = append(packages, filepath.ToSlash(rel))
+	}
+	sort.Strings(packages)
+
+	var result []EffectiveSetter
+	effective := map[string]map[string]EffectiveSetter{}
+	for _, pkg := range packages {
+		defs, err := setterDefinitions(filepath.Join(root, pkg, kptfile.KptFileName))
+		if err != nil {
+			return nil, err
+		}
+		// computed setters are computed by each package, rather than
+		// inherited
+		exprs, err := computedDefinitions(filepath.Join(root, pkg, kptfile.KptFileName))
+		if err != nil {
+			return nil, err
+		}
+		parent := parentPackage(pkg, effective)
+		effective[pkg] = map[string]EffectiveSetter{}
+		for _, def := range defs {
+			e := EffectiveSetter{Package: pkg, Name: def.Name, Value: def.Value,
+				ListValues: def.ListValues, Source: pkg, IsSet: def.IsSet}
+			inherited, found := effective[parent][def.Name]
+			if _, computed := exprs[def.Name]; computed {
+				found = false
+			}
+			if found && (!def.IsSet || def.SetBy == InheritedSetBy) {
+				e.Value, e.ListValues = inherited.Value, inherited.ListValues
+				e.Source, e.IsSet = inherited.Source, inherited.IsSet
+				if apply && (e.Value != def.Value || !reflect.DeepEqual(e.ListValues, def.ListValues)) {
+					if err := setInherited(filepath.Join(root, pkg), e); err != nil {
+						return nil, err
+					}
+				}
+			}
+			effective[pkg][def.Name] = e
+			result = append(result, e)
+		}
+	}
+	return result, nil
+}
+
+// parentPackage returns the closest package containing pkg, or "" if pkg
+// is the root package.
+func parentPackage(pkg string, packages map[string]map[string]EffectiveSetter) string {
+	if pkg == "." {
+		return ""
+	}
+	for dir := filepath.ToSlash(filepath.Dir(pkg)); ; dir = filepath.ToSlash(filepath.Dir(dir)) {
+		if _, found := packages[dir]; found {
+			return dir
+		}
+		if dir == "." {
+			return ""
+		}
+	}
+}
+
+// setInherited sets the setter of the package at path to the inherited
+// value.
+func setInherited(path string, e EffectiveSetter) error {
+	fs := &settersutil.FieldSetter{
+		Name:            e.Name,
+		Value:           e.Value,
+		ListValues:      e.ListValues,
+		OpenAPIPath:     filepath.Join(path, kptfile.KptFileName),
+		OpenAPIFileName: kptfile.KptFileName,
+		ResourcesPath:   path,
+		// inherit isSet so that required setters set in the parent are
+		// satisfied
+		IsSet: e.IsSet,
+	}
+	if e.IsSet {
+		fs.SetBy = InheritedSetBy
+	}
+	if _, err := setValidated(fs); err != nil {
+		return errors.Wrapf(err, "failed to set %q in package %q", e.Name, path)
+	}
+	return nil
+}
+
+// setterDefinitions returns the setter definitions in the Kptfile at path,
+// sorted by name.
+func setterDefinitions(path string) ([]setters2.SetterDefinition, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	k := struct {
+		OpenAPI struct {
+			Definitions map[string]struct {
+				Ext struct {
+					Setter *setters2.SetterDefinition `yaml:"setter"`
+				} `yaml:"x-k8s-cli"`
+			} `yaml:"definitions"`
+		} `yaml:"openAPI"`
+	}{}
+	if err := yaml.Unmarshal(b, &k); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse %q", path)
+	}
+	var defs []setters2.SetterDefinition
+	for key, def := range k.OpenAPI.Definitions {
+		if !strings.HasPrefix(key, fieldmeta.SetterDefinitionPrefix) || def.Ext.Setter == nil {
+			continue
+		}
+		defs = append(defs, *def.Ext.Setter)
+	}
+	sort.Slice(defs, func(i, j int) bool { return defs[i].Name < defs[j].Name })
+	return defs, nil
+}
+
+// WriteEffectiveSetters writes a table of the setters to w.
+func WriteEffectiveSetters(w io.Writer, setters []EffectiveSetter) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "PACKAGE\tSETTER\tVALUE\tSOURCE\tSET")
+	for _, s := range setters {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%t\n", s.Package, s.Name, s.DisplayValue(), s.Source, s.IsSet)
+	}
+	return errors.WithStack(tw.Flush())
+}
@@ -0,0 +1,177 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package setters
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt/internal/testutil/pkgbuilder"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/kustomize/kyaml/fieldmeta"
+	"sigs.k8s.io/kustomize/kyaml/openapi"
+)
+
+func TestCascade(t *testing.T) {
+	defer fieldmeta.SetShortHandRef(fieldmeta.ShortHandRef())
+	fieldmeta.SetShortHandRef("$kpt-set")
+	openapi.ResetOpenAPI()
+	defer openapi.ResetOpenAPI()
+
+	files := map[string]string{
+		"Kptfile": `apiVersion: kpt.dev/v1alpha1
+kind: Kptfile
+metadata:
+  name: root
+openAPI:
+  definitions:
+    io.k8s.cli.setters.namespace:
+      x-k8s-cli:
+        setter:
+          name: namespace
+          value: root-ns
+          isSet: true
+    io.k8s.cli.setters.replicas:
+      x-k8s-cli:
+        setter:
+          name: replicas
+          value: "1"
+`,
+		"a/Kptfile": `apiVersion: kpt.dev/v1alpha1
+kind: Kptfile
+metadata:
+  name: a
+openAPI:
+  definitions:
+    io.k8s.cli.setters.namespace:
+      x-k8s-cli:
+        setter:
+          name: namespace
+          value: a-ns
+    io.k8s.cli.setters.replicas:
+      x-k8s-cli:
+        setter:
+          name: replicas
+          value: "3"
+          isSet: true
+`,
+		"a/deploy.yaml": `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: a
+  namespace: a-ns # {"$kpt-set":"namespace"}
+spec:
+  replicas: 3 # {"$kpt-set":"replicas"}
+`,
+		"a/b/Kptfile": `apiVersion: kpt.dev/v1alpha1
+kind: Kptfile
+metadata:
+  name: b
+openAPI:
+  definitions:
+    io.k8s.cli.setters.replicas:
+      x-k8s-cli:
+        setter:
+          name: replicas
+          value: "5"
+    io.k8s.cli.setters.image:
+      x-k8s-cli:
+        setter:
+          name: image
+          value: nginx
+`,
+		"a/b/deploy.yaml": `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: b
+spec:
+  replicas: 5 # {"$kpt-set":"replicas"}
+`,
+	}
+	dir := pkgbuilder.WritePackage(t, files)
+	defer os.RemoveAll(dir)
+
+	// report only
+	effective, err := Cascade(dir, false)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	expected := []EffectiveSetter{
+		{Package: ".", Name: "namespace", Value: "root-ns", Source: ".", IsSet: true},
+		{Package: ".", Name: "replicas", Value: "1", Source: "."},
+		{Package: "a", Name: "namespace", Value: "root-ns", Source: ".", IsSet: true},
+		{Package: "a", Name: "replicas", Value: "3", Source: "a", IsSet: true},
+		{Package: "a/b", Name: "image", Value: "nginx", Source: "a/b"},
+		{Package: "a/b", Name: "replicas", Value: "3", Source: "a", IsSet: true},
+	}
+	if !assert.Equal(t, expected, effective) {
+		t.FailNow()
+	}
+	b, err := ioutil.ReadFile(filepath.Join(dir, "a", "deploy.yaml"))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	if !assert.Equal(t, files["a/deploy.yaml"], string(b)) {
+		t.FailNow()
+	}
+
+	out := &bytes.Buffer{}
+	if !assert.NoError(t, WriteEffectiveSetters(out, effective)) {
+		t.FailNow()
+	}
+	assert.Equal(t, `PACKAGE  SETTER     VALUE    SOURCE  SET
+.        namespace  root-ns  .       true
+.        replicas   1        .       false
+a        namespace  root-ns  .       true
+a        replicas   3        a       true
+a/b      image      nginx    a/b     false
+a/b      replicas   3        a       true
+`, out.String())
+
+	// apply the inherited values
+	applied, err := Cascade(dir, true)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	if !assert.Equal(t, expected, applied) {
+		t.FailNow()
+	}
+	b, err = ioutil.ReadFile(filepath.Join(dir, "a", "deploy.yaml"))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	assert.Contains(t, string(b), `namespace: root-ns # {"$kpt-set":"namespace"}`)
+	b, err = ioutil.ReadFile(filepath.Join(dir, "a", "b", "deploy.yaml"))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Contains(t, string(b), `replicas: 3 # {"$kpt-set":"replicas"}`)
+	b, err = ioutil.ReadFile(filepath.Join(dir, "a", "b", "Kptfile"))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Contains(t, string(b), "setBy: "+InheritedSetBy)
+
+	// inherited values follow the parent until overridden locally
+	effective, err = Cascade(dir, false)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, expected, effective)
+}
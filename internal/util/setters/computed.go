@@ -0,0 +1,356 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package setters
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/GoogleContainerTools/kpt/pkg/kptfile"
+	"github.com/go-openapi/spec"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/kustomize/kyaml/fieldmeta"
+	"sigs.k8s.io/kustomize/kyaml/pathutil"
+	"sigs.k8s.io/kustomize/kyaml/setters2"
+	"sigs.k8s.io/kustomize/kyaml/setters2/settersutil"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// ComputedSetBy is the setBy of computed setters.  Computed setters derive
+// their value from an expression referencing other setters of the package
+// by name, and are recomputed whenever the setters are set, e.g.
+//
+//	io.k8s.cli.setters.dns-name:
+//	  x-k8s-cli:
+//	    setter:
+//	      name: dns-name
+//	      value: web.example.com
+//	      setBy: kpt-computed
+//	  x-kpt:
+//	    compute: "{name}.{zone}"
+//
+// Computed setters may reference other computed setters, but not form a
+// cycle.  They can't be set directly.
+const ComputedSetBy = "kpt-computed"
+
+// ComputedSetter is the value a computed setter of a package was computed
+// to.
+type ComputedSetter struct {
+	// Package is the path of the package
+	Package string
+
+	// Name is the name of the setter
+	Name string
+
+	// Value is the computed value
+	Value string
+}
+
+var computedRefPattern = regexp.MustCompile(`\{([^{}]*)\}`)
+
+// computedReferences returns the names of the setters referenced by the
+// expression expr, in order.
+func computedReferences(expr string) []string {
+	var refs []string
+	for _, m := range computedRefPattern.FindAllStringSubmatch(expr, -1) {
+		refs = append(refs, m[1])
+	}
+	return refs
+}
+
+// computedExpression returns the expression of the setter definition s, or
+// "" if it isn't a computed setter.
+func computedExpression(s *spec.Schema) string {
+	kpt, _ := s.Extensions["x-kpt"].(map[string]interface{})
+	expr, _ := kpt["compute"].(string)
+	return expr
+}
+
+// computedDefinitions returns the expressions of the computed setters of
+// the Kptfile at path, keyed by setter name.
+func computedDefinitions(path string) (map[string]string, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	k := struct {
+		OpenAPI struct {
+			Definitions map[string]struct {
+				Kpt struct {
+					Compute string `yaml:"compute"`
+				} `yaml:"x-kpt"`
+			} `yaml:"definitions"`
+		} `yaml:"openAPI"`
+	}{}
+	if err := yaml.Unmarshal(b, &k); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse %q", path)
+	}
+	exprs := map[string]string{}
+	for key, def := range k.OpenAPI.Definitions {
+		if strings.HasPrefix(key, fieldmeta.SetterDefinitionPrefix) && def.Kpt.Compute != "" {
+			exprs[strings.TrimPrefix(key, fieldmeta.SetterDefinitionPrefix)] = def.Kpt.Compute
+		}
+	}
+	return exprs, nil
+}
+
+// computeOrder returns the names of the computed setters with exprs in the
+// order they must be computed, so that the computed setters an expression
+// references are computed before it.  It returns an error if an expression
+// references a setter which isn't in defs, or an array setter, or if the
+// computed setters form a cycle.
+func computeOrder(exprs map[string]string, defs map[string]setters2.SetterDefinition) ([]string, error) {
+	var names []string
+	for name := range exprs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var order, stack []string
+	done := map[string]bool{}
+	var visit func(name string) error
+	visit = func(name string) error {
+		for i, n := range stack {
+			if n == name {
+				return errors.Errorf("computed setters form a cycle: %s",
+					strings.Join(append(stack[i:], name), " -> "))
+			}
+		}
+		if done[name] {
+			return nil
+		}
+		stack = append(stack, name)
+		for _, ref := range computedReferences(exprs[name]) {
+			def, found := defs[ref]
+			switch {
+			case ref == "":
+				return errors.Errorf("computed setter %q has an empty reference in %q", name, exprs[name])
+			case !found:
+				return errors.Errorf("computed setter %q references undefined setter %q", name, ref)
+			case len(def.ListValues) > 0:
+				return errors.Errorf("computed setter %q references array setter %q", name, ref)
+			}
+			if _, computed := exprs[ref]; computed {
+				if err := visit(ref); err != nil {
+					return err
+				}
+			}
+		}
+		stack = stack[:len(stack)-1]
+		done[name] = true
+		order = append(order, name)
+		return nil
+	}
+	for _, name := range names {
+		if err := visit(name); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
+
+// compute computes the values of the computed setters with exprs from the
+// values of defs, returning their definitions in the order they were
+// computed.  A computed setter is set if every setter it references is set.
+func compute(exprs map[string]string, defs map[string]setters2.SetterDefinition) ([]setters2.SetterDefinition, error) {
+	order, err := computeOrder(exprs, defs)
+	if err != nil {
+		return nil, err
+	}
+	values := map[string]setters2.SetterDefinition{}
+	for name, def := range defs {
+		values[name] = def
+	}
+	var computed []setters2.SetterDefinition
+	for _, name := range order {
+		isSet := true
+		value := computedRefPattern.ReplaceAllStringFunc(exprs[name], func(ref string) string {
+			def := values[ref[1:len(ref)-1]]
+			isSet = isSet && def.IsSet
+			return def.Value
+		})
+		def := values[name]
+		def.Name, def.Value, def.IsSet = name, value, isSet
+		values[name] = def
+		computed = append(computed, def)
+	}
+	return computed, nil
+}
+
+// packageSetters returns the setter definitions and computed setter
+// expressions of the Kptfile at path.
+func packageSetters(path string) (map[string]setters2.SetterDefinition, map[string]string, error) {
+	list, err := setterDefinitions(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defs := map[string]setters2.SetterDefinition{}
+	for _, d := range list {
+		defs[d.Name] = d
+	}
+	exprs, err := computedDefinitions(path)
+	return defs, exprs, err
+}
+
+// ComputeValue returns the value the setter name of the package at dir
+// would be computed to with the expression expr, checking the setters it
+// references are defined and don't form a cycle.
+func ComputeValue(dir, name, expr string) (string, error) {
+	if len(computedReferences(expr)) == 0 {
+		return "", errors.Errorf("computed setter %q expression %q references no setters, "+
+			"e.g. {name}.{zone}", name, expr)
+	}
+	defs, exprs, err := packageSetters(filepath.Join(dir, kptfile.KptFileName))
+	if err != nil {
+		return "", err
+	}
+	if _, found := defs[name]; !found {
+		defs[name] = setters2.SetterDefinition{Name: name}
+	}
+	exprs[name] = expr
+	computed, err := compute(exprs, defs)
+	if err != nil {
+		return "", err
+	}
+	for _, def := range computed {
+		if def.Name == name {
+			return def.Value, nil
+		}
+	}
+	return "", nil
+}
+
+// MarkComputed makes the setter name of the package at dir a computed
+// setter with the expression expr, and sets it to its computed value.
+func MarkComputed(dir, name, expr string) error {
+	if _, err := ComputeValue(dir, name, expr); err != nil {
+		return err
+	}
+	path := filepath.Join(dir, kptfile.KptFileName)
+	kf, err := yaml.ReadFile(path)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	def, err := kf.Pipe(yaml.Lookup("openAPI", "definitions", fieldmeta.SetterDefinitionPrefix+name))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if def == nil {
+		return errors.Errorf("setter %q is not defined", name)
+	}
+	if t, err := def.Pipe(yaml.Lookup("type")); err != nil {
+		return errors.WithStack(err)
+	} else if t != nil && t.YNode().Value == "array" {
+		return errors.Errorf("computed setters can't be arrays")
+	}
+	err = def.PipeE(
+		yaml.LookupCreate(yaml.MappingNode, "x-kpt"),
+		yaml.SetField("compute", yaml.NewScalarRNode(expr)))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if err := yaml.WriteFile(kf, path); err != nil {
+		return errors.WithStack(err)
+	}
+	_, err = computeSetters(dir, nil, true)
+	return err
+}
+
+// computeSetters computes the computed setters of the package at path,
+// with the values of the setters of its Kptfile overridden by overrides.
+// If write is false the package isn't changed, only checked.  It returns
+// the computed setters whose values changed.
+func computeSetters(path string, overrides map[string]string, write bool) ([]ComputedSetter, error) {
+	kf := filepath.Join(path, kptfile.KptFileName)
+	defs, exprs, err := packageSetters(kf)
+	if err != nil || len(exprs) == 0 {
+		return nil, err
+	}
+	current := map[string]setters2.SetterDefinition{}
+	for name, def := range defs {
+		current[name] = def
+		if value, found := overrides[name]; found {
+			def.Value, def.IsSet = value, true
+			defs[name] = def
+		}
+	}
+	computed, err := compute(exprs, defs)
+	if err != nil {
+		return nil, err
+	}
+
+	var changed []ComputedSetter
+	for _, def := range computed {
+		name := def.Name
+		if sc, err := setterSchema(kf, name); err != nil {
+			return nil, err
+		} else if sc != nil {
+			if err := validate(sc, def.Value, nil); err != nil {
+				return nil, errors.Errorf("invalid value %q computed for setter %q: %v", def.Value, name, err)
+			}
+		}
+		if def.Value == current[name].Value && def.IsSet == current[name].IsSet {
+			continue
+		}
+		changed = append(changed, ComputedSetter{Package: path, Name: name, Value: def.Value})
+		if !write {
+			continue
+		}
+		fs := &settersutil.FieldSetter{
+			Name:            name,
+			Value:           def.Value,
+			SetBy:           ComputedSetBy,
+			OpenAPIPath:     kf,
+			OpenAPIFileName: kptfile.KptFileName,
+			ResourcesPath:   path,
+			IsSet:           def.IsSet,
+		}
+		if _, err := fs.Set(); err != nil {
+			return nil, errors.Wrapf(err, "failed to set computed setter %q", name)
+		}
+	}
+	return changed, nil
+}
+
+// Recompute sets the computed setters of the package at root, and its
+// subpackages, to the values computed from the current setter values.  It
+// returns the computed setters whose values changed.
+func Recompute(root string) ([]ComputedSetter, error) {
+	paths, err := pathutil.DirsWithFile(root, kptfile.KptFileName, true)
+	if err != nil {
+		return nil, err
+	}
+	var changed []ComputedSetter
+	for _, p := range paths {
+		c, err := computeSetters(p, nil, true)
+		if err != nil {
+			return changed, errors.Wrapf(err, "package %q", p)
+		}
+		changed = append(changed, c...)
+	}
+	return changed, nil
+}
+
+// WriteComputed writes the computed setters whose values changed to w.
+func WriteComputed(w io.Writer, computed []ComputedSetter) {
+	for _, c := range computed {
+		fmt.Fprintf(w, "computed setter %q of package %q to value %q\n", c.Name, c.Package, c.Value)
+	}
+}
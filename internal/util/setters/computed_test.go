@@ -0,0 +1,219 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package setters
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt/internal/testutil/pkgbuilder"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/kustomize/kyaml/fieldmeta"
+	"sigs.k8s.io/kustomize/kyaml/setters2/settersutil"
+)
+
+const computedKptfile = `apiVersion: kpt.dev/v1alpha1
+kind: Kptfile
+metadata:
+  name: app
+openAPI:
+  definitions:
+    io.k8s.cli.setters.name:
+      x-k8s-cli:
+        setter:
+          name: name
+          value: web
+          isSet: true
+    io.k8s.cli.setters.zone:
+      x-k8s-cli:
+        setter:
+          name: zone
+          value: example.com
+    io.k8s.cli.setters.dns-name:
+      x-k8s-cli:
+        setter:
+          name: dns-name
+          value: web.example.com
+      x-kpt:
+        compute: '{name}.{zone}'
+    io.k8s.cli.setters.url:
+      x-k8s-cli:
+        setter:
+          name: url
+          value: https://web.example.com
+      x-kpt:
+        compute: 'https://{dns-name}'
+`
+
+const computedResource = `apiVersion: v1
+kind: Service
+metadata:
+  name: web # {"$kpt-set":"name"}
+  annotations:
+    dns: web.example.com # {"$kpt-set":"dns-name"}
+    url: https://web.example.com # {"$kpt-set":"url"}
+`
+
+// computedPackage returns a package with the Kptfile kptfile and resources
+// referencing computed setters.
+func computedPackage(kptfile string) map[string]string {
+	return map[string]string{
+		"Kptfile":      kptfile,
+		"service.yaml": computedResource,
+	}
+}
+
+func TestRecompute(t *testing.T) {
+	defer fieldmeta.SetShortHandRef(fieldmeta.ShortHandRef())
+	fieldmeta.SetShortHandRef("$kpt-set")
+	dir := pkgbuilder.WritePackage(t, computedPackage(computedKptfile))
+	defer os.RemoveAll(dir)
+
+	computed, err := Recompute(dir)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Empty(t, computed)
+
+	_, err = setValidated(&settersutil.FieldSetter{
+		Name:            "zone",
+		Value:           "prod.io",
+		OpenAPIPath:     filepath.Join(dir, "Kptfile"),
+		OpenAPIFileName: "Kptfile",
+		ResourcesPath:   dir,
+		IsSet:           true,
+	})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	computed, err = Recompute(dir)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, []ComputedSetter{
+		{Package: dir, Name: "dns-name", Value: "web.prod.io"},
+		{Package: dir, Name: "url", Value: "https://web.prod.io"},
+	}, computed)
+
+	b, err := ioutil.ReadFile(filepath.Join(dir, "service.yaml"))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, `apiVersion: v1
+kind: Service
+metadata:
+  name: web # {"$kpt-set":"name"}
+  annotations:
+    dns: web.prod.io # {"$kpt-set":"dns-name"}
+    url: https://web.prod.io # {"$kpt-set":"url"}
+`, string(b))
+
+	infos, err := ListSetters(dir, "dns-name", false)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	if assert.Len(t, infos, 1) {
+		assert.Equal(t, "{name}.{zone}", infos[0].Computed)
+		assert.Equal(t, ComputedSetBy, infos[0].SetBy)
+		assert.True(t, infos[0].IsSet)
+	}
+}
+
+func TestComputeValue(t *testing.T) {
+	var tests = []struct {
+		name     string
+		kptfile  string
+		setter   string
+		expr     string
+		expected string
+		err      string
+	}{
+		{
+			name:     "computed",
+			setter:   "host",
+			expr:     "{name}-{zone}",
+			expected: "web-example.com",
+		},
+		{
+			name:     "computed-from-computed",
+			setter:   "host",
+			expr:     "api.{dns-name}",
+			expected: "api.web.example.com",
+		},
+		{
+			name:   "no-references",
+			setter: "host",
+			expr:   "web",
+			err:    `computed setter "host" expression "web" references no setters, e.g. {name}.{zone}`,
+		},
+		{
+			name:   "undefined",
+			setter: "host",
+			expr:   "{name}.{region}",
+			err:    `computed setter "host" references undefined setter "region"`,
+		},
+		{
+			name:   "self",
+			setter: "host",
+			expr:   "{host}.{zone}",
+			err:    `computed setters form a cycle: host -> host`,
+		},
+		{
+			name:   "cycle",
+			setter: "name",
+			expr:   "{url}",
+			err:    `computed setters form a cycle: dns-name -> name -> url -> dns-name`,
+		},
+	}
+	for i := range tests {
+		test := tests[i]
+		t.Run(test.name, func(t *testing.T) {
+			dir := pkgbuilder.WritePackage(t, computedPackage(computedKptfile))
+			defer os.RemoveAll(dir)
+			value, err := ComputeValue(dir, test.setter, test.expr)
+			if test.err != "" {
+				if assert.Error(t, err) {
+					assert.Equal(t, test.err, err.Error())
+				}
+				return
+			}
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			assert.Equal(t, test.expected, value)
+		})
+	}
+}
+
+func TestValidateSet_computed(t *testing.T) {
+	dir := pkgbuilder.WritePackage(t, computedPackage(computedKptfile+`      pattern: '^https://[a-z.]+$'
+`))
+	defer os.RemoveAll(dir)
+
+	err := ValidateSet(dir, "dns-name", "api.example.com", nil, false)
+	if assert.Error(t, err) {
+		assert.Equal(t, `setter "dns-name" is computed from "{name}.{zone}", `+
+			`set the setters it references instead`, err.Error())
+	}
+
+	err = ValidateSet(dir, "zone", "prod_io", nil, false)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), `invalid value "https://web.prod_io" computed for setter "url"`)
+	}
+
+	assert.NoError(t, ValidateSet(dir, "zone", "prod.io", nil, false))
+}
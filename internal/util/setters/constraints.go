@@ -0,0 +1,149 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package setters
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-openapi/spec"
+	"github.com/pkg/errors"
+)
+
+// Constraints are the constraints of a setter which may be given when the
+// setter is created, rather than in a schema file.
+type Constraints struct {
+	// Minimum is the minimum value of integer and number setters.
+	Minimum *float64
+
+	// Maximum is the maximum value of integer and number setters.
+	Maximum *float64
+
+	// Enum are the allowed values of the setter.
+	Enum []string
+
+	// Pattern is a regular expression values of the setter must match.
+	Pattern string
+
+	// Format is the format of the values of the setter, one of dns-label,
+	// dns-subdomain and ip.
+	Format string
+}
+
+// IsEmpty returns true if cs doesn't constrain the setter.
+func (cs Constraints) IsEmpty() bool {
+	return cs.Minimum == nil && cs.Maximum == nil && len(cs.Enum) == 0 &&
+		cs.Pattern == "" && cs.Format == ""
+}
+
+// Schema returns the OpenAPI schema for a setter of type typ read from the
+// schema file at schemaPath, if it isn't empty, with the constraints cs
+// added.  An error is returned if the constraints are invalid, or if value,
+// the current value of the setter, violates them.
+func (cs Constraints) Schema(schemaPath, typ, value string) ([]byte, error) {
+	s := &spec.Schema{}
+	if schemaPath != "" {
+		b, err := ioutil.ReadFile(schemaPath)
+		if err != nil {
+			return nil, err
+		}
+		if len(b) > 0 {
+			if err := s.UnmarshalJSON(b); err != nil {
+				return nil, errors.Errorf("unable to parse schema: %v", err)
+			}
+		}
+	}
+	if typ != "" && len(s.Type) == 0 {
+		s.Type = spec.StringOrArray{typ}
+	}
+
+	if cs.Minimum != nil {
+		s.Minimum = cs.Minimum
+	}
+	if cs.Maximum != nil {
+		s.Maximum = cs.Maximum
+	}
+	if s.Minimum != nil && s.Maximum != nil && *s.Minimum > *s.Maximum {
+		return nil, errors.Errorf("minimum %s is greater than maximum %s",
+			strconv.FormatFloat(*s.Minimum, 'f', -1, 64), strconv.FormatFloat(*s.Maximum, 'f', -1, 64))
+	}
+	if (s.Minimum != nil || s.Maximum != nil) && schemaType(s) != "integer" && schemaType(s) != "number" {
+		return nil, errors.Errorf("minimum and maximum require an integer or number setter")
+	}
+	if len(cs.Enum) > 0 {
+		s.Enum = nil
+		for _, e := range cs.Enum {
+			v, err := enumValue(schemaType(s), e)
+			if err != nil {
+				return nil, err
+			}
+			s.Enum = append(s.Enum, v)
+		}
+	}
+	if cs.Pattern != "" {
+		if _, err := regexp.Compile(cs.Pattern); err != nil {
+			return nil, errors.Errorf("invalid pattern %q: %v", cs.Pattern, err)
+		}
+		s.Pattern = cs.Pattern
+	}
+	if cs.Format != "" {
+		if _, found := formats[cs.Format]; !found {
+			var names []string
+			for f := range formats {
+				names = append(names, f)
+			}
+			sort.Strings(names)
+			return nil, errors.Errorf("unsupported format %q, must be one of %s",
+				cs.Format, strings.Join(names, ", "))
+		}
+		s.Format = cs.Format
+	}
+
+	if schemaType(s) != "array" {
+		if err := validateScalar(s, value); err != nil {
+			return nil, errors.Errorf("current value of the setter %v", err)
+		}
+	}
+	return json.Marshal(s)
+}
+
+// enumValue returns the enum value e of a setter of type typ.
+func enumValue(typ, e string) (interface{}, error) {
+	switch typ {
+	case "integer":
+		i, err := strconv.ParseInt(e, 10, 64)
+		if err != nil {
+			return nil, errors.Errorf("enum value %q is not an integer", e)
+		}
+		return i, nil
+	case "number":
+		f, err := strconv.ParseFloat(e, 64)
+		if err != nil {
+			return nil, errors.Errorf("enum value %q is not a number", e)
+		}
+		return f, nil
+	case "boolean":
+		if e != "true" && e != "false" {
+			return nil, errors.Errorf("enum value %q is not a boolean", e)
+		}
+		return e == "true", nil
+	default:
+		return e, nil
+	}
+}
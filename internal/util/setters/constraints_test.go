@@ -0,0 +1,103 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package setters
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConstraints_Schema(t *testing.T) {
+	one, ten := 1.0, 10.0
+	var tests = []struct {
+		name        string
+		constraints Constraints
+		schema      string
+		typ         string
+		value       string
+		expected    string
+		err         string
+	}{
+		{name: "minimum and maximum",
+			constraints: Constraints{Minimum: &one, Maximum: &ten},
+			typ:         "integer", value: "3",
+			expected: `{"type":"integer","maximum":10,"minimum":1}`},
+		{name: "minimum greater than maximum",
+			constraints: Constraints{Minimum: &ten, Maximum: &one},
+			typ:         "integer", value: "3",
+			err: "minimum 10 is greater than maximum 1"},
+		{name: "minimum of a string",
+			constraints: Constraints{Minimum: &one},
+			typ:         "string", value: "3",
+			err: "minimum and maximum require an integer or number setter"},
+		{name: "integer enum",
+			constraints: Constraints{Enum: []string{"1", "3"}},
+			typ:         "integer", value: "3",
+			expected: `{"type":"integer","enum":[1,3]}`},
+		{name: "invalid enum value",
+			constraints: Constraints{Enum: []string{"one"}},
+			typ:         "integer", value: "3",
+			err: `enum value "one" is not an integer`},
+		{name: "pattern and format",
+			constraints: Constraints{Pattern: "^web", Format: "dns-label"},
+			value:       "web-1",
+			expected:    `{"format":"dns-label","pattern":"^web"}`},
+		{name: "unsupported format",
+			constraints: Constraints{Format: "email"},
+			value:       "web",
+			err:         `unsupported format "email", must be one of dns-label, dns-subdomain, ip`},
+		{name: "merged with the schema file",
+			constraints: Constraints{Maximum: &ten},
+			schema:      `{"type": "integer", "minimum": 1}`,
+			value:       "3",
+			expected:    `{"type":"integer","maximum":10,"minimum":1}`},
+		{name: "current value violates the constraints",
+			constraints: Constraints{Enum: []string{"us-east1", "europe-west1"}},
+			value:       "asia",
+			err:         `current value of the setter violates enum [europe-west1, us-east1]: got "asia"`},
+	}
+	for i := range tests {
+		test := tests[i]
+		t.Run(test.name, func(t *testing.T) {
+			var schemaPath string
+			if test.schema != "" {
+				dir, err := ioutil.TempDir("", "")
+				if !assert.NoError(t, err) {
+					t.FailNow()
+				}
+				defer os.RemoveAll(dir)
+				schemaPath = filepath.Join(dir, "schema.json")
+				if !assert.NoError(t, ioutil.WriteFile(schemaPath, []byte(test.schema), 0600)) {
+					t.FailNow()
+				}
+			}
+			schema, err := test.constraints.Schema(schemaPath, test.typ, test.value)
+			if test.err != "" {
+				if assert.Error(t, err) {
+					assert.Equal(t, test.err, err.Error())
+				}
+				return
+			}
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			assert.JSONEq(t, test.expected, string(schema))
+		})
+	}
+}
@@ -0,0 +1,163 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package setters
+
+import (
+	"path/filepath"
+
+	"github.com/GoogleContainerTools/kpt/pkg/kptfile"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/kustomize/kyaml/fieldmeta"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/kio/kioutil"
+	"sigs.k8s.io/kustomize/kyaml/pathutil"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// packages returns the package at root and, if recurse is true, its
+// subpackages.
+func packages(root string, recurse bool) ([]string, error) {
+	if !recurse {
+		return []string{root}, nil
+	}
+	return pathutil.DirsWithFile(root, kptfile.KptFileName, true)
+}
+
+// CheckDeleteSetter returns an error if the setter name is referenced by a
+// capture substitution of the package at root or, if recurse is true, its
+// subpackages, as deleting it would leave the substitution dangling.
+// Substitutions referencing the setter are checked when it is deleted.
+func CheckDeleteSetter(root, name string, recurse bool) error {
+	paths, err := packages(root, recurse)
+	if err != nil {
+		return err
+	}
+	ref := fieldmeta.DefinitionsPrefix + fieldmeta.SetterDefinitionPrefix + name
+	for _, p := range paths {
+		defs, err := captureDefinitions(filepath.Join(p, kptfile.KptFileName))
+		if err != nil {
+			return err
+		}
+		for _, d := range defs {
+			for _, v := range d.Values {
+				if v.Ref == ref {
+					return errors.Errorf("setter %q is used in capture substitution %q, "+
+						"please delete the parent substitution first", name, d.Name)
+				}
+			}
+		}
+		starlarkDefs, err := starlarkDefinitions(filepath.Join(p, kptfile.KptFileName))
+		if err != nil {
+			return err
+		}
+		for _, d := range starlarkDefs {
+			for _, s := range d.Setters {
+				if s == name {
+					return errors.Errorf("setter %q is used in starlark substitution %q, "+
+						"please delete the parent substitution first", name, d.Name)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// DeleteCapture deletes the capture substitution name from the package at
+// root and, if recurse is true, the subpackages defining it, removing the
+// field references to it.  It returns the packages the substitution was
+// deleted from, which are empty if no package defines it.
+func DeleteCapture(root, name string, recurse bool) ([]string, error) {
+	paths, err := packages(root, recurse)
+	if err != nil {
+		return nil, err
+	}
+	var deleted []string
+	for _, p := range paths {
+		found, err := definitionExists(p, CaptureDefinitionPrefix+name)
+		if err != nil {
+			return deleted, err
+		}
+		if !found {
+			continue
+		}
+		if err := deleteDefinition(filepath.Join(p, kptfile.KptFileName), CaptureDefinitionPrefix+name); err != nil {
+			return deleted, errors.Wrapf(err, "package %q", p)
+		}
+		if err := deleteCaptureReferences(p, name); err != nil {
+			return deleted, errors.Wrapf(err, "package %q", p)
+		}
+		deleted = append(deleted, p)
+	}
+	return deleted, nil
+}
+
+// deleteDefinition deletes the definition key from the Kptfile at path,
+// and the openAPI definitions if they are left empty.
+func deleteDefinition(path, key string) error {
+	kf, err := yaml.ReadFile(path)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if _, err := kf.Pipe(yaml.Lookup("openAPI", "definitions"), yaml.FieldClearer{Name: key}); err != nil {
+		return errors.WithStack(err)
+	}
+	if _, err := kf.Pipe(yaml.Lookup("openAPI"), yaml.FieldClearer{Name: "definitions", IfEmpty: true}); err != nil {
+		return errors.WithStack(err)
+	}
+	if _, err := kf.Pipe(yaml.FieldClearer{Name: "openAPI", IfEmpty: true}); err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(yaml.WriteFile(kf, path))
+}
+
+// deleteCaptureReferences removes the field references to the capture
+// substitution name from the resources of the package at path.
+func deleteCaptureReferences(path, name string) error {
+	rw := &kio.LocalPackageReadWriter{PackagePath: path, NoDeleteFiles: true, PackageFileName: kptfile.KptFileName}
+	nodes, err := rw.Read()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	changed := map[string]bool{}
+	for _, n := range nodes {
+		file, _, err := kioutil.GetFileAnnotations(n)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		var walk func(*yaml.Node)
+		walk = func(y *yaml.Node) {
+			for _, comment := range []*string{&y.LineComment, &y.HeadComment} {
+				if ref, ok := captureReference(*comment); ok && ref == name {
+					*comment = ""
+					changed[file] = true
+				}
+			}
+			for _, c := range y.Content {
+				walk(c)
+			}
+		}
+		walk(n.YNode())
+	}
+	var out []*yaml.RNode
+	for _, n := range nodes {
+		if file, _, _ := kioutil.GetFileAnnotations(n); changed[file] {
+			out = append(out, n)
+		}
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return errors.WithStack(rw.Write(out))
+}
@@ -0,0 +1,64 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package setters
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt/internal/testutil/pkgbuilder"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckDeleteSetter(t *testing.T) {
+	dir := pkgbuilder.WritePackage(t, renamePackage)
+	defer os.RemoveAll(dir)
+
+	assert.EqualError(t, CheckDeleteSetter(dir, "tag", false),
+		`setter "tag" is used in capture substitution "tags", please delete the parent substitution first`)
+	assert.NoError(t, CheckDeleteSetter(dir, "replicas", false))
+}
+
+func TestDeleteCapture(t *testing.T) {
+	dir := pkgbuilder.WritePackage(t, renamePackage)
+	defer os.RemoveAll(dir)
+
+	deleted, err := DeleteCapture(dir, "image", false)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Empty(t, deleted)
+
+	deleted, err = DeleteCapture(dir, "tags", false)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, []string{dir}, deleted)
+
+	kf, err := ioutil.ReadFile(filepath.Join(dir, "Kptfile"))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.NotContains(t, string(kf), "io.kpt.substitutions.tags")
+	assert.NoError(t, CheckDeleteSetter(dir, "tag", false))
+
+	deploy, err := ioutil.ReadFile(filepath.Join(dir, "deploy.yaml"))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Contains(t, string(deploy), "    images: a:1.7,b:1.7\n")
+}
@@ -0,0 +1,238 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package setters
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/GoogleContainerTools/kpt/pkg/kptfile"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/kustomize/kyaml/fieldmeta"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/kio/kioutil"
+	"sigs.k8s.io/kustomize/kyaml/openapi"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// AppendElements returns the values of the array setter name of the package
+// at path with values appended, skipping those which are already elements.
+func AppendElements(path, name string, values []string) ([]string, error) {
+	elements, err := arrayElements(path, name)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range values {
+		if indexOf(elements, v) < 0 {
+			elements = append(elements, v)
+		}
+	}
+	return elements, nil
+}
+
+// RemoveElements returns the values of the array setter name of the package
+// at path with values removed.  Each value must be an element, and at least
+// one element must remain, as the lists referencing the setter can't be
+// set empty.
+func RemoveElements(path, name string, values []string) ([]string, error) {
+	elements, err := arrayElements(path, name)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range values {
+		i := indexOf(elements, v)
+		if i < 0 {
+			return nil, errors.Errorf("%q is not an element of setter %q", v, name)
+		}
+		elements = append(elements[:i:i], elements[i+1:]...)
+	}
+	if len(elements) == 0 {
+		return nil, errors.Errorf("can't remove every element of setter %q", name)
+	}
+	return elements, nil
+}
+
+// arrayElements returns the values of the array setter name of the package
+// at path.
+func arrayElements(path, name string) ([]string, error) {
+	kf := filepath.Join(path, kptfile.KptFileName)
+	defs, err := setterDefinitions(kf)
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range defs {
+		if d.Name != name {
+			continue
+		}
+		def, err := setterSchema(kf, name)
+		if err != nil {
+			return nil, err
+		}
+		if len(d.ListValues) == 0 && (def == nil || schemaType(def) != "array") {
+			return nil, errors.Errorf("setter %q is not an array setter", name)
+		}
+		return append([]string(nil), d.ListValues...), nil
+	}
+	return nil, errors.Errorf("setter %q is not defined", name)
+}
+
+// indexOf returns the index of v in values, or -1.
+func indexOf(values []string, v string) int {
+	for i := range values {
+		if values[i] == v {
+			return i
+		}
+	}
+	return -1
+}
+
+// PreserveElements calls set, which replaces the elements of the lists of
+// the package at path referencing the array setter name, and then restores
+// the original nodes of the elements which are still in the lists, so that
+// the comments and styles of untouched elements are preserved.
+func PreserveElements(path, name string, set func() error) error {
+	if _, err := arrayElements(path, name); err != nil {
+		// only the lists of array setters are set
+		return set()
+	}
+	before, err := readLists(path, name)
+	if err != nil {
+		return err
+	}
+	if len(before.lists) == 0 {
+		return set()
+	}
+	if err := set(); err != nil {
+		return err
+	}
+
+	after, err := readLists(path, name)
+	if err != nil {
+		return err
+	}
+	changed := map[string]bool{}
+	for key, l := range after.lists {
+		orig, found := before.lists[key]
+		if !found {
+			continue
+		}
+		used := map[*yaml.Node]bool{}
+		for i, e := range l.seq.Content {
+			for _, o := range orig.elements {
+				if !used[o] && o.Kind == yaml.ScalarNode && o.Value == e.Value {
+					used[o] = true
+					l.seq.Content[i] = o
+					break
+				}
+			}
+			if l.seq.Content[i] == e && len(orig.elements) > 0 {
+				// new elements are styled like the original elements
+				e.Style = orig.elements[0].Style
+			}
+		}
+		l.seq.Style = orig.style
+		changed[l.file] = true
+	}
+	var out []*yaml.RNode
+	for _, n := range after.nodes {
+		if file, _, _ := kioutil.GetFileAnnotations(n); changed[file] {
+			out = append(out, n)
+		}
+	}
+	return errors.WithStack(after.rw.Write(out))
+}
+
+// setterList is a list referencing an array setter.
+type setterList struct {
+	// file is the file of the resource of the list
+	file string
+
+	// seq is the list
+	seq *yaml.Node
+
+	// elements and style are the elements and style of the list when read
+	elements []*yaml.Node
+	style    yaml.Style
+}
+
+// packageLists are the lists of a package referencing an array setter.
+type packageLists struct {
+	rw    *kio.LocalPackageReadWriter
+	nodes []*yaml.RNode
+
+	// lists are keyed by the file, index and field path of the list
+	lists map[string]setterList
+}
+
+// readLists reads the lists of the package at path referencing the array
+// setter name.
+func readLists(path, name string) (packageLists, error) {
+	ref := fieldmeta.DefinitionsPrefix + fieldmeta.SetterDefinitionPrefix + name
+	sc, err := openapi.SchemaFromFile(filepath.Join(path, kptfile.KptFileName))
+	if err != nil {
+		return packageLists{}, errors.WithStack(err)
+	}
+	rw := &kio.LocalPackageReadWriter{PackagePath: path, NoDeleteFiles: true, PackageFileName: kptfile.KptFileName}
+	nodes, err := rw.Read()
+	if err != nil {
+		return packageLists{}, errors.WithStack(err)
+	}
+	pl := packageLists{rw: rw, nodes: nodes, lists: map[string]setterList{}}
+	for _, n := range nodes {
+		file, index, err := kioutil.GetFileAnnotations(n)
+		if err != nil {
+			return packageLists{}, errors.WithStack(err)
+		}
+		var visit func(n *yaml.Node, p string) error
+		visit = func(n *yaml.Node, p string) error {
+			switch n.Kind {
+			case yaml.MappingNode:
+				for i := 0; i+1 < len(n.Content); i += 2 {
+					k, v := n.Content[i], n.Content[i+1]
+					fp := p + "." + k.Value
+					if v.Kind == yaml.SequenceNode {
+						fm := fieldmeta.FieldMeta{SettersSchema: sc}
+						if err := fm.Read(yaml.NewRNode(k)); err != nil {
+							return errors.WithStack(err)
+						}
+						if fm.Schema.Ref.String() == ref {
+							pl.lists[fmt.Sprintf("%s/%s%s", file, index, fp)] = setterList{
+								file:     file,
+								seq:      v,
+								elements: append([]*yaml.Node(nil), v.Content...),
+								style:    v.Style,
+							}
+							continue
+						}
+					}
+					if err := visit(v, fp); err != nil {
+						return err
+					}
+				}
+			case yaml.SequenceNode:
+				for i, c := range n.Content {
+					if err := visit(c, fmt.Sprintf("%s[%d]", p, i)); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		}
+		if err := visit(n.YNode(), ""); err != nil {
+			return packageLists{}, err
+		}
+	}
+	return pl, nil
+}
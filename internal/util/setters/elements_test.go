@@ -0,0 +1,169 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package setters
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt/pkg/kptfile"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/kustomize/kyaml/fieldmeta"
+	"sigs.k8s.io/kustomize/kyaml/setters2/settersutil"
+)
+
+const elementsKptfile = `apiVersion: kpt.dev/v1alpha1
+kind: Kptfile
+metadata:
+  name: elements
+openAPI:
+  definitions:
+    io.k8s.cli.setters.hosts:
+      type: array
+      x-k8s-cli:
+        setter:
+          name: hosts
+          listValues:
+          - a.example.com
+          - b.example.com
+          - c.example.com
+    io.k8s.cli.setters.replicas:
+      x-k8s-cli:
+        setter:
+          name: replicas
+          value: "3"
+`
+
+func TestElements(t *testing.T) {
+	var tests = []struct {
+		name     string
+		remove   bool
+		values   []string
+		setter   string
+		expected []string
+		err      string
+	}{
+		{name: "append",
+			values:   []string{"d.example.com", "a.example.com"},
+			expected: []string{"a.example.com", "b.example.com", "c.example.com", "d.example.com"}},
+		{name: "remove",
+			remove:   true,
+			values:   []string{"b.example.com"},
+			expected: []string{"a.example.com", "c.example.com"}},
+		{name: "remove missing element",
+			remove: true,
+			values: []string{"d.example.com"},
+			err:    `"d.example.com" is not an element of setter "hosts"`},
+		{name: "remove every element",
+			remove: true,
+			values: []string{"a.example.com", "b.example.com", "c.example.com"},
+			err:    `can't remove every element of setter "hosts"`},
+		{name: "not an array setter",
+			setter: "replicas",
+			values: []string{"5"},
+			err:    `setter "replicas" is not an array setter`},
+		{name: "not defined",
+			setter: "ports",
+			values: []string{"80"},
+			err:    `setter "ports" is not defined`},
+	}
+	for i := range tests {
+		test := tests[i]
+		t.Run(test.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "")
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			defer os.RemoveAll(dir)
+			if !assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, kptfile.KptFileName), []byte(elementsKptfile), 0600)) {
+				t.FailNow()
+			}
+
+			setter := test.setter
+			if setter == "" {
+				setter = "hosts"
+			}
+			var elements []string
+			if test.remove {
+				elements, err = RemoveElements(dir, setter, test.values)
+			} else {
+				elements, err = AppendElements(dir, setter, test.values)
+			}
+			if test.err != "" {
+				assert.EqualError(t, err, test.err)
+				return
+			}
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			assert.Equal(t, test.expected, elements)
+		})
+	}
+}
+
+func TestPreserveElements(t *testing.T) {
+	defer fieldmeta.SetShortHandRef(fieldmeta.ShortHandRef())
+	fieldmeta.SetShortHandRef("$kpt-set")
+	dir, err := ioutil.TempDir("", "")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir)
+	if !assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, kptfile.KptFileName), []byte(elementsKptfile), 0600)) {
+		t.FailNow()
+	}
+	r := `apiVersion: example.com/v1
+kind: Allowlist
+metadata:
+  name: web
+spec:
+  hosts: # {"$kpt-set":"hosts"}
+  - a.example.com # primary
+  # staging
+  - b.example.com
+  - c.example.com
+`
+	if !assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "resources.yaml"), []byte(r), 0600)) {
+		t.FailNow()
+	}
+
+	_, err = setValidated(&settersutil.FieldSetter{
+		Name:            "hosts",
+		Value:           "c.example.com",
+		ListValues:      []string{"a.example.com", "d.example.com"},
+		OpenAPIPath:     filepath.Join(dir, kptfile.KptFileName),
+		OpenAPIFileName: kptfile.KptFileName,
+		ResourcesPath:   dir,
+	})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	b, err := ioutil.ReadFile(filepath.Join(dir, "resources.yaml"))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, `apiVersion: example.com/v1
+kind: Allowlist
+metadata:
+  name: web
+spec:
+  hosts: # {"$kpt-set":"hosts"}
+  - c.example.com
+  - a.example.com # primary
+  - d.example.com
+`, string(b))
+}
@@ -0,0 +1,267 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package setters
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/GoogleContainerTools/kpt/pkg/kptfile"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/kustomize/kyaml/pathutil"
+	"sigs.k8s.io/kustomize/kyaml/setters2"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// HistoryFileName is the name of the file recording the history of the
+// setter values of a package, next to its Kptfile.  It isn't a yaml file so
+// that it isn't read as a resource of the package.
+const HistoryFileName = kptfile.HistoryFileName
+
+// now returns the time changes are recorded at.
+var now = time.Now
+
+// HistoryEntry is a change of the value of a setter.
+type HistoryEntry struct {
+	// Timestamp is when the value was changed, in RFC 3339 format
+	Timestamp string `json:"timestamp" yaml:"timestamp"`
+
+	// Setter is the name of the setter
+	Setter string `json:"setter" yaml:"setter"`
+
+	// Scope is the file or resource the value is scoped to, if it is an
+	// override
+	Scope string `json:"scope,omitempty" yaml:"scope,omitempty"`
+
+	// OldValue and OldListValues are the value before the change, if the
+	// setter had one
+	OldValue      string   `json:"oldValue,omitempty" yaml:"oldValue,omitempty"`
+	OldListValues []string `json:"oldListValues,omitempty" yaml:"oldListValues,omitempty"`
+
+	// NewValue and NewListValues are the value after the change
+	NewValue      string   `json:"newValue,omitempty" yaml:"newValue,omitempty"`
+	NewListValues []string `json:"newListValues,omitempty" yaml:"newListValues,omitempty"`
+
+	// SetBy is who set the new value, if recorded on the setter
+	SetBy string `json:"setBy,omitempty" yaml:"setBy,omitempty"`
+}
+
+// History is the history of the setter values of a package, oldest first.
+type History struct {
+	Entries []HistoryEntry `yaml:"entries"`
+}
+
+// ReadHistory reads the history of the package at dir, which is empty if
+// no setter has been set since it was recorded.
+func ReadHistory(dir string) (History, error) {
+	var h History
+	path := filepath.Join(dir, HistoryFileName)
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return h, nil
+	}
+	if err != nil {
+		return h, errors.WithStack(err)
+	}
+	if err := yaml.Unmarshal(b, &h); err != nil {
+		return h, errors.Wrapf(err, "failed to parse %q", path)
+	}
+	return h, nil
+}
+
+// packageValues are the setter definitions and scoped overrides of a
+// package, by setter name.
+type packageValues struct {
+	defs      map[string]setters2.SetterDefinition
+	overrides map[string][]ScopedOverride
+}
+
+// Snapshot is the setter values of a package and its subpackages, by the
+// path of the package.  The changes of setting setters are recorded by
+// comparing the snapshots taken before and after.
+type Snapshot map[string]packageValues
+
+// TakeSnapshot returns the setter values of the package at root and its
+// subpackages.
+func TakeSnapshot(root string) (Snapshot, error) {
+	paths, err := pathutil.DirsWithFile(root, kptfile.KptFileName, true)
+	if err != nil {
+		return nil, err
+	}
+	s := Snapshot{}
+	for _, p := range paths {
+		defs, err := setterDefinitions(filepath.Join(p, kptfile.KptFileName))
+		if err != nil {
+			return nil, err
+		}
+		overrides, err := overrideDefinitions(filepath.Join(p, kptfile.KptFileName))
+		if err != nil {
+			return nil, err
+		}
+		v := packageValues{defs: map[string]setters2.SetterDefinition{}, overrides: overrides}
+		for _, d := range defs {
+			v.defs[d.Name] = d
+		}
+		s[p] = v
+	}
+	return s, nil
+}
+
+// RecordHistory appends the setter values which changed since the snapshot
+// before was taken to the history of each package, and returns the number
+// of changes recorded.
+func RecordHistory(root string, before Snapshot) (int, error) {
+	after, err := TakeSnapshot(root)
+	if err != nil {
+		return 0, err
+	}
+	timestamp := now().UTC().Format(time.RFC3339)
+	count := 0
+	for p, a := range after {
+		entries := changes(before[p], a)
+		if len(entries) == 0 {
+			continue
+		}
+		for i := range entries {
+			entries[i].Timestamp = timestamp
+		}
+		h, err := ReadHistory(p)
+		if err != nil {
+			return count, err
+		}
+		h.Entries = append(h.Entries, entries...)
+		b, err := yaml.Marshal(h)
+		if err != nil {
+			return count, errors.WithStack(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(p, HistoryFileName), b, 0600); err != nil {
+			return count, errors.WithStack(err)
+		}
+		count += len(entries)
+	}
+	return count, nil
+}
+
+// changes returns the entries for the setter values of a package which
+// changed from before to after, sorted by setter.
+func changes(before, after packageValues) []HistoryEntry {
+	var entries []HistoryEntry
+	for _, name := range sortedKeys(after.defs) {
+		a := after.defs[name]
+		b, found := before.defs[name]
+		if !found || b.Value != a.Value || !reflect.DeepEqual(b.ListValues, a.ListValues) {
+			entries = append(entries, HistoryEntry{
+				Setter:   name,
+				OldValue: b.Value, OldListValues: b.ListValues,
+				NewValue: a.Value, NewListValues: a.ListValues,
+				SetBy: a.SetBy,
+			})
+		}
+		for _, o := range after.overrides[name] {
+			// a new override overrides the value of the setter
+			old := b.Value
+			for _, bo := range before.overrides[name] {
+				if bo.Scope() == o.Scope() {
+					old = bo.Value
+				}
+			}
+			if old != o.Value {
+				entries = append(entries, HistoryEntry{
+					Setter: name, Scope: o.Scope(), OldValue: old, NewValue: o.Value,
+				})
+			}
+		}
+	}
+	return entries
+}
+
+// sortedKeys returns the setter names of defs, sorted.
+func sortedKeys(defs map[string]setters2.SetterDefinition) []string {
+	var names []string
+	for name := range defs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// FilterHistory returns the entries of h which change the setter name, or
+// all of them if name is empty.
+func FilterHistory(h History, name string) []HistoryEntry {
+	if name == "" {
+		return h.Entries
+	}
+	var entries []HistoryEntry
+	for _, e := range h.Entries {
+		if e.Setter == name {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
+
+// WriteHistory writes the history entries to w in format, JSONOutput or
+// YAMLOutput, or as a table if format is empty.
+func WriteHistory(w io.Writer, format string, entries []HistoryEntry) error {
+	if entries == nil {
+		entries = []HistoryEntry{}
+	}
+	switch format {
+	case "":
+		return writeHistoryTable(w, entries)
+	case JSONOutput:
+		e := json.NewEncoder(w)
+		e.SetIndent("", "  ")
+		return errors.WithStack(e.Encode(entries))
+	case YAMLOutput:
+		b, err := yaml.Marshal(entries)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		_, err = w.Write(b)
+		return errors.WithStack(err)
+	default:
+		return errors.Errorf("unsupported output format %q, must be %s or %s", format, JSONOutput, YAMLOutput)
+	}
+}
+
+// writeHistoryTable writes a table of the history entries to w.
+func writeHistoryTable(w io.Writer, entries []HistoryEntry) error {
+	display := func(value string, listValues []string) string {
+		switch {
+		case len(listValues) > 0:
+			return "[" + strings.Join(listValues, ",") + "]"
+		case value == "":
+			return "<none>"
+		}
+		return value
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "TIMESTAMP\tSETTER\tSCOPE\tOLD VALUE\tNEW VALUE\tSET BY")
+	for _, e := range entries {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", e.Timestamp, e.Setter, e.Scope,
+			display(e.OldValue, e.OldListValues), display(e.NewValue, e.NewListValues), e.SetBy)
+	}
+	return errors.WithStack(tw.Flush())
+}
@@ -0,0 +1,110 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package setters
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/GoogleContainerTools/kpt/internal/testutil/pkgbuilder"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/kustomize/kyaml/fieldmeta"
+	"sigs.k8s.io/kustomize/kyaml/setters2/settersutil"
+)
+
+func TestRecordHistory(t *testing.T) {
+	defer fieldmeta.SetShortHandRef(fieldmeta.ShortHandRef())
+	fieldmeta.SetShortHandRef("$kpt-set")
+	defer func(f func() time.Time) { now = f }(now)
+	now = func() time.Time { return time.Date(2020, 6, 1, 10, 0, 0, 0, time.UTC) }
+
+	dir := pkgbuilder.WritePackage(t, map[string]string{
+		"Kptfile":  scopedKptfile,
+		"web.yaml": scopedResources,
+	})
+	defer os.RemoveAll(dir)
+
+	// no history has been recorded
+	h, err := ReadHistory(dir)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Empty(t, h.Entries)
+
+	set := func(value, setBy string) {
+		before, err := TakeSnapshot(dir)
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		_, err = (&settersutil.FieldSetter{
+			Name:            "replicas",
+			Value:           value,
+			SetBy:           setBy,
+			OpenAPIPath:     filepath.Join(dir, "Kptfile"),
+			OpenAPIFileName: "Kptfile",
+			ResourcesPath:   dir,
+			IsSet:           true,
+		}).Set()
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		if _, err := RecordHistory(dir, before); !assert.NoError(t, err) {
+			t.FailNow()
+		}
+	}
+	set("5", "alice")
+	// unchanged values aren't recorded
+	set("5", "bob")
+	set("7", "")
+
+	before, err := TakeSnapshot(dir)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	s := ScopedSet{Name: "replicas", Value: "9", Scope: ScopedOverride{Resource: "Deployment/web"}}
+	if _, err := s.Set(dir); !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	n, err := RecordHistory(dir, before)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, 1, n)
+
+	h, err = ReadHistory(dir)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, []HistoryEntry{
+		{Timestamp: "2020-06-01T10:00:00Z", Setter: "replicas", OldValue: "3", NewValue: "5", SetBy: "alice"},
+		{Timestamp: "2020-06-01T10:00:00Z", Setter: "replicas", OldValue: "5", NewValue: "7"},
+		{Timestamp: "2020-06-01T10:00:00Z", Setter: "replicas", Scope: "resource Deployment/web",
+			OldValue: "7", NewValue: "9"},
+	}, h.Entries)
+
+	out := &bytes.Buffer{}
+	if !assert.NoError(t, WriteHistory(out, "", FilterHistory(h, "replicas"))) {
+		t.FailNow()
+	}
+	assert.Equal(t, `TIMESTAMP             SETTER    SCOPE                    OLD VALUE  NEW VALUE  SET BY
+2020-06-01T10:00:00Z  replicas                           3          5          alice
+2020-06-01T10:00:00Z  replicas                           5          7          
+2020-06-01T10:00:00Z  replicas  resource Deployment/web  7          9          
+`, out.String())
+	assert.Empty(t, FilterHistory(h, "image"))
+}
@@ -0,0 +1,462 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package setters
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/GoogleContainerTools/kpt/internal/util/redact"
+	"github.com/GoogleContainerTools/kpt/pkg/kptfile"
+	"github.com/go-openapi/spec"
+	"github.com/olekukonko/tablewriter"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/kustomize/kyaml/fieldmeta"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/kio/kioutil"
+	"sigs.k8s.io/kustomize/kyaml/openapi"
+	"sigs.k8s.io/kustomize/kyaml/pathutil"
+	"sigs.k8s.io/kustomize/kyaml/setters2"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// SetterInfo describes a setter of a package, and the fields it sets, for
+// machine-readable listings.
+type SetterInfo struct {
+	// Package is the path of the package defining the setter
+	Package string `json:"package" yaml:"package"`
+
+	// Name is the name of the setter
+	Name string `json:"name" yaml:"name"`
+
+	// Type is the OpenAPI type of the setter, if it is typed, or
+	// SecretType for secret setters
+	Type string `json:"type,omitempty" yaml:"type,omitempty"`
+
+	// SecretFrom is the source the value of secret setters is read from
+	SecretFrom string `json:"secretFrom,omitempty" yaml:"secretFrom,omitempty"`
+
+	// Computed is the expression computed setters derive their value from
+	Computed string `json:"computed,omitempty" yaml:"computed,omitempty"`
+
+	// Value is the current value of the setter
+	Value string `json:"value,omitempty" yaml:"value,omitempty"`
+
+	// ListValues are the current values of array setters
+	ListValues []string `json:"listValues,omitempty" yaml:"listValues,omitempty"`
+
+	// Overrides are the values of the setter scoped to files or resources
+	Overrides []ScopedOverride `json:"overrides,omitempty" yaml:"overrides,omitempty"`
+
+	// Description is the description of the setter
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+
+	// SetBy records who set the current value
+	SetBy string `json:"setBy,omitempty" yaml:"setBy,omitempty"`
+
+	// Required is true if the setter must be set before the package is
+	// deployed
+	Required bool `json:"required" yaml:"required"`
+
+	// IsSet is true if the setter has been set
+	IsSet bool `json:"isSet" yaml:"isSet"`
+
+	// Constraints summarize the constraints of the setter definition, e.g.
+	// "minimum 1"
+	Constraints []string `json:"constraints,omitempty" yaml:"constraints,omitempty"`
+
+	// Count is the number of fields set by the setter
+	Count int `json:"count" yaml:"count"`
+
+	// Fields are the fields set by the setter, directly or through
+	// substitutions
+	Fields []SetterField `json:"fields,omitempty" yaml:"fields,omitempty"`
+}
+
+// SetterField is a field set by a setter.
+type SetterField struct {
+	// File is the path of the file of the resource, relative to the package
+	File string `json:"file" yaml:"file"`
+
+	// Resource is the kind and name of the resource, e.g. Deployment/nginx
+	Resource string `json:"resource" yaml:"resource"`
+
+	// Path is the path to the field, e.g. spec.template.spec.containers[0].image
+	Path string `json:"path" yaml:"path"`
+}
+
+// ListSetters returns the setters of the package at root, and if recurse
+// is true of its subpackages, sorted by package and name.  If name isn't
+// empty only the setters with that name are returned.
+func ListSetters(root, name string, recurse bool) ([]SetterInfo, error) {
+	paths := []string{root}
+	if recurse {
+		var err error
+		if paths, err = pathutil.DirsWithFile(root, kptfile.KptFileName, true); err != nil {
+			return nil, err
+		}
+	}
+	infos := []SetterInfo{}
+	for _, p := range paths {
+		i, err := listSetters(p, name)
+		if err != nil {
+			return nil, errors.Wrapf(err, "package %q", p)
+		}
+		infos = append(infos, i...)
+	}
+	return infos, nil
+}
+
+// listSetters returns the setters of the package at path.
+func listSetters(path, name string) ([]SetterInfo, error) {
+	kf := filepath.Join(path, kptfile.KptFileName)
+	sc, err := openapi.SchemaFromFile(kf)
+	if err != nil {
+		return nil, err
+	}
+	l := setters2.List{Name: name, OpenAPIFileName: kptfile.KptFileName, SettersSchema: sc}
+	if err := l.ListSetters(kf, path); err != nil {
+		return nil, err
+	}
+	// the substitutions aren't filtered by name, as they may reference the
+	// setter with any name
+	substs := setters2.List{OpenAPIFileName: kptfile.KptFileName, SettersSchema: sc}
+	if err := substs.ListSubst(kf); err != nil {
+		return nil, err
+	}
+	fields, err := setterFields(path, sc, substs.Substitutions)
+	if err != nil {
+		return nil, err
+	}
+	overrides, err := overrideDefinitions(kf)
+	if err != nil {
+		return nil, err
+	}
+
+	var infos []SetterInfo
+	for _, s := range l.Setters {
+		info := SetterInfo{
+			Package:     path,
+			Name:        s.Name,
+			Value:       s.Value,
+			ListValues:  s.ListValues,
+			Overrides:   overrides[s.Name],
+			Description: s.Description,
+			SetBy:       s.SetBy,
+			Required:    s.Required,
+			IsSet:       s.IsSet,
+			Count:       len(fields[s.Name]),
+			Fields:      fields[s.Name],
+		}
+		if sc != nil {
+			if def, found := sc.Definitions[fieldmeta.SetterDefinitionPrefix+s.Name]; found {
+				info.Type = schemaType(&def)
+				info.Constraints = constraints(&def)
+				info.Computed = computedExpression(&def)
+				if info.SecretFrom = secretSource(&def); info.SecretFrom != "" {
+					info.Type = SecretType
+					info.Value = redact.Placeholder
+				}
+			}
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// setterFields returns the fields of the resources of the package at path
+// referencing each setter, directly or through substitutions and capture
+// substitutions, keyed by setter name.
+func setterFields(path string, sc *spec.Schema, substs []setters2.SubstitutionDefinition) (map[string][]SetterField, error) {
+	// the setters referenced by each substitution, which may be nested
+	substitutions := map[string][]string{}
+	for _, s := range substs {
+		substitutions[s.Name] = nil
+		for _, v := range s.Values {
+			substitutions[s.Name] = append(substitutions[s.Name], strings.TrimPrefix(v.Ref, fieldmeta.DefinitionsPrefix))
+		}
+	}
+	var resolve func(ref string, visited map[string]bool) []string
+	resolve = func(ref string, visited map[string]bool) []string {
+		if strings.HasPrefix(ref, fieldmeta.SetterDefinitionPrefix) {
+			return []string{strings.TrimPrefix(ref, fieldmeta.SetterDefinitionPrefix)}
+		}
+		name := strings.TrimPrefix(ref, fieldmeta.SubstitutionDefinitionPrefix)
+		if name == ref || visited[name] {
+			return nil
+		}
+		visited[name] = true
+		var names []string
+		for _, r := range substitutions[name] {
+			names = append(names, resolve(r, visited)...)
+		}
+		return names
+	}
+	captures := map[string][]string{}
+	defs, err := captureDefinitions(filepath.Join(path, kptfile.KptFileName))
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range defs {
+		for _, v := range d.Values {
+			captures[d.Name] = append(captures[d.Name], resolve(strings.TrimPrefix(v.Ref, fieldmeta.DefinitionsPrefix), map[string]bool{})...)
+		}
+	}
+	starlarkDefs, err := starlarkDefinitions(filepath.Join(path, kptfile.KptFileName))
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range starlarkDefs {
+		captures[d.Name] = append(captures[d.Name], d.Setters...)
+	}
+
+	// the setters referenced by a field comment
+	references := func(n *yaml.RNode) []string {
+		for _, comment := range []string{n.YNode().LineComment, n.YNode().HeadComment} {
+			if name, ok := captureReference(comment); ok {
+				return captures[name]
+			}
+		}
+		fm := fieldmeta.FieldMeta{SettersSchema: sc}
+		if err := fm.Read(n); err != nil || fm.Schema.Ref.String() == "" {
+			return nil
+		}
+		return resolve(strings.TrimPrefix(fm.Schema.Ref.String(), fieldmeta.DefinitionsPrefix), map[string]bool{})
+	}
+
+	nodes, err := (&kio.LocalPackageReader{PackagePath: path, PackageFileName: kptfile.KptFileName}).Read()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	fields := map[string][]SetterField{}
+	for _, n := range nodes {
+		file, _, err := kioutil.GetFileAnnotations(n)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		meta, err := n.GetMeta()
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		resource := meta.Kind + "/" + meta.Name
+		add := func(names []string, p string) {
+			seen := map[string]bool{}
+			for _, name := range names {
+				if !seen[name] {
+					seen[name] = true
+					fields[name] = append(fields[name], SetterField{File: file, Resource: resource, Path: p})
+				}
+			}
+		}
+		var walk func(*yaml.RNode, string) error
+		walk = func(n *yaml.RNode, p string) error {
+			switch n.YNode().Kind {
+			case yaml.MappingNode:
+				return n.VisitFields(func(f *yaml.MapNode) error {
+					fp := f.Key.YNode().Value
+					if p != "" {
+						fp = p + "." + fp
+					}
+					// the references of sequences are on their keys
+					if f.Value.YNode().Kind == yaml.SequenceNode {
+						add(references(f.Key), fp)
+					}
+					return walk(f.Value, fp)
+				})
+			case yaml.SequenceNode:
+				for i, e := range n.Content() {
+					if err := walk(yaml.NewRNode(e), fmt.Sprintf("%s[%d]", p, i)); err != nil {
+						return err
+					}
+				}
+			case yaml.ScalarNode:
+				add(references(n), p)
+			}
+			return nil
+		}
+		if err := walk(n, ""); err != nil {
+			return nil, err
+		}
+	}
+	return fields, nil
+}
+
+// constraints summarizes the constraints of the setter definition s.
+func constraints(s *spec.Schema) []string {
+	var c []string
+	if s.Minimum != nil {
+		c = append(c, bound("minimum", *s.Minimum, s.ExclusiveMinimum))
+	}
+	if s.Maximum != nil {
+		c = append(c, bound("maximum", *s.Maximum, s.ExclusiveMaximum))
+	}
+	if len(s.Enum) > 0 {
+		var values []string
+		for _, e := range s.Enum {
+			values = append(values, fmt.Sprint(e))
+		}
+		c = append(c, fmt.Sprintf("enum [%s]", strings.Join(values, ", ")))
+	}
+	if s.Pattern != "" {
+		c = append(c, "pattern "+s.Pattern)
+	}
+	if s.Format != "" {
+		c = append(c, "format "+s.Format)
+	}
+	if s.MinLength != nil {
+		c = append(c, "minLength "+strconv.FormatInt(*s.MinLength, 10))
+	}
+	if s.MaxLength != nil {
+		c = append(c, "maxLength "+strconv.FormatInt(*s.MaxLength, 10))
+	}
+	if s.MinItems != nil {
+		c = append(c, "minItems "+strconv.FormatInt(*s.MinItems, 10))
+	}
+	if s.MaxItems != nil {
+		c = append(c, "maxItems "+strconv.FormatInt(*s.MaxItems, 10))
+	}
+	if s.UniqueItems {
+		c = append(c, "uniqueItems")
+	}
+	return c
+}
+
+// Formats of machine-readable setter listings.
+const (
+	JSONOutput = "json"
+	YAMLOutput = "yaml"
+)
+
+// WriteTable writes the setters of the package at root, and if recurse is
+// true of its subpackages, to w as tables in the format of the kustomize
+// list-setters command, with the values of secret setters redacted.  If
+// name isn't empty only the setters with that name are written.  If
+// includeSubst is true the substitutions of each package are written after
+// its setters.
+func WriteTable(w io.Writer, root, name string, recurse, markdown, includeSubst bool) error {
+	paths := []string{root}
+	if recurse {
+		var err error
+		if paths, err = pathutil.DirsWithFile(root, kptfile.KptFileName, true); err != nil {
+			return err
+		}
+	}
+	for i, p := range paths {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "%s/\n", p)
+		infos, err := listSetters(p, name)
+		if err != nil {
+			return errors.Wrapf(err, "package %q", p)
+		}
+		table := newTable(w, markdown)
+		table.SetHeader([]string{"NAME", "VALUE", "SET BY", "DESCRIPTION", "COUNT", "REQUIRED", "IS SET"})
+		for _, s := range infos {
+			v := s.Value
+			if len(s.ListValues) > 0 {
+				v = "[" + strings.Join(s.ListValues, ",") + "]"
+			}
+			table.Append([]string{s.Name, v, s.SetBy, s.Description,
+				strconv.Itoa(s.Count), yesNo(s.Required), yesNo(s.IsSet)})
+		}
+		table.Render()
+		if includeSubst {
+			if err := writeSubstitutions(w, p, markdown); err != nil {
+				return errors.Wrapf(err, "package %q", p)
+			}
+		}
+	}
+	return nil
+}
+
+// writeSubstitutions writes the substitutions of the package at path to w
+// as a table, if it has any.
+func writeSubstitutions(w io.Writer, path string, markdown bool) error {
+	kf := filepath.Join(path, kptfile.KptFileName)
+	sc, err := openapi.SchemaFromFile(kf)
+	if err != nil {
+		return err
+	}
+	l := setters2.List{OpenAPIFileName: kptfile.KptFileName, SettersSchema: sc}
+	if err := l.ListSubst(kf); err != nil {
+		return err
+	}
+	if len(l.Substitutions) == 0 {
+		return nil
+	}
+	table := newTable(w, markdown)
+	table.SetBorders(tablewriter.Border{Top: true})
+	table.SetHeader([]string{"SUBSTITUTION", "PATTERN", "REFERENCES"})
+	for _, s := range l.Substitutions {
+		var refs []string
+		for _, v := range s.Values {
+			refs = append(refs, strings.TrimPrefix(strings.TrimPrefix(v.Ref,
+				fieldmeta.DefinitionsPrefix+fieldmeta.SetterDefinitionPrefix),
+				fieldmeta.DefinitionsPrefix+fieldmeta.SubstitutionDefinitionPrefix))
+		}
+		table.Append([]string{s.Name, s.Pattern, "[" + strings.Join(refs, ",") + "]"})
+	}
+	table.Render()
+	return nil
+}
+
+// newTable returns a table writing to w, as github markdown if markdown is
+// true.
+func newTable(w io.Writer, markdown bool) *tablewriter.Table {
+	table := tablewriter.NewWriter(w)
+	table.SetRowLine(false)
+	if markdown {
+		table.SetBorders(tablewriter.Border{Left: true, Right: true})
+		table.SetCenterSeparator("|")
+	} else {
+		table.SetBorder(false)
+		table.SetHeaderLine(false)
+		table.SetColumnSeparator(" ")
+		table.SetCenterSeparator(" ")
+	}
+	table.SetAlignment(tablewriter.ALIGN_LEFT)
+	return table
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "Yes"
+	}
+	return "No"
+}
+
+// WriteSetters writes the setters to w in format, JSONOutput or YAMLOutput.
+func WriteSetters(w io.Writer, format string, setters []SetterInfo) error {
+	switch format {
+	case JSONOutput:
+		e := json.NewEncoder(w)
+		e.SetIndent("", "  ")
+		return errors.WithStack(e.Encode(setters))
+	case YAMLOutput:
+		b, err := yaml.Marshal(setters)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		_, err = w.Write(b)
+		return errors.WithStack(err)
+	default:
+		return errors.Errorf("unsupported output format %q, must be %s or %s", format, JSONOutput, YAMLOutput)
+	}
+}
@@ -0,0 +1,175 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package setters
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt/internal/testutil/pkgbuilder"
+	"github.com/stretchr/testify/assert"
+)
+
+const listKptfile = `apiVersion: kpt.dev/v1alpha1
+kind: Kptfile
+metadata:
+  name: list
+openAPI:
+  definitions:
+    io.k8s.cli.setters.replicas:
+      type: integer
+      minimum: 1
+      maximum: 10
+      description: number of replicas
+      x-k8s-cli:
+        setter:
+          name: replicas
+          value: "3"
+          setBy: me
+          isSet: true
+    io.k8s.cli.setters.tag:
+      x-k8s-cli:
+        setter:
+          name: tag
+          value: "1.7"
+          required: true
+    io.k8s.cli.setters.args:
+      type: array
+      x-k8s-cli:
+        setter:
+          name: args
+          listValues: [a, b]
+    io.k8s.cli.substitutions.image:
+      x-k8s-cli:
+        substitution:
+          name: image
+          pattern: nginx:${tag}
+          values:
+          - marker: ${tag}
+            ref: '#/definitions/io.k8s.cli.setters.tag'
+`
+
+const listDeploy = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  replicas: 3 # {"$ref":"#/definitions/io.k8s.cli.setters.replicas"}
+  template:
+    spec:
+      containers:
+      - name: nginx
+        image: nginx:1.7 # {"$ref":"#/definitions/io.k8s.cli.substitutions.image"}
+        args: # {"$ref":"#/definitions/io.k8s.cli.setters.args"}
+        - a
+        - b
+`
+
+func TestListSetters(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir)
+	sub := filepath.Join(dir, "sub")
+	if !assert.NoError(t, os.MkdirAll(sub, 0700)) {
+		t.FailNow()
+	}
+	for _, p := range []string{dir, sub} {
+		if !assert.NoError(t, ioutil.WriteFile(filepath.Join(p, "Kptfile"), []byte(listKptfile), 0600)) {
+			t.FailNow()
+		}
+	}
+	if !assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "deploy.yaml"), []byte(listDeploy), 0600)) {
+		t.FailNow()
+	}
+
+	infos, err := ListSetters(dir, "", false)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	deployment := func(path string) []SetterField {
+		return []SetterField{{File: "deploy.yaml", Resource: "Deployment/web", Path: path}}
+	}
+	assert.Equal(t, []SetterInfo{
+		{Package: dir, Name: "args", Type: "array", ListValues: []string{"a", "b"},
+			Count: 1, Fields: deployment("spec.template.spec.containers[0].args")},
+		{Package: dir, Name: "replicas", Type: "integer", Value: "3",
+			Description: "number of replicas", SetBy: "me", IsSet: true,
+			Constraints: []string{"minimum 1", "maximum 10"},
+			Count:       1, Fields: deployment("spec.replicas")},
+		{Package: dir, Name: "tag", Value: "1.7", Required: true,
+			Count: 1, Fields: deployment("spec.template.spec.containers[0].image")},
+	}, infos)
+
+	// the fields of a setter referenced through a substitution are listed
+	// when only the setter is
+	infos, err = ListSetters(dir, "tag", true)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	if assert.Len(t, infos, 2) {
+		assert.Equal(t, deployment("spec.template.spec.containers[0].image"), infos[0].Fields)
+		assert.Equal(t, sub, infos[1].Package)
+		assert.Equal(t, 0, infos[1].Count)
+	}
+}
+
+func TestListSetters_captures(t *testing.T) {
+	dir := pkgbuilder.WritePackage(t, capturesPackage(capturesKptfile))
+	defer os.RemoveAll(dir)
+
+	infos, err := ListSetters(dir, "tag", false)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	if assert.Len(t, infos, 1) {
+		assert.Equal(t, 3, infos[0].Count)
+		assert.Equal(t, SetterField{File: "deploy.yaml", Resource: "Deployment/app",
+			Path: "metadata.annotations.images"}, infos[0].Fields[0])
+	}
+}
+
+func TestWriteSetters(t *testing.T) {
+	infos := []SetterInfo{{Package: ".", Name: "replicas", Value: "3", Count: 1,
+		Fields: []SetterField{{File: "deploy.yaml", Resource: "Deployment/web", Path: "spec.replicas"}}}}
+
+	out := &bytes.Buffer{}
+	if !assert.NoError(t, WriteSetters(out, "yaml", infos)) {
+		t.FailNow()
+	}
+	assert.Equal(t, `- package: .
+  name: replicas
+  value: "3"
+  required: false
+  isSet: false
+  count: 1
+  fields:
+    - file: deploy.yaml
+      resource: Deployment/web
+      path: spec.replicas
+`, out.String())
+
+	out.Reset()
+	if !assert.NoError(t, WriteSetters(out, "json", []SetterInfo{})) {
+		t.FailNow()
+	}
+	assert.Equal(t, "[]\n", out.String())
+
+	assert.EqualError(t, WriteSetters(out, "xml", infos), `unsupported output format "xml", must be json or yaml`)
+}
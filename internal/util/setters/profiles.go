@@ -0,0 +1,146 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package setters
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/GoogleContainerTools/kpt/pkg/kptfile"
+	"github.com/GoogleContainerTools/kpt/pkg/kptfile/kptfileutil"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// CurrentProfile names the current setter values of a package when they
+// are diffed with a profile.
+const CurrentProfile = "current"
+
+// ReadProfile reads the setter values of the profile name of the package
+// at dir.
+func ReadProfile(dir, name string) (map[string][]string, error) {
+	k, err := kptfileutil.ReadFile(dir)
+	if err != nil {
+		return nil, err
+	}
+	p, found := k.Profiles[name]
+	if !found {
+		var names []string
+		for n := range k.Profiles {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		if len(names) == 0 {
+			return nil, errors.Errorf("package %q has no profiles", dir)
+		}
+		return nil, errors.Errorf("package %q has no profile %q, its profiles are: %s",
+			dir, name, strings.Join(names, ", "))
+	}
+	return ProfileValues(name, p)
+}
+
+// ProfileValues returns the setter values of the profile p, named name.
+func ProfileValues(name string, p kptfile.Profile) (map[string][]string, error) {
+	n := yaml.NewMapRNode(nil)
+	var names []string
+	for setter := range p {
+		names = append(names, setter)
+	}
+	sort.Strings(names)
+	for _, setter := range names {
+		v := p[setter]
+		if err := n.PipeE(yaml.SetField(setter, yaml.NewRNode(&v))); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+	return parseValues(n, fmt.Sprintf("profile %q", name))
+}
+
+// CurrentValues returns the current values of the setters of the package
+// at dir.
+func CurrentValues(dir string) (map[string][]string, error) {
+	defs, err := setterDefinitions(filepath.Join(dir, kptfile.KptFileName))
+	if err != nil {
+		return nil, err
+	}
+	values := map[string][]string{}
+	for _, d := range defs {
+		if len(d.ListValues) > 0 {
+			values[d.Name] = d.ListValues
+		} else {
+			values[d.Name] = []string{d.Value}
+		}
+	}
+	return values, nil
+}
+
+// ValuesDiff is a setter whose values differ between two sets of values.
+// From and To are nil if the setter has no value in the set.
+type ValuesDiff struct {
+	Name string
+	From []string
+	To   []string
+}
+
+// DiffValues returns the setters whose values differ between from and to,
+// sorted by name.
+func DiffValues(from, to map[string][]string) []ValuesDiff {
+	names := map[string]bool{}
+	for name := range from {
+		names[name] = true
+	}
+	for name := range to {
+		names[name] = true
+	}
+	var diffs []ValuesDiff
+	for name := range names {
+		f, t := from[name], to[name]
+		if reflect.DeepEqual(f, t) {
+			continue
+		}
+		diffs = append(diffs, ValuesDiff{Name: name, From: f, To: t})
+	}
+	sort.Slice(diffs, func(i, j int) bool { return diffs[i].Name < diffs[j].Name })
+	return diffs
+}
+
+// WriteValuesDiff writes a table of the diffs between the profiles from
+// and to to w.
+func WriteValuesDiff(w io.Writer, from, to string, diffs []ValuesDiff) error {
+	if len(diffs) == 0 {
+		fmt.Fprintf(w, "%s and %s set the same values\n", from, to)
+		return nil
+	}
+	display := func(values []string) string {
+		switch len(values) {
+		case 0:
+			return "<none>"
+		case 1:
+			return values[0]
+		}
+		return "[" + strings.Join(values, ",") + "]"
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "SETTER\t%s\t%s\n", strings.ToUpper(from), strings.ToUpper(to))
+	for _, d := range diffs {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", d.Name, display(d.From), display(d.To))
+	}
+	return errors.WithStack(tw.Flush())
+}
@@ -0,0 +1,108 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package setters
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const profilesKptfile = `apiVersion: kpt.dev/v1alpha1
+kind: Kptfile
+metadata:
+  name: app
+openAPI:
+  definitions:
+    io.k8s.cli.setters.replicas:
+      x-k8s-cli:
+        setter:
+          name: replicas
+          value: "3"
+    io.k8s.cli.setters.env:
+      x-k8s-cli:
+        setter:
+          name: env
+          value: dev
+profiles:
+  staging:
+    replicas: 2
+    env: staging
+  prod:
+    replicas: 5
+    env: prod
+    ports: [80, 443]
+`
+
+func TestReadProfile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir)
+	if !assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "Kptfile"), []byte(profilesKptfile), 0600)) {
+		t.FailNow()
+	}
+
+	values, err := ReadProfile(dir, "prod")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, map[string][]string{
+		"replicas": {"5"},
+		"env":      {"prod"},
+		"ports":    {"80", "443"},
+	}, values)
+
+	_, err = ReadProfile(dir, "dev")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), `has no profile "dev", its profiles are: prod, staging`)
+	}
+
+	current, err := CurrentValues(dir)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, map[string][]string{
+		"replicas": {"3"},
+		"env":      {"dev"},
+	}, current)
+}
+
+func TestWriteValuesDiff(t *testing.T) {
+	staging := map[string][]string{"replicas": {"2"}, "env": {"staging"}, "debug": {"true"}}
+	prod := map[string][]string{"replicas": {"5"}, "env": {"prod"}, "debug": {"true"},
+		"ports": {"80", "443"}}
+
+	out := &bytes.Buffer{}
+	if !assert.NoError(t, WriteValuesDiff(out, "staging", "prod", DiffValues(staging, prod))) {
+		t.FailNow()
+	}
+	assert.Equal(t, `SETTER    STAGING  PROD
+env       staging  prod
+ports     <none>   [80,443]
+replicas  2        5
+`, out.String())
+
+	out.Reset()
+	if !assert.NoError(t, WriteValuesDiff(out, "prod", "prod", DiffValues(prod, prod))) {
+		t.FailNow()
+	}
+	assert.Equal(t, "prod and prod set the same values\n", out.String())
+}
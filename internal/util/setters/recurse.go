@@ -0,0 +1,137 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package setters
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/GoogleContainerTools/kpt/pkg/kptfile"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/kustomize/kyaml/pathutil"
+	"sigs.k8s.io/kustomize/kyaml/setters2/settersutil"
+)
+
+// SetResult is the result of setting a setter in one package.
+type SetResult struct {
+	// Package is the path of the package, relative to the root package
+	Package string
+
+	// Count is the number of fields set
+	Count int
+
+	// Skipped is true if the package doesn't define the setter
+	Skipped bool
+
+	// Err is the error setting the setter, if any
+	Err error
+}
+
+// RecursiveSet sets a setter in a package and every nested package which
+// defines it.
+type RecursiveSet struct {
+	// Name, Value and ListValues are the setter and its value
+	Name       string
+	Value      string
+	ListValues []string
+
+	// SetBy and Description are recorded on the setter definitions
+	SetBy       string
+	Description string
+}
+
+// Set sets the setter in the package at root and its subpackages, parent
+// packages first.  A package failing doesn't stop the others being set;
+// the results of every package are returned, with an error if any failed.
+func (s RecursiveSet) Set(root string) ([]SetResult, error) {
+	paths, err := pathutil.DirsWithFile(root, kptfile.KptFileName, true)
+	if err != nil {
+		return nil, err
+	}
+	var packages []string
+	for _, p := range paths {
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		packages = append(packages, filepath.ToSlash(rel))
+	}
+	sort.Strings(packages)
+
+	var results []SetResult
+	failed := 0
+	for _, pkg := range packages {
+		path := filepath.Join(root, pkg)
+		r := SetResult{Package: pkg}
+		if !DefExists(path, s.Name) {
+			r.Skipped = true
+			results = append(results, r)
+			continue
+		}
+		r.Count, r.Err = setValidated(&settersutil.FieldSetter{
+			Name:            s.Name,
+			Value:           s.Value,
+			ListValues:      s.ListValues,
+			Description:     s.Description,
+			SetBy:           s.SetBy,
+			OpenAPIPath:     filepath.Join(path, kptfile.KptFileName),
+			OpenAPIFileName: kptfile.KptFileName,
+			ResourcesPath:   path,
+			IsSet:           true,
+		})
+		if r.Err != nil {
+			failed++
+		}
+		results = append(results, r)
+	}
+	if failed > 0 {
+		return results, errors.Errorf("failed to set %q in %d package(s)", s.Name, failed)
+	}
+	return results, nil
+}
+
+// WriteSetResults writes a table of the results of setting the setter in
+// each package to w, followed by a summary.
+func WriteSetResults(w io.Writer, s RecursiveSet, results []SetResult) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "PACKAGE\tFIELDS\tRESULT")
+	set, fields := 0, 0
+	for _, r := range results {
+		result := "set"
+		switch {
+		case r.Skipped:
+			result = "skipped, setter not defined"
+		case r.Err != nil:
+			result = "failed: " + r.Err.Error()
+		default:
+			set++
+			fields += r.Count
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%s\n", r.Package, r.Count, result)
+	}
+	if err := tw.Flush(); err != nil {
+		return errors.WithStack(err)
+	}
+	value := s.Value
+	if len(s.ListValues) > 0 {
+		value = fmt.Sprint(append([]string{s.Value}, s.ListValues...))
+	}
+	fmt.Fprintf(w, "set %d field(s) of setter %q to value %q in %d of %d package(s)\n",
+		fields, s.Name, value, set, len(results))
+	return nil
+}
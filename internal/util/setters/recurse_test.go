@@ -0,0 +1,90 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package setters
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecursiveSet_Set(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir)
+
+	deploy := `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+spec:
+  replicas: 3 # {"$ref":"#/definitions/io.k8s.cli.setters.replicas"}
+`
+	undefined := `apiVersion: kpt.dev/v1alpha1
+kind: Kptfile
+metadata:
+  name: undefined
+`
+	for pkg, k := range map[string]string{".": typedKptfile, "a": typedKptfile, "a/b": typedKptfile, "c": undefined} {
+		path := filepath.Join(dir, pkg)
+		if !assert.NoError(t, os.MkdirAll(path, 0700)) {
+			t.FailNow()
+		}
+		if !assert.NoError(t, ioutil.WriteFile(filepath.Join(path, "Kptfile"), []byte(k), 0600)) {
+			t.FailNow()
+		}
+		if !assert.NoError(t, ioutil.WriteFile(filepath.Join(path, "deploy.yaml"), []byte(deploy), 0600)) {
+			t.FailNow()
+		}
+	}
+
+	s := RecursiveSet{Name: "replicas", Value: "5", SetBy: "team"}
+	results, err := s.Set(dir)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, []SetResult{
+		{Package: ".", Count: 1},
+		{Package: "a", Count: 1},
+		{Package: "a/b", Count: 1},
+		{Package: "c", Skipped: true},
+	}, results)
+
+	for pkg, replicas := range map[string]string{".": "5", "a": "5", "a/b": "5", "c": "3"} {
+		b, err := ioutil.ReadFile(filepath.Join(dir, pkg, "deploy.yaml"))
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		assert.Contains(t, string(b), "replicas: "+replicas+" ", pkg)
+	}
+
+	out := &bytes.Buffer{}
+	if !assert.NoError(t, WriteSetResults(out, s, results)) {
+		t.FailNow()
+	}
+	assert.Equal(t, `PACKAGE  FIELDS  RESULT
+.        1       set
+a        1       set
+a/b      1       set
+c        0       skipped, setter not defined
+set 3 field(s) of setter "replicas" to value "5" in 3 of 4 package(s)
+`, out.String())
+}
@@ -0,0 +1,280 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package setters
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+
+	"github.com/GoogleContainerTools/kpt/pkg/kptfile"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/kustomize/kyaml/fieldmeta"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/kio/kioutil"
+	"sigs.k8s.io/kustomize/kyaml/setters2"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// RenameResult is the result of renaming a setter in a package.
+type RenameResult struct {
+	// Package is the path of the package
+	Package string
+
+	// Fields is the number of field references renamed
+	Fields int
+}
+
+// RenameSetter renames the setter old to new in the package at root and,
+// if recurse is true, the subpackages which define it.  The definition,
+// the substitutions and capture substitutions referencing the setter, and
+// the field references to it are renamed.  No package is changed if any
+// package already defines a setter or substitution named new.
+func RenameSetter(root, old, new string, recurse bool) ([]RenameResult, error) {
+	if old == new {
+		return nil, errors.Errorf("setter %q can't be renamed to itself", old)
+	}
+	paths, err := packages(root, recurse)
+	if err != nil {
+		return nil, err
+	}
+	var renamed []string
+	for _, p := range paths {
+		if !DefExists(p, old) {
+			continue
+		}
+		for _, d := range []struct{ kind, prefix string }{
+			{"setter", fieldmeta.SetterDefinitionPrefix},
+			{"substitution", fieldmeta.SubstitutionDefinitionPrefix},
+		} {
+			found, err := definitionExists(p, d.prefix+new)
+			if err != nil {
+				return nil, err
+			}
+			if found {
+				return nil, errors.Errorf("package %q already defines %s %q", p, d.kind, new)
+			}
+		}
+		renamed = append(renamed, p)
+	}
+	if len(renamed) == 0 {
+		return nil, errors.Errorf("setter %q is not defined", old)
+	}
+
+	var results []RenameResult
+	for _, p := range renamed {
+		if err := renameDefinitions(filepath.Join(p, kptfile.KptFileName), old, new); err != nil {
+			return results, errors.Wrapf(err, "package %q", p)
+		}
+		n, err := renameReferences(p, old, new)
+		if err != nil {
+			return results, errors.Wrapf(err, "package %q", p)
+		}
+		results = append(results, RenameResult{Package: p, Fields: n})
+	}
+	return results, nil
+}
+
+// definitionExists returns true if the Kptfile of the package at path has
+// a definition with key.
+func definitionExists(path, key string) (bool, error) {
+	kf, err := yaml.ReadFile(filepath.Join(path, kptfile.KptFileName))
+	if err != nil {
+		return false, errors.WithStack(err)
+	}
+	def, err := kf.Pipe(yaml.Lookup("openAPI", "definitions", key))
+	return def != nil, errors.WithStack(err)
+}
+
+// renameDefinitions renames the setter old to new in the definitions of
+// the Kptfile at path.
+func renameDefinitions(path, old, new string) error {
+	kf, err := yaml.ReadFile(path)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	defs, err := kf.Pipe(yaml.Lookup("openAPI", "definitions"))
+	if err != nil || defs == nil {
+		return errors.WithStack(err)
+	}
+	oldRef := fieldmeta.DefinitionsPrefix + fieldmeta.SetterDefinitionPrefix + old
+	newRef := fieldmeta.DefinitionsPrefix + fieldmeta.SetterDefinitionPrefix + new
+	err = defs.VisitFields(func(node *yaml.MapNode) error {
+		key := node.Key.YNode().Value
+		switch {
+		case key == fieldmeta.SetterDefinitionPrefix+old:
+			node.Key.YNode().Value = fieldmeta.SetterDefinitionPrefix + new
+			return node.Value.PipeE(
+				yaml.Lookup(setters2.K8sCliExtensionKey, "setter"),
+				yaml.SetField("name", yaml.NewScalarRNode(new)))
+		case strings.HasPrefix(key, fieldmeta.SubstitutionDefinitionPrefix):
+			subst, err := node.Value.Pipe(yaml.Lookup(setters2.K8sCliExtensionKey, "substitution"))
+			if err != nil || subst == nil {
+				return err
+			}
+			return renameValues(subst, "marker", oldRef, newRef, old, new)
+		case strings.HasPrefix(key, CaptureDefinitionPrefix):
+			if err := renameStarlark(node.Value, old, new); err != nil {
+				return err
+			}
+			subst, err := node.Value.Pipe(yaml.Lookup("x-kpt", "substitution"))
+			if err != nil || subst == nil {
+				return err
+			}
+			return renameValues(subst, "", oldRef, newRef, old, new)
+		}
+		return nil
+	})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return errors.WithStack(yaml.WriteFile(kf, path))
+}
+
+// renameStarlark renames the setter in the setters read by the Starlark
+// substitution defined by def, if any, and in the lookups of its
+// expression, e.g. setters["old"].
+func renameStarlark(def *yaml.RNode, old, new string) error {
+	subst, err := def.Pipe(yaml.Lookup("x-kpt", "starlark"))
+	if err != nil || subst == nil {
+		return err
+	}
+	names, err := subst.Pipe(yaml.Lookup("setters"))
+	if err != nil || names == nil {
+		return err
+	}
+	renamed := false
+	for _, n := range names.Content() {
+		if n.Value == old {
+			n.Value, renamed = new, true
+		}
+	}
+	if expr := subst.Field("expression"); renamed && expr != nil {
+		expr.Value.YNode().Value = strings.NewReplacer(
+			`setters["`+old+`"]`, `setters["`+new+`"]`,
+			`setters['`+old+`']`, `setters['`+new+`']`,
+		).Replace(expr.Value.YNode().Value)
+	}
+	return nil
+}
+
+// renameValues renames the references to the setter in the values of the
+// substitution subst, and for substitutions with markers the markers in
+// its pattern.
+func renameValues(subst *yaml.RNode, markerField, oldRef, newRef, old, new string) error {
+	values, err := subst.Pipe(yaml.Lookup("values"))
+	if err != nil || values == nil {
+		return err
+	}
+	renamed := false
+	err = values.VisitElements(func(v *yaml.RNode) error {
+		ref := v.Field("ref")
+		if ref == nil || ref.Value.YNode().Value != oldRef {
+			return nil
+		}
+		renamed = true
+		ref.Value.YNode().Value = newRef
+		if markerField == "" {
+			return nil
+		}
+		if marker := v.Field(markerField); marker != nil && marker.Value.YNode().Value == "${"+old+"}" {
+			marker.Value.YNode().Value = "${" + new + "}"
+		}
+		return nil
+	})
+	if err != nil || !renamed || markerField == "" {
+		return err
+	}
+	if pattern := subst.Field("pattern"); pattern != nil {
+		pattern.Value.YNode().Value = strings.ReplaceAll(pattern.Value.YNode().Value, "${"+old+"}", "${"+new+"}")
+	}
+	return nil
+}
+
+// renameReferences renames the field references to the setter old in the
+// resources of the package at path to new, returning the number of fields
+// renamed.
+func renameReferences(path, old, new string) (int, error) {
+	rw := &kio.LocalPackageReadWriter{PackagePath: path, NoDeleteFiles: true, PackageFileName: kptfile.KptFileName}
+	nodes, err := rw.Read()
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	count := 0
+	changed := map[string]bool{}
+	for _, n := range nodes {
+		file, _, err := kioutil.GetFileAnnotations(n)
+		if err != nil {
+			return 0, errors.WithStack(err)
+		}
+		var walk func(*yaml.Node)
+		walk = func(y *yaml.Node) {
+			renamed := false
+			for _, comment := range []*string{&y.LineComment, &y.HeadComment} {
+				if c, ok := renameReference(*comment, old, new); ok {
+					*comment = c
+					renamed = true
+				}
+			}
+			if renamed {
+				count++
+				changed[file] = true
+			}
+			for _, c := range y.Content {
+				walk(c)
+			}
+		}
+		walk(n.YNode())
+	}
+	if count == 0 {
+		return 0, nil
+	}
+	var out []*yaml.RNode
+	for _, n := range nodes {
+		if file, _, _ := kioutil.GetFileAnnotations(n); changed[file] {
+			out = append(out, n)
+		}
+	}
+	return count, errors.WithStack(rw.Write(out))
+}
+
+// renameReference returns comment with its reference to the setter old
+// renamed to new, and whether it referenced the setter.  References may be
+// by shorthand, e.g. {"$kpt-set":"replicas"}, or by $ref.
+func renameReference(comment, old, new string) (string, bool) {
+	i := strings.Index(comment, "{")
+	if i < 0 {
+		return comment, false
+	}
+	ref := map[string]string{}
+	if err := json.Unmarshal([]byte(comment[i:]), &ref); err != nil || len(ref) != 1 {
+		return comment, false
+	}
+	for key, value := range ref {
+		switch {
+		case key == "$ref" && value == fieldmeta.DefinitionsPrefix+fieldmeta.SetterDefinitionPrefix+old:
+			ref[key] = fieldmeta.DefinitionsPrefix + fieldmeta.SetterDefinitionPrefix + new
+		case key != "$ref" && key != CaptureRef && value == old:
+			ref[key] = new
+		default:
+			return comment, false
+		}
+	}
+	b, err := json.Marshal(ref)
+	if err != nil {
+		return comment, false
+	}
+	return comment[:i] + string(b), true
+}
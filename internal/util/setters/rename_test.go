@@ -0,0 +1,177 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package setters
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt/internal/testutil/pkgbuilder"
+	"github.com/stretchr/testify/assert"
+)
+
+const renameKptfile = `apiVersion: kpt.dev/v1alpha1
+kind: Kptfile
+metadata:
+  name: rename
+openAPI:
+  definitions:
+    io.k8s.cli.setters.tag:
+      x-k8s-cli:
+        setter:
+          name: tag
+          value: "1.7"
+    io.k8s.cli.setters.replicas:
+      x-k8s-cli:
+        setter:
+          name: replicas
+          value: "3"
+    io.k8s.cli.substitutions.image:
+      x-k8s-cli:
+        substitution:
+          name: image
+          pattern: nginx:${tag}
+          values:
+          - marker: ${tag}
+            ref: '#/definitions/io.k8s.cli.setters.tag'
+    io.kpt.substitutions.tags:
+      x-kpt:
+        substitution:
+          name: tags
+          pattern: ':(?P<tag>[0-9.]+)'
+          values:
+          - group: tag
+            ref: '#/definitions/io.k8s.cli.setters.tag'
+`
+
+const renameDeploy = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  annotations:
+    images: a:1.7,b:1.7 # {"$kpt-subst":"tags"}
+spec:
+  replicas: 3 # {"$openapi":"replicas"}
+  template:
+    spec:
+      containers:
+      - name: nginx
+        image: nginx:1.7 # {"$ref":"#/definitions/io.k8s.cli.substitutions.image"}
+        args: # {"$ref":"#/definitions/io.k8s.cli.setters.tag"}
+        - "1.7"
+`
+
+// renamePackage is a package with setters and substitutions to rename.
+var renamePackage = map[string]string{
+	"Kptfile":     renameKptfile,
+	"deploy.yaml": renameDeploy,
+}
+
+func TestRenameSetter(t *testing.T) {
+	dir := pkgbuilder.WritePackage(t, renamePackage)
+	defer os.RemoveAll(dir)
+
+	results, err := RenameSetter(dir, "tag", "version", false)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, []RenameResult{{Package: dir, Fields: 1}}, results)
+
+	kf, err := ioutil.ReadFile(filepath.Join(dir, "Kptfile"))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, `apiVersion: kpt.dev/v1alpha1
+kind: Kptfile
+metadata:
+  name: rename
+openAPI:
+  definitions:
+    io.k8s.cli.setters.version:
+      x-k8s-cli:
+        setter:
+          name: version
+          value: "1.7"
+    io.k8s.cli.setters.replicas:
+      x-k8s-cli:
+        setter:
+          name: replicas
+          value: "3"
+    io.k8s.cli.substitutions.image:
+      x-k8s-cli:
+        substitution:
+          name: image
+          pattern: nginx:${version}
+          values:
+          - marker: ${version}
+            ref: '#/definitions/io.k8s.cli.setters.version'
+    io.kpt.substitutions.tags:
+      x-kpt:
+        substitution:
+          name: tags
+          pattern: ':(?P<tag>[0-9.]+)'
+          values:
+          - group: tag
+            ref: '#/definitions/io.k8s.cli.setters.version'
+`, string(kf))
+
+	results, err = RenameSetter(dir, "replicas", "web-replicas", false)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, []RenameResult{{Package: dir, Fields: 1}}, results)
+
+	deploy, err := ioutil.ReadFile(filepath.Join(dir, "deploy.yaml"))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  annotations:
+    images: a:1.7,b:1.7 # {"$kpt-subst":"tags"}
+spec:
+  replicas: 3 # {"$openapi":"web-replicas"}
+  template:
+    spec:
+      containers:
+      - name: nginx
+        image: nginx:1.7 # {"$ref":"#/definitions/io.k8s.cli.substitutions.image"}
+        args: # {"$ref":"#/definitions/io.k8s.cli.setters.version"}
+        - "1.7"
+`, string(deploy))
+}
+
+func TestRenameSetter_errors(t *testing.T) {
+	dir := pkgbuilder.WritePackage(t, renamePackage)
+	defer os.RemoveAll(dir)
+
+	_, err := RenameSetter(dir, "tag", "image", false)
+	assert.EqualError(t, err, `package "`+dir+`" already defines substitution "image"`)
+	_, err = RenameSetter(dir, "tag", "replicas", false)
+	assert.EqualError(t, err, `package "`+dir+`" already defines setter "replicas"`)
+	_, err = RenameSetter(dir, "undefined", "other", false)
+	assert.EqualError(t, err, `setter "undefined" is not defined`)
+
+	// nothing is renamed
+	kf, err := ioutil.ReadFile(filepath.Join(dir, "Kptfile"))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, renameKptfile, string(kf))
+}
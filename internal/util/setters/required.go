@@ -0,0 +1,169 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package setters
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/GoogleContainerTools/kpt/internal/util/inherit"
+	"github.com/GoogleContainerTools/kpt/pkg/kptfile"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/kustomize/kyaml/fieldmeta"
+	"sigs.k8s.io/kustomize/kyaml/pathutil"
+	"sigs.k8s.io/kustomize/kyaml/setters2"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// UnsetSetter is a required setter which hasn't been set.
+type UnsetSetter struct {
+	// Package is the path of the package defining the setter
+	Package string
+
+	// Name is the name of the setter
+	Name string
+
+	// Value is the current value of the setter
+	Value string
+
+	// Placeholder is true if the setter was set, but to its placeholder
+	Placeholder bool
+}
+
+// requiredSetter is the definition of a required setter.
+type requiredSetter struct {
+	setters2.SetterDefinition
+
+	// Placeholder is the value the package was published with, which must
+	// be replaced before it is deployed
+	Placeholder string
+}
+
+// requiredSetters returns the definitions of the required setters of the
+// package at dir, sorted by name.  Setters inherited from the packages
+// containing it are included.
+func requiredSetters(dir string) ([]requiredSetter, error) {
+	path := filepath.Join(dir, kptfile.KptFileName)
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	k := struct {
+		OpenAPI struct {
+			Definitions map[string]struct {
+				Ext struct {
+					Setter *setters2.SetterDefinition `yaml:"setter"`
+				} `yaml:"x-k8s-cli"`
+				Kpt struct {
+					Placeholder string `yaml:"placeholder"`
+				} `yaml:"x-kpt"`
+			} `yaml:"definitions"`
+		} `yaml:"openAPI"`
+		Inherit *kptfile.Inherit `yaml:"inherit"`
+	}{}
+	if err := yaml.Unmarshal(b, &k); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse %q", path)
+	}
+	if k.Inherit != nil && k.Inherit.Setters {
+		merged, err := inherit.Kptfile(dir)
+		if err != nil {
+			return nil, err
+		}
+		if b, err = yaml.Marshal(merged); err != nil {
+			return nil, errors.WithStack(err)
+		}
+		if err := yaml.Unmarshal(b, &k); err != nil {
+			return nil, errors.WithStack(err)
+		}
+	}
+	var defs []requiredSetter
+	for key, def := range k.OpenAPI.Definitions {
+		if !strings.HasPrefix(key, fieldmeta.SetterDefinitionPrefix) || def.Ext.Setter == nil ||
+			!def.Ext.Setter.Required {
+			continue
+		}
+		defs = append(defs, requiredSetter{SetterDefinition: *def.Ext.Setter, Placeholder: def.Kpt.Placeholder})
+	}
+	sort.Slice(defs, func(i, j int) bool { return defs[i].Name < defs[j].Name })
+	return defs, nil
+}
+
+// UnsetRequiredSetters returns the required setters of the package at root,
+// and its subpackages, which haven't been set or still hold their
+// placeholder values.
+func UnsetRequiredSetters(root string) ([]UnsetSetter, error) {
+	if info, err := os.Stat(root); err != nil || !info.IsDir() {
+		// resources may be read from stdin rather than a package
+		return nil, nil
+	}
+	paths, err := pathutil.DirsWithFile(root, kptfile.KptFileName, true)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+	var unset []UnsetSetter
+	for _, p := range paths {
+		defs, err := requiredSetters(p)
+		if err != nil {
+			return nil, err
+		}
+		for _, d := range defs {
+			value, _ := splitListValues(d.Value, d.ListValues)
+			placeholder := d.Placeholder != "" && value == d.Placeholder
+			if d.IsSet && value != "" && !placeholder {
+				continue
+			}
+			unset = append(unset, UnsetSetter{Package: p, Name: d.Name, Value: value, Placeholder: placeholder})
+		}
+	}
+	return unset, nil
+}
+
+// CheckForRequiredSetters returns an error listing the required setters of
+// the package at path, and its subpackages, which haven't been set or still
+// hold their placeholder values, and how to set them.  Setters injected
+// with values, e.g. from --set flags, aren't required to be set.
+func CheckForRequiredSetters(path string, values map[string]string) error {
+	all, err := UnsetRequiredSetters(path)
+	if err != nil {
+		return err
+	}
+	var unset []UnsetSetter
+	for _, s := range all {
+		if _, found := values[s.Name]; !found {
+			unset = append(unset, s)
+		}
+	}
+	if len(unset) == 0 {
+		return nil
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d required setter(s) are not set, set them and try again:\n", len(unset))
+	tw := tabwriter.NewWriter(&b, 0, 4, 2, ' ', 0)
+	for _, s := range unset {
+		reason := fmt.Sprintf("setter %s is required but not set", s.Name)
+		if s.Placeholder {
+			reason = fmt.Sprintf("setter %s still holds its placeholder %q", s.Name, s.Value)
+		}
+		fmt.Fprintf(tw, "  kpt cfg set %s %s VALUE\t# %s\n", s.Package, s.Name, reason)
+	}
+	_ = tw.Flush()
+	return errors.New(strings.TrimSuffix(b.String(), "\n"))
+}
@@ -0,0 +1,99 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package setters
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnsetRequiredSetters(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir)
+	for pkg, k := range map[string]string{
+		".": `apiVersion: kpt.dev/v1alpha1
+kind: Kptfile
+metadata:
+  name: root
+openAPI:
+  definitions:
+    io.k8s.cli.setters.project:
+      x-kpt:
+        placeholder: PROJECT_ID_PLACEHOLDER
+      x-k8s-cli:
+        setter:
+          name: project
+          value: PROJECT_ID_PLACEHOLDER
+          required: true
+          isSet: true
+    io.k8s.cli.setters.replicas:
+      x-k8s-cli:
+        setter:
+          name: replicas
+          value: "3"
+          required: true
+          isSet: true
+    io.k8s.cli.setters.tag:
+      x-k8s-cli:
+        setter:
+          name: tag
+          value: "1.0"
+`,
+		"db": `apiVersion: kpt.dev/v1alpha1
+kind: Kptfile
+metadata:
+  name: db
+openAPI:
+  definitions:
+    io.k8s.cli.setters.region:
+      x-k8s-cli:
+        setter:
+          name: region
+          value: ""
+          required: true
+`,
+	} {
+		path := filepath.Join(dir, pkg)
+		if !assert.NoError(t, os.MkdirAll(path, 0700)) {
+			t.FailNow()
+		}
+		if !assert.NoError(t, ioutil.WriteFile(filepath.Join(path, "Kptfile"), []byte(k), 0600)) {
+			t.FailNow()
+		}
+	}
+
+	unset, err := UnsetRequiredSetters(dir)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, []UnsetSetter{
+		{Package: dir, Name: "project", Value: "PROJECT_ID_PLACEHOLDER", Placeholder: true},
+		{Package: filepath.Join(dir, "db"), Name: "region"},
+	}, unset)
+
+	err = CheckForRequiredSetters(dir, nil)
+	if assert.Error(t, err) {
+		assert.Equal(t, `2 required setter(s) are not set, set them and try again:
+  kpt cfg set `+dir+` project VALUE    # setter project still holds its placeholder "PROJECT_ID_PLACEHOLDER"
+  kpt cfg set `+filepath.Join(dir, "db")+` region VALUE  # setter region is required but not set`, err.Error())
+	}
+}
@@ -0,0 +1,363 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package setters
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/GoogleContainerTools/kpt/pkg/kptfile"
+	"github.com/go-openapi/spec"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/kustomize/kyaml/fieldmeta"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/kio/kioutil"
+	"sigs.k8s.io/kustomize/kyaml/openapi"
+	"sigs.k8s.io/kustomize/kyaml/pathutil"
+	"sigs.k8s.io/kustomize/kyaml/setters2"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// ScopedOverride is a value of a setter scoped to the resources of a file,
+// or to a single resource.  Setting the setter without a scope doesn't
+// change the fields of the resources in the scope of an override.
+// Overrides are recorded by the setter definition, e.g.
+//
+//	io.k8s.cli.setters.replicas:
+//	  x-k8s-cli:
+//	    setter:
+//	      name: replicas
+//	      value: "3"
+//	  x-kpt:
+//	    overrides:
+//	    - file: canary.yaml
+//	      value: "1"
+//	    - resource: Deployment/web
+//	      value: "5"
+//
+// Resource overrides take precedence over file overrides.
+type ScopedOverride struct {
+	// File is the path of the file, relative to the package
+	File string `json:"file,omitempty" yaml:"file,omitempty"`
+
+	// Resource is the kind and name of the resource, e.g. Deployment/web
+	Resource string `json:"resource,omitempty" yaml:"resource,omitempty"`
+
+	// Value is the value of the setter in the scope
+	Value string `json:"value" yaml:"value"`
+}
+
+// Scope describes the scope of the override.
+func (o ScopedOverride) Scope() string {
+	if o.Resource != "" {
+		return "resource " + o.Resource
+	}
+	return "file " + o.File
+}
+
+// matches returns true if the resource n, of the file, is in the scope of
+// the override.
+func (o ScopedOverride) matches(n *yaml.RNode, file string) (bool, error) {
+	if o.Resource == "" {
+		return filepath.ToSlash(filepath.Clean(o.File)) == filepath.ToSlash(file), nil
+	}
+	meta, err := n.GetMeta()
+	if err != nil {
+		return false, errors.WithStack(err)
+	}
+	return o.Resource == meta.Kind+"/"+meta.Name, nil
+}
+
+// check returns an error if the override doesn't have exactly one scope.
+func (o ScopedOverride) check() error {
+	switch {
+	case o.File != "" && o.Resource != "":
+		return errors.Errorf("a setter value may be scoped to a file or a resource, not both")
+	case o.File == "" && o.Resource == "":
+		return errors.Errorf("a setter value must be scoped to a file or a resource")
+	case o.Resource != "" && len(strings.Split(o.Resource, "/")) != 2,
+		strings.HasPrefix(o.Resource, "/"), strings.HasSuffix(o.Resource, "/"):
+		return errors.Errorf("invalid resource %q, must be KIND/NAME", o.Resource)
+	}
+	return nil
+}
+
+// overrideDefinitions returns the overrides of the setters of the Kptfile
+// at path keyed by setter name, files first.
+func overrideDefinitions(path string) (map[string][]ScopedOverride, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	k := struct {
+		OpenAPI struct {
+			Definitions map[string]struct {
+				Kpt struct {
+					Overrides []ScopedOverride `yaml:"overrides"`
+				} `yaml:"x-kpt"`
+			} `yaml:"definitions"`
+		} `yaml:"openAPI"`
+	}{}
+	if err := yaml.Unmarshal(b, &k); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse %q", path)
+	}
+	overrides := map[string][]ScopedOverride{}
+	for key, def := range k.OpenAPI.Definitions {
+		if !strings.HasPrefix(key, fieldmeta.SetterDefinitionPrefix) || len(def.Kpt.Overrides) == 0 {
+			continue
+		}
+		o := def.Kpt.Overrides
+		sort.SliceStable(o, func(i, j int) bool { return o[i].Resource == "" && o[j].Resource != "" })
+		overrides[strings.TrimPrefix(key, fieldmeta.SetterDefinitionPrefix)] = o
+	}
+	return overrides, nil
+}
+
+// withSetterValue returns a copy of sc with the value of the setter name
+// replaced by value.
+func withSetterValue(sc *spec.Schema, name, value string) (*spec.Schema, error) {
+	b, err := json.Marshal(sc)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	c := &spec.Schema{}
+	if err := c.UnmarshalJSON(b); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	def := c.Definitions[fieldmeta.SetterDefinitionPrefix+name]
+	cli, _ := def.Extensions[setters2.K8sCliExtensionKey].(map[string]interface{})
+	setter, _ := cli["setter"].(map[string]interface{})
+	if setter == nil {
+		return nil, errors.Errorf("setter %q is not defined", name)
+	}
+	setter["value"] = value
+	return c, nil
+}
+
+// ScopedSet sets a setter only in the resources of a file, or in a single
+// resource, and records the value as an override of the setter so that
+// setting it without a scope doesn't change them.
+type ScopedSet struct {
+	// Name is the name of the setter
+	Name string
+
+	// Value is the value to set
+	Value string
+
+	// Scope is the file or resource the value is scoped to.  Its value is
+	// ignored.
+	Scope ScopedOverride
+}
+
+// Set sets the setter in the scope of s in the package at path, returning
+// the number of fields set.  Nothing is changed if the value isn't valid
+// for the setter, or no field in the scope references it.
+func (s ScopedSet) Set(path string) (int, error) {
+	if err := s.Scope.check(); err != nil {
+		return 0, err
+	}
+	if !DefExists(path, s.Name) {
+		return 0, errors.Errorf("setter %q is not defined", s.Name)
+	}
+	if err := ValidateValue(path, s.Name, s.Value, nil); err != nil {
+		return 0, err
+	}
+	kf := filepath.Join(path, kptfile.KptFileName)
+	if def, err := setterSchema(kf, s.Name); err != nil {
+		return 0, err
+	} else if def != nil && schemaType(def) == "array" {
+		return 0, errors.Errorf("array setters can't be scoped")
+	}
+
+	// record the override, then set the fields in its scope
+	k, err := yaml.ReadFile(kf)
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	overrides, err := k.Pipe(
+		yaml.Lookup("openAPI", "definitions", fieldmeta.SetterDefinitionPrefix+s.Name),
+		yaml.LookupCreate(yaml.SequenceNode, "x-kpt", "overrides"))
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	override := s.Scope
+	override.Value = s.Value
+	found := false
+	for _, e := range overrides.Content() {
+		var o ScopedOverride
+		if err := e.Decode(&o); err != nil {
+			return 0, errors.Wrap(err, "invalid setter overrides")
+		}
+		if o.File == override.File && o.Resource == override.Resource {
+			if err := yaml.NewRNode(e).PipeE(yaml.SetField("value", yaml.NewStringRNode(s.Value))); err != nil {
+				return 0, errors.WithStack(err)
+			}
+			found = true
+		}
+	}
+	if !found {
+		e := yaml.NewRNode(&yaml.Node{Kind: yaml.MappingNode})
+		for _, f := range []struct{ name, value string }{
+			{"file", override.File}, {"resource", override.Resource}, {"value", override.Value},
+		} {
+			if f.value == "" && f.name != "value" {
+				continue
+			}
+			if err := e.PipeE(yaml.SetField(f.name, yaml.NewStringRNode(f.value))); err != nil {
+				return 0, errors.WithStack(err)
+			}
+		}
+		if err := overrides.PipeE(yaml.Append(e.YNode())); err != nil {
+			return 0, errors.WithStack(err)
+		}
+	}
+
+	sc, err := openapi.SchemaFromFile(kf)
+	if err != nil {
+		return 0, err
+	}
+	scoped, err := withSetterValue(sc, s.Name, s.Value)
+	if err != nil {
+		return 0, err
+	}
+	rw := &kio.LocalPackageReadWriter{PackagePath: path, NoDeleteFiles: true, PackageFileName: kptfile.KptFileName}
+	nodes, err := rw.Read()
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	count := 0
+	changed := map[string]bool{}
+	for _, n := range nodes {
+		file, _, err := kioutil.GetFileAnnotations(n)
+		if err != nil {
+			return 0, errors.WithStack(err)
+		}
+		if ok, err := override.matches(n, file); err != nil || !ok {
+			if err != nil {
+				return 0, err
+			}
+			continue
+		}
+		set := &setters2.Set{Name: s.Name, SettersSchema: scoped}
+		if err := n.PipeE(set); err != nil {
+			return 0, errors.WithStack(err)
+		}
+		count += set.Count
+		changed[file] = changed[file] || set.Count > 0
+	}
+	if count == 0 {
+		return 0, errors.Errorf("no fields of %s reference setter %q", override.Scope(), s.Name)
+	}
+	if err := yaml.WriteFile(k, kf); err != nil {
+		return 0, errors.WithStack(err)
+	}
+	return count, errors.WithStack(writeChanged(rw, nodes, changed))
+}
+
+// applyOverrides sets the fields of the resources in the scope of the
+// overrides of the setters of the package at path to the override values.
+// It returns the number of fields changed.
+func applyOverrides(path string) (int, error) {
+	kf := filepath.Join(path, kptfile.KptFileName)
+	overrides, err := overrideDefinitions(kf)
+	if err != nil || len(overrides) == 0 {
+		return 0, err
+	}
+	sc, err := openapi.SchemaFromFile(kf)
+	if err != nil {
+		return 0, err
+	}
+	var names []string
+	for name := range overrides {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	rw := &kio.LocalPackageReadWriter{PackagePath: path, NoDeleteFiles: true, PackageFileName: kptfile.KptFileName}
+	nodes, err := rw.Read()
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	count := 0
+	changed := map[string]bool{}
+	for _, name := range names {
+		for _, o := range overrides[name] {
+			scoped, err := withSetterValue(sc, name, o.Value)
+			if err != nil {
+				return 0, err
+			}
+			for _, n := range nodes {
+				file, _, err := kioutil.GetFileAnnotations(n)
+				if err != nil {
+					return 0, errors.WithStack(err)
+				}
+				if ok, err := o.matches(n, file); err != nil || !ok {
+					if err != nil {
+						return 0, err
+					}
+					continue
+				}
+				before := scalarValues(n)
+				if err := n.PipeE(&setters2.Set{Name: name, SettersSchema: scoped}); err != nil {
+					return 0, errors.WithStack(err)
+				}
+				for _, s := range scalars(n.YNode(), "") {
+					if before[s.node] != s.node.Value {
+						count++
+						changed[file] = true
+					}
+				}
+			}
+		}
+	}
+	if count == 0 {
+		return 0, nil
+	}
+	return count, errors.WithStack(writeChanged(rw, nodes, changed))
+}
+
+// writeChanged writes the resources of nodes in the changed files with rw,
+// leaving the other files untouched.
+func writeChanged(rw kio.Writer, nodes []*yaml.RNode, changed map[string]bool) error {
+	var out []*yaml.RNode
+	for _, n := range nodes {
+		if file, _, _ := kioutil.GetFileAnnotations(n); changed[file] {
+			out = append(out, n)
+		}
+	}
+	return rw.Write(out)
+}
+
+// ApplyOverrides restores the scoped overrides of the setters of the
+// package at root, and its subpackages, after the setters have been set
+// without a scope.  It returns the number of fields restored.
+func ApplyOverrides(root string) (int, error) {
+	paths, err := pathutil.DirsWithFile(root, kptfile.KptFileName, true)
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, p := range paths {
+		n, err := applyOverrides(p)
+		if err != nil {
+			return count, errors.Wrapf(err, "package %q", p)
+		}
+		count += n
+	}
+	return count, nil
+}
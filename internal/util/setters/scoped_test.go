@@ -0,0 +1,163 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package setters
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt/internal/testutil/pkgbuilder"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/kustomize/kyaml/fieldmeta"
+	"sigs.k8s.io/kustomize/kyaml/setters2/settersutil"
+)
+
+const scopedKptfile = `apiVersion: kpt.dev/v1alpha1
+kind: Kptfile
+metadata:
+  name: app
+openAPI:
+  definitions:
+    io.k8s.cli.setters.replicas:
+      x-k8s-cli:
+        setter:
+          name: replicas
+          value: "3"
+`
+
+const scopedResources = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  replicas: 3 # {"$kpt-set":"replicas"}
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: api
+spec:
+  replicas: 3 # {"$kpt-set":"replicas"}
+`
+
+const scopedCanary = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: canary
+spec:
+  replicas: 3 # {"$kpt-set":"replicas"}
+`
+
+func TestScopedSet(t *testing.T) {
+	defer fieldmeta.SetShortHandRef(fieldmeta.ShortHandRef())
+	fieldmeta.SetShortHandRef("$kpt-set")
+	dir := pkgbuilder.WritePackage(t, map[string]string{
+		"Kptfile":     scopedKptfile,
+		"web.yaml":    scopedResources,
+		"canary.yaml": scopedCanary,
+	})
+	defer os.RemoveAll(dir)
+
+	count, err := ScopedSet{Name: "replicas", Value: "1", Scope: ScopedOverride{File: "canary.yaml"}}.Set(dir)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, 1, count)
+	count, err = ScopedSet{Name: "replicas", Value: "5", Scope: ScopedOverride{Resource: "Deployment/api"}}.Set(dir)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, 1, count)
+
+	// setting without a scope keeps the overrides
+	_, err = setValidated(&settersutil.FieldSetter{
+		Name:            "replicas",
+		Value:           "7",
+		OpenAPIPath:     filepath.Join(dir, "Kptfile"),
+		OpenAPIFileName: "Kptfile",
+		ResourcesPath:   dir,
+		IsSet:           true,
+	})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	count, err = ApplyOverrides(dir)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, 2, count)
+
+	for name, expected := range map[string]string{
+		"web.yaml": `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  replicas: 7 # {"$kpt-set":"replicas"}
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: api
+spec:
+  replicas: 5 # {"$kpt-set":"replicas"}
+`,
+		"canary.yaml": `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: canary
+spec:
+  replicas: 1 # {"$kpt-set":"replicas"}
+`,
+	} {
+		b, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		assert.Equal(t, expected, string(b), name)
+	}
+
+	infos, err := ListSetters(dir, "replicas", false)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	if assert.Len(t, infos, 1) {
+		assert.Equal(t, []ScopedOverride{
+			{File: "canary.yaml", Value: "1"},
+			{Resource: "Deployment/api", Value: "5"},
+		}, infos[0].Overrides)
+	}
+
+	// nothing is set when no field in the scope references the setter
+	_, err = ScopedSet{Name: "replicas", Value: "2", Scope: ScopedOverride{Resource: "Service/web"}}.Set(dir)
+	if assert.Error(t, err) {
+		assert.Equal(t, `no fields of resource Service/web reference setter "replicas"`, err.Error())
+	}
+	_, err = ScopedSet{Name: "replicas", Value: "2", Scope: ScopedOverride{Resource: "web"}}.Set(dir)
+	if assert.Error(t, err) {
+		assert.Equal(t, `invalid resource "web", must be KIND/NAME`, err.Error())
+	}
+	_, err = ScopedSet{Name: "replicas", Value: "2",
+		Scope: ScopedOverride{File: "web.yaml", Resource: "Deployment/web"}}.Set(dir)
+	if assert.Error(t, err) {
+		assert.Equal(t, `a setter value may be scoped to a file or a resource, not both`, err.Error())
+	}
+	_, err = ScopedSet{Name: "image", Value: "2", Scope: ScopedOverride{File: "web.yaml"}}.Set(dir)
+	if assert.Error(t, err) {
+		assert.Equal(t, `setter "image" is not defined`, err.Error())
+	}
+}
@@ -0,0 +1,494 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package setters
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/GoogleContainerTools/kpt/internal/util/inherit"
+	"github.com/GoogleContainerTools/kpt/internal/util/redact"
+	"github.com/GoogleContainerTools/kpt/pkg/kptfile"
+	"github.com/go-openapi/spec"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/kustomize/kyaml/fieldmeta"
+	"sigs.k8s.io/kustomize/kyaml/kio/kioutil"
+	"sigs.k8s.io/kustomize/kyaml/pathutil"
+	"sigs.k8s.io/kustomize/kyaml/setters2"
+	"sigs.k8s.io/kustomize/kyaml/setters2/settersutil"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// SecretType is the type of secret setters.  Their values are never written
+// to the package: the fields referencing them hold redact.Placeholder, and the
+// values are read from their sources when the package is rendered to stdout
+// or applied.  Secret setters are string setters whose definition records
+// the source, e.g.
+//
+//	io.k8s.cli.setters.db-password:
+//	  type: string
+//	  x-kpt:
+//	    secret:
+//	      from: env:DB_PASSWORD
+const SecretType = "secret"
+
+// The sources secret values may be read from.
+const (
+	// EnvSecretSource reads the value from an environment variable,
+	// e.g. env:DB_PASSWORD
+	EnvSecretSource = "env"
+
+	// FileSecretSource reads the value from a file, relative to the
+	// package, e.g. file:/var/run/secrets/db-password
+	FileSecretSource = "file"
+
+	// GCPSecretSource reads the value from Google Cloud Secret Manager,
+	// e.g. gcp-secret-manager:db-password, or the resource name of a
+	// version, e.g. gcp-secret-manager:projects/p/secrets/db-password/versions/2
+	GCPSecretSource = "gcp-secret-manager"
+)
+
+var secretSources = map[string]func(dir, ref string) (string, error){
+	EnvSecretSource: func(_, ref string) (string, error) {
+		v, found := os.LookupEnv(ref)
+		if !found {
+			return "", errors.Errorf("environment variable %s is not set", ref)
+		}
+		return v, nil
+	},
+	FileSecretSource: func(dir, ref string) (string, error) {
+		if !filepath.IsAbs(ref) {
+			ref = filepath.Join(dir, ref)
+		}
+		b, err := ioutil.ReadFile(ref)
+		if err != nil {
+			return "", errors.WithStack(err)
+		}
+		return strings.TrimSuffix(string(b), "\n"), nil
+	},
+	GCPSecretSource: func(_, ref string) (string, error) {
+		return AccessGCPSecret(ref)
+	},
+}
+
+// AccessGCPSecret returns the value of the Google Cloud Secret Manager
+// secret ref, the name of a secret of the current project or the resource
+// name of a secret or secret version.
+var AccessGCPSecret = func(ref string) (string, error) {
+	args := []string{"secrets", "versions", "access"}
+	switch {
+	case strings.Contains(ref, "/versions/"):
+		args = append(args, ref)
+	case strings.HasPrefix(ref, "projects/"):
+		args = append(args, ref+"/versions/latest")
+	default:
+		args = append(args, "latest", "--secret", ref)
+	}
+	b, err := exec.Command("gcloud", args...).Output()
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to access secret %s, please verify gcloud "+
+			"credentials are valid and try again", ref)
+	}
+	return string(b), nil
+}
+
+// DefaultSecretSource is the source of the secret setter name when none is
+// provided, the environment variable KPT_SECRET_<NAME>.
+func DefaultSecretSource(name string) string {
+	return EnvSecretSource + ":KPT_SECRET_" +
+		strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(name))
+}
+
+// MarkSecret makes the setter name of the package at dir a secret setter,
+// whose value is read from from.  The value of the setter, which may be the
+// secret itself, is replaced with redact.Placeholder in its definition and
+// the fields referencing it, so it is never committed with the package.
+func MarkSecret(dir, name, from string) error {
+	if err := CheckSecretSource(from); err != nil {
+		return err
+	}
+	path := filepath.Join(dir, kptfile.KptFileName)
+	kf, err := yaml.ReadFile(path)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	def, err := kf.Pipe(yaml.Lookup("openAPI", "definitions", fieldmeta.SetterDefinitionPrefix+name))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if def == nil {
+		return errors.Errorf("setter %q is not defined", name)
+	}
+	if t, err := def.Pipe(yaml.Lookup("type")); err != nil {
+		return errors.WithStack(err)
+	} else if t != nil && t.YNode().Value != "string" {
+		return errors.Errorf("secret setters are strings, got type %s", t.YNode().Value)
+	}
+	err = def.PipeE(
+		yaml.LookupCreate(yaml.MappingNode, "x-kpt", "secret"),
+		yaml.SetField("from", yaml.NewScalarRNode(from)))
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if err := yaml.WriteFile(kf, path); err != nil {
+		return errors.WithStack(err)
+	}
+	fs := settersutil.FieldSetter{
+		Name:            name,
+		Value:           redact.Placeholder,
+		OpenAPIPath:     path,
+		OpenAPIFileName: kptfile.KptFileName,
+		ResourcesPath:   dir,
+	}
+	_, err = fs.Set()
+	return errors.WithStack(err)
+}
+
+// CheckSecretSource returns an error if from isn't a supported source.
+func CheckSecretSource(from string) error {
+	parts := strings.SplitN(from, ":", 2)
+	if _, found := secretSources[parts[0]]; found && len(parts) == 2 && parts[1] != "" {
+		return nil
+	}
+	var names []string
+	for name := range secretSources {
+		names = append(names, name+":REF")
+	}
+	sort.Strings(names)
+	return errors.Errorf("invalid secret source %q, must be one of %s", from, strings.Join(names, ", "))
+}
+
+// secretSource returns the source of the setter definition s, or "" if it
+// isn't a secret setter.
+func secretSource(s *spec.Schema) string {
+	kpt, _ := s.Extensions["x-kpt"].(map[string]interface{})
+	secret, _ := kpt["secret"].(map[string]interface{})
+	from, _ := secret["from"].(string)
+	return from
+}
+
+// ResolveSecret returns the value of a secret read from its source from.
+// Relative file sources are relative to the package at dir.
+func ResolveSecret(dir, from string) (string, error) {
+	if err := CheckSecretSource(from); err != nil {
+		return "", err
+	}
+	parts := strings.SplitN(from, ":", 2)
+	return secretSources[parts[0]](dir, parts[1])
+}
+
+// InjectedField is a field of a resource set from secret setters or setter
+// values injected when the package is rendered or applied.
+type InjectedField struct {
+	// Resource identifies the resource of the field
+	Resource yaml.ResourceIdentifier
+
+	// Path is the path of the field, e.g. spec.containers[0].env[1].value
+	Path string
+
+	// Value is the value of the field with the values injected
+	Value string
+}
+
+// InjectSecrets sets the fields of nodes, the resources of the package at
+// root, which reference secret setters, directly or through substitutions,
+// to the secret values read from their sources.  Each resource is set from
+// the secret setters of the package it is in.  The fields are returned so
+// they may be injected into the resources again once they have been read
+// without their comments, e.g. to be applied.  Fields are only changed in
+// nodes, the package isn't changed.
+func InjectSecrets(root string, nodes []*yaml.RNode) ([]InjectedField, error) {
+	return Inject(root, nodes, nil)
+}
+
+// Inject sets the fields of nodes like InjectSecrets, and also the fields
+// referencing the setters of values, e.g. the values of --set flags, to
+// those values, so a package committed with placeholders may be rendered or
+// applied with other values without changing it.  The values are validated
+// against the setter definitions of each package defining them, and the
+// computed setters are computed from them.  Fields in the scope of an
+// override of a setter keep the override value.
+func Inject(root string, nodes []*yaml.RNode, values map[string]string) ([]InjectedField, error) {
+	packages, err := pathutil.DirsWithFile(root, kptfile.KptFileName, true)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	// the subpackages are matched first
+	sort.Slice(packages, func(i, j int) bool { return len(packages[i]) > len(packages[j]) })
+	owned := map[string][]*yaml.RNode{}
+	for _, n := range nodes {
+		path, _, err := kioutil.GetFileAnnotations(n)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		for _, p := range packages {
+			rel, err := filepath.Rel(root, p)
+			if err != nil {
+				return nil, errors.WithStack(err)
+			}
+			if rel == "." || strings.HasPrefix(filepath.Clean(path), rel+string(filepath.Separator)) {
+				owned[p] = append(owned[p], n)
+				break
+			}
+		}
+	}
+
+	defined := map[string]bool{}
+	for _, p := range packages {
+		if len(values) == 0 {
+			break
+		}
+		defs, err := setterDefinitions(filepath.Join(p, kptfile.KptFileName))
+		if err != nil {
+			return nil, err
+		}
+		for _, def := range defs {
+			value, found := values[def.Name]
+			if !found {
+				continue
+			}
+			defined[def.Name] = true
+			if len(def.ListValues) > 0 {
+				return nil, errors.Errorf("array setter %q can't be injected", def.Name)
+			}
+			if err := ValidateValue(p, def.Name, value, nil); err != nil {
+				return nil, err
+			}
+		}
+	}
+	var undefined []string
+	for name := range values {
+		if !defined[name] {
+			undefined = append(undefined, name)
+		}
+	}
+	if len(undefined) > 0 {
+		sort.Strings(undefined)
+		return nil, errors.Errorf("setter(s) %s are not defined by the package or its subpackages",
+			strings.Join(undefined, ", "))
+	}
+
+	var fields []InjectedField
+	for _, p := range packages {
+		if len(owned[p]) == 0 {
+			continue
+		}
+		f, err := injectPackage(p, owned[p], values)
+		if err != nil {
+			if p == root {
+				return nil, err
+			}
+			return nil, errors.Wrapf(err, "package %q", p)
+		}
+		fields = append(fields, f...)
+	}
+	return fields, nil
+}
+
+// injectPackage sets the fields of nodes referencing the secret setters of
+// the package at dir, and the setters of values it defines.
+func injectPackage(dir string, nodes []*yaml.RNode, values map[string]string) ([]InjectedField, error) {
+	sc, err := packageSchema(dir)
+	if err != nil || sc == nil {
+		return nil, err
+	}
+	// the computed setters are computed from the values
+	computed, err := computeSetters(dir, values, false)
+	if err != nil {
+		return nil, err
+	}
+	inject := map[string]string{}
+	for name, value := range values {
+		inject[name] = value
+	}
+	for _, c := range computed {
+		inject[c.Name] = c.Value
+	}
+
+	var names, problems []string
+	for key, def := range sc.Definitions {
+		if !strings.HasPrefix(key, fieldmeta.SetterDefinitionPrefix) {
+			continue
+		}
+		name := strings.TrimPrefix(key, fieldmeta.SetterDefinitionPrefix)
+		value, found := inject[name]
+		if from := secretSource(&def); from != "" {
+			if value, err = ResolveSecret(dir, from); err != nil {
+				problems = append(problems, fmt.Sprintf("secret setter %q: %v", name, err))
+				continue
+			}
+		} else if !found {
+			continue
+		}
+		cli, _ := def.Extensions[setters2.K8sCliExtensionKey].(map[string]interface{})
+		setter, _ := cli["setter"].(map[string]interface{})
+		if setter == nil {
+			continue
+		}
+		setter["value"] = value
+		names = append(names, name)
+	}
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return nil, errors.Errorf("unable to read the secrets:\n  %s", strings.Join(problems, "\n  "))
+	}
+	if len(names) == 0 {
+		return nil, nil
+	}
+	sort.Strings(names)
+	overrides, err := overrideDefinitions(filepath.Join(dir, kptfile.KptFileName))
+	if err != nil {
+		return nil, err
+	}
+
+	var fields []InjectedField
+	for _, n := range nodes {
+		file, _, err := kioutil.GetFileAnnotations(n)
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		before := scalarValues(n)
+		for _, name := range names {
+			if scoped, err := inOverrideScope(n, file, overrides[name]); err != nil {
+				return nil, err
+			} else if scoped {
+				continue
+			}
+			if err := n.PipeE(&setters2.Set{Name: name, SettersSchema: sc}); err != nil {
+				return nil, errors.WithStack(err)
+			}
+		}
+		meta, err := n.GetMeta()
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		for _, s := range scalars(n.YNode(), "") {
+			if before[s.node] != s.node.Value {
+				fields = append(fields, InjectedField{
+					Resource: meta.GetIdentifier(), Path: s.path, Value: s.node.Value,
+				})
+			}
+		}
+	}
+	return fields, nil
+}
+
+// inOverrideScope returns true if the resource n, of the file, is in the
+// scope of any of overrides.
+func inOverrideScope(n *yaml.RNode, file string, overrides []ScopedOverride) (bool, error) {
+	for _, o := range overrides {
+		if ok, err := o.matches(n, file); err != nil || ok {
+			return ok, err
+		}
+	}
+	return false, nil
+}
+
+// packageSchema returns the setter definitions of the package at dir,
+// including those it inherits.
+func packageSchema(dir string) (*spec.Schema, error) {
+	k, err := inherit.Kptfile(dir)
+	if err != nil {
+		return nil, err
+	}
+	if k.OpenAPI == nil {
+		return nil, nil
+	}
+	b, err := json.Marshal(k.OpenAPI)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	sc := &spec.Schema{}
+	if err := sc.UnmarshalJSON(b); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return sc, nil
+}
+
+// SecretValues returns the values of the secret setters of the package at
+// root and its subpackages, e.g. to redact them from output.  Secrets
+// which can't be read are skipped.
+func SecretValues(root string) []string {
+	packages, err := pathutil.DirsWithFile(root, kptfile.KptFileName, true)
+	if err != nil {
+		return nil
+	}
+	var values []string
+	for _, p := range packages {
+		sc, err := packageSchema(p)
+		if err != nil || sc == nil {
+			continue
+		}
+		for _, def := range sc.Definitions {
+			if from := secretSource(&def); from != "" {
+				if v, err := ResolveSecret(p, from); err == nil {
+					values = append(values, v)
+				}
+			}
+		}
+	}
+	return values
+}
+
+// scalar is a scalar field and its path.
+type scalar struct {
+	node *yaml.Node
+	path string
+}
+
+// scalars returns the scalar fields under n, whose path is p.
+func scalars(n *yaml.Node, p string) []scalar {
+	var s []scalar
+	switch n.Kind {
+	case yaml.DocumentNode:
+		for _, c := range n.Content {
+			s = append(s, scalars(c, p)...)
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			fp := n.Content[i].Value
+			switch {
+			case strings.ContainsAny(fp, ".["):
+				// e.g. metadata.labels[app.kubernetes.io/name]
+				fp = p + "[" + fp + "]"
+			case p != "":
+				fp = p + "." + fp
+			}
+			s = append(s, scalars(n.Content[i+1], fp)...)
+		}
+	case yaml.SequenceNode:
+		for i, c := range n.Content {
+			s = append(s, scalars(c, fmt.Sprintf("%s[%d]", p, i))...)
+		}
+	case yaml.ScalarNode:
+		s = append(s, scalar{node: n, path: p})
+	}
+	return s
+}
+
+// scalarValues returns the values of the scalar fields of n.
+func scalarValues(n *yaml.RNode) map[*yaml.Node]string {
+	values := map[*yaml.Node]string{}
+	for _, s := range scalars(n.YNode(), "") {
+		values[s.node] = s.node.Value
+	}
+	return values
+}
@@ -0,0 +1,284 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package setters
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt/internal/testutil/pkgbuilder"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/kustomize/kyaml/fieldmeta"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+const secretsKptfile = `apiVersion: kpt.dev/v1alpha1
+kind: Kptfile
+metadata:
+  name: app
+openAPI:
+  definitions:
+    io.k8s.cli.setters.db-password:
+      type: string
+      x-k8s-cli:
+        setter:
+          name: db-password
+          value: changeme
+      x-kpt:
+        secret:
+          from: env:TEST_DB_PASSWORD
+    io.k8s.cli.setters.db-host:
+      x-k8s-cli:
+        setter:
+          name: db-host
+          value: db
+    io.k8s.cli.substitutions.db-url:
+      x-k8s-cli:
+        substitution:
+          name: db-url
+          pattern: postgres://app:${db-password}@${db-host}
+          values:
+          - marker: ${db-password}
+            ref: '#/definitions/io.k8s.cli.setters.db-password'
+          - marker: ${db-host}
+            ref: '#/definitions/io.k8s.cli.setters.db-host'
+`
+
+const secretsResource = `apiVersion: v1
+kind: Secret
+metadata:
+  name: db
+  namespace: prod
+stringData:
+  password: changeme # {"$kpt-set":"db-password"}
+  url: postgres://app:changeme@db # {"$kpt-set":"db-url"}
+  host: db # {"$kpt-set":"db-host"}
+`
+
+const secretsSubKptfile = `apiVersion: kpt.dev/v1alpha1
+kind: Kptfile
+metadata:
+  name: cache
+openAPI:
+  definitions:
+    io.k8s.cli.setters.cache-password:
+      type: string
+      x-k8s-cli:
+        setter:
+          name: cache-password
+          value: changeme
+      x-kpt:
+        secret:
+          from: file:password.txt
+`
+
+const secretsSubResource = `apiVersion: v1
+kind: Secret
+metadata:
+  name: cache
+  labels:
+    app.kubernetes.io/name: cache
+stringData:
+  password: changeme # {"$kpt-set":"cache-password"}
+`
+
+// secretsPackage is a package with secret setters, and a subpackage reading
+// them from a file.
+var secretsPackage = map[string]string{
+	"Kptfile":            secretsKptfile,
+	"secret.yaml":        secretsResource,
+	"cache/Kptfile":      secretsSubKptfile,
+	"cache/secret.yaml":  secretsSubResource,
+	"cache/password.txt": "cache-pw\n",
+}
+
+func TestInjectSecrets(t *testing.T) {
+	defer fieldmeta.SetShortHandRef(fieldmeta.ShortHandRef())
+	fieldmeta.SetShortHandRef("$kpt-set")
+	dir := pkgbuilder.WritePackage(t, secretsPackage)
+	defer os.RemoveAll(dir)
+	read := func() []*yaml.RNode {
+		nodes, err := (&kio.LocalPackageReader{PackagePath: dir}).Read()
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		return nodes
+	}
+
+	_, err := InjectSecrets(dir, read())
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(),
+			`secret setter "db-password": environment variable TEST_DB_PASSWORD is not set`)
+	}
+
+	os.Setenv("TEST_DB_PASSWORD", "s3cr3t")
+	defer os.Unsetenv("TEST_DB_PASSWORD")
+	fields, err := InjectSecrets(dir, read())
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	cache := yaml.ResourceIdentifier{
+		TypeMeta: yaml.TypeMeta{APIVersion: "v1", Kind: "Secret"},
+		NameMeta: yaml.NameMeta{Name: "cache"},
+	}
+	db := yaml.ResourceIdentifier{
+		TypeMeta: yaml.TypeMeta{APIVersion: "v1", Kind: "Secret"},
+		NameMeta: yaml.NameMeta{Name: "db", Namespace: "prod"},
+	}
+	assert.ElementsMatch(t, []InjectedField{
+		{Resource: cache, Path: "stringData.password", Value: "cache-pw"},
+		{Resource: db, Path: "stringData.password", Value: "s3cr3t"},
+		{Resource: db, Path: "stringData.url", Value: "postgres://app:s3cr3t@db"},
+	}, fields)
+	assert.ElementsMatch(t, []string{"s3cr3t", "cache-pw"}, SecretValues(dir))
+
+	// the package isn't changed
+	b, err := ioutil.ReadFile(filepath.Join(dir, "secret.yaml"))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, secretsResource, string(b))
+}
+
+func TestValidateValue_secret(t *testing.T) {
+	dir := pkgbuilder.WritePackage(t, secretsPackage)
+	defer os.RemoveAll(dir)
+
+	err := ValidateValue(dir, "db-password", "hunter2", nil)
+	if assert.Error(t, err) {
+		assert.Equal(t, `setter "db-password" is a secret, its value is read from `+
+			`env:TEST_DB_PASSWORD when the package is rendered or applied`, err.Error())
+	}
+	assert.NoError(t, ValidateValue(dir, "db-host", "db.prod", nil))
+
+	errs, err := ValidateSetterValues(dir)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Empty(t, errs)
+}
+
+func TestMarkSecret(t *testing.T) {
+	dir := pkgbuilder.WritePackage(t, secretsPackage)
+	defer os.RemoveAll(dir)
+
+	if !assert.NoError(t, MarkSecret(dir, "db-host", "gcp-secret-manager:db-host")) {
+		t.FailNow()
+	}
+	infos, err := ListSetters(dir, "db-host", false)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	if assert.Len(t, infos, 1) {
+		assert.Equal(t, SecretType, infos[0].Type)
+		assert.Equal(t, "gcp-secret-manager:db-host", infos[0].SecretFrom)
+		assert.Equal(t, "<redacted>", infos[0].Value)
+	}
+
+	err = MarkSecret(dir, "db-host", "vault:db-host")
+	if assert.Error(t, err) {
+		assert.Equal(t, `invalid secret source "vault:db-host", must be one of `+
+			`env:REF, file:REF, gcp-secret-manager:REF`, err.Error())
+	}
+	err = MarkSecret(dir, "db-port", "env:DB_PORT")
+	if assert.Error(t, err) {
+		assert.Equal(t, `setter "db-port" is not defined`, err.Error())
+	}
+}
+
+func TestResolveSecret_gcp(t *testing.T) {
+	defer func(f func(string) (string, error)) { AccessGCPSecret = f }(AccessGCPSecret)
+	AccessGCPSecret = func(ref string) (string, error) {
+		return "value of " + ref, nil
+	}
+	v, err := ResolveSecret("", "gcp-secret-manager:projects/p/secrets/db/versions/2")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, "value of projects/p/secrets/db/versions/2", v)
+	assert.Equal(t, "env:KPT_SECRET_DB_PASSWORD", DefaultSecretSource("db-password"))
+}
+
+func TestInject(t *testing.T) {
+	defer fieldmeta.SetShortHandRef(fieldmeta.ShortHandRef())
+	fieldmeta.SetShortHandRef("$kpt-set")
+	dir := pkgbuilder.WritePackage(t, computedPackage(computedKptfile))
+	defer os.RemoveAll(dir)
+	read := func(dir string) []*yaml.RNode {
+		nodes, err := (&kio.LocalPackageReader{PackagePath: dir}).Read()
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		return nodes
+	}
+
+	// the computed setters are computed from the injected values
+	nodes := read(dir)
+	fields, err := Inject(dir, nodes, map[string]string{"zone": "prod.io"})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	service := yaml.ResourceIdentifier{
+		TypeMeta: yaml.TypeMeta{APIVersion: "v1", Kind: "Service"},
+		NameMeta: yaml.NameMeta{Name: "web"},
+	}
+	assert.ElementsMatch(t, []InjectedField{
+		{Resource: service, Path: "metadata.annotations.dns", Value: "web.prod.io"},
+		{Resource: service, Path: "metadata.annotations.url", Value: "https://web.prod.io"},
+	}, fields)
+
+	// the package isn't changed
+	b, err := ioutil.ReadFile(filepath.Join(dir, "service.yaml"))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, computedResource, string(b))
+
+	_, err = Inject(dir, read(dir), map[string]string{"dns-name": "api.prod.io"})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), `setter "dns-name" is computed from "{name}.{zone}"`)
+	}
+	_, err = Inject(dir, read(dir), map[string]string{"replicas": "5", "image": "nginx"})
+	if assert.Error(t, err) {
+		assert.Equal(t, "setter(s) image, replicas are not defined by the package or its subpackages",
+			err.Error())
+	}
+
+	// fields in the scope of an override keep the override value
+	scoped := pkgbuilder.WritePackage(t, map[string]string{
+		"Kptfile":  scopedKptfile,
+		"web.yaml": scopedResources,
+	})
+	defer os.RemoveAll(scoped)
+	s := ScopedSet{Name: "replicas", Value: "1", Scope: ScopedOverride{Resource: "Deployment/api"}}
+	if _, err := s.Set(scoped); !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	fields, err = Inject(scoped, read(scoped), map[string]string{"replicas": "5"})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, []InjectedField{{
+		Resource: yaml.ResourceIdentifier{
+			TypeMeta: yaml.TypeMeta{APIVersion: "apps/v1", Kind: "Deployment"},
+			NameMeta: yaml.NameMeta{Name: "web"},
+		},
+		Path:  "spec.replicas",
+		Value: "5",
+	}}, fields)
+}
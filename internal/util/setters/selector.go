@@ -0,0 +1,307 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package setters
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/GoogleContainerTools/kpt/internal/util/fieldpath"
+	"github.com/GoogleContainerTools/kpt/pkg/kptfile"
+	"github.com/go-openapi/spec"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/kustomize/kyaml/fieldmeta"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/kio/kioutil"
+	"sigs.k8s.io/kustomize/kyaml/openapi"
+	"sigs.k8s.io/kustomize/kyaml/setters2"
+	"sigs.k8s.io/kustomize/kyaml/setters2/settersutil"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// PathSet sets the fields selected by a field path to a value, without a
+// setter having been created for them.  The setter is created if the
+// package doesn't define it, and references to it are added to the fields,
+// so that they may be set again by name.
+type PathSet struct {
+	// Name is the name of the setter
+	Name string
+
+	// Value is the value to set
+	Value string
+
+	// Path selects the fields, e.g.
+	// spec.template.spec.containers[*].resources.limits.cpu
+	// Elements of lists are selected with [*] for every element, [N] for
+	// the element at index N, or [key=value] for the elements whose key
+	// field has value.
+	Path string
+
+	// Kind limits the fields to those of resources of the kind, if set
+	Kind string
+
+	// SetBy records who set the value
+	SetBy string
+
+	// Description describes the value
+	Description string
+}
+
+// Set sets the fields selected by s in the package at path, returning the
+// number of fields set.  No fields are changed if the value isn't valid for
+// the setter, or any selected field isn't a scalar or is already set by
+// another setter or substitution.
+func (s PathSet) Set(path string) (int, error) {
+	fp, err := fieldpath.Parse(s.Path)
+	if err != nil {
+		return 0, err
+	}
+	kf := filepath.Join(path, kptfile.KptFileName)
+	defined := DefExists(path, s.Name)
+	if defined {
+		if err := ValidateValue(path, s.Name, s.Value, nil); err != nil {
+			return 0, err
+		}
+	}
+	sc, err := openapi.SchemaFromFile(kf)
+	if err != nil {
+		return 0, err
+	}
+	ref, err := spec.NewRef(fieldmeta.DefinitionsPrefix + fieldmeta.SetterDefinitionPrefix + s.Name)
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+
+	rw := &kio.LocalPackageReadWriter{PackagePath: path, NoDeleteFiles: true, PackageFileName: kptfile.KptFileName}
+	nodes, err := rw.Read()
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	var selected []*yaml.RNode
+	changed := map[string]bool{}
+	for _, n := range nodes {
+		meta, err := n.GetMeta()
+		if err != nil {
+			return 0, errors.WithStack(err)
+		}
+		if s.Kind != "" && meta.Kind != s.Kind {
+			continue
+		}
+		fields, err := fp.Lookup(n)
+		if err != nil {
+			return 0, errors.Wrapf(err, "%s/%s", meta.Kind, meta.Name)
+		}
+		file, _, err := kioutil.GetFileAnnotations(n)
+		if err != nil {
+			return 0, errors.WithStack(err)
+		}
+		for _, m := range fields {
+			f := m.Node
+			if f.YNode().Kind != yaml.ScalarNode {
+				return 0, errors.Errorf("field %s of %s/%s is not a scalar", s.Path, meta.Kind, meta.Name)
+			}
+			fm := fieldmeta.FieldMeta{SettersSchema: sc}
+			if err := fm.Read(f); err != nil {
+				return 0, errors.WithStack(err)
+			}
+			if r := fm.Schema.Ref.String(); r != "" && r != ref.String() {
+				return 0, errors.Errorf("field %s of %s/%s is already set by %s", s.Path, meta.Kind, meta.Name, describeRef(r))
+			}
+			selected = append(selected, f)
+			changed[file] = true
+		}
+	}
+	if len(selected) == 0 {
+		return 0, errors.Errorf("no fields match %s", s.selection())
+	}
+
+	if !defined {
+		def := setters2.SetterDefinition{Name: s.Name, Value: s.Value, SetBy: s.SetBy, Description: s.Description}
+		if err := def.AddToFile(kf); err != nil {
+			return 0, errors.WithStack(err)
+		}
+	}
+	for _, f := range selected {
+		fm := fieldmeta.FieldMeta{Schema: spec.Schema{SchemaProps: spec.SchemaProps{Ref: ref}}}
+		if err := fm.Write(f); err != nil {
+			return 0, errors.WithStack(err)
+		}
+	}
+	var out []*yaml.RNode
+	for _, n := range nodes {
+		if file, _, _ := kioutil.GetFileAnnotations(n); changed[file] {
+			out = append(out, n)
+		}
+	}
+	if err := rw.Write(out); err != nil {
+		return 0, errors.WithStack(err)
+	}
+
+	return setValidated(&settersutil.FieldSetter{
+		Name:            s.Name,
+		Value:           s.Value,
+		Description:     s.Description,
+		SetBy:           s.SetBy,
+		OpenAPIPath:     kf,
+		OpenAPIFileName: kptfile.KptFileName,
+		ResourcesPath:   path,
+		IsSet:           true,
+	})
+}
+
+// selection describes the fields selected by s.
+func (s PathSet) selection() string {
+	if s.Kind == "" {
+		return s.Path
+	}
+	return fmt.Sprintf("%s of %s resources", s.Path, s.Kind)
+}
+
+// describeRef describes the setter or substitution referenced by ref.
+func describeRef(ref string) string {
+	name := strings.TrimPrefix(ref, fieldmeta.DefinitionsPrefix)
+	if strings.HasPrefix(name, fieldmeta.SubstitutionDefinitionPrefix) {
+		return fmt.Sprintf("substitution %q", strings.TrimPrefix(name, fieldmeta.SubstitutionDefinitionPrefix))
+	}
+	return fmt.Sprintf("setter %q", strings.TrimPrefix(name, fieldmeta.SetterDefinitionPrefix))
+}
+
+// FieldPathRef references a setter from the fields selected by a field path
+// with list selectors, such as containers[*].image or rules[0].host, so that
+// one setter sets values distributed across lists.  The path is matched
+// against the end of the paths of the fields, like the paths of the --field
+// flag of create-setter.
+type FieldPathRef struct {
+	// Name is the name of the setter
+	Name string
+
+	// Value is the value of the setter
+	Value string
+
+	// Path selects the fields
+	Path string
+}
+
+// Check returns an error if any field of the package at path selected by r
+// isn't a scalar, is already set by another setter or substitution, or
+// doesn't have the value of the setter, as the fields couldn't all be set
+// consistently.
+func (r FieldPathRef) Check(path string) error {
+	_, _, err := r.read(path, func(meta yaml.ResourceMeta, m fieldpath.Match, fm fieldmeta.FieldMeta) error {
+		if m.Node.YNode().Kind != yaml.ScalarNode {
+			return errors.Errorf("field %s of %s/%s is not a scalar", m.Path, meta.Kind, meta.Name)
+		}
+		if ref := fm.Schema.Ref.String(); ref != "" {
+			return errors.Errorf("field %s of %s/%s is already set by %s", m.Path, meta.Kind, meta.Name, describeRef(ref))
+		}
+		if v := m.Node.YNode().Value; v != r.Value {
+			return errors.Errorf("field %s of %s/%s has value %q rather than the value of setter %q",
+				m.Path, meta.Kind, meta.Name, v, r.Name)
+		}
+		return nil
+	})
+	return err
+}
+
+// Limit limits the references to the setter in the package at path to the
+// fields selected by r, returning the number of fields referencing it.  The
+// setter is created referenced by the fields whose paths end with the path
+// of r without its selectors, which may be more than r selects.
+func (r FieldPathRef) Limit(path string) (int, error) {
+	ref := fieldmeta.DefinitionsPrefix + fieldmeta.SetterDefinitionPrefix + r.Name
+	selected := map[*yaml.Node]bool{}
+	rw, nodes, err := r.read(path, func(_ yaml.ResourceMeta, m fieldpath.Match, _ fieldmeta.FieldMeta) error {
+		selected[m.Node.YNode()] = true
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	sc, err := openapi.SchemaFromFile(filepath.Join(path, kptfile.KptFileName))
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+
+	changed := map[string]bool{}
+	for _, n := range nodes {
+		file, _, err := kioutil.GetFileAnnotations(n)
+		if err != nil {
+			return 0, errors.WithStack(err)
+		}
+		for _, f := range scalars(n.YNode(), "") {
+			if selected[f.node] {
+				continue
+			}
+			fm := fieldmeta.FieldMeta{SettersSchema: sc}
+			if err := fm.Read(yaml.NewRNode(f.node)); err != nil {
+				return 0, errors.WithStack(err)
+			}
+			if fm.Schema.Ref.String() == ref {
+				f.node.LineComment = ""
+				changed[file] = true
+			}
+		}
+	}
+	var out []*yaml.RNode
+	for _, n := range nodes {
+		if file, _, _ := kioutil.GetFileAnnotations(n); changed[file] {
+			out = append(out, n)
+		}
+	}
+	if err := rw.Write(out); err != nil {
+		return 0, errors.WithStack(err)
+	}
+	return len(selected), nil
+}
+
+// read reads the resources of the package at path, calling fn for each
+// field selected by r.
+func (r FieldPathRef) read(path string, fn func(yaml.ResourceMeta, fieldpath.Match, fieldmeta.FieldMeta) error) (
+	*kio.LocalPackageReadWriter, []*yaml.RNode, error) {
+	fp, err := fieldpath.Parse(r.Path)
+	if err != nil {
+		return nil, nil, err
+	}
+	sc, err := openapi.SchemaFromFile(filepath.Join(path, kptfile.KptFileName))
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+	rw := &kio.LocalPackageReadWriter{PackagePath: path, NoDeleteFiles: true, PackageFileName: kptfile.KptFileName}
+	nodes, err := rw.Read()
+	if err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+	for _, n := range nodes {
+		meta, err := n.GetMeta()
+		if err != nil {
+			return nil, nil, errors.WithStack(err)
+		}
+		matches, err := fp.LookupSuffix(n)
+		if err != nil {
+			return nil, nil, errors.Wrapf(err, "%s/%s", meta.Kind, meta.Name)
+		}
+		for _, m := range matches {
+			fm := fieldmeta.FieldMeta{SettersSchema: sc}
+			if err := fm.Read(m.Node); err != nil {
+				return nil, nil, errors.WithStack(err)
+			}
+			if err := fn(meta, m, fm); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+	return rw, nodes, nil
+}
@@ -0,0 +1,275 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package setters
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const selectorResources = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  template:
+    spec:
+      containers:
+      - name: nginx
+        image: nginx:1.7
+        resources:
+          limits:
+            cpu: 100m
+      - name: sidecar
+        image: sidecar:1.0
+        resources:
+          limits:
+            cpu: 50m # {"$ref":"#/definitions/io.k8s.cli.setters.sidecar-cpu"}
+---
+apiVersion: apps/v1
+kind: StatefulSet
+metadata:
+  name: db
+spec:
+  template:
+    spec:
+      containers:
+      - name: db
+        resources:
+          limits:
+            cpu: "1"
+`
+
+func TestPathSet_Set(t *testing.T) {
+	var tests = []struct {
+		name      string
+		set       PathSet
+		count     int
+		expected  []string
+		unchanged bool
+		err       string
+	}{
+		{name: "every element",
+			set:   PathSet{Name: "cpu", Value: "500m", Path: "spec.template.spec.containers[*].resources.limits.cpu", Kind: "StatefulSet"},
+			count: 1,
+			expected: []string{
+				`cpu: "500m" # {"$openapi":"cpu"}`,
+				`cpu: 100m`,
+			}},
+		{name: "selected element",
+			set:   PathSet{Name: "image", Value: "nginx:1.8", Path: "spec.template.spec.containers[name=nginx].image"},
+			count: 1,
+			expected: []string{
+				`image: nginx:1.8 # {"$openapi":"image"}`,
+				`image: sidecar:1.0`,
+			}},
+		{name: "element by index",
+			set:   PathSet{Name: "image", Value: "sidecar:1.1", Path: "spec.template.spec.containers[1].image"},
+			count: 1,
+			expected: []string{
+				`image: nginx:1.7`,
+				`image: sidecar:1.1 # {"$openapi":"image"}`,
+			}},
+		{name: "set by another setter",
+			set:       PathSet{Name: "cpu", Value: "500m", Path: "spec.template.spec.containers[*].resources.limits.cpu"},
+			unchanged: true,
+			err:       `field spec.template.spec.containers[*].resources.limits.cpu of Deployment/web is already set by setter "sidecar-cpu"`},
+		{name: "not a scalar",
+			set:       PathSet{Name: "limits", Value: "1", Path: "spec.template.spec.containers[*].resources.limits", Kind: "StatefulSet"},
+			unchanged: true,
+			err:       "field spec.template.spec.containers[*].resources.limits of StatefulSet/db is not a scalar"},
+		{name: "no fields",
+			set:       PathSet{Name: "cpu", Value: "500m", Path: "spec.template.spec.containers[*].resources.limits.cpu", Kind: "Job"},
+			unchanged: true,
+			err:       "no fields match spec.template.spec.containers[*].resources.limits.cpu of Job resources"},
+		{name: "invalid path",
+			set:       PathSet{Name: "cpu", Value: "500m", Path: "spec..cpu"},
+			unchanged: true,
+			err:       `invalid field path "spec..cpu"`},
+	}
+	for i := range tests {
+		test := tests[i]
+		t.Run(test.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "")
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			defer os.RemoveAll(dir)
+			kf := `apiVersion: kpt.dev/v1alpha1
+kind: Kptfile
+metadata:
+  name: selector
+openAPI:
+  definitions:
+    io.k8s.cli.setters.sidecar-cpu:
+      x-k8s-cli:
+        setter:
+          name: sidecar-cpu
+          value: 50m
+`
+			if !assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "Kptfile"), []byte(kf), 0600)) {
+				t.FailNow()
+			}
+			if !assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "resources.yaml"), []byte(selectorResources), 0600)) {
+				t.FailNow()
+			}
+
+			count, err := test.set.Set(dir)
+			b, rerr := ioutil.ReadFile(filepath.Join(dir, "resources.yaml"))
+			if !assert.NoError(t, rerr) {
+				t.FailNow()
+			}
+			if test.err != "" {
+				assert.EqualError(t, err, test.err)
+				if test.unchanged {
+					assert.Equal(t, selectorResources, string(b))
+					assert.False(t, DefExists(dir, test.set.Name))
+				}
+				return
+			}
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			assert.Equal(t, test.count, count)
+			for _, e := range test.expected {
+				assert.Contains(t, string(b), e)
+			}
+
+			// the setter is created, so the fields may be set again by name
+			if assert.True(t, DefExists(dir, test.set.Name)) {
+				results, err := RecursiveSet{Name: test.set.Name, Value: "2"}.Set(dir)
+				if assert.NoError(t, err) && assert.Len(t, results, 1) {
+					assert.Equal(t, test.count, results[0].Count)
+				}
+			}
+		})
+	}
+}
+
+func TestFieldPathRef_Check(t *testing.T) {
+	var tests = []struct {
+		name string
+		ref  FieldPathRef
+		err  string
+	}{
+		{name: "every element",
+			ref: FieldPathRef{Name: "db-cpu", Value: "1", Path: "containers[*].resources.limits.cpu"},
+			err: `field spec.template.spec.containers[0].resources.limits.cpu of Deployment/web has value "100m" rather than the value of setter "db-cpu"`},
+		{name: "selected element",
+			ref: FieldPathRef{Name: "image", Value: "nginx:1.7", Path: "containers[name=nginx].image"}},
+		{name: "element by index",
+			ref: FieldPathRef{Name: "image", Value: "nginx:1.7", Path: "spec.containers[0].image"}},
+		{name: "set by another setter",
+			ref: FieldPathRef{Name: "cpu", Value: "50m", Path: "containers[1].resources.limits.cpu"},
+			err: `field spec.template.spec.containers[1].resources.limits.cpu of Deployment/web is already set by setter "sidecar-cpu"`},
+		{name: "not a scalar",
+			ref: FieldPathRef{Name: "limits", Value: "1", Path: "containers[*].resources.limits"},
+			err: "field spec.template.spec.containers[0].resources.limits of Deployment/web is not a scalar"},
+	}
+	for i := range tests {
+		test := tests[i]
+		t.Run(test.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "")
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			defer os.RemoveAll(dir)
+			kf := `apiVersion: kpt.dev/v1alpha1
+kind: Kptfile
+metadata:
+  name: selector
+openAPI:
+  definitions:
+    io.k8s.cli.setters.sidecar-cpu:
+      x-k8s-cli:
+        setter:
+          name: sidecar-cpu
+          value: 50m
+`
+			if !assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "Kptfile"), []byte(kf), 0600)) {
+				t.FailNow()
+			}
+			if !assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "resources.yaml"), []byte(selectorResources), 0600)) {
+				t.FailNow()
+			}
+
+			err = test.ref.Check(dir)
+			if test.err != "" {
+				assert.EqualError(t, err, test.err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestFieldPathRef_Limit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir)
+	kf := `apiVersion: kpt.dev/v1alpha1
+kind: Kptfile
+metadata:
+  name: selector
+openAPI:
+  definitions:
+    io.k8s.cli.setters.host:
+      x-k8s-cli:
+        setter:
+          name: host
+          value: a.example.com
+`
+	if !assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "Kptfile"), []byte(kf), 0600)) {
+		t.FailNow()
+	}
+	// the references the kustomize command adds for rules.host
+	r := `apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: web
+spec:
+  rules:
+  - host: a.example.com # {"$ref":"#/definitions/io.k8s.cli.setters.host"}
+  - host: a.example.com # {"$ref":"#/definitions/io.k8s.cli.setters.host"}
+`
+	if !assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "resources.yaml"), []byte(r), 0600)) {
+		t.FailNow()
+	}
+
+	count, err := FieldPathRef{Name: "host", Value: "a.example.com", Path: "rules[0].host"}.Limit(dir)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, 1, count)
+	b, err := ioutil.ReadFile(filepath.Join(dir, "resources.yaml"))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, `apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: web
+spec:
+  rules:
+  - host: a.example.com # {"$ref":"#/definitions/io.k8s.cli.setters.host"}
+  - host: a.example.com
+`, string(b))
+}
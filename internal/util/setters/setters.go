@@ -122,7 +122,7 @@ func SetV2AutoSetter(name, value, root string, w io.Writer) error {
 			IsSet:           true,
 		}
 		format := "automatically set %d field(s) for setter %q to value %q in package %q derived from gcloud config\n"
-		count, err := fs.Set()
+		count, err := setValidated(fs)
 		if err != nil {
 			fmt.Fprintf(w, "failed to set %q automatically in package %q with error: %s\n", name, resourcesPath, err.Error())
 		} else {
@@ -148,7 +148,7 @@ func SetV2AutoSetter(name, value, root string, w io.Writer) error {
 					ResourcesPath:   resourcesPath,
 					IsSet:           true,
 				}
-				count, err := fs.Set()
+				count, err := setValidated(fs)
 				if err != nil {
 					fmt.Fprintf(w, "failed setting auto-setter %q in package %q with error: %s\n", GcloudProjectNumber, resourcesPath, err.Error())
 				} else {
@@ -203,7 +203,7 @@ func (a AutoSet) SetEnvAutoSetters() error {
 				OpenAPIFileName: kptfile.KptFileName,
 				IsSet:           true,
 			}
-			count, err := fs.Set()
+			count, err := setValidated(fs)
 			if err != nil {
 				fmt.Fprintf(a.Writer, "failed to set %q automatically in package %q with error: %s\n", k, resourcesPath, err.Error())
 			} else {
@@ -290,7 +290,7 @@ func (a AutoSet) setInheritedSettersForPkg(pkgPath, parentKptfilePath, setterRef
 		IsSet: isSet(cliExt.Setter.Name, parentKptfilePath),
 	}
 
-	count, err := fs.Set()
+	count, err := setValidated(fs)
 	if err != nil {
 		fmt.Fprintf(a.Writer, "failed to set %q automatically in package %q with error: %s\n", cliExt.Setter.Name, pkgPath, err.Error())
 	} else {
@@ -367,24 +367,3 @@ func DefExists(resourcePath, setterName string) bool {
 	setter, _ := openapi.Resolve(&ref, sc)
 	return setter != nil
 }
-
-// CheckForRequiredSetters takes the package path, checks if there is a KrmFile
-// and checks if all the required setters are set
-func CheckForRequiredSetters(path string) error {
-	kptFilePath := filepath.Join(path, kptfile.KptFileName)
-	_, err := os.Stat(kptFilePath)
-	if err != nil {
-		// if file is not readable or doesn't exist, exit without error
-		// as there might be packages without KrmFile
-		return nil
-	}
-	settersSchema, err := openapi.SchemaFromFile(kptFilePath)
-	if err != nil {
-		return err
-	}
-	if settersSchema == nil {
-		// this happens when there is Kptfile but no setter definitions
-		return nil
-	}
-	return setters2.CheckRequiredSettersSet(settersSchema)
-}
@@ -441,9 +441,7 @@ metadata:
   namespace: child_namespace # {"$kpt-set":"namespace"}
 `,
 			expectedOut: `failed to set "namespace" automatically in package "${childPkg}" with error: ` +
-				`The input value doesn't validate against provided OpenAPI schema: validation failure list:
-namespace in body should be at most 15 chars long
-
+				`invalid value for setter "namespace": violates maxLength 15: "parent_namespace" has 16 characters
 `,
 		},
 		{
@@ -765,7 +763,7 @@ kind: OpenAPIfile`,
 					t.FailNow()
 				}
 			}
-			err = CheckForRequiredSetters(dir)
+			err = CheckForRequiredSetters(dir, nil)
 			if test.expectedError && !assert.Error(t, err) {
 				t.FailNow()
 			}
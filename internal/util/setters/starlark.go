@@ -0,0 +1,138 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package setters
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"go.starlark.net/starlark"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// StarlarkSubstitution sets field values to the result of a Starlark
+// expression of setter values, for derivations which marker replacement
+// can't express -- e.g. case transforms, hashing or conditional prefixes.
+// Like capture substitutions, they are defined in the Kptfile by hand and
+// referenced by fields with $kpt-subst, e.g.
+//
+//	io.kpt.substitutions.bucket:
+//	  x-kpt:
+//	    starlark:
+//	      setters: [env, name]
+//	      expression: '("" if setters["env"] == "prod" else setters["env"] + "-") + setters["name"].lower()'
+//
+// The expression is evaluated hermetically: it may only read the values of
+// the setters it lists, from the setters dict, and call the Starlark
+// built-ins and sha256(s), which returns the hex digest of s.
+type StarlarkSubstitution struct {
+	// Name is the name of the substitution
+	Name string `yaml:"name"`
+
+	// Setters are the names of the setters the expression reads
+	Setters []string `yaml:"setters"`
+
+	// Expression is the Starlark expression computing the field values.  It
+	// must evaluate to a string, number or boolean.
+	Expression string `yaml:"expression"`
+}
+
+// evaluate evaluates the expression with the values of setters, returning
+// the field value.
+func (s StarlarkSubstitution) evaluate(setters map[string]string) (string, error) {
+	values := starlark.NewDict(len(s.Setters))
+	for _, name := range s.Setters {
+		value, found := setters[name]
+		if !found {
+			return "", errors.Errorf("starlark substitution %q references undefined setter %q", s.Name, name)
+		}
+		if err := values.SetKey(starlark.String(name), starlark.String(value)); err != nil {
+			return "", errors.WithStack(err)
+		}
+	}
+	values.Freeze()
+	env := starlark.StringDict{
+		"setters": values,
+		"sha256":  starlark.NewBuiltin("sha256", starlarkSHA256),
+	}
+	thread := &starlark.Thread{
+		Name:  "substitution " + s.Name,
+		Print: func(*starlark.Thread, string) {},
+		Load: func(*starlark.Thread, string) (starlark.StringDict, error) {
+			return nil, errors.Errorf("load is not allowed in substitutions")
+		},
+	}
+	v, err := starlark.Eval(thread, s.Name, s.Expression, env)
+	if err != nil {
+		return "", errors.Errorf("starlark substitution %q failed: %v", s.Name, err)
+	}
+	switch v := v.(type) {
+	case starlark.String:
+		return string(v), nil
+	case starlark.Int, starlark.Float, starlark.Bool:
+		return v.String(), nil
+	}
+	return "", errors.Errorf("starlark substitution %q must evaluate to a string, number or boolean, got %s",
+		s.Name, v.Type())
+}
+
+// starlarkSHA256 implements sha256(s), returning the hex digest of s.
+func starlarkSHA256(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple,
+	kwargs []starlark.Tuple) (starlark.Value, error) {
+	var s string
+	if err := starlark.UnpackPositionalArgs(b.Name(), args, kwargs, 1, &s); err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256([]byte(s))
+	return starlark.String(hex.EncodeToString(sum[:])), nil
+}
+
+// starlarkDefinitions returns the Starlark substitutions of the Kptfile at
+// path, sorted by name.
+func starlarkDefinitions(path string) ([]StarlarkSubstitution, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	k := struct {
+		OpenAPI struct {
+			Definitions map[string]struct {
+				Ext struct {
+					Starlark *StarlarkSubstitution `yaml:"starlark"`
+				} `yaml:"x-kpt"`
+			} `yaml:"definitions"`
+		} `yaml:"openAPI"`
+	}{}
+	if err := yaml.Unmarshal(b, &k); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse %q", path)
+	}
+	var defs []StarlarkSubstitution
+	for key, def := range k.OpenAPI.Definitions {
+		if !strings.HasPrefix(key, CaptureDefinitionPrefix) || def.Ext.Starlark == nil {
+			continue
+		}
+		s := *def.Ext.Starlark
+		if s.Name == "" {
+			s.Name = strings.TrimPrefix(key, CaptureDefinitionPrefix)
+		}
+		defs = append(defs, s)
+	}
+	sort.Slice(defs, func(i, j int) bool { return defs[i].Name < defs[j].Name })
+	return defs, nil
+}
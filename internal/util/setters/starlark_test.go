@@ -0,0 +1,169 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package setters
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt/internal/testutil/pkgbuilder"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStarlarkSubstitution_evaluate(t *testing.T) {
+	setters := map[string]string{"env": "dev", "name": "Web", "replicas": "3"}
+	var tests = []struct {
+		name       string
+		setters    []string
+		expression string
+		expected   string
+		err        string
+	}{
+		{
+			name:       "case",
+			setters:    []string{"name"},
+			expression: `setters["name"].lower()`,
+			expected:   "web",
+		},
+		{
+			name:       "conditional-prefix",
+			setters:    []string{"env", "name"},
+			expression: `("" if setters["env"] == "prod" else setters["env"] + "-") + setters["name"].lower()`,
+			expected:   "dev-web",
+		},
+		{
+			name:       "hash",
+			setters:    []string{"name"},
+			expression: `sha256(setters["name"])[:8]`,
+			expected:   "29751047",
+		},
+		{
+			name:       "number",
+			setters:    []string{"replicas"},
+			expression: `int(setters["replicas"]) * 2`,
+			expected:   "6",
+		},
+		{
+			name:       "unlisted-setter",
+			setters:    []string{"name"},
+			expression: `setters["env"]`,
+			err:        `starlark substitution "s" failed: key "env" not in dict`,
+		},
+		{
+			name:       "undefined-setter",
+			setters:    []string{"region"},
+			expression: `setters["region"]`,
+			err:        `starlark substitution "s" references undefined setter "region"`,
+		},
+		{
+			name:       "list",
+			setters:    []string{"name"},
+			expression: `[setters["name"]]`,
+			err:        `starlark substitution "s" must evaluate to a string, number or boolean, got list`,
+		},
+		{
+			name:       "syntax",
+			setters:    []string{"name"},
+			expression: `setters["name"`,
+			err:        `starlark substitution "s" failed: s:1:15: got end of file, want ']'`,
+		},
+	}
+	for i := range tests {
+		test := tests[i]
+		t.Run(test.name, func(t *testing.T) {
+			s := StarlarkSubstitution{Name: "s", Setters: test.setters, Expression: test.expression}
+			value, err := s.evaluate(setters)
+			if test.err != "" {
+				if assert.Error(t, err) {
+					assert.Equal(t, test.err, err.Error())
+				}
+				return
+			}
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			assert.Equal(t, test.expected, value)
+		})
+	}
+}
+
+func TestSubstituteCaptures_starlark(t *testing.T) {
+	dir := pkgbuilder.WritePackage(t, map[string]string{
+		"Kptfile": `apiVersion: kpt.dev/v1alpha1
+kind: Kptfile
+metadata:
+  name: app
+openAPI:
+  definitions:
+    io.k8s.cli.setters.env:
+      x-k8s-cli:
+        setter:
+          name: env
+          value: prod
+    io.k8s.cli.setters.name:
+      x-k8s-cli:
+        setter:
+          name: name
+          value: Web
+    io.kpt.substitutions.bucket:
+      x-kpt:
+        starlark:
+          setters: [env, name]
+          expression: '("" if setters["env"] == "prod" else setters["env"] + "-") + setters["name"].lower()'
+`,
+		"bucket.yaml": `apiVersion: storage.cnrm.cloud.google.com/v1beta1
+kind: StorageBucket
+metadata:
+  name: dev-web # {"$kpt-subst":"bucket"}
+`,
+	})
+	defer os.RemoveAll(dir)
+
+	// overrides are only checked
+	count, err := substituteCaptures(dir, map[string]string{"env": "staging"}, false)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, 1, count)
+
+	count, err = SubstituteCaptures(dir)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, 1, count)
+	b, err := ioutil.ReadFile(filepath.Join(dir, "bucket.yaml"))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, `apiVersion: storage.cnrm.cloud.google.com/v1beta1
+kind: StorageBucket
+metadata:
+  name: web # {"$kpt-subst":"bucket"}
+`, string(b))
+
+	errs, err := CheckCaptures(dir)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Empty(t, errs)
+
+	err = CheckDeleteSetter(dir, "env", false)
+	if assert.Error(t, err) {
+		assert.Equal(t, `setter "env" is used in starlark substitution "bucket", `+
+			`please delete the parent substitution first`, err.Error())
+	}
+}
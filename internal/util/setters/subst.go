@@ -0,0 +1,108 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package setters
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/GoogleContainerTools/kpt/pkg/kptfile"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/kustomize/kyaml/fieldmeta"
+	"sigs.k8s.io/kustomize/kyaml/pathutil"
+	"sigs.k8s.io/kustomize/kyaml/setters2"
+	"sigs.k8s.io/kustomize/kyaml/setters2/settersutil"
+)
+
+var markerPattern = regexp.MustCompile(`\$\{([^}]*)\}`)
+
+// CheckSubstitution checks a substitution with pattern may be created for
+// the fields with fieldValue in the package at root and, if recurse is
+// true, its subpackages.  The values of the setters the pattern references
+// are derived from fieldValue, and those which a package already defines
+// must have the derived values, otherwise setting any setter of the
+// substitution would change the rest of the field value to the values of
+// the existing setters.
+func CheckSubstitution(root, fieldValue, pattern string, recurse bool) error {
+	paths := []string{root}
+	if recurse {
+		var err error
+		if paths, err = pathutil.DirsWithFile(root, kptfile.KptFileName, true); err != nil {
+			return err
+		}
+	}
+	for _, p := range paths {
+		if err := checkSubstitution(p, fieldValue, pattern); err != nil {
+			if p == root {
+				return err
+			}
+			return errors.Wrapf(err, "package %q", p)
+		}
+	}
+	return nil
+}
+
+// checkSubstitution checks a substitution with pattern may be created for
+// the fields with fieldValue in the package at path.
+func checkSubstitution(path, fieldValue, pattern string) error {
+	defs, err := setterDefinitions(filepath.Join(path, kptfile.KptFileName))
+	if err != nil {
+		return err
+	}
+	existing := map[string]setters2.SetterDefinition{}
+	for _, d := range defs {
+		existing[d.Name] = d
+	}
+
+	c := settersutil.SubstitutionCreator{FieldValue: fieldValue, Pattern: pattern}
+	seen := map[string]bool{}
+	for _, m := range markerPattern.FindAllStringSubmatch(pattern, -1) {
+		if seen[m[0]] {
+			continue
+		}
+		seen[m[0]] = true
+		c.Values = append(c.Values, setters2.Value{
+			Marker: m[0],
+			Ref:    fieldmeta.DefinitionsPrefix + fieldmeta.SetterDefinitionPrefix + m[1],
+		})
+	}
+	if len(c.Values) == 0 {
+		return errors.Errorf("pattern %q doesn't reference any setters, "+
+			"setter names must be enclosed in ${}", pattern)
+	}
+	values, err := c.GetValuesForMarkers()
+	if err != nil {
+		return err
+	}
+
+	var mismatched []string
+	for _, v := range c.Values {
+		name := strings.TrimSuffix(strings.TrimPrefix(v.Marker, "${"), "}")
+		d, found := existing[name]
+		if !found || d.Value == values[v.Marker] {
+			continue
+		}
+		mismatched = append(mismatched, fmt.Sprintf("setter %q has value %q, not %q",
+			name, d.Value, values[v.Marker]))
+	}
+	if len(mismatched) > 0 {
+		return errors.Errorf("field value %q doesn't match the pattern %q with the values of "+
+			"the existing setters: %s, set them or use other setter names",
+			fieldValue, pattern, strings.Join(mismatched, ", "))
+	}
+	return nil
+}
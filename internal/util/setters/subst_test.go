@@ -0,0 +1,74 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package setters
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckSubstitution(t *testing.T) {
+	var tests = []struct {
+		name       string
+		fieldValue string
+		pattern    string
+		err        string
+	}{
+		{name: "new setters", fieldValue: "nginx:1.8", pattern: "${image}:${version}"},
+		{name: "existing setter value", fieldValue: "nginx:1.7", pattern: "${image}:${tag}"},
+		{name: "repeated marker", fieldValue: "1.7-1.7", pattern: "${tag}-${tag}"},
+		{name: "existing setter with another value", fieldValue: "nginx:1.8", pattern: "${image}:${tag}",
+			err: `field value "nginx:1.8" doesn't match the pattern "${image}:${tag}" with the values ` +
+				`of the existing setters: setter "tag" has value "1.7", not "1.8", set them or use other setter names`},
+		{name: "no setters", fieldValue: "nginx:1.8", pattern: "nginx:1.8",
+			err: `pattern "nginx:1.8" doesn't reference any setters, setter names must be enclosed in ${}`},
+		{name: "pattern doesn't match", fieldValue: "nginx:1.8", pattern: "${image}@${digest}",
+			err: "unable to derive values for markers, create setters for all markers and then try again"},
+	}
+	dir, err := ioutil.TempDir("", "")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir)
+	if !assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "Kptfile"), []byte(`apiVersion: kpt.dev/v1alpha1
+kind: Kptfile
+metadata:
+  name: subst
+openAPI:
+  definitions:
+    io.k8s.cli.setters.tag:
+      x-k8s-cli:
+        setter:
+          name: tag
+          value: "1.7"
+`), 0600)) {
+		t.FailNow()
+	}
+	for i := range tests {
+		test := tests[i]
+		t.Run(test.name, func(t *testing.T) {
+			err := CheckSubstitution(dir, test.fieldValue, test.pattern, false)
+			if test.err == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, test.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,305 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package setters
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/GoogleContainerTools/kpt/pkg/kptfile"
+	"github.com/go-openapi/spec"
+	"github.com/pkg/errors"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"sigs.k8s.io/kustomize/kyaml/fieldmeta"
+	"sigs.k8s.io/kustomize/kyaml/openapi"
+	"sigs.k8s.io/kustomize/kyaml/pathutil"
+	"sigs.k8s.io/kustomize/kyaml/setters2/settersutil"
+)
+
+// ValidateValue returns an error if value, or for array setters value
+// followed by listValues, doesn't satisfy the type and constraints of the
+// definition of the setter name in the Kptfile of the package at path.
+// Setters which aren't defined by the package aren't validated.
+func ValidateValue(path, name, value string, listValues []string) error {
+	def, err := setterSchema(filepath.Join(path, kptfile.KptFileName), name)
+	if err != nil || def == nil {
+		return err
+	}
+	if from := secretSource(def); from != "" {
+		return errors.Errorf("setter %q is a secret, its value is read from %s "+
+			"when the package is rendered or applied", name, from)
+	}
+	if expr := computedExpression(def); expr != "" {
+		return errors.Errorf("setter %q is computed from %q, set the setters it references instead",
+			name, expr)
+	}
+	if err := validate(def, value, listValues); err != nil {
+		return errors.Errorf("invalid value for setter %q: %v", name, err)
+	}
+	return nil
+}
+
+// ValidateSet validates the value of the setter name against its
+// definitions, the capture substitutions it is bound to and the computed
+// setters computed from it, in the package
+// at root and, if recurse is true, its subpackages, so that invalid values
+// are rejected before any package is changed.
+func ValidateSet(root, name, value string, listValues []string, recurse bool) error {
+	paths := []string{root}
+	if recurse {
+		var err error
+		if paths, err = pathutil.DirsWithFile(root, kptfile.KptFileName, true); err != nil {
+			return err
+		}
+	}
+	for _, p := range paths {
+		err := ValidateValue(p, name, value, listValues)
+		if err == nil {
+			_, err = substituteCaptures(p, map[string]string{name: value}, false)
+		}
+		if err == nil {
+			_, err = computeSetters(p, map[string]string{name: value}, false)
+		}
+		if err != nil {
+			if p == root {
+				return err
+			}
+			return errors.Wrapf(err, "package %q", p)
+		}
+	}
+	return nil
+}
+
+// ValidateSetterValues validates the current values of the setters in the
+// Kptfile of the package at path against their definitions, returning the
+// errors of the setters whose values are invalid keyed by setter name.
+// Setters which are unset and have no value aren't validated.
+func ValidateSetterValues(path string) (map[string]error, error) {
+	defs, err := setterDefinitions(filepath.Join(path, kptfile.KptFileName))
+	if err != nil {
+		return nil, err
+	}
+	errs := map[string]error{}
+	for _, d := range defs {
+		if !d.IsSet && d.Value == "" && len(d.ListValues) == 0 {
+			continue
+		}
+		if def, err := setterSchema(filepath.Join(path, kptfile.KptFileName), d.Name); err != nil {
+			return nil, err
+		} else if def != nil && secretSource(def) != "" {
+			// secret setters hold placeholders
+			continue
+		} else if def != nil && computedExpression(def) != "" {
+			// computed setters can't be set, but their values are validated
+			if err := validate(def, d.Value, nil); err != nil {
+				errs[d.Name] = errors.Errorf("invalid value for setter %q: %v", d.Name, err)
+			}
+			continue
+		}
+		value, listValues := splitListValues(d.Value, d.ListValues)
+		if err := ValidateValue(path, d.Name, value, listValues); err != nil {
+			errs[d.Name] = err
+		}
+	}
+	return errs, nil
+}
+
+// setValidated sets the setter of fs after validating its value against
+// the setter definition, preserving the untouched elements of the lists of
+// array setters.
+func setValidated(fs *settersutil.FieldSetter) (int, error) {
+	value, listValues := splitListValues(fs.Value, fs.ListValues)
+	if err := ValidateValue(fs.ResourcesPath, fs.Name, value, listValues); err != nil {
+		return 0, err
+	}
+	var count int
+	err := PreserveElements(fs.ResourcesPath, fs.Name, func() error {
+		var err error
+		count, err = fs.Set()
+		return err
+	})
+	return count, err
+}
+
+// splitListValues returns the value and list values of array setters,
+// whose definitions store the value as the first of the list values.
+func splitListValues(value string, listValues []string) (string, []string) {
+	if value == "" && len(listValues) > 0 {
+		return listValues[0], listValues[1:]
+	}
+	return value, listValues
+}
+
+// setterSchema returns the definition of the setter name in the Kptfile at
+// path, or nil if it isn't defined.
+func setterSchema(path, name string) (*spec.Schema, error) {
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil, nil
+	}
+	sc, err := openapi.SchemaFromFile(path)
+	if err != nil || sc == nil {
+		return nil, err
+	}
+	def, found := sc.Definitions[fieldmeta.SetterDefinitionPrefix+name]
+	if !found {
+		return nil, nil
+	}
+	return &def, nil
+}
+
+// schemaType returns the type of s, accepting the int and bool aliases
+// setters have historically been created with.
+func schemaType(s *spec.Schema) string {
+	if len(s.Type) == 0 {
+		return ""
+	}
+	switch t := s.Type[0]; t {
+	case "int":
+		return "integer"
+	case "bool":
+		return "boolean"
+	default:
+		return t
+	}
+}
+
+// validate validates the value of a setter against its definition s.
+func validate(s *spec.Schema, value string, listValues []string) error {
+	if t := schemaType(s); t != "array" {
+		if len(listValues) > 0 && t != "" {
+			return errors.Errorf("setter is not an array, got %d values", len(listValues)+1)
+		}
+		return validateScalar(s, value)
+	}
+
+	values := append([]string{value}, listValues...)
+	if s.MinItems != nil && int64(len(values)) < *s.MinItems {
+		return errors.Errorf("violates minItems %d: got %d values", *s.MinItems, len(values))
+	}
+	if s.MaxItems != nil && int64(len(values)) > *s.MaxItems {
+		return errors.Errorf("violates maxItems %d: got %d values", *s.MaxItems, len(values))
+	}
+	if s.UniqueItems {
+		seen := map[string]bool{}
+		for _, v := range values {
+			if seen[v] {
+				return errors.Errorf("violates uniqueItems: %q is repeated", v)
+			}
+			seen[v] = true
+		}
+	}
+	if s.Items == nil || s.Items.Schema == nil {
+		return nil
+	}
+	for i, v := range values {
+		if err := validateScalar(s.Items.Schema, v); err != nil {
+			return errors.Errorf("value %d: %v", i, err)
+		}
+	}
+	return nil
+}
+
+// validateScalar validates a scalar value against s.  Errors name the
+// constraint of s which value violates.
+func validateScalar(s *spec.Schema, value string) error {
+	var number *float64
+	switch t := schemaType(s); t {
+	case "", "string":
+	case "integer":
+		i, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return errors.Errorf("violates type integer: got %q", value)
+		}
+		f := float64(i)
+		number = &f
+	case "number":
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return errors.Errorf("violates type number: got %q", value)
+		}
+		number = &f
+	case "boolean":
+		if value != "true" && value != "false" {
+			return errors.Errorf("violates type boolean: got %q, must be true or false", value)
+		}
+	default:
+		return errors.Errorf("unsupported setter type %q", t)
+	}
+
+	if len(s.Enum) > 0 {
+		var allowed []string
+		found := false
+		for _, e := range s.Enum {
+			a := fmt.Sprint(e)
+			allowed = append(allowed, a)
+			found = found || a == value
+		}
+		if !found {
+			sort.Strings(allowed)
+			return errors.Errorf("violates enum [%s]: got %q", strings.Join(allowed, ", "), value)
+		}
+	}
+	if number != nil {
+		if s.Minimum != nil && (*number < *s.Minimum || s.ExclusiveMinimum && *number == *s.Minimum) {
+			return errors.Errorf("violates %s: got %s", bound("minimum", *s.Minimum, s.ExclusiveMinimum), value)
+		}
+		if s.Maximum != nil && (*number > *s.Maximum || s.ExclusiveMaximum && *number == *s.Maximum) {
+			return errors.Errorf("violates %s: got %s", bound("maximum", *s.Maximum, s.ExclusiveMaximum), value)
+		}
+	}
+	if s.MinLength != nil && int64(len(value)) < *s.MinLength {
+		return errors.Errorf("violates minLength %d: %q has %d characters", *s.MinLength, value, len(value))
+	}
+	if s.MaxLength != nil && int64(len(value)) > *s.MaxLength {
+		return errors.Errorf("violates maxLength %d: %q has %d characters", *s.MaxLength, value, len(value))
+	}
+	if s.Pattern != "" {
+		p, err := regexp.Compile(s.Pattern)
+		if err != nil {
+			return errors.Errorf("invalid pattern %q: %v", s.Pattern, err)
+		}
+		if !p.MatchString(value) {
+			return errors.Errorf("violates pattern %q: got %q", s.Pattern, value)
+		}
+	}
+	if check, found := formats[s.Format]; found {
+		if msgs := check(value); len(msgs) > 0 {
+			return errors.Errorf("violates format %s: got %q, %s", s.Format, value, strings.Join(msgs, "; "))
+		}
+	}
+	return nil
+}
+
+// formats are the checks of the string formats setters may be constrained
+// to.  Other formats, such as the OpenAPI int32 and int64 formats, aren't
+// checked.
+var formats = map[string]func(string) []string{
+	"dns-label":     validation.IsDNS1123Label,
+	"dns-subdomain": validation.IsDNS1123Subdomain,
+	"ip":            validation.IsValidIP,
+}
+
+// bound formats the minimum or maximum constraint named name for errors.
+func bound(name string, b float64, exclusive bool) string {
+	if exclusive {
+		name = "exclusive" + strings.ToUpper(name[:1]) + name[1:]
+	}
+	return name + " " + strconv.FormatFloat(b, 'f', -1, 64)
+}
@@ -0,0 +1,175 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package setters
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const typedKptfile = `apiVersion: kpt.dev/v1alpha1
+kind: Kptfile
+metadata:
+  name: typed
+openAPI:
+  definitions:
+    io.k8s.cli.setters.replicas:
+      type: integer
+      minimum: 1
+      maximum: 10
+      x-k8s-cli:
+        setter:
+          name: replicas
+          value: "3"
+    io.k8s.cli.setters.debug:
+      type: bool
+      x-k8s-cli:
+        setter:
+          name: debug
+          value: "false"
+    io.k8s.cli.setters.env:
+      type: string
+      enum: [dev, prod]
+      x-k8s-cli:
+        setter:
+          name: env
+          value: dev
+    io.k8s.cli.setters.name:
+      type: string
+      pattern: ^[a-z-]+$
+      maxLength: 8
+      x-k8s-cli:
+        setter:
+          name: name
+          value: app
+    io.k8s.cli.setters.host:
+      type: string
+      format: dns-label
+      x-k8s-cli:
+        setter:
+          name: host
+          value: web
+    io.k8s.cli.setters.ports:
+      type: array
+      maxItems: 2
+      items:
+        type: integer
+      x-k8s-cli:
+        setter:
+          name: ports
+          listValues: ["80"]
+    io.k8s.cli.setters.untyped:
+      x-k8s-cli:
+        setter:
+          name: untyped
+          value: anything
+`
+
+func TestValidateValue(t *testing.T) {
+	var tests = []struct {
+		name       string
+		setter     string
+		value      string
+		listValues []string
+		err        string
+	}{
+		{name: "integer", setter: "replicas", value: "5"},
+		{name: "not an integer", setter: "replicas", value: "three",
+			err: `invalid value for setter "replicas": violates type integer: got "three"`},
+		{name: "below minimum", setter: "replicas", value: "0",
+			err: `invalid value for setter "replicas": violates minimum 1: got 0`},
+		{name: "above maximum", setter: "replicas", value: "11",
+			err: `invalid value for setter "replicas": violates maximum 10: got 11`},
+		{name: "boolean", setter: "debug", value: "true"},
+		{name: "not a boolean", setter: "debug", value: "yes",
+			err: `invalid value for setter "debug": violates type boolean: got "yes", must be true or false`},
+		{name: "enum", setter: "env", value: "prod"},
+		{name: "not in enum", setter: "env", value: "staging",
+			err: `invalid value for setter "env": violates enum [dev, prod]: got "staging"`},
+		{name: "pattern", setter: "name", value: "web-app"},
+		{name: "doesn't match pattern", setter: "name", value: "Web",
+			err: `invalid value for setter "name": violates pattern "^[a-z-]+$": got "Web"`},
+		{name: "too long", setter: "name", value: "long-name",
+			err: `invalid value for setter "name": violates maxLength 8: "long-name" has 9 characters`},
+		{name: "format", setter: "host", value: "web-1"},
+		{name: "doesn't match format", setter: "host", value: "web.1",
+			err: `invalid value for setter "host": violates format dns-label: got "web.1", ` +
+				`a lowercase RFC 1123 label must consist of lower case alphanumeric characters or '-', ` +
+				`and must start and end with an alphanumeric character ` +
+				`(e.g. 'my-name',  or '123-abc', regex used for validation is '[a-z0-9]([-a-z0-9]*[a-z0-9])?')`},
+		{name: "array", setter: "ports", value: "80", listValues: []string{"443"}},
+		{name: "too many items", setter: "ports", value: "80", listValues: []string{"443", "8080"},
+			err: `invalid value for setter "ports": violates maxItems 2: got 3 values`},
+		{name: "invalid item", setter: "ports", value: "80", listValues: []string{"https"},
+			err: `invalid value for setter "ports": value 1: violates type integer: got "https"`},
+		{name: "not an array", setter: "replicas", value: "1", listValues: []string{"2"},
+			err: `invalid value for setter "replicas": setter is not an array, got 2 values`},
+		{name: "untyped", setter: "untyped", value: "three"},
+		{name: "undefined", setter: "undefined", value: "three"},
+	}
+	dir, err := ioutil.TempDir("", "")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir)
+	if !assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "Kptfile"), []byte(typedKptfile), 0600)) {
+		t.FailNow()
+	}
+	for i := range tests {
+		test := tests[i]
+		t.Run(test.name, func(t *testing.T) {
+			err := ValidateValue(dir, test.setter, test.value, test.listValues)
+			if test.err == "" {
+				assert.NoError(t, err)
+			} else if assert.Error(t, err) {
+				assert.Equal(t, test.err, err.Error())
+			}
+		})
+	}
+}
+
+func TestValidateSet(t *testing.T) {
+	dir, err := ioutil.TempDir("", "")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir)
+	sub := filepath.Join(dir, "sub")
+	if !assert.NoError(t, os.MkdirAll(sub, 0700)) {
+		t.FailNow()
+	}
+	if !assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "Kptfile"), []byte(`apiVersion: kpt.dev/v1alpha1
+kind: Kptfile
+metadata:
+  name: root
+`), 0600)) {
+		t.FailNow()
+	}
+	if !assert.NoError(t, ioutil.WriteFile(filepath.Join(sub, "Kptfile"), []byte(typedKptfile), 0600)) {
+		t.FailNow()
+	}
+
+	// the setter isn't defined by the root package
+	assert.NoError(t, ValidateSet(dir, "replicas", "three", nil, false))
+
+	err = ValidateSet(dir, "replicas", "three", nil, true)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), `invalid value for setter "replicas": violates type integer: got "three"`)
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sync
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/GoogleContainerTools/kpt/internal/gitutil"
+	"github.com/GoogleContainerTools/kpt/internal/util/update"
+	"github.com/GoogleContainerTools/kpt/pkg/kptfile"
+	"github.com/GoogleContainerTools/kpt/pkg/kptfile/kptfileutil"
+	"sigs.k8s.io/kustomize/kyaml/errors"
+)
+
+// Add declares dependency in the Kptfile under c.Dir and fetches it, running
+// its setters and functions as Run would.  The Kptfile is only updated once
+// the dependency was fetched, so adding a dependency which can't be fetched
+// leaves the package unchanged.
+func (c Command) Add(dependency kptfile.Dependency) error {
+	k, err := kptfileutil.ReadFile(c.Dir)
+	if err != nil {
+		return errors.WrapPrefixf(err, "failed to read Kptfile under %q -- "+
+			"create one with `kpt pkg init`", c.Dir)
+	}
+	if dependency.Name == "" || filepath.IsAbs(dependency.Name) {
+		return errors.Errorf("dependency destination %q must be a relative path", dependency.Name)
+	}
+	dependency.Name = filepath.ToSlash(filepath.Clean(dependency.Name))
+	for _, d := range k.Dependencies {
+		if d.Name == dependency.Name {
+			return errors.Errorf("dependency %q is already declared -- "+
+				"change its version with `kpt pkg sync set`", dependency.Name)
+		}
+	}
+	path := filepath.Join(c.Dir, dependency.Name)
+	if _, err := os.Stat(path); err == nil {
+		return errors.Errorf("cannot add dependency %q, %q already exists", dependency.Name, path)
+	}
+	if dependency.Strategy == "" {
+		dependency.Strategy = string(update.FastForward)
+	}
+	if dependency.Git.Directory == "" {
+		dependency.Git.Directory = "/"
+	}
+	if c.RequirePinned {
+		err := gitutil.CheckPinnedRef(dependency.Git.Repo, dependency.Git.Directory,
+			dependency.Git.TagTemplate, dependency.Git.Ref, c.GitConfig)
+		if err != nil {
+			return errors.WrapPrefixf(err, "dependency %q", dependency.Name)
+		}
+	}
+
+	c.tree = newDependencyTree(c.Dir, c.FunctionConcurrency)
+	c.chain = []string{packageKey(k.Upstream.Git, c.Dir)}
+	if c.Recursive {
+		if err := c.tree.require(c.chain, c.Dir, append(k.Dependencies, dependency)); err != nil {
+			return err
+		}
+	}
+	if err := c.syncDependency(dependency, c.StdOut); err != nil {
+		_ = os.RemoveAll(path)
+		return err
+	}
+
+	k.Dependencies = append(k.Dependencies, dependency)
+	return kptfileutil.WriteFile(c.Dir, k)
+}
@@ -0,0 +1,105 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sync
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/GoogleContainerTools/kpt/pkg/kptfile"
+	"sigs.k8s.io/kustomize/kyaml/errors"
+)
+
+// dependencyTree records the state shared by the commands syncing a package
+// and its dependencies.
+type dependencyTree struct {
+	mu sync.Mutex
+
+	// root is the directory of the root package
+	root string
+
+	// versions records the first version each package was required at
+	versions map[string]requirement
+
+	// fnSem limits the number of dependencies running functions at once
+	fnSem chan struct{}
+}
+
+// requirement is a package required by a dependency.
+type requirement struct {
+	ref string
+	by  string
+}
+
+func newDependencyTree(root string, fnConcurrency int) *dependencyTree {
+	if fnConcurrency < 1 {
+		fnConcurrency = 1
+	}
+	return &dependencyTree{
+		root:     root,
+		versions: map[string]requirement{},
+		fnSem:    make(chan struct{}, fnConcurrency),
+	}
+}
+
+// require records the dependencies declared by the package at dir, which
+// was reached through chain.  It fails if a dependency is already in chain,
+// or was required at a different version.
+func (t *dependencyTree) require(chain []string, dir string, deps []kptfile.Dependency) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, dep := range deps {
+		if dep.EnsureNotExists {
+			continue
+		}
+		key := packageKey(dep.Git, "")
+		for _, c := range chain {
+			if c == key {
+				return errors.Errorf("dependencies have a cycle: %s",
+					strings.Join(append(chain, key), " -> "))
+			}
+		}
+		r, found := t.versions[key]
+		if !found {
+			t.versions[key] = requirement{ref: dep.Git.Ref, by: dir}
+			continue
+		}
+		if r.ref != dep.Git.Ref {
+			return errors.Errorf("dependency version conflict: %q is required at %q by %q "+
+				"and at %q by %q", key, r.ref, r.by, dep.Git.Ref, dir)
+		}
+	}
+	return nil
+}
+
+// packageKey identifies the package fetched from git, e.g.
+// https://github.com/example/repo/my-pkg, or returns dir if the package
+// wasn't fetched from git.
+func packageKey(git kptfile.Git, dir string) string {
+	if git.Repo == "" {
+		if abs, err := filepath.Abs(dir); err == nil {
+			return abs
+		}
+		return dir
+	}
+	repo := strings.TrimSuffix(strings.TrimSuffix(git.Repo, "/"), ".git")
+	directory := strings.Trim(path.Clean("/"+filepath.ToSlash(git.Directory)), "/")
+	if directory == "" {
+		return repo
+	}
+	return repo + "/" + directory
+}
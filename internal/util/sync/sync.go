@@ -16,14 +16,18 @@
 package sync
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 
+	"github.com/GoogleContainerTools/kpt/internal/gitutil"
+	"github.com/GoogleContainerTools/kpt/internal/util/concurrency"
 	"github.com/GoogleContainerTools/kpt/internal/util/functions"
 	"github.com/GoogleContainerTools/kpt/internal/util/get"
+	"github.com/GoogleContainerTools/kpt/internal/util/license"
 	"github.com/GoogleContainerTools/kpt/internal/util/setters"
 	"github.com/GoogleContainerTools/kpt/internal/util/update"
 	"github.com/GoogleContainerTools/kpt/pkg/kptfile"
@@ -41,6 +45,37 @@ type Command struct {
 	DryRun  bool
 	StdOut  io.Writer
 	StdErr  io.Writer
+
+	// RequirePinned if set rejects dependencies which are not pinned to a
+	// tag or commit
+	RequirePinned bool
+
+	// Licenses is the policy the license of each fetched dependency is
+	// checked against
+	Licenses license.Policy
+
+	// GitConfig is passed as -c key=value flags to each git command run
+	// while fetching or updating the dependencies
+	GitConfig map[string]string
+
+	// FetchConcurrency is the number of dependencies fetched or updated at
+	// once.  Defaults to 1.
+	FetchConcurrency int
+
+	// FunctionConcurrency is the number of dependencies whose setters and
+	// functions run at once.  Defaults to 1.
+	FunctionConcurrency int
+
+	// Recursive if set also syncs the dependencies declared by each
+	// dependency, failing if the dependencies have a cycle or require the
+	// same package at different versions.
+	Recursive bool
+
+	// tree is shared by the commands syncing the packages under Dir
+	tree *dependencyTree
+
+	// chain identifies the packages from the root package to Dir
+	chain []string
 }
 
 // Run syncs all dependencies declared in the Kptfile, fetching them
@@ -81,29 +116,116 @@ func (c Command) Run() error {
 		}
 	}
 
-	for i := range k.Dependencies {
-		dep := k.Dependencies[i]
-		if err := c.sync(dep); err != nil {
+	root := c.tree == nil
+	if root {
+		c.tree = newDependencyTree(c.Dir, c.FunctionConcurrency)
+		c.chain = []string{packageKey(k.Upstream.Git, c.Dir)}
+	}
+	if c.Recursive {
+		if err := c.tree.require(c.chain, c.Dir, k.Dependencies); err != nil {
 			return err
 		}
-		path := filepath.Join(c.Dir, dep.Name)
-		if dep.AutoSet {
-			a := setters.AutoSet{
-				Writer:      c.StdOut,
-				PackagePath: path,
+	}
+
+	// check every dependency before syncing any of them
+	if c.RequirePinned {
+		for _, dep := range k.Dependencies {
+			if dep.EnsureNotExists {
+				continue
 			}
-			if err := a.PerformAutoSetters(); err != nil {
-				return err
+			err := gitutil.CheckPinnedRef(dep.Git.Repo, dep.Git.Directory, dep.Git.TagTemplate,
+				dep.Git.Ref, c.GitConfig)
+			if err != nil {
+				return errors.WrapPrefixf(err, "dependency %q", dep.Name)
 			}
 		}
-		if err := functions.RunFunctions(path, dep.Functions); err != nil {
+	}
+
+	// sync the dependencies at once, writing the output of each in order.
+	// Dependencies which haven't started once one fails are skipped.
+	var failed bool
+	out := make([]bytes.Buffer, len(k.Dependencies))
+	errs := concurrency.ForEach(c.FetchConcurrency, len(k.Dependencies), func(i int) error {
+		c.tree.mu.Lock()
+		skip := failed
+		c.tree.mu.Unlock()
+		if skip {
+			return nil
+		}
+		err := c.syncDependency(k.Dependencies[i], &out[i])
+		if err != nil {
+			c.tree.mu.Lock()
+			failed = true
+			c.tree.mu.Unlock()
+		}
+		return err
+	})
+	for i := range k.Dependencies {
+		if _, err := out[i].WriteTo(c.StdOut); err != nil {
+			return errors.Wrap(err)
+		}
+	}
+	for _, err := range errs {
+		if err != nil {
 			return err
 		}
 	}
-
 	return nil
 }
 
+// syncDependency syncs dep, writing its output to out, runs its functions,
+// and then syncs its dependencies if c is recursive.
+func (c Command) syncDependency(dep kptfile.Dependency, out io.Writer) error {
+	c.StdOut = out
+	if err := c.sync(dep); err != nil {
+		return err
+	}
+	if err := c.runDependencyFunctions(dep); err != nil {
+		return err
+	}
+
+	path := filepath.Join(c.Dir, dep.Name)
+	if !c.Recursive || dep.EnsureNotExists {
+		return nil
+	}
+	if _, err := os.Stat(filepath.Join(path, kptfile.KptFileName)); os.IsNotExist(err) && c.DryRun {
+		// the dependency would have been fetched
+		return nil
+	}
+	c.Dir = path
+	c.chain = append(append([]string{}, c.chain...), packageKey(dep.Git, path))
+	return c.Run()
+}
+
+// runDependencyFunctions runs the functions of dep once there is room to.
+func (c Command) runDependencyFunctions(dep kptfile.Dependency) error {
+	c.tree.fnSem <- struct{}{}
+	defer func() { <-c.tree.fnSem }()
+	return c.runFunctions(dep)
+}
+
+// runFunctions performs the setters and runs the functions of the fetched
+// dependency.
+func (c Command) runFunctions(dep kptfile.Dependency) error {
+	path := filepath.Join(c.Dir, dep.Name)
+	if dep.AutoSet {
+		a := setters.AutoSet{
+			Writer:      c.StdOut,
+			PackagePath: path,
+		}
+		if err := a.PerformAutoSetters(); err != nil {
+			return err
+		}
+	}
+	if err := functions.ApplyCommonMetadata(path); err != nil {
+		return err
+	}
+	if err := functions.RunFunctions(path, dep.Functions); err != nil {
+		return err
+	}
+	return update.RecordDigests(path)
+}
+
 func (c Command) sync(dependency kptfile.Dependency) error {
 	path := filepath.Join(c.Dir, dependency.Name)
 	f, err := os.Stat(path)
@@ -165,6 +287,8 @@ func (c Command) get(dependency kptfile.Dependency) error {
 		Git:         dependency.Git,
 		Destination: path,
 		Name:        dependency.Name,
+		Licenses:    c.Licenses,
+		GitConfig:   c.GitConfig,
 	}.Run()
 }
 
@@ -182,12 +306,14 @@ func (c Command) update(dependency kptfile.Dependency, k *kptfile.KptFile) error
 		return nil
 	}
 	return update.Command{
-		Path:     path,
-		Ref:      dependency.Git.Ref,
-		Repo:     dependency.Git.Repo,
-		Strategy: update.StrategyType(dependency.Strategy),
-		Verbose:  c.Verbose,
-		AutoSet:  dependency.AutoSet,
+		Path:      path,
+		Ref:       dependency.Git.Ref,
+		Repo:      dependency.Git.Repo,
+		Strategy:  update.StrategyType(dependency.Strategy),
+		Verbose:   c.Verbose,
+		AutoSet:   dependency.AutoSet,
+		Output:    c.StdOut,
+		GitConfig: c.GitConfig,
 	}.Run()
 }
 
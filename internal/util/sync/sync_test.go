@@ -12,5 +12,173 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-// TODO(pwittrock): write tests for this package
+// TODO(pwittrock): write tests for the rest of this package
 package sync_test
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt/internal/gitutil"
+	"github.com/GoogleContainerTools/kpt/internal/testutil"
+	. "github.com/GoogleContainerTools/kpt/internal/util/sync"
+	kptfilev1 "github.com/GoogleContainerTools/kpt/pkg/kptfile"
+	"github.com/GoogleContainerTools/kpt/pkg/kptfile/kptfileutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// kptfile returns a Kptfile declaring a dependency on the repo for each name,
+// directory and ref triple in deps.
+func kptfile(repo string, deps ...string) string {
+	k := `apiVersion: kpt.dev/v1alpha1
+kind: Kptfile
+metadata:
+  name: pkg
+dependencies:
+`
+	for i := 0; i+2 < len(deps); i += 3 {
+		k += fmt.Sprintf(`- name: %s
+  git:
+    repo: %s
+    directory: %s
+    ref: %s
+`, deps[i], repo, deps[i+1], deps[i+2])
+	}
+	return k
+}
+
+// writeFile writes and stages the file at path in the repo.
+func writeFile(t *testing.T, g *testutil.TestGitRepo, path, content string) {
+	err := ioutil.WriteFile(filepath.Join(g.RepoDirectory, path), []byte(content), 0600)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	if !assert.NoError(t, gitutil.NewLocalGitRunner(g.RepoDirectory).Run("add", ".")) {
+		t.FailNow()
+	}
+}
+
+// TestCommand_Run_recursive verifies that the dependencies of dependencies
+// are synced.
+func TestCommand_Run_recursive(t *testing.T) {
+	g, w, clean := testutil.SetupDefaultRepoAndWorkspace(t, testutil.Dataset1)
+	defer clean()
+
+	writeFile(t, g, filepath.Join("java", "Kptfile"), kptfile(g.RepoDirectory, "mysql", "mysql", "master"))
+	testutil.Commit(t, g, "java depends on mysql")
+
+	err := ioutil.WriteFile(filepath.Join(w.WorkspaceDirectory, "Kptfile"),
+		[]byte(kptfile(g.RepoDirectory, "java", "java", "master")), 0600)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	b := &bytes.Buffer{}
+	err = Command{Dir: w.WorkspaceDirectory, StdOut: b, Recursive: true}.Run()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	testutil.AssertPkgEqual(t, g, filepath.Join(g.DatasetDirectory, testutil.Dataset1, "mysql"),
+		filepath.Join(w.WorkspaceDirectory, "java", "mysql"))
+	assert.Contains(t, b.String(), fmt.Sprintf("fetching %q from %q", "mysql",
+		filepath.Join(w.WorkspaceDirectory, "java", "mysql")))
+}
+
+// TestCommand_Run_cycle verifies that dependency cycles are rejected.
+func TestCommand_Run_cycle(t *testing.T) {
+	g, w, clean := testutil.SetupDefaultRepoAndWorkspace(t, testutil.Dataset1)
+	defer clean()
+
+	writeFile(t, g, filepath.Join("java", "Kptfile"), kptfile(g.RepoDirectory, "mysql", "mysql", "master"))
+	writeFile(t, g, filepath.Join("mysql", "Kptfile"), kptfile(g.RepoDirectory, "java", "java", "master"))
+	testutil.Commit(t, g, "java and mysql depend on each other")
+
+	err := ioutil.WriteFile(filepath.Join(w.WorkspaceDirectory, "Kptfile"),
+		[]byte(kptfile(g.RepoDirectory, "java", "java", "master")), 0600)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	err = Command{Dir: w.WorkspaceDirectory, StdOut: &bytes.Buffer{}, Recursive: true}.Run()
+	if assert.Error(t, err) {
+		java, mysql := g.RepoDirectory+"/java", g.RepoDirectory+"/mysql"
+		assert.Contains(t, err.Error(), fmt.Sprintf("dependencies have a cycle: %s -> %s -> %s -> %s",
+			w.WorkspaceDirectory, java, mysql, java))
+	}
+}
+
+// TestCommand_Run_versionConflict verifies that packages required at different
+// versions are rejected.
+func TestCommand_Run_versionConflict(t *testing.T) {
+	g, w, clean := testutil.SetupDefaultRepoAndWorkspace(t, testutil.Dataset1)
+	defer clean()
+
+	testutil.Tag(t, g, "v1")
+	writeFile(t, g, filepath.Join("java", "Kptfile"), kptfile(g.RepoDirectory, "mysql", "mysql", "master"))
+	testutil.Commit(t, g, "java depends on mysql")
+
+	err := ioutil.WriteFile(filepath.Join(w.WorkspaceDirectory, "Kptfile"),
+		[]byte(kptfile(g.RepoDirectory, "mysql", "mysql", "v1", "java", "java", "master")), 0600)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	err = Command{Dir: w.WorkspaceDirectory, StdOut: &bytes.Buffer{}, Recursive: true}.Run()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), fmt.Sprintf(
+			"dependency version conflict: %q is required at %q by %q and at %q by %q",
+			g.RepoDirectory+"/mysql", "v1", w.WorkspaceDirectory,
+			"master", filepath.Join(w.WorkspaceDirectory, "java")))
+	}
+}
+
+// TestCommand_Add verifies that added dependencies are fetched and declared
+// in the Kptfile, and that dependencies which can't be fetched aren't.
+func TestCommand_Add(t *testing.T) {
+	g, w, clean := testutil.SetupDefaultRepoAndWorkspace(t, testutil.Dataset1)
+	defer clean()
+
+	err := ioutil.WriteFile(filepath.Join(w.WorkspaceDirectory, "Kptfile"),
+		[]byte(kptfile(g.RepoDirectory)), 0600)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	c := Command{Dir: w.WorkspaceDirectory, StdOut: &bytes.Buffer{}, Recursive: true}
+	err = c.Add(kptfilev1.Dependency{Name: "java",
+		Upstream: kptfilev1.Upstream{Git: kptfilev1.Git{Repo: g.RepoDirectory, Directory: "java", Ref: "master"}}})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	testutil.AssertPkgEqual(t, g, filepath.Join(g.DatasetDirectory, testutil.Dataset1, "java"),
+		filepath.Join(w.WorkspaceDirectory, "java"))
+	k, err := kptfileutil.ReadFile(w.WorkspaceDirectory)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	if assert.Len(t, k.Dependencies, 1) {
+		assert.Equal(t, "java", k.Dependencies[0].Name)
+		assert.Equal(t, "java", k.Dependencies[0].Git.Directory)
+		assert.Equal(t, "master", k.Dependencies[0].Git.Ref)
+		assert.Equal(t, "fast-forward", k.Dependencies[0].Strategy)
+	}
+
+	err = c.Add(kptfilev1.Dependency{Name: "java",
+		Upstream: kptfilev1.Upstream{Git: kptfilev1.Git{Repo: g.RepoDirectory, Directory: "mysql", Ref: "master"}}})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), `dependency "java" is already declared`)
+	}
+
+	err = c.Add(kptfilev1.Dependency{Name: "missing",
+		Upstream: kptfilev1.Upstream{Git: kptfilev1.Git{Repo: g.RepoDirectory, Directory: "missing", Ref: "master"}}})
+	assert.Error(t, err)
+	assert.NoDirExists(t, filepath.Join(w.WorkspaceDirectory, "missing"))
+	k, err = kptfileutil.ReadFile(w.WorkspaceDirectory)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Len(t, k.Dependencies, 1)
+}
@@ -0,0 +1,325 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package tmputil contains libraries for creating temporary directories.
+package tmputil
+
+import (
+	"fmt"
+	"hash/fnv"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"sigs.k8s.io/kustomize/kyaml/errors"
+)
+
+const (
+	// TmpDirEnv is the name of the environment variable that controls where
+	// temporary clones and staging directories are created.  Defaults to the
+	// os temp directory if unspecified.
+	TmpDirEnv = "KPT_TMPDIR"
+
+	// MinFreeEnv is the name of the environment variable that controls the
+	// minimum free space, in MiB, required in the temp directory before
+	// fetching packages.  Set to 0 to disable the check.
+	MinFreeEnv = "KPT_TMPDIR_MIN_FREE_MB"
+
+	// DefaultMinFreeMB is the default minimum free space required in the
+	// temp directory.
+	DefaultMinFreeMB = 100
+
+	// MaxSizeEnv is the name of the environment variable that controls the
+	// total size of kpt temp directories, e.g. 2GiB.  The least recently
+	// modified directories are removed once it is exceeded.  Unlimited if
+	// unspecified.
+	MaxSizeEnv = "KPT_TMPDIR_MAX_SIZE"
+
+	// prefix is the prefix shared by the temp directories kpt creates.
+	prefix = "kpt-"
+)
+
+// StaleAge is the age after which temp directories left behind by
+// interrupted commands are removed.
+var StaleAge = 24 * time.Hour
+
+// MinEvictAge is the age under which temp directories are never removed to
+// fit MaxSizeEnv, as they may still be in use.
+var MinEvictAge = time.Hour
+
+// Dir returns the directory temporary directories are created under.
+func Dir() string {
+	if dir := os.Getenv(TmpDirEnv); dir != "" {
+		return dir
+	}
+	return os.TempDir()
+}
+
+// TempDir creates a new temporary directory with the given prefix, first
+// removing any stale directories with the same prefix.
+func TempDir(prefix string) (string, error) {
+	dir := Dir()
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", errors.Errorf("failed to create temp directory %q: %v", dir, err)
+	}
+	RemoveStale(prefix)
+	if err := Evict(); err != nil {
+		return "", err
+	}
+	// the name records the process creating the directory, so it isn't
+	// removed by other processes while in use
+	d, err := ioutil.TempDir(dir, fmt.Sprintf("%s%d.%s-", prefix, os.Getpid(), hostHash()))
+	if err != nil {
+		return "", errors.Errorf("failed to create temp directory under %q "+
+			"(set %s to use a different directory): %v", dir, TmpDirEnv, err)
+	}
+	return d, nil
+}
+
+// WorkspaceDir creates a new temporary directory with the given prefix to
+// copy packages to, first checking there is room for them.
+func WorkspaceDir(prefix string) (string, error) {
+	if err := CheckFreeSpace(); err != nil {
+		return "", err
+	}
+	return TempDir(prefix)
+}
+
+// hostHash returns a short hash of the hostname, distinguishing the
+// processes of hosts sharing a temp directory.
+func hostHash() string {
+	host, _ := os.Hostname()
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(host))
+	return fmt.Sprintf("%08x", h.Sum32())
+}
+
+// ownerPattern matches the pid and host hash TempDir adds to the names of
+// the directories it creates.
+var ownerPattern = regexp.MustCompile(`(\d+)\.([0-9a-f]{8})-\d+$`)
+
+// inUse returns true if dir was created by a process on this host which is
+// still running.  Directories created by older versions of kpt, or on other
+// hosts, are only removed once they are old enough.
+func inUse(dir string) bool {
+	m := ownerPattern.FindStringSubmatch(filepath.Base(dir))
+	if m == nil || m[2] != hostHash() {
+		return false
+	}
+	pid, err := strconv.Atoi(m[1])
+	if err != nil {
+		return false
+	}
+	return pid == os.Getpid() || processRunning(pid)
+}
+
+// TempFile creates a new temporary file with the given prefix.
+func TempFile(prefix string) (*os.File, error) {
+	dir := Dir()
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, errors.Errorf("failed to create temp directory %q: %v", dir, err)
+	}
+	return ioutil.TempFile(dir, prefix)
+}
+
+// RemoveStale removes temp directories with the given prefix which are older
+// than StaleAge, unless the process which created them is still running.
+// Failures are ignored as the directories may be in use or owned by another
+// user.
+func RemoveStale(prefix string) {
+	matches, err := filepath.Glob(filepath.Join(Dir(), prefix+"*"))
+	if err != nil {
+		return
+	}
+	for _, m := range matches {
+		info, err := os.Stat(m)
+		if err != nil || !info.IsDir() || time.Since(info.ModTime()) < StaleAge || inUse(m) {
+			continue
+		}
+		_ = os.RemoveAll(m)
+	}
+}
+
+// Usage returns the number of kpt temp directories, and their total size.
+func Usage() (int, int64) {
+	dirs := tempDirs()
+	var size int64
+	for _, d := range dirs {
+		size += d.size
+	}
+	return len(dirs), size
+}
+
+// MaxSize returns the total size of kpt temp directories configured by
+// MaxSizeEnv, or 0 for no limit.
+func MaxSize() (int64, error) {
+	v := os.Getenv(MaxSizeEnv)
+	if v == "" {
+		return 0, nil
+	}
+	max, err := ParseSize(v)
+	if err != nil {
+		return 0, errors.Errorf("invalid %s value: %v", MaxSizeEnv, err)
+	}
+	return max, nil
+}
+
+// Evict removes the least recently modified kpt temp directories, other than
+// those modified within MinEvictAge or still in use, until they fit the size
+// configured by MaxSizeEnv.
+func Evict() error {
+	max, err := MaxSize()
+	if err != nil || max <= 0 {
+		return err
+	}
+	dirs := tempDirs()
+	var size int64
+	for _, d := range dirs {
+		size += d.size
+	}
+	sort.Slice(dirs, func(i, j int) bool { return dirs[i].modified.Before(dirs[j].modified) })
+	for _, d := range dirs {
+		if size <= max {
+			break
+		}
+		if time.Since(d.modified) < MinEvictAge || inUse(d.path) {
+			continue
+		}
+		if err := os.RemoveAll(d.path); err != nil {
+			// may be owned by another user
+			continue
+		}
+		size -= d.size
+	}
+	return nil
+}
+
+// tempDir is a kpt temp directory.
+type tempDir struct {
+	path     string
+	size     int64
+	modified time.Time
+}
+
+// tempDirs returns the kpt temp directories.
+func tempDirs() []tempDir {
+	matches, err := filepath.Glob(filepath.Join(Dir(), prefix+"*"))
+	if err != nil {
+		return nil
+	}
+	var dirs []tempDir
+	for _, m := range matches {
+		info, err := os.Stat(m)
+		if err != nil || !info.IsDir() {
+			continue
+		}
+		dirs = append(dirs, tempDir{path: m, size: DirSize(m), modified: info.ModTime()})
+	}
+	return dirs
+}
+
+// DirSize returns the total size of the files under dir.  Files which can't
+// be read are skipped.
+func DirSize(dir string) int64 {
+	var size int64
+	_ = filepath.Walk(dir, func(_ string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil
+		}
+		if info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size
+}
+
+// sizeUnits are the suffixes accepted by ParseSize.
+var sizeUnits = []struct {
+	suffix string
+	bytes  int64
+}{
+	{"KiB", 1 << 10}, {"MiB", 1 << 20}, {"GiB", 1 << 30}, {"TiB", 1 << 40},
+	{"Ki", 1 << 10}, {"Mi", 1 << 20}, {"Gi", 1 << 30}, {"Ti", 1 << 40},
+	{"KB", 1e3}, {"MB", 1e6}, {"GB", 1e9}, {"TB", 1e12},
+	{"K", 1e3}, {"M", 1e6}, {"G", 1e9}, {"T", 1e12},
+	{"B", 1},
+}
+
+// ParseSize parses a size in bytes, optionally with a unit suffix, e.g.
+// 500MB or 2GiB.
+func ParseSize(v string) (int64, error) {
+	s := strings.TrimSpace(v)
+	unit := int64(1)
+	for _, u := range sizeUnits {
+		if strings.HasSuffix(s, u.suffix) {
+			s, unit = strings.TrimSpace(strings.TrimSuffix(s, u.suffix)), u.bytes
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil || n < 0 {
+		return 0, errors.Errorf("invalid size %q: must be a number of bytes, "+
+			"optionally followed by a unit such as MB or GiB", v)
+	}
+	return int64(n * float64(unit)), nil
+}
+
+// CheckFreeSpace returns an error if the temp directory doesn't have the
+// minimum free space, as configured by MinFreeEnv, available.
+func CheckFreeSpace() error {
+	minMB := uint64(DefaultMinFreeMB)
+	if v := os.Getenv(MinFreeEnv); v != "" {
+		var err error
+		minMB, err = strconv.ParseUint(strings.TrimSpace(v), 10, 64)
+		if err != nil {
+			return errors.Errorf("invalid %s value %q: %v", MinFreeEnv, v, err)
+		}
+	}
+	if minMB == 0 {
+		return nil
+	}
+
+	dir := Dir()
+	free, ok := freeSpace(dir)
+	if !ok {
+		// free space can't be determined on this platform -- don't fail
+		return nil
+	}
+	if free < minMB<<20 {
+		return errors.Errorf("insufficient free space in temp directory %q: "+
+			"%s available, %s required (set %s to use a different directory)",
+			dir, FormatBytes(int64(free)), FormatBytes(int64(minMB<<20)), TmpDirEnv)
+	}
+	return nil
+}
+
+// FormatBytes formats b as a human readable size.
+func FormatBytes(b int64) string {
+	switch {
+	case b >= 1<<30:
+		return fmt.Sprintf("%.1fGiB", float64(b)/(1<<30))
+	case b >= 1<<20:
+		return fmt.Sprintf("%.1fMiB", float64(b)/(1<<20))
+	case b >= 1<<10:
+		return fmt.Sprintf("%.1fKiB", float64(b)/(1<<10))
+	default:
+		return fmt.Sprintf("%dB", b)
+	}
+}
@@ -0,0 +1,190 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tmputil_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GoogleContainerTools/kpt/internal/util/tmputil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTempDir(t *testing.T) {
+	d, err := ioutil.TempDir("", "kpt-tmputil")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer os.RemoveAll(d)
+	os.Setenv(tmputil.TmpDirEnv, filepath.Join(d, "tmp"))
+	defer os.Unsetenv(tmputil.TmpDirEnv)
+
+	// create a stale directory left behind by a previous command
+	stale := filepath.Join(d, "tmp", "kpt-test-stale")
+	if !assert.NoError(t, os.MkdirAll(stale, 0700)) {
+		t.FailNow()
+	}
+	old := time.Now().Add(-2 * tmputil.StaleAge)
+	if !assert.NoError(t, os.Chtimes(stale, old, old)) {
+		t.FailNow()
+	}
+
+	tmp, err := tmputil.TempDir("kpt-test-")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, filepath.Join(d, "tmp"), filepath.Dir(tmp))
+	_, err = os.Stat(stale)
+	assert.True(t, os.IsNotExist(err))
+}
+
+// TestRemoveStale verifies that stale directories are only removed once the
+// process which created them has exited.
+func TestRemoveStale(t *testing.T) {
+	d, err := ioutil.TempDir("", "kpt-tmputil")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer os.RemoveAll(d)
+	os.Setenv(tmputil.TmpDirEnv, d)
+	defer os.Unsetenv(tmputil.TmpDirEnv)
+
+	running, err := tmputil.TempDir("kpt-test-")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	// a directory created by a process which has exited
+	cmd := exec.Command("true")
+	if !assert.NoError(t, cmd.Run()) {
+		t.FailNow()
+	}
+	hash := strings.TrimPrefix(filepath.Base(running), fmt.Sprintf("kpt-test-%d", os.Getpid()))
+	hash = hash[:strings.LastIndex(hash, "-")]
+	exited := filepath.Join(d, fmt.Sprintf("kpt-test-%d%s-1234", cmd.Process.Pid, hash))
+	if !assert.NoError(t, os.MkdirAll(exited, 0700)) {
+		t.FailNow()
+	}
+
+	old := time.Now().Add(-2 * tmputil.StaleAge)
+	for _, dir := range []string{running, exited} {
+		if !assert.NoError(t, os.Chtimes(dir, old, old)) {
+			t.FailNow()
+		}
+	}
+
+	tmputil.RemoveStale("kpt-test-")
+	_, err = os.Stat(running)
+	assert.NoError(t, err)
+	_, err = os.Stat(exited)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestCheckFreeSpace(t *testing.T) {
+	os.Setenv(tmputil.MinFreeEnv, "0")
+	assert.NoError(t, tmputil.CheckFreeSpace())
+
+	os.Setenv(tmputil.MinFreeEnv, "foo")
+	assert.Error(t, tmputil.CheckFreeSpace())
+
+	// no filesystem has this much space free
+	os.Setenv(tmputil.MinFreeEnv, "1000000000000")
+	err := tmputil.CheckFreeSpace()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "insufficient free space")
+	}
+	os.Unsetenv(tmputil.MinFreeEnv)
+}
+
+func TestEvict(t *testing.T) {
+	d, err := ioutil.TempDir("", "kpt-tmputil")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer os.RemoveAll(d)
+	os.Setenv(tmputil.TmpDirEnv, d)
+	defer os.Unsetenv(tmputil.TmpDirEnv)
+	os.Setenv(tmputil.MaxSizeEnv, "1500B")
+	defer os.Unsetenv(tmputil.MaxSizeEnv)
+
+	// create clones of 1000 bytes each, the oldest first
+	for i, name := range []string{"kpt-get-old", "kpt-get-newer", "kpt-get-recent"} {
+		dir := filepath.Join(d, name)
+		if !assert.NoError(t, os.MkdirAll(dir, 0700)) {
+			t.FailNow()
+		}
+		if !assert.NoError(t, ioutil.WriteFile(
+			filepath.Join(dir, "f"), make([]byte, 1000), 0600)) {
+			t.FailNow()
+		}
+		modified := time.Now().Add(time.Duration(i-3) * tmputil.MinEvictAge)
+		if i == 2 {
+			// still in use
+			modified = time.Now()
+		}
+		if !assert.NoError(t, os.Chtimes(dir, modified, modified)) {
+			t.FailNow()
+		}
+	}
+
+	if !assert.NoError(t, tmputil.Evict()) {
+		t.FailNow()
+	}
+	n, size := tmputil.Usage()
+	assert.Equal(t, 1, n)
+	assert.Equal(t, int64(1000), size)
+	_, err = os.Stat(filepath.Join(d, "kpt-get-recent"))
+	assert.NoError(t, err)
+
+	os.Setenv(tmputil.MaxSizeEnv, "foo")
+	assert.Error(t, tmputil.Evict())
+}
+
+func TestParseSize(t *testing.T) {
+	for s, expected := range map[string]int64{
+		"100":    100,
+		"100B":   100,
+		"2KiB":   2048,
+		"1.5 GB": 1500000000,
+		"5Gi":    5 << 30,
+		"10MB":   10000000,
+	} {
+		actual, err := tmputil.ParseSize(s)
+		if assert.NoError(t, err, s) {
+			assert.Equal(t, expected, actual, s)
+		}
+	}
+	_, err := tmputil.ParseSize("-1GiB")
+	assert.Error(t, err)
+	_, err = tmputil.ParseSize("lots")
+	assert.Error(t, err)
+}
+
+func TestFormatBytes(t *testing.T) {
+	for b, expected := range map[int64]string{
+		100:     "100B",
+		1536:    "1.5KiB",
+		5 << 20: "5.0MiB",
+		3 << 30: "3.0GiB",
+	} {
+		assert.Equal(t, expected, tmputil.FormatBytes(b))
+	}
+}
@@ -0,0 +1,465 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package update
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/GoogleContainerTools/kpt/internal/util/merge"
+	"github.com/GoogleContainerTools/kpt/pkg/kptfile"
+	"github.com/pmezard/go-difflib/difflib"
+	"sigs.k8s.io/kustomize/kyaml/errors"
+)
+
+// ConflictPolicy controls how the resource merge strategies resolve
+// resources and fields which were changed both upstream and locally.
+type ConflictPolicy string
+
+const (
+	// ConflictUpstream takes the upstream changes.  The default.
+	ConflictUpstream ConflictPolicy = "upstream"
+
+	// ConflictLocal keeps the local changes, and merges the other upstream
+	// changes.
+	ConflictLocal ConflictPolicy = "local"
+
+	// ConflictSkip doesn't update the files with conflicts.
+	ConflictSkip ConflictPolicy = "skip"
+
+	// ConflictAbort fails the update without changing the package.
+	ConflictAbort ConflictPolicy = "abort"
+
+	// ConflictPrompt prompts for how to resolve the conflicts in each file.
+	ConflictPrompt ConflictPolicy = "prompt"
+
+	// ConflictMarkers writes the local and upstream values of the conflicts
+	// between git-style conflict markers, merges the other upstream changes
+	// and fails with a ConflictMarkersError.
+	ConflictMarkers ConflictPolicy = "markers"
+)
+
+var ConflictPolicies = []string{
+	string(ConflictUpstream), string(ConflictLocal), string(ConflictSkip),
+	string(ConflictAbort), string(ConflictPrompt), string(ConflictMarkers),
+}
+
+// Conflict markers delimit the local and upstream values of conflicts.
+const (
+	localMarker     = "<<<<<<< local\n"
+	separatorMarker = "=======\n"
+	upstreamMarker  = ">>>>>>> upstream\n"
+)
+
+// ConflictMarkersError is returned by updates with ConflictMarkers which
+// wrote conflict markers.  The package is otherwise updated.
+type ConflictMarkersError struct {
+	// Files are the files with conflict markers, relative to the package
+	Files []string
+}
+
+func (e *ConflictMarkersError) Error() string {
+	return fmt.Sprintf("update has conflicts -- resolve the conflict markers in:\n  %s",
+		strings.Join(e.Files, "\n  "))
+}
+
+// orNil returns e as an error, or nil if e is nil.
+func (e *ConflictMarkersError) orNil() error {
+	if e == nil {
+		return nil
+	}
+	return e
+}
+
+// splitConflictMarkers returns err if it is a ConflictMarkersError, or
+// otherwise the error.
+func splitConflictMarkers(err error) (*ConflictMarkersError, error) {
+	if markers, ok := err.(*ConflictMarkersError); ok {
+		return markers, nil
+	}
+	return nil, err
+}
+
+// resolution is how the conflicts in a file are resolved
+type resolution string
+
+const (
+	resolveUpstream resolution = "upstream"
+	resolveLocal    resolution = "local"
+	resolveSkip     resolution = "skip"
+	resolveEdit     resolution = "edit"
+	resolveMarkers  resolution = "markers"
+)
+
+// conflictResolver resolves the conflicts of a resource merge.
+type conflictResolver struct {
+	options UpdateOptions
+
+	// conflicts are the conflicts in each file
+	conflicts map[string][]merge.Conflict
+
+	// files are the files with conflicts, in order
+	files []string
+
+	// resolutions are the resolutions for each file
+	resolutions map[string]resolution
+
+	// protected are the fields and resources marked to keep their local
+	// values which were changed upstream, in each file
+	protected map[string][]merge.Conflict
+
+	// protectedFiles are the files with protected fields, in order
+	protectedFiles []string
+
+	// upstream are the conflicts resolved to the upstream value by a
+	// conflict rule
+	upstream []merge.Conflict
+
+	// local is the content of each file before the merge, or nil if the
+	// file didn't exist
+	local map[string][]byte
+}
+
+// newConflictResolver detects the conflicts between the original, updated
+// and local packages and chooses how to resolve them.  Fields and resources
+// marked to keep their local values aren't conflicts, they are always
+// restored.  It must be called before the packages are merged.
+func newConflictResolver(options UpdateOptions, originalPath, updatedPath string) (
+	*conflictResolver, error) {
+	r := &conflictResolver{
+		options:     options,
+		conflicts:   map[string][]merge.Conflict{},
+		resolutions: map[string]resolution{},
+		protected:   map[string][]merge.Conflict{},
+		local:       map[string][]byte{},
+	}
+	protected, err := merge.Protected(originalPath, updatedPath, options.PackagePath)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range protected {
+		r.protect(c)
+	}
+
+	rules := append(append([]kptfile.ConflictRule{}, options.ConflictRules...),
+		options.KptFile.ConflictRules...)
+	for _, rule := range rules {
+		if rule.Prefer != kptfile.PreferLocal && rule.Prefer != kptfile.PreferUpstream {
+			return nil, errors.Errorf("conflict rule for %s must prefer %s or %s, not %q",
+				strings.Join(rule.Fields, ","), kptfile.PreferLocal, kptfile.PreferUpstream,
+				rule.Prefer)
+		}
+	}
+
+	conflicts, err := merge.Conflicts(originalPath, updatedPath, options.PackagePath)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range conflicts {
+		if r.isProtected(c) {
+			continue
+		}
+		switch matchRule(rules, c) {
+		case kptfile.PreferLocal:
+			r.protect(c)
+			continue
+		case kptfile.PreferUpstream:
+			r.upstream = append(r.upstream, c)
+			continue
+		}
+		if r.conflicts[c.File] == nil {
+			r.files = append(r.files, c.File)
+		}
+		r.conflicts[c.File] = append(r.conflicts[c.File], c)
+	}
+	if err := r.readLocal(r.protectedFiles); err != nil {
+		return nil, err
+	}
+	if len(r.files) == 0 {
+		return r, nil
+	}
+
+	switch options.OnConflict {
+	case ConflictUpstream, "":
+		return r, nil
+	case ConflictAbort:
+		return nil, errors.Errorf("update has conflicts -- "+
+			"resolve them with --on-conflict:\n%s", r.describe("  "))
+	case ConflictLocal, ConflictSkip, ConflictMarkers:
+		for _, f := range r.files {
+			r.resolutions[f] = resolution(options.OnConflict)
+		}
+	case ConflictPrompt:
+		if err := r.prompt(); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errors.Errorf("unrecognized conflict policy %q -- must be one of: %s",
+			options.OnConflict, strings.Join(ConflictPolicies, ","))
+	}
+
+	if err := r.readLocal(r.files); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// protect restores the local value of c after the merge.
+func (r *conflictResolver) protect(c merge.Conflict) {
+	if r.protected[c.File] == nil {
+		r.protectedFiles = append(r.protectedFiles, c.File)
+	}
+	r.protected[c.File] = append(r.protected[c.File], c)
+}
+
+// matchRule returns the preference of the first rule matching c, or "" if
+// no rule matches.
+func matchRule(rules []kptfile.ConflictRule, c merge.Conflict) kptfile.ConflictPreference {
+	for _, rule := range rules {
+		if len(rule.Kinds) > 0 && !containsString(rule.Kinds, c.Kind()) {
+			continue
+		}
+		for _, f := range rule.Fields {
+			if c.MatchField(f) {
+				return rule.Prefer
+			}
+		}
+	}
+	return ""
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// readLocal reads the content of files before the merge.
+func (r *conflictResolver) readLocal(files []string) error {
+	for _, f := range files {
+		if _, found := r.local[f]; found {
+			continue
+		}
+		b, err := ioutil.ReadFile(filepath.Join(r.options.PackagePath, f))
+		if err != nil && !os.IsNotExist(err) {
+			return errors.Wrap(err)
+		}
+		r.local[f] = b
+	}
+	return nil
+}
+
+// isProtected returns true if the conflict is in a protected resource or
+// field.
+func (r *conflictResolver) isProtected(c merge.Conflict) bool {
+	for _, p := range r.protected[c.File] {
+		if p.Resource != c.Resource || len(p.Field) > len(c.Field) {
+			continue
+		}
+		found := true
+		for i := range p.Field {
+			if p.Field[i] != c.Field[i] {
+				found = false
+				break
+			}
+		}
+		if found {
+			return true
+		}
+	}
+	return false
+}
+
+// describe returns the conflicts in each file, one per line.
+func (r *conflictResolver) describe(indent string) string {
+	var b strings.Builder
+	for _, f := range r.files {
+		fmt.Fprintf(&b, "%s%s:\n", indent, f)
+		for _, c := range r.conflicts[f] {
+			fmt.Fprintf(&b, "%s  %s\n", indent, c)
+		}
+	}
+	return b.String()
+}
+
+// prompt reads the resolution for each file from the options Input.
+func (r *conflictResolver) prompt() error {
+	in := bufio.NewReader(r.options.Input)
+	for _, f := range r.files {
+		fmt.Fprintf(r.options.Output, "conflicts in %q:\n", f)
+		for _, c := range r.conflicts[f] {
+			fmt.Fprintf(r.options.Output, "  %s\n", c)
+		}
+		for r.resolutions[f] == "" {
+			fmt.Fprint(r.options.Output,
+				"keep [l]ocal, accept [u]pstream, [e]dit or [s]kip file? ")
+			line, err := in.ReadString('\n')
+			if err != nil && (err != io.EOF || line == "") {
+				return errors.Errorf("no resolution for the conflicts in %q: %v", f, err)
+			}
+			switch strings.ToLower(strings.TrimSpace(line)) {
+			case "l", "local":
+				r.resolutions[f] = resolveLocal
+			case "u", "upstream":
+				r.resolutions[f] = resolveUpstream
+			case "e", "edit":
+				r.resolutions[f] = resolveEdit
+			case "s", "skip":
+				r.resolutions[f] = resolveSkip
+			}
+		}
+	}
+	return nil
+}
+
+// resolve restores the protected fields and resolves the conflicts in the
+// merged package.
+func (r *conflictResolver) resolve() error {
+	var kept []merge.Conflict
+	for _, f := range r.protectedFiles {
+		if r.resolutions[f] == resolveSkip {
+			// the whole file is restored
+			continue
+		}
+		path := filepath.Join(r.options.PackagePath, f)
+		if err := merge.KeepLocal(path, r.local[f], r.protected[f]); err != nil {
+			return err
+		}
+		kept = append(kept, r.protected[f]...)
+	}
+	if r.options.Output != nil {
+		for _, c := range kept {
+			fmt.Fprintf(r.options.Output, "kept local %s in %s\n", c, c.File)
+		}
+		for _, c := range r.upstream {
+			fmt.Fprintf(r.options.Output, "took upstream %s in %s\n", c, c.File)
+		}
+	}
+
+	var markers []string
+	for _, f := range r.files {
+		path := filepath.Join(r.options.PackagePath, f)
+		switch r.resolutions[f] {
+		case resolveLocal:
+			if err := merge.KeepLocal(path, r.local[f], r.conflicts[f]); err != nil {
+				return err
+			}
+		case resolveSkip:
+			if err := restoreFile(path, r.local[f]); err != nil {
+				return err
+			}
+		case resolveEdit:
+			if err := r.edit(path); err != nil {
+				return err
+			}
+		case resolveMarkers:
+			if err := writeMarkers(path, r.local[f], r.conflicts[f]); err != nil {
+				return err
+			}
+			markers = append(markers, f)
+		}
+	}
+	if len(markers) > 0 {
+		return &ConflictMarkersError{Files: markers}
+	}
+	return nil
+}
+
+// writeMarkers writes the merged file at path with the lines which differ
+// between keeping the local and taking the upstream values of conflicts
+// between conflict markers.
+func writeMarkers(path string, local []byte, conflicts []merge.Conflict) error {
+	upstream, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err)
+	}
+	if err := merge.KeepLocal(path, local, conflicts); err != nil {
+		return err
+	}
+	kept, err := ioutil.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err)
+	}
+
+	a, b := splitLines(string(kept)), splitLines(string(upstream))
+	var out strings.Builder
+	m := difflib.NewMatcherWithJunk(a, b, false, nil)
+	for _, op := range m.GetOpCodes() {
+		if op.Tag == 'e' {
+			out.WriteString(strings.Join(a[op.I1:op.I2], ""))
+			continue
+		}
+		out.WriteString(localMarker)
+		out.WriteString(strings.Join(a[op.I1:op.I2], ""))
+		out.WriteString(separatorMarker)
+		out.WriteString(strings.Join(b[op.J1:op.J2], ""))
+		out.WriteString(upstreamMarker)
+	}
+	return errors.Wrap(ioutil.WriteFile(path, []byte(out.String()), 0600))
+}
+
+// splitLines splits s into lines, each ending with a newline.
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(s, "\n")
+	if lines[len(lines)-1] == "" {
+		return lines[:len(lines)-1]
+	}
+	lines[len(lines)-1] += "\n"
+	return lines
+}
+
+// restoreFile restores the content of the file at path before the merge.
+func restoreFile(path string, content []byte) error {
+	if content == nil {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return errors.Wrap(err)
+		}
+		return nil
+	}
+	return errors.Wrap(ioutil.WriteFile(path, content, 0600))
+}
+
+// edit opens the merged file at path in the editor from KPT_EDITOR or
+// EDITOR, defaulting to vi.
+func (r *conflictResolver) edit(path string) error {
+	editor := os.Getenv("KPT_EDITOR")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+	args := append(strings.Fields(editor), path)
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return errors.Errorf("failed to edit %q: %v", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package update
+
+import (
+	"path/filepath"
+
+	"github.com/GoogleContainerTools/kpt/internal/util/digest"
+	"github.com/GoogleContainerTools/kpt/pkg/kptfile/kptfileutil"
+	"sigs.k8s.io/kustomize/kyaml/errors"
+)
+
+// Digest returns the digest of the contents of the package at path,
+// excluding its Kptfile and independent subpackages.
+func Digest(path string) (string, error) {
+	files, err := Files(path)
+	if err != nil {
+		return "", err
+	}
+	return digest.Sum(files), nil
+}
+
+// Files returns the digests of the files of the package at path, keyed by
+// their slash separated path relative to it, excluding its Kptfile and
+// independent subpackages.
+func Files(path string) (map[string]string, error) {
+	subpackages, err := Subpackages(path)
+	if err != nil {
+		return nil, err
+	}
+	return digest.Files(path, subpackages...)
+}
+
+// RecordDigest records the digest of the contents of the package at path in
+// its Kptfile, so that later changes made outside of kpt can be detected.
+func RecordDigest(path string) error {
+	d, err := Digest(path)
+	if err != nil {
+		return err
+	}
+	k, err := kptfileutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if k.Upstream.Digest == d {
+		return nil
+	}
+	k.Upstream.Digest = d
+	return errors.Wrap(kptfileutil.WriteFile(path, k))
+}
+
+// RecordDigests records the digest of the package at path and, recursively,
+// of its independent subpackages.
+func RecordDigests(path string) error {
+	if err := RecordDigest(path); err != nil {
+		return err
+	}
+	subpackages, err := Subpackages(path)
+	if err != nil {
+		return err
+	}
+	for _, s := range subpackages {
+		if err := RecordDigests(filepath.Join(path, s)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
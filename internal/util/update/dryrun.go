@@ -0,0 +1,189 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package update
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/GoogleContainerTools/kpt/pkg/kptfile"
+	"github.com/pmezard/go-difflib/difflib"
+	"sigs.k8s.io/kustomize/kyaml/errors"
+)
+
+// DryRunFormat controls how the changes of a dry-run update are printed.
+type DryRunFormat string
+
+const (
+	// DryRunDiff prints a unified diff of each changed file.
+	DryRunDiff DryRunFormat = "diff"
+
+	// DryRunSummary prints the path of each changed file.
+	DryRunSummary DryRunFormat = "summary"
+
+	// DryRunJSON prints the changed files and their diffs as json.
+	DryRunJSON DryRunFormat = "json"
+)
+
+var DryRunFormats = []string{string(DryRunDiff), string(DryRunSummary), string(DryRunJSON)}
+
+// ChangeType is the type of change made to a file by an update.
+type ChangeType string
+
+const (
+	Added    ChangeType = "added"
+	Modified ChangeType = "modified"
+	Removed  ChangeType = "removed"
+)
+
+// FileChange is a change made to a file in the package by an update.
+type FileChange struct {
+	// Path is the path to the file, relative to the package
+	Path string `json:"path"`
+
+	// Type is the type of the change
+	Type ChangeType `json:"type"`
+
+	// Diff is the unified diff of the file
+	Diff string `json:"diff,omitempty"`
+}
+
+// DiffPackages returns the changes to the files of the package at fromPath
+// which would make it match the package at toPath.
+func DiffPackages(fromPath, toPath string) ([]FileChange, error) {
+	from, err := packageFiles(fromPath)
+	if err != nil {
+		return nil, err
+	}
+	to, err := packageFiles(toPath)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := map[string]bool{}
+	for p := range from {
+		paths[p] = true
+	}
+	for p := range to {
+		paths[p] = true
+	}
+	var sorted []string
+	for p := range paths {
+		sorted = append(sorted, p)
+	}
+	sort.Strings(sorted)
+
+	var changes []FileChange
+	for _, p := range sorted {
+		a, inFrom := from[p]
+		b, inTo := to[p]
+		change := FileChange{Path: p, Type: Modified}
+		switch {
+		case !inFrom:
+			change.Type = Added
+		case !inTo:
+			change.Type = Removed
+		case a == b:
+			continue
+		}
+		fromFile, toFile := "a/"+p, "b/"+p
+		if !inFrom {
+			fromFile = "/dev/null"
+		}
+		if !inTo {
+			toFile = "/dev/null"
+		}
+		change.Diff, err = difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+			A:        difflib.SplitLines(a),
+			B:        difflib.SplitLines(b),
+			FromFile: fromFile,
+			ToFile:   toFile,
+			Context:  3,
+		})
+		if err != nil {
+			return nil, errors.Wrap(err)
+		}
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
+
+// packageFiles returns the contents of the files under path, keyed by their
+// path relative to path.
+func packageFiles(path string) (map[string]string, error) {
+	files := map[string]string{}
+	err := filepath.Walk(path, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return errors.Wrap(err)
+		}
+		if info.IsDir() {
+			if info.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		// setter histories are local to the package
+		if info.Name() == kptfile.HistoryFileName {
+			return nil
+		}
+		rel, err := filepath.Rel(path, p)
+		if err != nil {
+			return errors.Wrap(err)
+		}
+		b, err := ioutil.ReadFile(p)
+		if err != nil {
+			return errors.Wrap(err)
+		}
+		files[filepath.ToSlash(rel)] = string(b)
+		return nil
+	})
+	return files, err
+}
+
+// PrintChanges writes the changes to w in the given format.
+func PrintChanges(w io.Writer, format DryRunFormat, changes []FileChange) error {
+	switch format {
+	case DryRunDiff, "":
+		for _, c := range changes {
+			fmt.Fprint(w, c.Diff)
+		}
+	case DryRunSummary:
+		counts := map[ChangeType]int{}
+		for _, c := range changes {
+			fmt.Fprintf(w, "%-9s %s\n", c.Type, c.Path)
+			counts[c.Type]++
+		}
+		fmt.Fprintf(w, "%d added, %d modified, %d removed\n",
+			counts[Added], counts[Modified], counts[Removed])
+	case DryRunJSON:
+		if changes == nil {
+			changes = []FileChange{}
+		}
+		b, err := json.MarshalIndent(changes, "", "  ")
+		if err != nil {
+			return errors.Wrap(err)
+		}
+		fmt.Fprintln(w, string(b))
+	default:
+		return errors.Errorf("unrecognized dry-run output %q -- must be one of: %v",
+			format, DryRunFormats)
+	}
+	return nil
+}
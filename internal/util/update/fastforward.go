@@ -16,9 +16,11 @@ package update
 
 import (
 	"fmt"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strings"
 
-	"github.com/GoogleContainerTools/kpt/internal/gitutil"
 	"github.com/GoogleContainerTools/kpt/internal/util/get"
 	"github.com/GoogleContainerTools/kpt/internal/util/git"
 	"github.com/GoogleContainerTools/kpt/pkg/kptfile"
@@ -43,37 +45,59 @@ func (u FastForwardUpdater) Update(options UpdateOptions) error {
 	g := options.KptFile.Upstream.Git
 	g.Ref = options.ToRef
 	g.Repo = options.ToRepo
-	if err := errorIfChanged(g, options.PackagePath); err != nil {
-		return err
+	if err := errorIfChanged(g, options.PackagePath, options.Subpackages, options.GitConfig); err != nil {
+		if _, ok := err.(DiffError); ok {
+			return err
+		}
+		return originalError(options, err)
 	}
 
-	// refetch the package
-	return get.Command{Destination: options.PackagePath, Clean: true, Git: g}.Run()
+	// refetch the package, keeping its setter history
+	history := filepath.Join(options.PackagePath, kptfile.HistoryFileName)
+	b, err := ioutil.ReadFile(history)
+	if err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err)
+	}
+	err = get.Command{Destination: options.PackagePath, Clean: true, Git: g,
+		GitConfig: options.GitConfig}.Run()
+	if err != nil {
+		return err
+	}
+	if b == nil {
+		return nil
+	}
+	return errors.Wrap(ioutil.WriteFile(history, b, 0600))
 }
 
 // errorIfChanged returns an error if the package at pkgPath has changed from the upstream
-// source referenced by g.
-func errorIfChanged(g kptfile.Git, pkgPath string) error {
+// source referenced by g, fetched with the git config.  The subpackages are not compared.
+func errorIfChanged(g kptfile.Git, pkgPath string, subpackages []string, config map[string]string) error {
 	original := &git.RepoSpec{
-		OrgRepo: g.Repo,
-		Path:    g.Directory,
-		Ref:     g.Commit,
+		OrgRepo:   g.Repo,
+		Path:      g.Directory,
+		Ref:       g.Commit,
+		GitConfig: config,
 	}
-	defaultRef, err := gitutil.DefaultRef(g.Repo)
-	if err != nil {
-		return err
-	}
-	err = get.ClonerUsingGitExec(original, defaultRef)
+	err := get.CloneUpstream(original)
 	if err != nil {
 		return errors.Errorf("failed cloning git repo: %v", err)
 	}
 	defer os.RemoveAll(original.Dir)
+	if err := excludeSubpackages(original.AbsPath(), subpackages); err != nil {
+		return err
+	}
 	diff, err := copyutil.Diff(original.AbsPath(), pkgPath)
 	if err != nil {
 		return errors.Errorf("failed to compare local package to original source: %v", err)
 	}
 
 	diff = diff.Difference(kptfileSet)
+	for _, p := range diff.List() {
+		// setter histories are local to the package
+		if filepath.Base(p) == kptfile.HistoryFileName {
+			delete(diff, p)
+		}
+	}
 	if diff.Len() > 0 {
 		return DiffError(fmt.Sprintf(
 			"local package files have been modified: %v.\n  use a different update --strategy.",
@@ -82,6 +106,50 @@ func errorIfChanged(g kptfile.Git, pkgPath string) error {
 	return nil
 }
 
+// LocalChanges returns the paths of the files of the package at pkgPath
+// which differ from the upstream commit referenced by g, sorted.  Unlike
+// errorIfChanged it doesn't print the changes.  The Kptfile, and the
+// subpackages, are not compared.
+func LocalChanges(g kptfile.Git, pkgPath string, subpackages []string) ([]string, error) {
+	original := &git.RepoSpec{
+		OrgRepo: g.Repo,
+		Path:    g.Directory,
+		Ref:     g.Commit,
+	}
+	err := get.CloneUpstream(original)
+	if err != nil {
+		return nil, errors.Errorf("failed cloning git repo: %v", err)
+	}
+	defer os.RemoveAll(original.Dir)
+	if err := excludeSubpackages(original.AbsPath(), subpackages); err != nil {
+		return nil, err
+	}
+	diff, err := DiffPackages(original.AbsPath(), pkgPath)
+	if err != nil {
+		return nil, errors.Errorf("failed to compare local package to original source: %v", err)
+	}
+
+	var changes []string
+	for _, c := range diff {
+		if c.Path != kptfile.KptFileName && !underSubpackage(c.Path, subpackages) {
+			changes = append(changes, c.Path)
+		}
+	}
+	return changes, nil
+}
+
+// underSubpackage returns true if the file at path, relative to the package,
+// is part of one of the subpackages.
+func underSubpackage(path string, subpackages []string) bool {
+	for _, s := range subpackages {
+		s = filepath.ToSlash(s)
+		if path == s || strings.HasPrefix(path, s+"/") {
+			return true
+		}
+	}
+	return false
+}
+
 // DiffError is returned if the local package and upstream package contents do not match.
 type DiffError string
 
@@ -19,7 +19,6 @@ import (
 	"fmt"
 	"io"
 	"os"
-	"path"
 	"strings"
 
 	"github.com/GoogleContainerTools/kpt/internal/gitutil"
@@ -43,14 +42,22 @@ type GitPatchUpdater struct {
 	// gitRunner is used to run git commands
 	gitRunner *gitutil.GitRunner
 
-	// packageRef is the RemoteDirectory/ToRef -- for sub directory versioning
+	// packageRef is the tag named by the tag template for ToRef, e.g.
+	// RemoteDirectory/ToRef -- for sub directory versioning
 	packageRef string
 }
 
 func (u GitPatchUpdater) Update(options UpdateOptions) error {
 	u.UpdateOptions = options
-	u.packageRef = path.Join(strings.TrimLeft(u.KptFile.Upstream.Git.Directory, "/"),
-		u.ToRef)
+	tag, err := gitutil.PackageTag(u.KptFile.Upstream.Git.TagTemplate,
+		u.KptFile.Upstream.Git.Directory, u.ToRef)
+	if err != nil {
+		return err
+	}
+	u.packageRef = u.ToRef
+	if tag != "" {
+		u.packageRef = tag
+	}
 	if err := u.calculatePatch(); err != nil {
 		return err
 	}
@@ -75,14 +82,17 @@ func (u GitPatchUpdater) Update(options UpdateOptions) error {
 func (u *GitPatchUpdater) calculatePatch() error {
 	var err error
 
+	// the cached repo is reset while calculating the patch
+	defer gitutil.LockRepoCache(u.KptFile.Upstream.Git.Repo)()
+
 	optional := []string{u.ToRef}
 	if u.packageRef != u.ToRef {
 		optional = append(optional, u.ToRef)
 	}
-	if u.gitRunner, err = gitutil.NewUpstreamGitRunner(
+	if u.gitRunner, err = gitutil.NewUpstreamGitRunnerWithConfig(
 		u.KptFile.Upstream.Git.Repo, u.KptFile.Upstream.Git.Directory,
 		[]string{u.UpdateOptions.KptFile.Upstream.Git.Commit},
-		optional,
+		optional, u.GitConfig,
 	); err != nil {
 		return err
 	}
@@ -131,7 +141,7 @@ func (u *GitPatchUpdater) patchLocalPackage() error {
 			fmt.Fprintf(os.Stderr, "cleanup remote failed: %v\n", err)
 		}
 	}()
-	defaultRef, err := gitutil.DefaultRef(u.UpdateOptions.ToRepo)
+	defaultRef, err := gitutil.DefaultRefWithConfig(u.UpdateOptions.ToRepo, u.GitConfig)
 	if err != nil {
 		return err
 	}
@@ -169,10 +179,23 @@ func (u *GitPatchUpdater) hardResetSourceFiles() error {
 	if err != nil {
 		// no upstream Kptfile, use our local copy -- use the local Kptfile value.
 		pf = u.UpdateOptions.KptFile
+		if u.RelativeRepo != "" {
+			pf.Upstream.Git.Repo = u.RelativeRepo
+		}
+		if u.RelocatedFrom != "" {
+			pf.Upstream.Git.Repo = u.RelocatedFrom
+		}
 	} else {
 		// found upstream Kptfile, use the upstream copy, but set the `upstream` field
 		// since it is owned locally
 		pf.Upstream = u.UpdateOptions.KptFile.Upstream
+		if u.RelativeRepo != "" {
+			pf.Upstream.Git.Repo = u.RelativeRepo
+		}
+		if u.RelocatedFrom != "" {
+			// the local Kptfile still records the former repo
+			pf.Upstream.Git.Repo = u.RelocatedFrom
+		}
 		// also keep the local OpenAPI which may have been modified.
 		err = pf.MergeOpenAPI(u.UpdateOptions.KptFile, u.UpdateOptions.KptFile)
 		if err != nil {
@@ -218,6 +241,11 @@ func (u *GitPatchUpdater) commitTargetFiles() error {
 	updatedKptfile.Upstream.Git.Commit = u.toCommit           // set the commit we are updating to
 	updatedKptfile.Upstream.Git.Ref = u.UpdateOptions.ToRef   // set the ref we are updating to
 	updatedKptfile.Upstream.Git.Repo = u.UpdateOptions.ToRepo // set the repo we are using for the update
+	updatedKptfile.Upstream.Git.TagTemplate = u.KptFile.Upstream.Git.TagTemplate
+	if u.RelativeRepo != "" && u.ToRepo == u.KptFile.Upstream.Git.Repo {
+		// keep the repo relative if it hasn't changed
+		updatedKptfile.Upstream.Git.Repo = u.RelativeRepo
+	}
 	if err := kptfileutil.WriteFile(u.gitRunner.Dir, updatedKptfile); err != nil {
 		return errors.Errorf("update failed: unable to write Kptfile: %q", err)
 	}
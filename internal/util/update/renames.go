@@ -0,0 +1,205 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package update
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/GoogleContainerTools/kpt/pkg/kptfile"
+	"sigs.k8s.io/kustomize/kyaml/errors"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/sets"
+)
+
+// renameSimilarity is how similar a file added upstream must be to a file
+// deleted upstream to be considered a rename of it.
+const renameSimilarity = 0.5
+
+// Rename is a file renamed upstream.
+type Rename struct {
+	// From and To are the slash separated paths of the file, relative to
+	// the package
+	From, To string
+}
+
+// DetectRenames returns the files of the original package which were renamed
+// in the updated package: files which were deleted, paired with the most
+// similar file which was added.  Resource files are similar if they contain
+// the same resources, other files if they have the same lines.
+func DetectRenames(originalDir, updatedDir string) ([]Rename, error) {
+	originalFiles, err := packageFiles(originalDir)
+	if err != nil {
+		return nil, err
+	}
+	updatedFiles, err := packageFiles(updatedDir)
+	if err != nil {
+		return nil, err
+	}
+	deleted, added := renameCandidates(originalFiles, updatedFiles), renameCandidates(updatedFiles, originalFiles)
+	if len(deleted) == 0 || len(added) == 0 {
+		return nil, nil
+	}
+
+	type candidate struct {
+		from, to   string
+		similarity float64
+	}
+	var candidates []candidate
+	for _, from := range deleted {
+		fromKrm, err := isKrmFile(from)
+		if err != nil {
+			return nil, err
+		}
+		for _, to := range added {
+			toKrm, err := isKrmFile(to)
+			if err != nil {
+				return nil, err
+			}
+			if fromKrm != toKrm {
+				continue
+			}
+			if s := similarity(originalFiles[from], updatedFiles[to], fromKrm); s >= renameSimilarity {
+				candidates = append(candidates, candidate{from: from, to: to, similarity: s})
+			}
+		}
+	}
+	// pair the most similar files first
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].similarity > candidates[j].similarity
+	})
+
+	var renames []Rename
+	paired := sets.String{}
+	for _, c := range candidates {
+		if paired.Has(c.from) || paired.Has(c.to) {
+			continue
+		}
+		paired.Insert(c.from, c.to)
+		renames = append(renames, Rename{From: c.from, To: c.to})
+	}
+	sort.Slice(renames, func(i, j int) bool { return renames[i].From < renames[j].From })
+	return renames, nil
+}
+
+// ApplyRenames renames the files of each of dirs, so local changes to files
+// renamed upstream are merged into the file at its new path.  Renames are
+// skipped for dirs which don't have the file, or already have the new path.
+func ApplyRenames(renames []Rename, dirs ...string) error {
+	for _, r := range renames {
+		for _, dir := range dirs {
+			from := filepath.Join(dir, filepath.FromSlash(r.From))
+			to := filepath.Join(dir, filepath.FromSlash(r.To))
+			if !fileExists(from) || fileExists(to) {
+				continue
+			}
+			if err := os.MkdirAll(filepath.Dir(to), 0700); err != nil {
+				return errors.Wrap(err)
+			}
+			if err := os.Rename(from, to); err != nil {
+				return errors.Wrap(err)
+			}
+		}
+	}
+	return nil
+}
+
+// renameCandidates returns the files of a, other than Kptfiles, which aren't
+// in b.
+func renameCandidates(a, b map[string]string) []string {
+	var files []string
+	for f := range a {
+		if _, found := b[f]; !found && path.Base(f) != kptfile.KptFileName {
+			files = append(files, f)
+		}
+	}
+	sort.Strings(files)
+	return files
+}
+
+// similarity returns the similarity of the file contents a and b, from 0
+// to 1.
+func similarity(a, b string, krm bool) float64 {
+	if krm {
+		ia, errA := resourceIDs(a)
+		ib, errB := resourceIDs(b)
+		// files which can't be parsed are compared by their lines
+		if errA == nil && errB == nil && (len(ia) > 0 || len(ib) > 0) {
+			return overlap(ia, ib)
+		}
+	}
+	return overlap(lines(a), lines(b))
+}
+
+// lines returns the non-blank lines of s.
+func lines(s string) []string {
+	var l []string
+	for _, line := range strings.Split(s, "\n") {
+		if strings.TrimSpace(line) != "" {
+			l = append(l, line)
+		}
+	}
+	return l
+}
+
+// resourceIDs returns the identifiers of the resources in s.
+func resourceIDs(s string) ([]string, error) {
+	nodes, err := (&kio.ByteReader{Reader: strings.NewReader(s), OmitReaderAnnotations: true}).Read()
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	for _, n := range nodes {
+		meta, err := n.GetMeta()
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, fmt.Sprintf("%s/%s/%s/%s", meta.APIVersion, meta.Kind, meta.Namespace, meta.Name))
+	}
+	return ids, nil
+}
+
+// overlap returns the fraction of the elements of a and b which are common
+// to both.
+func overlap(a, b []string) float64 {
+	if len(a)+len(b) == 0 {
+		return 1
+	}
+	counts := map[string]int{}
+	for _, s := range a {
+		counts[s]++
+	}
+	common := 0
+	for _, s := range b {
+		if counts[s] > 0 {
+			counts[s]--
+			common++
+		}
+	}
+	return 2 * float64(common) / float64(len(a)+len(b))
+}
+
+// reverseRenames returns renames undoing renames.
+func reverseRenames(renames []Rename) []Rename {
+	var reversed []Rename
+	for _, r := range renames {
+		reversed = append(reversed, Rename{From: r.To, To: r.From})
+	}
+	return reversed
+}
@@ -0,0 +1,113 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package update_test
+
+import (
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt/internal/testutil"
+	"github.com/GoogleContainerTools/kpt/internal/testutil/pkgbuilder"
+	. "github.com/GoogleContainerTools/kpt/internal/util/update"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	renameConfigMap = `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: app-config
+data:
+  foo: bar
+`
+	renameReadme = `# app
+
+The app package.
+`
+)
+
+func TestCommand_Run_renames(t *testing.T) {
+	initial := pkgbuilder.NewPackage("app").
+		WithKptfile().
+		WithFile("cm.yaml", renameConfigMap).
+		WithFile("README.md", renameReadme)
+
+	// upstream moves the files, and changes the ConfigMap
+	updated := pkgbuilder.NewPackage("app").
+		WithKptfile().
+		WithSubPackages(
+			pkgbuilder.NewPackage("config").WithFile("cm.yaml", renameConfigMap+"  upstream: change\n"),
+			pkgbuilder.NewPackage("docs").WithFile("README.md", renameReadme))
+
+	// the local package changes the files at their old path
+	local := pkgbuilder.NewPackage("app").
+		WithKptfile().
+		WithFile("cm.yaml", renameConfigMap+"  local: change\n").
+		WithFile("README.md", renameReadme+"Local notes.\n")
+
+	g := &testutil.TestSetupManager{
+		T:               t,
+		UpstreamChanges: []testutil.Content{{Data: pkgbuilder.ExpandPkg(t, updated)}},
+		LocalChanges:    []testutil.Content{{Data: pkgbuilder.ExpandPkg(t, local)}},
+	}
+	defer g.Clean()
+	if !g.Init(pkgbuilder.ExpandPkg(t, initial)) {
+		return
+	}
+
+	err := Command{
+		Path:            g.UpstreamRepo.RepoName,
+		FullPackagePath: toAbsPath(t, g.UpstreamRepo.RepoName),
+		Strategy:        KResourceMerge,
+	}.Run()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	// the local changes follow the files to their new path
+	expected := pkgbuilder.NewPackage("app").
+		WithKptfile().
+		WithSubPackages(
+			pkgbuilder.NewPackage("config").
+				WithFile("cm.yaml", renameConfigMap+"  local: change\n  upstream: change\n"),
+			pkgbuilder.NewPackage("docs").WithFile("README.md", renameReadme+"Local notes.\n"))
+	if !g.AssertLocalDataEquals(pkgbuilder.ExpandPkg(t, expected)) {
+		t.FailNow()
+	}
+}
+
+func TestDetectRenames(t *testing.T) {
+	original := pkgbuilder.ExpandPkg(t, pkgbuilder.NewPackage("app").
+		WithKptfile().
+		WithResource(pkgbuilder.DeploymentResource).
+		WithFile("cm.yaml", renameConfigMap).
+		WithFile("README.md", renameReadme).
+		WithFile("NOTES.md", "unrelated\n"))
+	updated := pkgbuilder.ExpandPkg(t, pkgbuilder.NewPackage("app").
+		WithKptfile().
+		WithResource(pkgbuilder.DeploymentResource).
+		WithFile("CHANGELOG.md", "new file\n").
+		WithSubPackages(
+			pkgbuilder.NewPackage("config").WithFile("app-config.yaml", renameConfigMap+"  upstream: change\n"),
+			pkgbuilder.NewPackage("docs").WithFile("README.md", renameReadme+"More docs.\n")))
+
+	renames, err := DetectRenames(original, updated)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, []Rename{
+		{From: "README.md", To: "docs/README.md"},
+		{From: "cm.yaml", To: "config/app-config.yaml"},
+	}, renames)
+}
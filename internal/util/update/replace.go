@@ -25,5 +25,6 @@ type ReplaceUpdater struct{}
 func (u ReplaceUpdater) Update(options UpdateOptions) error {
 	options.KptFile.Upstream.Git.Ref = options.ToRef
 	options.KptFile.Upstream.Git.Repo = options.ToRepo
-	return get.Command{Destination: options.PackagePath, Clean: true, Git: options.KptFile.Upstream.Git}.Run()
+	return get.Command{Destination: options.PackagePath, Clean: true, Git: options.KptFile.Upstream.Git,
+		GitConfig: options.GitConfig}.Run()
 }
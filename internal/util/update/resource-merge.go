@@ -16,15 +16,16 @@ package update
 
 import (
 	"bytes"
+	"fmt"
 	"io/ioutil"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"strings"
 
-	"github.com/GoogleContainerTools/kpt/internal/gitutil"
 	"github.com/GoogleContainerTools/kpt/internal/util/get"
 	"github.com/GoogleContainerTools/kpt/internal/util/git"
+	"github.com/GoogleContainerTools/kpt/internal/util/merge"
+	kptopenapi "github.com/GoogleContainerTools/kpt/internal/util/openapi"
 	"github.com/GoogleContainerTools/kpt/pkg/kptfile"
 	"github.com/GoogleContainerTools/kpt/pkg/kptfile/kptfileutil"
 	"sigs.k8s.io/kustomize/kyaml/copyutil"
@@ -41,34 +42,83 @@ import (
 type ResourceMergeUpdater struct{}
 
 func (u ResourceMergeUpdater) Update(options UpdateOptions) error {
+	return u.update(options, func(originalPath, updatedPath, destPath string) error {
+		return filters.Merge3{
+			OriginalPath: originalPath,
+			UpdatedPath:  updatedPath,
+			DestPath:     destPath,
+			// TODO: Write a test to ensure this is set
+			MergeOnPath: true,
+		}.Merge()
+	})
+}
+
+// ResourceMerge3Updater updates a package the same way as ResourceMergeUpdater,
+// but merges the Resources in place so the local comments, field order and
+// formatting are preserved.
+type ResourceMerge3Updater struct{}
+
+func (u ResourceMerge3Updater) Update(options UpdateOptions) error {
+	return ResourceMergeUpdater{}.update(options, func(originalPath, updatedPath, destPath string) error {
+		return merge.Merge3{
+			OriginalPath: originalPath,
+			UpdatedPath:  updatedPath,
+			DestPath:     destPath,
+		}.Merge()
+	})
+}
+
+// update fetches the original and updated source packages, merges the
+// Kptfiles and uses mergeResources to merge the Resources into the local
+// package.
+func (u ResourceMergeUpdater) update(options UpdateOptions,
+	mergeResources func(originalPath, updatedPath, destPath string) error) error {
 	g := options.KptFile.Upstream.Git
 	g.Ref = options.ToRef
 	g.Repo = options.ToRepo
 
-	defaultRef, err := gitutil.DefaultRef(g.Repo)
-	if err != nil {
-		return err
-	}
-
 	// get the original repo
-	original := &git.RepoSpec{OrgRepo: g.Repo, Path: g.Directory, Ref: g.Commit}
-	if err := get.ClonerUsingGitExec(original, defaultRef); err != nil {
-		return errors.Errorf("failed to clone git repo: original source: %v", err)
+	original := &git.RepoSpec{OrgRepo: g.Repo, Path: g.Directory, Ref: g.Commit,
+		GitConfig: options.GitConfig}
+	if err := get.CloneUpstream(original); err != nil {
+		return originalError(options, errors.Errorf("failed to clone git repo: original source: %v", err))
 	}
 	defer os.RemoveAll(original.AbsPath())
 
 	// get the updated repo
-	updated := &git.RepoSpec{OrgRepo: options.ToRepo, Path: g.Directory, Ref: options.ToRef}
-	if err := get.ClonerUsingGitExec(updated, defaultRef); err != nil {
+	updated := &git.RepoSpec{OrgRepo: options.ToRepo, Path: g.Directory, Ref: options.ToRef,
+		TagTemplate: g.TagTemplate, GitConfig: options.GitConfig}
+	if err := get.CloneUpstream(updated); err != nil {
 		return errors.Errorf("failed to clone git repo: updated source: %v", err)
 	}
 	defer os.RemoveAll(updated.AbsPath())
 
-	kf, err := u.updatedKptfile(updated.AbsPath(), original.AbsPath(), options)
+	// the independent subpackages are merged against their own upstream
+	if err := excludeSubpackages(original.AbsPath(), options.Subpackages); err != nil {
+		return err
+	}
+	if err := excludeSubpackages(updated.AbsPath(), options.Subpackages); err != nil {
+		return err
+	}
+
+	// the resources are merged using the schemas of their custom resources
+	// bundled in the packages
+	for _, p := range []string{updated.AbsPath(), options.PackagePath} {
+		if err := kptopenapi.AddPackageTreeSchemas(p); err != nil {
+			return err
+		}
+	}
+
+	kf, err := u.updatedKptfile(updated, original.AbsPath(), options)
 	if err != nil {
 		return err
 	}
 
+	// keep the local Kptfile to restore if the update is aborted
+	localKf, err := ioutil.ReadFile(filepath.Join(options.PackagePath, kptfile.KptFileName))
+	if err != nil {
+		return errors.Wrap(err)
+	}
 	if err := kptfileutil.WriteFile(options.PackagePath, kf); err != nil {
 		return err
 	}
@@ -87,33 +137,54 @@ func (u ResourceMergeUpdater) Update(options UpdateOptions) error {
 		return err
 	}
 
+	// local changes to files renamed upstream are merged into the renamed
+	// files
+	renames, err := DetectRenames(original.AbsPath(), updated.AbsPath())
+	if err != nil {
+		return err
+	}
+	if err := ApplyRenames(renames, original.AbsPath(), options.PackagePath); err != nil {
+		return err
+	}
+
+	conflicts, err := newConflictResolver(options, original.AbsPath(), updated.AbsPath())
+	if err != nil {
+		if restoreErr := ioutil.WriteFile(filepath.Join(options.PackagePath, kptfile.KptFileName),
+			localKf, 0600); restoreErr != nil {
+			return errors.Wrap(restoreErr)
+		}
+		if restoreErr := ApplyRenames(reverseRenames(renames), options.PackagePath); restoreErr != nil {
+			return restoreErr
+		}
+		return err
+	}
+	if options.Output != nil {
+		for _, r := range renames {
+			fmt.Fprintf(options.Output, "renamed %s to %s\n", r.From, r.To)
+		}
+	}
+
 	// merge the Resources: original + updated + dest => dest
-	err = filters.Merge3{
-		OriginalPath: original.AbsPath(),
-		UpdatedPath:  updated.AbsPath(),
-		DestPath:     options.PackagePath,
-		// TODO: Write a test to ensure this is set
-		MergeOnPath: true,
-	}.Merge()
+	err = mergeResources(original.AbsPath(), updated.AbsPath(), options.PackagePath)
 	if err != nil {
 		return err
 	}
 
-	return ReplaceNonKRMFiles(updated.AbsPath(), original.AbsPath(), options.PackagePath)
+	err = ReplaceNonKRMFiles(updated.AbsPath(), original.AbsPath(), options.PackagePath)
+	if err != nil {
+		return err
+	}
+	return conflicts.resolve()
 }
 
 // updatedKptfile returns a Kptfile to replace the existing local Kptfile as part of the update
-func (u ResourceMergeUpdater) updatedKptfile(updatedPath, originalPath string, options UpdateOptions) (
+func (u ResourceMergeUpdater) updatedKptfile(updated *git.RepoSpec, originalPath string, options UpdateOptions) (
 	kptfile.KptFile, error) {
-	cmd := exec.Command("git", "rev-parse", "--verify", "HEAD")
-	cmd.Dir = updatedPath
-	cmd.Env = os.Environ()
-	cmd.Stderr = os.Stderr
-	b, err := cmd.Output()
+	updatedPath := updated.AbsPath()
+	commit, err := get.ClonedCommit(updated)
 	if err != nil {
 		return kptfile.KptFile{}, err
 	}
-	commit := strings.TrimSpace(string(b))
 	updatedKf, err := kptfileutil.ReadFile(updatedPath)
 	if err != nil {
 		updatedKf, err = kptfileutil.ReadFile(options.PackagePath)
@@ -135,8 +206,11 @@ func (u ResourceMergeUpdater) updatedKptfile(updatedPath, originalPath string, o
 	updatedKf.Upstream.Git.Commit = commit
 	updatedKf.Upstream.Git.Ref = options.ToRef
 	updatedKf.Upstream.Git.Repo = options.ToRepo
+	updatedKf.UpstreamAliases = options.KptFile.UpstreamAliases
+	updatedKf.ConflictRules = options.KptFile.ConflictRules
 
-	// keep the local OpenAPI values
+	// keep the local OpenAPI values and conditions
+	updatedKf.MergeConditions(options.KptFile, originalKf)
 	err = updatedKf.MergeOpenAPI(options.KptFile, originalKf)
 	return updatedKf, err
 }
@@ -192,6 +266,7 @@ func MergeSubPackages(localRoot, updatedRoot, originalRoot string) error {
 			}
 		}
 
+		updatedKf.MergeConditions(localKf, originalKf)
 		err = updatedKf.MergeOpenAPI(localKf, originalKf)
 		if err != nil {
 			return err
@@ -342,6 +417,10 @@ func getSubDirsAndNonKrmFiles(root string) (sets.String, sets.String, error) {
 			}
 			return nil
 		}
+		// setter histories are local to the package, and are left as is
+		if info.Name() == kptfile.HistoryFileName {
+			return nil
+		}
 		isKrm, err := isKrmFile(path)
 		if err != nil {
 			return err
@@ -0,0 +1,121 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package update
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/GoogleContainerTools/kpt/internal/util/tmputil"
+	"github.com/GoogleContainerTools/kpt/pkg/kptfile"
+	"github.com/GoogleContainerTools/kpt/pkg/kptfile/kptfileutil"
+	"sigs.k8s.io/kustomize/kyaml/copyutil"
+	"sigs.k8s.io/kustomize/kyaml/errors"
+	"sigs.k8s.io/kustomize/kyaml/pathutil"
+)
+
+// Subpackages returns the paths, relative to path, of the independent
+// subpackages of the package at path -- the nested packages whose Kptfile
+// has its own upstream.  They are updated against their own upstream rather
+// than merged as part of the package.  Subpackages nested in an independent
+// subpackage are left to it.
+func Subpackages(path string) ([]string, error) {
+	dirs, err := pathutil.DirsWithFile(path, kptfile.KptFileName, true)
+	if err != nil {
+		return nil, err
+	}
+	var subpackages []string
+	for _, dir := range dirs {
+		rel, err := filepath.Rel(path, dir)
+		if err != nil {
+			return nil, errors.Wrap(err)
+		}
+		if rel == "." {
+			continue
+		}
+		k, err := kptfileutil.ReadFile(dir)
+		if err != nil {
+			return nil, err
+		}
+		if k.Upstream.Git.Repo != "" {
+			subpackages = append(subpackages, rel)
+		}
+	}
+	sort.Strings(subpackages)
+
+	var independent []string
+	for _, s := range subpackages {
+		if len(independent) > 0 &&
+			strings.HasPrefix(s, independent[len(independent)-1]+string(filepath.Separator)) {
+			continue
+		}
+		independent = append(independent, s)
+	}
+	return independent, nil
+}
+
+// excludeSubpackages removes the subpackages from the package at root, e.g. a
+// clone of the upstream, so they aren't merged as part of the package.
+func excludeSubpackages(root string, subpackages []string) error {
+	for _, s := range subpackages {
+		if err := os.RemoveAll(filepath.Join(root, s)); err != nil {
+			return errors.Wrap(err)
+		}
+	}
+	return nil
+}
+
+// stashSubpackages moves the subpackages out of the package at path while it
+// is updated.  The returned func moves them back, replacing any copies the
+// update wrote.
+func stashSubpackages(path string, subpackages []string) (func() error, error) {
+	if len(subpackages) == 0 {
+		return func() error { return nil }, nil
+	}
+	dir, err := tmputil.TempDir("kpt-subpackages-")
+	if err != nil {
+		return nil, err
+	}
+	stashed := 0
+	restore := func() error {
+		defer os.RemoveAll(dir)
+		for i, s := range subpackages[:stashed] {
+			p := filepath.Join(path, s)
+			if err := os.RemoveAll(p); err != nil {
+				return errors.Wrap(err)
+			}
+			if err := copyutil.CopyDir(filepath.Join(dir, strconv.Itoa(i)), p); err != nil {
+				return errors.Wrap(err)
+			}
+		}
+		return nil
+	}
+	for i, s := range subpackages {
+		p := filepath.Join(path, s)
+		if err := copyutil.CopyDir(p, filepath.Join(dir, strconv.Itoa(i))); err != nil {
+			_ = restore()
+			return nil, errors.Wrap(err)
+		}
+		stashed++
+		if err := os.RemoveAll(p); err != nil {
+			_ = restore()
+			return nil, errors.Wrap(err)
+		}
+	}
+	return restore, nil
+}
@@ -16,14 +16,22 @@
 package update
 
 import (
+	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"reflect"
 	"strings"
 
 	"github.com/GoogleContainerTools/kpt/internal/gitutil"
+	"github.com/GoogleContainerTools/kpt/internal/util/functions"
+	"github.com/GoogleContainerTools/kpt/internal/util/revert"
 	"github.com/GoogleContainerTools/kpt/internal/util/setters"
+	"github.com/GoogleContainerTools/kpt/internal/util/tmputil"
 	"github.com/GoogleContainerTools/kpt/pkg/kptfile"
 	"github.com/GoogleContainerTools/kpt/pkg/kptfile/kptfileutil"
+	"sigs.k8s.io/kustomize/kyaml/copyutil"
 	"sigs.k8s.io/kustomize/kyaml/errors"
 )
 
@@ -58,6 +66,35 @@ type UpdateOptions struct {
 
 	// Perform setters automatically based on environment
 	AutoSet bool
+
+	// RelativeRepo is the upstream repo as recorded in the local Kptfile,
+	// if it is relative to the package.  KptFile contains the resolved repo.
+	RelativeRepo string
+
+	// OnConflict controls how the resource merge strategies resolve
+	// changes made both upstream and locally
+	OnConflict ConflictPolicy
+
+	// Input is read for the resolution of conflicts when OnConflict is
+	// ConflictPrompt
+	Input io.Reader
+
+	// ConflictRules resolve conflicts by field before OnConflict applies.
+	// They take precedence over the rules in the local Kptfile.
+	ConflictRules []kptfile.ConflictRule
+
+	// RelocatedFrom is the upstream repo the package was fetched from, if
+	// it is updated from a relocated repo.  KptFile contains the new repo.
+	RelocatedFrom string
+
+	// Subpackages are the paths of the independent subpackages of the
+	// package, relative to it.  They are updated separately, so updaters
+	// exclude them from the package.
+	Subpackages []string
+
+	// GitConfig is passed as -c key=value flags to each git command run
+	// while fetching the upstream package
+	GitConfig map[string]string
 }
 
 // Updater updates a local package
@@ -71,6 +108,7 @@ var strategies = map[StrategyType]func() Updater{
 	FastForward:        func() Updater { return FastForwardUpdater{} },
 	ForceDeleteReplace: func() Updater { return ReplaceUpdater{} },
 	KResourceMerge:     func() Updater { return ResourceMergeUpdater{} },
+	KResourceMerge3:    func() Updater { return ResourceMerge3Updater{} },
 }
 
 // StrategyType controls the update strategy to use when the local package
@@ -93,6 +131,10 @@ const (
 
 	KResourceMerge StrategyType = "resource-merge"
 
+	// KResourceMerge3 will merge upstream changes into the local Resources in
+	// place, preserving their comments, field order and formatting.
+	KResourceMerge3 StrategyType = "resource-merge-3"
+
 	// Default defaults to the recommended strategy, which is FailOnChanges.
 	// The recommended strategy may change as new strategies are introduced.
 	Default StrategyType = ""
@@ -100,6 +142,7 @@ const (
 
 var Strategies = []string{
 	string(FastForward), string(ForceDeleteReplace), string(AlphaGitPatch), string(KResourceMerge),
+	string(KResourceMerge3),
 }
 
 // Command updates the contents of a local package to a different version.
@@ -113,15 +156,27 @@ type Command struct {
 	// Ref is the ref to update to
 	Ref string
 
+	// ToLatest if set updates to the newest version the package is tagged
+	// with which satisfies Constraint, rather than Ref.
+	ToLatest bool
+
+	// Constraint restricts the versions ToLatest updates to, e.g. "^1.2".
+	// Defaults to any release.
+	Constraint string
+
 	// Repo is the repo to update to
 	Repo string
 
 	// Strategy is the update strategy to use
 	Strategy StrategyType
 
-	// DryRun if set will print the patch instead of applying it
+	// DryRun if set will print the changes instead of applying them
 	DryRun bool
 
+	// DryRunFormat is the format the changes are printed in for DryRun.
+	// The alpha-git-patch strategy always prints its patch.
+	DryRunFormat DryRunFormat
+
 	// Verbose if set will print verbose information about the commands being run
 	Verbose bool
 
@@ -134,6 +189,31 @@ type Command struct {
 
 	// Perform setters automatically based on environment
 	AutoSet bool
+
+	// RequirePinned if set rejects updating to refs which do not pin the
+	// package to a fixed version -- i.e. branches rather than tags or commits.
+	RequirePinned bool
+
+	// OnConflict controls how the resource merge strategies resolve
+	// changes made both upstream and locally.  Defaults to ConflictUpstream.
+	OnConflict ConflictPolicy
+
+	// Input is read for the resolution of conflicts when OnConflict is
+	// ConflictPrompt.  Defaults to stdin.
+	Input io.Reader
+
+	// ConflictRules resolve the conflicts in matching fields automatically,
+	// ahead of the rules in the local Kptfile.
+	ConflictRules []kptfile.ConflictRule
+
+	// SkipHooks if set doesn't run the post-update hooks declared by the
+	// updated Kptfile.
+	SkipHooks bool
+
+	// GitConfig is passed as -c key=value flags to each git command run
+	// while fetching the upstream package, e.g. to set http.extraHeader
+	// without changing the user's git config.
+	GitConfig map[string]string
 }
 
 // Run runs the Command.
@@ -141,18 +221,13 @@ func (u Command) Run() error {
 	if u.Output == nil {
 		u.Output = os.Stdout
 	}
-
-	kptfile, err := kptfileutil.ReadFileStrict(u.Path)
-	if err != nil {
-		return errors.Errorf("unable to read package Kptfile: %v", err)
+	if u.Input == nil {
+		u.Input = os.Stdin
 	}
 
-	// default arguments
-	if u.Repo == "" {
-		u.Repo = kptfile.Upstream.Git.Repo
-	}
-	if u.Ref == "" {
-		u.Ref = kptfile.Upstream.Git.Ref
+	options, err := u.options()
+	if err != nil {
+		return err
 	}
 
 	// require package is checked into git before trying to update it
@@ -171,7 +246,142 @@ func (u Command) Run() error {
 	if !found {
 		return errors.Errorf("unrecognized update strategy %q", u.Strategy)
 	}
-	err = updater().Update(UpdateOptions{
+	if u.DryRun && u.Strategy != AlphaGitPatch {
+		return u.dryRun(updater(), options)
+	}
+	if !u.DryRun {
+		// snapshot the package so the update can be reverted
+		if err := revert.Save(u.Path); err != nil {
+			return err
+		}
+	}
+	markers, err := splitConflictMarkers(u.update(updater(), options))
+	if err != nil {
+		return err
+	}
+	if markers != nil {
+		// the files with conflict markers can't be read until they are
+		// resolved, so the update is completed without the setters
+		if !u.DryRun {
+			if err := revert.Complete(u.Path); err != nil {
+				return err
+			}
+		}
+		return markers
+	}
+
+	// perform auto-setters after the package is updated
+	a := setters.AutoSet{
+		Writer:      u.Output,
+		PackagePath: u.Path,
+	}
+	if err := a.PerformAutoSetters(); err != nil {
+		return err
+	}
+	if err := functions.ApplyCommonMetadata(u.Path); err != nil {
+		return err
+	}
+	if u.DryRun {
+		return nil
+	}
+	hookErr := u.runHooks(u.Path, u.Output)
+	if hookErr == nil {
+		if err := RecordDigests(u.Path); err != nil {
+			return err
+		}
+	}
+	if err := revert.Complete(u.Path); err != nil {
+		return err
+	}
+	if hookErr != nil {
+		return errors.Errorf("%v; the update was applied, run 'kpt pkg revert %s' to undo it",
+			hookErr, u.Path)
+	}
+	return nil
+}
+
+// runHooks runs the post-update hooks declared by the Kptfile of the
+// package at path, writing the hooks run to out.
+func (u Command) runHooks(path string, out io.Writer) error {
+	if u.SkipHooks {
+		return nil
+	}
+	k, err := kptfileutil.ReadFile(path)
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	for _, h := range k.Hooks.PostUpdate {
+		fmt.Fprintf(out, "running post-update hook %s\n", h)
+		if err := functions.RunHook(path, h); err != nil {
+			return errors.Errorf("post-update hook %s failed: %v", h, err)
+		}
+	}
+	return nil
+}
+
+// options returns the options to update the package with, from its Kptfile.
+func (u Command) options() (UpdateOptions, error) {
+	kptfile, err := kptfileutil.ReadFileStrict(u.Path)
+	if err != nil {
+		return UpdateOptions{}, errors.Errorf("unable to read package Kptfile: %v", err)
+	}
+
+	// relative upstreams are updated from the enclosing repository
+	relativeRepo := ""
+	if gitutil.IsRelativeRepo(kptfile.Upstream.Git.Repo) {
+		relativeRepo = kptfile.Upstream.Git.Repo
+		kptfile.Upstream.Git.Repo, kptfile.Upstream.Git.Directory, err =
+			gitutil.ResolveRelativeRepo(u.Path, relativeRepo)
+		if err != nil {
+			return UpdateOptions{}, err
+		}
+	}
+
+	// default arguments
+	if u.Repo == "" {
+		u.Repo = kptfile.Upstream.Git.Repo
+	}
+
+	// the package is rebased onto a relocated repo, which must contain the
+	// commit it was fetched at
+	relocatedFrom := ""
+	if relativeRepo == "" && kptfile.Upstream.Git.Repo != "" && u.Repo != kptfile.Upstream.Git.Repo {
+		relocatedFrom = kptfile.Upstream.Git.Repo
+		kptfile.Upstream.Git.Repo = u.Repo
+	}
+	if u.ToLatest {
+		if u.Ref != "" {
+			return UpdateOptions{}, errors.Errorf(
+				"a version may not be specified when updating to the latest version")
+		}
+		u.Ref, err = gitutil.LatestVersion(u.Repo, kptfile.Upstream.Git.Directory,
+			kptfile.Upstream.Git.TagTemplate, u.Constraint, u.GitConfig)
+		if err != nil {
+			return UpdateOptions{}, err
+		}
+		fmt.Fprintf(os.Stderr, "latest version of package %q is %s\n", u.Path, u.Ref)
+	}
+	if u.Ref == "" {
+		u.Ref = kptfile.Upstream.Git.Ref
+	}
+	if u.RequirePinned {
+		err := gitutil.CheckPinnedRef(u.Repo, kptfile.Upstream.Git.Directory,
+			kptfile.Upstream.Git.TagTemplate, u.Ref, u.GitConfig)
+		if err != nil {
+			return UpdateOptions{}, err
+		}
+	}
+
+	// alpha-git-patch patches the package as a whole
+	var subpackages []string
+	if u.Strategy != AlphaGitPatch {
+		subpackages, err = Subpackages(u.Path)
+		if err != nil {
+			return UpdateOptions{}, err
+		}
+	}
+
+	return UpdateOptions{
 		KptFile:        kptfile,
 		ToRef:          u.Ref,
 		ToRepo:         u.Repo,
@@ -182,16 +392,176 @@ func (u Command) Run() error {
 		SimpleMessage:  u.SimpleMessage,
 		Output:         u.Output,
 		AutoSet:        u.AutoSet,
-	})
+		RelativeRepo:   relativeRepo,
+		OnConflict:     u.OnConflict,
+		Input:          u.Input,
+		ConflictRules:  u.ConflictRules,
+		RelocatedFrom:  relocatedFrom,
+		Subpackages:    subpackages,
+		GitConfig:      u.GitConfig,
+	}, nil
+}
 
+// update updates the package at options.PackagePath with updater, then
+// updates each of its independent subpackages against their own upstream.
+// The subpackages are moved out of the package while it is merged.
+func (u Command) update(updater Updater, options UpdateOptions) error {
+	restore, err := stashSubpackages(options.PackagePath, options.Subpackages)
+	if err != nil {
+		return err
+	}
+	markers, err := splitConflictMarkers(updater.Update(options))
+	if restoreErr := restore(); err == nil {
+		err = restoreErr
+	}
 	if err != nil {
 		return err
 	}
+	if options.DryRun {
+		return markers.orNil()
+	}
 
-	// perform auto-setters after the package is updated
-	a := setters.AutoSet{
-		Writer:      u.Output,
-		PackagePath: u.Path,
+	if options.RelativeRepo != "" {
+		err := restoreRelativeRepo(options.PackagePath, options.KptFile.Upstream.Git.Repo,
+			options.RelativeRepo)
+		if err != nil {
+			return err
+		}
+	}
+	aliases := options.KptFile.UpstreamAliases
+	if options.RelocatedFrom != "" {
+		aliases = appendAlias(aliases, options.RelocatedFrom)
+	}
+	if len(aliases) > 0 {
+		if err := restoreAliases(options.PackagePath, aliases); err != nil {
+			return err
+		}
+	}
+
+	for _, s := range options.Subpackages {
+		// the subpackage is updated to the ref recorded in its own Kptfile
+		sub := u
+		sub.Path = filepath.Join(u.Path, s)
+		sub.FullPackagePath = filepath.Join(u.FullPackagePath, s)
+		sub.Ref, sub.Repo, sub.ToLatest, sub.Constraint = "", "", false, ""
+		subOptions, err := sub.options()
+		if err != nil {
+			return errors.Errorf("unable to update subpackage %q: %v", s, err)
+		}
+		subOptions.PackagePath = filepath.Join(options.PackagePath, s)
+		subOptions.AbsPackagePath = filepath.Join(options.AbsPackagePath, s)
+		subOptions.Output = options.Output
+		subMarkers, err := splitConflictMarkers(sub.update(updater, subOptions))
+		if err != nil {
+			return errors.Errorf("unable to update subpackage %q: %v", s, err)
+		}
+		if subMarkers != nil {
+			if markers == nil {
+				markers = &ConflictMarkersError{}
+			}
+			for _, f := range subMarkers.Files {
+				markers.Files = append(markers.Files, filepath.Join(s, f))
+			}
+		}
+		if options.Output != nil {
+			fmt.Fprintf(options.Output, "updated subpackage %s\n", s)
+		}
+	}
+	return markers.orNil()
+}
+
+// dryRun updates a copy of the package and prints the changes made to it,
+// leaving the package unchanged.
+func (u Command) dryRun(updater Updater, options UpdateOptions) error {
+	dir, err := tmputil.TempDir("kpt-update-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	pkg := filepath.Join(dir, filepath.Base(u.FullPackagePath))
+	if err := copyutil.CopyDir(u.Path, pkg); err != nil {
+		return errors.Wrap(err)
+	}
+
+	// the updater logs to stderr so it isn't mixed with the changes
+	options.PackagePath = pkg
+	options.AbsPackagePath = pkg
+	options.DryRun = false
+	options.Output = os.Stderr
+	u.DryRun = false
+	markers, err := splitConflictMarkers(u.update(updater, options))
+	if err != nil {
+		return err
+	}
+	if markers == nil {
+		a := setters.AutoSet{Writer: ioutil.Discard, PackagePath: pkg}
+		if err := a.PerformAutoSetters(); err != nil {
+			return err
+		}
+		if err := functions.ApplyCommonMetadata(pkg); err != nil {
+			return err
+		}
+		if err := u.runHooks(pkg, ioutil.Discard); err != nil {
+			return err
+		}
+	}
+
+	changes, err := DiffPackages(u.Path, pkg)
+	if err != nil {
+		return err
+	}
+	if err := PrintChanges(u.Output, u.DryRunFormat, changes); err != nil {
+		return err
+	}
+	return markers.orNil()
+}
+
+// appendAlias appends repo to the aliases of the upstream, unless it is
+// already one of them.
+func appendAlias(aliases []string, repo string) []string {
+	for _, a := range aliases {
+		if a == repo {
+			return aliases
+		}
+	}
+	return append(append([]string{}, aliases...), repo)
+}
+
+// restoreAliases records the former repos of the upstream in the Kptfile,
+// which the strategies refetching the package don't keep.
+func restoreAliases(path string, aliases []string) error {
+	k, err := kptfileutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if reflect.DeepEqual(k.UpstreamAliases, aliases) {
+		return nil
+	}
+	k.UpstreamAliases = aliases
+	return kptfileutil.WriteFile(path, k)
+}
+
+// originalError returns the error cloning the original package, explaining
+// the likely cause if the upstream was relocated.
+func originalError(options UpdateOptions, err error) error {
+	if options.RelocatedFrom == "" {
+		return err
+	}
+	return errors.Errorf("unable to fetch commit %s from %s -- a relocated repo must "+
+		"contain the history of %s: %v", options.KptFile.Upstream.Git.Commit,
+		options.KptFile.Upstream.Git.Repo, options.RelocatedFrom, err)
+}
+
+// restoreRelativeRepo records the upstream repo in the Kptfile as the relative
+// path it was resolved from, if it was updated from the enclosing repo root.
+func restoreRelativeRepo(path, root, relativeRepo string) error {
+	k, err := kptfileutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if k.Upstream.Git.Repo != root {
+		return nil
 	}
-	return a.PerformAutoSetters()
+	k.Upstream.Git.Repo = relativeRepo
+	return kptfileutil.WriteFile(path, k)
 }
@@ -16,15 +16,19 @@ package update_test
 
 import (
 	"bytes"
+	"encoding/json"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/GoogleContainerTools/kpt/internal/gitutil"
 	"github.com/GoogleContainerTools/kpt/internal/testutil"
 	"github.com/GoogleContainerTools/kpt/internal/testutil/pkgbuilder"
+	"github.com/GoogleContainerTools/kpt/internal/util/get"
 	. "github.com/GoogleContainerTools/kpt/internal/util/update"
+	"github.com/GoogleContainerTools/kpt/pkg/kptfile"
 	"github.com/GoogleContainerTools/kpt/pkg/kptfile/kptfileutil"
 	"github.com/stretchr/testify/assert"
 	"sigs.k8s.io/kustomize/kyaml/copyutil"
@@ -36,6 +40,7 @@ var (
 		ForceDeleteReplace,
 		AlphaGitPatch,
 		KResourceMerge,
+		KResourceMerge3,
 	}
 )
 
@@ -82,6 +87,52 @@ func TestCommand_Run_noRefChanges(t *testing.T) {
 	}
 }
 
+// TestCommand_Run_setterHistory verifies that the setter history of the
+// local package isn't treated as a local change, and is kept by the update.
+func TestCommand_Run_setterHistory(t *testing.T) {
+	for _, strategy := range []StrategyType{FastForward, KResourceMerge, KResourceMerge3} {
+		strategy := strategy
+		t.Run(string(strategy), func(t *testing.T) {
+			g := &testutil.TestSetupManager{
+				T:               t,
+				UpstreamChanges: []testutil.Content{{Data: testutil.Dataset2}},
+			}
+			defer g.Clean()
+			if !g.Init(testutil.Dataset1) {
+				return
+			}
+			history := filepath.Join(g.UpstreamRepo.RepoName, kptfile.HistoryFileName)
+			if !assert.NoError(t, ioutil.WriteFile(history, []byte("entries: []\n"), 0600)) {
+				return
+			}
+			localGit := gitutil.NewLocalGitRunner(g.LocalWorkspace.WorkspaceDirectory)
+			if !assert.NoError(t, localGit.Run("add", ".")) {
+				return
+			}
+			if !assert.NoError(t, localGit.Run("commit", "-m", "record history")) {
+				return
+			}
+
+			if !assert.NoError(t, Command{
+				Path:            g.UpstreamRepo.RepoName,
+				FullPackagePath: toAbsPath(t, g.UpstreamRepo.RepoName),
+				Strategy:        strategy,
+			}.Run()) {
+				return
+			}
+
+			b, err := ioutil.ReadFile(history)
+			if assert.NoError(t, err) {
+				assert.Equal(t, "entries: []\n", string(b))
+			}
+			if !assert.NoError(t, os.Remove(history)) {
+				return
+			}
+			g.AssertLocalDataEquals(testutil.Dataset2)
+		})
+	}
+}
+
 func TestCommand_Run_subDir(t *testing.T) {
 	for i := range updateStrategies {
 		strategy := updateStrategies[i]
@@ -132,6 +183,7 @@ func TestCommand_Run_noChanges(t *testing.T) {
 		{ForceDeleteReplace, ""},
 		{AlphaGitPatch, "no updates"},
 		{KResourceMerge, ""},
+		{KResourceMerge3, ""},
 	}
 	for i := range updates {
 		u := updates[i]
@@ -453,9 +505,350 @@ func TestCommand_Run_toTagRef(t *testing.T) {
 	}
 }
 
+// TestCommand_Run_requirePinned verifies updating to a branch fails when
+// pinned upstreams are required, and updating to a tag succeeds.
+func TestCommand_Run_requirePinned(t *testing.T) {
+	g := &testutil.TestSetupManager{
+		T: t,
+		UpstreamChanges: []testutil.Content{
+			{Data: testutil.Dataset2, Tag: "v1.0"},
+			{Data: testutil.Dataset3},
+		},
+	}
+	defer g.Clean()
+	if !g.Init(testutil.Dataset1) {
+		return
+	}
+
+	err := Command{
+		Path:            g.UpstreamRepo.RepoName,
+		FullPackagePath: toAbsPath(t, g.UpstreamRepo.RepoName),
+		Ref:             "master",
+		RequirePinned:   true,
+	}.Run()
+	if !assert.Error(t, err) {
+		return
+	}
+	assert.Contains(t, err.Error(), `ref "master" is a branch`)
+	if !g.AssertLocalDataEquals(testutil.Dataset1) {
+		return
+	}
+
+	if !assert.NoError(t, Command{
+		Path:            g.UpstreamRepo.RepoName,
+		FullPackagePath: toAbsPath(t, g.UpstreamRepo.RepoName),
+		Ref:             "v1.0",
+		RequirePinned:   true,
+	}.Run()) {
+		return
+	}
+	g.AssertLocalDataEquals(testutil.Dataset2)
+}
+
+// TestCommand_Run_gitConfig verifies that GitConfig is passed to each git
+// command run by the strategies, by updating from a url.insteadOf alias.
+func TestCommand_Run_gitConfig(t *testing.T) {
+	for i := range updateStrategies {
+		strategy := updateStrategies[i]
+		t.Run(string(strategy), func(t *testing.T) {
+			g := &testutil.TestSetupManager{
+				T:               t,
+				UpstreamChanges: []testutil.Content{{Data: testutil.Dataset2}},
+			}
+			defer g.Clean()
+			if !g.Init(testutil.Dataset1) {
+				return
+			}
+			k, err := kptfileutil.ReadFile(g.UpstreamRepo.RepoName)
+			if !assert.NoError(t, err) {
+				return
+			}
+			k.Upstream.Git.Repo = "kpt-test-alias:repo"
+			if !assert.NoError(t, kptfileutil.WriteFile(g.UpstreamRepo.RepoName, k)) ||
+				!assert.NoError(t, gitutil.NewLocalGitRunner(g.LocalWorkspace.WorkspaceDirectory).
+					Run("add", ".")) ||
+				!assert.NoError(t, g.LocalWorkspace.Commit("use the alias")) {
+				return
+			}
+			config := map[string]string{
+				"url." + g.UpstreamRepo.RepoDirectory + ".insteadOf": "kpt-test-alias:repo",
+			}
+
+			// without the config the alias can't be fetched
+			assert.Error(t, Command{
+				Path:            g.UpstreamRepo.RepoName,
+				FullPackagePath: toAbsPath(t, g.UpstreamRepo.RepoName),
+				Ref:             "master",
+				Strategy:        strategy,
+			}.Run())
+
+			if !assert.NoError(t, Command{
+				Path:            g.UpstreamRepo.RepoName,
+				FullPackagePath: toAbsPath(t, g.UpstreamRepo.RepoName),
+				Ref:             "master",
+				Strategy:        strategy,
+				GitConfig:       config,
+			}.Run()) {
+				return
+			}
+			commit, err := g.UpstreamRepo.GetCommit()
+			if !assert.NoError(t, err) {
+				return
+			}
+			k, err = kptfileutil.ReadFile(g.UpstreamRepo.RepoName)
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, commit, k.Upstream.Git.Commit)
+		})
+	}
+}
+
+// TestCommand_Run_relativeRepo verifies packages with an upstream relative
+// to the package are updated from the enclosing repository, and keep the
+// relative upstream.
+func TestCommand_Run_relativeRepo(t *testing.T) {
+	for i := range updateStrategies {
+		strategy := updateStrategies[i]
+		t.Run(string(strategy), func(t *testing.T) {
+			g, _, clean := testutil.SetupDefaultRepoAndWorkspace(t, testutil.Dataset1)
+			defer clean()
+			if !assert.NoError(t, os.Chdir(g.RepoDirectory)) {
+				return
+			}
+			gr := gitutil.NewLocalGitRunner(g.RepoDirectory)
+
+			// fetch java from the same repo and commit it
+			if !assert.NoError(t, get.Command{
+				Git:         kptfile.Git{Repo: "./java", Ref: "master"},
+				Destination: "java-copy",
+			}.Run()) {
+				return
+			}
+			if !assert.NoError(t, gr.Run("add", ".")) ||
+				!assert.NoError(t, g.Commit("add java-copy")) {
+				return
+			}
+
+			// change the upstream package
+			if !assert.NoError(t, ioutil.WriteFile(filepath.Join("java", "new.yaml"),
+				[]byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: new\n"), 0600)) {
+				return
+			}
+			if !assert.NoError(t, gr.Run("add", ".")) ||
+				!assert.NoError(t, g.Commit("add new.yaml")) {
+				return
+			}
+
+			if !assert.NoError(t, Command{
+				Path:            "java-copy",
+				FullPackagePath: toAbsPath(t, "java-copy"),
+				Strategy:        strategy,
+			}.Run()) {
+				return
+			}
+			assert.FileExists(t, filepath.Join("java-copy", "new.yaml"))
+			k, err := kptfileutil.ReadFile("java-copy")
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, "../java", k.Upstream.Git.Repo)
+			assert.Equal(t, "/java", k.Upstream.Git.Directory)
+		})
+	}
+}
+
+// TestCommand_Run_relocatedRepo verifies a package is updated from the new
+// location of its upstream repo, which is recorded in the Kptfile
+func TestCommand_Run_relocatedRepo(t *testing.T) {
+	for i := range updateStrategies {
+		strategy := updateStrategies[i]
+		t.Run(string(strategy), func(t *testing.T) {
+			g := &testutil.TestSetupManager{
+				T:               t,
+				UpstreamChanges: []testutil.Content{{Data: testutil.Dataset2}},
+			}
+			defer g.Clean()
+			if !g.Init(testutil.Dataset1) {
+				t.FailNow()
+			}
+
+			// move the upstream repo
+			dir, err := ioutil.TempDir("", "kpt-relocated")
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			defer os.RemoveAll(dir)
+			original := g.UpstreamRepo.RepoDirectory
+			relocated := filepath.Join(dir, "repo")
+			if !assert.NoError(t, os.Rename(original, relocated)) {
+				t.FailNow()
+			}
+
+			err = Command{
+				Path:            g.UpstreamRepo.RepoName,
+				FullPackagePath: toAbsPath(t, g.UpstreamRepo.RepoName),
+				Repo:            relocated,
+				Strategy:        strategy,
+			}.Run()
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			if !g.AssertLocalDataEquals(testutil.Dataset2) {
+				t.FailNow()
+			}
+			k, err := kptfileutil.ReadFile(g.UpstreamRepo.RepoName)
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			assert.Equal(t, relocated, k.Upstream.Git.Repo)
+			assert.Equal(t, []string{original}, k.UpstreamAliases)
+		})
+	}
+}
+
+// TestCommand_Run_relocatedRepoHistory verifies a package isn't updated
+// from a repo without the commit it was fetched at
+func TestCommand_Run_relocatedRepoHistory(t *testing.T) {
+	// an unrelated repo
+	other, _, clean := testutil.SetupDefaultRepoAndWorkspace(t, testutil.Dataset2)
+	defer clean()
+
+	g := &testutil.TestSetupManager{
+		T:               t,
+		UpstreamChanges: []testutil.Content{{Data: testutil.Dataset2}},
+	}
+	defer g.Clean()
+	if !g.Init(testutil.Dataset1) {
+		t.FailNow()
+	}
+	k, err := kptfileutil.ReadFile(g.UpstreamRepo.RepoName)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	err = Command{
+		Path:            g.UpstreamRepo.RepoName,
+		FullPackagePath: toAbsPath(t, g.UpstreamRepo.RepoName),
+		Repo:            other.RepoDirectory,
+		Strategy:        KResourceMerge,
+	}.Run()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "unable to fetch commit "+k.Upstream.Git.Commit+
+			" from "+other.RepoDirectory+" -- a relocated repo must contain the history of "+
+			g.UpstreamRepo.RepoDirectory)
+	}
+}
+
+// TestCommand_Run_independentSubpackages verifies that a subpackage with its own
+// upstream is updated against that upstream, rather than merged as part of
+// the parent package.
+func TestCommand_Run_independentSubpackages(t *testing.T) {
+	for i := range updateStrategies {
+		strategy := updateStrategies[i]
+		if strategy == AlphaGitPatch {
+			// patches the package as a whole
+			continue
+		}
+		t.Run(string(strategy), func(t *testing.T) {
+			// the upstream of the subpackage
+			sub, _, clean := testutil.SetupDefaultRepoAndWorkspace(t, testutil.Dataset1)
+			defer clean()
+
+			g := &testutil.TestSetupManager{
+				T:               t,
+				UpstreamChanges: []testutil.Content{{Data: testutil.Dataset2}},
+			}
+			defer g.Clean()
+			if !g.Init(testutil.Dataset1) {
+				t.FailNow()
+			}
+
+			// fetch the subpackage into the package
+			subPath := filepath.Join(g.UpstreamRepo.RepoName, "sub")
+			err := get.Command{Destination: subPath,
+				Git: kptfile.Git{Repo: sub.RepoDirectory, Ref: "master", Directory: "/"}}.Run()
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			localGit := gitutil.NewLocalGitRunner(g.LocalWorkspace.WorkspaceDirectory)
+			if !assert.NoError(t, localGit.Run("add", ".")) {
+				t.FailNow()
+			}
+			if !assert.NoError(t, localGit.Run("commit", "-m", "add subpackage")) {
+				t.FailNow()
+			}
+
+			// change the upstream of the subpackage
+			if !assert.NoError(t, sub.ReplaceData(testutil.Dataset3)) {
+				t.FailNow()
+			}
+			if !assert.NoError(t, sub.Commit("update subpackage")) {
+				t.FailNow()
+			}
+			commit, err := sub.GetCommit()
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+
+			var out bytes.Buffer
+			err = Command{
+				Path:            g.UpstreamRepo.RepoName,
+				FullPackagePath: toAbsPath(t, g.UpstreamRepo.RepoName),
+				Strategy:        strategy,
+				Output:          &out,
+			}.Run()
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			assert.Contains(t, out.String(), "updated subpackage sub\n")
+
+			// the subpackage is updated from its own upstream
+			sub.AssertEqual(t, filepath.Join(sub.DatasetDirectory, testutil.Dataset3), subPath)
+			k, err := kptfileutil.ReadFile(subPath)
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			assert.Equal(t, commit, k.Upstream.Git.Commit)
+			assert.Equal(t, sub.RepoDirectory, k.Upstream.Git.Repo)
+
+			// the rest of the package is updated from the package upstream
+			if !assert.NoError(t, os.RemoveAll(subPath)) {
+				t.FailNow()
+			}
+			g.AssertLocalDataEquals(testutil.Dataset2)
+		})
+	}
+}
+
+// TestSubpackages verifies that only the outermost nested packages with
+// their own upstream are independent subpackages.
+func TestSubpackages(t *testing.T) {
+	pkg := pkgbuilder.NewPackage("parent").
+		WithKptfile().
+		WithSubPackages(
+			pkgbuilder.NewPackage("blueprint").WithKptfile(),
+			pkgbuilder.NewPackage("fetched").
+				WithKptfile(pkgbuilder.NewKptfile().WithUpstream("github.com/a/b", "v1")).
+				WithSubPackages(pkgbuilder.NewPackage("nested").
+					WithKptfile(pkgbuilder.NewKptfile().WithUpstream("github.com/c/d", "v1"))),
+			pkgbuilder.NewPackage("other").
+				WithSubPackages(pkgbuilder.NewPackage("fetched").
+					WithKptfile(pkgbuilder.NewKptfile().WithUpstream("github.com/e/f", "v1"))),
+		)
+	dir := pkgbuilder.ExpandPkg(t, pkg)
+	defer os.RemoveAll(filepath.Dir(dir))
+
+	subpackages, err := Subpackages(dir)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, []string{"fetched", filepath.Join("other", "fetched")}, subpackages)
+}
+
 // TestCommand_ResourceMerge_NonKRMUpdates tests if the local non KRM files are updated
 func TestCommand_ResourceMerge_NonKRMUpdates(t *testing.T) {
-	strategies := []StrategyType{KResourceMerge}
+	strategies := []StrategyType{KResourceMerge, KResourceMerge3}
 	for i := range strategies {
 		strategy := strategies[i]
 		t.Run(string(strategy), func(t *testing.T) {
@@ -504,7 +897,7 @@ func TestCommand_ResourceMerge_NonKRMUpdates(t *testing.T) {
 // TestCommand_Run_toTagRef verifies the package contents are set to the contents of the tag
 // it was updated to with local values set to different values in upstream.
 func TestCommand_ResourceMerge_WithSetters_TagRef(t *testing.T) {
-	strategies := []StrategyType{KResourceMerge}
+	strategies := []StrategyType{KResourceMerge, KResourceMerge3}
 	for i := range strategies {
 		strategy := strategies[i]
 		t.Run(string(strategy), func(t *testing.T) {
@@ -577,33 +970,470 @@ func TestCommand_ResourceMerge_WithSetters_TagRef(t *testing.T) {
 	}
 }
 
-func TestCommand_Run_emitPatch(t *testing.T) {
-	// Setup the test upstream and local packages
+// TestCommand_ResourceMerge3_comments verifies the local comments are kept
+// when merging upstream changes into a resource.
+func TestCommand_ResourceMerge3_comments(t *testing.T) {
 	g := &testutil.TestSetupManager{
-		T:               t,
+		T: t,
+		// Update upstream to Dataset2
 		UpstreamChanges: []testutil.Content{{Data: testutil.Dataset2}},
 	}
 	defer g.Clean()
 	if !g.Init(testutil.Dataset1) {
-		return
+		t.FailNow()
 	}
 
-	f, err := ioutil.TempFile("", "*.patch")
+	// comment the field which is changed upstream
+	file := filepath.Join("mysql", "mysql-statefulset.resource.yaml")
+	local := filepath.Join(g.LocalWorkspace.WorkspaceDirectory, g.UpstreamRepo.RepoName, file)
+	b, err := ioutil.ReadFile(local)
 	if !assert.NoError(t, err) {
-		return
+		t.FailNow()
+	}
+	b = []byte(strings.Replace(string(b),
+		"name: mysql\n        image: mysql:5.7", "name: mysql\n        image: mysql:5.7 # pinned", 1))
+	if !assert.NoError(t, ioutil.WriteFile(local, b, 0600)) {
+		t.FailNow()
+	}
+	localGit := gitutil.NewLocalGitRunner(g.LocalWorkspace.WorkspaceDirectory)
+	if !assert.NoError(t, localGit.Run("commit", "-am", "comment image")) {
+		t.FailNow()
 	}
-	defer os.RemoveAll(f.Name())
 
-	// Update the local package
-	b := &bytes.Buffer{}
-	err = Command{
+	if !assert.NoError(t, Command{
 		Path:            g.UpstreamRepo.RepoName,
 		FullPackagePath: toAbsPath(t, g.UpstreamRepo.RepoName),
-		Strategy:        AlphaGitPatch,
-		DryRun:          true,
-		Output:          b,
-	}.Run()
-	if !assert.NoError(t, err) {
+		Ref:             "master",
+		Strategy:        KResourceMerge3,
+	}.Run()) {
+		t.FailNow()
+	}
+
+	// expect the upstream changes with the local comment
+	b, err = ioutil.ReadFile(filepath.Join(g.UpstreamRepo.DatasetDirectory, testutil.Dataset2, file))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	expected := strings.Replace(string(b),
+		"name: mysql\n        image: mysql:8.0", "name: mysql\n        image: mysql:8.0 # pinned", 1)
+	b, err = ioutil.ReadFile(local)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, expected, string(b))
+}
+
+// TestCommand_Run_onConflict verifies fields changed both upstream and
+// locally are resolved with the conflict policy.
+func TestCommand_Run_onConflict(t *testing.T) {
+	file := filepath.Join("mysql", "mysql-statefulset.resource.yaml")
+	editor, err := ioutil.TempFile("", "kpt-test-editor")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer os.Remove(editor.Name())
+	_, err = editor.WriteString("#!/bin/sh\nsed -i 's/initialDelaySeconds: 45/initialDelaySeconds: 50/' \"$1\"\n")
+	if !assert.NoError(t, err) || !assert.NoError(t, editor.Close()) ||
+		!assert.NoError(t, os.Chmod(editor.Name(), 0700)) {
+		t.FailNow()
+	}
+	defer os.Unsetenv("KPT_EDITOR")
+	if !assert.NoError(t, os.Setenv("KPT_EDITOR", editor.Name())) {
+		t.FailNow()
+	}
+
+	tests := []struct {
+		name     string
+		policy   ConflictPolicy
+		input    string
+		expected string
+		err      string
+		output   string
+	}{
+		{name: "upstream", policy: ConflictUpstream, expected: "initialDelaySeconds: 45"},
+		{name: "default", expected: "initialDelaySeconds: 45"},
+		{name: "local", policy: ConflictLocal, expected: "initialDelaySeconds: 60"},
+		{name: "skip", policy: ConflictSkip, expected: "initialDelaySeconds: 60\n          periodSeconds: 10"},
+		{name: "abort", policy: ConflictAbort, expected: "initialDelaySeconds: 60\n          periodSeconds: 10",
+			err: `update has conflicts -- resolve them with --on-conflict:
+  mysql/mysql-statefulset.resource.yaml:
+    StatefulSet/mysql spec.template.spec.containers[name=mysql].livenessProbe.initialDelaySeconds: local 60, upstream 45
+`},
+		{name: "prompt-local", policy: ConflictPrompt, input: "x\nl\n", expected: "initialDelaySeconds: 60",
+			output: `conflicts in "mysql/mysql-statefulset.resource.yaml":
+  StatefulSet/mysql spec.template.spec.containers[name=mysql].livenessProbe.initialDelaySeconds: local 60, upstream 45
+keep [l]ocal, accept [u]pstream, [e]dit or [s]kip file? ` +
+				`keep [l]ocal, accept [u]pstream, [e]dit or [s]kip file? `},
+		{name: "prompt-edit", policy: ConflictPrompt, input: "e\n", expected: "initialDelaySeconds: 50"},
+		{name: "prompt-eof", policy: ConflictPrompt, expected: "initialDelaySeconds: 60\n          periodSeconds: 10",
+			err: `no resolution for the conflicts in "mysql/mysql-statefulset.resource.yaml": EOF`},
+	}
+	for i := range tests {
+		test := tests[i]
+		t.Run(test.name, func(t *testing.T) {
+			g := &testutil.TestSetupManager{
+				T:               t,
+				UpstreamChanges: []testutil.Content{{Data: testutil.Dataset2}},
+			}
+			defer g.Clean()
+			if !g.Init(testutil.Dataset1) {
+				t.FailNow()
+			}
+
+			// change the field which is changed upstream
+			local := filepath.Join(g.LocalWorkspace.WorkspaceDirectory, g.UpstreamRepo.RepoName, file)
+			b, err := ioutil.ReadFile(local)
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			b = []byte(strings.Replace(string(b),
+				"initialDelaySeconds: 30", "initialDelaySeconds: 60", 1))
+			if !assert.NoError(t, ioutil.WriteFile(local, b, 0600)) {
+				t.FailNow()
+			}
+			localGit := gitutil.NewLocalGitRunner(g.LocalWorkspace.WorkspaceDirectory)
+			if !assert.NoError(t, localGit.Run("commit", "-am", "change delay")) {
+				t.FailNow()
+			}
+			kf, err := ioutil.ReadFile(filepath.Join(g.UpstreamRepo.RepoName, kptfile.KptFileName))
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+
+			out := &bytes.Buffer{}
+			err = Command{
+				Path:            g.UpstreamRepo.RepoName,
+				FullPackagePath: toAbsPath(t, g.UpstreamRepo.RepoName),
+				Strategy:        KResourceMerge3,
+				OnConflict:      test.policy,
+				Input:           strings.NewReader(test.input),
+				Output:          out,
+			}.Run()
+			if test.err != "" {
+				if assert.Error(t, err) {
+					assert.Equal(t, test.err, err.Error())
+				}
+				// the package isn't changed
+				updated, err := ioutil.ReadFile(filepath.Join(g.UpstreamRepo.RepoName, kptfile.KptFileName))
+				if assert.NoError(t, err) {
+					assert.Equal(t, string(kf), string(updated))
+				}
+			} else if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			if test.output != "" {
+				assert.Equal(t, test.output, out.String())
+			}
+
+			b, err = ioutil.ReadFile(local)
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			assert.Contains(t, string(b), test.expected)
+			if test.err == "" && test.policy != ConflictSkip {
+				// the other upstream changes are merged
+				assert.Contains(t, string(b), "periodSeconds: 15")
+				assert.Contains(t, string(b), "image: mysql:8.0")
+			}
+		})
+	}
+}
+
+// TestCommand_Run_conflictMarkers verifies conflicts are written between
+// conflict markers with ConflictMarkers, and the rest of the update is made.
+func TestCommand_Run_conflictMarkers(t *testing.T) {
+	file := filepath.Join("mysql", "mysql-statefulset.resource.yaml")
+	g := &testutil.TestSetupManager{
+		T:               t,
+		UpstreamChanges: []testutil.Content{{Data: testutil.Dataset2}},
+	}
+	defer g.Clean()
+	if !g.Init(testutil.Dataset1) {
+		t.FailNow()
+	}
+
+	// change the field which is changed upstream
+	local := filepath.Join(g.LocalWorkspace.WorkspaceDirectory, g.UpstreamRepo.RepoName, file)
+	b, err := ioutil.ReadFile(local)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	b = []byte(strings.Replace(string(b),
+		"initialDelaySeconds: 30", "initialDelaySeconds: 60", 1))
+	if !assert.NoError(t, ioutil.WriteFile(local, b, 0600)) {
+		t.FailNow()
+	}
+	localGit := gitutil.NewLocalGitRunner(g.LocalWorkspace.WorkspaceDirectory)
+	if !assert.NoError(t, localGit.Run("commit", "-am", "change delay")) {
+		t.FailNow()
+	}
+
+	err = Command{
+		Path:            g.UpstreamRepo.RepoName,
+		FullPackagePath: toAbsPath(t, g.UpstreamRepo.RepoName),
+		Strategy:        KResourceMerge3,
+		OnConflict:      ConflictMarkers,
+		Output:          &bytes.Buffer{},
+	}.Run()
+	if assert.Error(t, err) {
+		assert.Equal(t, `update has conflicts -- resolve the conflict markers in:
+  mysql/mysql-statefulset.resource.yaml`, err.Error())
+	}
+
+	b, err = ioutil.ReadFile(local)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Contains(t, string(b), `<<<<<<< local
+          initialDelaySeconds: 60
+=======
+          initialDelaySeconds: 45
+>>>>>>> upstream
+          periodSeconds: 15
+`)
+	assert.Contains(t, string(b), "image: mysql:8.0")
+
+	// the package is updated to the upstream commit
+	commit, err := g.UpstreamRepo.GetCommit()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	g.AssertKptfile(g.UpstreamRepo.RepoName, commit, "master")
+}
+
+// TestCommand_Run_hooks verifies the post-update hooks declared by the
+// updated Kptfile are run after the update, unless they are skipped.
+func TestCommand_Run_hooks(t *testing.T) {
+	for _, skip := range []bool{false, true} {
+		g := &testutil.TestSetupManager{T: t}
+		if !g.Init(testutil.Dataset1) {
+			t.FailNow()
+		}
+
+		// declare a hook upstream which labels every resource
+		upstream := g.UpstreamRepo.RepoDirectory
+		if !assert.NoError(t, os.MkdirAll(filepath.Join(upstream, "hooks"), 0700)) {
+			t.FailNow()
+		}
+		if !assert.NoError(t, ioutil.WriteFile(filepath.Join(upstream, "hooks", "label.star"), []byte(`
+def label(items):
+  for item in items:
+    item["metadata"].setdefault("labels", {})["hooked"] = "true"
+label(ctx.resource_list["items"])
+`), 0600)) {
+			t.FailNow()
+		}
+		if !assert.NoError(t, ioutil.WriteFile(filepath.Join(upstream, kptfile.KptFileName), []byte(`
+apiVersion: kpt.dev/v1alpha1
+kind: Kptfile
+metadata:
+  name: hooks
+hooks:
+  postUpdate:
+  - name: label
+    starlark: hooks/label.star
+`), 0600)) {
+			t.FailNow()
+		}
+		if !assert.NoError(t, gitutil.NewLocalGitRunner(upstream).Run("add", ".")) {
+			t.FailNow()
+		}
+		testutil.Commit(t, g.UpstreamRepo, "add hook")
+
+		out := &bytes.Buffer{}
+		err := Command{
+			Path:            g.UpstreamRepo.RepoName,
+			FullPackagePath: toAbsPath(t, g.UpstreamRepo.RepoName),
+			Strategy:        KResourceMerge,
+			SkipHooks:       skip,
+			Output:          out,
+		}.Run()
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+
+		b, err := ioutil.ReadFile(filepath.Join(g.LocalWorkspace.WorkspaceDirectory,
+			g.UpstreamRepo.RepoName, "mysql", "mysql-statefulset.resource.yaml"))
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		if skip {
+			assert.NotContains(t, string(b), "hooked")
+			assert.NotContains(t, out.String(), "running post-update hook")
+		} else {
+			assert.Contains(t, string(b), `hooked: "true"`)
+			assert.Contains(t, out.String(), "running post-update hook label\n")
+		}
+		g.Clean()
+	}
+}
+
+// TestCommand_Run_conflictRules verifies conflicts are resolved by the
+// rules in the local Kptfile and the command, ahead of the conflict policy.
+func TestCommand_Run_conflictRules(t *testing.T) {
+	file := filepath.Join("mysql", "mysql-statefulset.resource.yaml")
+	g := &testutil.TestSetupManager{
+		T:               t,
+		UpstreamChanges: []testutil.Content{{Data: testutil.Dataset2}},
+	}
+	defer g.Clean()
+	if !g.Init(testutil.Dataset1) {
+		t.FailNow()
+	}
+
+	// change the fields which are changed upstream
+	pkg := filepath.Join(g.LocalWorkspace.WorkspaceDirectory, g.UpstreamRepo.RepoName)
+	local := filepath.Join(pkg, file)
+	b, err := ioutil.ReadFile(local)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	b = []byte(strings.Replace(string(b), "initialDelaySeconds: 30", "initialDelaySeconds: 60", 1))
+	b = []byte(strings.Replace(string(b), "- name: mysql\n        image: mysql:5.7\n",
+		"- name: mysql\n        image: mysql:5.7-patched\n", 1))
+	if !assert.NoError(t, ioutil.WriteFile(local, b, 0600)) {
+		t.FailNow()
+	}
+	k, err := kptfileutil.ReadFile(pkg)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	k.ConflictRules = []kptfile.ConflictRule{{
+		Fields: []string{"**.containers[*].image"},
+		Kinds:  []string{"StatefulSet"},
+		Prefer: kptfile.PreferUpstream,
+	}}
+	if !assert.NoError(t, kptfileutil.WriteFile(pkg, k)) {
+		t.FailNow()
+	}
+	localGit := gitutil.NewLocalGitRunner(g.LocalWorkspace.WorkspaceDirectory)
+	if !assert.NoError(t, localGit.Run("commit", "-am", "change delay and image")) {
+		t.FailNow()
+	}
+
+	out := &bytes.Buffer{}
+	err = Command{
+		Path:            g.UpstreamRepo.RepoName,
+		FullPackagePath: toAbsPath(t, g.UpstreamRepo.RepoName),
+		Strategy:        KResourceMerge3,
+		OnConflict:      ConflictAbort,
+		Output:          out,
+		ConflictRules: []kptfile.ConflictRule{{
+			Fields: []string{"spec.template.spec.containers[name=mysql].livenessProbe"},
+			Prefer: kptfile.PreferLocal,
+		}},
+	}.Run()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Contains(t, out.String(), "kept local StatefulSet/mysql "+
+		"spec.template.spec.containers[name=mysql].livenessProbe.initialDelaySeconds: "+
+		"local 60, upstream 45 in mysql/mysql-statefulset.resource.yaml\n")
+	assert.Contains(t, out.String(), "took upstream StatefulSet/mysql "+
+		"spec.template.spec.containers[name=mysql].image: "+
+		"local mysql:5.7-patched, upstream mysql:8.0 in mysql/mysql-statefulset.resource.yaml\n")
+
+	b, err = ioutil.ReadFile(local)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Contains(t, string(b), "initialDelaySeconds: 60")
+	assert.Contains(t, string(b), "image: mysql:8.0")
+
+	// the local rules are kept
+	k, err = kptfileutil.ReadFile(pkg)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Len(t, k.ConflictRules, 1)
+}
+
+// TestCommand_Run_keepLocal verifies fields marked to keep their local
+// values aren't updated, or reported as conflicts
+func TestCommand_Run_keepLocal(t *testing.T) {
+	file := filepath.Join("mysql", "mysql-statefulset.resource.yaml")
+	strategies := []StrategyType{KResourceMerge, KResourceMerge3}
+	for i := range strategies {
+		strategy := strategies[i]
+		t.Run(string(strategy), func(t *testing.T) {
+			g := &testutil.TestSetupManager{
+				T:               t,
+				UpstreamChanges: []testutil.Content{{Data: testutil.Dataset2}},
+			}
+			defer g.Clean()
+			if !g.Init(testutil.Dataset1) {
+				t.FailNow()
+			}
+
+			local := filepath.Join(g.LocalWorkspace.WorkspaceDirectory, g.UpstreamRepo.RepoName, file)
+			b, err := ioutil.ReadFile(local)
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			b = []byte(strings.Replace(string(b), "initialDelaySeconds: 30",
+				"initialDelaySeconds: 60 # kpt-merge: keep-local", 1))
+			if !assert.NoError(t, ioutil.WriteFile(local, b, 0600)) {
+				t.FailNow()
+			}
+			localGit := gitutil.NewLocalGitRunner(g.LocalWorkspace.WorkspaceDirectory)
+			if !assert.NoError(t, localGit.Run("commit", "-am", "keep delay")) {
+				t.FailNow()
+			}
+
+			out := &bytes.Buffer{}
+			err = Command{
+				Path:            g.UpstreamRepo.RepoName,
+				FullPackagePath: toAbsPath(t, g.UpstreamRepo.RepoName),
+				Strategy:        strategy,
+				OnConflict:      ConflictAbort,
+				Output:          out,
+			}.Run()
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			assert.Contains(t, out.String(), "kept local StatefulSet/mysql "+
+				"spec.template.spec.containers[name=mysql].livenessProbe.initialDelaySeconds: "+
+				"local 60, upstream 45 in mysql/mysql-statefulset.resource.yaml\n")
+
+			b, err = ioutil.ReadFile(local)
+			if !assert.NoError(t, err) {
+				t.FailNow()
+			}
+			assert.Contains(t, string(b), "initialDelaySeconds: 60 # kpt-merge: keep-local")
+			// the other upstream changes are merged
+			assert.Contains(t, string(b), "periodSeconds: 15")
+			assert.Contains(t, string(b), "image: mysql:8.0")
+		})
+	}
+}
+
+func TestCommand_Run_emitPatch(t *testing.T) {
+	// Setup the test upstream and local packages
+	g := &testutil.TestSetupManager{
+		T:               t,
+		UpstreamChanges: []testutil.Content{{Data: testutil.Dataset2}},
+	}
+	defer g.Clean()
+	if !g.Init(testutil.Dataset1) {
+		return
+	}
+
+	f, err := ioutil.TempFile("", "*.patch")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(f.Name())
+
+	// Update the local package
+	b := &bytes.Buffer{}
+	err = Command{
+		Path:            g.UpstreamRepo.RepoName,
+		FullPackagePath: toAbsPath(t, g.UpstreamRepo.RepoName),
+		Strategy:        AlphaGitPatch,
+		DryRun:          true,
+		Output:          b,
+	}.Run()
+	if !assert.NoError(t, err) {
 		return
 	}
 
@@ -615,6 +1445,117 @@ func TestCommand_Run_emitPatch(t *testing.T) {
 `)
 }
 
+// TestCommand_Run_dryRun verifies the changes are printed without updating
+// the package
+func TestCommand_Run_dryRun(t *testing.T) {
+	strategies := []StrategyType{FastForward, ForceDeleteReplace, KResourceMerge, KResourceMerge3}
+	for i := range strategies {
+		strategy := strategies[i]
+		t.Run(string(strategy), func(t *testing.T) {
+			g := &testutil.TestSetupManager{
+				T:               t,
+				UpstreamChanges: []testutil.Content{{Data: testutil.Dataset2}},
+			}
+			defer g.Clean()
+			if !g.Init(testutil.Dataset1) {
+				return
+			}
+
+			kf, err := ioutil.ReadFile(filepath.Join(g.UpstreamRepo.RepoName, kptfile.KptFileName))
+			if !assert.NoError(t, err) {
+				return
+			}
+
+			b := &bytes.Buffer{}
+			err = Command{
+				Path:            g.UpstreamRepo.RepoName,
+				FullPackagePath: toAbsPath(t, g.UpstreamRepo.RepoName),
+				Strategy:        strategy,
+				DryRun:          true,
+				Output:          b,
+			}.Run()
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Contains(t, b.String(), `--- a/mysql/mysql-statefulset.resource.yaml
++++ b/mysql/mysql-statefulset.resource.yaml
+`)
+			assert.Contains(t, b.String(), `-          initialDelaySeconds: 30
+-          periodSeconds: 10
++          initialDelaySeconds: 45
++          periodSeconds: 15
+           timeoutSeconds: 5
+`)
+
+			// the package isn't changed
+			if !g.AssertLocalDataEquals(testutil.Dataset1) {
+				return
+			}
+			updated, err := ioutil.ReadFile(filepath.Join(g.UpstreamRepo.RepoName, kptfile.KptFileName))
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, string(kf), string(updated))
+		})
+	}
+}
+
+// TestCommand_Run_dryRunFormats verifies the changes are printed in each
+// format
+func TestCommand_Run_dryRunFormats(t *testing.T) {
+	g := &testutil.TestSetupManager{
+		T:               t,
+		UpstreamChanges: []testutil.Content{{Data: testutil.Dataset2}},
+	}
+	defer g.Clean()
+	if !g.Init(testutil.Dataset1) {
+		return
+	}
+
+	b := &bytes.Buffer{}
+	err := Command{
+		Path:            g.UpstreamRepo.RepoName,
+		FullPackagePath: toAbsPath(t, g.UpstreamRepo.RepoName),
+		Strategy:        KResourceMerge,
+		DryRun:          true,
+		DryRunFormat:    DryRunSummary,
+		Output:          b,
+	}.Run()
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, `modified  Kptfile
+modified  java/java-deployment.resource.yaml
+modified  java/java-service.resource.yaml
+modified  mysql/mysql-statefulset.resource.yaml
+modified  wordpress/wordpress-service.resource.yaml
+modified  wordpress/wordpress-statefulset.resource.yaml
+0 added, 6 modified, 0 removed
+`, b.String())
+
+	b.Reset()
+	err = Command{
+		Path:            g.UpstreamRepo.RepoName,
+		FullPackagePath: toAbsPath(t, g.UpstreamRepo.RepoName),
+		Strategy:        KResourceMerge,
+		DryRun:          true,
+		DryRunFormat:    DryRunJSON,
+		Output:          b,
+	}.Run()
+	if !assert.NoError(t, err) {
+		return
+	}
+	var changes []FileChange
+	if !assert.NoError(t, json.Unmarshal(b.Bytes(), &changes)) {
+		return
+	}
+	if assert.Len(t, changes, 6) {
+		assert.Equal(t, "Kptfile", changes[0].Path)
+		assert.Equal(t, Modified, changes[0].Type)
+		assert.Contains(t, changes[0].Diff, "--- a/Kptfile\n")
+	}
+}
+
 // TestCommand_Run_failInvalidPath verifies Run fails if the path is invalid
 func TestCommand_Run_failInvalidPath(t *testing.T) {
 	for i := range updateStrategies {
@@ -981,3 +1922,76 @@ func TestReplaceNonKRMFiles(t *testing.T) {
 		})
 	}
 }
+
+// TestCommand_Run_toLatest verifies that packages are updated to the newest
+// version satisfying the constraint.
+func TestCommand_Run_toLatest(t *testing.T) {
+	tests := []struct {
+		constraint string
+		data       string
+		tag        string
+	}{
+		{constraint: "", data: testutil.Dataset3, tag: "v2.0.0"},
+		{constraint: "^1.0", data: testutil.Dataset2, tag: "v1.1.0"},
+	}
+	for i := range tests {
+		test := tests[i]
+		t.Run(test.tag, func(t *testing.T) {
+			g := &testutil.TestSetupManager{
+				T: t,
+				UpstreamChanges: []testutil.Content{
+					{Data: testutil.Dataset2, Tag: "v1.1.0"},
+					{Data: testutil.Dataset3, Tag: "v2.0.0"},
+				},
+			}
+			defer g.Clean()
+			if !g.Init(testutil.Dataset1) {
+				return
+			}
+
+			if !assert.NoError(t, Command{
+				Path:            g.UpstreamRepo.RepoName,
+				FullPackagePath: toAbsPath(t, g.UpstreamRepo.RepoName),
+				ToLatest:        true,
+				Constraint:      test.constraint,
+			}.Run()) {
+				return
+			}
+			if !g.AssertLocalDataEquals(test.data) {
+				return
+			}
+			if !assert.NoError(t, g.UpstreamRepo.CheckoutBranch(test.tag, false)) {
+				return
+			}
+			commit, err := g.UpstreamRepo.GetCommit()
+			if !assert.NoError(t, err) {
+				return
+			}
+			g.AssertKptfile(g.UpstreamRepo.RepoName, commit, test.tag)
+		})
+	}
+}
+
+// TestCommand_Run_toLatestNoMatch verifies that updating fails if no version
+// satisfies the constraint.
+func TestCommand_Run_toLatestNoMatch(t *testing.T) {
+	g := &testutil.TestSetupManager{
+		T:               t,
+		UpstreamChanges: []testutil.Content{{Data: testutil.Dataset2, Tag: "v1.1.0"}},
+	}
+	defer g.Clean()
+	if !g.Init(testutil.Dataset1) {
+		return
+	}
+
+	err := Command{
+		Path:            g.UpstreamRepo.RepoName,
+		FullPackagePath: toAbsPath(t, g.UpstreamRepo.RepoName),
+		ToLatest:        true,
+		Constraint:      ">=2",
+	}.Run()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), `satisfies ">=2"`)
+	}
+	g.AssertLocalDataEquals(testutil.Dataset1)
+}
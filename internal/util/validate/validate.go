@@ -0,0 +1,625 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package validate checks packages for structural and schema errors.
+package validate
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	kptopenapi "github.com/GoogleContainerTools/kpt/internal/util/openapi"
+	"github.com/GoogleContainerTools/kpt/internal/util/setters"
+	"github.com/GoogleContainerTools/kpt/pkg/kptfile"
+	"github.com/GoogleContainerTools/kpt/pkg/kptfile/kptfileutil"
+	"sigs.k8s.io/kustomize/kyaml/errors"
+	"sigs.k8s.io/kustomize/kyaml/fieldmeta"
+	"sigs.k8s.io/kustomize/kyaml/kio"
+	"sigs.k8s.io/kustomize/kyaml/kio/kioutil"
+	"sigs.k8s.io/kustomize/kyaml/openapi"
+	"sigs.k8s.io/kustomize/kyaml/pathutil"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// Output formats.
+const (
+	TableOutput = "table"
+	JSONOutput  = "json"
+)
+
+// Severities.
+const (
+	// Error findings fail validation
+	Error = "error"
+
+	// Warning findings are reported without failing validation
+	Warning = "warning"
+)
+
+// Checks.
+const (
+	// KptfileCheck checks the Kptfile parses and matches its schema
+	KptfileCheck = "kptfile"
+
+	// UpstreamCheck checks the upstream fields of the Kptfile are well-formed
+	UpstreamCheck = "upstream"
+
+	// SettersCheck checks setters and substitutions are defined and set
+	SettersCheck = "setters"
+
+	// ResourcesCheck checks the resource files parse
+	ResourcesCheck = "resources"
+
+	// DuplicatesCheck checks a package doesn't contain the same resource
+	// twice
+	DuplicatesCheck = "duplicates"
+
+	// SchemaCheck checks the fields of resources have the types of their
+	// OpenAPI schemas
+	SchemaCheck = "schema"
+)
+
+// Finding is a problem found in a package.
+type Finding struct {
+	// File is the path of the file, relative to the validated directory
+	File string `json:"file"`
+
+	// Line is the line of the problem in File, if known.  Lines are only
+	// known for problems in Kptfiles, resources are identified by the
+	// message instead.
+	Line int `json:"line,omitempty"`
+
+	// Severity is Error or Warning
+	Severity string `json:"severity"`
+
+	// Check is the check which found the problem
+	Check string `json:"check"`
+
+	// Message describes the problem
+	Message string `json:"message"`
+}
+
+// Location returns the file and line of the finding.
+func (f Finding) Location() string {
+	if f.Line > 0 {
+		return fmt.Sprintf("%s:%d", f.File, f.Line)
+	}
+	return f.File
+}
+
+// Command validates the packages under Dir.
+type Command struct {
+	// Dir is the directory to validate the packages under
+	Dir string
+
+	// Output is the format of the findings, TableOutput or JSONOutput
+	Output string
+
+	// StdOut is where the findings are written
+	StdOut io.Writer
+}
+
+// Run writes the findings to StdOut, and returns an error if any of them
+// are errors.
+func (c Command) Run() error {
+	if c.Output == "" {
+		c.Output = TableOutput
+	}
+	if c.Output != TableOutput && c.Output != JSONOutput {
+		return errors.Errorf("unsupported output %q, must be one of %s, %s",
+			c.Output, TableOutput, JSONOutput)
+	}
+	findings, err := Validate(c.Dir)
+	if err != nil {
+		return err
+	}
+	if c.Output == JSONOutput {
+		if findings == nil {
+			findings = []Finding{}
+		}
+		e := json.NewEncoder(c.StdOut)
+		e.SetIndent("", "  ")
+		if err := e.Encode(findings); err != nil {
+			return errors.Wrap(err)
+		}
+	} else if err := WriteTable(c.StdOut, findings); err != nil {
+		return err
+	}
+	var n int
+	for _, f := range findings {
+		if f.Severity == Error {
+			n++
+		}
+	}
+	if n > 0 {
+		return errors.Errorf("validation failed with %d error(s)", n)
+	}
+	return nil
+}
+
+// WriteTable writes the findings to w as a table.
+func WriteTable(w io.Writer, findings []Finding) error {
+	if len(findings) == 0 {
+		_, err := fmt.Fprintln(w, "no problems found")
+		return err
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(tw, "FILE\tSEVERITY\tCHECK\tMESSAGE")
+	for _, f := range findings {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", f.Location(), f.Severity, f.Check, f.Message)
+	}
+	return errors.Wrap(tw.Flush())
+}
+
+// Validate returns the findings for the packages under dir, sorted by file
+// and line.
+func Validate(dir string) ([]Finding, error) {
+	paths, err := pathutil.DirsWithFile(dir, kptfile.KptFileName, true)
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	if len(paths) == 0 {
+		return nil, errors.Errorf("no packages found under %s", dir)
+	}
+	var validators []*validator
+	for _, p := range paths {
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return nil, errors.Wrap(err)
+		}
+		v := &validator{dir: p, rel: rel}
+		// the schemas of every package are added first, as resources may
+		// be of the custom resources of other packages
+		if err := kptopenapi.AddPackageSchemas(p); err != nil {
+			v.add(kptopenapi.SchemasDir, 0, Error, SchemaCheck, "%v", err)
+		}
+		validators = append(validators, v)
+	}
+	var findings []Finding
+	for _, v := range validators {
+		v.validate()
+		findings = append(findings, v.findings...)
+	}
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].File != findings[j].File {
+			return findings[i].File < findings[j].File
+		}
+		return findings[i].Line < findings[j].Line
+	})
+	return findings, nil
+}
+
+// validator collects the findings for a single package.
+type validator struct {
+	// dir is the package directory
+	dir string
+
+	// rel is the package directory relative to the validated directory
+	rel string
+
+	findings []Finding
+}
+
+func (v *validator) add(file string, line int, severity, check, format string, args ...interface{}) {
+	v.findings = append(v.findings, Finding{
+		File:     filepath.ToSlash(filepath.Join(v.rel, file)),
+		Line:     line,
+		Severity: severity,
+		Check:    check,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+func (v *validator) validate() {
+	k, err := kptfileutil.ReadFile(v.dir)
+	if err != nil {
+		v.add(kptfile.KptFileName, 0, Error, KptfileCheck, "%v", err)
+	} else {
+		v.validateKptfile(k)
+		v.validateUpstream(k.Upstream)
+	}
+	n, err := yaml.ReadFile(filepath.Join(v.dir, kptfile.KptFileName))
+	if err == nil {
+		v.validateDefinitions(n)
+	}
+	v.validateResources(n)
+}
+
+func (v *validator) validateKptfile(k kptfile.KptFile) {
+	if k.APIVersion != kptfile.KptFileAPIVersion {
+		v.add(kptfile.KptFileName, 0, Error, KptfileCheck,
+			"apiVersion must be %s, got %q", kptfile.KptFileAPIVersion, k.APIVersion)
+	}
+	if k.Kind != kptfile.KptFileName {
+		v.add(kptfile.KptFileName, 0, Error, KptfileCheck,
+			"kind must be %s, got %q", kptfile.KptFileName, k.Kind)
+	}
+	if k.Name == "" {
+		v.add(kptfile.KptFileName, 0, Warning, KptfileCheck, "metadata.name is not set")
+	}
+	if k.Inventory != nil {
+		if _, err := kptfileutil.ValidateInventory(k.Inventory); err != nil {
+			v.add(kptfile.KptFileName, 0, Error, KptfileCheck, "%v", err)
+		}
+	}
+	for i, h := range k.Hooks.PostUpdate {
+		if (h.Image == "") == (h.Starlark == "") {
+			v.add(kptfile.KptFileName, 0, Error, KptfileCheck,
+				"hooks.postUpdate[%d] must set exactly one of image and starlark", i)
+		}
+	}
+	for _, p := range []struct {
+		field string
+		fns   []kptfile.PipelineFunction
+	}{{"pipeline.mutators", k.Pipeline.Mutators}, {"pipeline.validators", k.Pipeline.Validators}} {
+		for i, f := range p.fns {
+			set := 0
+			for _, s := range []string{f.Image, f.Exec, f.Starlark, f.Wasm, f.Endpoint} {
+				if s != "" {
+					set++
+				}
+			}
+			if set != 1 {
+				v.add(kptfile.KptFileName, 0, Error, KptfileCheck,
+					"%s[%d] must set exactly one of image, exec, starlark, wasm and endpoint", p.field, i)
+			}
+			if f.Config.Kind != 0 && f.ConfigPath != "" {
+				v.add(kptfile.KptFileName, 0, Error, KptfileCheck,
+					"%s[%d] must set only one of config and configPath", p.field, i)
+			}
+			if u, err := url.Parse(f.Wasm); err == nil && (u.Scheme == "http" || u.Scheme == "https") &&
+				f.SHA256 == "" {
+				v.add(kptfile.KptFileName, 0, Error, KptfileCheck,
+					"%s[%d] must set the sha256 digest of the wasm url", p.field, i)
+			}
+		}
+	}
+	for i, r := range k.ConflictRules {
+		if r.Prefer != kptfile.PreferLocal && r.Prefer != kptfile.PreferUpstream {
+			v.add(kptfile.KptFileName, 0, Error, KptfileCheck,
+				"conflictRules[%d].prefer must be %s or %s", i, kptfile.PreferLocal, kptfile.PreferUpstream)
+		}
+		if len(r.Fields) == 0 {
+			v.add(kptfile.KptFileName, 0, Error, KptfileCheck, "conflictRules[%d].fields is empty", i)
+		}
+	}
+}
+
+var commitPattern = regexp.MustCompile(`^[0-9a-f]{7,40}$`)
+
+func (v *validator) validateUpstream(u kptfile.Upstream) {
+	missing := func(field string) {
+		v.add(kptfile.KptFileName, 0, Error, UpstreamCheck, "upstream.%s is not set", field)
+	}
+	switch u.Type {
+	case "":
+		if u.Git.Repo != "" || u.Helm.Chart != "" || u.Stdin.Original != "" {
+			v.add(kptfile.KptFileName, 0, Error, UpstreamCheck, "upstream.type is not set")
+		}
+	case kptfile.GitOrigin:
+		g := u.Git
+		if g.Repo == "" {
+			missing("git.repo")
+		} else if !strings.Contains(g.Repo, "://") && !strings.Contains(g.Repo, "@") &&
+			!filepath.IsAbs(g.Repo) {
+			v.add(kptfile.KptFileName, 0, Error, UpstreamCheck,
+				"upstream.git.repo %q is not a url or absolute path", g.Repo)
+		}
+		if g.Directory == "" {
+			missing("git.directory")
+		}
+		if g.Ref == "" {
+			missing("git.ref")
+		}
+		if g.Commit == "" {
+			missing("git.commit")
+		} else if !commitPattern.MatchString(g.Commit) {
+			v.add(kptfile.KptFileName, 0, Error, UpstreamCheck,
+				"upstream.git.commit %q is not a commit sha", g.Commit)
+		}
+	case kptfile.StdinOrigin:
+		if u.Stdin.FilenamePattern == "" {
+			missing("stdin.filenamePattern")
+		}
+		if u.Stdin.Original == "" {
+			missing("stdin.original")
+		}
+	case kptfile.HelmOrigin:
+		if u.Helm.Chart == "" {
+			missing("helm.chart")
+		}
+	default:
+		v.add(kptfile.KptFileName, 0, Error, UpstreamCheck,
+			"unknown upstream.type %q, must be one of %s, %s, %s",
+			u.Type, kptfile.GitOrigin, kptfile.HelmOrigin, kptfile.StdinOrigin)
+	}
+}
+
+// definitions returns the definitions of the setters and substitutions in
+// the Kptfile, keyed by name.
+func definitions(kf *yaml.RNode, prefix string) map[string]*yaml.MapNode {
+	defs := map[string]*yaml.MapNode{}
+	if kf == nil {
+		return defs
+	}
+	n, err := kf.Pipe(yaml.Lookup("openAPI", "definitions"))
+	if err != nil || n == nil {
+		return defs
+	}
+	_ = n.VisitFields(func(f *yaml.MapNode) error {
+		if key := f.Key.YNode().Value; strings.HasPrefix(key, prefix) {
+			defs[strings.TrimPrefix(key, prefix)] = f
+		}
+		return nil
+	})
+	return defs
+}
+
+func (v *validator) validateDefinitions(kf *yaml.RNode) {
+	setterDefs := definitions(kf, fieldmeta.SetterDefinitionPrefix)
+	for name, f := range setterDefs {
+		s, _ := f.Value.Pipe(yaml.Lookup("x-k8s-cli", "setter"))
+		if s == nil {
+			v.add(kptfile.KptFileName, f.Key.YNode().Line, Error, SettersCheck,
+				"setter %q has no x-k8s-cli.setter", name)
+		} else if n := s.Field("name"); n == nil || n.Value.YNode().Value != name {
+			v.add(kptfile.KptFileName, f.Key.YNode().Line, Error, SettersCheck,
+				"setter %q has a different x-k8s-cli.setter.name", name)
+		}
+	}
+	for name, f := range definitions(kf, fieldmeta.SubstitutionDefinitionPrefix) {
+		s, _ := f.Value.Pipe(yaml.Lookup("x-k8s-cli", "substitution"))
+		if s == nil {
+			v.add(kptfile.KptFileName, f.Key.YNode().Line, Error, SettersCheck,
+				"substitution %q has no x-k8s-cli.substitution", name)
+			continue
+		}
+		pattern := ""
+		if p := s.Field("pattern"); p != nil {
+			pattern = p.Value.YNode().Value
+		}
+		values, _ := s.Pipe(yaml.Lookup("values"))
+		if values == nil {
+			continue
+		}
+		elements, _ := values.Elements()
+		for _, e := range elements {
+			marker, ref := yaml.GetValue(e.Field("marker").Value), yaml.GetValue(e.Field("ref").Value)
+			if !strings.Contains(pattern, marker) {
+				v.add(kptfile.KptFileName, e.YNode().Line, Error, SettersCheck,
+					"substitution %q marker %q is not in its pattern", name, marker)
+			}
+			setter := strings.TrimPrefix(ref, fieldmeta.DefinitionsPrefix+fieldmeta.SetterDefinitionPrefix)
+			if _, found := setterDefs[setter]; !found || setter == ref {
+				v.add(kptfile.KptFileName, e.YNode().Line, Error, SettersCheck,
+					"substitution %q references undefined setter %q", name, ref)
+			}
+		}
+	}
+	unset, err := setters.UnsetRequiredSetters(v.dir)
+	if err != nil {
+		v.add(kptfile.KptFileName, 0, Error, SettersCheck, "%v", err)
+	}
+	for _, s := range unset {
+		if filepath.Clean(s.Package) != filepath.Clean(v.dir) {
+			// subpackages are validated on their own
+			continue
+		}
+		line := 0
+		if d, found := setterDefs[s.Name]; found {
+			line = d.Key.YNode().Line
+		}
+		if s.Placeholder {
+			v.add(kptfile.KptFileName, line, Error, SettersCheck,
+				"required setter %q still holds its placeholder %q", s.Name, s.Value)
+		} else {
+			v.add(kptfile.KptFileName, line, Error, SettersCheck, "required setter %q is not set", s.Name)
+		}
+	}
+	captureDefs := definitions(kf, setters.CaptureDefinitionPrefix)
+	captureErrs, err := setters.CheckCaptures(v.dir)
+	if err != nil {
+		v.add(kptfile.KptFileName, 0, Error, SettersCheck, "%v", err)
+	}
+	for name, err := range captureErrs {
+		line := 0
+		if d, found := captureDefs[name]; found {
+			line = d.Key.YNode().Line
+		}
+		v.add(kptfile.KptFileName, line, Error, SettersCheck, "%v", err)
+	}
+	errs, err := setters.ValidateSetterValues(v.dir)
+	if err != nil {
+		v.add(kptfile.KptFileName, 0, Error, SettersCheck, "%v", err)
+	}
+	for name, err := range errs {
+		line := 0
+		if d, found := setterDefs[name]; found {
+			line = d.Key.YNode().Line
+		}
+		v.add(kptfile.KptFileName, line, Error, SettersCheck, "%v", err)
+	}
+}
+
+// refPattern matches the setter and substitution references in field
+// comments.
+var refPattern = regexp.MustCompile(`^\s*#?\s*(\{.*\})\s*$`)
+
+// reference returns the name of the setter or substitution referenced by
+// a field comment, and whether the comment references one.
+func reference(comment string) (string, bool) {
+	m := refPattern.FindStringSubmatch(comment)
+	if m == nil {
+		return "", false
+	}
+	ref := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(m[1]), &ref); err != nil {
+		return "", false
+	}
+	for _, key := range []string{"$kpt-set", "$openapi", setters.CaptureRef, fieldmeta.ShortHandRef()} {
+		if name, ok := ref[key].(string); ok {
+			return name, true
+		}
+	}
+	if r, ok := ref["$ref"].(string); ok {
+		return strings.TrimPrefix(r, fieldmeta.DefinitionsPrefix), true
+	}
+	return "", false
+}
+
+func (v *validator) validateResources(kf *yaml.RNode) {
+	known := map[string]bool{}
+	for name := range definitions(kf, fieldmeta.SetterDefinitionPrefix) {
+		known[name] = true
+		known[fieldmeta.SetterDefinitionPrefix+name] = true
+	}
+	for name := range definitions(kf, fieldmeta.SubstitutionDefinitionPrefix) {
+		known[name] = true
+		known[fieldmeta.SubstitutionDefinitionPrefix+name] = true
+	}
+	for name := range definitions(kf, setters.CaptureDefinitionPrefix) {
+		known[name] = true
+	}
+
+	nodes, err := (&kio.LocalPackageReader{
+		PackagePath: v.dir, PackageFileName: kptfile.KptFileName}).Read()
+	if err != nil {
+		v.add("", 0, Error, ResourcesCheck, "%v", err)
+		return
+	}
+	seen := map[string]string{}
+	for _, n := range nodes {
+		meta, err := n.GetMeta()
+		if err != nil {
+			continue
+		}
+		file := meta.Annotations[kioutil.PathAnnotation]
+		if file == kptfile.KptFileName {
+			continue
+		}
+		var walk func(*yaml.Node)
+		walk = func(y *yaml.Node) {
+			for _, c := range []string{y.LineComment, y.HeadComment} {
+				if name, ok := reference(c); ok && !known[name] {
+					v.add(file, 0, Error, SettersCheck,
+						"%s %q references undefined setter or substitution %q",
+						meta.Kind, meta.Name, name)
+				}
+			}
+			for _, c := range y.Content {
+				walk(c)
+			}
+		}
+		walk(n.YNode())
+		v.validateSchema(file, meta, n)
+
+		if meta.Kind == "" || meta.Name == "" {
+			continue
+		}
+		group := meta.APIVersion
+		if i := strings.LastIndex(group, "/"); i >= 0 {
+			group = group[:i]
+		} else {
+			group = ""
+		}
+		key := strings.Join([]string{group, meta.Kind, meta.Namespace, meta.Name}, "/")
+		if other, found := seen[key]; found {
+			v.add(file, 0, Error, DuplicatesCheck,
+				"%s %q is also declared in %s", meta.Kind, meta.Name,
+				filepath.ToSlash(filepath.Join(v.rel, other)))
+			continue
+		}
+		seen[key] = file
+	}
+}
+
+// validateSchema checks the fields of the resource n have the types of the
+// OpenAPI schema of its type, if it has one.  The schema is the builtin
+// Kubernetes schema, or the one read with --k8s-schema-source, which
+// includes the CRDs installed in the cluster when read from it, and the
+// schemas of the packages.
+func (v *validator) validateSchema(file string, meta yaml.ResourceMeta, n *yaml.RNode) {
+	s := openapi.SchemaForResourceType(meta.TypeMeta)
+	if s == nil {
+		return
+	}
+	var check func(y *yaml.Node, s *openapi.ResourceSchema, path string)
+	check = func(y *yaml.Node, s *openapi.ResourceSchema, path string) {
+		for y.Kind == yaml.AliasNode && y.Alias != nil {
+			y = y.Alias
+		}
+		if s.IsMissingOrNull() || len(s.Schema.Type) != 1 || y.ShortTag() == yaml.NodeTagNull {
+			return
+		}
+		typ := s.Schema.Type[0]
+		if !hasType(y, typ) {
+			v.add(file, 0, Error, SchemaCheck, "%s %q field %s must be %s %s",
+				meta.Kind, meta.Name, path, article(typ), typ)
+			return
+		}
+		switch typ {
+		case "object":
+			for i := 0; i+1 < len(y.Content); i += 2 {
+				field := y.Content[i].Value
+				p := field
+				if path != "" {
+					p = path + "." + field
+				}
+				check(y.Content[i+1], s.Field(field), p)
+			}
+		case "array":
+			elements := s.Elements()
+			for i, c := range y.Content {
+				check(c, elements, fmt.Sprintf("%s[%d]", path, i))
+			}
+		}
+	}
+	check(n.YNode(), s, "")
+}
+
+// hasType returns true if y is a value of the OpenAPI type typ.  Strings
+// may be any scalar, as e.g. quantities may be written as numbers.
+func hasType(y *yaml.Node, typ string) bool {
+	switch typ {
+	case "object":
+		return y.Kind == yaml.MappingNode
+	case "array":
+		return y.Kind == yaml.SequenceNode
+	case "string":
+		return y.Kind == yaml.ScalarNode
+	case "integer":
+		return y.Kind == yaml.ScalarNode && y.ShortTag() == yaml.NodeTagInt
+	case "number":
+		return y.Kind == yaml.ScalarNode &&
+			(y.ShortTag() == yaml.NodeTagInt || y.ShortTag() == yaml.NodeTagFloat)
+	case "boolean":
+		return y.Kind == yaml.ScalarNode && y.ShortTag() == yaml.NodeTagBool
+	}
+	return true
+}
+
+// article returns the indefinite article of the OpenAPI type typ.
+func article(typ string) string {
+	if typ == "array" || typ == "object" || typ == "integer" {
+		return "an"
+	}
+	return "a"
+}
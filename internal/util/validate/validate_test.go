@@ -0,0 +1,301 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt/internal/testutil/pkgbuilder"
+	"github.com/GoogleContainerTools/kpt/internal/util/validate"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/kustomize/kyaml/openapi"
+)
+
+func TestValidate(t *testing.T) {
+	dir := pkgbuilder.WritePackage(t, map[string]string{
+		"Kptfile": `apiVersion: kpt.dev/v1alpha1
+kind: Kptfile
+metadata:
+  name: pkg
+upstream:
+  type: git
+  git:
+    repo: https://github.com/example/repo
+    directory: /pkg
+    ref: main
+    commit: not-a-commit
+openAPI:
+  definitions:
+    io.k8s.cli.setters.replicas:
+      x-k8s-cli:
+        setter:
+          name: replicas
+          value: "3"
+    io.k8s.cli.substitutions.image:
+      x-k8s-cli:
+        substitution:
+          name: image
+          pattern: ${image}:${tag}
+          values:
+          - marker: ${image}
+            ref: '#/definitions/io.k8s.cli.setters.image'
+    io.k8s.cli.setters.env:
+      type: string
+      enum: [dev, prod]
+      x-k8s-cli:
+        setter:
+          name: env
+          value: staging
+          isSet: true
+`,
+		"deploy.yaml": `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+spec:
+  replicas: 3 # {"$kpt-set":"replicas"}
+  paused: false # {"$kpt-set":"paused"}
+---
+apiVersion: apps/v1beta1
+kind: Deployment
+metadata:
+  name: app
+`,
+		"sub/Kptfile": `apiVersion: kpt.dev/v1alpha1
+kind: Kptfile
+metadata:
+  name: sub
+pipeline:
+  mutators:
+  - wasm: https://example.com/scale.wasm
+`,
+		"sub/deploy.yaml": `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+`,
+	})
+	defer os.RemoveAll(dir)
+
+	findings, err := validate.Validate(dir)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, []validate.Finding{
+		{File: "Kptfile", Severity: validate.Error, Check: validate.UpstreamCheck,
+			Message: `upstream.git.commit "not-a-commit" is not a commit sha`},
+		{File: "Kptfile", Line: 25, Severity: validate.Error, Check: validate.SettersCheck,
+			Message: `substitution "image" references undefined setter "#/definitions/io.k8s.cli.setters.image"`},
+		{File: "Kptfile", Line: 27, Severity: validate.Error, Check: validate.SettersCheck,
+			Message: `invalid value for setter "env": violates enum [dev, prod]: got "staging"`},
+		{File: "deploy.yaml", Severity: validate.Error, Check: validate.SettersCheck,
+			Message: `Deployment "app" references undefined setter or substitution "paused"`},
+		{File: "deploy.yaml", Severity: validate.Error, Check: validate.DuplicatesCheck,
+			Message: `Deployment "app" is also declared in deploy.yaml`},
+		{File: "sub/Kptfile", Severity: validate.Error, Check: validate.KptfileCheck,
+			Message: "pipeline.mutators[0] must set the sha256 digest of the wasm url"},
+	}, findings)
+}
+
+func TestValidate_schema(t *testing.T) {
+	// the schema of a custom resource, like those read from the cluster
+	// with --k8s-schema-source cluster
+	openapi.ResetOpenAPI()
+	defer openapi.ResetOpenAPI()
+	err := openapi.AddSchema([]byte(`{
+  "definitions": {
+    "com.example.v1.Database": {
+      "type": "object",
+      "properties": {
+        "spec": {
+          "type": "object",
+          "properties": {
+            "replicas": {"type": "integer"},
+            "users": {"type": "array", "items": {"type": "string"}}
+          }
+        }
+      },
+      "x-kubernetes-group-version-kind": [{"group": "example.com", "kind": "Database", "version": "v1"}]
+    }
+  }
+}`))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	dir := pkgbuilder.WritePackage(t, map[string]string{
+		"Kptfile": `apiVersion: kpt.dev/v1alpha1
+kind: Kptfile
+metadata:
+  name: pkg
+`,
+		"deploy.yaml": `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+  labels: &labels
+    app: app
+spec:
+  replicas: "3"
+  paused: false
+  selector:
+    matchLabels: *labels
+  template:
+    spec:
+      containers:
+        name: app
+      terminationGracePeriodSeconds: 1.5
+`,
+		"db.yaml": `apiVersion: example.com/v1
+kind: Database
+metadata:
+  name: db
+spec:
+  replicas: 3
+  users: admin
+`,
+	})
+	defer os.RemoveAll(dir)
+
+	findings, err := validate.Validate(dir)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, []validate.Finding{
+		{File: "db.yaml", Severity: validate.Error, Check: validate.SchemaCheck,
+			Message: `Database "db" field spec.users must be an array`},
+		{File: "deploy.yaml", Severity: validate.Error, Check: validate.SchemaCheck,
+			Message: `Deployment "app" field spec.replicas must be an integer`},
+		{File: "deploy.yaml", Severity: validate.Error, Check: validate.SchemaCheck,
+			Message: `Deployment "app" field spec.template.spec.containers must be an array`},
+		{File: "deploy.yaml", Severity: validate.Error, Check: validate.SchemaCheck,
+			Message: `Deployment "app" field spec.template.spec.terminationGracePeriodSeconds must be an integer`},
+	}, findings)
+}
+
+func TestValidate_packageSchemas(t *testing.T) {
+	openapi.ResetOpenAPI()
+	defer openapi.ResetOpenAPI()
+
+	dir := pkgbuilder.WritePackage(t, map[string]string{
+		"Kptfile": `apiVersion: kpt.dev/v1alpha1
+kind: Kptfile
+metadata:
+  name: pkg
+`,
+		"schemas/database.json": `{
+  "definitions": {
+    "com.example.v1.Database": {
+      "type": "object",
+      "properties": {
+        "spec": {
+          "type": "object",
+          "properties": {
+            "replicas": {"type": "integer"}
+          }
+        }
+      },
+      "x-kubernetes-group-version-kind": [{"group": "example.com", "kind": "Database", "version": "v1"}]
+    }
+  }
+}`,
+		"db.yaml": `apiVersion: example.com/v1
+kind: Database
+metadata:
+  name: db
+spec:
+  replicas: three
+`,
+		"sub/Kptfile": `apiVersion: kpt.dev/v1alpha1
+kind: Kptfile
+metadata:
+  name: sub
+`,
+		"sub/schemas/cache.json": `{
+  "definitions": {
+    "com.example.v1.Cache": {
+      "type": "object",
+      "x-kubernetes-group-version-kind": [{"group": "example.com", "kind": "Cache", "version": 1}]
+    }
+  }
+}`,
+	})
+	defer os.RemoveAll(dir)
+
+	findings, err := validate.Validate(dir)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	if !assert.Len(t, findings, 2) {
+		t.FailNow()
+	}
+	assert.Equal(t, validate.Finding{File: "db.yaml", Severity: validate.Error, Check: validate.SchemaCheck,
+		Message: `Database "db" field spec.replicas must be an integer`}, findings[0])
+	assert.Equal(t, filepath.Join("sub", "schemas"), findings[1].File)
+	assert.Equal(t, validate.SchemaCheck, findings[1].Check)
+	assert.Contains(t, findings[1].Message, "x-kubernetes-group-version-kind version must be a string")
+}
+
+func TestCommand_Run(t *testing.T) {
+	dir := pkgbuilder.WritePackage(t, map[string]string{
+		"Kptfile": `apiVersion: kpt.dev/v1alpha1
+kind: Kptfile
+metadata:
+  name: pkg
+upstream:
+  type: oci
+`,
+		"sub/Kptfile": `apiVersion: kpt.dev/v1alpha1
+kind: Kptfile
+unknown: field
+`,
+	})
+	defer os.RemoveAll(dir)
+
+	out := &bytes.Buffer{}
+	err := validate.Command{Dir: dir, Output: validate.JSONOutput, StdOut: out}.Run()
+	assert.EqualError(t, err, "validation failed with 2 error(s)")
+
+	var findings []validate.Finding
+	if !assert.NoError(t, json.Unmarshal(out.Bytes(), &findings)) {
+		t.FailNow()
+	}
+	if assert.Len(t, findings, 2) {
+		assert.Equal(t, "Kptfile", findings[0].File)
+		assert.Equal(t, validate.UpstreamCheck, findings[0].Check)
+		assert.Equal(t, "sub/Kptfile", findings[1].File)
+		assert.Equal(t, validate.KptfileCheck, findings[1].Check)
+	}
+}
+
+func TestCommand_Run_valid(t *testing.T) {
+	dir := pkgbuilder.WritePackage(t, map[string]string{
+		"Kptfile": `apiVersion: kpt.dev/v1alpha1
+kind: Kptfile
+metadata:
+  name: pkg
+`,
+	})
+	defer os.RemoveAll(dir)
+
+	out := &bytes.Buffer{}
+	err := validate.Command{Dir: dir, StdOut: out}.Run()
+	assert.NoError(t, err)
+	assert.Equal(t, "no problems found\n", out.String())
+}
@@ -0,0 +1,331 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package vendored materializes the upstreams of packages into a vendor
+// tree, so packages can be fetched and updated without network access.
+package vendored
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/GoogleContainerTools/kpt/internal/gitutil"
+	"github.com/GoogleContainerTools/kpt/internal/util/git"
+	"github.com/GoogleContainerTools/kpt/pkg/kptfile"
+	"github.com/GoogleContainerTools/kpt/pkg/kptfile/kptfileutil"
+	"sigs.k8s.io/kustomize/kyaml/copyutil"
+	"sigs.k8s.io/kustomize/kyaml/errors"
+	"sigs.k8s.io/kustomize/kyaml/pathutil"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+const (
+	// DirName is the name of the vendor tree directory
+	DirName = "vendor"
+
+	// ManifestFileName is the name of the manifest in the vendor tree
+	ManifestFileName = "vendor.yaml"
+)
+
+// Manifest records the packages in a vendor tree.
+type Manifest struct {
+	Packages []Package `yaml:"packages,omitempty"`
+}
+
+// Package is a vendored package.
+type Package struct {
+	// Repo, Directory and Ref are the upstream the package was vendored from
+	Repo      string `yaml:"repo"`
+	Directory string `yaml:"directory"`
+	Ref       string `yaml:"ref"`
+
+	// TagTemplate names the tags which version the package
+	TagTemplate string `yaml:"tagTemplate,omitempty"`
+
+	// Commit is the commit Ref resolved to
+	Commit string `yaml:"commit"`
+
+	// Path is the directory of the package, relative to the vendor tree
+	Path string `yaml:"path"`
+}
+
+// ReadManifest reads the manifest of the vendor tree dir.
+func ReadManifest(dir string) (Manifest, error) {
+	var m Manifest
+	b, err := ioutil.ReadFile(filepath.Join(dir, ManifestFileName))
+	if err != nil {
+		return m, errors.Wrap(err)
+	}
+	if err := yaml.Unmarshal(b, &m); err != nil {
+		return m, errors.Errorf("invalid vendor manifest %s: %v",
+			filepath.Join(dir, ManifestFileName), err)
+	}
+	return m, nil
+}
+
+// Lookup returns the package vendored from directory of repo at ref, which
+// may also be the commit the package was vendored at.
+func (m Manifest) Lookup(repo, directory, ref string) (Package, bool) {
+	for _, p := range m.Packages {
+		if p.Repo == repo && cleanDirectory(p.Directory) == cleanDirectory(directory) &&
+			(p.Ref == ref || p.Commit == ref) {
+			return p, true
+		}
+	}
+	return Package{}, false
+}
+
+// Find returns the vendor tree of dir -- the vendor directory with a
+// manifest in dir or the closest of its parents.
+func Find(dir string) (string, bool) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", false
+	}
+	for {
+		v := filepath.Join(dir, DirName)
+		if _, err := os.Stat(filepath.Join(v, ManifestFileName)); err == nil {
+			return v, true
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", false
+		}
+		dir = parent
+	}
+}
+
+// Lookup returns the directory and commit of the package vendored from
+// directory of repo at ref, in the vendor tree of the working directory.
+func Lookup(repo, directory, ref string) (string, string, bool, error) {
+	dir, found := Find(".")
+	if !found {
+		return "", "", false, nil
+	}
+	m, err := ReadManifest(dir)
+	if err != nil {
+		return "", "", false, err
+	}
+	p, found := m.Lookup(repo, directory, ref)
+	if !found {
+		return "", "", false, nil
+	}
+	return filepath.Join(dir, filepath.FromSlash(p.Path)), p.Commit, true, nil
+}
+
+// Command vendors the upstreams of the packages in a directory.
+type Command struct {
+	// Path is the directory containing the packages.  The vendor tree is
+	// written to its vendor directory.
+	Path string
+
+	// Cloner clones the upstream packages, e.g. get.ClonerUsingGitExec.
+	Cloner func(repoSpec *git.RepoSpec) error
+
+	// StdOut receives a line for each vendored package
+	StdOut io.Writer
+}
+
+// source is an upstream to vendor.
+type source struct {
+	repo, directory, ref, tagTemplate string
+}
+
+// Run vendors the upstream and dependencies of each package under Path, and
+// the dependencies of the vendored packages, replacing the vendor tree.
+func (c Command) Run() error {
+	if c.StdOut == nil {
+		c.StdOut = ioutil.Discard
+	}
+	vendorDir := filepath.Join(c.Path, DirName)
+
+	// the new tree is staged next to the vendor tree so the old tree is kept
+	// if vendoring fails
+	staging, err := ioutil.TempDir(c.Path, ".vendor-")
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	defer os.RemoveAll(staging)
+
+	queue, err := sources(c.Path, vendorDir, false)
+	if err != nil {
+		return err
+	}
+	seen := map[source]bool{}
+	byCommit := map[source]string{}
+	var m Manifest
+	for len(queue) > 0 {
+		s := queue[0]
+		queue = queue[1:]
+		if seen[s] {
+			continue
+		}
+		seen[s] = true
+
+		r := &git.RepoSpec{OrgRepo: s.repo, Path: s.directory, Ref: s.ref, TagTemplate: s.tagTemplate}
+		if err := c.Cloner(r); err != nil {
+			return errors.Errorf("failed to vendor %s: %v", describe(s), err)
+		}
+		p, vendored, err := c.vendor(r, s, staging, byCommit)
+		_ = os.RemoveAll(r.Dir)
+		if err != nil {
+			return err
+		}
+		m.Packages = append(m.Packages, p)
+		if !vendored {
+			continue
+		}
+		fmt.Fprintf(c.StdOut, "vendored %s (%s) to %s\n", describe(s), p.Commit,
+			filepath.Join(vendorDir, filepath.FromSlash(p.Path)))
+
+		// dependencies of the vendored package are vendored too
+		deps, err := sources(filepath.Join(staging, filepath.FromSlash(p.Path)), "", true)
+		if err != nil {
+			return err
+		}
+		queue = append(queue, deps...)
+	}
+
+	sort.Slice(m.Packages, func(i, j int) bool {
+		a, b := m.Packages[i], m.Packages[j]
+		if a.Repo != b.Repo {
+			return a.Repo < b.Repo
+		}
+		if a.Directory != b.Directory {
+			return a.Directory < b.Directory
+		}
+		return a.Ref < b.Ref
+	})
+	b, err := yaml.Marshal(m)
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(staging, ManifestFileName), b, 0600); err != nil {
+		return errors.Wrap(err)
+	}
+	if err := os.RemoveAll(vendorDir); err != nil {
+		return errors.Wrap(err)
+	}
+	return errors.Wrap(os.Rename(staging, vendorDir))
+}
+
+// vendor copies the package cloned to r from source s to the staging vendor
+// tree, unless the same commit was already vendored.  Returns the manifest
+// entry, and whether the package was copied.
+func (c Command) vendor(r *git.RepoSpec, s source, staging string, byCommit map[source]string) (
+	Package, bool, error) {
+	cmd := exec.Command("git", "rev-parse", "--verify", "HEAD")
+	cmd.Dir = r.Dir
+	b, err := cmd.Output()
+	if err != nil {
+		return Package{}, false, errors.Errorf("failed to vendor %s: %v", describe(s), err)
+	}
+	commit := strings.TrimSpace(string(b))
+	p := Package{Repo: s.repo, Directory: s.directory, Ref: s.ref, TagTemplate: s.tagTemplate,
+		Commit: commit}
+
+	key := source{repo: s.repo, directory: cleanDirectory(s.directory), ref: commit}
+	if existing, found := byCommit[key]; found {
+		p.Path = existing
+		return p, false, nil
+	}
+	short := commit
+	if len(short) > 12 {
+		short = short[:12]
+	}
+	p.Path = path.Join(repoPath(s.repo), cleanDirectory(s.directory)) + "@" + short
+	byCommit[key] = p.Path
+
+	if _, err := os.Stat(r.AbsPath()); err != nil {
+		return Package{}, false, errors.Errorf("failed to vendor %s: missing directory %q",
+			describe(s), s.directory)
+	}
+	if err := copyutil.CopyDir(r.AbsPath(), filepath.Join(staging, filepath.FromSlash(p.Path))); err != nil {
+		return Package{}, false, errors.Wrap(err)
+	}
+	return p, true, nil
+}
+
+// sources returns the upstreams of the packages under dir, skipping the
+// vendor tree vendorDir.  Only the dependencies of the packages are returned
+// if depsOnly is set.
+func sources(dir, vendorDir string, depsOnly bool) ([]source, error) {
+	paths, err := pathutil.DirsWithFile(dir, kptfile.KptFileName, true)
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	sort.Strings(paths)
+	var srcs []source
+	add := func(g kptfile.Git, ref string) {
+		// relative upstreams are in the repo being vendored
+		if g.Repo == "" || ref == "" || gitutil.IsRelativeRepo(g.Repo) {
+			return
+		}
+		srcs = append(srcs, source{repo: g.Repo, directory: g.Directory, ref: ref,
+			tagTemplate: g.TagTemplate})
+	}
+	for _, p := range paths {
+		if vendorDir != "" && (p == vendorDir || strings.HasPrefix(p, vendorDir+string(filepath.Separator))) {
+			continue
+		}
+		k, err := kptfileutil.ReadFile(p)
+		if err != nil {
+			return nil, err
+		}
+		if !depsOnly && k.Upstream.Type == kptfile.GitOrigin {
+			add(k.Upstream.Git, k.Upstream.Git.Ref)
+			// the fetched commit is the base of 3-way merges when updating
+			add(k.Upstream.Git, k.Upstream.Git.Commit)
+		}
+		for _, d := range k.Dependencies {
+			add(d.Git, d.Git.Ref)
+		}
+	}
+	return srcs, nil
+}
+
+// repoPath returns the path of repo in the vendor tree, e.g.
+// github.com/example/repo for https://github.com/example/repo.git.
+func repoPath(repo string) string {
+	p := strings.TrimSuffix(strings.TrimSuffix(repo, "/"), ".git")
+	if i := strings.Index(p, "://"); i >= 0 {
+		p = p[i+len("://"):]
+	}
+	if i := strings.Index(p, "@"); i >= 0 && !strings.Contains(p[:i], "/") {
+		// user@host:org/repo
+		p = strings.Replace(p[i+1:], ":", "/", 1)
+	}
+	return strings.Trim(path.Clean("/"+filepath.ToSlash(p)), "/")
+}
+
+// cleanDirectory returns directory relative to the repo root.
+func cleanDirectory(directory string) string {
+	return strings.Trim(path.Clean("/"+directory), "/")
+}
+
+// describe describes source s for messages.
+func describe(s source) string {
+	d := cleanDirectory(s.directory)
+	if d == "" {
+		return fmt.Sprintf("%s@%s", s.repo, s.ref)
+	}
+	return fmt.Sprintf("%s/%s@%s", s.repo, d, s.ref)
+}
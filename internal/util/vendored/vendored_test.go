@@ -0,0 +1,131 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vendored_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt/internal/gitutil"
+	"github.com/GoogleContainerTools/kpt/internal/testutil"
+	"github.com/GoogleContainerTools/kpt/internal/util/get"
+	"github.com/GoogleContainerTools/kpt/internal/util/git"
+	. "github.com/GoogleContainerTools/kpt/internal/util/vendored"
+	"github.com/GoogleContainerTools/kpt/pkg/kptfile"
+	"github.com/GoogleContainerTools/kpt/pkg/kptfile/kptfileutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func clone(repoSpec *git.RepoSpec) error {
+	defaultRef, err := gitutil.DefaultRef(repoSpec.OrgRepo)
+	if err != nil {
+		return err
+	}
+	return get.ClonerUsingGitExec(repoSpec, defaultRef)
+}
+
+func TestCommand_Run(t *testing.T) {
+	g, w, clean := testutil.SetupDefaultRepoAndWorkspace(t, testutil.Dataset1)
+	defer clean()
+
+	// the java package depends on the mysql package
+	err := ioutil.WriteFile(filepath.Join(g.RepoDirectory, "java", kptfile.KptFileName), []byte(fmt.Sprintf(`apiVersion: kpt.dev/v1alpha1
+kind: Kptfile
+metadata:
+  name: java
+dependencies:
+- name: mysql
+  git:
+    repo: %s
+    directory: /mysql
+    ref: master
+`, g.RepoDirectory)), 0600)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	if !assert.NoError(t, gitutil.NewLocalGitRunner(g.RepoDirectory).Run("add", ".")) {
+		t.FailNow()
+	}
+	testutil.Commit(t, g, "add java dependencies")
+	commit, err := g.GetCommit()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	// the workspace contains the java package fetched at master
+	err = get.Command{Git: kptfile.Git{Repo: g.RepoDirectory, Directory: "/java", Ref: "master"},
+		Destination: "app"}.Run()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	err = Command{Path: w.WorkspaceDirectory, Cloner: clone}.Run()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+
+	vendorDir := filepath.Join(w.WorkspaceDirectory, DirName)
+	m, err := ReadManifest(vendorDir)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	repoPath := filepath.ToSlash(g.RepoDirectory)[1:]
+	javaPath := repoPath + "/java@" + commit[:12]
+	mysqlPath := repoPath + "/mysql@" + commit[:12]
+	assert.Equal(t, Manifest{Packages: []Package{
+		{Repo: g.RepoDirectory, Directory: "/java", Ref: commit, Commit: commit, Path: javaPath},
+		{Repo: g.RepoDirectory, Directory: "/java", Ref: "master", Commit: commit, Path: javaPath},
+		{Repo: g.RepoDirectory, Directory: "/mysql", Ref: "master", Commit: commit, Path: mysqlPath},
+	}}, m)
+	testutil.AssertPkgEqual(t, g, filepath.Join(g.DatasetDirectory, testutil.Dataset1, "mysql"),
+		filepath.Join(vendorDir, filepath.FromSlash(mysqlPath)))
+
+	// packages are fetched from the vendor tree once the upstream is gone
+	if !assert.NoError(t, g.RemoveAll()) {
+		t.FailNow()
+	}
+	err = get.Command{Git: kptfile.Git{Repo: g.RepoDirectory, Directory: "/mysql", Ref: "master"},
+		Destination: "db"}.Run()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	k, err := kptfileutil.ReadFile("db")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, commit, k.Upstream.Git.Commit)
+	_, err = os.Stat(filepath.Join("db", "mysql-statefulset.resource.yaml"))
+	assert.NoError(t, err)
+}
+
+func TestManifest_Lookup(t *testing.T) {
+	m := Manifest{Packages: []Package{
+		{Repo: "https://github.com/example/catalog", Directory: "/app", Ref: "v1.2.0",
+			Commit: "8b8ecd5", Path: "github.com/example/catalog/app@8b8ecd5"},
+	}}
+
+	for _, ref := range []string{"v1.2.0", "8b8ecd5"} {
+		p, found := m.Lookup("https://github.com/example/catalog", "app/", ref)
+		assert.True(t, found, ref)
+		assert.Equal(t, "github.com/example/catalog/app@8b8ecd5", p.Path)
+	}
+	_, found := m.Lookup("https://github.com/example/catalog", "/app", "v1.3.0")
+	assert.False(t, found)
+	_, found = m.Lookup("https://github.com/example/other", "/app", "v1.2.0")
+	assert.False(t, found)
+}
@@ -0,0 +1,240 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package verify reports the changes made to a package since it was fetched
+// or updated, and how it has drifted from its upstream.
+package verify
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/GoogleContainerTools/kpt/internal/gitutil"
+	"github.com/GoogleContainerTools/kpt/internal/util/digest"
+	"github.com/GoogleContainerTools/kpt/internal/util/get"
+	"github.com/GoogleContainerTools/kpt/internal/util/tmputil"
+	"github.com/GoogleContainerTools/kpt/internal/util/update"
+	"github.com/GoogleContainerTools/kpt/pkg/kptfile"
+	"github.com/GoogleContainerTools/kpt/pkg/kptfile/kptfileutil"
+	"sigs.k8s.io/kustomize/kyaml/errors"
+)
+
+// Output formats.
+const (
+	TableOutput = "table"
+	JSONOutput  = "json"
+)
+
+// File statuses, relative to the upstream.
+const (
+	Modified = "modified"
+	Added    = "added"
+	Deleted  = "deleted"
+)
+
+// File is a file of the package which differs from its upstream.
+type File struct {
+	// Path is the slash separated path of the file, relative to the package
+	Path string `json:"path"`
+
+	// Status is Modified, Added or Deleted
+	Status string `json:"status"`
+}
+
+// Result is the result of verifying a package.
+type Result struct {
+	// Digest is the digest of the package contents
+	Digest string `json:"digest"`
+
+	// Recorded is the digest recorded in the Kptfile when the package was
+	// last fetched or updated
+	Recorded string `json:"recorded,omitempty"`
+
+	// Commit is the upstream commit the package is pinned to
+	Commit string `json:"commit,omitempty"`
+
+	// Files are the files which differ from the upstream commit.  They are
+	// not computed for offline verification.
+	Files []File `json:"files,omitempty"`
+}
+
+// Changed returns true if the package contents have changed since the
+// digest was recorded.
+func (r Result) Changed() bool {
+	return r.Recorded != "" && r.Recorded != r.Digest
+}
+
+// Command verifies a package against its recorded digest and upstream.
+type Command struct {
+	// Dir is the package to verify
+	Dir string
+
+	// Offline skips comparing the package against its upstream commit
+	Offline bool
+
+	// Output is TableOutput or JSONOutput
+	Output string
+
+	StdOut io.Writer
+}
+
+// Run verifies the package, returning an error if it has changed since it
+// was last fetched or updated -- or, if it has no recorded digest, if it
+// differs from its upstream.
+func (c Command) Run() error {
+	if c.StdOut == nil {
+		c.StdOut = os.Stdout
+	}
+	r, err := Verify(c.Dir, c.Offline)
+	if err != nil {
+		return err
+	}
+	switch c.Output {
+	case "", TableOutput:
+		err = WriteTable(c.StdOut, r)
+	case JSONOutput:
+		e := json.NewEncoder(c.StdOut)
+		e.SetIndent("", "  ")
+		err = e.Encode(r)
+	default:
+		return errors.Errorf("unknown output format %q", c.Output)
+	}
+	if err != nil {
+		return errors.Wrap(err)
+	}
+	switch {
+	case r.Changed():
+		return errors.Errorf("package %q has been modified since it was last fetched or updated",
+			c.Dir)
+	case r.Recorded == "" && len(r.Files) > 0:
+		return errors.Errorf("package %q differs from its upstream", c.Dir)
+	case r.Recorded == "" && c.Offline:
+		return errors.Errorf("package %q has no recorded digest", c.Dir)
+	}
+	return nil
+}
+
+// Verify computes the digest of the package at dir and, unless offline,
+// compares its files against the upstream commit it is pinned to.
+func Verify(dir string, offline bool) (Result, error) {
+	k, err := kptfileutil.ReadFile(dir)
+	if err != nil {
+		return Result{}, err
+	}
+	files, err := update.Files(dir)
+	if err != nil {
+		return Result{}, err
+	}
+	r := Result{
+		Digest:   digest.Sum(files),
+		Recorded: k.Upstream.Digest,
+		Commit:   k.Upstream.Git.Commit,
+	}
+	if offline {
+		return r, nil
+	}
+	if k.Upstream.Git.Repo == "" || k.Upstream.Git.Commit == "" {
+		return r, errors.Errorf("package %q has no upstream commit to verify against", dir)
+	}
+	upstream, err := fetch(dir, k.Upstream.Git)
+	if err != nil {
+		return r, err
+	}
+	r.Files = compare(upstream, files)
+	return r, nil
+}
+
+// fetch returns the digests of the files of the package at the upstream
+// commit.
+func fetch(dir string, upstream kptfile.Git) (map[string]string, error) {
+	if gitutil.IsRelativeRepo(upstream.Repo) {
+		var err error
+		upstream.Repo, upstream.Directory, err = gitutil.ResolveRelativeRepo(dir, upstream.Repo)
+		if err != nil {
+			return nil, err
+		}
+	}
+	tmp, err := tmputil.TempDir("kpt-verify-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, errors.Wrap(err)
+	}
+	pkg := filepath.Join(tmp, filepath.Base(abs))
+	err = get.Command{
+		Git:         kptfile.Git{Repo: upstream.Repo, Directory: upstream.Directory, Ref: upstream.Commit},
+		Destination: pkg,
+		Clean:       true,
+	}.Run()
+	if err != nil {
+		return nil, errors.WrapPrefixf(err, "failed to fetch upstream commit %s", upstream.Commit)
+	}
+	return update.Files(pkg)
+}
+
+// compare returns the local files which differ from the upstream files,
+// sorted by path.
+func compare(upstream, local map[string]string) []File {
+	files := []File{}
+	for p, d := range local {
+		u, found := upstream[p]
+		switch {
+		case !found:
+			files = append(files, File{Path: p, Status: Added})
+		case u != d:
+			files = append(files, File{Path: p, Status: Modified})
+		}
+	}
+	for p := range upstream {
+		if _, found := local[p]; !found {
+			files = append(files, File{Path: p, Status: Deleted})
+		}
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	return files
+}
+
+// WriteTable writes the result as a table.
+func WriteTable(w io.Writer, r Result) error {
+	switch {
+	case r.Recorded == "":
+		fmt.Fprintf(w, "no digest recorded, package digest is %s\n", r.Digest)
+	case r.Changed():
+		fmt.Fprintf(w, "package modified since it was last fetched or updated: recorded %s, found %s\n",
+			r.Recorded, r.Digest)
+	default:
+		fmt.Fprintf(w, "package matches the recorded digest %s\n", r.Digest)
+	}
+	if r.Files == nil {
+		return nil
+	}
+	if len(r.Files) == 0 {
+		fmt.Fprintf(w, "package matches upstream commit %s\n", r.Commit)
+		return nil
+	}
+	fmt.Fprintf(w, "package differs from upstream commit %s:\n", r.Commit)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, f := range r.Files {
+		fmt.Fprintf(tw, "  %s\t%s\n", f.Status, f.Path)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,101 @@
+// Copyright 2019 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package verify_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/GoogleContainerTools/kpt/internal/testutil"
+	"github.com/GoogleContainerTools/kpt/internal/util/get"
+	"github.com/GoogleContainerTools/kpt/internal/util/update"
+	"github.com/GoogleContainerTools/kpt/internal/util/verify"
+	"github.com/GoogleContainerTools/kpt/pkg/kptfile"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCommand_Run(t *testing.T) {
+	g, w, clean := testutil.SetupDefaultRepoAndWorkspace(t, testutil.Dataset1)
+	defer clean()
+
+	err := get.Command{Git: kptfile.Git{
+		Repo: g.RepoDirectory, Ref: "master", Directory: "/"},
+		Destination: g.RepoName,
+	}.Run()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	pkg := filepath.Join(w.WorkspaceDirectory, g.RepoName)
+	if !assert.NoError(t, update.RecordDigests(pkg)) {
+		t.FailNow()
+	}
+
+	// the fetched package matches its digest and upstream
+	b := &bytes.Buffer{}
+	err = verify.Command{Dir: pkg, StdOut: b}.Run()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	r, err := verify.Verify(pkg, false)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.False(t, r.Changed())
+	assert.Empty(t, r.Files)
+
+	// edit, add and delete files
+	service := filepath.Join(pkg, "mysql", "mysql-service.resource.yaml")
+	if !assert.NoError(t, ioutil.WriteFile(service, []byte("# edited\n"), 0600)) {
+		t.FailNow()
+	}
+	if !assert.NoError(t, ioutil.WriteFile(filepath.Join(pkg, "extra.yaml"), nil, 0600)) {
+		t.FailNow()
+	}
+	if !assert.NoError(t, os.Remove(filepath.Join(pkg, "mysql", "mysql-configmap.resource.yaml"))) {
+		t.FailNow()
+	}
+
+	r, err = verify.Verify(pkg, false)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.True(t, r.Changed())
+	assert.Equal(t, []verify.File{
+		{Path: "extra.yaml", Status: verify.Added},
+		{Path: "mysql/mysql-configmap.resource.yaml", Status: verify.Deleted},
+		{Path: "mysql/mysql-service.resource.yaml", Status: verify.Modified},
+	}, r.Files)
+
+	b.Reset()
+	err = verify.Command{Dir: pkg, Offline: true, StdOut: b}.Run()
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "has been modified since it was last fetched or updated")
+	}
+
+	// recording the digest accepts the changes, but not the drift from upstream
+	if !assert.NoError(t, update.RecordDigests(pkg)) {
+		t.FailNow()
+	}
+	b.Reset()
+	err = verify.Command{Dir: pkg, StdOut: b}.Run()
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Contains(t, b.String(), "package differs from upstream commit")
+	assert.Contains(t, b.String(), "modified  mysql/mysql-service.resource.yaml")
+}
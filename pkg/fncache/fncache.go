@@ -0,0 +1,172 @@
+// Copyright 2020 Google LLC.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package fncache caches the results of running functions, keyed by the
+// digest of their input, so identical renders reuse the results instead of
+// running the functions again.
+//
+// Results are cached in a directory, see Dir, or shared between machines by
+// a cache server, see Server and Remote.  The server serves
+//
+//	GET /v1/entries/KEY   200 with the entry, or 404 if it isn't cached
+//	PUT /v1/entries/KEY   204 once the request body is cached as the entry
+//
+// where KEY is the hex encoded sha256 digest of the input, see Key.
+package fncache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Cache stores function results by key.
+type Cache interface {
+	// Get returns the entry for key, and false if it isn't cached.
+	Get(key string) ([]byte, bool, error)
+
+	// Put caches value as the entry for key.
+	Put(key string, value []byte) error
+}
+
+// Key returns the key of the entry for the result of the function identified
+// by id run against input.  id must identify the function and its version,
+// e.g. the image of a container function.
+func Key(id string, input []byte) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%d:%s", len(id), id)
+	_, _ = h.Write(input)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// keyPattern matches valid keys.
+var keyPattern = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// ValidKey returns an error if key isn't a key returned by Key.
+func ValidKey(key string) error {
+	if !keyPattern.MatchString(key) {
+		return fmt.Errorf("invalid cache key %q -- must be a hex encoded sha256 digest", key)
+	}
+	return nil
+}
+
+// Dir caches entries as files under a directory.  It is safe to share
+// the directory between processes.
+type Dir struct {
+	// Path is the directory the entries are cached under.
+	Path string
+
+	// MaxBytes is the size the entries are pruned to, least recently used
+	// first, once they exceed it.  0 for no limit.
+	MaxBytes int64
+
+	mu   sync.Mutex
+	size int64
+	scan bool
+}
+
+// Get implements Cache.
+func (d *Dir) Get(key string) ([]byte, bool, error) {
+	if err := ValidKey(key); err != nil {
+		return nil, false, err
+	}
+	b, err := ioutil.ReadFile(d.path(key))
+	if os.IsNotExist(err) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	// record the use, so recently used entries are pruned last
+	now := time.Now()
+	_ = os.Chtimes(d.path(key), now, now)
+	return b, true, nil
+}
+
+// Put implements Cache.
+func (d *Dir) Put(key string, value []byte) error {
+	if err := ValidKey(key); err != nil {
+		return err
+	}
+	path := d.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	// write the entry atomically, so concurrent readers never see part of it
+	f, err := ioutil.TempFile(filepath.Dir(path), ".tmp-")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(value)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		_ = os.Remove(f.Name())
+		return err
+	}
+
+	if d.MaxBytes <= 0 {
+		return nil
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.size += int64(len(value))
+	if d.scan && d.size <= d.MaxBytes {
+		return nil
+	}
+	return d.prune()
+}
+
+// path returns the file the entry for key is cached in.
+func (d *Dir) path(key string) string {
+	return filepath.Join(d.Path, key[:2], key)
+}
+
+// prune removes the least recently used entries until they fit in
+// MaxBytes, and recounts the size of the entries.
+func (d *Dir) prune() error {
+	type entry struct {
+		path string
+		size int64
+		used time.Time
+	}
+	var entries []entry
+	var size int64
+	err := filepath.Walk(d.Path, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !keyPattern.MatchString(info.Name()) {
+			return nil
+		}
+		entries = append(entries, entry{path: path, size: info.Size(), used: info.ModTime()})
+		size += info.Size()
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].used.Before(entries[j].used) })
+	for _, e := range entries {
+		if size <= d.MaxBytes {
+			break
+		}
+		if err := os.Remove(e.path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		size -= e.size
+	}
+	d.size, d.scan = size, true
+	return nil
+}
@@ -0,0 +1,94 @@
+// Copyright 2020 Google LLC.
+// SPDX-License-Identifier: Apache-2.0
+
+package fncache_test
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/GoogleContainerTools/kpt/pkg/fncache"
+	"github.com/stretchr/testify/assert"
+)
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "kpt-fncache-test")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	return dir
+}
+
+// TestDir verifies that entries are cached, and the least recently used
+// entries are pruned once the cache exceeds its size.
+func TestDir(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	d := &Dir{Path: dir, MaxBytes: 10}
+
+	a, b, c := Key("fn", []byte("a")), Key("fn", []byte("b")), Key("fn", []byte("c"))
+	_, found, err := d.Get(a)
+	assert.NoError(t, err)
+	assert.False(t, found)
+
+	assert.NoError(t, d.Put(a, []byte("aaaa")))
+	assert.NoError(t, d.Put(b, []byte("bbbb")))
+	// use a, so b is least recently used
+	old := time.Now().Add(-time.Hour)
+	assert.NoError(t, os.Chtimes(filepath.Join(dir, b[:2], b), old, old))
+	value, found, err := d.Get(a)
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "aaaa", string(value))
+
+	assert.NoError(t, d.Put(c, []byte("cccc")))
+	for key, expected := range map[string]bool{a: true, b: false, c: true} {
+		_, found, err := d.Get(key)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, found, key)
+	}
+
+	assert.Error(t, d.Put("../escape", nil))
+}
+
+// TestServer verifies that Remote caches entries with a Server.
+func TestServer(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	s := &Server{Cache: &Dir{Path: dir}, Token: "secret", MaxEntryBytes: 8}
+	server := httptest.NewServer(s)
+	defer server.Close()
+
+	key := Key("fn", []byte("input"))
+	r := &Remote{URL: server.URL, Token: "secret"}
+	_, found, err := r.Get(key)
+	assert.NoError(t, err)
+	assert.False(t, found)
+
+	assert.NoError(t, r.Put(key, []byte("output")))
+	value, found, err := r.Get(key)
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "output", string(value))
+
+	err = r.Put(key, []byte(strings.Repeat("x", 9)))
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "413")
+	}
+
+	_, _, err = (&Remote{URL: server.URL}).Get(key)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "401")
+	}
+
+	s.ReadOnly = true
+	err = r.Put(Key("fn", []byte("other")), []byte("output"))
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "read-only")
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright 2020 Google LLC.
+// SPDX-License-Identifier: Apache-2.0
+
+package fncache
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Remote caches entries with a cache Server.
+type Remote struct {
+	// URL is the url of the server, e.g. http://cache.example.com:8080.
+	URL string
+
+	// Token if set is the bearer token to authenticate with.
+	Token string
+
+	// Client makes the requests.  Defaults to a client with a 30s timeout.
+	Client *http.Client
+}
+
+// defaultClient makes the requests of Remotes without a Client.
+var defaultClient = &http.Client{Timeout: 30 * time.Second}
+
+// Get implements Cache.
+func (r *Remote) Get(key string) ([]byte, bool, error) {
+	resp, err := r.do(http.MethodGet, key, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, false, fmt.Errorf("cache request failed: %v", err)
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return body, true, nil
+	case http.StatusNotFound:
+		return nil, false, nil
+	default:
+		return nil, false, fmt.Errorf("cache request failed: %s: %s",
+			resp.Status, strings.TrimSpace(string(body)))
+	}
+}
+
+// Put implements Cache.
+func (r *Remote) Put(key string, value []byte) error {
+	resp, err := r.do(http.MethodPut, key, value)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, _ := ioutil.ReadAll(resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("cache request failed: %s: %s",
+			resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// do makes a request for the entry for key.
+func (r *Remote) do(method, key string, body []byte) (*http.Response, error) {
+	if err := ValidKey(key); err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(method, strings.TrimSuffix(r.URL, "/")+EntriesPath+key,
+		bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if r.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+r.Token)
+	}
+	client := r.Client
+	if client == nil {
+		client = defaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("cache request failed: %v", err)
+	}
+	return resp, nil
+}
@@ -0,0 +1,97 @@
+// Copyright 2020 Google LLC.
+// SPDX-License-Identifier: Apache-2.0
+
+package fncache
+
+import (
+	"crypto/subtle"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// EntriesPath is the path the entries are served under.
+const EntriesPath = "/v1/entries/"
+
+// DefaultMaxEntryBytes is the default size limit of the entries a Server
+// accepts.
+const DefaultMaxEntryBytes = 32 << 20
+
+// Server serves a Cache to Remote clients.  It can be embedded in other
+// servers, e.g. mounted under a prefix with http.StripPrefix.
+type Server struct {
+	// Cache stores the entries.
+	Cache Cache
+
+	// Token if set is the bearer token clients must authenticate with.
+	Token string
+
+	// ReadOnly if set rejects requests to cache entries.
+	ReadOnly bool
+
+	// MaxEntryBytes is the size limit of the entries which are accepted.
+	// Defaults to DefaultMaxEntryBytes.
+	MaxEntryBytes int64
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.Token != "" {
+		auth := r.Header.Get("Authorization")
+		if subtle.ConstantTimeCompare([]byte(auth), []byte("Bearer "+s.Token)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+	}
+	if !strings.HasPrefix(r.URL.Path, EntriesPath) {
+		http.NotFound(w, r)
+		return
+	}
+	key := strings.TrimPrefix(r.URL.Path, EntriesPath)
+	if err := ValidKey(key); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		value, found, err := s.Cache.Get(key)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !found {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		_, _ = w.Write(value)
+	case http.MethodPut:
+		if s.ReadOnly {
+			http.Error(w, "the cache is read-only", http.StatusForbidden)
+			return
+		}
+		max := s.MaxEntryBytes
+		if max <= 0 {
+			max = DefaultMaxEntryBytes
+		}
+		value, err := ioutil.ReadAll(io.LimitReader(r.Body, max+1))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if int64(len(value)) > max {
+			http.Error(w, "entry too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		if err := s.Cache.Put(key, value); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", "GET, HEAD, PUT")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
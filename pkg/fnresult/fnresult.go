@@ -0,0 +1,225 @@
+// Copyright 2020 Google LLC.
+// SPDX-License-Identifier: Apache-2.0
+
+// Package fnresult defines the structured results functions return in the
+// results field of the ResourceList they write, e.g.
+//
+//	results:
+//	- message: replicas must be at most 3
+//	  severity: error
+//	  resourceRef:
+//	    apiVersion: apps/v1
+//	    kind: Deployment
+//	    name: app
+//	  field:
+//	    path: spec.replicas
+//	    currentValue: 5
+//	    suggestedValue: 3
+//	  file:
+//	    path: app/deploy.yaml
+//	    line: 6
+//
+// The results may also be a Result, as written by the kyaml function
+// framework, whose items are the list above.
+package fnresult
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// Severity is the severity of an Item.
+type Severity string
+
+const (
+	// Error is a result which fails the function, e.g. a failed validation
+	Error Severity = "error"
+	// Warning is a result which should be fixed, but doesn't fail the
+	// function
+	Warning Severity = "warning"
+	// Info is an informative result, and the severity of items which don't
+	// set one
+	Info Severity = "info"
+)
+
+// Item is a single result of a function.
+type Item struct {
+	// Message is the human readable result
+	Message string `yaml:"message" json:"message"`
+
+	// Severity is the severity of the result.  Defaults to Info.
+	Severity Severity `yaml:"severity,omitempty" json:"severity,omitempty"`
+
+	// ResourceRef is the resource the result is about, if any
+	ResourceRef *ResourceRef `yaml:"resourceRef,omitempty" json:"resourceRef,omitempty"`
+
+	// Field is the field of the resource the result is about, if any
+	Field *Field `yaml:"field,omitempty" json:"field,omitempty"`
+
+	// File is the file of the resource, if any
+	File *File `yaml:"file,omitempty" json:"file,omitempty"`
+}
+
+// ResourceRef identifies a resource.
+type ResourceRef struct {
+	APIVersion string `yaml:"apiVersion,omitempty" json:"apiVersion,omitempty"`
+	Kind       string `yaml:"kind,omitempty" json:"kind,omitempty"`
+	Name       string `yaml:"name,omitempty" json:"name,omitempty"`
+	Namespace  string `yaml:"namespace,omitempty" json:"namespace,omitempty"`
+}
+
+// Field identifies a field of a resource.
+type Field struct {
+	// Path is the path of the field, e.g. spec.containers[0].image
+	Path string `yaml:"path,omitempty" json:"path,omitempty"`
+
+	// CurrentValue is the value of the field, and SuggestedValue the value
+	// the function suggests instead
+	CurrentValue   interface{} `yaml:"currentValue,omitempty" json:"currentValue,omitempty"`
+	SuggestedValue interface{} `yaml:"suggestedValue,omitempty" json:"suggestedValue,omitempty"`
+}
+
+// File identifies a location in a file.
+type File struct {
+	// Path is the path of the file, relative to the package
+	Path string `yaml:"path,omitempty" json:"path,omitempty"`
+
+	// Line is the 1-based line of the field, or of the resource, in the
+	// file, or 0 if it isn't known
+	Line int `yaml:"line,omitempty" json:"line,omitempty"`
+
+	// Index is the index of the resource in the file
+	Index int `yaml:"index,omitempty" json:"index,omitempty"`
+}
+
+// Result is the results of a single function.
+type Result struct {
+	// Name identifies the function
+	Name string `yaml:"name,omitempty" json:"name,omitempty"`
+
+	Items []Item `yaml:"items,omitempty" json:"items,omitempty"`
+}
+
+// Parse parses the results field of a ResourceList.
+func Parse(b []byte) (Result, error) {
+	var n yaml.Node
+	if err := yaml.Unmarshal(b, &n); err != nil {
+		return Result{}, err
+	}
+	if n.Kind == yaml.DocumentNode && len(n.Content) > 0 {
+		n = *n.Content[0]
+	}
+	var r Result
+	var err error
+	switch n.Kind {
+	case 0:
+	case yaml.SequenceNode:
+		err = n.Decode(&r.Items)
+	case yaml.MappingNode:
+		err = n.Decode(&r)
+	default:
+		err = fmt.Errorf("results must be a list of items, or a result with items")
+	}
+	if err != nil {
+		return Result{}, err
+	}
+	for i := range r.Items {
+		if r.Items[i].Severity == "" {
+			r.Items[i].Severity = Info
+		}
+	}
+	return r, nil
+}
+
+// Count returns the number of items with severity s.
+func Count(results []Result, s Severity) int {
+	n := 0
+	for _, r := range results {
+		for _, i := range r.Items {
+			if i.Severity == s {
+				n++
+			}
+		}
+	}
+	return n
+}
+
+// String returns the item as a single line, e.g.
+//
+//	[error] apps/v1/Deployment/app spec.replicas: replicas must be at most 3 (app/deploy.yaml:6)
+func (i Item) String() string {
+	b := &strings.Builder{}
+	fmt.Fprintf(b, "[%s]", i.Severity)
+	if ref := i.ResourceRef; ref != nil {
+		id := []string{ref.APIVersion, ref.Kind, ref.Namespace, ref.Name}
+		var parts []string
+		for _, p := range id {
+			if p != "" {
+				parts = append(parts, p)
+			}
+		}
+		fmt.Fprintf(b, " %s", strings.Join(parts, "/"))
+	}
+	if i.Field != nil && i.Field.Path != "" {
+		fmt.Fprintf(b, " %s", i.Field.Path)
+	}
+	if i.ResourceRef != nil || (i.Field != nil && i.Field.Path != "") {
+		b.WriteString(":")
+	}
+	fmt.Fprintf(b, " %s", i.Message)
+	if i.Field != nil && i.Field.SuggestedValue != nil {
+		fmt.Fprintf(b, " (suggested: %v)", i.Field.SuggestedValue)
+	}
+	if i.File != nil && i.File.Path != "" {
+		if i.File.Line > 0 {
+			fmt.Fprintf(b, " (%s:%d)", i.File.Path, i.File.Line)
+		} else {
+			fmt.Fprintf(b, " (%s)", i.File.Path)
+		}
+	}
+	return b.String()
+}
+
+// Write pretty-prints the results to w, the items of each function
+// ordered by severity, followed by the number of items of each severity.
+func Write(w io.Writer, results []Result) error {
+	rank := map[Severity]int{Error: 0, Warning: 1, Info: 2}
+	for _, r := range results {
+		if len(r.Items) == 0 {
+			continue
+		}
+		items := append([]Item{}, r.Items...)
+		sort.SliceStable(items, func(i, j int) bool {
+			return rank[items[i].Severity] < rank[items[j].Severity]
+		})
+		name := r.Name
+		if name == "" {
+			name = "function"
+		}
+		if _, err := fmt.Fprintf(w, "%s:\n", name); err != nil {
+			return err
+		}
+		for _, i := range items {
+			if _, err := fmt.Fprintf(w, "  %s\n", i); err != nil {
+				return err
+			}
+		}
+	}
+	_, err := fmt.Fprintf(w, "%s, %s, %s\n",
+		plural(Count(results, Error), "error"),
+		plural(Count(results, Warning), "warning"),
+		plural(Count(results, Info), "info"))
+	return err
+}
+
+// plural returns n and noun, pluralized unless n is 1.
+func plural(n int, noun string) string {
+	if n == 1 || noun == "info" {
+		return fmt.Sprintf("%d %s", n, noun)
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
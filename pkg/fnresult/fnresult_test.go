@@ -0,0 +1,78 @@
+// Copyright 2020 Google LLC.
+// SPDX-License-Identifier: Apache-2.0
+
+package fnresult_test
+
+import (
+	"bytes"
+	"testing"
+
+	. "github.com/GoogleContainerTools/kpt/pkg/fnresult"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParse(t *testing.T) {
+	items := `- message: replicas must be at most 3
+  severity: error
+  resourceRef: {apiVersion: apps/v1, kind: Deployment, name: app}
+  field: {path: spec.replicas, currentValue: 5, suggestedValue: 3}
+  file: {path: app/deploy.yaml, line: 6}
+- message: checked 1 resource
+`
+	r, err := Parse([]byte(items))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, Result{Items: []Item{
+		{
+			Message:     "replicas must be at most 3",
+			Severity:    Error,
+			ResourceRef: &ResourceRef{APIVersion: "apps/v1", Kind: "Deployment", Name: "app"},
+			Field:       &Field{Path: "spec.replicas", CurrentValue: 5, SuggestedValue: 3},
+			File:        &File{Path: "app/deploy.yaml", Line: 6},
+		},
+		// the severity defaults to info
+		{Message: "checked 1 resource", Severity: Info},
+	}}, r)
+
+	// the results of the kyaml function framework are a result
+	r, err = Parse([]byte("name: check\nitems:\n- message: ok\n  severity: warning\n"))
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, Result{Name: "check", Items: []Item{{Message: "ok", Severity: Warning}}}, r)
+
+	r, err = Parse(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, Result{}, r)
+
+	_, err = Parse([]byte("ok"))
+	assert.EqualError(t, err, "results must be a list of items, or a result with items")
+}
+
+func TestWrite(t *testing.T) {
+	results := []Result{
+		{Name: "check", Items: []Item{
+			{Message: "checked 1 resource", Severity: Info},
+			{
+				Message:     "replicas must be at most 3",
+				Severity:    Error,
+				ResourceRef: &ResourceRef{APIVersion: "apps/v1", Kind: "Deployment", Namespace: "default", Name: "app"},
+				Field:       &Field{Path: "spec.replicas", SuggestedValue: 3},
+				File:        &File{Path: "app/deploy.yaml", Line: 6},
+			},
+			{Message: "image is not pinned", Severity: Warning, File: &File{Path: "app/deploy.yaml"}},
+		}},
+		{Name: "empty"},
+	}
+	b := &bytes.Buffer{}
+	if !assert.NoError(t, Write(b, results)) {
+		t.FailNow()
+	}
+	assert.Equal(t, `check:
+  [error] apps/v1/Deployment/default/app spec.replicas: replicas must be at most 3 (suggested: 3) (app/deploy.yaml:6)
+  [warning] image is not pinned (app/deploy.yaml)
+  [info] checked 1 resource
+1 error, 1 warning, 1 info
+`, b.String())
+}
@@ -15,6 +15,7 @@
 package kptfileutil
 
 import (
+	"bytes"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -26,6 +27,21 @@ import (
 	"sigs.k8s.io/kustomize/kyaml/yaml"
 )
 
+// UnknownKptfileVersionError is returned when reading a Kptfile whose
+// apiVersion kpt doesn't know, e.g. one written by a newer kpt release.
+type UnknownKptfileVersionError struct {
+	// Path is the path of the Kptfile
+	Path string
+
+	// APIVersion is the apiVersion of the Kptfile
+	APIVersion string
+}
+
+func (e *UnknownKptfileVersionError) Error() string {
+	return fmt.Sprintf("%q has unknown apiVersion %q, expected %s",
+		e.Path, e.APIVersion, kptfile.KptFileAPIVersion)
+}
+
 // ReadFile reads the KptFile in the given directory
 func ReadFile(dir string) (kptfile.KptFile, error) {
 	kpgfile := kptfile.KptFile{ResourceMeta: kptfile.TypeMeta}
@@ -43,7 +59,21 @@ func ReadFile(dir string) (kptfile.KptFile, error) {
 	}
 	defer f.Close()
 
-	d := yaml.NewDecoder(f)
+	b, err := ioutil.ReadAll(f)
+	if err != nil {
+		return kptfile.KptFile{}, errors.Errorf("unable to read %q: %v", kptfile.KptFileName, err)
+	}
+	// check the apiVersion first, since the fields of other versions differ
+	var meta yaml.ResourceMeta
+	switch err := yaml.Unmarshal(b, &meta); {
+	case err != nil, meta.Kind != kptfile.KptFileName, meta.APIVersion == "",
+		meta.APIVersion == kptfile.KptFileAPIVersion, meta.APIVersion == kptfile.KptFileLegacyAPIVersion:
+	default:
+		return kptfile.KptFile{}, &UnknownKptfileVersionError{
+			Path: filepath.Join(dir, kptfile.KptFileName), APIVersion: meta.APIVersion}
+	}
+
+	d := yaml.NewDecoder(bytes.NewReader(b))
 	d.KnownFields(true)
 	if err = d.Decode(&kpgfile); err != nil {
 		return kptfile.KptFile{}, errors.Errorf("unable to parse %q: %v", kptfile.KptFileName, err)
@@ -110,6 +140,12 @@ func ReadFileStrict(pkgPath string) (kptfile.KptFile, error) {
 				"%q Kptfile missing upstream.stdin.original", pkgPath)
 		}
 	}
+	if kf.Upstream.Type == kptfile.HelmOrigin {
+		if kf.Upstream.Helm.Chart == "" {
+			return kptfile.KptFile{}, errors.Errorf(
+				"%q Kptfile missing upstream.helm.chart", pkgPath)
+		}
+	}
 	return kf, nil
 }
 
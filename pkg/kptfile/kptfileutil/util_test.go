@@ -15,6 +15,9 @@
 package kptfileutil
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/GoogleContainerTools/kpt/pkg/kptfile"
@@ -54,3 +57,30 @@ func TestValidateInventory(t *testing.T) {
 		t.Errorf("inventory with non-empty namespace, name, and id should validate")
 	}
 }
+
+// TestReadFile_unknownVersion tests that Kptfiles with unknown apiVersions
+// aren't read.
+func TestReadFile_unknownVersion(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kptfileutil")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	err = ioutil.WriteFile(filepath.Join(dir, kptfile.KptFileName), []byte(`apiVersion: kpt.dev/v9
+kind: Kptfile
+metadata:
+  name: app
+pipeline: []
+`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = ReadFile(dir)
+	e, ok := err.(*UnknownKptfileVersionError)
+	if !ok {
+		t.Fatalf("expected an UnknownKptfileVersionError, got %v", err)
+	}
+	if e.APIVersion != "kpt.dev/v9" {
+		t.Errorf("expected apiVersion kpt.dev/v9, got %q", e.APIVersion)
+	}
+}
@@ -25,8 +25,18 @@ const (
 	KptFileGroup      = "kpt.dev"
 	KptFileVersion    = "v1alpha1"
 	KptFileAPIVersion = KptFileGroup + "/" + KptFileVersion
+
+	// KptFileLegacyAPIVersion is the apiVersion of the Kptfiles written by
+	// early kpt releases.  Its schema is that of KptFileAPIVersion, and
+	// `kpt pkg fix` migrates it.
+	KptFileLegacyAPIVersion = "krm.dev/v1alpha1"
 )
 
+// HistoryFileName is the name of the file recording the history of the
+// setter values of a package, next to its Kptfile.  It is local to the
+// package, so it isn't compared with other versions of the package.
+const HistoryFileName = ".kpthistory"
+
 // TypeMeta is the TypeMeta for KptFile instances.
 var TypeMeta = yaml.ResourceMeta{
 	TypeMeta: yaml.TypeMeta{
@@ -42,6 +52,10 @@ type KptFile struct {
 	// CloneFrom records where the package was originally cloned from
 	Upstream Upstream `yaml:"upstream,omitempty"`
 
+	// UpstreamAliases are the former urls of the upstream repo, recorded
+	// when the package is updated from a relocated repo
+	UpstreamAliases []string `yaml:"upstreamAliases,omitempty"`
+
 	// PackageMeta contains information about the package
 	PackageMeta PackageMeta `yaml:"packageMetadata,omitempty"`
 
@@ -53,11 +67,138 @@ type KptFile struct {
 	// https://github.com/go-yaml/yaml/issues/575
 	OpenAPI interface{} `yaml:"openAPI,omitempty"`
 
+	// Profiles are named sets of setter values, e.g. one for each
+	// environment the package is deployed to, set with
+	// `kpt cfg set --profile`
+	Profiles map[string]Profile `yaml:"profiles,omitempty"`
+
+	// Conditions are named feature flags, set with `kpt cfg set-condition`.
+	// Resources annotated with config.kpt.dev/condition are only rendered
+	// and applied when their condition is true.
+	Conditions map[string]bool `yaml:"conditions,omitempty"`
+
+	// CommonLabels are set on every resource of the package
+	CommonLabels map[string]string `yaml:"commonLabels,omitempty"`
+
+	// CommonAnnotations are set on every resource of the package
+	CommonAnnotations map[string]string `yaml:"commonAnnotations,omitempty"`
+
 	// Functions contains configuration for running functions
 	Functions Functions `yaml:"functions,omitempty"`
 
+	// Hooks are functions kpt runs automatically against the package
+	Hooks Hooks `yaml:"hooks,omitempty"`
+
+	// Pipeline declares the functions `kpt fn render` runs against the
+	// package
+	Pipeline Pipeline `yaml:"pipeline,omitempty"`
+
+	// ConflictRules resolve the conflicts of resource merge updates
+	// automatically, by field.  The first matching rule applies.
+	ConflictRules []ConflictRule `yaml:"conflictRules,omitempty"`
+
 	// Parameters for inventory object.
 	Inventory *Inventory `yaml:"inventory,omitempty"`
+
+	// Formatting is the yaml style `kpt cfg fmt` formats the resources of
+	// the package with
+	Formatting *Formatting `yaml:"formatting,omitempty"`
+
+	// Inherit declares the settings the package inherits from the Kptfiles
+	// of the packages containing it
+	Inherit *Inherit `yaml:"inherit,omitempty"`
+}
+
+// FieldOrder is how the fields of resources are ordered.
+type FieldOrder string
+
+const (
+	// FieldOrderKubernetes orders common resource fields, such as
+	// apiVersion, kind and metadata, first and the other fields
+	// lexicographically
+	FieldOrderKubernetes FieldOrder = "kubernetes"
+
+	// FieldOrderAlphabetical orders all fields lexicographically
+	FieldOrderAlphabetical FieldOrder = "alphabetical"
+
+	// FieldOrderPreserve keeps the order of the fields
+	FieldOrderPreserve FieldOrder = "preserve"
+)
+
+// ListStyle is the yaml style lists are written in.
+type ListStyle string
+
+const (
+	// ListStyleFlow writes lists as [a, b]
+	ListStyleFlow ListStyle = "flow"
+
+	// ListStyleBlock writes an element per line, prefixed by '- '
+	ListStyleBlock ListStyle = "block"
+)
+
+// Quoting is how string values are quoted.
+type Quoting string
+
+const (
+	// QuotingMinimal quotes only the strings which would otherwise be
+	// read as another type, or aren't valid unquoted
+	QuotingMinimal Quoting = "minimal"
+
+	// QuotingDouble double-quotes strings
+	QuotingDouble Quoting = "double"
+
+	// QuotingSingle single-quotes strings
+	QuotingSingle Quoting = "single"
+)
+
+// Formatting is a canonical yaml style for the resources of a package.
+// Unset fields keep the style of the resources, except for the field order
+// and indent which default to those `kpt cfg fmt` uses without a policy.
+type Formatting struct {
+	// FieldOrder is how the fields of resources are ordered.  Defaults to
+	// FieldOrderKubernetes.
+	FieldOrder FieldOrder `yaml:"fieldOrder,omitempty"`
+
+	// Indent is the number of spaces nested fields are indented by, 2 or
+	// 4.  Lists are indented under their field with 4, but not with 2.
+	// Defaults to 2.
+	Indent int `yaml:"indent,omitempty"`
+
+	// ShortLists is the style of lists of scalars which fit in
+	// ShortListMaxLength characters in flow style.  Other lists are written
+	// in block style if ShortLists is set.
+	ShortLists ListStyle `yaml:"shortLists,omitempty"`
+
+	// ShortListMaxLength is the length of the longest list written in
+	// flow style with ShortLists ListStyleFlow, e.g. 10 for [a, b, c, d].
+	// Defaults to 40.
+	ShortListMaxLength int `yaml:"shortListMaxLength,omitempty"`
+
+	// Quoting is how string values are quoted
+	Quoting Quoting `yaml:"quoting,omitempty"`
+}
+
+// Profile maps setter names to their values, or to lists of values for
+// array setters.
+type Profile map[string]yaml.Node
+
+// Inherit declares the settings a subpackage inherits from the Kptfile of
+// the nearest package containing it, which may itself inherit from the
+// packages containing it.  The settings of the subpackage override those it
+// inherits.
+type Inherit struct {
+	// Pipeline if set runs the inherited pipeline functions before those of
+	// the package, in its own package context.  A function of the package
+	// overrides the inherited function with the same name.
+	Pipeline bool `yaml:"pipeline,omitempty"`
+
+	// Setters if set inherits the setters the package doesn't define, and
+	// the values of the setters it defines but hasn't set.
+	Setters bool `yaml:"setters,omitempty"`
+
+	// Formatting if set inherits the formatting policy fields the package
+	// doesn't set.
+	Formatting bool `yaml:"formatting,omitempty"`
 }
 
 // Inventory encapsulates the parameters for the inventory object. All of the
@@ -86,6 +227,141 @@ type StarlarkFunction struct {
 	Path string `yaml:"path,omitempty"`
 }
 
+// Hooks are functions run against the package at points in its lifecycle.
+type Hooks struct {
+	// PostUpdate are run in order after the package is updated by
+	// kpt pkg update, e.g. to regenerate derived resources or validate the
+	// package.
+	PostUpdate []Hook `yaml:"postUpdate,omitempty"`
+}
+
+// Hook is a function run against the package.  Exactly one of Image and
+// Starlark must be set.
+type Hook struct {
+	// Name identifies the hook in messages.  Defaults to the image or
+	// starlark program.
+	Name string `yaml:"name,omitempty"`
+
+	// Image is the container image of the function
+	Image string `yaml:"image,omitempty"`
+
+	// Starlark is the path, relative to the package, of a starlark program
+	Starlark string `yaml:"starlark,omitempty"`
+
+	// Config is the functionConfig passed to the function
+	Config yaml.Node `yaml:"config,omitempty"`
+}
+
+// String returns the name of the hook.
+func (h Hook) String() string {
+	switch {
+	case h.Name != "":
+		return h.Name
+	case h.Image != "":
+		return h.Image
+	default:
+		return h.Starlark
+	}
+}
+
+// Pipeline is the functions run, in order, to render a package.
+type Pipeline struct {
+	// Mutators are run in order and may change the resources of the
+	// package.
+	Mutators []PipelineFunction `yaml:"mutators,omitempty"`
+
+	// Validators are run in order against the mutated resources.  They
+	// fail the pipeline by failing, and the changes they make are
+	// discarded.
+	Validators []PipelineFunction `yaml:"validators,omitempty"`
+}
+
+// PipelineFunction is a function of a Pipeline.  Exactly one of Image, Exec,
+// Starlark, Wasm and Endpoint must be set, and at most one of Config and
+// ConfigPath.
+type PipelineFunction struct {
+	// Name identifies the function in messages.  Defaults to the image,
+	// executable or starlark program.
+	Name string `yaml:"name,omitempty"`
+
+	// Image is the container image of the function
+	Image string `yaml:"image,omitempty"`
+
+	// Exec is the executable of the function, relative to the package if it
+	// is a path, otherwise looked up on the PATH
+	Exec string `yaml:"exec,omitempty"`
+
+	// Args are the arguments the executable is run with
+	Args []string `yaml:"args,omitempty"`
+
+	// Starlark is the path, relative to the package, of a starlark program
+	Starlark string `yaml:"starlark,omitempty"`
+
+	// Wasm is the path, relative to the package, or the http(s) url of a
+	// module compiled to WASI
+	Wasm string `yaml:"wasm,omitempty"`
+
+	// SHA256 is the hex sha256 digest the Wasm module is verified against.
+	// Required if Wasm is a url.
+	SHA256 string `yaml:"sha256,omitempty"`
+
+	// Endpoint is the http(s) url of a function service the ResourceList
+	// is POSTed to
+	Endpoint string `yaml:"endpoint,omitempty"`
+
+	// Config is the functionConfig passed to the function
+	Config yaml.Node `yaml:"config,omitempty"`
+
+	// ConfigPath is the path, relative to the package, of a file containing
+	// the functionConfig passed to the function
+	ConfigPath string `yaml:"configPath,omitempty"`
+}
+
+// String returns the name of the function.
+func (f PipelineFunction) String() string {
+	switch {
+	case f.Name != "":
+		return f.Name
+	case f.Image != "":
+		return f.Image
+	case f.Exec != "":
+		return f.Exec
+	case f.Wasm != "":
+		return f.Wasm
+	case f.Endpoint != "":
+		return f.Endpoint
+	default:
+		return f.Starlark
+	}
+}
+
+// ConflictPreference is the value a ConflictRule keeps.
+type ConflictPreference string
+
+const (
+	// PreferLocal keeps the local value of conflicting fields
+	PreferLocal ConflictPreference = "local"
+
+	// PreferUpstream takes the upstream value of conflicting fields
+	PreferUpstream ConflictPreference = "upstream"
+)
+
+// ConflictRule resolves the update conflicts in matching fields by keeping
+// either the local or the upstream value.
+type ConflictRule struct {
+	// Fields are the paths of the fields the rule applies to, including the
+	// fields under them, e.g. spec.replicas or
+	// spec.template.spec.containers[*].image.  * matches any field or list
+	// element, and ** any number of them.
+	Fields []string `yaml:"fields,omitempty"`
+
+	// Kinds if set restricts the rule to resources of these kinds
+	Kinds []string `yaml:"kinds,omitempty"`
+
+	// Prefer is the value kept, PreferLocal or PreferUpstream
+	Prefer ConflictPreference `yaml:"prefer,omitempty"`
+}
+
 // MergeOpenAPI adds the OpenAPI definitions from localKf to updatedKf.
 // It takes originalKf as a reference for 3-way merge
 // This function is very complex due to serialization issues with yaml.Node.
@@ -163,6 +439,27 @@ func (updatedKf *KptFile) MergeOpenAPI(localKf, originalKf KptFile) error {
 	return err
 }
 
+// MergeConditions merges the conditions of localKf into updatedKf, taking
+// originalKf as a reference for a 3-way merge.  Conditions set locally
+// keep their local value, and conditions added locally are kept.
+func (updatedKf *KptFile) MergeConditions(localKf, originalKf KptFile) {
+	for name, value := range localKf.Conditions {
+		original, found := originalKf.Conditions[name]
+		if _, updated := updatedKf.Conditions[name]; !updated && found {
+			// deleted upstream
+			continue
+		}
+		if found && original == value {
+			// unchanged locally
+			continue
+		}
+		if updatedKf.Conditions == nil {
+			updatedKf.Conditions = map[string]bool{}
+		}
+		updatedKf.Conditions[name] = value
+	}
+}
+
 // mergeDef takes localDef, originalDef and updateDef, it iterates through the unique keys of localDef
 // and updateDef, skip copy the local node if nothing changed or updateDef get deleted.
 // It deletes the node from updateDef if node get deleted in localDef
@@ -297,6 +594,35 @@ type PackageMeta struct {
 
 	// ShortDescription contains a short description of the package.
 	ShortDescription string `yaml:"shortDescription,omitempty"`
+
+	// Maintainers are the people maintaining the package
+	Maintainers []Maintainer `yaml:"maintainers,omitempty"`
+
+	// Keywords are the words catalogs index the package by
+	Keywords []string `yaml:"keywords,omitempty"`
+
+	// ChangelogURL is the location of the changelog of the package
+	ChangelogURL string `yaml:"changelogURL,omitempty"`
+
+	// MinKptVersion is the minimum version of kpt the package supports.
+	// e.g. v0.37.0
+	MinKptVersion string `yaml:"minKptVersion,omitempty"`
+
+	// MinKubernetesVersion is the minimum version of Kubernetes the package
+	// supports.  e.g. v1.18
+	MinKubernetesVersion string `yaml:"minKubernetesVersion,omitempty"`
+}
+
+// Maintainer is a maintainer of a package.
+type Maintainer struct {
+	// Name is the name of the maintainer
+	Name string `yaml:"name,omitempty"`
+
+	// Email is the email of the maintainer
+	Email string `yaml:"email,omitempty"`
+
+	// URL is the homepage of the maintainer
+	URL string `yaml:"url,omitempty"`
 }
 
 // OriginType defines the type of origin for a package
@@ -306,6 +632,9 @@ const (
 	// GitOrigin specifies a package as having been cloned from a git repository
 	GitOrigin   OriginType = "git"
 	StdinOrigin OriginType = "stdin"
+
+	// HelmOrigin specifies a package as having been converted from a Helm chart
+	HelmOrigin OriginType = "helm"
 )
 
 // Upstream defines where a package was cloned from
@@ -317,6 +646,18 @@ type Upstream struct {
 	Git Git `yaml:"git,omitempty"`
 
 	Stdin Stdin `yaml:"stdin,omitempty"`
+
+	// Helm contains information on the origin of packages converted from a Helm chart.
+	Helm Helm `yaml:"helm,omitempty"`
+
+	// License is the license of the upstream package when it was fetched --
+	// the license declared by its Kptfile, or detected from its LICENSE file.
+	License string `yaml:"license,omitempty"`
+
+	// Digest is the digest of the package contents when it was last fetched
+	// or updated, excluding its Kptfile and independent subpackages.  It is
+	// compared against the package contents by `kpt pkg verify`.
+	Digest string `yaml:"digest,omitempty"`
 }
 
 type Stdin struct {
@@ -325,6 +666,28 @@ type Stdin struct {
 	Original string `yaml:"original,omitempty"`
 }
 
+// Helm contains information on the origin of packages converted from a Helm chart.
+type Helm struct {
+	// Chart is the chart the package was rendered from -- a chart reference,
+	// path or url
+	Chart string `yaml:"chart,omitempty"`
+
+	// Repo is the chart repository url, if the chart was fetched from one
+	Repo string `yaml:"repo,omitempty"`
+
+	// Version is the version of the chart
+	Version string `yaml:"version,omitempty"`
+
+	// ReleaseName is the release name the chart was rendered with
+	ReleaseName string `yaml:"releaseName,omitempty"`
+
+	// Namespace is the namespace the chart was rendered with
+	Namespace string `yaml:"namespace,omitempty"`
+
+	// ValuesFiles are the values files the chart was rendered with
+	ValuesFiles []string `yaml:"valuesFiles,omitempty"`
+}
+
 // Git contains information on the origin of packages cloned from a git repository.
 type Git struct {
 	// Commit is the git commit that the package was fetched at
@@ -338,6 +701,12 @@ type Git struct {
 
 	// Ref is the git ref the package was cloned from
 	Ref string `yaml:"ref,omitempty"`
+
+	// TagTemplate names the tags which version the package, for repos which
+	// version their packages independently, e.g. {{.Path}}/v{{.Version}} or
+	// v{{.Version}}-{{.PkgName}}.  Defaults to the directory joined with the
+	// ref.
+	TagTemplate string `yaml:"tagTemplate,omitempty"`
 }
 
 type Function struct {
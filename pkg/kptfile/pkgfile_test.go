@@ -537,3 +537,23 @@ openAPI:
 		})
 	}
 }
+
+func TestKptFile_MergeConditions(t *testing.T) {
+	kUpdated := KptFile{Conditions: map[string]bool{
+		"enable-monitoring": false, "enable-tls": true, "enable-ingress": true}}
+	kLocal := KptFile{Conditions: map[string]bool{
+		"enable-monitoring": true, "enable-tls": true, "enable-backups": true, "enable-debug": false}}
+	kOriginal := KptFile{Conditions: map[string]bool{
+		"enable-monitoring": false, "enable-tls": false, "enable-debug": false}}
+
+	kUpdated.MergeConditions(kLocal, kOriginal)
+	assert.Equal(t, map[string]bool{
+		// set locally
+		"enable-monitoring": true,
+		"enable-tls":        true,
+		// added upstream
+		"enable-ingress": true,
+		// added locally
+		"enable-backups": true,
+	}, kUpdated.Conditions)
+}
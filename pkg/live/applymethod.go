@@ -0,0 +1,349 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package live
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/kubectl/pkg/cmd/util"
+	"sigs.k8s.io/cli-utils/pkg/apply"
+	"sigs.k8s.io/cli-utils/pkg/apply/event"
+	"sigs.k8s.io/cli-utils/pkg/common"
+	"sigs.k8s.io/cli-utils/pkg/inventory"
+	"sigs.k8s.io/cli-utils/pkg/object"
+)
+
+// ApplyMethodAnnotation overrides how a resource is applied, e.g.
+//
+//	config.kpt.dev/apply-method: create-only
+const ApplyMethodAnnotation = "config.kpt.dev/apply-method"
+
+// ApplyMethod is how a resource is applied.
+type ApplyMethod string
+
+const (
+	// ApplyMethodApply creates the resource, or patches it if it exists.
+	// The default.
+	ApplyMethodApply ApplyMethod = "apply"
+
+	// ApplyMethodCreateOnly creates the resource if it doesn't exist, and
+	// never changes it afterwards -- e.g. one-shot bootstrap Secrets.  The
+	// resource is annotated to be kept when it's pruned, so it isn't deleted
+	// when it's skipped.
+	ApplyMethodCreateOnly ApplyMethod = "create-only"
+
+	// ApplyMethodReplace deletes and recreates the resource when it changed
+	// -- e.g. Jobs, whose template is immutable.
+	ApplyMethodReplace ApplyMethod = "replace"
+
+	// ApplyMethodPatch patches the resource if it exists, and fails rather
+	// than creating it.
+	ApplyMethodPatch ApplyMethod = "patch"
+)
+
+// ApplyMethods are the valid values of ApplyMethodAnnotation.
+var ApplyMethods = []string{
+	string(ApplyMethodApply), string(ApplyMethodCreateOnly),
+	string(ApplyMethodReplace), string(ApplyMethodPatch),
+}
+
+// Method returns the apply method of obj from its ApplyMethodAnnotation.
+func Method(obj *unstructured.Unstructured) (ApplyMethod, error) {
+	value, found := obj.GetAnnotations()[ApplyMethodAnnotation]
+	if !found || value == "" {
+		return ApplyMethodApply, nil
+	}
+	for _, m := range ApplyMethods {
+		if value == m {
+			return ApplyMethod(value), nil
+		}
+	}
+	return "", fmt.Errorf("invalid %s %q: must be one of %s",
+		ApplyMethodAnnotation, value, strings.Join(ApplyMethods, ","))
+}
+
+// MethodApplier applies the resources with an ApplyMethodAnnotation with
+// their method, and the rest with Apply.
+//
+// Resources are still applied by Apply when their method calls for it, so
+// they are recorded in the inventory -- e.g. a create-only resource is
+// applied when it doesn't exist, and a resource to replace is applied once
+// the existing one is deleted.  Events are returned for the resources which
+// aren't applied, as if they had been.
+type MethodApplier struct {
+	Apply ApplyFunc
+
+	// Client and Mapper read and delete the resources with an apply method
+	Client dynamic.Interface
+	Mapper meta.RESTMapper
+
+	// Out if set is written the method chosen for each resource with an
+	// apply method, before the resources are applied
+	Out io.Writer
+
+	// DeleteTimeout is how long to wait for a resource being replaced to be
+	// deleted.  Defaults to a minute.
+	DeleteTimeout time.Duration
+
+	// printed are the resources whose method was written to Out, so it's
+	// only written once when the resources are applied in several runs
+	printed map[object.ObjMetadata]bool
+}
+
+// NewMethodApplier returns a MethodApplier applying the rest of the
+// resources with apply, which reads the resources with the clients of f.
+func NewMethodApplier(f util.Factory, apply ApplyFunc, out io.Writer) (*MethodApplier, error) {
+	client, err := f.DynamicClient()
+	if err != nil {
+		return nil, err
+	}
+	mapper, err := f.ToRESTMapper()
+	if err != nil {
+		return nil, err
+	}
+	return &MethodApplier{Apply: apply, Client: client, Mapper: mapper, Out: out}, nil
+}
+
+// methodDecision is what the apply method of a resource does with it.
+type methodDecision struct {
+	id     object.ObjMetadata
+	method ApplyMethod
+
+	// action describes the decision, e.g. "skip, exists"
+	action string
+
+	// apply is the resource to apply with Apply, or nil if it isn't applied
+	apply *unstructured.Unstructured
+
+	// event is the event returned for the resource if it isn't applied
+	event event.ApplyEvent
+}
+
+// Run applies objs, returning the events of the resources applied by Apply
+// and of those which aren't.  Prune events for the resources which aren't
+// applied are dropped, they are kept rather than pruned.
+func (a *MethodApplier) Run(ctx context.Context, inv inventory.InventoryInfo,
+	objs []*unstructured.Unstructured, options apply.Options) <-chan event.Event {
+	if a.printed == nil {
+		a.printed = map[object.ObjMetadata]bool{}
+	}
+	ch := make(chan event.Event)
+	go func() {
+		defer close(ch)
+		var run []*unstructured.Unstructured
+		var events []event.ApplyEvent
+		kept := map[object.ObjMetadata]bool{}
+		for _, obj := range objs {
+			method, err := Method(obj)
+			if err == nil && method == ApplyMethodApply {
+				run = append(run, obj)
+				continue
+			}
+			d := methodDecision{
+				id:     object.UnstructuredToObjMeta(obj),
+				method: ApplyMethod(obj.GetAnnotations()[ApplyMethodAnnotation]),
+			}
+			if err == nil {
+				d, err = a.decide(ctx, d, obj, options.DryRunStrategy)
+			}
+			if err != nil {
+				d.action = "fail, " + err.Error()
+				d.event = event.ApplyEvent{Operation: event.Failed, Error: err}
+			}
+			if a.Out != nil && !a.printed[d.id] {
+				a.printed[d.id] = true
+				fmt.Fprintf(a.Out, "%s: %s -- %s\n", FormatID(d.id), d.method, d.action)
+			}
+			if d.apply != nil {
+				run = append(run, d.apply)
+				continue
+			}
+			d.event.Identifier, d.event.Object = d.id, obj
+			events = append(events, d.event)
+			kept[d.id] = true
+		}
+
+		sent := false
+		send := func() {
+			for _, e := range events {
+				ch <- event.Event{Type: event.ApplyType, ApplyEvent: e}
+			}
+			sent = true
+		}
+		for e := range a.Apply(ctx, inv, run, options) {
+			switch e.Type {
+			case event.InitType:
+				e.InitEvent = initEvent(e.InitEvent, objs)
+				ch <- e
+				send()
+				continue
+			case event.ApplyType:
+				if e.ApplyEvent.Type == event.ApplyEventCompleted && !sent {
+					send()
+				}
+			case event.PruneType:
+				if kept[e.PruneEvent.Identifier] {
+					continue
+				}
+			}
+			ch <- e
+		}
+	}()
+	return ch
+}
+
+// decide decides how to apply obj with its method.
+func (a *MethodApplier) decide(ctx context.Context, d methodDecision, obj *unstructured.Unstructured,
+	dryRun common.DryRunStrategy) (methodDecision, error) {
+	client, err := a.client(obj)
+	if err != nil && !meta.IsNoMatchError(err) {
+		return d, err
+	}
+	var live *unstructured.Unstructured
+	if client != nil {
+		live, err = client.Get(ctx, obj.GetName(), metav1.GetOptions{})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return d, err
+		}
+		if err != nil {
+			live = nil
+		}
+	}
+
+	switch d.method {
+	case ApplyMethodCreateOnly:
+		if live == nil {
+			// kept when it's skipped by later applies, rather than pruned
+			d.action, d.apply = "create", obj.DeepCopy()
+			annotations := d.apply.GetAnnotations()
+			annotations[common.OnRemoveAnnotation] = common.OnRemoveKeep
+			d.apply.SetAnnotations(annotations)
+			return d, nil
+		}
+		d.action, d.event.Operation = "skip, exists", event.Unchanged
+		if live.GetAnnotations()[common.OnRemoveAnnotation] == common.OnRemoveKeep ||
+			dryRun.ClientOrServerDryRun() {
+			return d, nil
+		}
+		// created by an earlier apply before it was create-only
+		patch, err := json.Marshal(map[string]interface{}{"metadata": map[string]interface{}{
+			"annotations": map[string]string{common.OnRemoveAnnotation: common.OnRemoveKeep}}})
+		if err != nil {
+			return d, err
+		}
+		_, err = client.Patch(ctx, obj.GetName(), types.MergePatchType, patch, metav1.PatchOptions{})
+		return d, err
+
+	case ApplyMethodPatch:
+		if live == nil {
+			d.action, d.event.Operation = "fail, not found", event.Failed
+			d.event.Error = fmt.Errorf("%s not found: resources with %s %s aren't created",
+				FormatID(d.id), ApplyMethodAnnotation, ApplyMethodPatch)
+			return d, nil
+		}
+		d.action, d.apply = "patch", obj
+		return d, nil
+
+	case ApplyMethodReplace:
+		if live == nil {
+			d.action, d.apply = "create", obj
+			return d, nil
+		}
+		if subset(obj.Object, live.Object) {
+			d.action, d.apply = "apply, not changed", obj
+			return d, nil
+		}
+		d.action = "recreate, changed"
+		if dryRun.ClientOrServerDryRun() {
+			// the resource can't be applied until it's deleted
+			d.event.Operation = event.Created
+			return d, nil
+		}
+		if err := a.delete(ctx, client, obj.GetName()); err != nil {
+			return d, err
+		}
+		d.apply = obj
+		return d, nil
+	}
+	return d, nil
+}
+
+// client returns the client for the resource type of obj.
+func (a *MethodApplier) client(obj *unstructured.Unstructured) (dynamic.ResourceInterface, error) {
+	gvk := obj.GroupVersionKind()
+	mapping, err := a.Mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return nil, err
+	}
+	if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+		return a.Client.Resource(mapping.Resource).Namespace(obj.GetNamespace()), nil
+	}
+	return a.Client.Resource(mapping.Resource), nil
+}
+
+// delete deletes the resource name and waits until it's gone.
+func (a *MethodApplier) delete(ctx context.Context, client dynamic.ResourceInterface, name string) error {
+	background := metav1.DeletePropagationBackground
+	err := client.Delete(ctx, name, metav1.DeleteOptions{PropagationPolicy: &background})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return err
+	}
+	timeout := a.DeleteTimeout
+	if timeout == 0 {
+		timeout = time.Minute
+	}
+	return wait.PollImmediate(time.Second, timeout, func() (bool, error) {
+		_, err := client.Get(ctx, name, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			return true, nil
+		}
+		return false, err
+	})
+}
+
+// subset returns true if every field of want has the same value in got,
+// i.e. the resource want was applied and hasn't changed since.  Fields
+// defaulted by the server only exist in got.
+func subset(want, got interface{}) bool {
+	switch w := want.(type) {
+	case map[string]interface{}:
+		g, ok := got.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		for k, v := range w {
+			if gv, found := g[k]; !found || !subset(v, gv) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		g, ok := got.([]interface{})
+		if !ok || len(g) != len(w) {
+			return false
+		}
+		for i := range w {
+			if !subset(w[i], g[i]) {
+				return false
+			}
+		}
+		return true
+	default:
+		// numbers may be decoded as different types
+		return reflect.DeepEqual(want, got) || fmt.Sprint(want) == fmt.Sprint(got)
+	}
+}
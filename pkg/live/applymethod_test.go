@@ -0,0 +1,149 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package live
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"sigs.k8s.io/cli-utils/pkg/apply"
+	"sigs.k8s.io/cli-utils/pkg/apply/event"
+	"sigs.k8s.io/cli-utils/pkg/common"
+)
+
+// methodObj returns a resource with the apply method, and a field.
+func methodObj(apiVersion, kind, name, method, value string) *unstructured.Unstructured {
+	obj := newObj(apiVersion, kind, "prod", name, "")
+	if method != "" {
+		obj.SetAnnotations(map[string]string{ApplyMethodAnnotation: method})
+	}
+	obj.Object["data"] = map[string]interface{}{"value": value}
+	return obj
+}
+
+func newMethodApplier(f *fakeApply, live ...runtime.Object) (*MethodApplier, *bytes.Buffer) {
+	mapper := meta.NewDefaultRESTMapper(nil)
+	for _, gvk := range []schema.GroupVersionKind{
+		{Version: "v1", Kind: "Secret"},
+		{Version: "v1", Kind: "ConfigMap"},
+		{Group: "batch", Version: "v1", Kind: "Job"},
+	} {
+		mapper.Add(gvk, meta.RESTScopeNamespace)
+	}
+	out := &bytes.Buffer{}
+	return &MethodApplier{
+		Apply:  f.Run,
+		Client: dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(), live...),
+		Mapper: mapper,
+		Out:    out,
+	}, out
+}
+
+// TestMethodApplier_Run verifies the resources are applied with their
+// apply method.
+func TestMethodApplier_Run(t *testing.T) {
+	f := &fakeApply{}
+	a, out := newMethodApplier(f,
+		methodObj("v1", "Secret", "bootstrap", "", "cluster"),
+		methodObj("v1", "ConfigMap", "settings", "", "old"),
+		methodObj("batch/v1", "Job", "migrate", "replace", "v1"),
+		methodObj("batch/v1", "Job", "seed", "replace", "v1"),
+	)
+	objs := []*unstructured.Unstructured{
+		methodObj("v1", "ConfigMap", "config", "", "new"),
+		methodObj("v1", "Secret", "bootstrap", "create-only", "local"),
+		methodObj("v1", "Secret", "token", "create-only", "local"),
+		methodObj("v1", "ConfigMap", "settings", "patch", "new"),
+		methodObj("v1", "ConfigMap", "missing", "patch", "new"),
+		methodObj("batch/v1", "Job", "migrate", "replace", "v2"),
+		methodObj("batch/v1", "Job", "seed", "replace", "v1"),
+		methodObj("v1", "ConfigMap", "invalid", "upsert", "new"),
+	}
+
+	operations := map[string]event.ApplyEventOperation{}
+	var inits int
+	for e := range a.Run(context.Background(), nil, objs, apply.Options{}) {
+		switch {
+		case e.Type == event.InitType:
+			inits++
+			assert.Len(t, e.InitEvent.ResourceGroups[0].Identifiers, len(objs))
+		case e.Type == event.ApplyType && e.ApplyEvent.Type != event.ApplyEventCompleted:
+			operations[e.ApplyEvent.Identifier.Name] = e.ApplyEvent.Operation
+		}
+	}
+	assert.Equal(t, 1, inits)
+	assert.Equal(t, [][]string{{"config", "token", "settings", "migrate", "seed"}}, f.runs)
+	assert.Equal(t, map[string]event.ApplyEventOperation{
+		"config":    event.Created,
+		"bootstrap": event.Unchanged,
+		"token":     event.Created,
+		"settings":  event.Created,
+		"missing":   event.Failed,
+		"migrate":   event.Created,
+		"seed":      event.Created,
+		"invalid":   event.Failed,
+	}, operations)
+	assert.Equal(t, `prod/secret/bootstrap: create-only -- skip, exists
+prod/secret/token: create-only -- create
+prod/configmap/settings: patch -- patch
+prod/configmap/missing: patch -- fail, not found
+prod/job.batch/migrate: replace -- recreate, changed
+prod/job.batch/seed: replace -- apply, not changed
+prod/configmap/invalid: upsert -- fail, invalid config.kpt.dev/apply-method "upsert": `+
+		`must be one of apply,create-only,replace,patch
+`, out.String())
+
+	// the existing create-only resource is kept rather than pruned
+	secrets := a.Client.Resource(schema.GroupVersionResource{Version: "v1", Resource: "secrets"})
+	live, err := secrets.Namespace("prod").Get(context.Background(), "bootstrap", metav1.GetOptions{})
+	if assert.NoError(t, err) {
+		assert.Equal(t, common.OnRemoveKeep, live.GetAnnotations()[common.OnRemoveAnnotation])
+		assert.Equal(t, "cluster", live.Object["data"].(map[string]interface{})["value"])
+	}
+
+	// the changed resource to replace is deleted, to be recreated
+	jobs := a.Client.Resource(schema.GroupVersionResource{Group: "batch", Version: "v1", Resource: "jobs"})
+	_, err = jobs.Namespace("prod").Get(context.Background(), "migrate", metav1.GetOptions{})
+	assert.True(t, apierrors.IsNotFound(err))
+	_, err = jobs.Namespace("prod").Get(context.Background(), "seed", metav1.GetOptions{})
+	assert.NoError(t, err)
+}
+
+// TestMethodApplier_Run_dryRun verifies resources aren't changed by
+// previews.
+func TestMethodApplier_Run_dryRun(t *testing.T) {
+	f := &fakeApply{}
+	a, out := newMethodApplier(f,
+		methodObj("v1", "Secret", "bootstrap", "", "cluster"),
+		methodObj("batch/v1", "Job", "migrate", "replace", "v1"),
+	)
+	objs := []*unstructured.Unstructured{
+		methodObj("v1", "Secret", "bootstrap", "create-only", "local"),
+		methodObj("batch/v1", "Job", "migrate", "replace", "v2"),
+	}
+	for range a.Run(context.Background(), nil, objs, apply.Options{DryRunStrategy: common.DryRunClient}) {
+	}
+	assert.Equal(t, [][]string{nil}, f.runs)
+	assert.Equal(t, `prod/secret/bootstrap: create-only -- skip, exists
+prod/job.batch/migrate: replace -- recreate, changed
+`, out.String())
+
+	secrets := a.Client.Resource(schema.GroupVersionResource{Version: "v1", Resource: "secrets"})
+	live, err := secrets.Namespace("prod").Get(context.Background(), "bootstrap", metav1.GetOptions{})
+	if assert.NoError(t, err) {
+		assert.Empty(t, live.GetAnnotations())
+	}
+	jobs := a.Client.Resource(schema.GroupVersionResource{Group: "batch", Version: "v1", Resource: "jobs"})
+	_, err = jobs.Namespace("prod").Get(context.Background(), "migrate", metav1.GetOptions{})
+	assert.NoError(t, err)
+}
@@ -0,0 +1,230 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package live
+
+import (
+	"context"
+	"fmt"
+	"os/user"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/kubectl/pkg/cmd/util"
+	"sigs.k8s.io/cli-utils/pkg/apply/event"
+	"sigs.k8s.io/cli-utils/pkg/inventory"
+)
+
+// Annotations recording the apply run on audit events.
+const (
+	AuditUserAnnotation     = "config.kpt.dev/apply-user"
+	AuditKubeUserAnnotation = "config.kpt.dev/apply-kube-user"
+	AuditCommitAnnotation   = "config.kpt.dev/apply-commit"
+)
+
+// Reasons of audit events.
+const (
+	ApplySucceededReason = "ApplySucceeded"
+	ApplyFailedReason    = "ApplyFailed"
+)
+
+// AuditRecord records an apply run, for the Event describing it.
+type AuditRecord struct {
+	mu sync.Mutex
+
+	// User is the local user running the apply
+	User string
+
+	// KubeUser is the kubeconfig user the apply is run as
+	KubeUser string
+
+	// Commit is the git commit of the applied package, suffixed with
+	// -dirty if the package has uncommitted changes
+	Commit string
+
+	// Start and End are when the apply started and ended
+	Start, End time.Time
+
+	// Applied counts the applied resources by operation
+	Applied map[event.ApplyEventOperation]int
+
+	// Pruned, PruneSkipped and PruneFailed count the pruned resources
+	Pruned, PruneSkipped, PruneFailed int
+
+	// Err is the error the apply failed with, if any
+	Err error
+}
+
+// NewAuditRecord returns a record of an apply run by the current user as the
+// kubeconfig user of f.  The Commit is left to the caller.
+func NewAuditRecord(f util.Factory) *AuditRecord {
+	r := &AuditRecord{Start: time.Now(), Applied: map[event.ApplyEventOperation]int{}}
+	if u, err := user.Current(); err == nil {
+		r.User = u.Username
+	}
+	if raw, err := f.ToRawKubeConfigLoader().RawConfig(); err == nil {
+		if c, found := raw.Contexts[raw.CurrentContext]; found {
+			r.KubeUser = c.AuthInfo
+		}
+	}
+	return r
+}
+
+// Tap returns a channel with the events of ch, recording them.
+func (r *AuditRecord) Tap(ch <-chan event.Event) <-chan event.Event {
+	out := make(chan event.Event)
+	go func() {
+		defer close(out)
+		for e := range ch {
+			r.record(e)
+			out <- e
+		}
+	}()
+	return out
+}
+
+func (r *AuditRecord) record(e event.Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	switch e.Type {
+	case event.ErrorType:
+		r.Err = e.ErrorEvent.Err
+	case event.ApplyType:
+		if e.ApplyEvent.Type == event.ApplyEventResourceUpdate {
+			r.Applied[e.ApplyEvent.Operation]++
+		}
+	case event.PruneType:
+		switch {
+		case e.PruneEvent.Type == event.PruneEventFailed:
+			r.PruneFailed++
+		case e.PruneEvent.Type != event.PruneEventResourceUpdate:
+		case e.PruneEvent.Operation == event.Pruned:
+			r.Pruned++
+		case e.PruneEvent.Operation == event.PruneSkipped:
+			r.PruneSkipped++
+		}
+	}
+}
+
+// Finish records the end of the apply, and the error it failed with.
+func (r *AuditRecord) Finish(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.End = time.Now()
+	if err != nil {
+		r.Err = err
+	}
+}
+
+// Message summarizes the apply run.
+func (r *AuditRecord) Message() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	who := r.User
+	if who == "" {
+		who = "unknown user"
+	}
+	if r.KubeUser != "" {
+		who += fmt.Sprintf(" (kube user %s)", r.KubeUser)
+	}
+	at := ""
+	if r.Commit != "" {
+		at = fmt.Sprintf(" at commit %s", r.Commit)
+	}
+	result := "succeeded"
+	if r.Err != nil {
+		result = fmt.Sprintf("failed: %v", r.Err)
+	}
+	return fmt.Sprintf("kpt live apply by %s%s %s in %s: "+
+		"%d created, %d configured, %d unchanged, %d server-side applied, %d failed, "+
+		"%d pruned, %d prune skipped, %d prune failed",
+		who, at, result, r.End.Sub(r.Start).Round(time.Second),
+		r.Applied[event.Created], r.Applied[event.Configured], r.Applied[event.Unchanged],
+		r.Applied[event.ServersideApplied], r.Applied[event.Failed],
+		r.Pruned, r.PruneSkipped, r.PruneFailed)
+}
+
+// Event returns the Event describing the apply run, involving inv.
+func (r *AuditRecord) Event(inv *unstructured.Unstructured) *corev1.Event {
+	r.mu.Lock()
+	failed := r.Err != nil || r.Applied[event.Failed] > 0 || r.PruneFailed > 0
+	r.mu.Unlock()
+	reason, eventType := ApplySucceededReason, corev1.EventTypeNormal
+	if failed {
+		reason, eventType = ApplyFailedReason, corev1.EventTypeWarning
+	}
+	annotations := map[string]string{}
+	for k, v := range map[string]string{
+		AuditUserAnnotation:     r.User,
+		AuditKubeUserAnnotation: r.KubeUser,
+		AuditCommitAnnotation:   r.Commit,
+	} {
+		if v != "" {
+			annotations[k] = v
+		}
+	}
+	return &corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: inv.GetName() + ".",
+			Namespace:    inv.GetNamespace(),
+			Annotations:  annotations,
+		},
+		InvolvedObject: corev1.ObjectReference{
+			APIVersion: inv.GetAPIVersion(),
+			Kind:       inv.GetKind(),
+			Name:       inv.GetName(),
+			Namespace:  inv.GetNamespace(),
+			UID:        inv.GetUID(),
+		},
+		Reason:              reason,
+		Message:             r.Message(),
+		Type:                eventType,
+		Source:              corev1.EventSource{Component: "kpt"},
+		ReportingController: "kpt",
+		Action:              "Apply",
+		FirstTimestamp:      metav1.NewTime(r.Start),
+		LastTimestamp:       metav1.NewTime(r.End),
+		Count:               1,
+	}
+}
+
+// RecordAuditEvent creates the Event describing the apply run on the
+// inventory object in the cluster.
+func RecordAuditEvent(f util.Factory, inv inventory.InventoryInfo, r *AuditRecord) error {
+	obj := invToUnstructuredFunc(inv)
+	if obj == nil {
+		return fmt.Errorf("unsupported inventory %s/%s", inv.Namespace(), inv.Name())
+	}
+	obj = obj.DeepCopy()
+
+	// kubectl describe only lists the events of the object with its uid
+	mapper, err := f.ToRESTMapper()
+	if err != nil {
+		return err
+	}
+	gvk := obj.GroupVersionKind()
+	mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return err
+	}
+	client, err := f.DynamicClient()
+	if err != nil {
+		return err
+	}
+	live, err := client.Resource(mapping.Resource).Namespace(obj.GetNamespace()).
+		Get(context.Background(), obj.GetName(), metav1.GetOptions{})
+	if err == nil {
+		obj.SetUID(live.GetUID())
+	}
+
+	clientset, err := f.KubernetesClientSet()
+	if err != nil {
+		return err
+	}
+	_, err = clientset.CoreV1().Events(obj.GetNamespace()).
+		Create(context.Background(), r.Event(obj), metav1.CreateOptions{})
+	return err
+}
@@ -0,0 +1,61 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package live
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"sigs.k8s.io/cli-utils/pkg/apply/event"
+)
+
+func TestAuditRecord(t *testing.T) {
+	r := &AuditRecord{
+		User:     "alice",
+		KubeUser: "admin",
+		Commit:   "8b8ecd5",
+		Start:    time.Now(),
+		Applied:  map[event.ApplyEventOperation]int{},
+	}
+
+	ch := make(chan event.Event)
+	go func() {
+		defer close(ch)
+		for _, op := range []event.ApplyEventOperation{event.Created, event.Created, event.Configured,
+			event.Unchanged, event.Failed} {
+			ch <- event.Event{Type: event.ApplyType, ApplyEvent: event.ApplyEvent{Operation: op}}
+		}
+		ch <- event.Event{Type: event.ApplyType, ApplyEvent: event.ApplyEvent{Type: event.ApplyEventCompleted}}
+		ch <- event.Event{Type: event.PruneType, PruneEvent: event.PruneEvent{Operation: event.Pruned}}
+		ch <- event.Event{Type: event.PruneType, PruneEvent: event.PruneEvent{Type: event.PruneEventCompleted}}
+	}()
+	var n int
+	for range r.Tap(ch) {
+		n++
+	}
+	assert.Equal(t, 8, n)
+	r.Finish(fmt.Errorf("1 resources failed"))
+	r.End = r.Start.Add(3 * time.Second)
+
+	assert.Equal(t, "kpt live apply by alice (kube user admin) at commit 8b8ecd5 failed: "+
+		"1 resources failed in 3s: 2 created, 1 configured, 1 unchanged, 0 server-side applied, "+
+		"1 failed, 1 pruned, 0 prune skipped, 0 prune failed", r.Message())
+
+	inv := newObj("kpt.dev/v1alpha1", "ResourceGroup", "prod", "inventory", "")
+	e := r.Event(inv)
+	assert.Equal(t, "inventory.", e.GenerateName)
+	assert.Equal(t, "prod", e.Namespace)
+	assert.Equal(t, corev1.ObjectReference{APIVersion: "kpt.dev/v1alpha1", Kind: "ResourceGroup",
+		Name: "inventory", Namespace: "prod"}, e.InvolvedObject)
+	assert.Equal(t, ApplyFailedReason, e.Reason)
+	assert.Equal(t, corev1.EventTypeWarning, e.Type)
+	assert.Equal(t, map[string]string{
+		AuditUserAnnotation:     "alice",
+		AuditKubeUserAnnotation: "admin",
+		AuditCommitAnnotation:   "8b8ecd5",
+	}, e.Annotations)
+}
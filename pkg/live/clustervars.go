@@ -0,0 +1,294 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package live
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/kubectl/pkg/cmd/util"
+	"sigs.k8s.io/cli-utils/pkg/object"
+	"sigs.k8s.io/yaml"
+)
+
+// ClusterVarsAnnotation designates the fields of a resource which are set
+// from the cluster it's applied to, as a map from field path to cluster
+// variable, e.g.
+//
+//	config.kpt.dev/cluster-vars: |
+//	  spec.clusterName: cluster-name
+//	  metadata.labels[topology.kubernetes.io/region]: region
+//	  data.bucket: logs-${region}
+//
+// A value containing ${variable} references is a template, in which the
+// references are replaced.
+const ClusterVarsAnnotation = "config.kpt.dev/cluster-vars"
+
+// Cluster variables.
+const (
+	// ClusterNameVar is the name of the cluster of the current kube context
+	ClusterNameVar = "cluster-name"
+
+	// ContextVar is the name of the current kube context
+	ContextVar = "context"
+
+	// NamespaceVar is the namespace of the current kube context, or the
+	// namespace flag
+	NamespaceVar = "namespace"
+
+	// RegionVar is the region label of the nodes of the cluster
+	RegionVar = "region"
+)
+
+// ClusterVarNames are the cluster variables which are resolved from the
+// cluster.
+var ClusterVarNames = []string{ClusterNameVar, ContextVar, NamespaceVar, RegionVar}
+
+// regionLabels are the node labels the region is read from, in order of
+// preference.
+var regionLabels = []string{"topology.kubernetes.io/region", "failure-domain.beta.kubernetes.io/region"}
+
+// ClusterVars resolves the cluster variables of the cluster resources are
+// applied to.  Variables are resolved when they are first used, so the
+// cluster is only queried for the variables the resources reference.
+type ClusterVars struct {
+	// Config is the kubeconfig of the cluster
+	Config clientcmd.ClientConfig
+
+	// Context if set overrides the current context of Config, e.g. from the
+	// --context flag
+	Context string
+
+	// Client lists the nodes of the cluster
+	Client kubernetes.Interface
+
+	// Values are variables set explicitly, which take precedence over the
+	// resolved ones and may define other variables
+	Values map[string]string
+}
+
+// NewClusterVars returns the ClusterVars of the cluster of f, with the
+// kube context overridden by kubeContext if it's set.
+func NewClusterVars(f util.Factory, kubeContext string, values map[string]string) (*ClusterVars, error) {
+	client, err := f.KubernetesClientSet()
+	if err != nil {
+		return nil, err
+	}
+	return &ClusterVars{Config: f.ToRawKubeConfigLoader(), Context: kubeContext,
+		Client: client, Values: values}, nil
+}
+
+// Get returns the value of the variable name.
+func (v *ClusterVars) Get(name string) (string, error) {
+	if value, found := v.Values[name]; found {
+		return value, nil
+	}
+	value, err := v.resolve(name)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve cluster variable %q: %v", name, err)
+	}
+	if v.Values == nil {
+		v.Values = map[string]string{}
+	}
+	v.Values[name] = value
+	return value, nil
+}
+
+func (v *ClusterVars) resolve(name string) (string, error) {
+	switch name {
+	case ContextVar, ClusterNameVar:
+		raw, err := v.Config.RawConfig()
+		if err != nil {
+			return "", err
+		}
+		kubeContext := raw.CurrentContext
+		if v.Context != "" {
+			kubeContext = v.Context
+		}
+		if name == ContextVar {
+			if kubeContext == "" {
+				return "", fmt.Errorf("no current kube context")
+			}
+			return kubeContext, nil
+		}
+		c, found := raw.Contexts[kubeContext]
+		if !found || c.Cluster == "" {
+			return "", fmt.Errorf("kube context %q has no cluster", kubeContext)
+		}
+		return c.Cluster, nil
+	case NamespaceVar:
+		ns, _, err := v.Config.Namespace()
+		return ns, err
+	case RegionVar:
+		return v.region()
+	default:
+		return "", fmt.Errorf("unknown variable, must be one of %s or set with --cluster-var",
+			strings.Join(ClusterVarNames, ","))
+	}
+}
+
+// region returns the most common region label of the nodes.
+func (v *ClusterVars) region() (string, error) {
+	nodes, err := v.Client.CoreV1().Nodes().List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		return "", err
+	}
+	counts := map[string]int{}
+	for _, n := range nodes.Items {
+		for _, l := range regionLabels {
+			if r := n.Labels[l]; r != "" {
+				counts[r]++
+				break
+			}
+		}
+	}
+	var regions []string
+	for r := range counts {
+		regions = append(regions, r)
+	}
+	if len(regions) == 0 {
+		return "", fmt.Errorf("no nodes are labeled with %s", regionLabels[0])
+	}
+	sort.Slice(regions, func(i, j int) bool {
+		if counts[regions[i]] != counts[regions[j]] {
+			return counts[regions[i]] > counts[regions[j]]
+		}
+		return regions[i] < regions[j]
+	})
+	return regions[0], nil
+}
+
+var varReference = regexp.MustCompile(`\$\{([^}]+)\}`)
+
+// Inject sets the fields designated by the ClusterVarsAnnotation of each
+// of objs to their cluster variables.
+func (v *ClusterVars) Inject(objs []*unstructured.Unstructured) error {
+	for _, obj := range objs {
+		value, found := obj.GetAnnotations()[ClusterVarsAnnotation]
+		if !found {
+			continue
+		}
+		id := object.UnstructuredToObjMeta(obj)
+		fields := map[string]string{}
+		if err := yaml.Unmarshal([]byte(value), &fields); err != nil {
+			return fmt.Errorf("invalid %s of %s: %v", ClusterVarsAnnotation, id, err)
+		}
+		var paths []string
+		for p := range fields {
+			paths = append(paths, p)
+		}
+		sort.Strings(paths)
+		for _, p := range paths {
+			value, err := v.expand(fields[p])
+			if err != nil {
+				return fmt.Errorf("%s of %s: %v", p, id, err)
+			}
+			if err := setField(obj.Object, splitField(p), value); err != nil {
+				return fmt.Errorf("failed to set %s of %s: %v", p, id, err)
+			}
+		}
+	}
+	return nil
+}
+
+// expand returns the value of the variable, or the template with its
+// variable references replaced.
+func (v *ClusterVars) expand(template string) (string, error) {
+	if !varReference.MatchString(template) {
+		return v.Get(template)
+	}
+	var err error
+	value := varReference.ReplaceAllStringFunc(template, func(ref string) string {
+		value, getErr := v.Get(varReference.FindStringSubmatch(ref)[1])
+		if getErr != nil && err == nil {
+			err = getErr
+		}
+		return value
+	})
+	return value, err
+}
+
+// splitField splits a field path, e.g. spec.containers[0].env or
+// metadata.labels[example.com/key], into its keys and indexes.
+func splitField(p string) []string {
+	var fields []string
+	for p != "" {
+		if strings.HasPrefix(p, "[") {
+			end := strings.Index(p, "]")
+			if end < 0 {
+				return append(fields, p[1:])
+			}
+			fields = append(fields, p[:end+1])
+			p = strings.TrimPrefix(p[end+1:], ".")
+			continue
+		}
+		end := strings.IndexAny(p, ".[")
+		if end < 0 {
+			return append(fields, p)
+		}
+		fields = append(fields, p[:end])
+		p = strings.TrimPrefix(p[end:], ".")
+	}
+	return fields
+}
+
+// setField sets the field at path under obj to value, creating the maps on
+// the path which don't exist.  Numeric [n] elements index lists, others
+// are map keys.
+func setField(obj interface{}, path []string, value string) error {
+	key := path[0]
+	isIndex := false
+	if strings.HasPrefix(key, "[") {
+		key = strings.TrimSuffix(strings.TrimPrefix(key, "["), "]")
+		_, err := strconv.Atoi(key)
+		isIndex = err == nil
+	}
+	if isIndex {
+		list, ok := obj.([]interface{})
+		i, _ := strconv.Atoi(key)
+		if !ok || i >= len(list) {
+			return fmt.Errorf("no element %d", i)
+		}
+		if len(path) == 1 {
+			list[i] = value
+			return nil
+		}
+		return setField(list[i], path[1:], value)
+	}
+	m, ok := obj.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("%s is not in a map", key)
+	}
+	if len(path) == 1 {
+		m[key] = value
+		return nil
+	}
+	next, found := m[key]
+	if !found || next == nil {
+		next = map[string]interface{}{}
+		m[key] = next
+	}
+	return setField(next, path[1:], value)
+}
+
+// ParseClusterVars parses name=value pairs, e.g. from --cluster-var flags.
+func ParseClusterVars(pairs []string) (map[string]string, error) {
+	values := map[string]string{}
+	for _, p := range pairs {
+		parts := strings.SplitN(p, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("invalid cluster variable %q, must be NAME=VALUE", p)
+		}
+		values[parts[0]] = parts[1]
+	}
+	return values, nil
+}
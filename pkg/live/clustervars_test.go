@@ -0,0 +1,101 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package live
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+func node(name, region string) *corev1.Node {
+	return &corev1.Node{ObjectMeta: metav1.ObjectMeta{
+		Name: name, Labels: map[string]string{"topology.kubernetes.io/region": region}}}
+}
+
+func newClusterVars(values map[string]string) *ClusterVars {
+	config := clientcmdapi.NewConfig()
+	config.Clusters["prod-cluster"] = &clientcmdapi.Cluster{Server: "https://prod"}
+	config.Contexts["prod"] = &clientcmdapi.Context{Cluster: "prod-cluster", Namespace: "web"}
+	config.Contexts["staging"] = &clientcmdapi.Context{Cluster: "staging-cluster"}
+	config.CurrentContext = "prod"
+	return &ClusterVars{
+		Config: clientcmd.NewDefaultClientConfig(*config, &clientcmd.ConfigOverrides{}),
+		Client: fake.NewSimpleClientset(
+			node("a", "us-east1"), node("b", "us-west1"), node("c", "us-west1")),
+		Values: values,
+	}
+}
+
+// TestClusterVars_Inject verifies the designated fields are set to the
+// cluster variables.
+func TestClusterVars_Inject(t *testing.T) {
+	obj := newObj("v1", "ConfigMap", "web", "settings", "")
+	obj.SetAnnotations(map[string]string{ClusterVarsAnnotation: `
+data.cluster: cluster-name
+data.bucket: logs-${region}-${env}
+data.context: context
+metadata.labels[topology.kubernetes.io/region]: region
+spec.items[0].namespace: namespace
+`})
+	obj.Object["spec"] = map[string]interface{}{
+		"items": []interface{}{map[string]interface{}{"namespace": "default"}},
+	}
+
+	v := newClusterVars(map[string]string{"env": "prod"})
+	if !assert.NoError(t, v.Inject([]*unstructured.Unstructured{obj})) {
+		t.FailNow()
+	}
+	data, _, _ := unstructured.NestedStringMap(obj.Object, "data")
+	assert.Equal(t, map[string]string{
+		"cluster": "prod-cluster",
+		"bucket":  "logs-us-west1-prod",
+		"context": "prod",
+	}, data)
+	assert.Equal(t, "us-west1", obj.GetLabels()["topology.kubernetes.io/region"])
+	items, _, _ := unstructured.NestedSlice(obj.Object, "spec", "items")
+	assert.Equal(t, []interface{}{map[string]interface{}{"namespace": "web"}}, items)
+}
+
+// TestClusterVars_Get verifies the variables are resolved from the
+// overridden context, and the explicit values take precedence.
+func TestClusterVars_Get(t *testing.T) {
+	v := newClusterVars(map[string]string{RegionVar: "eu-west1"})
+	v.Context = "staging"
+	for name, expected := range map[string]string{
+		ContextVar:     "staging",
+		ClusterNameVar: "staging-cluster",
+		RegionVar:      "eu-west1",
+	} {
+		value, err := v.Get(name)
+		if assert.NoError(t, err) {
+			assert.Equal(t, expected, value, name)
+		}
+	}
+
+	_, err := v.Get("zone")
+	assert.EqualError(t, err, `failed to resolve cluster variable "zone": unknown variable, `+
+		`must be one of cluster-name,context,namespace,region or set with --cluster-var`)
+
+	v = newClusterVars(nil)
+	v.Client = fake.NewSimpleClientset()
+	_, err = v.Get(RegionVar)
+	assert.EqualError(t, err, `failed to resolve cluster variable "region": `+
+		`no nodes are labeled with topology.kubernetes.io/region`)
+}
+
+func TestParseClusterVars(t *testing.T) {
+	values, err := ParseClusterVars([]string{"env=prod", "region=us=1"})
+	if assert.NoError(t, err) {
+		assert.Equal(t, map[string]string{"env": "prod", "region": "us=1"}, values)
+	}
+	_, err = ParseClusterVars([]string{"env"})
+	assert.EqualError(t, err, `invalid cluster variable "env", must be NAME=VALUE`)
+}
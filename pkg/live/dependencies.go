@@ -0,0 +1,157 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package live
+
+import (
+	"fmt"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/cli-utils/pkg/object"
+)
+
+// DependsOnAnnotation declares the resources a resource depends on, as a
+// comma separated list of references:
+//
+//	GROUP/namespaces/NAMESPACE/KIND/NAME  for namespaced resources
+//	GROUP/KIND/NAME                       for cluster scoped resources
+//
+// The group is empty for the core group, e.g. /namespaces/prod/Secret/creds.
+const DependsOnAnnotation = "config.kubernetes.io/depends-on"
+
+// Dependencies returns the resources each of objs depends on.  A resource
+// depends on the resources in its DependsOnAnnotation, its Namespace and,
+// for custom resources, its CustomResourceDefinition.  Only dependencies on
+// resources in objs are returned.
+func Dependencies(objs []*unstructured.Unstructured) (map[object.ObjMetadata][]object.ObjMetadata, error) {
+	ids := map[object.ObjMetadata]bool{}
+	crds := map[schema.GroupKind]object.ObjMetadata{}
+	for _, obj := range objs {
+		id := object.UnstructuredToObjMeta(obj)
+		ids[id] = true
+		if id.GroupKind.Kind != "CustomResourceDefinition" {
+			continue
+		}
+		group, _, _ := unstructured.NestedString(obj.Object, "spec", "group")
+		kind, _, _ := unstructured.NestedString(obj.Object, "spec", "names", "kind")
+		crds[schema.GroupKind{Group: group, Kind: kind}] = id
+	}
+
+	deps := map[object.ObjMetadata][]object.ObjMetadata{}
+	for _, obj := range objs {
+		id := object.UnstructuredToObjMeta(obj)
+		seen := map[object.ObjMetadata]bool{id: true}
+		add := func(dep object.ObjMetadata) {
+			if ids[dep] && !seen[dep] {
+				seen[dep] = true
+				deps[id] = append(deps[id], dep)
+			}
+		}
+
+		if id.Namespace != "" {
+			add(object.ObjMetadata{Name: id.Namespace, GroupKind: schema.GroupKind{Kind: "Namespace"}})
+		}
+		if crd, found := crds[id.GroupKind]; found {
+			add(crd)
+		}
+		refs := obj.GetAnnotations()[DependsOnAnnotation]
+		for _, ref := range strings.Split(refs, ",") {
+			if strings.TrimSpace(ref) == "" {
+				continue
+			}
+			dep, err := parseDependency(strings.TrimSpace(ref))
+			if err != nil {
+				return nil, fmt.Errorf("%s: invalid %s annotation: %v",
+					FormatID(id), DependsOnAnnotation, err)
+			}
+			if !ids[dep] {
+				// dependencies on resources which aren't being applied are
+				// expected to already exist
+				continue
+			}
+			add(dep)
+		}
+	}
+	return deps, nil
+}
+
+// parseDependency parses a reference from the DependsOnAnnotation.
+func parseDependency(ref string) (object.ObjMetadata, error) {
+	parts := strings.Split(ref, "/")
+	switch {
+	case len(parts) == 3:
+		return object.ObjMetadata{Name: parts[2],
+			GroupKind: schema.GroupKind{Group: parts[0], Kind: parts[1]}}, nil
+	case len(parts) == 5 && parts[1] == "namespaces":
+		return object.ObjMetadata{Namespace: parts[2], Name: parts[4],
+			GroupKind: schema.GroupKind{Group: parts[0], Kind: parts[3]}}, nil
+	default:
+		return object.ObjMetadata{}, fmt.Errorf(
+			"%q must be GROUP/KIND/NAME or GROUP/namespaces/NAMESPACE/KIND/NAME", ref)
+	}
+}
+
+// Layers sorts objs into layers such that the resources in each layer only
+// depend on resources in earlier layers.  The order of objs is kept within
+// each layer.  Returns an error if the dependencies have a cycle.
+func Layers(objs []*unstructured.Unstructured, deps map[object.ObjMetadata][]object.ObjMetadata) (
+	[][]*unstructured.Unstructured, error) {
+	layer := map[object.ObjMetadata]int{}
+	visiting := map[object.ObjMetadata]bool{}
+	var visit func(id object.ObjMetadata, chain []object.ObjMetadata) (int, error)
+	visit = func(id object.ObjMetadata, chain []object.ObjMetadata) (int, error) {
+		if l, found := layer[id]; found {
+			return l, nil
+		}
+		chain = append(chain, id)
+		if visiting[id] {
+			var names []string
+			for _, c := range chain {
+				names = append(names, FormatID(c))
+			}
+			return 0, fmt.Errorf("dependencies have a cycle: %s", strings.Join(names, " -> "))
+		}
+		visiting[id] = true
+		l := 0
+		for _, dep := range deps[id] {
+			dl, err := visit(dep, chain)
+			if err != nil {
+				return 0, err
+			}
+			if dl+1 > l {
+				l = dl + 1
+			}
+		}
+		visiting[id] = false
+		layer[id] = l
+		return l, nil
+	}
+
+	var layers [][]*unstructured.Unstructured
+	for _, obj := range objs {
+		l, err := visit(object.UnstructuredToObjMeta(obj), nil)
+		if err != nil {
+			return nil, err
+		}
+		for len(layers) <= l {
+			layers = append(layers, nil)
+		}
+		layers[l] = append(layers[l], obj)
+	}
+	return layers, nil
+}
+
+// FormatID formats the identifier of a resource, e.g.
+// default/deployment.apps/nginx.
+func FormatID(id object.ObjMetadata) string {
+	kind := strings.ToLower(id.GroupKind.Kind)
+	if id.GroupKind.Group != "" {
+		kind += "." + id.GroupKind.Group
+	}
+	if id.Namespace != "" {
+		return fmt.Sprintf("%s/%s/%s", id.Namespace, kind, id.Name)
+	}
+	return fmt.Sprintf("%s/%s", kind, id.Name)
+}
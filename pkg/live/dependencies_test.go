@@ -0,0 +1,101 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package live
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/cli-utils/pkg/object"
+)
+
+// newObj returns a resource with the given depends-on annotation.
+func newObj(apiVersion, kind, namespace, name, dependsOn string) *unstructured.Unstructured {
+	obj := &unstructured.Unstructured{}
+	obj.SetAPIVersion(apiVersion)
+	obj.SetKind(kind)
+	obj.SetNamespace(namespace)
+	obj.SetName(name)
+	if dependsOn != "" {
+		obj.SetAnnotations(map[string]string{DependsOnAnnotation: dependsOn})
+	}
+	return obj
+}
+
+// names returns the names of the resources in each layer.
+func names(layers [][]*unstructured.Unstructured) [][]string {
+	var result [][]string
+	for _, layer := range layers {
+		var n []string
+		for _, obj := range layer {
+			n = append(n, obj.GetName())
+		}
+		result = append(result, n)
+	}
+	return result
+}
+
+func TestLayers(t *testing.T) {
+	crd := newObj("apiextensions.k8s.io/v1", "CustomResourceDefinition", "", "crontabs.example.com", "")
+	crd.Object["spec"] = map[string]interface{}{
+		"group": "example.com",
+		"names": map[string]interface{}{"kind": "CronTab"},
+	}
+	objs := []*unstructured.Unstructured{
+		newObj("apps/v1", "Deployment", "prod", "web", "/namespaces/prod/Secret/creds"),
+		newObj("v1", "Service", "prod", "web-svc", "apps/namespaces/prod/Deployment/web"),
+		newObj("v1", "Secret", "prod", "creds", ""),
+		newObj("example.com/v1", "CronTab", "", "backup", ""),
+		// dependencies which aren't applied are ignored
+		newObj("v1", "ConfigMap", "default", "config", "/namespaces/default/Secret/missing"),
+		newObj("v1", "Namespace", "", "prod", ""),
+		crd,
+	}
+
+	deps, err := Dependencies(objs)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, []object.ObjMetadata{
+		object.UnstructuredToObjMeta(objs[5]),
+		object.UnstructuredToObjMeta(objs[2]),
+	}, deps[object.UnstructuredToObjMeta(objs[0])])
+
+	layers, err := Layers(objs, deps)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, [][]string{
+		{"config", "prod", "crontabs.example.com"},
+		{"creds", "backup"},
+		{"web"},
+		{"web-svc"},
+	}, names(layers))
+}
+
+func TestLayers_cycle(t *testing.T) {
+	objs := []*unstructured.Unstructured{
+		newObj("v1", "ConfigMap", "default", "a", "/namespaces/default/ConfigMap/b"),
+		newObj("v1", "ConfigMap", "default", "b", "/namespaces/default/ConfigMap/a"),
+	}
+	deps, err := Dependencies(objs)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	_, err = Layers(objs, deps)
+	if assert.Error(t, err) {
+		assert.Equal(t, "dependencies have a cycle: default/configmap/a -> "+
+			"default/configmap/b -> default/configmap/a", err.Error())
+	}
+}
+
+func TestDependencies_invalid(t *testing.T) {
+	_, err := Dependencies([]*unstructured.Unstructured{
+		newObj("v1", "ConfigMap", "default", "a", "ConfigMap/b"),
+	})
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), `default/configmap/a: invalid config.kubernetes.io/depends-on annotation`)
+	}
+}
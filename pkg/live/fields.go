@@ -0,0 +1,71 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package live
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/cli-utils/pkg/object"
+)
+
+// FieldValue is the value of a field of a resource, e.g. injected from a
+// secret when the resource is applied.
+type FieldValue struct {
+	// Object identifies the resource.  An empty namespace matches the
+	// resource in any namespace, as its namespace may have been defaulted.
+	Object object.ObjMetadata
+
+	// Path is the path of the field, e.g. spec.containers[0].image
+	Path string
+
+	// Value is the value of the field
+	Value string
+}
+
+// SetFields sets the fields of objs to values.  Values of resources which
+// aren't in objs, e.g. local configuration which isn't applied, are
+// skipped.
+func SetFields(objs []*unstructured.Unstructured, values []FieldValue) error {
+	for _, v := range values {
+		for _, obj := range objs {
+			if !matches(v.Object, obj) {
+				continue
+			}
+			id := object.UnstructuredToObjMeta(obj)
+			if err := setField(obj.Object, splitField(v.Path), v.Value); err != nil {
+				return fmt.Errorf("failed to set %s of %s: %v", v.Path, id, err)
+			}
+		}
+	}
+	return nil
+}
+
+// ExcludeObjects returns objs without the resources identified by ids,
+// e.g. those excluded by their conditions.  An id without a namespace
+// matches the resource in any namespace.
+func ExcludeObjects(objs []*unstructured.Unstructured, ids []object.ObjMetadata) []*unstructured.Unstructured {
+	var included []*unstructured.Unstructured
+	for _, obj := range objs {
+		excluded := false
+		for _, id := range ids {
+			if matches(id, obj) {
+				excluded = true
+				break
+			}
+		}
+		if !excluded {
+			included = append(included, obj)
+		}
+	}
+	return included
+}
+
+// matches returns true if obj is the resource identified by id, in any
+// namespace if id has none.
+func matches(id object.ObjMetadata, obj *unstructured.Unstructured) bool {
+	objID := object.UnstructuredToObjMeta(obj)
+	return objID.GroupKind == id.GroupKind && objID.Name == id.Name &&
+		(id.Namespace == "" || objID.Namespace == id.Namespace)
+}
@@ -0,0 +1,58 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package live
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/cli-utils/pkg/object"
+)
+
+// TestSetFields verifies the fields of the matching resources are set, the
+// resources without a namespace matching any namespace.
+func TestSetFields(t *testing.T) {
+	prod := newObj("v1", "Secret", "prod", "db", "")
+	staging := newObj("v1", "Secret", "staging", "db", "")
+	cm := newObj("v1", "ConfigMap", "prod", "db", "")
+	objs := []*unstructured.Unstructured{prod, staging, cm}
+	secret := schema.GroupKind{Kind: "Secret"}
+
+	err := SetFields(objs, []FieldValue{
+		{Object: object.ObjMetadata{GroupKind: secret, Namespace: "prod", Name: "db"},
+			Path: "stringData.password", Value: "s3cr3t"},
+		{Object: object.ObjMetadata{GroupKind: secret, Name: "db"},
+			Path: "stringData[db.url]", Value: "postgres://db"},
+		{Object: object.ObjMetadata{GroupKind: secret, Name: "cache"},
+			Path: "stringData.password", Value: "skipped"},
+	})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	data, _, _ := unstructured.NestedStringMap(prod.Object, "stringData")
+	assert.Equal(t, map[string]string{"password": "s3cr3t", "db.url": "postgres://db"}, data)
+	data, _, _ = unstructured.NestedStringMap(staging.Object, "stringData")
+	assert.Equal(t, map[string]string{"db.url": "postgres://db"}, data)
+	_, found, _ := unstructured.NestedFieldNoCopy(cm.Object, "stringData")
+	assert.False(t, found)
+}
+
+// TestExcludeObjects verifies the matching resources are excluded, the
+// resources without a namespace matching any namespace.
+func TestExcludeObjects(t *testing.T) {
+	prod := newObj("v1", "Secret", "prod", "db", "")
+	staging := newObj("v1", "Secret", "staging", "db", "")
+	cm := newObj("v1", "ConfigMap", "prod", "db", "")
+	monitor := newObj("monitoring.coreos.com/v1", "ServiceMonitor", "prod", "db", "")
+	objs := []*unstructured.Unstructured{prod, staging, cm, monitor}
+
+	included := ExcludeObjects(objs, []object.ObjMetadata{
+		{GroupKind: schema.GroupKind{Kind: "Secret"}, Namespace: "staging", Name: "db"},
+		{GroupKind: schema.GroupKind{Group: "monitoring.coreos.com", Kind: "ServiceMonitor"}, Name: "db"},
+		{GroupKind: schema.GroupKind{Kind: "ConfigMap"}, Name: "cache"},
+	})
+	assert.Equal(t, []*unstructured.Unstructured{prod, cm}, included)
+}
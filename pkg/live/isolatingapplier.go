@@ -0,0 +1,220 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package live
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/cli-utils/pkg/apply"
+	"sigs.k8s.io/cli-utils/pkg/apply/event"
+	"sigs.k8s.io/cli-utils/pkg/inventory"
+	"sigs.k8s.io/cli-utils/pkg/object"
+)
+
+// ApplyFunc applies objs to the cluster, e.g. (*apply.Applier).Run.
+type ApplyFunc func(ctx context.Context, inv inventory.InventoryInfo,
+	objs []*unstructured.Unstructured, options apply.Options) <-chan event.Event
+
+// IsolatingApplier applies resources in dependency order, continuing past
+// resources which fail to apply instead of aborting.  Resources which
+// depend on a resource which failed, or was skipped, are skipped.
+//
+// The resources are applied in layers, see Layers.  Pruning only happens
+// if every resource was applied.
+type IsolatingApplier struct {
+	Apply ApplyFunc
+}
+
+// ApplyFailure is a resource which failed to apply, or was skipped.
+type ApplyFailure struct {
+	ID object.ObjMetadata
+
+	// Err is the error applying the resource, nil if the resource was
+	// skipped
+	Err error
+
+	// DependsOn is the failed or skipped dependency of a skipped resource
+	DependsOn object.ObjMetadata
+}
+
+// ApplyResult records the resources which failed to apply.  It is complete
+// once the event channel returned by IsolatingApplier.Run is closed.
+type ApplyResult struct {
+	Failed  []ApplyFailure
+	Skipped []ApplyFailure
+
+	// PruneSkipped is true if pruning was skipped because resources failed
+	// or were skipped
+	PruneSkipped bool
+
+	// Aborted is the error which stopped the apply, e.g. a timeout waiting
+	// for resources to reconcile
+	Aborted error
+}
+
+// Run applies objs in dependency order.  The events of every layer are
+// returned on a single channel, as if objs were applied at once.  Returns
+// an error if the dependencies of objs are invalid.
+func (a IsolatingApplier) Run(ctx context.Context, inv inventory.InventoryInfo,
+	objs []*unstructured.Unstructured, options apply.Options) (<-chan event.Event, *ApplyResult, error) {
+	deps, err := Dependencies(objs)
+	if err != nil {
+		return nil, nil, err
+	}
+	layers, err := Layers(objs, deps)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(layers) == 0 {
+		// still apply the empty set so previously applied resources are pruned
+		layers = [][]*unstructured.Unstructured{nil}
+	}
+
+	result := &ApplyResult{}
+	ch := make(chan event.Event)
+	go func() {
+		defer close(ch)
+		// blocked are the resources which failed or were skipped
+		blocked := map[object.ObjMetadata]bool{}
+		reported := map[object.ObjMetadata]bool{}
+		var applied []*unstructured.Unstructured
+		initSent, completedSent, aborted := false, false, false
+
+		for i, layer := range layers {
+			var eligible []*unstructured.Unstructured
+			for _, obj := range layer {
+				id := object.UnstructuredToObjMeta(obj)
+				if dep, found := firstBlocked(deps[id], blocked); found {
+					blocked[id] = true
+					result.Skipped = append(result.Skipped, ApplyFailure{ID: id, DependsOn: dep})
+					continue
+				}
+				eligible = append(eligible, obj)
+			}
+
+			last := i == len(layers)-1
+			opts := options
+			opts.NoPrune = true
+			runObjs := eligible
+			if last && !options.NoPrune {
+				if len(result.Failed)+len(result.Skipped) == 0 {
+					// prune computes the resources to delete from the
+					// resources of the run, so it needs all of them
+					opts.NoPrune = false
+					runObjs = append(append([]*unstructured.Unstructured{}, applied...), eligible...)
+				} else {
+					result.PruneSkipped = true
+				}
+			}
+			applied = append(applied, eligible...)
+			if len(runObjs) == 0 && opts.NoPrune {
+				continue
+			}
+
+			for e := range a.Apply(ctx, inv, runObjs, opts) {
+				if aborted {
+					// the printer stops reading after an error, drain the
+					// channel so the applier can finish
+					continue
+				}
+				switch e.Type {
+				case event.InitType:
+					if initSent {
+						continue
+					}
+					initSent = true
+					e.InitEvent = initEvent(e.InitEvent, objs)
+				case event.ErrorType:
+					aborted = true
+					result.Aborted = e.ErrorEvent.Err
+				case event.ApplyType:
+					if e.ApplyEvent.Type == event.ApplyEventCompleted {
+						if !last {
+							continue
+						}
+						completedSent = true
+						break
+					}
+					id := e.ApplyEvent.Identifier
+					if reported[id] {
+						// re-applied by the final run
+						continue
+					}
+					reported[id] = true
+					if e.ApplyEvent.Operation == event.Failed {
+						blocked[id] = true
+						result.Failed = append(result.Failed, ApplyFailure{ID: id, Err: e.ApplyEvent.Error})
+					}
+				}
+				ch <- e
+			}
+			if aborted {
+				return
+			}
+		}
+		if !completedSent {
+			ch <- event.Event{Type: event.ApplyType, ApplyEvent: event.ApplyEvent{Type: event.ApplyEventCompleted}}
+		}
+	}()
+	return ch, result, nil
+}
+
+// firstBlocked returns the first of deps which is blocked.
+func firstBlocked(deps []object.ObjMetadata, blocked map[object.ObjMetadata]bool) (object.ObjMetadata, bool) {
+	for _, dep := range deps {
+		if blocked[dep] {
+			return dep, true
+		}
+	}
+	return object.ObjMetadata{}, false
+}
+
+// initEvent returns the init event of applying objs at once, given the init
+// event of applying a subset of objs.
+func initEvent(e event.InitEvent, objs []*unstructured.Unstructured) event.InitEvent {
+	ids := object.UnstructuredsToObjMetas(objs)
+	var groups []event.ResourceGroup
+	for _, g := range e.ResourceGroups {
+		switch g.Action {
+		case event.ApplyAction:
+			g.Identifiers = ids
+		case event.PruneAction:
+			g.Identifiers = object.SetDiff(g.Identifiers, ids)
+		}
+		groups = append(groups, g)
+	}
+	return event.InitEvent{ResourceGroups: groups}
+}
+
+// Err returns an error summarizing the failed and skipped resources, or nil
+// if every resource was applied.
+func (r *ApplyResult) Err() error {
+	if len(r.Failed)+len(r.Skipped) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%d resource(s) failed to apply, %d resource(s) skipped",
+		len(r.Failed), len(r.Skipped))
+}
+
+// WriteSummary writes the failed and skipped resources to w.
+func (r *ApplyResult) WriteSummary(w io.Writer) {
+	if len(r.Failed) > 0 {
+		fmt.Fprintln(w, "failed:")
+		for _, f := range r.Failed {
+			fmt.Fprintf(w, "  %s: %v\n", FormatID(f.ID), f.Err)
+		}
+	}
+	if len(r.Skipped) > 0 {
+		fmt.Fprintln(w, "skipped:")
+		for _, s := range r.Skipped {
+			fmt.Fprintf(w, "  %s: depends on %s\n", FormatID(s.ID), FormatID(s.DependsOn))
+		}
+	}
+	if r.PruneSkipped {
+		fmt.Fprintln(w, "prune skipped: not every resource was applied")
+	}
+}
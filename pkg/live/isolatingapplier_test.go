@@ -0,0 +1,130 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package live
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/cli-utils/pkg/apply"
+	"sigs.k8s.io/cli-utils/pkg/apply/event"
+	"sigs.k8s.io/cli-utils/pkg/inventory"
+	"sigs.k8s.io/cli-utils/pkg/object"
+)
+
+// fakeApply applies resources, failing those named in fail, and records
+// the runs.
+type fakeApply struct {
+	fail   map[string]bool
+	runs   [][]string
+	prunes []bool
+}
+
+func (f *fakeApply) Run(_ context.Context, _ inventory.InventoryInfo,
+	objs []*unstructured.Unstructured, options apply.Options) <-chan event.Event {
+	ch := make(chan event.Event)
+	var run []string
+	for _, obj := range objs {
+		run = append(run, obj.GetName())
+	}
+	f.runs = append(f.runs, run)
+	f.prunes = append(f.prunes, !options.NoPrune)
+	go func() {
+		defer close(ch)
+		ch <- event.Event{Type: event.InitType, InitEvent: event.InitEvent{
+			ResourceGroups: []event.ResourceGroup{
+				{Action: event.ApplyAction, Identifiers: object.UnstructuredsToObjMetas(objs)},
+			}}}
+		for _, obj := range objs {
+			e := event.ApplyEvent{Identifier: object.UnstructuredToObjMeta(obj), Operation: event.Created}
+			if f.fail[obj.GetName()] {
+				e.Operation, e.Error = event.Failed, fmt.Errorf("denied by webhook")
+			}
+			ch <- event.Event{Type: event.ApplyType, ApplyEvent: e}
+		}
+		ch <- event.Event{Type: event.ApplyType, ApplyEvent: event.ApplyEvent{Type: event.ApplyEventCompleted}}
+	}()
+	return ch
+}
+
+func isolatingObjs() []*unstructured.Unstructured {
+	return []*unstructured.Unstructured{
+		newObj("v1", "Namespace", "", "prod", ""),
+		newObj("v1", "Secret", "prod", "creds", ""),
+		newObj("apps/v1", "Deployment", "prod", "web", "/namespaces/prod/Secret/creds"),
+		newObj("v1", "Service", "prod", "web-svc", "apps/namespaces/prod/Deployment/web"),
+		newObj("v1", "ConfigMap", "prod", "config", ""),
+	}
+}
+
+// collect returns the apply events of ch.
+func collect(ch <-chan event.Event) (applied []string, inits, completed int) {
+	for e := range ch {
+		switch {
+		case e.Type == event.InitType:
+			inits++
+		case e.Type == event.ApplyType && e.ApplyEvent.Type == event.ApplyEventCompleted:
+			completed++
+		case e.Type == event.ApplyType:
+			applied = append(applied, e.ApplyEvent.Identifier.Name)
+		}
+	}
+	return applied, inits, completed
+}
+
+// TestIsolatingApplier_Run verifies that resources are applied in
+// dependency order, and pruned by a final run with every resource.
+func TestIsolatingApplier_Run(t *testing.T) {
+	f := &fakeApply{}
+	ch, result, err := IsolatingApplier{Apply: f.Run}.Run(context.Background(), nil, isolatingObjs(), apply.Options{})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	applied, inits, completed := collect(ch)
+
+	assert.Equal(t, [][]string{
+		{"prod"},
+		{"creds", "config"},
+		{"web"},
+		{"prod", "creds", "config", "web", "web-svc"},
+	}, f.runs)
+	assert.Equal(t, []bool{false, false, false, true}, f.prunes)
+	assert.Equal(t, []string{"prod", "creds", "config", "web", "web-svc"}, applied)
+	assert.Equal(t, 1, inits)
+	assert.Equal(t, 1, completed)
+	assert.NoError(t, result.Err())
+}
+
+// TestIsolatingApplier_Run_failure verifies that resources depending on a
+// resource which failed are skipped, and the rest are applied.
+func TestIsolatingApplier_Run_failure(t *testing.T) {
+	f := &fakeApply{fail: map[string]bool{"creds": true}}
+	ch, result, err := IsolatingApplier{Apply: f.Run}.Run(context.Background(), nil, isolatingObjs(), apply.Options{})
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	applied, _, completed := collect(ch)
+
+	assert.Equal(t, [][]string{{"prod"}, {"creds", "config"}}, f.runs)
+	assert.Equal(t, []string{"prod", "creds", "config"}, applied)
+	assert.Equal(t, 1, completed)
+	assert.True(t, result.PruneSkipped)
+	if assert.Error(t, result.Err()) {
+		assert.Equal(t, "1 resource(s) failed to apply, 2 resource(s) skipped", result.Err().Error())
+	}
+
+	b := &bytes.Buffer{}
+	result.WriteSummary(b)
+	assert.Equal(t, `failed:
+  prod/secret/creds: denied by webhook
+skipped:
+  prod/deployment.apps/web: depends on prod/secret/creds
+  prod/service/web-svc: depends on prod/deployment.apps/web
+prune skipped: not every resource was applied
+`, b.String())
+}
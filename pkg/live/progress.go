@@ -0,0 +1,208 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package live
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/cli-utils/pkg/apply/event"
+	"sigs.k8s.io/cli-utils/pkg/inventory"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/status"
+	"sigs.k8s.io/cli-utils/pkg/object"
+)
+
+// ProgressDirEnv is the environment variable for the directory the
+// progress of applies is persisted to.  Defaults to UserHomeDir/.kpt/apply.
+const ProgressDirEnv = "KPT_APPLY_PROGRESS_DIR"
+
+// progressSaveInterval is how often the progress is saved while applying.
+const progressSaveInterval = time.Second
+
+// ResourcePhase is the phase a resource reached in an apply.
+type ResourcePhase string
+
+const (
+	// PhaseApplied is a resource which was applied
+	PhaseApplied ResourcePhase = "Applied"
+	// PhaseReconciled is a resource which was applied and reconciled
+	PhaseReconciled ResourcePhase = "Reconciled"
+)
+
+// ResourceProgress is the progress of a resource.
+type ResourceProgress struct {
+	Phase ResourcePhase `json:"phase"`
+
+	// Hash is the hash of the applied resource config, so resources which
+	// changed since they were applied are applied again
+	Hash string `json:"hash"`
+}
+
+// ApplyProgress is the progress of an apply, persisted so an interrupted
+// apply can be resumed without applying the resources it already applied.
+type ApplyProgress struct {
+	mu       sync.Mutex
+	path     string
+	lastSave time.Time
+
+	// Resources is the progress of the resources, by FormatID
+	Resources map[string]ResourceProgress `json:"resources"`
+}
+
+// ProgressDir returns the directory the progress of applies is persisted
+// to.
+func ProgressDir() (string, error) {
+	if dir := os.Getenv(ProgressDirEnv); dir != "" {
+		return dir, nil
+	}
+	dir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, ".kpt", "apply"), nil
+}
+
+// LoadApplyProgress loads the progress of applying the package with the
+// inventory inv from dir.  The progress is empty if there is none.
+func LoadApplyProgress(dir string, inv inventory.InventoryInfo) (*ApplyProgress, error) {
+	id := inv.ID()
+	if id == "" {
+		id = inv.Name()
+	}
+	p := &ApplyProgress{
+		path:      filepath.Join(dir, fmt.Sprintf("%s_%s.json", inv.Namespace(), id)),
+		Resources: map[string]ResourceProgress{},
+	}
+	b, err := ioutil.ReadFile(p.path)
+	if os.IsNotExist(err) {
+		return p, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, p); err != nil {
+		return nil, fmt.Errorf("invalid apply progress %s: %v", p.path, err)
+	}
+	if p.Resources == nil {
+		p.Resources = map[string]ResourceProgress{}
+	}
+	return p, nil
+}
+
+// Remaining returns the resources of objs which still need to be applied:
+// those which weren't applied, or changed since they were applied.  If
+// reconciled is true, resources which were applied but didn't reconcile
+// also need to be applied.  Returns the number of resources skipped.
+func (p *ApplyProgress) Remaining(objs []*unstructured.Unstructured, reconciled bool) (
+	[]*unstructured.Unstructured, int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var remaining []*unstructured.Unstructured
+	for _, obj := range objs {
+		hash, err := configHash(obj)
+		if err != nil {
+			return nil, 0, err
+		}
+		r, found := p.Resources[FormatID(object.UnstructuredToObjMeta(obj))]
+		if found && r.Hash == hash && (!reconciled || r.Phase == PhaseReconciled) {
+			continue
+		}
+		remaining = append(remaining, obj)
+	}
+	return remaining, len(objs) - len(remaining), nil
+}
+
+// Tap returns a channel with the events of applying objs from ch, recording
+// the progress of the resources.  The progress is saved periodically, Save
+// saves the rest once the events are read.
+func (p *ApplyProgress) Tap(objs []*unstructured.Unstructured, ch <-chan event.Event) <-chan event.Event {
+	hashes := map[string]string{}
+	for _, obj := range objs {
+		if hash, err := configHash(obj); err == nil {
+			hashes[FormatID(object.UnstructuredToObjMeta(obj))] = hash
+		}
+	}
+	out := make(chan event.Event)
+	go func() {
+		defer close(out)
+		for e := range ch {
+			if p.record(e, hashes) {
+				// errors are returned by the final Save
+				_ = p.Save()
+			}
+			out <- e
+		}
+	}()
+	return out
+}
+
+// record records the progress of the resource of e.  Returns true if the
+// progress is due to be saved.
+func (p *ApplyProgress) record(e event.Event, hashes map[string]string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	switch {
+	case e.Type == event.ApplyType && e.ApplyEvent.Type == event.ApplyEventResourceUpdate:
+		id := FormatID(e.ApplyEvent.Identifier)
+		if e.ApplyEvent.Operation == event.Failed || hashes[id] == "" {
+			delete(p.Resources, id)
+			break
+		}
+		p.Resources[id] = ResourceProgress{Phase: PhaseApplied, Hash: hashes[id]}
+	case e.Type == event.StatusType && e.StatusEvent.Type == event.StatusEventResourceUpdate &&
+		e.StatusEvent.Resource != nil && e.StatusEvent.Resource.Status == status.CurrentStatus:
+		id := FormatID(e.StatusEvent.Resource.Identifier)
+		if r, found := p.Resources[id]; found && r.Hash == hashes[id] {
+			r.Phase = PhaseReconciled
+			p.Resources[id] = r
+		}
+	}
+	return time.Since(p.lastSave) > progressSaveInterval
+}
+
+// Save persists the progress.
+func (p *ApplyProgress) Save() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.lastSave = time.Now()
+	b, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p.path), 0700); err != nil {
+		return err
+	}
+	// write to a temporary file first so an interrupted save doesn't lose
+	// the progress
+	tmp := p.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, b, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, p.path)
+}
+
+// Remove removes the persisted progress, once the apply is complete.
+func (p *ApplyProgress) Remove() error {
+	if err := os.Remove(p.path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// configHash returns the hash of the config of obj.
+func configHash(obj *unstructured.Unstructured) (string, error) {
+	// maps are marshalled with sorted keys, so the hash is stable
+	b, err := json.Marshal(obj.Object)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(b)), nil
+}
@@ -0,0 +1,106 @@
+// Copyright 2020 The Kubernetes Authors.
+// SPDX-License-Identifier: Apache-2.0
+
+package live
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/cli-utils/pkg/apply/event"
+	"sigs.k8s.io/cli-utils/pkg/common"
+	"sigs.k8s.io/cli-utils/pkg/inventory"
+	pollevent "sigs.k8s.io/cli-utils/pkg/kstatus/polling/event"
+	"sigs.k8s.io/cli-utils/pkg/kstatus/status"
+	"sigs.k8s.io/cli-utils/pkg/object"
+)
+
+func TestApplyProgress(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kpt-apply-progress")
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	defer os.RemoveAll(dir)
+
+	invObj := newObj("v1", "ConfigMap", "prod", "inventory", "")
+	invObj.SetLabels(map[string]string{common.InventoryLabel: "abc"})
+	inv := inventory.WrapInventoryInfoObj(invObj)
+
+	ns := newObj("v1", "Namespace", "", "prod", "")
+	cm := newObj("v1", "ConfigMap", "prod", "config", "")
+	deploy := newObj("apps/v1", "Deployment", "prod", "web", "")
+	objs := []*unstructured.Unstructured{ns, cm, deploy}
+
+	p, err := LoadApplyProgress(dir, inv)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	remaining, skipped, err := p.Remaining(objs, false)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, objs, remaining)
+	assert.Equal(t, 0, skipped)
+
+	// the apply is interrupted after applying the namespace and configmap,
+	// and reconciling the namespace
+	ch := make(chan event.Event)
+	go func() {
+		defer close(ch)
+		for _, obj := range []*unstructured.Unstructured{ns, cm} {
+			ch <- event.Event{Type: event.ApplyType, ApplyEvent: event.ApplyEvent{
+				Identifier: object.UnstructuredToObjMeta(obj), Operation: event.Created}}
+		}
+		ch <- event.Event{Type: event.ApplyType, ApplyEvent: event.ApplyEvent{
+			Identifier: object.UnstructuredToObjMeta(deploy), Operation: event.Failed}}
+		ch <- event.Event{Type: event.StatusType, StatusEvent: event.StatusEvent{
+			Resource: &pollevent.ResourceStatus{
+				Identifier: object.UnstructuredToObjMeta(ns), Status: status.CurrentStatus}}}
+	}()
+	for range p.Tap(objs, ch) {
+	}
+	if !assert.NoError(t, p.Save()) {
+		t.FailNow()
+	}
+
+	p, err = LoadApplyProgress(dir, inv)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, PhaseReconciled, p.Resources["namespace/prod"].Phase)
+	assert.Equal(t, PhaseApplied, p.Resources["prod/configmap/config"].Phase)
+
+	remaining, skipped, err = p.Remaining(objs, false)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, []*unstructured.Unstructured{deploy}, remaining)
+	assert.Equal(t, 2, skipped)
+
+	remaining, skipped, err = p.Remaining(objs, true)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, []*unstructured.Unstructured{cm, deploy}, remaining)
+	assert.Equal(t, 1, skipped)
+
+	// resources which changed since they were applied are applied again
+	cm.SetLabels(map[string]string{"changed": "true"})
+	remaining, _, err = p.Remaining(objs, false)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Equal(t, []*unstructured.Unstructured{cm, deploy}, remaining)
+
+	if !assert.NoError(t, p.Remove()) {
+		t.FailNow()
+	}
+	p, err = LoadApplyProgress(dir, inv)
+	if !assert.NoError(t, err) {
+		t.FailNow()
+	}
+	assert.Empty(t, p.Resources)
+}
@@ -25,9 +25,11 @@ import (
 
 	kptcommands "github.com/GoogleContainerTools/kpt/commands"
 	"github.com/GoogleContainerTools/kpt/internal/cmdcomplete"
+	"github.com/GoogleContainerTools/kpt/internal/cmdselfupdate"
 	"github.com/GoogleContainerTools/kpt/internal/docs/generated/overview"
 	"github.com/GoogleContainerTools/kpt/internal/util/cfgflags"
 	"github.com/GoogleContainerTools/kpt/internal/util/cmdutil"
+	"github.com/GoogleContainerTools/kpt/internal/util/functions"
 	kptopenapi "github.com/GoogleContainerTools/kpt/internal/util/openapi"
 	"github.com/GoogleContainerTools/kpt/pkg/kptfile"
 	"github.com/spf13/cobra"
@@ -78,6 +80,24 @@ func GetMain() *cobra.Command {
 
 	f := newFactory(cmd)
 
+	// the kubernetes container runtime runs functions in the cluster of the
+	// kubeconfig flags
+	functions.NewJob = func() (*functions.Job, error) {
+		config, err := f.ToRESTConfig()
+		if err != nil {
+			return nil, err
+		}
+		client, err := f.KubernetesClientSet()
+		if err != nil {
+			return nil, err
+		}
+		namespace, _, err := f.ToRawKubeConfigLoader().Namespace()
+		if err != nil {
+			return nil, err
+		}
+		return &functions.Job{Client: client, Config: config, Namespace: namespace}, nil
+	}
+
 	cmd.PersistentPreRunE = func(cmd *cobra.Command, args []string) error {
 		// register function to use Kptfile for OpenAPI
 		ext.KRMFileName = func() string {
@@ -141,6 +161,14 @@ func GetMain() *cobra.Command {
 	cmd.PersistentFlags().StringVar(&cmdutil.K8sSchemaPath, "k8s-schema-path",
 		"./openapi.json", "path to the kubernetes openAPI schema file")
 
+	cmd.PersistentFlags().IntVar(&cmdutil.Concurrency, "concurrency", 0,
+		"number of operations of each kind to run at once, e.g. git fetches.  "+
+			"defaults to $KPT_CONCURRENCY or the kpt config file")
+
+	cmd.PersistentFlags().StringVar(&cmdutil.ContainerRuntime, "container-runtime", "",
+		"container runtime functions are run with: docker, podman, nerdctl or "+
+			"kubernetes.  defaults to $KPT_CONTAINER_RUNTIME or the first installed")
+
 	if _, err := exec.LookPath("git"); err != nil {
 		fmt.Fprintf(os.Stderr, "kpt requires that `git` is installed and on the PATH")
 		os.Exit(1)
@@ -148,7 +176,8 @@ func GetMain() *cobra.Command {
 
 	replace(cmd)
 
-	cmd.AddCommand(versionCmd)
+	cmdutil.Version = version
+	cmd.AddCommand(versionCmd, cmdselfupdate.NewCommand("kpt", version))
 	hideFlags(cmd)
 	return cmd
 }